@@ -0,0 +1,56 @@
+const { validateConfig } = require('./config');
+const fabricDAO = require('./src/dao/FabricDAO');
+const { runInChannel } = require('./src/dao/channelContext');
+const { runAsIdentity } = require('./src/dao/identityContext');
+
+/**
+ * Certificate expiry check job
+ * Runs CheckExpiringCertifications, which emits a CertificationExpiring event for certificates nearing expiry;
+ * the event bridge forwards it to Kafka and webhooks so owners are notified. Meant to run daily from a scheduler,
+ * e.g. cron: 30 0 * * * cd /opt/ricetrace/my-js && npm run check:expiry
+ *
+ * Usage: node check-expiring-certs.js [--within=30] [--role=processor] [--channel=<name>] [--identity=<name>]
+ *   --within    Look-ahead window in days (default 30, at most 365)
+ *   --role      Submitting role (default processor)
+ *   --channel   Channel from the channel registry (default: default channel)
+ *   --identity  Identity of the role from the identity registry (default: User1 of the role's organization)
+ */
+
+function parseArgs(argv) {
+  const options = { within: '30', role: 'processor' };
+  for (const arg of argv) {
+    const match = arg.match(/^--([^=]+)=(.*)$/);
+    if (match) {
+      options[match[1]] = match[2];
+    }
+  }
+  return options;
+}
+
+async function check(options) {
+  const result = await fabricDAO.submitTransaction(options.role, 'QualityCertificationContract:CheckExpiringCertifications', options.within);
+  const report = JSON.parse(new TextDecoder().decode(result));
+
+  if (report.expiring.length === 0) {
+    console.log(`No certificates newly found to expire within ${report.withinDays} days`);
+    return;
+  }
+  console.log(`${report.expiring.length} certificate(s) expiring within ${report.withinDays} days:`);
+  for (const certificate of report.expiring) {
+    console.log(`  ${certificate.certificateId} (${certificate.certificateType}) of batch ${certificate.batchId}: ` +
+      `expires ${certificate.expiresAt.slice(0, 10)}, in ${certificate.daysRemaining} day(s); owner ${certificate.owner} (${certificate.ownerMspId})`);
+  }
+}
+
+async function run() {
+  validateConfig();
+  const options = parseArgs(process.argv.slice(2));
+  await runInChannel(options.channel, () => runAsIdentity(options.identity, () => check(options)));
+}
+
+run()
+  .catch(error => {
+    console.error('Certificate expiry check failed:', error.message);
+    process.exitCode = 1;
+  })
+  .finally(() => fabricDAO.cleanup());
@@ -1,3 +1,4 @@
+const fs = require('node:fs');
 const path = require('node:path');
 
 // Load environment variables
@@ -15,17 +16,100 @@ const env = {
   LOG_LEVEL: process.env.LOG_LEVEL || 'info'
 };
 
+/**
+ * Load the channel registry
+ * Each channel is a separate traceability network (e.g. one per province) served by the same organizations.
+ * Read from the JSON file at FABRIC_CHANNELS_PATH (default channels.json); without a file only the channel
+ * CHANNEL_NAME with chaincode CHAINCODE_NAME is served.
+ * @returns {{defaultChannel: string, channels: Object}} Default channel and channels by name
+ */
+function loadChannelRegistry() {
+  const channelName = process.env.CHANNEL_NAME || 'channel1';
+  const chaincodeName = process.env.CHAINCODE_NAME || 'basic';
+  const registryPath = process.env.FABRIC_CHANNELS_PATH || path.resolve(__dirname, 'channels.json');
+
+  if (!fs.existsSync(registryPath)) {
+    return {
+      defaultChannel: channelName,
+      channels: { [channelName]: { name: channelName, chaincodeName } }
+    };
+  }
+
+  const registry = JSON.parse(fs.readFileSync(registryPath, 'utf8'));
+  const channels = {};
+  for (const channel of registry.channels || []) {
+    channels[channel.name] = { chaincodeName, ...channel };
+  }
+  return {
+    defaultChannel: registry.defaultChannel || Object.keys(channels)[0],
+    channels
+  };
+}
+
+const channelRegistry = loadChannelRegistry();
+
+/**
+ * Load the identity registry
+ * Each identity is the credentials of one user of a role's organization, so one API instance can act for several
+ * farmers, processors or labs. Read from the JSON file at FABRIC_IDENTITIES_PATH (default identities.json); without
+ * a file each role acts as the User1 identity of its organization. An identity signs with the private key at keyPath,
+ * with a key held in a PKCS#11 HSM (hsm: { label, pin or pinEnv, identifier }), or offline (offline: true) when
+ * the client signs each transaction digest itself, e.g. with an air-gapped HSM. Relative paths are resolved
+ * against the registry file.
+ * @returns {{registryPath: string, hsmLibrary: string, identities: Object}} Registry file, PKCS#11 library and identities by name
+ */
+function loadIdentityRegistry() {
+  const hsmLibrary = process.env.PKCS11_LIB || '';
+  const registryPath = process.env.FABRIC_IDENTITIES_PATH || path.resolve(__dirname, 'identities.json');
+
+  if (!fs.existsSync(registryPath)) {
+    return { registryPath, hsmLibrary, identities: {} };
+  }
+
+  const registry = JSON.parse(fs.readFileSync(registryPath, 'utf8'));
+  const baseDir = path.dirname(registryPath);
+  const identities = {};
+  for (const identity of registry.identities || []) {
+    identities[identity.name] = {
+      ...identity,
+      certPath: path.resolve(baseDir, identity.certPath),
+      ...(identity.keyPath ? { keyPath: path.resolve(baseDir, identity.keyPath) } : {})
+    };
+  }
+  return {
+    registryPath,
+    hsmLibrary: registry.hsmLibrary || hsmLibrary,
+    identities
+  };
+}
+
+const identityRegistry = loadIdentityRegistry();
+
 // Hyperledger Fabric network configuration
 const fabric = {
-  channelName: process.env.CHANNEL_NAME || 'channel1',
-  chaincodeName: process.env.CHAINCODE_NAME || 'basic',
+  // Channel and chaincode used when a request does not select a channel
+  channelName: channelRegistry.defaultChannel,
+  chaincodeName: (channelRegistry.channels[channelRegistry.defaultChannel] || {}).chaincodeName,
+  channels: channelRegistry.channels,
+
+  // Identities requests can act as, in addition to the default User1 identity of each role
+  identities: identityRegistry.identities,
+  identitiesPath: identityRegistry.registryPath,
+  hsmLibrary: identityRegistry.hsmLibrary,
   
-  // Network timeout configuration
+  // Network timeout configuration (milliseconds)
   timeouts: {
-    evaluate: 5000,     // 5 seconds
-    endorse: 15000,     // 15 seconds
-    submit: 5000,       // 5 seconds
-    commitStatus: 60000 // 60 seconds
+    evaluate: parseInt(process.env.FABRIC_EVALUATE_TIMEOUT_MS, 10) || 5000,          // 5 seconds
+    endorse: parseInt(process.env.FABRIC_ENDORSE_TIMEOUT_MS, 10) || 15000,           // 15 seconds
+    submit: parseInt(process.env.FABRIC_SUBMIT_TIMEOUT_MS, 10) || 5000,              // 5 seconds
+    commitStatus: parseInt(process.env.FABRIC_COMMIT_STATUS_TIMEOUT_MS, 10) || 60000 // 60 seconds
+  },
+
+  // Resubmission of transactions invalidated by a concurrent write (MVCC_READ_CONFLICT / PHANTOM_READ_CONFLICT)
+  retry: {
+    maxAttempts: parseInt(process.env.FABRIC_SUBMIT_MAX_ATTEMPTS, 10) || 3,
+    initialDelay: 100, // 100 ms, doubled after each conflict
+    maxDelay: 2000     // 2 seconds
   }
 };
 
@@ -37,6 +121,8 @@ const organizations = {
     mspId: 'Org1MSP',
     peerPort: '7051',
     peerEndpoint: 'localhost:7051',
+    caEndpoint: 'https://localhost:7054',
+    caName: 'ca-org1',
     description: 'Farmer organization - responsible for creating batches, initial processing, and transfer'
   },
   processor: {
@@ -45,6 +131,8 @@ const organizations = {
     mspId: 'Org2MSP',
     peerPort: '9051',
     peerEndpoint: 'localhost:9051',
+    caEndpoint: 'https://localhost:8054',
+    caName: 'ca-org2',
     description: 'Processor organization - responsible for quality inspection, deep processing, product packaging, and transfer'
   },
   consumer: {
@@ -53,16 +141,44 @@ const organizations = {
     mspId: 'Org3MSP',
     peerPort: '11051',
     peerEndpoint: 'localhost:11051',
+    caEndpoint: 'https://localhost:11054',
+    caName: 'ca-org3',
     description: 'Consumer/regulatory organization - view traceability information'
   }
 };
 
 // Role permission configuration
 const permissions = {
-  farmer: ['getAll', 'create', 'getById'],
-  processor: ['getAll', 'transfer', 'addTest', 'addProcess', 'createProduct', 'getById'],
-  consumer: ['getAll', 'getById', 'getProduct'],
-  admin: ['getAll', 'create', 'getById', 'transfer', 'addTest', 'addProcess', 'createProduct', 'getProduct']
+  farmer: ['getAll', 'create', 'getById', 'commercialTerms', 'foreignReference', 'epcisCapture', 'insurance', 'weatherAnchor', 'attach', 'equipment', 'label', 'delegate', 'giCheck', 'consignment', 'notifications', 'reserve', 'correctRecord', 'certificate', 'recall', 'integrity', 'acknowledge', 'retention', 'settlement', 'branding', 'translate', 'scheduleTransfer', 'activateTransfer', 'resourceUsage', 'offlineSign'],
+  processor: ['getAll', 'transfer', 'addTest', 'addProcess', 'createProduct', 'returnProduct', 'getById', 'commercialTerms', 'foreignReference', 'epcisCapture', 'insurance', 'weatherAnchor', 'attach', 'equipment', 'label', 'giCheck', 'consignment', 'notifications', 'reserve', 'correctRecord', 'certificate', 'recall', 'integrity', 'acknowledge', 'retention', 'settlement', 'branding', 'translate', 'scheduleTransfer', 'activateTransfer', 'resourceUsage', 'offlineSign'],
+  consumer: ['getAll', 'getById', 'getProduct', 'returnProduct', 'accessLog', 'notifications', 'explorer', 'integrity', 'acknowledge', 'inspection', 'anomalies', 'activateTransfer'],
+  admin: ['getAll', 'create', 'getById', 'transfer', 'addTest', 'addProcess', 'createProduct', 'returnProduct', 'getProduct', 'commercialTerms', 'foreignReference', 'epcisCapture', 'insurance', 'weatherAnchor', 'attach', 'equipment', 'label', 'delegate', 'giCheck', 'giRule', 'complianceProfile', 'consignment', 'notifications', 'reserve', 'correctRecord', 'enroll', 'certificate', 'explorer', 'apiKeys', 'recall', 'eventReplay', 'acknowledge', 'retention', 'settlement', 'anomalies', 'branding', 'translate', 'scheduleTransfer', 'activateTransfer', 'resourceUsage', 'offlineSign', 'networkKpi']
+};
+
+// Chaincode transactions an offline identity may sign (POST /api/offline-transactions), with the permission its
+// role needs: the one the HTTP endpoint of the transaction checks. Other transactions cannot be signed offline
+const offlineSignableMethods = {
+  CreateRiceBatch: 'create',
+  TransferRiceBatch: 'transfer',
+  CompleteStepAndTransfer: 'transfer',
+  AddTestResult: 'addTest',
+  'QualityCertificationContract:RecordMoistureContent': 'addTest',
+  'QualityCertificationContract:RecordResidueLevels': 'addTest',
+  'QualityCertificationContract:RevokeTestResult': 'addTest',
+  AddProcessingRecord: 'addProcess',
+  AddProcessingRecords: 'addProcess',
+  CorrectProcessingRecord: 'correctRecord',
+  GrantDelegate: 'delegate',
+  RevokeDelegate: 'delegate',
+  CreateProduct: 'createProduct',
+  'ProductManagementContract:ReturnProduct': 'returnProduct',
+  'ConsignmentContract:CreateConsignment': 'consignment',
+  'ConsignmentContract:AdvanceConsignment': 'consignment',
+  'ConsignmentContract:TransferConsignment': 'consignment',
+  'RecallContract:IssueRecall': 'recall',
+  'RecallContract:AcknowledgeRecall': 'acknowledge',
+  'DocumentAnchorContract:AcknowledgeDocument': 'acknowledge',
+  'SettlementContract:RecordSettlement': 'settlement'
 };
 
 // Path configuration factory function
@@ -75,6 +191,7 @@ function getPaths(orgConfig) {
     certDirectoryPath: path.resolve(cryptoPath, 'users', `User1@${orgConfig.org}`, 'msp', 'signcerts'),
     keyDirectoryPath: path.resolve(cryptoPath, 'users', `User1@${orgConfig.org}`, 'msp', 'keystore'),
     tlsCertPath: path.resolve(cryptoPath, 'peers', `peer0.${orgConfig.org}`, 'tls', 'ca.crt'),
+    caCertPath: path.resolve(testNetworkPath, 'organizations', 'fabric-ca', orgConfig.org.split('.')[0], 'ca-cert.pem'),
     peerHostAlias: `peer0.${orgConfig.org}`
   };
 }
@@ -99,6 +216,21 @@ const cloudflareR2 = {
   endpoint: `https://${process.env.CLOUDFLARE_ACCOUNT_ID}.r2.cloudflarestorage.com`
 };
 
+// IPFS storage of attachment files (photos, lab reports, certificates)
+const ipfs = {
+  // Kubo RPC API of the node files are added to and read from
+  apiUrl: (process.env.IPFS_API_URL || 'http://127.0.0.1:5001').replace(/\/$/, ''),
+  // Authorization header value for hosted RPC endpoints, e.g. "Basic <base64 of projectId:secret>"
+  apiAuthorization: process.env.IPFS_API_AUTHORIZATION,
+  // Optional remote pinning service (IPFS Pinning Service API), so files outlive the gateway's node
+  pinningService: {
+    endpoint: (process.env.IPFS_PINNING_SERVICE_URL || '').replace(/\/$/, ''),
+    accessToken: process.env.IPFS_PINNING_SERVICE_TOKEN
+  },
+  maxFileSize: parseInt(process.env.IPFS_MAX_FILE_SIZE, 10) || 20 * 1024 * 1024, // 20MB
+  timeout: 60000 // 1 minute per IPFS request
+};
+
 // Redis configuration
 const redis = {
   host: process.env.REDIS_HOST || 'localhost',
@@ -111,17 +243,235 @@ const redis = {
     ttl: {
       batchList: 300,      // 5 minutes for batch list
       batchDetail: 600,    // 10 minutes for batch detail
-      batchExists: 300     // 5 minutes for batch existence check
+      batchExists: 300,    // 5 minutes for batch existence check
+      trace: 3600,         // 1 hour for consumer trace payloads (mostly immutable)
+      productDetail: 600,  // 10 minutes for product detail
+      labels: 600          // 10 minutes for the label dictionary of a locale
     },
     // Cache key prefixes
     keys: {
       batchList: 'batch:list',
       batchDetail: 'batch:detail',
-      batchExists: 'batch:exists'
+      batchExists: 'batch:exists',
+      trace: 'trace:product',
+      productDetail: 'product:detail',
+      batchDependents: 'batch:dependents',
+      labels: 'labels:dictionary'
+    },
+    // In-process layer in front of Redis for batch details, products and traces
+    memory: {
+      enabled: process.env.CACHE_MEMORY_ENABLED !== 'false',
+      ttl: parseInt(process.env.CACHE_MEMORY_TTL, 10) || 30, // seconds; bounds staleness if an event is missed
+      maxEntries: parseInt(process.env.CACHE_MEMORY_MAX_ENTRIES, 10) || 5000
+    },
+    // Clear cached entries when chaincode events announce a change, including writes made through other gateways
+    eventInvalidation: {
+      enabled: process.env.CACHE_EVENT_INVALIDATION !== 'false',
+      // Role whose identity listens for the events
+      role: process.env.CACHE_EVENT_ROLE || 'consumer',
+      retryDelay: 5000 // 5 seconds before reconnecting a failed event stream
     }
   }
 };
 
+// gRPC API configuration
+const grpcServer = {
+  port: process.env.GRPC_PORT || 50051,
+  protoPath: path.resolve(__dirname, 'proto', 'ricetrace.proto'),
+  serviceName: 'ricetrace.v1.TraceabilityService',
+  // Optional TLS; plaintext when not set
+  tlsCertPath: process.env.GRPC_TLS_CERT_PATH,
+  tlsKeyPath: process.env.GRPC_TLS_KEY_PATH
+};
+
+// Event bridge configuration (forwards chaincode events to Kafka and webhooks)
+const eventBridge = {
+  // Role whose identity is used to listen for chaincode events
+  role: process.env.EVENT_BRIDGE_ROLE || 'consumer',
+  // Channel to listen on (one bridge process per channel, each with its own checkpoint file)
+  channel: process.env.EVENT_BRIDGE_CHANNEL || fabric.channelName,
+  // Block to start from when no checkpoint exists yet
+  startBlock: process.env.EVENT_BRIDGE_START_BLOCK || '0',
+  checkpointPath: process.env.EVENT_BRIDGE_CHECKPOINT_PATH || path.resolve(__dirname, 'data', 'event-bridge-checkpoint.json'),
+  // Events that could not be delivered after all retries are appended here (JSON lines)
+  deadLetterPath: process.env.EVENT_BRIDGE_DEAD_LETTER_PATH || path.resolve(__dirname, 'data', 'event-bridge-dead-letter.jsonl'),
+  retry: {
+    maxAttempts: parseInt(process.env.EVENT_BRIDGE_MAX_ATTEMPTS, 10) || 5,
+    initialDelay: 1000, // 1 second, doubled after each failed attempt
+    maxDelay: 30000     // 30 seconds
+  },
+  kafka: {
+    brokers: (process.env.KAFKA_BROKERS || '').split(',').map(broker => broker.trim()).filter(Boolean),
+    clientId: process.env.KAFKA_CLIENT_ID || 'ricetrace-event-bridge',
+    topicPrefix: process.env.KAFKA_TOPIC_PREFIX || 'ricetrace' // Topic per event: <prefix>.<eventName>
+  },
+  webhooks: {
+    urls: (process.env.EVENT_WEBHOOK_URLS || '').split(',').map(url => url.trim()).filter(Boolean),
+    secret: process.env.EVENT_WEBHOOK_SECRET, // Optional HMAC-SHA256 signing secret
+    timeout: 10000 // 10 seconds
+  },
+  // Participant notifications routed by the preferences registered on the ledger
+  notifications: {
+    enabled: process.env.EVENT_NOTIFICATIONS_ENABLED === 'true',
+    // HTTP relays delivering email and SMS (POST { to, subject, text, message }); channels without a relay are skipped
+    emailRelayUrl: process.env.NOTIFY_EMAIL_RELAY_URL,
+    smsRelayUrl: process.env.NOTIFY_SMS_RELAY_URL
+  },
+  // Replays of past events to one sink (POST /api/events/replay), run by the API process
+  replay: {
+    maxBlocks: parseInt(process.env.EVENT_REPLAY_MAX_BLOCKS, 10) || 10000
+  }
+};
+
+// Transaction explorer: the event bridge process indexes every committed transaction by the batches and products
+// it touched, so support staff can list them without block-level access to the peers
+const explorer = {
+  indexEnabled: process.env.EXPLORER_INDEX_ENABLED === 'true',
+  // Role whose identity receives the blocks; its organization's peer must deliver full blocks
+  role: process.env.EXPLORER_ROLE || eventBridge.role,
+  channel: process.env.EXPLORER_CHANNEL || eventBridge.channel,
+  // Block to start from when no checkpoint exists yet
+  startBlock: process.env.EXPLORER_START_BLOCK || '0',
+  checkpointPath: process.env.EXPLORER_CHECKPOINT_PATH || path.resolve(__dirname, 'data', 'explorer-checkpoint.json'),
+  // Redis key prefix of the per-entity transaction lists
+  keyPrefix: 'explorer:entity',
+  maxPageSize: 200
+};
+
+// Reconciliation of the gateway's Redis copy of the ledger (batch details, existence checks and batch lists)
+// Runs in the event bridge process every intervalMinutes, or once with npm run reconcile
+const reconciliation = {
+  enabled: process.env.RECONCILE_ENABLED === 'true',
+  role: process.env.RECONCILE_ROLE || eventBridge.role,
+  channel: process.env.RECONCILE_CHANNEL || eventBridge.channel,
+  intervalMinutes: parseInt(process.env.RECONCILE_INTERVAL_MINUTES, 10) || 60,
+  // Evict drifted entries; when false, drift is only reported
+  repair: process.env.RECONCILE_REPAIR === 'true',
+  pageSize: 100,
+  reportPath: process.env.RECONCILE_REPORT_PATH || path.resolve(__dirname, 'data', 'reconciliation-report.json')
+};
+
+// Anomaly detection: the event bridge process applies rules to chaincode events and raises alerts for the audit team
+const anomalyDetection = {
+  enabled: process.env.ANOMALY_DETECTION_ENABLED === 'true',
+  role: process.env.ANOMALY_DETECTION_ROLE || eventBridge.role,
+  channel: process.env.ANOMALY_DETECTION_CHANNEL || eventBridge.channel,
+  // Block to start from when no checkpoint exists yet
+  startBlock: process.env.ANOMALY_DETECTION_START_BLOCK || '0',
+  checkpointPath: process.env.ANOMALY_DETECTION_CHECKPOINT_PATH || path.resolve(__dirname, 'data', 'anomaly-detection-checkpoint.json'),
+  // Audit team endpoints receiving each new alert (POST, JSON)
+  alertWebhookUrls: (process.env.ANOMALY_ALERT_WEBHOOK_URLS || '').split(',').map(url => url.trim()).filter(Boolean),
+  rules: {
+    // Handovers of one batch beyond which it is flagged
+    maxTransfers: parseInt(process.env.ANOMALY_MAX_TRANSFERS, 10) || 6,
+    // Share of a batch's declared quantity held by reservations at which it is flagged
+    reservedRatio: parseFloat(process.env.ANOMALY_RESERVED_RATIO) || 0.95
+  },
+  // Redis key prefix of the alerts
+  keyPrefix: 'anomaly:alerts',
+  maxPageSize: 200
+};
+
+// Access audit of sensitive views (private test reports, commercial terms)
+const accessAudit = {
+  // Record every read before serving it; disable only on development networks without the accessAudit collections
+  enabled: process.env.ACCESS_AUDIT_ENABLED !== 'false',
+  // Organization that reads the audit trail; must match RICETRACE_REGULATOR_MSP on the chaincode
+  regulatorMspId: process.env.ACCESS_AUDIT_REGULATOR_MSP || 'Org3MSP'
+};
+
+/**
+ * Load the application user mapping
+ * Maps the subject (sub claim) of API tokens to a role and optionally an identity of the identity registry, for
+ * identity providers that cannot issue the role claims. Read from the JSON file at AUTH_USERS_PATH (default
+ * users.json): { "users": [{ "subject", "role", "identity"? }] }
+ * @returns {Object} Mappings by subject
+ */
+function loadUserMappings() {
+  const mappingPath = process.env.AUTH_USERS_PATH || path.resolve(__dirname, 'users.json');
+  if (!fs.existsSync(mappingPath)) {
+    return {};
+  }
+
+  const mapping = JSON.parse(fs.readFileSync(mappingPath, 'utf8'));
+  const users = {};
+  for (const user of mapping.users || []) {
+    users[user.subject] = user;
+  }
+  return users;
+}
+
+// API authentication with bearer tokens (JWT signed with a shared secret, or by an OIDC provider)
+const auth = {
+  // Off by default so the X-User-Role header keeps working on development networks
+  enabled: process.env.AUTH_ENABLED === 'true',
+  // HS256 shared secret
+  jwtSecret: process.env.AUTH_JWT_SECRET,
+  // OIDC provider signing RS256/ES256 tokens; its keys are read from AUTH_JWKS_URI or the discovery document
+  issuer: process.env.AUTH_ISSUER,
+  jwksUri: process.env.AUTH_JWKS_URI,
+  audience: process.env.AUTH_AUDIENCE,
+  // Claims carrying the role (a string or a list of roles) and the identity of the identity registry
+  roleClaim: process.env.AUTH_ROLE_CLAIM || 'ricetrace_role',
+  identityClaim: process.env.AUTH_IDENTITY_CLAIM || 'fabric_identity',
+  clockToleranceSeconds: 60,
+  users: loadUserMappings()
+};
+
+// QR code labels printed on product packages and batch sacks
+const labels = {
+  // Public page consumers reach by scanning a label; /product/<id>?code=<code> and /batch/<id> are appended
+  traceBaseUrl: (process.env.PUBLIC_TRACE_URL || 'http://localhost:3000/trace').replace(/\/$/, ''),
+  maxSheetLabels: 200
+};
+
+// Consumer trace API (GET /api/trace/:productId), public and read-only
+const trace = {
+  // Role whose identity reads the ledger for anonymous consumers
+  role: process.env.TRACE_ROLE || 'consumer',
+  languages: ['zh', 'en'],
+  defaultLanguage: process.env.TRACE_DEFAULT_LANGUAGE || 'zh',
+  // Cache-Control max-age of trace responses, in seconds
+  maxAge: parseInt(process.env.TRACE_MAX_AGE, 10) || 300,
+  // Attachment categories shown to consumers; contracts and customs documents stay private
+  attachmentCategories: ['photo', 'labReport', 'certificate']
+};
+
+// API keys and rate limits of the public trace and verification endpoints
+// Counters are kept in Redis, so the limits hold across API instances; each instance counts alone while Redis is down
+const rateLimit = {
+  enabled: process.env.RATE_LIMIT_ENABLED !== 'false',
+  // Refuse requests without an X-API-Key; otherwise they share the anonymous limits, counted per client IP
+  requireApiKey: process.env.API_KEY_REQUIRED === 'true',
+  anonymous: {
+    requestsPerMinute: parseInt(process.env.RATE_LIMIT_ANONYMOUS_PER_MINUTE, 10) || 30,
+    dailyQuota: parseInt(process.env.RATE_LIMIT_ANONYMOUS_DAILY_QUOTA, 10) || 1000
+  },
+  // Limits of new API keys unless set when the key is created
+  apiKeyDefaults: {
+    requestsPerMinute: parseInt(process.env.RATE_LIMIT_API_KEY_PER_MINUTE, 10) || 600,
+    dailyQuota: parseInt(process.env.RATE_LIMIT_API_KEY_DAILY_QUOTA, 10) || 100000
+  },
+  keyPrefix: 'apikey',
+  counterPrefix: 'ratelimit'
+};
+
+// Participant onboarding through the organizations' Fabric CAs
+const enrollment = {
+  // CA registrar of each organization (bootstrap identity of the test network CAs)
+  registrarId: process.env.FABRIC_CA_REGISTRAR_ID || 'admin',
+  registrarSecret: process.env.FABRIC_CA_REGISTRAR_SECRET || 'adminpw',
+  affiliation: process.env.FABRIC_CA_AFFILIATION || '',
+  // Directory the certificates and private keys of enrolled participants are stored in
+  walletPath: process.env.FABRIC_WALLET_PATH || path.resolve(__dirname, 'wallet'),
+  // Participant role -> role whose organization enrolls and registers the participant
+  participantRoles: {
+    farmer: 'farmer',
+    tester: 'processor',
+    processor: 'processor'
+  }
+};
+
 // Supabase configuration
 const supabase = {
   url: process.env.SUPABASE_URL,
@@ -140,13 +490,21 @@ const supabase = {
 
 // Error code configuration
 const errorCodes = {
+  UNAUTHENTICATED: 'UNAUTHENTICATED',
   PERMISSION_DENIED: 'PERMISSION_DENIED',
   ROLE_MISSING: 'ROLE_MISSING',
   VALIDATION_ERROR: 'VALIDATION_ERROR',
   FABRIC_ERROR: 'FABRIC_ERROR',
+  FABRIC_TIMEOUT: 'FABRIC_TIMEOUT',
+  TRANSACTION_INVALID: 'TRANSACTION_INVALID',
+  ALREADY_EXISTS: 'ALREADY_EXISTS',
   NOT_FOUND: 'NOT_FOUND',
   INTERNAL_ERROR: 'INTERNAL_ERROR',
   ORACLE_ERROR: 'ORACLE_ERROR',
+  STORAGE_ERROR: 'STORAGE_ERROR',
+  RATE_LIMITED: 'RATE_LIMITED',
+  QUOTA_EXCEEDED: 'QUOTA_EXCEEDED',
+  PAYLOAD_TOO_LARGE: 'PAYLOAD_TOO_LARGE',
   ORACLE_VERIFICATION_FAILED: 'ORACLE_VERIFICATION_FAILED'
 };
 
@@ -161,6 +519,26 @@ function validateConfig() {
       throw new Error(`Missing permissions for role: ${role}`);
     }
   }
+
+  if (!fabric.channels[fabric.channelName]) {
+    throw new Error(`Default channel ${fabric.channelName} is not in the channel registry`);
+  }
+
+  if (auth.enabled && !auth.jwtSecret && !auth.issuer && !auth.jwksUri) {
+    throw new Error('AUTH_ENABLED requires AUTH_JWT_SECRET, AUTH_ISSUER or AUTH_JWKS_URI');
+  }
+
+  for (const identity of Object.values(fabric.identities)) {
+    if (!organizations[identity.role]) {
+      throw new Error(`Identity ${identity.name} has unknown role: ${identity.role}`);
+    }
+    if (!identity.keyPath && !identity.hsm && !identity.offline) {
+      throw new Error(`Identity ${identity.name} needs a keyPath, an hsm signer or offline signing`);
+    }
+    if (identity.hsm && !fabric.hsmLibrary) {
+      throw new Error(`Identity ${identity.name} signs with an HSM, but no PKCS#11 library is configured (hsmLibrary or PKCS11_LIB)`);
+    }
+  }
   
   console.log('Configuration validation passed');
 }
@@ -179,6 +557,30 @@ function getRoleConfig(role) {
   };
 }
 
+// Get channel configuration
+function getChannelConfig(channel) {
+  const channelConfig = fabric.channels[channel];
+  if (!channelConfig) {
+    throw new Error(`Unknown channel: ${channel}. Available channels: ${Object.keys(fabric.channels).join(', ')}`);
+  }
+  return channelConfig;
+}
+
+// Get identity configuration; the role's default identity when no name is given
+function getIdentityConfig(role, name) {
+  if (!name) {
+    return null;
+  }
+  const identity = fabric.identities[name];
+  if (!identity) {
+    throw new Error(`Unknown identity: ${name}. Available identities: ${Object.keys(fabric.identities).join(', ') || 'none'}`);
+  }
+  if (identity.role !== role) {
+    throw new Error(`Identity ${name} belongs to role ${identity.role}, not ${role}`);
+  }
+  return identity;
+}
+
 // Get all available roles
 function getAvailableRoles() {
   return Object.keys(organizations);
@@ -190,21 +592,43 @@ function hasPermission(role, permission) {
   return rolePermissions && rolePermissions.includes(permission);
 }
 
+// Check if role may sign a chaincode transaction offline
+function canSignOffline(role, method) {
+  const permission = offlineSignableMethods[method];
+  return Boolean(permission) && hasPermission(role, permission);
+}
+
 module.exports = {
   env,
   fabric,
   organizations,
   permissions,
+  offlineSignableMethods,
   oracleServices,
   cloudflareR2,
+  ipfs,
   redis,
+  grpcServer,
+  eventBridge,
+  explorer,
+  reconciliation,
+  anomalyDetection,
+  accessAudit,
+  auth,
+  labels,
+  trace,
+  rateLimit,
+  enrollment,
   supabase,
   errorCodes,
   
   // Utility functions
   validateConfig,
   getRoleConfig,
+  getChannelConfig,
+  getIdentityConfig,
   getAvailableRoles,
   hasPermission,
+  canSignOffline,
   getPaths
 }; 
\ No newline at end of file
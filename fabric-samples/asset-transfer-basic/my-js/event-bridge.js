@@ -0,0 +1,54 @@
+const { validateConfig, explorer, reconciliation, anomalyDetection } = require('./config');
+const eventBridgeService = require('./src/services/EventBridgeService');
+const explorerService = require('./src/services/ExplorerService');
+const reconciliationService = require('./src/services/ReconciliationService');
+const anomalyDetectionService = require('./src/services/AnomalyDetectionService');
+const cacheService = require('./src/services/CacheService');
+const fabricDAO = require('./src/dao/FabricDAO');
+
+/**
+ * Event bridge process
+ * Runs separately from the API server: forwards chaincode events to Kafka and webhooks and, with
+ * EXPLORER_INDEX_ENABLED, indexes the transactions of every block for the transaction explorer. With
+ * RECONCILE_ENABLED, it also compares the gateway's cache with the ledger periodically, and with
+ * ANOMALY_DETECTION_ENABLED it flags suspicious patterns for the audit team
+ */
+
+// Validate configuration
+validateConfig();
+
+// The indexer, reconciliation and anomaly detection may run alone; the bridge then only starts when it has targets
+const services = [
+  ...(explorer.indexEnabled ? [explorerService] : []),
+  ...(reconciliation.enabled ? [reconciliationService] : []),
+  ...(anomalyDetection.enabled ? [anomalyDetectionService] : [])
+];
+if (services.length === 0 || eventBridgeService.hasTargets()) {
+  services.push(eventBridgeService);
+}
+
+async function shutdown(signal) {
+  console.log(`Received ${signal} signal, stopping event bridge...`);
+  for (const service of services) {
+    await service.stop();
+  }
+  await cacheService.disconnect();
+  await fabricDAO.cleanup();
+  for (const service of services) {
+    console.log('Stopped', service.getStatus());
+  }
+  process.exit(0);
+}
+
+process.on('SIGTERM', () => shutdown('SIGTERM'));
+process.on('SIGINT', () => shutdown('SIGINT'));
+
+Promise.all(services.map(service => service.start()))
+  .then(() => {
+    console.log('Event stream ended');
+    process.exit(0);
+  })
+  .catch(error => {
+    console.error('Event bridge failed:', error.message);
+    process.exit(1);
+  });
@@ -0,0 +1,48 @@
+const fs = require('node:fs');
+const grpc = require('@grpc/grpc-js');
+const { grpcServer, validateConfig } = require('./config');
+const { loadServiceDefinition } = require('./src/grpc/protoLoader');
+const traceabilityHandlers = require('./src/grpc/traceabilityHandlers');
+const fabricDAO = require('./src/dao/FabricDAO');
+
+/**
+ * gRPC API server
+ * Exposes the traceability operations defined in proto/ricetrace.proto
+ */
+
+// Validate configuration
+validateConfig();
+
+function createCredentials() {
+  if (grpcServer.tlsCertPath && grpcServer.tlsKeyPath) {
+    return grpc.ServerCredentials.createSsl(null, [{
+      cert_chain: fs.readFileSync(grpcServer.tlsCertPath),
+      private_key: fs.readFileSync(grpcServer.tlsKeyPath)
+    }], false);
+  }
+  return grpc.ServerCredentials.createInsecure();
+}
+
+const server = new grpc.Server();
+server.addService(loadServiceDefinition(grpcServer.protoPath, grpcServer.serviceName), traceabilityHandlers);
+
+server.bindAsync(`0.0.0.0:${grpcServer.port}`, createCredentials(), (error, port) => {
+  if (error) {
+    console.error('Failed to start gRPC server:', error.message);
+    process.exit(1);
+  }
+  console.log(`gRPC server (${grpcServer.serviceName}) listening on port ${port}`);
+});
+
+// Graceful shutdown
+function shutdown(signal) {
+  console.log(`Received ${signal} signal, shutting down gRPC server...`);
+  server.tryShutdown(async () => {
+    await fabricDAO.cleanup();
+    console.log('gRPC server closed');
+    process.exit(0);
+  });
+}
+
+process.on('SIGTERM', () => shutdown('SIGTERM'));
+process.on('SIGINT', () => shutdown('SIGINT'));
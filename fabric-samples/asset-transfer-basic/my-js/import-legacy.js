@@ -0,0 +1,385 @@
+const fs = require('node:fs');
+const path = require('node:path');
+const { validateConfig } = require('./config');
+const fabricDAO = require('./src/dao/FabricDAO');
+const { runInChannel } = require('./src/dao/channelContext');
+
+/**
+ * Legacy data import tool
+ * Migrates batch and product records exported from paper registers or an ERP (CSV, or an Excel sheet saved as
+ * CSV / Unicode text) onto the ledger. Rows are validated and normalized locally, then submitted as
+ * CreateRiceBatch / CreateProduct transactions, several at a time. Rows rejected locally or by the chaincode
+ * are written to an error report with their row number and reason; fix them and re-run the same files, since
+ * already imported rows are recognized by their client request ID and not recorded again.
+ *
+ * Usage: node import-legacy.js [--batches=batches.csv] [--products=products.csv] [--concurrency=5]
+ *          [--date-format=YMD] [--report=import-errors.csv] [--dry-run] [--channel=<name>]
+ *   --batches      Batch records: batchId, origin, variety, harvestDate, owner, operator, [step], [testResult],
+ *                  [reportId], [workflowId], [cropYear], [season], [notes]; submitted as farmer
+ *   --products     Product records: productId, batchId, packageDate, owner; submitted as processor
+ *   --concurrency  Transactions in flight (default 5)
+ *   --date-format  Order of day, month and year in dates like 03/09/2024: YMD (default), DMY or MDY
+ *   --report       Error report path (default import-errors.csv)
+ *   --dry-run      Validate and normalize only, submit nothing
+ *   --channel      Channel from the channel registry (default: default channel)
+ */
+
+const DEFAULT_BATCH_STEP = 'Harvested';
+const LEGACY_VERIFICATION_SOURCE = 'LegacyImport';
+
+// Normalized header (lowercase, no spaces, dashes or underscores) -> field
+const COLUMN_ALIASES = {
+  batchid: 'batchId', batch: 'batchId', batchno: 'batchId', batchnumber: 'batchId', lot: 'batchId', lotno: 'batchId',
+  origin: 'origin', location: 'origin', farm: 'origin', region: 'origin',
+  variety: 'variety', cultivar: 'variety',
+  harvestdate: 'harvestDate', harvested: 'harvestDate',
+  owner: 'owner', currentowner: 'owner',
+  operator: 'operator', recordedby: 'operator',
+  step: 'step', initialstep: 'step', state: 'step',
+  testresult: 'testResult', result: 'testResult', inspection: 'testResult',
+  reportid: 'reportId',
+  workflowid: 'workflowId', workflow: 'workflowId',
+  cropyear: 'cropYear', season: 'season',
+  notes: 'notes', remarks: 'notes', comment: 'notes',
+  productid: 'productId', product: 'productId', sku: 'productId', serial: 'productId',
+  packagedate: 'packageDate', packdate: 'packageDate', packaged: 'packageDate'
+};
+
+const BATCH_REQUIRED = ['batchId', 'origin', 'variety', 'harvestDate', 'owner', 'operator'];
+const PRODUCT_REQUIRED = ['productId', 'batchId', 'packageDate', 'owner'];
+
+const ID_PATTERN = /^[A-Za-z0-9][A-Za-z0-9._-]*$/;
+
+function parseArgs(argv) {
+  const options = { concurrency: '5', 'date-format': 'YMD', report: 'import-errors.csv' };
+  for (const arg of argv) {
+    const match = arg.match(/^--([^=]+)(?:=(.*))?$/);
+    if (match) {
+      options[match[1]] = match[2] === undefined ? true : match[2];
+    }
+  }
+  return {
+    batches: options.batches,
+    products: options.products,
+    concurrency: parseInt(options.concurrency, 10),
+    dateFormat: String(options['date-format']).toUpperCase(),
+    report: path.resolve(options.report),
+    dryRun: options['dry-run'] === true,
+    channel: options.channel
+  };
+}
+
+/**
+ * Parse CSV text (RFC 4180 quoting) into header-keyed rows
+ * The delimiter (comma, semicolon or tab) is taken from the header line, so Excel exports of any locale work.
+ * @returns {Array<{rowNumber: number, values: Object}>} Rows with their line number in the file (header = 1)
+ */
+function parseCsv(text) {
+  const content = text.replace(/^\uFEFF/, '');
+  const headerLine = content.split(/\r?\n/, 1)[0];
+  const delimiter = [',', ';', '\t'].reduce((best, candidate) =>
+    headerLine.split(candidate).length > headerLine.split(best).length ? candidate : best, ',');
+
+  const records = [];
+  let record = [];
+  let field = '';
+  let quoted = false;
+  let line = 1;
+  let recordLine = 1;
+
+  for (let i = 0; i < content.length; i++) {
+    const char = content[i];
+    if (quoted) {
+      if (char === '"' && content[i + 1] === '"') {
+        field += '"';
+        i++;
+      } else if (char === '"') {
+        quoted = false;
+      } else {
+        if (char === '\n') {
+          line++;
+        }
+        field += char;
+      }
+    } else if (char === '"') {
+      quoted = true;
+    } else if (char === delimiter) {
+      record.push(field);
+      field = '';
+    } else if (char === '\n' || char === '\r') {
+      if (char === '\r' && content[i + 1] === '\n') {
+        i++;
+      }
+      record.push(field);
+      records.push({ line: recordLine, fields: record });
+      record = [];
+      field = '';
+      recordLine = ++line;
+    } else {
+      field += char;
+    }
+  }
+  if (field !== '' || record.length > 0) {
+    record.push(field);
+    records.push({ line: recordLine, fields: record });
+  }
+
+  const [header, ...rows] = records;
+  if (!header) {
+    return [];
+  }
+  const columns = header.fields.map(name => COLUMN_ALIASES[name.trim().toLowerCase().replace(/[\s_-]/g, '')] || null);
+  return rows
+    .filter(row => row.fields.some(value => value.trim() !== ''))
+    .map(row => {
+      const values = {};
+      columns.forEach((column, index) => {
+        if (column && row.fields[index] !== undefined) {
+          values[column] = row.fields[index].trim();
+        }
+      });
+      return { rowNumber: row.line, values };
+    });
+}
+
+/**
+ * Normalize a legacy date to YYYY-MM-DD
+ * Accepts ISO dates (with or without time), Excel serial day numbers and d/m/y dates in the configured order
+ * with '/', '.' or '-' separators.
+ */
+function normalizeDate(value, dateFormat, field) {
+  if (/^\d{4}-\d{2}-\d{2}([T ].*)?$/.test(value)) {
+    return value.replace(' ', 'T');
+  }
+
+  let year, month, day;
+  if (/^\d{1,5}(\.\d+)?$/.test(value)) {
+    // Excel stores dates as days since 1899-12-30
+    const date = new Date(Date.UTC(1899, 11, 30) + Math.floor(Number(value)) * 86400000);
+    [year, month, day] = [date.getUTCFullYear(), date.getUTCMonth() + 1, date.getUTCDate()];
+  } else {
+    const parts = value.split(/[/.-]/);
+    if (parts.length !== 3 || parts.some(part => !/^\d+$/.test(part))) {
+      throw new Error(`${field} "${value}" is not a date`);
+    }
+    const order = { YMD: [0, 1, 2], DMY: [2, 1, 0], MDY: [2, 0, 1] }[dateFormat];
+    [year, month, day] = order.map(index => Number(parts[index]));
+    if (year < 100) {
+      year += 2000;
+    }
+  }
+
+  const date = new Date(Date.UTC(year, month - 1, day));
+  if (date.getUTCMonth() !== month - 1 || date.getUTCDate() !== day) {
+    throw new Error(`${field} "${value}" is out of range for date format ${dateFormat}`);
+  }
+  return date.toISOString().slice(0, 10);
+}
+
+function requireFields(values, required) {
+  const missing = required.filter(field => !values[field]);
+  if (missing.length > 0) {
+    throw new Error(`Missing required fields: ${missing.join(', ')}`);
+  }
+}
+
+function requireId(value, field) {
+  if (!ID_PATTERN.test(value)) {
+    throw new Error(`${field} "${value}" may only contain letters, digits, '.', '_' and '-'`);
+  }
+}
+
+/**
+ * Validate and normalize batch rows
+ * @returns {{accepted: Array, rejected: Array}}
+ */
+function prepareBatches(rows, options, source) {
+  const accepted = [];
+  const rejected = [];
+  const seen = new Set();
+  const today = new Date().toISOString().slice(0, 10);
+
+  for (const { rowNumber, values } of rows) {
+    try {
+      requireFields(values, BATCH_REQUIRED);
+      requireId(values.batchId, 'batchId');
+      if (seen.has(values.batchId)) {
+        throw new Error(`Duplicate batchId ${values.batchId} in file`);
+      }
+      const harvestDate = normalizeDate(values.harvestDate, options.dateFormat, 'harvestDate');
+      if (harvestDate.slice(0, 10) > today) {
+        throw new Error(`harvestDate ${harvestDate} is in the future`);
+      }
+
+      seen.add(values.batchId);
+      accepted.push({
+        rowNumber,
+        id: values.batchId,
+        args: [
+          values.batchId,
+          values.origin,
+          values.variety,
+          harvestDate,
+          JSON.stringify({
+            reportId: values.reportId || '',
+            result: values.testResult || '',
+            isVerified: false,
+            verificationSource: LEGACY_VERIFICATION_SOURCE,
+            notes: values.notes || `Imported from ${source}, row ${rowNumber}`
+          }),
+          values.owner,
+          values.step || DEFAULT_BATCH_STEP,
+          values.operator,
+          values.workflowId || '',
+          values.cropYear || '',
+          values.season || '',
+          `legacy-batch-${values.batchId}`
+        ]
+      });
+    } catch (error) {
+      rejected.push({ file: source, rowNumber, id: values.batchId || '', reason: error.message });
+    }
+  }
+  return { accepted, rejected };
+}
+
+/**
+ * Validate and normalize product rows
+ * @returns {{accepted: Array, rejected: Array}}
+ */
+function prepareProducts(rows, options, source) {
+  const accepted = [];
+  const rejected = [];
+  const seen = new Set();
+
+  for (const { rowNumber, values } of rows) {
+    try {
+      requireFields(values, PRODUCT_REQUIRED);
+      requireId(values.productId, 'productId');
+      if (seen.has(values.productId)) {
+        throw new Error(`Duplicate productId ${values.productId} in file`);
+      }
+      const packageDate = normalizeDate(values.packageDate, options.dateFormat, 'packageDate');
+
+      seen.add(values.productId);
+      accepted.push({
+        rowNumber,
+        id: values.productId,
+        batchId: values.batchId,
+        args: [values.productId, values.batchId, packageDate, values.owner, `legacy-product-${values.productId}`]
+      });
+    } catch (error) {
+      rejected.push({ file: source, rowNumber, id: values.productId || '', reason: error.message });
+    }
+  }
+  return { accepted, rejected };
+}
+
+/**
+ * Submit prepared rows with limited concurrency and report progress
+ * @returns {Promise<{imported: number, rejected: Array}>}
+ */
+async function submitRows(label, items, options, submit) {
+  const rejected = [];
+  let imported = 0;
+  let done = 0;
+  let next = 0;
+
+  const progress = () => process.stdout.write(`\r${label}: ${done}/${items.length} (${rejected.length} rejected)`);
+
+  async function worker() {
+    while (next < items.length) {
+      const item = items[next++];
+      try {
+        await submit(item);
+        imported++;
+      } catch (error) {
+        rejected.push({ rowNumber: item.rowNumber, id: item.id, reason: error.message });
+      }
+      done++;
+      progress();
+    }
+  }
+
+  progress();
+  await Promise.all(Array.from({ length: Math.min(options.concurrency, items.length) }, worker));
+  process.stdout.write('\n');
+  return { imported, rejected };
+}
+
+function csvField(value) {
+  const text = String(value);
+  return /[",\r\n]/.test(text) ? `"${text.replace(/"/g, '""')}"` : text;
+}
+
+function writeReport(reportPath, rejected) {
+  const lines = ['file,row,id,reason', ...rejected.map(entry =>
+    [entry.file, entry.rowNumber, entry.id, entry.reason].map(csvField).join(','))];
+  fs.writeFileSync(reportPath, `${lines.join('\n')}\n`);
+}
+
+async function importLegacy(options) {
+  const batchSource = options.batches ? path.basename(options.batches) : '';
+  const productSource = options.products ? path.basename(options.products) : '';
+  const batches = options.batches
+    ? prepareBatches(parseCsv(fs.readFileSync(options.batches, 'utf8')), options, batchSource)
+    : { accepted: [], rejected: [] };
+  const products = options.products
+    ? prepareProducts(parseCsv(fs.readFileSync(options.products, 'utf8')), options, productSource)
+    : { accepted: [], rejected: [] };
+  const rejected = [...batches.rejected, ...products.rejected];
+
+  console.log(`Validated ${batches.accepted.length + batches.rejected.length} batch rows (${batches.rejected.length} rejected) ` +
+    `and ${products.accepted.length + products.rejected.length} product rows (${products.rejected.length} rejected)`);
+
+  const summary = { batchesImported: 0, productsImported: 0 };
+  if (!options.dryRun) {
+    const batchResult = await submitRows('Batches', batches.accepted, options, item =>
+      fabricDAO.submitTransaction('farmer', 'CreateRiceBatch', ...item.args));
+    rejected.push(...batchResult.rejected.map(entry => ({ file: batchSource, ...entry })));
+    summary.batchesImported = batchResult.imported;
+
+    // Products of batches that failed to import would only be rejected by the chaincode
+    const failedBatches = new Set(batchResult.rejected.map(entry => entry.id));
+    const productItems = products.accepted.filter(item => {
+      if (failedBatches.has(item.batchId)) {
+        rejected.push({ file: productSource, rowNumber: item.rowNumber, id: item.id, reason: `Batch ${item.batchId} was not imported` });
+        return false;
+      }
+      return true;
+    });
+    const productResult = await submitRows('Products', productItems, options, item =>
+      fabricDAO.submitTransaction('processor', 'CreateProduct', ...item.args));
+    rejected.push(...productResult.rejected.map(entry => ({ file: productSource, ...entry })));
+    summary.productsImported = productResult.imported;
+  }
+
+  console.table({ ...summary, rejectedRows: rejected.length, dryRun: options.dryRun });
+  if (rejected.length > 0) {
+    writeReport(options.report, rejected);
+    console.log(`Rejected rows written to ${options.report}`);
+    process.exitCode = 2;
+  }
+}
+
+async function run() {
+  const options = parseArgs(process.argv.slice(2));
+  if (!options.batches && !options.products) {
+    throw new Error('--batches and/or --products is required');
+  }
+  if (!['YMD', 'DMY', 'MDY'].includes(options.dateFormat)) {
+    throw new Error(`Unknown --date-format ${options.dateFormat}, expected YMD, DMY or MDY`);
+  }
+  if (!options.dryRun) {
+    validateConfig();
+  }
+
+  await runInChannel(options.channel, () => importLegacy(options));
+}
+
+run()
+  .catch(error => {
+    console.error('Import failed:', error.message);
+    process.exitCode = 1;
+  })
+  .finally(() => fabricDAO.cleanup());
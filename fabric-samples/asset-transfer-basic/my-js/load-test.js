@@ -0,0 +1,138 @@
+const { validateConfig, fabric } = require('./config');
+const fabricDAO = require('./src/dao/FabricDAO');
+const { runInChannel } = require('./src/dao/channelContext');
+const crypto = require('crypto');
+
+/**
+ * Load-generation tool
+ * Submits concurrent transactions against a running network and reports throughput, latency and
+ * MVCC conflict rate. Hot-key operations (many writers on one batch) show how often endorsed
+ * transactions are invalidated at commit.
+ *
+ * Usage: node load-test.js --operation=createTestResult --batch=<batchId> --count=200 --concurrency=20
+ *   --operation    createTestResult (new test results on one batch) | completeStep (transfers of one batch)
+ *   --batch        Existing batch ID to load
+ *   --count        Total transactions (default 100)
+ *   --concurrency  Transactions in flight (default 10)
+ *   --role         Submitting role (default processor)
+ *   --channel      Channel from the channel registry (default: default channel)
+ */
+
+const MVCC_CONFLICT_PATTERN = /MVCC_READ_CONFLICT|PHANTOM_READ_CONFLICT|status code (11|12)\b/;
+
+function parseArgs(argv) {
+  const options = { operation: 'createTestResult', count: '100', concurrency: '10', role: 'processor' };
+  for (const arg of argv) {
+    const match = arg.match(/^--([^=]+)=(.*)$/);
+    if (match) {
+      options[match[1]] = match[2];
+    }
+  }
+  return {
+    operation: options.operation,
+    batch: options.batch,
+    role: options.role,
+    channel: options.channel,
+    count: parseInt(options.count, 10),
+    concurrency: parseInt(options.concurrency, 10)
+  };
+}
+
+/**
+ * Build the transaction for the i-th request of an operation
+ * @private
+ */
+function buildOperation(options, runId) {
+  switch (options.operation) {
+    case 'createTestResult':
+      return {
+        setup: () => fabricDAO.submitTransaction(options.role, 'QualityCertificationContract:RecordSample',
+          options.batch, `${runId}-sample`, '500g', 'Load Test', 'Load Test'),
+        submit: (index) => fabricDAO.submitTransaction(options.role, 'QualityCertificationContract:CreateTestResult',
+          `${runId}-test-${index}`, options.batch, `${runId}-sample`, 'Moisture',
+          new Date().toISOString().slice(0, 10), 'Passed', 'Load Test', '',
+          crypto.createHash('sha256').update(`${runId}-report-${index}`).digest('hex'), '')
+      };
+    case 'completeStep':
+      return {
+        setup: async () => undefined,
+        submit: (index) => fabricDAO.submitTransaction(options.role, 'CompleteStepAndTransfer',
+          options.batch, 'Load Test', `Load Test ${index}`, 'Milling', '{}', '')
+      };
+    default:
+      throw new Error(`Unknown operation: ${options.operation}. Available operations: createTestResult, completeStep`);
+  }
+}
+
+function percentile(sorted, p) {
+  if (sorted.length === 0) {
+    return 0;
+  }
+  return sorted[Math.min(sorted.length - 1, Math.ceil((p / 100) * sorted.length) - 1)];
+}
+
+async function loadTest(options) {
+  const runId = `load-${Date.now()}`;
+  const operation = buildOperation(options, runId);
+  await operation.setup();
+
+  const stats = { succeeded: 0, conflicts: 0, failed: 0, latencies: [] };
+  let next = 0;
+
+  async function worker() {
+    while (next < options.count) {
+      const index = next++;
+      const start = process.hrtime.bigint();
+      try {
+        await operation.submit(index);
+        stats.succeeded++;
+      } catch (error) {
+        if (MVCC_CONFLICT_PATTERN.test(error.message)) {
+          stats.conflicts++;
+        } else {
+          stats.failed++;
+          console.error(`Transaction ${index} failed:`, error.message);
+        }
+      }
+      stats.latencies.push(Number(process.hrtime.bigint() - start) / 1e6);
+    }
+  }
+
+  console.log(`Running ${options.count} x ${options.operation} on batch ${options.batch} (concurrency ${options.concurrency})`);
+  const start = Date.now();
+  await Promise.all(Array.from({ length: Math.min(options.concurrency, options.count) }, worker));
+  const seconds = (Date.now() - start) / 1000;
+
+  const sorted = stats.latencies.sort((a, b) => a - b);
+  console.table({
+    transactions: options.count,
+    succeeded: stats.succeeded,
+    conflicts: stats.conflicts,
+    failed: stats.failed,
+    conflictRate: `${((stats.conflicts / options.count) * 100).toFixed(1)}%`,
+    throughputTxPerSecond: (stats.succeeded / seconds).toFixed(1),
+    latencyP50Ms: percentile(sorted, 50).toFixed(0),
+    latencyP95Ms: percentile(sorted, 95).toFixed(0),
+    latencyP99Ms: percentile(sorted, 99).toFixed(0)
+  });
+}
+
+async function run() {
+  validateConfig();
+  const options = parseArgs(process.argv.slice(2));
+  if (!options.batch) {
+    throw new Error('--batch is required');
+  }
+
+  // Measure raw conflicts: the gateway's automatic resubmission would hide them
+  fabric.retry.maxAttempts = 1;
+
+  await runInChannel(options.channel, () => loadTest(options));
+}
+
+run()
+  .catch(error => {
+    console.error('Load test failed:', error.message);
+    process.exitCode = 1;
+  })
+  .finally(() => fabricDAO.cleanup());
@@ -0,0 +1,49 @@
+const { validateConfig, reconciliation } = require('./config');
+const reconciliationService = require('./src/services/ReconciliationService');
+const cacheService = require('./src/services/CacheService');
+const fabricDAO = require('./src/dao/FabricDAO');
+
+/**
+ * Ledger/cache reconciliation job
+ * Compares every batch on the ledger with the gateway's Redis copy once and writes the report. Exits with code 2 when
+ * drift was found and not repaired, so a scheduler can alert on it. The event bridge runs the same check
+ * periodically with RECONCILE_ENABLED=true
+ *
+ * Usage: node reconcile.js [--repair] [--channel=<name>]
+ *   --repair   Evict the drifted cache entries (default: report only, or RECONCILE_REPAIR)
+ *   --channel  Channel from the channel registry (default: RECONCILE_CHANNEL or the event bridge channel)
+ */
+
+function parseArgs(argv) {
+  const options = { repair: reconciliation.repair, channel: reconciliation.channel };
+  for (const arg of argv) {
+    if (arg === '--repair') {
+      options.repair = true;
+      continue;
+    }
+    const match = arg.match(/^--([^=]+)=(.*)$/);
+    if (match) {
+      options[match[1]] = match[2];
+    }
+  }
+  return options;
+}
+
+async function run() {
+  validateConfig();
+  const options = parseArgs(process.argv.slice(2));
+  const report = await reconciliationService.reconcile(options);
+  if (report.drift.length > 0 && !report.repaired) {
+    process.exitCode = 2;
+  }
+}
+
+run()
+  .catch(error => {
+    console.error('Reconciliation failed:', error.message);
+    process.exitCode = 1;
+  })
+  .finally(async () => {
+    await cacheService.disconnect();
+    await fabricDAO.cleanup();
+  });
@@ -0,0 +1,41 @@
+const fs = require('node:fs');
+const path = require('node:path');
+const { validateConfig } = require('./config');
+const fabricDAO = require('./src/dao/FabricDAO');
+const { runInChannel } = require('./src/dao/channelContext');
+
+/**
+ * Ledger seeding tool
+ * Seeds a demo, QA or training network with a fixture set ({ batches, products, participants }) by calling
+ * InitLedger with the fixtures as transient data.
+ *
+ * Usage: node seed-ledger.js [fixtures.json] [--role=farmer] [--channel=<name>]
+ */
+
+async function run() {
+  validateConfig();
+
+  const args = process.argv.slice(2);
+  const roleArg = args.find(arg => arg.startsWith('--role='));
+  const role = roleArg ? roleArg.slice('--role='.length) : 'farmer';
+  const channelArg = args.find(arg => arg.startsWith('--channel='));
+  const channel = channelArg ? channelArg.slice('--channel='.length) : undefined;
+  const fixturesPath = path.resolve(args.find(arg => !arg.startsWith('--')) || path.join(__dirname, 'fixtures', 'demo.json'));
+
+  // Parse locally first so malformed files fail before a transaction is submitted
+  const fixtures = JSON.parse(fs.readFileSync(fixturesPath, 'utf8'));
+  console.log(`Seeding ${(fixtures.batches || []).length} batches, ${(fixtures.products || []).length} products and ` +
+    `${(fixtures.participants || []).length} participants from ${fixturesPath}`);
+
+  await runInChannel(channel, () => fabricDAO.submitAsyncTransaction(role, 'InitLedger', {
+    transientData: { fixtures: JSON.stringify(fixtures) }
+  }));
+  console.log('Ledger seeded');
+}
+
+run()
+  .catch(error => {
+    console.error('Seeding failed:', error.message);
+    process.exitCode = 1;
+  })
+  .finally(() => fabricDAO.cleanup());
@@ -3,6 +3,8 @@ const cors = require('cors');
 const { env, validateConfig } = require('./config');
 const routes = require('./src/routes');
 const { errorHandler, notFoundHandler } = require('./src/middleware/errorMiddleware');
+const { metricsMiddleware, metricsHandler } = require('./src/middleware/metricsMiddleware');
+const cacheInvalidationService = require('./src/services/CacheInvalidationService');
 
 // Validate configuration
 validateConfig();
@@ -17,6 +19,9 @@ app.use(cors({
   credentials: true
 }));
 
+// Request metrics (exposed on /metrics)
+app.use(metricsMiddleware);
+
 // Basic middleware
 app.use(express.json({ limit: '10mb' }));
 app.use(express.urlencoded({ extended: true, limit: '10mb' }));
@@ -35,6 +40,9 @@ app.use((req, res, next) => {
 // API route
 app.use('/api', routes);
 
+// Prometheus metrics
+app.get('/metrics', metricsHandler);
+
 // Root path redirect to API information
 app.get('/', (req, res) => {
   res.redirect('/api/info');
@@ -57,14 +65,19 @@ const server = app.listen(PORT, () => {
   console.log(`Server address: http://localhost:${PORT}`);
   console.log(`API information: http://localhost:${PORT}/api/info`);
   console.log(`Health check: http://localhost:${PORT}/api/health`);
+  console.log(`Metrics: http://localhost:${PORT}/metrics`);
   console.log(`Frontend interface: http://localhost:${PORT}/`);
   console.log(`Environment: ${env.NODE_ENV}`);
   console.log('=' .repeat(50));
+
+  // Clear cached reads when chaincode events announce changes
+  cacheInvalidationService.start();
 });
 
 // Graceful shutdown
 process.on('SIGTERM', () => {
   console.log('Received SIGTERM signal, shutting down gracefully...');
+  cacheInvalidationService.stop();
   server.close(() => {
     console.log('Server closed');
     process.exit(0);
@@ -73,6 +86,7 @@ process.on('SIGTERM', () => {
 
 process.on('SIGINT', () => {
   console.log('Received SIGINT signal, shutting down gracefully...');
+  cacheInvalidationService.stop();
   server.close(() => {
     console.log('Server closed');
     process.exit(0);
@@ -0,0 +1,53 @@
+const { validateConfig } = require('./config');
+const fabricDAO = require('./src/dao/FabricDAO');
+const { runInChannel } = require('./src/dao/channelContext');
+
+/**
+ * Daily statistics snapshot job
+ * Records the on-chain activity summary of a completed UTC day with SnapshotDailyStats. Meant to run from a
+ * scheduler shortly after midnight UTC, e.g. cron: 15 0 * * * cd /opt/ricetrace/my-js && npm run snapshot
+ *
+ * Usage: node snapshot-stats.js [--date=YYYY-MM-DD] [--role=processor] [--channel=<name>]
+ *   --date     Day to snapshot (default: yesterday, UTC)
+ *   --role     Submitting role (default processor)
+ *   --channel  Channel from the channel registry (default: default channel)
+ */
+
+function parseArgs(argv) {
+  const yesterday = new Date(Date.now() - 24 * 60 * 60 * 1000).toISOString().slice(0, 10);
+  const options = { date: yesterday, role: 'processor' };
+  for (const arg of argv) {
+    const match = arg.match(/^--([^=]+)=(.*)$/);
+    if (match) {
+      options[match[1]] = match[2];
+    }
+  }
+  return options;
+}
+
+async function snapshot(options) {
+  try {
+    const result = await fabricDAO.submitTransaction(options.role, 'SnapshotDailyStats', options.date);
+    console.log(`Daily statistics for ${options.date} recorded:`, new TextDecoder().decode(result));
+  } catch (error) {
+    // A re-run of the job for a day that was already recorded is not a failure
+    if (error.message.includes('already been recorded')) {
+      console.log(`Daily statistics for ${options.date} were already recorded`);
+      return;
+    }
+    throw error;
+  }
+}
+
+async function run() {
+  validateConfig();
+  const options = parseArgs(process.argv.slice(2));
+  await runInChannel(options.channel, () => snapshot(options));
+}
+
+run()
+  .catch(error => {
+    console.error('Statistics snapshot failed:', error.message);
+    process.exitCode = 1;
+  })
+  .finally(() => fabricDAO.cleanup());
@@ -0,0 +1,93 @@
+const { Readable } = require('node:stream');
+const { ipfs, errorCodes } = require('../../config');
+
+/**
+ * IPFS Client
+ * Adds files to and reads them from an IPFS node through its Kubo RPC API, and pins them with a remote pinning
+ * service (IPFS Pinning Service API) when one is configured
+ */
+class IpfsClient {
+
+  /**
+   * Add a file to IPFS, pinned on the node
+   * @param {Buffer} content - File content
+   * @param {string} filename - File name, kept in the upload only
+   * @returns {Promise<{cid: string, size: number}>} CIDv1 of the file
+   */
+  async add(content, filename) {
+    const form = new FormData();
+    form.append('file', new Blob([content]), filename || 'file');
+
+    const response = await this._rpc('add?cid-version=1&pin=true', form);
+    const result = await response.json();
+    return { cid: result.Hash, size: Number(result.Size) };
+  }
+
+  /**
+   * Pin a file with the remote pinning service; does nothing when none is configured
+   * @param {string} cid - Content identifier
+   * @param {string} name - Name shown by the pinning service
+   * @returns {Promise<Object|null>} { requestId, status } or null without a pinning service
+   */
+  async pinRemote(cid, name) {
+    const { endpoint, accessToken } = ipfs.pinningService;
+    if (!endpoint) {
+      return null;
+    }
+
+    let response;
+    try {
+      response = await fetch(`${endpoint}/pins`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json', Authorization: `Bearer ${accessToken}` },
+        body: JSON.stringify({ cid, name }),
+        signal: AbortSignal.timeout(ipfs.timeout)
+      });
+    } catch (error) {
+      throw new Error(`${errorCodes.STORAGE_ERROR}: Pinning service unreachable: ${error.message}`);
+    }
+    if (!response.ok) {
+      throw new Error(`${errorCodes.STORAGE_ERROR}: Pinning service rejected ${cid}: HTTP ${response.status} ${await response.text()}`);
+    }
+    const pin = await response.json();
+    return { requestId: pin.requestid, status: pin.status };
+  }
+
+  /**
+   * Read a file from IPFS
+   * @param {string} cid - Content identifier
+   * @returns {Promise<Readable>} File content stream
+   */
+  async cat(cid) {
+    const response = await this._rpc(`cat?arg=${encodeURIComponent(cid)}`);
+    return Readable.fromWeb(response.body);
+  }
+
+  /**
+   * Call the Kubo RPC API (every endpoint takes POST)
+   * @private
+   */
+  async _rpc(path, body) {
+    let response;
+    try {
+      response = await fetch(`${ipfs.apiUrl}/api/v0/${path}`, {
+        method: 'POST',
+        headers: ipfs.apiAuthorization ? { Authorization: ipfs.apiAuthorization } : {},
+        body,
+        signal: AbortSignal.timeout(ipfs.timeout)
+      });
+    } catch (error) {
+      throw new Error(`${errorCodes.STORAGE_ERROR}: IPFS node unreachable at ${ipfs.apiUrl}: ${error.message}`);
+    }
+    if (!response.ok) {
+      const message = await response.text();
+      throw new Error(`${errorCodes.STORAGE_ERROR}: IPFS ${path.split('?')[0]} failed: HTTP ${response.status} ${message}`);
+    }
+    return response;
+  }
+}
+
+// Create singleton instance
+const ipfsClient = new IpfsClient();
+
+module.exports = ipfsClient;
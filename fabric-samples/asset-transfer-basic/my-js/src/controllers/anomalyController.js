@@ -0,0 +1,48 @@
+const anomalyDetectionService = require('../services/AnomalyDetectionService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Anomaly controller
+ * Gives the audit team the alerts the event bridge raised for suspicious patterns
+ */
+
+/**
+ * List the anomaly alerts of the channel, newest first
+ * GET /api/audit/anomalies?batchId=&limit=50&offset=0
+ */
+const getAlerts = asyncHandler(async (req, res) => {
+  const { batchId, limit, offset } = req.query;
+  const page = await anomalyDetectionService.getAlerts({ batchId, limit, offset });
+
+  res.json({
+    success: true,
+    data: page.alerts,
+    count: page.alerts.length,
+    total: page.total,
+    limit: page.limit,
+    offset: page.offset,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Describe the detection rules
+ * GET /api/audit/anomalies/rules
+ */
+const getRules = asyncHandler(async (req, res) => {
+  const rules = anomalyDetectionService.getRules();
+
+  res.json({
+    success: true,
+    data: rules,
+    count: rules.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  getAlerts,
+  getRules
+};
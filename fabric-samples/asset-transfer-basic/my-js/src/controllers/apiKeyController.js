@@ -0,0 +1,82 @@
+const apiKeyService = require('../services/ApiKeyService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * API key controller
+ * Lets administrators issue, tune and revoke the API keys of the public trace and verification endpoints
+ */
+
+/**
+ * Create an API key; the key is only returned in this response
+ * POST /api/api-keys
+ */
+const createApiKey = asyncHandler(async (req, res) => {
+  const { name, requestsPerMinute, dailyQuota } = req.body;
+  const client = await apiKeyService.createKey({ name, requestsPerMinute, dailyQuota });
+
+  res.status(201).json({
+    success: true,
+    message: 'API key created, store it now: it cannot be shown again',
+    data: client,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * List API key clients with today's usage
+ * GET /api/api-keys
+ */
+const listApiKeys = asyncHandler(async (req, res) => {
+  const clients = await apiKeyService.listKeys();
+
+  res.json({
+    success: true,
+    data: clients,
+    count: clients.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Change the limits of an API key
+ * PATCH /api/api-keys/:clientId
+ */
+const updateApiKeyLimits = asyncHandler(async (req, res) => {
+  const { clientId } = req.params;
+  const { requestsPerMinute, dailyQuota } = req.body;
+  const client = await apiKeyService.updateLimits(clientId, { requestsPerMinute, dailyQuota });
+
+  res.json({
+    success: true,
+    message: 'API key limits updated',
+    data: client,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Revoke an API key
+ * DELETE /api/api-keys/:clientId
+ */
+const revokeApiKey = asyncHandler(async (req, res) => {
+  const { clientId } = req.params;
+  const client = await apiKeyService.revokeKey(clientId);
+
+  res.json({
+    success: true,
+    message: 'API key revoked',
+    data: client,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  createApiKey,
+  listApiKeys,
+  updateApiKeyLimits,
+  revokeApiKey
+};
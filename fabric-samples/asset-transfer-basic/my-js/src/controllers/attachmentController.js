@@ -0,0 +1,157 @@
+const { pipeline } = require('node:stream');
+const multer = require('multer');
+const attachmentService = require('../services/AttachmentService');
+const { ipfs } = require('../../config');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+// Files uploaded to IPFS are held in memory until they are added to the node
+const upload = multer({
+  storage: multer.memoryStorage(),
+  limits: {
+    fileSize: ipfs.maxFileSize
+  }
+});
+
+/**
+ * Attachment controller
+ * Handles the typed document attachments of batches and products
+ */
+
+/**
+ * Attach a document to a batch or product
+ * POST /api/attachments/:entityId
+ */
+const addAttachment = asyncHandler(async (req, res) => {
+  const { entityId } = req.params;
+  const attachment = await attachmentService.addAttachment(req.role, entityId, req.body);
+
+  res.json({
+    success: true,
+    message: `${req.body.category} attachment added to ${entityId}`,
+    data: attachment,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Upload a file to IPFS and attach it to a batch or product (multipart: file, category, title, metadata)
+ * POST /api/attachments/:entityId/upload
+ */
+const uploadAttachment = asyncHandler(async (req, res) => {
+  const { entityId } = req.params;
+  const result = await attachmentService.uploadAttachment(req.role, entityId, req.file, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `${result.attachment.category} attachment stored on IPFS as ${result.attachment.cid} and added to ${entityId}`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Add a photo to the photo journal of a batch
+ * POST /api/batch/:id/photos
+ */
+const addBatchPhoto = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const photo = await attachmentService.addBatchPhoto(req.role, id, req.body);
+
+  res.json({
+    success: true,
+    message: `${photo.metadata.stage} photo added to the journal of batch ${id}`,
+    data: photo,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the photo journal of a batch
+ * GET /api/batch/:id/photos
+ */
+const getBatchPhotos = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const photos = await attachmentService.getBatchPhotos(req.role, id);
+
+  res.json({
+    success: true,
+    data: photos,
+    count: photos.length,
+    batchId: id,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * List the attachments of a batch or product
+ * GET /api/attachments/:entityId?category=
+ */
+const listAttachments = asyncHandler(async (req, res) => {
+  const { entityId } = req.params;
+  const { category = '' } = req.query;
+  const attachments = await attachmentService.listAttachments(req.role, entityId, category);
+
+  res.json({
+    success: true,
+    data: attachments,
+    count: attachments.length,
+    entityId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get an attachment of a batch or product
+ * GET /api/attachments/:entityId/:attachmentId
+ */
+const getAttachment = asyncHandler(async (req, res) => {
+  const { entityId, attachmentId } = req.params;
+  const attachment = await attachmentService.getAttachment(req.role, entityId, attachmentId);
+
+  res.json({
+    success: true,
+    data: attachment,
+    entityId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Stream the content of an attachment stored on IPFS
+ * GET /api/attachments/:entityId/:attachmentId/content
+ */
+const getAttachmentContent = asyncHandler(async (req, res) => {
+  const { entityId, attachmentId } = req.params;
+  const { attachment, stream } = await attachmentService.openAttachmentContent(req.role, entityId, attachmentId);
+
+  res.setHeader('Content-Type', attachment.mimeType);
+  res.setHeader('Content-Disposition', `inline; filename="${attachment.title.replace(/[^\w .-]/g, '_')}"`);
+  // Content is addressed by its hash, so it never changes
+  res.setHeader('Cache-Control', 'private, max-age=31536000, immutable');
+  res.setHeader('ETag', `"${attachment.fileHash}"`);
+  res.setHeader('X-Content-SHA256', attachment.fileHash);
+  res.setHeader('X-IPFS-CID', attachment.cid);
+  pipeline(stream, res, (error) => {
+    if (error) {
+      // Headers are sent by now; closing the connection early tells the client the content is incomplete
+      console.error(`Failed to stream attachment ${attachmentId}: ${error.message}`);
+    }
+  });
+});
+
+module.exports = {
+  upload,
+  addAttachment,
+  uploadAttachment,
+  getAttachmentContent,
+  listAttachments,
+  getAttachment,
+  addBatchPhoto,
+  getBatchPhotos
+};
@@ -0,0 +1,30 @@
+const accessAuditService = require('../services/AccessAuditService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Audit controller
+ * Serves the access audit trail of sensitive views to the regulator
+ */
+
+/**
+ * Get an organization's access log
+ * GET /api/audit/access-log/:mspId?from=&to=
+ */
+const getAccessLog = asyncHandler(async (req, res) => {
+  const { mspId } = req.params;
+  const { from = '', to = '' } = req.query;
+  const entries = await accessAuditService.getAccessLog(req.role, mspId, from, to);
+
+  res.json({
+    success: true,
+    data: entries,
+    count: entries.length,
+    mspId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  getAccessLog
+};
@@ -1,4 +1,8 @@
 const riceService = require('../services/RiceService');
+const exportService = require('../services/ExportService');
+const labelService = require('../services/LabelService');
+const translationService = require('../services/TranslationService');
+const searchService = require('../services/SearchService');
 const { asyncHandler } = require('../middleware/errorMiddleware');
 
 /**
@@ -22,17 +26,56 @@ const getAllBatches = asyncHandler(async (req, res) => {
   });
 });
 
+/**
+ * Get batches currently at a processing step
+ * GET /api/batch/step/:step
+ */
+const getBatchesByStep = asyncHandler(async (req, res) => {
+  const { step } = req.params;
+  const batches = await riceService.getBatchesByStep(req.role, step);
+
+  res.json({
+    success: true,
+    data: batches,
+    count: batches.length,
+    step,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get batches with a derived status
+ * GET /api/batch/status/:status
+ */
+const getBatchesByStatus = asyncHandler(async (req, res) => {
+  const { status } = req.params;
+  const batches = await riceService.getBatchesByStatus(req.role, status);
+
+  res.json({
+    success: true,
+    data: batches,
+    count: batches.length,
+    status,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
 /**
  * Get batch by ID
- * GET /api/batch/:id
+ * GET /api/batch/:id?locale=zh|en
+ * With a locale, the state, season and history steps carry their labels
  */
 const getBatchById = asyncHandler(async (req, res) => {
   const { id } = req.params;
   const batch = await riceService.getBatchById(req.role, id);
-  
+  const dictionary = req.query.locale ? await translationService.getDictionary(String(req.query.locale).toLowerCase()) : null;
+
   res.json({
     success: true,
-    data: batch,
+    data: dictionary ? translationService.localizeBatch(batch, dictionary) : batch,
+    ...(dictionary && { locale: dictionary.locale, labels: dictionary.fields }),
     batchId: id,
     role: req.role,
     timestamp: new Date().toISOString()
@@ -68,16 +111,20 @@ const createBatch = asyncHandler(async (req, res) => {
   }
 
   const batchData = {
+    batchId: req.body.batchId,
     location: req.body.location,
     variety: req.body.variety,
     harvestDate: req.body.harvestDate,
     initialTestResult: req.body.initialTestResult,
     owner: req.body.owner,
     initialStep: req.body.initialStep,
-    operator: req.body.operator
+    operator: req.body.operator,
+    workflowId: req.body.workflowId,
+    cropYear: req.body.cropYear,
+    season: req.body.season
   };
 
-  const result = await riceService.createBatch(req.role, batchData, reportId);
+  const result = await riceService.createBatch(req.role, batchData, reportId, req.get('Idempotency-Key') || '');
   
   res.status(201).json({
     success: true,
@@ -131,6 +178,7 @@ const addTestResult = asyncHandler(async (req, res) => {
     timestamp: req.body.timestamp,
     temperature: req.body.temperature,
     report: req.body.report,
+    reportHash: req.body.reportHash,
     result: req.body.result
   };
 
@@ -202,6 +250,88 @@ const getBatchStats = asyncHandler(async (req, res) => {
   });
 });
 
+/**
+ * Get recorded daily activity statistics
+ * GET /api/batch/stats/daily?from=YYYY-MM-DD&to=YYYY-MM-DD
+ */
+const getDailyStats = asyncHandler(async (req, res) => {
+  const { from, to } = req.query;
+  const stats = await riceService.getDailyStats(req.role, from, to);
+
+  res.json({
+    success: true,
+    data: stats,
+    count: stats.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get network-level KPIs for operator reporting
+ * GET /api/batch/stats/network?activeDays=90
+ */
+const getNetworkKpis = asyncHandler(async (req, res) => {
+  const kpis = await riceService.getNetworkKpis(req.role, req.query.activeDays);
+
+  res.json({
+    success: true,
+    data: kpis,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the aggregates of a crop year or season
+ * GET /api/batch/stats/seasons/:cropYear?season=Middle
+ */
+const getSeasonStats = asyncHandler(async (req, res) => {
+  const { cropYear } = req.params;
+  const { season = '' } = req.query;
+  const stats = await riceService.getSeasonStats(req.role, cropYear, season);
+
+  res.json({
+    success: true,
+    data: stats,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Compare a season year over year
+ * GET /api/batch/stats/seasons?season=Middle&from=2022&to=2024
+ */
+const compareSeasons = asyncHandler(async (req, res) => {
+  const { season = '', from, to } = req.query;
+  const stats = await riceService.compareSeasons(req.role, season, from, to);
+
+  res.json({
+    success: true,
+    data: stats,
+    count: stats.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the quality trends of a variety and region month by month
+ * GET /api/batch/stats/quality-trends?variety=Daohuaxiang&region=Wuchang&period=2024-01-01/2024-12-31
+ */
+const getQualityTrends = asyncHandler(async (req, res) => {
+  const { variety = '', region = '', period } = req.query;
+  const trends = await riceService.getQualityTrends(req.role, variety, region, period);
+
+  res.json({
+    success: true,
+    data: trends,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
 /**
  * Get Oracle service status
  */
@@ -218,13 +348,28 @@ const getOracleStatus = asyncHandler(async (req, res) => {
   });
 });
 
+/**
+ * Get chaincode connectivity and deployed version
+ * GET /api/health/chaincode
+ */
+const getChaincodeStatus = asyncHandler(async (req, res) => {
+  const status = await riceService.getChaincodeStatus(req.role);
+
+  res.status(status.reachable ? 200 : 503).json({
+    success: status.reachable,
+    data: status,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
 /**
  * Complete step and transfer batch - new unified endpoint
  * POST /api/v2/batch/:id/event
  */
 const completeStepAndTransfer = asyncHandler(async (req, res) => {
   const { id: batchId } = req.params;
-  const { fromOperator, toOperator, step, reportId } = req.body;
+  const { fromOperator, toOperator, step, reportId, destinationCountry, equipmentId, geolocation, temperatureLogHash, inputs, facilityId, line, shift, ambient } = req.body;
   
   // Validate required fields
   if (!fromOperator || !toOperator || !step || !reportId) {
@@ -239,7 +384,9 @@ const completeStepAndTransfer = asyncHandler(async (req, res) => {
     fromOperator,
     toOperator,
     step,
-    reportId
+    reportId,
+    { destinationCountry, equipmentId, geolocation, temperatureLogHash, inputs, facilityId, line, shift, ambient },
+    req.get('Idempotency-Key') || ''
   );
   
   res.json({
@@ -254,6 +401,31 @@ const completeStepAndTransfer = asyncHandler(async (req, res) => {
   });
 });
 
+/**
+ * Check a step and transfer against every transfer rule without submitting it
+ * POST /api/v2/batch/:id/event/check
+ */
+const checkTransfer = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const { toOperator, step, reportId, destinationCountry, equipmentId, geolocation, temperatureLogHash, inputs, facilityId, line, shift, ambient } = req.body;
+
+  const check = await riceService.checkTransfer(
+    req.role,
+    batchId,
+    toOperator,
+    step,
+    reportId,
+    { destinationCountry, equipmentId, geolocation, temperatureLogHash, inputs, facilityId, line, shift, ambient }
+  );
+
+  res.json({
+    success: true,
+    data: check,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
 /**
  * Get current batch owner for auto-fill
  * GET /api/batch/:id/owner
@@ -272,8 +444,761 @@ const getCurrentBatchOwner = asyncHandler(async (req, res) => {
   });
 });
 
+/**
+ * Privately attach commercial terms to a batch owned by the caller's organization
+ * PUT /api/batch/:id/terms
+ */
+const setCommercialTerms = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const result = await riceService.setCommercialTerms(req.role, batchId, req.body.terms);
+
+  res.json({
+    success: true,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the caller organization's commercial terms for a batch
+ * GET /api/batch/:id/terms
+ */
+const getCommercialTerms = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const terms = await riceService.getCommercialTerms(req.role, batchId);
+
+  res.json({
+    success: true,
+    data: terms,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Privately share the full report of a test with the organization it was made for
+ * PUT /api/batch/:id/test/:testId/report-details
+ */
+const setTestReportDetails = asyncHandler(async (req, res) => {
+  const { id: batchId, testId } = req.params;
+  const result = await riceService.setTestReportDetails(req.role, batchId, testId, req.body);
+
+  res.json({
+    success: true,
+    data: result,
+    batchId,
+    testId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the full report of a test shared with the caller's organization
+ * GET /api/batch/:id/test/:testId/report-details
+ */
+const getTestReportDetails = asyncHandler(async (req, res) => {
+  const { id: batchId, testId } = req.params;
+  const details = await riceService.getTestReportDetails(req.role, batchId, testId);
+
+  res.json({
+    success: true,
+    data: details,
+    batchId,
+    testId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Check a report file hash against the one registered with a test result
+ * GET /api/batch/:id/test/:testId/verify-hash?hash=<sha256>
+ */
+const verifyTestReportHash = asyncHandler(async (req, res) => {
+  const { id: batchId, testId } = req.params;
+  const matches = await riceService.verifyTestReportHash(req.role, batchId, testId, req.query.hash);
+
+  res.json({
+    success: true,
+    data: { matches },
+    batchId,
+    testId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Withdraw a test result
+ * POST /api/batch/:id/test/:testId/revoke
+ */
+const revokeTestResult = asyncHandler(async (req, res) => {
+  const { id: batchId, testId } = req.params;
+  const result = await riceService.revokeTestResult(req.role, batchId, testId, req.body.reason);
+
+  res.json({
+    success: true,
+    message: `Test result ${testId} of batch ${batchId} revoked`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Record the moisture content a moisture test measured
+ * POST /api/batch/:id/test/:testId/moisture
+ */
+const recordMoistureContent = asyncHandler(async (req, res) => {
+  const { id: batchId, testId } = req.params;
+  const result = await riceService.recordMoistureContent(req.role, batchId, testId, req.body.moisturePercent);
+
+  res.status(201).json({
+    success: true,
+    message: `Moisture content of test result ${testId} recorded`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Reference a batch committed on another channel
+ * POST /api/batch/:id/foreign-references
+ */
+const linkForeignBatch = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const result = await riceService.linkForeignBatch(req.role, batchId, req.body);
+
+  res.json({
+    success: true,
+    message: `Batch ${req.body.foreignBatchId} on channel ${req.body.channel} linked to batch ${batchId}`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Compare a referenced foreign batch with its state when linked
+ * GET /api/batch/:id/foreign-references/:channel/:foreignBatchId/verify
+ */
+const verifyForeignBatchReference = asyncHandler(async (req, res) => {
+  const { id: batchId, channel, foreignBatchId } = req.params;
+  const verification = await riceService.verifyForeignBatchReference(req.role, batchId, channel, foreignBatchId);
+
+  res.json({
+    success: true,
+    data: verification,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Attach an insurance policy to a batch
+ * POST /api/batch/:id/insurance
+ */
+const attachInsurancePolicy = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const result = await riceService.attachInsurancePolicy(req.role, batchId, req.body);
+
+  res.json({
+    success: true,
+    message: `Policy ${req.body.policyNumber} of ${req.body.insurer} attached to batch ${batchId}`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * File an insurance claim for a batch
+ * POST /api/batch/:id/insurance/claims
+ */
+const claimInsurance = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const result = await riceService.claimInsurance(req.role, batchId, req.body);
+
+  res.json({
+    success: true,
+    message: `Claim ${req.body.claimId} filed against policy ${req.body.policyNumber}`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the state hash of a batch
+ * GET /api/batch/:id/state-hash
+ */
+const getBatchStateHash = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const stateHash = await riceService.getBatchStateHash(req.role, batchId);
+
+  res.json({
+    success: true,
+    data: stateHash,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Compare two committed versions of a batch
+ * GET /api/batch/:id/history/diff?from=&to=
+ */
+const getBatchHistoryDiff = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const { from = '', to } = req.query;
+  const diff = await riceService.getBatchHistoryDiff(req.role, batchId, from, to);
+
+  res.json({
+    success: true,
+    data: diff,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the ancestor/descendant graph of a batch
+ * GET /api/batch/:id/genealogy?depth=
+ */
+const getBatchGenealogy = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const genealogy = await riceService.getBatchGenealogy(req.role, batchId, req.query.depth || '');
+
+  res.json({
+    success: true,
+    data: genealogy,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the traceability completeness score of a batch
+ * GET /api/batch/:id/traceability-score
+ */
+const getTraceabilityScore = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const score = await riceService.getTraceabilityScore(req.role, batchId);
+
+  res.json({
+    success: true,
+    data: score,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the storage used by a batch against the batch storage limits
+ * GET /api/batch/:id/storage-usage
+ */
+const getBatchStorageUsage = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const usage = await riceService.getBatchStorageUsage(req.role, batchId);
+
+  res.json({
+    success: true,
+    data: usage,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the batches scoring at least a minimum traceability score
+ * GET /api/batch/well-documented?minScore=
+ */
+const getWellDocumentedBatches = asyncHandler(async (req, res) => {
+  const { minScore = '' } = req.query;
+  const scores = await riceService.getWellDocumentedBatches(req.role, minScore);
+
+  res.json({
+    success: true,
+    data: scores,
+    count: scores.length,
+    minScore: minScore === '' ? 0 : Number(minScore),
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Send an export file as a download
+ * @private
+ */
+function sendExport(res, file) {
+  res.setHeader('Content-Type', file.contentType);
+  res.setHeader('Content-Disposition', `attachment; filename="${file.filename}"`);
+  res.send(file.body);
+}
+
+/**
+ * Export a batch's history (transfers, processing records, test results)
+ * GET /api/batch/:id/history/export?format=csv|xlsx
+ */
+const exportBatchHistory = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const file = await exportService.exportBatchHistory(req.role, batchId, req.query.format || 'csv');
+
+  sendExport(res, file);
+});
+
+/**
+ * Export an ISO 22005 traceability audit package of a batch
+ * GET /api/batch/:id/audit-package?format=json|xlsx
+ */
+const exportAuditPackage = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const file = await exportService.exportAuditPackage(req.role, batchId, req.query.format || 'json');
+
+  sendExport(res, file);
+});
+
+/**
+ * Send a generated label, inline so it can be shown or printed directly
+ * @private
+ */
+function sendLabel(res, label) {
+  res.setHeader('Content-Type', label.contentType);
+  res.setHeader('Content-Disposition', `inline; filename="${label.filename}"`);
+  res.send(label.body);
+}
+
+/**
+ * Get the QR code label of a batch, encoding its public trace URL
+ * GET /api/batch/:id/qr?format=png|svg&size=
+ */
+const getBatchLabel = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const { format, size } = req.query;
+  const label = await labelService.getBatchLabel(req.role, batchId, { format, size });
+
+  res.setHeader('X-Trace-Url', label.url);
+  sendLabel(res, label);
+});
+
+/**
+ * Get a printable A4 sheet of numbered sack labels of a batch
+ * GET /api/batch/:id/qr-sheet?count=&columns=
+ */
+const getBatchLabelSheet = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const { count, columns } = req.query;
+  const sheet = await labelService.getBatchLabelSheet(req.role, batchId, { count, columns });
+
+  res.setHeader('X-Total-Count', sheet.count);
+  sendLabel(res, sheet);
+});
+
+/**
+ * Export a filtered batch list
+ * GET /api/batch/export?format=csv|xlsx&step=&owner=&variety=&origin=&harvestedFrom=&harvestedTo=&quarantined=
+ */
+const exportBatches = asyncHandler(async (req, res) => {
+  const { format, step, owner, variety, origin, harvestedFrom, harvestedTo, quarantined } = req.query;
+  const file = await exportService.exportBatchList(
+    req.role,
+    { step, owner, variety, origin, harvestedFrom, harvestedTo, quarantined },
+    format || 'csv'
+  );
+
+  res.setHeader('X-Total-Count', file.count);
+  sendExport(res, file);
+});
+
+/**
+ * Search batches by free text over origin, variety, owner and operator names
+ * GET /api/batch/search?q=&fields=&limit=
+ */
+const searchBatches = asyncHandler(async (req, res) => {
+  const { q = '', fields, limit } = req.query;
+  const hits = await searchService.searchBatches(req.role, q, { fields, limit });
+
+  res.json({
+    success: true,
+    data: hits,
+    count: hits.length,
+    query: q,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Replace the labels of a batch
+ * PUT /api/batch/:id/labels
+ */
+const setBatchLabels = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const result = await riceService.setBatchLabels(req.role, batchId, req.body.labels);
+
+  res.json({
+    success: true,
+    message: `Labels of batch ${batchId} updated`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Grant a delegation over a batch
+ * POST /api/batch/:id/delegates
+ */
+const grantDelegate = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const delegation = await riceService.grantDelegate(req.role, batchId, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `Delegation granted on batch ${batchId}`,
+    data: delegation,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Revoke a delegation over a batch
+ * POST /api/batch/:id/delegates/:delegationId/revoke
+ */
+const revokeDelegate = asyncHandler(async (req, res) => {
+  const { id: batchId, delegationId } = req.params;
+  const result = await riceService.revokeDelegate(req.role, batchId, delegationId);
+
+  res.json({
+    success: true,
+    message: `Delegation ${delegationId} on batch ${batchId} revoked`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Declare the quantity of a batch
+ * PUT /api/batch/:id/quantity
+ */
+const setBatchQuantity = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const result = await riceService.setBatchQuantity(req.role, batchId, req.body.quantityKg);
+
+  res.json({
+    success: true,
+    message: `Quantity of batch ${batchId} set to ${result.quantityKg} kg`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Record the weight a batch lost in a processing step
+ * POST /api/batch/:id/weight-adjustments
+ */
+const recordWeightAdjustment = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const adjustment = await riceService.recordWeightAdjustment(req.role, batchId, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `Batch ${batchId} lost ${adjustment.lossPercent}% in ${adjustment.step}; quantity set to ${adjustment.afterKg} kg`,
+    data: adjustment,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Reserve quantity of a batch for a pending sale
+ * POST /api/batch/:id/reservations
+ */
+const reserveBatchQuantity = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const reservation = await riceService.reserveBatchQuantity(req.role, batchId, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `${reservation.quantityKg} kg of batch ${batchId} reserved for ${reservation.buyer}`,
+    data: reservation,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Release a reservation
+ * POST /api/batch/:id/reservations/:reservationId/release
+ */
+const releaseReservation = asyncHandler(async (req, res) => {
+  const { id: batchId, reservationId } = req.params;
+  const result = await riceService.releaseReservation(req.role, batchId, reservationId);
+
+  res.json({
+    success: true,
+    message: `Reservation ${reservationId} on batch ${batchId} released`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the reservations of a batch
+ * GET /api/batch/:id/reservations
+ */
+const getBatchReservations = asyncHandler(async (req, res) => {
+  const summary = await riceService.getBatchReservations(req.role, req.params.id);
+
+  res.json({
+    success: true,
+    data: summary,
+    count: summary.reservations.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Schedule the transfer of a batch for a contractual date
+ * POST /api/batch/:id/scheduled-transfers
+ */
+const scheduleTransfer = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const schedule = await riceService.scheduleTransfer(req.role, batchId, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `Transfer of batch ${batchId} to ${schedule.newOwner} scheduled for ${schedule.effectiveTime}`,
+    data: schedule,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Activate the pending scheduled transfer of a batch
+ * POST /api/batch/:id/scheduled-transfers/activate
+ */
+const activateScheduledTransfer = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const schedule = await riceService.activateScheduledTransfer(req.role, batchId);
+
+  res.json({
+    success: true,
+    message: `Batch ${batchId} handed over to ${schedule.newOwner}`,
+    data: schedule,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Cancel the pending scheduled transfer of a batch
+ * POST /api/batch/:id/scheduled-transfers/cancel
+ */
+const cancelScheduledTransfer = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const result = await riceService.cancelScheduledTransfer(req.role, batchId);
+
+  res.json({
+    success: true,
+    message: `Scheduled transfer of batch ${batchId} cancelled`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the scheduled transfers of a batch
+ * GET /api/batch/:id/scheduled-transfers
+ */
+const getScheduledTransfers = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const schedules = await riceService.getScheduledTransfers(req.role, batchId);
+
+  res.json({
+    success: true,
+    data: schedules,
+    count: schedules.length,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the scheduled transfers due for activation
+ * GET /api/batch/scheduled-transfers/due
+ */
+const getDueScheduledTransfers = asyncHandler(async (req, res) => {
+  const schedules = await riceService.getDueScheduledTransfers(req.role);
+
+  res.json({
+    success: true,
+    data: schedules,
+    count: schedules.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Correct a mistyped processing record
+ * POST /api/batch/:id/history/:index/corrections
+ */
+const correctProcessingRecord = asyncHandler(async (req, res) => {
+  const { id: batchId, index } = req.params;
+  const correction = await riceService.correctProcessingRecord(req.role, batchId, index, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `Record ${index} of batch ${batchId} corrected`,
+    data: correction,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Add a run of processing records in one transaction
+ * POST /api/batch/:id/processing-records
+ */
+const addProcessingRecords = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const result = await riceService.addProcessingRecords(req.role, batchId, req.body.records, req.get('Idempotency-Key') || '');
+
+  res.status(201).json({
+    success: true,
+    message: `${result.added} processing records added to batch ${batchId}`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Report references to missing entities across the ledger
+ * GET /api/integrity
+ */
+const validateReferentialIntegrity = asyncHandler(async (req, res) => {
+  const report = await riceService.validateReferentialIntegrity(req.role);
+
+  res.json({
+    success: true,
+    data: report,
+    count: report.issues.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the inventory of an owner
+ * GET /api/batch/inventory/:owner
+ */
+const getOwnerInventory = asyncHandler(async (req, res) => {
+  const inventory = await riceService.getOwnerInventory(req.role, req.params.owner);
+
+  res.json({
+    success: true,
+    data: inventory,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Compare offered lots side by side
+ * GET /api/batch/compare?ids=batch1,batch2
+ */
+const compareBatches = asyncHandler(async (req, res) => {
+  const { ids = '' } = req.query;
+  const batchIds = String(ids).split(',').map(id => id.trim()).filter(Boolean);
+  const comparison = await riceService.compareBatches(req.role, batchIds);
+
+  res.json({
+    success: true,
+    data: comparison,
+    count: comparison.batches.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the batches carrying a label
+ * GET /api/batch/label/:key?value=
+ */
+const getBatchesByLabel = asyncHandler(async (req, res) => {
+  const { key } = req.params;
+  const { value = '' } = req.query;
+  const batches = await riceService.getBatchesByLabel(req.role, key, value);
+
+  res.json({
+    success: true,
+    data: batches,
+    count: batches.length,
+    label: { key, value },
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
 module.exports = {
   getAllBatches,
+  setTestReportDetails,
+  getTestReportDetails,
+  setBatchLabels,
+  getBatchesByLabel,
+  getOwnerInventory,
+  compareBatches,
+  grantDelegate,
+  revokeDelegate,
+  setBatchQuantity,
+  recordWeightAdjustment,
+  reserveBatchQuantity,
+  releaseReservation,
+  getBatchReservations,
+  correctProcessingRecord,
+  addProcessingRecords,
+  validateReferentialIntegrity,
+  getBatchesByStep,
+  getBatchesByStatus,
+  searchBatches,
   getBatchById,
   checkBatchExists,
   createBatch,
@@ -281,7 +1206,39 @@ module.exports = {
   addTestResult,
   addProcessingRecord,
   getBatchStats,
+  getDailyStats,
+  getNetworkKpis,
+  getSeasonStats,
+  compareSeasons,
+  getQualityTrends,
   getOracleStatus,
+  getChaincodeStatus,
   completeStepAndTransfer,
-  getCurrentBatchOwner
+  checkTransfer,
+  getCurrentBatchOwner,
+  setCommercialTerms,
+  getCommercialTerms,
+  verifyTestReportHash,
+  revokeTestResult,
+  recordMoistureContent,
+  scheduleTransfer,
+  activateScheduledTransfer,
+  cancelScheduledTransfer,
+  getScheduledTransfers,
+  getDueScheduledTransfers,
+  linkForeignBatch,
+  verifyForeignBatchReference,
+  attachInsurancePolicy,
+  claimInsurance,
+  getBatchStateHash,
+  getBatchHistoryDiff,
+  getBatchGenealogy,
+  getTraceabilityScore,
+  getWellDocumentedBatches,
+  getBatchStorageUsage,
+  exportBatchHistory,
+  exportAuditPackage,
+  getBatchLabel,
+  getBatchLabelSheet,
+  exportBatches
 }; 
\ No newline at end of file
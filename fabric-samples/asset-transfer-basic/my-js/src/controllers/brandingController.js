@@ -0,0 +1,60 @@
+const brandingService = require('../services/BrandingService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Branding controller
+ * Handles the brands organizations present to consumers
+ */
+
+/**
+ * Register or replace the brand of the caller's organization
+ * PUT /api/organizations/branding
+ */
+const setBranding = asyncHandler(async (req, res) => {
+  const branding = await brandingService.setBranding(req.role, req.body);
+
+  res.json({
+    success: true,
+    message: `Brand ${branding.brandName} registered for ${branding.mspId}`,
+    data: branding,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the brands of all organizations
+ * GET /api/organizations/branding
+ */
+const getAllBrandings = asyncHandler(async (req, res) => {
+  const brandings = await brandingService.getAllBrandings(req.role);
+
+  res.json({
+    success: true,
+    data: brandings,
+    count: brandings.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the brand of an organization
+ * GET /api/organizations/:mspId/branding
+ */
+const getBranding = asyncHandler(async (req, res) => {
+  const branding = await brandingService.getBranding(req.role, req.params.mspId);
+
+  res.json({
+    success: true,
+    data: branding,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  setBranding,
+  getAllBrandings,
+  getBranding
+};
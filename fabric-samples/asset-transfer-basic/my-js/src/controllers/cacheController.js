@@ -1,4 +1,5 @@
 const cacheService = require('../services/CacheService');
+const cacheInvalidationService = require('../services/CacheInvalidationService');
 const { asyncHandler } = require('../middleware/errorMiddleware');
 
 /**
@@ -15,7 +16,7 @@ const getCacheStats = asyncHandler(async (req, res) => {
   
   res.json({
     success: true,
-    data: stats,
+    data: { ...stats, eventInvalidation: cacheInvalidationService.getStatus() },
     timestamp: new Date().toISOString()
   });
 });
@@ -0,0 +1,136 @@
+const complianceService = require('../services/ComplianceService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Export compliance controller
+ * Handles the compliance profiles of export markets, compliance checks of batches and residue levels of tests
+ */
+
+/**
+ * Create or update a compliance profile
+ * PUT /api/compliance-profiles/:market
+ */
+const defineProfile = asyncHandler(async (req, res) => {
+  const { market } = req.params;
+  const result = await complianceService.defineProfile(req.role, market, req.body);
+
+  res.json({
+    success: true,
+    message: `Compliance profile ${result.market} defined`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get all compliance profiles
+ * GET /api/compliance-profiles
+ */
+const getAllProfiles = asyncHandler(async (req, res) => {
+  const profiles = await complianceService.getAllProfiles(req.role);
+
+  res.json({
+    success: true,
+    data: profiles,
+    count: profiles.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a compliance profile
+ * GET /api/compliance-profiles/:market
+ */
+const getProfile = asyncHandler(async (req, res) => {
+  const { market } = req.params;
+  const profile = await complianceService.getProfile(req.role, market);
+
+  res.json({
+    success: true,
+    data: profile,
+    market,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Configure the domestic market
+ * PUT /api/domestic-market
+ */
+const defineDomesticMarket = asyncHandler(async (req, res) => {
+  const result = await complianceService.defineDomesticMarket(req.role, req.body.country);
+
+  res.json({
+    success: true,
+    message: `Domestic market set to ${result.country}`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the domestic market
+ * GET /api/domestic-market
+ */
+const getDomesticMarket = asyncHandler(async (req, res) => {
+  const market = await complianceService.getDomesticMarket(req.role);
+
+  res.json({
+    success: true,
+    data: market,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Check whether a batch may be exported to a market
+ * GET /api/batch/:id/export-compliance?market=
+ */
+const checkExportCompliance = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const check = await complianceService.checkExportCompliance(req.role, batchId, req.query.market);
+
+  res.json({
+    success: true,
+    message: check.compliant
+      ? `Batch ${batchId} meets the ${check.market} compliance profile`
+      : `Batch ${batchId} misses ${check.violations.length} requirement(s) of the ${check.market} compliance profile`,
+    data: check,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Record the residue levels a test measured
+ * POST /api/batch/:id/test/:testId/residues
+ */
+const recordResidueLevels = asyncHandler(async (req, res) => {
+  const { id: batchId, testId } = req.params;
+  const result = await complianceService.recordResidueLevels(req.role, batchId, testId, req.body.residues);
+
+  res.status(201).json({
+    success: true,
+    message: `Residue levels of test result ${testId} recorded`,
+    data: result,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  defineProfile,
+  getAllProfiles,
+  getProfile,
+  defineDomesticMarket,
+  getDomesticMarket,
+  checkExportCompliance,
+  recordResidueLevels
+};
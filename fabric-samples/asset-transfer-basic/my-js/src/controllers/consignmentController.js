@@ -0,0 +1,102 @@
+const consignmentService = require('../services/ConsignmentService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Consignment controller
+ * Handles export consignments and their customs documentation
+ */
+
+/**
+ * Prepare an export consignment
+ * POST /api/consignments
+ */
+const createConsignment = asyncHandler(async (req, res) => {
+  const result = await consignmentService.createConsignment(req.role, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `Consignment ${result.consignmentId} prepared`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Move a consignment to its next status
+ * POST /api/consignments/:consignmentId/status
+ */
+const advanceConsignment = asyncHandler(async (req, res) => {
+  const { consignmentId } = req.params;
+  const result = await consignmentService.advanceConsignment(req.role, consignmentId, req.body);
+
+  res.json({
+    success: true,
+    message: `Consignment ${consignmentId} is now ${result.status}`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Hand every batch and product of a consignment over to the importer
+ * POST /api/consignments/:consignmentId/transfer
+ */
+const transferConsignment = asyncHandler(async (req, res) => {
+  const { consignmentId } = req.params;
+  const result = await consignmentService.transferConsignment(req.role, consignmentId, req.body, req.get('Idempotency-Key') || '');
+
+  res.json({
+    success: true,
+    message: result.complete
+      ? `Consignment ${consignmentId} transferred to ${result.newOwner}`
+      : `${result.transferred} items of consignment ${consignmentId} transferred, ${result.remaining} remaining`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a consignment
+ * GET /api/consignments/:consignmentId
+ */
+const getConsignment = asyncHandler(async (req, res) => {
+  const { consignmentId } = req.params;
+  const consignment = await consignmentService.getConsignment(req.role, consignmentId);
+
+  res.json({
+    success: true,
+    data: consignment,
+    consignmentId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the consignments a batch or product was exported in
+ * GET /api/consignments/entity/:entityId
+ */
+const getConsignmentsByEntity = asyncHandler(async (req, res) => {
+  const { entityId } = req.params;
+  const consignments = await consignmentService.getConsignmentsByEntity(req.role, entityId);
+
+  res.json({
+    success: true,
+    data: consignments,
+    count: consignments.length,
+    entityId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  createConsignment,
+  advanceConsignment,
+  transferConsignment,
+  getConsignment,
+  getConsignmentsByEntity
+};
@@ -0,0 +1,133 @@
+const traceCertificateService = require('../services/TraceCertificateService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Document controller
+ * Handles PDF traceability certificates and the verification of anchored documents
+ */
+
+/**
+ * Send an issued certificate with its number and anchored hash in headers
+ * @private
+ */
+function sendCertificate(res, certificate) {
+  res.status(201);
+  res.setHeader('Content-Type', certificate.contentType);
+  res.setHeader('Content-Disposition', `attachment; filename="${certificate.filename}"`);
+  res.setHeader('X-Certificate-Id', certificate.certificateId);
+  res.setHeader('X-Document-Hash', certificate.documentHash);
+  res.send(certificate.body);
+}
+
+/**
+ * Issue a PDF traceability certificate of a batch and anchor its hash
+ * POST /api/batch/:id/certificate
+ */
+const issueBatchCertificate = asyncHandler(async (req, res) => {
+  const certificate = await traceCertificateService.issueCertificate(req.role, 'batch', req.params.id);
+  sendCertificate(res, certificate);
+});
+
+/**
+ * Issue a PDF traceability certificate of a product and anchor its hash
+ * POST /api/product/:id/certificate
+ */
+const issueProductCertificate = asyncHandler(async (req, res) => {
+  const certificate = await traceCertificateService.issueCertificate(req.role, 'product', req.params.id);
+  sendCertificate(res, certificate);
+});
+
+/**
+ * Check a document against the anchored hashes; the body is the PDF (application/pdf) or { documentHash }
+ * POST /api/documents/verify
+ */
+const verifyDocument = asyncHandler(async (req, res) => {
+  const input = Buffer.isBuffer(req.body) ? { file: req.body } : { documentHash: (req.body || {}).documentHash };
+  const result = await traceCertificateService.verifyDocument(req.role, input);
+
+  res.json({
+    success: true,
+    message: result.verified
+      ? `Document ${result.document.documentId} was issued by ${result.document.issuedBy} and has not been altered`
+      : 'The document does not match any anchored document',
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get an anchored document by ID
+ * GET /api/documents/:documentId
+ */
+const getDocument = asyncHandler(async (req, res) => {
+  const document = await traceCertificateService.getDocument(req.role, req.params.documentId);
+
+  res.json({
+    success: true,
+    data: document,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the documents issued about a batch or product
+ * GET /api/documents/entity/:entityId
+ */
+const getEntityDocuments = asyncHandler(async (req, res) => {
+  const documents = await traceCertificateService.getEntityDocuments(req.role, req.params.entityId);
+
+  res.json({
+    success: true,
+    data: documents,
+    count: documents.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Acknowledge having received and reviewed a quality document
+ * POST /api/documents/entity/:entityId/acknowledgments
+ */
+const acknowledgeDocument = asyncHandler(async (req, res) => {
+  const { entityId } = req.params;
+  const acknowledgment = await traceCertificateService.acknowledgeDocument(req.role, entityId, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `Document ${acknowledgment.documentHash} of ${entityId} acknowledged`,
+    data: acknowledgment,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the acknowledgments of the documents of a batch or product
+ * GET /api/documents/entity/:entityId/acknowledgments?documentHash=
+ */
+const getAcknowledgments = asyncHandler(async (req, res) => {
+  const { entityId } = req.params;
+  const acknowledgments = await traceCertificateService.getAcknowledgments(req.role, entityId, req.query.documentHash || '');
+
+  res.json({
+    success: true,
+    data: acknowledgments,
+    count: acknowledgments.length,
+    entityId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  issueBatchCertificate,
+  issueProductCertificate,
+  verifyDocument,
+  getDocument,
+  getEntityDocuments,
+  acknowledgeDocument,
+  getAcknowledgments
+};
@@ -0,0 +1,63 @@
+const epcisService = require('../services/EpcisService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * EPCIS controller
+ * Handles EPCIS capture imports from partner systems and the logistics units and shipments they produce
+ */
+
+/**
+ * Import an EPCIS 2.0 capture document
+ * POST /api/epcis/capture
+ */
+const captureDocument = asyncHandler(async (req, res) => {
+  const result = await epcisService.importDocument(req.role, req.body);
+
+  res.json({
+    success: true,
+    message: `Imported ${result.eventCount - result.skipped.length} of ${result.eventCount} EPCIS events`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a logistics unit
+ * GET /api/epcis/units/:id
+ */
+const getLogisticsUnit = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const unit = await epcisService.getLogisticsUnit(req.role, id);
+
+  res.json({
+    success: true,
+    data: unit,
+    unitId: id,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a shipment
+ * GET /api/epcis/shipments/:id
+ */
+const getShipment = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const shipment = await epcisService.getShipment(req.role, id);
+
+  res.json({
+    success: true,
+    data: shipment,
+    shipmentId: id,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  captureDocument,
+  getLogisticsUnit,
+  getShipment
+};
@@ -0,0 +1,101 @@
+const equipmentService = require('../services/EquipmentService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Equipment controller
+ * Handles the processing equipment registry
+ */
+
+/**
+ * Register a piece of equipment
+ * POST /api/equipment
+ */
+const registerEquipment = asyncHandler(async (req, res) => {
+  const result = await equipmentService.registerEquipment(req.role, req.body);
+
+  res.json({
+    success: true,
+    message: `Equipment ${req.body.equipmentId} registered`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Record a calibration
+ * POST /api/equipment/:equipmentId/calibrations
+ */
+const recordCalibration = asyncHandler(async (req, res) => {
+  const { equipmentId } = req.params;
+  const result = await equipmentService.recordCalibration(req.role, equipmentId, req.body);
+
+  res.json({
+    success: true,
+    message: `Calibration of ${equipmentId} recorded`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Record maintenance
+ * POST /api/equipment/:equipmentId/maintenance
+ */
+const recordMaintenance = asyncHandler(async (req, res) => {
+  const { equipmentId } = req.params;
+  const result = await equipmentService.recordMaintenance(req.role, equipmentId, req.body);
+
+  res.json({
+    success: true,
+    message: `Maintenance of ${equipmentId} recorded`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a piece of equipment
+ * GET /api/equipment/:equipmentId
+ */
+const getEquipment = asyncHandler(async (req, res) => {
+  const { equipmentId } = req.params;
+  const equipment = await equipmentService.getEquipment(req.role, equipmentId);
+
+  res.json({
+    success: true,
+    data: equipment,
+    equipmentId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the batches processed on a piece of equipment
+ * GET /api/equipment/:equipmentId/usage?from=&to=
+ */
+const getEquipmentUsage = asyncHandler(async (req, res) => {
+  const { equipmentId } = req.params;
+  const { from = '', to = '' } = req.query;
+  const usage = await equipmentService.getEquipmentUsage(req.role, equipmentId, from, to);
+
+  res.json({
+    success: true,
+    data: usage,
+    count: usage.length,
+    equipmentId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  registerEquipment,
+  recordCalibration,
+  recordMaintenance,
+  getEquipment,
+  getEquipmentUsage
+};
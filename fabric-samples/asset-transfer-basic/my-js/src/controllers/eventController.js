@@ -0,0 +1,28 @@
+const eventBridgeService = require('../services/EventBridgeService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Event controller
+ * Handles replays of past chaincode events to an event bridge sink
+ */
+
+/**
+ * Replay the chaincode events of a block or time range to one sink
+ * POST /api/events/replay
+ */
+const replayEvents = asyncHandler(async (req, res) => {
+  const { sink, fromBlock, toBlock, from, to } = req.body;
+  const summary = await eventBridgeService.replay({ sink, fromBlock, toBlock, from, to });
+
+  res.json({
+    success: true,
+    message: `${summary.events} event(s) of blocks ${summary.fromBlock}-${summary.toBlock} replayed to ${sink}`,
+    data: summary,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  replayEvents
+};
@@ -0,0 +1,33 @@
+const explorerService = require('../services/ExplorerService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Explorer controller
+ * Gives support staff the ledger transactions of a batch or product without access to the peers' blocks
+ */
+
+/**
+ * List the transactions that touched a batch or product
+ * GET /api/batch/:id/transactions?limit=50&offset=0
+ * GET /api/product/:id/transactions?limit=50&offset=0
+ */
+const getEntityTransactions = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const { limit, offset } = req.query;
+  const page = await explorerService.getEntityTransactions(id, { limit, offset });
+
+  res.json({
+    success: true,
+    data: page.transactions,
+    count: page.transactions.length,
+    total: page.total,
+    limit: page.limit,
+    offset: page.offset,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  getEntityTransactions
+};
@@ -0,0 +1,66 @@
+const facilityService = require('../services/FacilityService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Facility controller
+ * Handles the registry of processing sites, their lines and shifts
+ */
+
+/**
+ * Register or update a facility
+ * PUT /api/facilities/:facilityId
+ */
+const registerFacility = asyncHandler(async (req, res) => {
+  const { facilityId } = req.params;
+  const result = await facilityService.registerFacility(req.role, facilityId, req.body);
+
+  res.json({
+    success: true,
+    message: `Facility ${facilityId} registered`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a facility
+ * GET /api/facilities/:facilityId
+ */
+const getFacility = asyncHandler(async (req, res) => {
+  const { facilityId } = req.params;
+  const facility = await facilityService.getFacility(req.role, facilityId);
+
+  res.json({
+    success: true,
+    data: facility,
+    facilityId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the processing steps recorded at a facility
+ * GET /api/facilities/:facilityId/activity?from=&to=&line=&shift=
+ */
+const getFacilityActivity = asyncHandler(async (req, res) => {
+  const { facilityId } = req.params;
+  const { from = '', to = '', line = '', shift = '' } = req.query;
+  const activity = await facilityService.getFacilityActivity(req.role, facilityId, { from, to, line, shift });
+
+  res.json({
+    success: true,
+    data: activity,
+    count: activity.length,
+    facilityId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  registerFacility,
+  getFacility,
+  getFacilityActivity
+};
@@ -0,0 +1,83 @@
+const giService = require('../services/GIService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Geographic indication controller
+ * Handles GI rules of protected origins and compliance checks of batches
+ */
+
+/**
+ * Create or update a GI rule
+ * PUT /api/gi/:giId
+ */
+const defineRule = asyncHandler(async (req, res) => {
+  const { giId } = req.params;
+  const result = await giService.defineRule(req.role, giId, req.body);
+
+  res.json({
+    success: true,
+    message: `GI rule ${giId} defined`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get all GI rules
+ * GET /api/gi
+ */
+const getAllRules = asyncHandler(async (req, res) => {
+  const rules = await giService.getAllRules(req.role);
+
+  res.json({
+    success: true,
+    data: rules,
+    count: rules.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a GI rule
+ * GET /api/gi/:giId
+ */
+const getRule = asyncHandler(async (req, res) => {
+  const { giId } = req.params;
+  const rule = await giService.getRule(req.role, giId);
+
+  res.json({
+    success: true,
+    data: rule,
+    giId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Check a batch against a GI rule
+ * POST /api/batch/:id/gi-check
+ */
+const checkCompliance = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const { giId, plotId = '' } = req.body;
+  const check = await giService.checkCompliance(req.role, batchId, giId, plotId);
+
+  res.json({
+    success: true,
+    message: check.passed ? `Batch ${batchId} complies with ${check.giName}` : `Batch ${batchId} violates ${check.giName}`,
+    data: check,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  defineRule,
+  getAllRules,
+  getRule,
+  checkCompliance
+};
@@ -0,0 +1,67 @@
+const { typeDefs, rootValue, createContext } = require('../graphql/schema');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+const { errorCodes } = require('../../config');
+
+/**
+ * GraphQL controller
+ * Executes GraphQL queries over batches, products and their history
+ */
+
+let schema = null;
+
+/**
+ * Build the schema on first use
+ * graphql is an optional dependency, only needed when the GraphQL endpoint is used
+ * @private
+ */
+function getGraphQL() {
+  let graphqlModule;
+  try {
+    graphqlModule = require('graphql');
+  } catch {
+    throw new Error(`${errorCodes.INTERNAL_ERROR}: GraphQL endpoint requires the graphql package (npm install graphql)`);
+  }
+
+  if (!schema) {
+    schema = graphqlModule.buildSchema(typeDefs);
+  }
+  return { graphql: graphqlModule.graphql, schema };
+}
+
+/**
+ * Execute GraphQL query
+ * POST /api/graphql
+ * Body: { query, variables, operationName }
+ */
+const executeQuery = asyncHandler(async (req, res) => {
+  const { query, variables, operationName } = req.body;
+  if (!query || typeof query !== 'string') {
+    throw new Error(`${errorCodes.VALIDATION_ERROR}: GraphQL query is required`);
+  }
+
+  const { graphql, schema: builtSchema } = getGraphQL();
+  const result = await graphql({
+    schema: builtSchema,
+    source: query,
+    rootValue,
+    contextValue: createContext(req.role),
+    variableValues: variables,
+    operationName
+  });
+
+  // Per the GraphQL over HTTP convention, field errors are returned alongside partial data
+  res.status(result.data === undefined ? 400 : 200).json(result);
+});
+
+/**
+ * Get GraphQL schema (SDL)
+ * GET /api/graphql/schema
+ */
+const getSchema = (req, res) => {
+  res.type('text/plain').send(typeDefs);
+};
+
+module.exports = {
+  executeQuery,
+  getSchema
+};
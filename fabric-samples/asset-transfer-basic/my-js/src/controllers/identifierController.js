@@ -0,0 +1,58 @@
+const identifierService = require('../services/IdentifierService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Identifier controller
+ * Handles the ID policy and drawing batch and product IDs under it
+ */
+
+/**
+ * Get the ID policy in force
+ * GET /api/id-policy
+ */
+const getIdPolicy = asyncHandler(async (req, res) => {
+  const policy = await identifierService.getPolicy(req.role);
+
+  res.json({
+    success: true,
+    data: policy,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Draw the next batch ID of the caller's organization
+ * POST /api/batch/ids
+ */
+const generateBatchId = asyncHandler(async (req, res) => {
+  const batchId = await identifierService.generateId(req.role, 'batch');
+
+  res.status(201).json({
+    success: true,
+    data: { batchId },
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Draw the next product ID of the caller's organization
+ * POST /api/product/ids
+ */
+const generateProductId = asyncHandler(async (req, res) => {
+  const productId = await identifierService.generateId(req.role, 'product');
+
+  res.status(201).json({
+    success: true,
+    data: { productId },
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  getIdPolicy,
+  generateBatchId,
+  generateProductId
+};
@@ -0,0 +1,26 @@
+const inputLimitService = require('../services/InputLimitService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Input limit controller
+ * Handles the size limits the chaincode applies to transaction inputs
+ */
+
+/**
+ * Get the input limits in force
+ * GET /api/input-limits
+ */
+const getInputLimits = asyncHandler(async (req, res) => {
+  const limits = await inputLimitService.getLimits(req.role);
+
+  res.json({
+    success: true,
+    data: limits,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  getInputLimits
+};
@@ -0,0 +1,60 @@
+const inspectionService = require('../services/InspectionService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Inspection controller
+ * Handles the risk-weighted draws of batches for regulator spot checks
+ */
+
+/**
+ * Draw batches for spot checks
+ * POST /api/inspections/selections
+ */
+const selectBatches = asyncHandler(async (req, res) => {
+  const selection = await inspectionService.selectBatches(req.role, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `${selection.selected.length} of ${selection.candidateCount} batches selected for inspection`,
+    data: selection,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get all selections
+ * GET /api/inspections/selections
+ */
+const getSelections = asyncHandler(async (req, res) => {
+  const selections = await inspectionService.getSelections(req.role);
+
+  res.json({
+    success: true,
+    data: selections,
+    count: selections.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a selection by its seed
+ * GET /api/inspections/selections/:seedTxId
+ */
+const getSelection = asyncHandler(async (req, res) => {
+  const selection = await inspectionService.getSelection(req.role, req.params.seedTxId);
+
+  res.json({
+    success: true,
+    data: selection,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  selectBatches,
+  getSelections,
+  getSelection
+};
@@ -0,0 +1,63 @@
+const notificationService = require('../services/NotificationService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Notification controller
+ * Handles the notification preferences of participants
+ */
+
+/**
+ * Register or replace the notification preferences of a participant
+ * PUT /api/notifications/preferences/:participantId
+ */
+const setPreference = asyncHandler(async (req, res) => {
+  const { participantId } = req.params;
+  const result = await notificationService.setPreference(req.role, participantId, req.body);
+
+  res.json({
+    success: true,
+    message: `Notification preferences of ${participantId} saved`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the notification preferences of a participant
+ * GET /api/notifications/preferences/:participantId
+ */
+const getPreference = asyncHandler(async (req, res) => {
+  const { participantId } = req.params;
+  const preference = await notificationService.getPreference(req.role, participantId);
+
+  res.json({
+    success: true,
+    data: preference,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Remove the notification preferences of a participant
+ * DELETE /api/notifications/preferences/:participantId
+ */
+const removePreference = asyncHandler(async (req, res) => {
+  const { participantId } = req.params;
+  const result = await notificationService.removePreference(req.role, participantId);
+
+  res.json({
+    success: true,
+    message: `Notification preferences of ${participantId} removed`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  setPreference,
+  getPreference,
+  removePreference
+};
@@ -0,0 +1,47 @@
+const offlineSigningService = require('../services/OfflineSigningService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Offline signing controller
+ * Handles transactions of identities whose keys stay in an air-gapped HSM: prepare, then one signature per stage
+ */
+
+/**
+ * Prepare the proposal of a transaction for offline signing
+ * POST /api/offline-transactions
+ */
+const prepareTransaction = asyncHandler(async (req, res) => {
+  const prepared = await offlineSigningService.prepare(req.role, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `Sign the proposal digest of transaction ${prepared.transactionId}`,
+    data: prepared,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Submit the signature of the current stage of an offline-signed transaction
+ * POST /api/offline-transactions/:transactionId/signatures
+ */
+const submitSignature = asyncHandler(async (req, res) => {
+  const { transactionId } = req.params;
+  const next = await offlineSigningService.sign(req.role, transactionId, req.body);
+
+  res.json({
+    success: true,
+    message: next.stage === 'committed'
+      ? `Transaction ${transactionId} committed`
+      : `Sign the ${next.stage} digest of transaction ${transactionId}`,
+    data: next,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  prepareTransaction,
+  submitSignature
+};
@@ -0,0 +1,43 @@
+const participantService = require('../services/ParticipantService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Participant controller
+ * Handles onboarding of participants through the Fabric CAs and the on-ledger participant registry
+ */
+
+/**
+ * Register and enroll a participant with its organization's CA and register it on the ledger
+ * POST /api/participants
+ */
+const onboardParticipant = asyncHandler(async (req, res) => {
+  const result = await participantService.onboardParticipant(req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `Participant ${result.participant.participantId} onboarded; act as it with X-User-Role: ${result.identity.role} and X-Fabric-Identity: ${result.identity.name}`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a registered participant
+ * GET /api/participants/:participantId
+ */
+const getParticipant = asyncHandler(async (req, res) => {
+  const participant = await participantService.getParticipant(req.role, req.params.participantId);
+
+  res.json({
+    success: true,
+    data: participant,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  onboardParticipant,
+  getParticipant
+};
@@ -0,0 +1,70 @@
+const marketPriceService = require('../services/MarketPriceService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Market price controller
+ * Serves the oracle-recorded market price series
+ */
+
+/**
+ * Get the price series of a variety in a region
+ * GET /api/prices/:variety/:region?from=&to=
+ */
+const getPriceSeries = asyncHandler(async (req, res) => {
+  const { variety, region } = req.params;
+  const { from = '', to = '' } = req.query;
+  const prices = await marketPriceService.getPriceSeries(req.role, variety, region, from, to);
+
+  res.json({
+    success: true,
+    data: prices,
+    count: prices.length,
+    variety,
+    region,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the price in force on a day
+ * GET /api/prices/:variety/:region/reference?date=&maxAgeDays=
+ */
+const getReferencePrice = asyncHandler(async (req, res) => {
+  const { variety, region } = req.params;
+  const { date, maxAgeDays = '' } = req.query;
+  const price = await marketPriceService.getReferencePrice(req.role, variety, region, date, maxAgeDays);
+
+  res.json({
+    success: true,
+    data: price,
+    variety,
+    region,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the price recorded for a day
+ * GET /api/prices/:variety/:region/:date
+ */
+const getMarketPrice = asyncHandler(async (req, res) => {
+  const { variety, region, date } = req.params;
+  const price = await marketPriceService.getMarketPrice(req.role, variety, region, date);
+
+  res.json({
+    success: true,
+    data: price,
+    variety,
+    region,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  getPriceSeries,
+  getReferencePrice,
+  getMarketPrice
+};
@@ -1,4 +1,6 @@
 const productService = require('../services/ProductService');
+const labelService = require('../services/LabelService');
+const translationService = require('../services/TranslationService');
 const { asyncHandler } = require('../middleware/errorMiddleware');
 
 /**
@@ -18,7 +20,7 @@ const createProduct = asyncHandler(async (req, res) => {
     owner: req.body.owner
   };
 
-  const result = await productService.createProduct(req.role, productData);
+  const result = await productService.createProduct(req.role, productData, req.get('Idempotency-Key') || '');
   
   res.status(201).json({
     success: true,
@@ -30,21 +32,152 @@ const createProduct = asyncHandler(async (req, res) => {
 
 /**
  * Get product by ID
- * GET /api/product/:id
+ * GET /api/product/:id?locale=zh|en
+ * With a locale, the product status and grade and the state and steps of its batch carry their labels
  */
 const getProductById = asyncHandler(async (req, res) => {
   const { id } = req.params;
   const product = await productService.getProductById(req.role, id);
-  
+  const dictionary = req.query.locale ? await translationService.getDictionary(String(req.query.locale).toLowerCase()) : null;
+
   res.json({
     success: true,
-    data: product,
+    data: dictionary
+      ? {
+        ...product,
+        product: translationService.localizeProduct(product.product, dictionary),
+        batch: product.batch && translationService.localizeBatch(product.batch, dictionary)
+      }
+      : product,
+    ...(dictionary && { locale: dictionary.locale, labels: dictionary.fields }),
     productId: id,
     role: req.role,
     timestamp: new Date().toISOString()
   });
 });
 
+/**
+ * Get products held by an owner (paginated)
+ * GET /api/product/owner/:owner?pageSize=20&bookmark=
+ */
+const getProductsByOwner = asyncHandler(async (req, res) => {
+  const { owner } = req.params;
+  const { pageSize, bookmark } = req.query;
+  const page = await productService.getProductsByOwner(req.role, owner, pageSize, bookmark);
+
+  res.json({
+    success: true,
+    data: page.products,
+    count: page.fetchedRecordsCount,
+    bookmark: page.bookmark,
+    owner,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Query products by owner, batch, status and package date range (paginated)
+ * GET /api/product/query?owner=&batchId=&status=&packageDateFrom=&packageDateTo=&pageSize=20&bookmark=
+ */
+const queryProducts = asyncHandler(async (req, res) => {
+  const { owner, batchId, status, packageDateFrom, packageDateTo, pageSize, bookmark } = req.query;
+  const selector = { owner, batchId, status, packageDateFrom, packageDateTo };
+  const page = await productService.queryProducts(req.role, selector, pageSize, bookmark);
+
+  res.json({
+    success: true,
+    data: page.products,
+    count: page.fetchedRecordsCount,
+    bookmark: page.bookmark,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Return a sold product to its distributor
+ * POST /api/product/:id/return
+ */
+const returnProduct = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const { reason, requireReinspection } = req.body;
+  const result = await productService.returnProduct(req.role, id, reason, requireReinspection, req.get('Idempotency-Key') || '');
+
+  res.json({
+    success: true,
+    ...result,
+    role: req.role
+  });
+});
+
+/**
+ * Register the verification code printed on a product package
+ * POST /api/product/:id/verification-code
+ */
+const registerVerificationCode = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const result = await productService.registerVerificationCode(req.role, id, req.body.code);
+
+  res.json({
+    success: true,
+    message: `Verification code of ${id} registered`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Generate a product label: a QR code of the public trace URL with a newly registered verification code
+ * POST /api/product/:id/qr?format=png|svg&size=
+ * The code is also returned in the X-Verification-Code header, for printing it in clear text next to the QR code
+ */
+const createProductLabel = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const { format, size } = { ...req.query, ...req.body };
+  const label = await labelService.createProductLabel(req.role, id, { format, size });
+
+  res.status(201);
+  res.setHeader('Content-Type', label.contentType);
+  res.setHeader('Content-Disposition', `inline; filename="${label.filename}"`);
+  res.setHeader('X-Verification-Code', label.code);
+  res.setHeader('X-Trace-Url', label.url);
+  res.send(label.body);
+});
+
+/**
+ * Check the code on a product package
+ * POST /api/product/:id/verify
+ */
+const verifyProduct = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const result = await productService.verifyProduct(req.role, id, req.body.code);
+
+  res.json({
+    success: true,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the verification attempt counters and lockout of a product
+ * GET /api/product/:id/verification
+ */
+const getVerificationStatus = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const status = await productService.getVerificationStatus(req.role, id);
+
+  res.json({
+    success: true,
+    data: status,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
 /**
  * Get product traceability
  * GET /api/product/:id/traceability
@@ -79,9 +212,106 @@ const checkProductExists = asyncHandler(async (req, res) => {
   });
 });
 
+/**
+ * Set product nutrition facts and composition
+ * PUT /api/product/:id/nutrition
+ */
+const setProductNutrition = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const { nutrition, composition } = req.body;
+  const result = await productService.setProductNutrition(req.role, id, nutrition, composition);
+
+  res.json({
+    success: true,
+    ...result,
+    role: req.role
+  });
+});
+
+/**
+ * Replace the labels of a product
+ * PUT /api/product/:id/labels
+ */
+const setProductLabels = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const result = await productService.setProductLabels(req.role, id, req.body.labels);
+
+  res.json({
+    success: true,
+    message: `Labels of product ${id} updated`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the products carrying a label
+ * GET /api/product/label/:key?value=
+ */
+const getProductsByLabel = asyncHandler(async (req, res) => {
+  const { key } = req.params;
+  const { value = '' } = req.query;
+  const products = await productService.getProductsByLabel(req.role, key, value);
+
+  res.json({
+    success: true,
+    data: products,
+    count: products.length,
+    label: { key, value },
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the origins of the rice in a product with their shares
+ * GET /api/product/:id/origins
+ */
+const getProductComposition = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const composition = await productService.getProductComposition(req.role, id);
+
+  res.json({
+    success: true,
+    data: composition,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Check the trace chain of a product for gaps
+ * GET /api/product/:id/chain-validation
+ */
+const validateTraceChain = asyncHandler(async (req, res) => {
+  const { id } = req.params;
+  const validation = await productService.validateTraceChain(req.role, id);
+
+  res.json({
+    success: true,
+    data: validation,
+    count: validation.deficiencies.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
 module.exports = {
   createProduct,
+  setProductLabels,
+  getProductsByLabel,
   getProductById,
+  getProductsByOwner,
+  queryProducts,
+  returnProduct,
+  registerVerificationCode,
+  createProductLabel,
+  verifyProduct,
+  getVerificationStatus,
   getProductTraceability,
-  checkProductExists
+  checkProductExists,
+  setProductNutrition,
+  getProductComposition,
+  validateTraceChain
 }; 
\ No newline at end of file
@@ -0,0 +1,45 @@
+const queryService = require('../services/QueryService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Named query controller
+ * Handles the catalog of index-backed queries
+ */
+
+/**
+ * List the queries in the catalog
+ * GET /api/queries
+ */
+const listQueries = asyncHandler(async (req, res) => {
+  const queries = await queryService.listQueries(req.role);
+
+  res.json({
+    success: true,
+    data: queries,
+    count: queries.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Run a named query; the query string carries its parameters
+ * GET /api/queries/:name?<parameters>&pageSize=&bookmark=
+ */
+const runQuery = asyncHandler(async (req, res) => {
+  const { name } = req.params;
+  const result = await queryService.runQuery(req.role, name, req.query);
+
+  res.json({
+    success: true,
+    data: result,
+    query: name,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  listQueries,
+  runQuery
+};
@@ -0,0 +1,136 @@
+const recallService = require('../services/RecallService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Recall controller
+ * Handles recalls of batches and products and the notices to their current owners
+ */
+
+/**
+ * Issue a recall
+ * POST /api/recalls
+ */
+const issueRecall = asyncHandler(async (req, res) => {
+  const recall = await recallService.issueRecall(req.role, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `Recall ${recall.recallId} issued to ${recall.notices.length} owner(s)`,
+    data: recall,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a recall
+ * GET /api/recalls/:recallId
+ */
+const getRecall = asyncHandler(async (req, res) => {
+  const { recallId } = req.params;
+  const recall = await recallService.getRecall(req.role, recallId);
+
+  res.json({
+    success: true,
+    data: recall,
+    recallId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the recalls covering a batch or product
+ * GET /api/recalls/entity/:entityId
+ */
+const getRecallsByEntity = asyncHandler(async (req, res) => {
+  const { entityId } = req.params;
+  const recalls = await recallService.getRecallsByEntity(req.role, entityId);
+
+  res.json({
+    success: true,
+    data: recalls,
+    count: recalls.length,
+    entityId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Confirm that a notified owner has pulled recalled stock
+ * POST /api/recalls/:recallId/acknowledgments
+ */
+const acknowledgeRecall = asyncHandler(async (req, res) => {
+  const { recallId } = req.params;
+  const acknowledgment = await recallService.acknowledgeRecall(req.role, recallId, req.body);
+
+  res.status(201).json({
+    success: true,
+    message: `${acknowledgment.owner} acknowledged ${acknowledgment.itemIds.length} item(s) of recall ${recallId} as ${acknowledgment.disposition}`,
+    data: acknowledgment,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the acknowledgments of a recall
+ * GET /api/recalls/:recallId/acknowledgments
+ */
+const getRecallAcknowledgments = asyncHandler(async (req, res) => {
+  const { recallId } = req.params;
+  const acknowledgments = await recallService.getRecallAcknowledgments(req.role, recallId);
+
+  res.json({
+    success: true,
+    data: acknowledgments,
+    count: acknowledgments.length,
+    recallId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the completion dashboard of a recall
+ * GET /api/recalls/:recallId/completion
+ */
+const getRecallCompletion = asyncHandler(async (req, res) => {
+  const { recallId } = req.params;
+  const completion = await recallService.getRecallCompletion(req.role, recallId);
+
+  res.json({
+    success: true,
+    data: completion,
+    recallId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Find the batches and products reached by an agro-chemical
+ * GET /api/recalls/input-exposure?input=
+ */
+const findInputExposure = asyncHandler(async (req, res) => {
+  const exposure = await recallService.findInputExposure(req.role, req.query.input);
+
+  res.json({
+    success: true,
+    data: exposure,
+    count: exposure.batches.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  issueRecall,
+  getRecall,
+  getRecallsByEntity,
+  acknowledgeRecall,
+  getRecallAcknowledgments,
+  getRecallCompletion,
+  findInputExposure
+};
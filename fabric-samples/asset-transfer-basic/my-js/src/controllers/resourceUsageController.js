@@ -0,0 +1,45 @@
+const resourceUsageService = require('../services/ResourceUsageService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Resource usage controller
+ * Handles the private energy, labor and machine time of processing steps for internal cost analytics
+ */
+
+/**
+ * Get the caller organization's resource usage for a batch
+ * GET /api/batch/:id/resource-usage
+ */
+const getBatchUsage = asyncHandler(async (req, res) => {
+  const { id: batchId } = req.params;
+  const usage = await resourceUsageService.getBatchUsage(req.role, batchId);
+
+  res.json({
+    success: true,
+    data: usage,
+    count: usage.length,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Sum the caller organization's resource usage over a period
+ * GET /api/resource-usage/summary?period=<start>/<end>
+ */
+const getSummary = asyncHandler(async (req, res) => {
+  const summary = await resourceUsageService.getSummary(req.role, req.query.period);
+
+  res.json({
+    success: true,
+    data: summary,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  getBatchUsage,
+  getSummary
+};
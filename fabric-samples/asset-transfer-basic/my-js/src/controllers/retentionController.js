@@ -0,0 +1,64 @@
+const retentionService = require('../services/RetentionService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Retention controller
+ * Handles the retention periods and purging of private test report details and commercial terms
+ */
+
+/**
+ * Set the retention period of a kind of private data
+ * PUT /api/retention-policies/:dataType
+ */
+const defineRetentionPolicy = asyncHandler(async (req, res) => {
+  const { dataType } = req.params;
+  const result = await retentionService.defineRetentionPolicy(req.role, dataType, req.body.retentionDays);
+
+  res.json({
+    success: true,
+    message: `${dataType} is kept for ${result.retentionDays} days`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the retention policies of all organizations
+ * GET /api/retention-policies
+ */
+const getRetentionPolicies = asyncHandler(async (req, res) => {
+  const policies = await retentionService.getRetentionPolicies(req.role);
+
+  res.json({
+    success: true,
+    data: policies,
+    count: policies.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Purge private data past its retention period or older than a cutoff
+ * POST /api/retention-policies/:dataType/purge
+ */
+const purgePrivateData = asyncHandler(async (req, res) => {
+  const { dataType } = req.params;
+  const result = await retentionService.purgePrivateData(req.role, dataType, req.body.olderThan || '');
+
+  res.json({
+    success: true,
+    message: `${result.purged.length} ${dataType} record(s) purged`,
+    data: result,
+    count: result.purged.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  defineRetentionPolicy,
+  getRetentionPolicies,
+  purgePrivateData
+};
@@ -0,0 +1,64 @@
+const settlementService = require('../services/SettlementService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Settlement controller
+ * Handles the settlement statuses of batch handovers and farmer payout reports
+ */
+
+/**
+ * Record the settlement status of a handover
+ * PUT /api/batch/:id/history/:index/settlement
+ */
+const recordSettlement = asyncHandler(async (req, res) => {
+  const { id: batchId, index } = req.params;
+  const settlement = await settlementService.recordSettlement(req.role, batchId, index, req.body);
+
+  res.json({
+    success: true,
+    message: `Handover ${index} of batch ${batchId} is ${settlement.status}`,
+    data: settlement,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the settlement of a handover
+ * GET /api/batch/:id/history/:index/settlement
+ */
+const getSettlement = asyncHandler(async (req, res) => {
+  const { id: batchId, index } = req.params;
+  const settlement = await settlementService.getSettlement(req.role, batchId, index);
+
+  res.json({
+    success: true,
+    data: settlement,
+    batchId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the transfers out of a farmer's batches with their settlement statuses
+ * GET /api/participants/:participantId/settlement-report?period=
+ */
+const getFarmerSettlementReport = asyncHandler(async (req, res) => {
+  const { participantId } = req.params;
+  const report = await settlementService.getFarmerSettlementReport(req.role, participantId, req.query.period || '');
+
+  res.json({
+    success: true,
+    data: report,
+    count: report.lines.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  recordSettlement,
+  getSettlement,
+  getFarmerSettlementReport
+};
@@ -0,0 +1,33 @@
+const traceService = require('../services/TraceService');
+const translationService = require('../services/TranslationService');
+const { trace } = require('../../config');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Consumer trace controller
+ * Public endpoint of the mobile apps consumers scan product labels with
+ */
+
+/**
+ * Get the aggregated, localized trace of a product
+ * GET /api/trace/:productId?locale=zh|en (or ?lang=)
+ * The payload has no request timestamp, so the ETag stays stable and Express answers If-None-Match with 304
+ */
+const getProductTrace = asyncHandler(async (req, res) => {
+  const { productId } = req.params;
+  const locale = translationService.negotiateLocale(req.query.locale || req.query.lang, req.headers['accept-language']);
+  const result = await traceService.getProductTrace(productId, locale);
+
+  res.setHeader('Cache-Control', `public, max-age=${trace.maxAge}, stale-while-revalidate=${trace.maxAge * 12}`);
+  res.setHeader('Content-Language', locale);
+  res.setHeader('Vary', 'Accept-Language');
+  res.setHeader('ETag', result.etag);
+  res.json({
+    success: true,
+    data: result.trace
+  });
+});
+
+module.exports = {
+  getProductTrace
+};
@@ -0,0 +1,79 @@
+const translationService = require('../services/TranslationService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Translation controller
+ * Handles the Chinese and English labels of fields and recorded values kept on the ledger
+ */
+
+/**
+ * Set the labels of a field name or recorded value
+ * PUT /api/translations/:category/:code
+ */
+const setTranslation = asyncHandler(async (req, res) => {
+  const { category, code } = req.params;
+  const translation = await translationService.setTranslation(req.role, category, code, req.body);
+
+  res.json({
+    success: true,
+    message: `Labels of ${category} ${code} set`,
+    data: translation,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Remove the labels of a field name or recorded value
+ * DELETE /api/translations/:category/:code
+ */
+const removeTranslation = asyncHandler(async (req, res) => {
+  const { category, code } = req.params;
+  await translationService.removeTranslation(req.role, category, code);
+
+  res.json({
+    success: true,
+    message: `Labels of ${category} ${code} removed`,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the translations on the ledger
+ * GET /api/translations?category=
+ */
+const getTranslations = asyncHandler(async (req, res) => {
+  const { category = '' } = req.query;
+  const translations = await translationService.getTranslations(req.role, category);
+
+  res.json({
+    success: true,
+    data: translations,
+    count: translations.length,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the labels of a locale, built-in labels included
+ * GET /api/translations/dictionary/:locale
+ */
+const getDictionary = asyncHandler(async (req, res) => {
+  const dictionary = await translationService.getDictionary(String(req.params.locale).toLowerCase());
+
+  res.json({
+    success: true,
+    data: dictionary,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  setTranslation,
+  removeTranslation,
+  getTranslations,
+  getDictionary
+};
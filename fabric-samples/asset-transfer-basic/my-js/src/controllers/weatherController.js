@@ -0,0 +1,83 @@
+const weatherService = require('../services/WeatherService');
+const { asyncHandler } = require('../middleware/errorMiddleware');
+
+/**
+ * Weather controller
+ * Handles anchoring and reading weather observations of plots
+ */
+
+/**
+ * Anchor a weather observation
+ * POST /api/weather
+ */
+const anchorWeatherData = asyncHandler(async (req, res) => {
+  const result = await weatherService.anchorWeatherData(req.role, req.body);
+
+  res.json({
+    success: true,
+    message: `Weather data for plot ${req.body.plotId} anchored`,
+    data: result,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get the weather observations of a plot
+ * GET /api/weather/plot/:plotId?from=&to=
+ */
+const getPlotObservations = asyncHandler(async (req, res) => {
+  const { plotId } = req.params;
+  const { from = '', to = '' } = req.query;
+  const observations = await weatherService.getPlotObservations(req.role, plotId, from, to);
+
+  res.json({
+    success: true,
+    data: observations,
+    count: observations.length,
+    plotId,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Get a weather observation
+ * GET /api/weather/:dataHash
+ */
+const getObservation = asyncHandler(async (req, res) => {
+  const { dataHash } = req.params;
+  const observation = await weatherService.getObservation(req.role, dataHash);
+
+  res.json({
+    success: true,
+    data: observation,
+    dataHash,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+/**
+ * Check raw feed data against an anchored observation
+ * POST /api/weather/:dataHash/verify
+ */
+const verifyWeatherData = asyncHandler(async (req, res) => {
+  const { dataHash } = req.params;
+  const matches = await weatherService.verifyWeatherData(req.role, dataHash, req.body.data);
+
+  res.json({
+    success: true,
+    data: { matches },
+    dataHash,
+    role: req.role,
+    timestamp: new Date().toISOString()
+  });
+});
+
+module.exports = {
+  anchorWeatherData,
+  getPlotObservations,
+  getObservation,
+  verifyWeatherData
+};
@@ -3,7 +3,66 @@ const grpc = require('@grpc/grpc-js');
 const fs = require('node:fs/promises');
 const crypto = require('node:crypto');
 const path = require('node:path');
-const { fabric, getRoleConfig, errorCodes } = require('../../config');
+const { fabric, getRoleConfig, getChannelConfig, getIdentityConfig, errorCodes } = require('../../config');
+const metricsService = require('../services/MetricsService');
+const { withSpan } = require('../telemetry/tracing');
+const { isSimulated } = require('./simulationContext');
+const { currentChannel } = require('./channelContext');
+const { currentIdentity } = require('./identityContext');
+
+// Transaction validation codes assigned by committing peers (peer.TxValidationCode)
+const VALIDATION_CODES = {
+  0: 'VALID',
+  1: 'NIL_ENVELOPE',
+  2: 'BAD_PAYLOAD',
+  3: 'BAD_COMMON_HEADER',
+  4: 'BAD_CREATOR_SIGNATURE',
+  5: 'INVALID_ENDORSER_TRANSACTION',
+  6: 'INVALID_CONFIG_TRANSACTION',
+  7: 'UNSUPPORTED_TX_PAYLOAD',
+  8: 'BAD_PROPOSAL_TXID',
+  9: 'DUPLICATE_TXID',
+  10: 'ENDORSEMENT_POLICY_FAILURE',
+  11: 'MVCC_READ_CONFLICT',
+  12: 'PHANTOM_READ_CONFLICT',
+  13: 'UNKNOWN_TX_TYPE',
+  14: 'TARGET_CHAIN_NOT_FOUND',
+  15: 'MARSHAL_TX_ERROR',
+  16: 'NIL_TXACTION',
+  17: 'EXPIRED_CHAINCODE',
+  18: 'CHAINCODE_VERSION_CONFLICT',
+  19: 'BAD_HEADER_EXTENSION',
+  20: 'BAD_CHANNEL_HEADER',
+  21: 'BAD_RESPONSE_PAYLOAD',
+  22: 'BAD_RWSET',
+  23: 'ILLEGAL_WRITESET',
+  24: 'INVALID_WRITESET',
+  25: 'INVALID_CHAINCODE',
+  254: 'NOT_VALIDATED',
+  255: 'INVALID_OTHER_REASON'
+};
+
+// Stages of an offline-signed transaction, each signed by the client: the proposal sent for endorsement, the endorsed
+// transaction sent to the orderer, and the request for its commit status
+const OFFLINE_STAGES = ['proposal', 'transaction', 'commit'];
+
+// Invalidated because another transaction changed the keys read; a fresh endorsement can succeed
+const RETRYABLE_VALIDATION_CODES = new Set(['MVCC_READ_CONFLICT', 'PHANTOM_READ_CONFLICT']);
+
+function validationCodeName(code) {
+  return VALIDATION_CODES[code] || 'UNKNOWN';
+}
+
+/**
+ * Cache key of an identity: the role for its default identity, role/name for an identity of the registry
+ */
+function identityKey(role, identity) {
+  return identity ? `${role}/${identity}` : role;
+}
+
+function sleep(ms) {
+  return new Promise(resolve => setTimeout(resolve, ms));
+}
 
 /**
  * Fabric DAO layer
@@ -11,51 +70,107 @@ const { fabric, getRoleConfig, errorCodes } = require('../../config');
  */
 class FabricDAO {
   constructor() {
-    this.connections = new Map(); // Cache connections to avoid duplicate creation
+    this.clients = new Map(); // gRPC client per peer endpoint, shared by all identities of the organization
+    this.gateways = new Map(); // Gateway connection per identity, shared by all channels
+    this.hsmSigners = new Map(); // Close functions of the HSM signers per identity
+    this.hsmSignerFactory = null; // PKCS#11 signer factory, loaded on first use
+    this.connections = new Map(); // Cache contracts per identity and channel to avoid duplicate creation
+    this.networks = new Map(); // Networks of the cached contracts, used for event listening
+    this.evaluateOnly = new Map(); // Evaluate-only transactions of the chaincode per channel, from its metadata
   }
 
   /**
    * Get contract instance for a specific role
    * @param {string} role - Role name (farmer, processor, consumer)
+   * @param {string} [channel] - Channel name (default: channel of the current request)
+   * @param {string} [identity] - Identity name from the registry (default: identity of the current request)
    * @returns {Promise<Contract>} Fabric contract instance
    */
-  async getContract(role) {
+  async getContract(role, channel = currentChannel(), identity = currentIdentity()) {
+    const connectionKey = `${identityKey(role, identity)}@${channel}`;
+    const identityConfig = getIdentityConfig(role, identity);
+    if (identityConfig && identityConfig.offline) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Identity ${identity} signs offline; submit its transactions through /api/offline-transactions`);
+    }
     try {
       // Check if there is a cached connection
-      if (this.connections.has(role)) {
-        return this.connections.get(role);
+      if (this.connections.has(connectionKey)) {
+        return this.connections.get(connectionKey);
       }
 
-      const roleConfig = getRoleConfig(role);
-      const contract = await this._createContract(roleConfig);
-      
+      const channelConfig = getChannelConfig(channel);
+      const gateway = await this._getGateway(role, identity);
+      const network = gateway.getNetwork(channel);
+      const contract = network.getContract(channelConfig.chaincodeName);
+
       // Cache connection
-      this.connections.set(role, contract);
-      
-      console.log(`Fabric contract created for role: ${role}`);
+      this.networks.set(connectionKey, network);
+      this.connections.set(connectionKey, contract);
+
+      console.log(`Fabric contract created for ${identityKey(role, identity)} on channel: ${channel}`);
       return contract;
     } catch (error) {
-      console.error(`Failed to create contract for role ${role}:`, error.message);
+      console.error(`Failed to create contract for ${identityKey(role, identity)} on channel ${channel}:`, error.message);
       throw new Error(`${errorCodes.FABRIC_ERROR}: Failed to connect to Fabric network: ${error.message}`);
     }
   }
 
   /**
-   * Create Fabric contract connection
+   * Get network instance for a specific role
+   * @param {string} role - Role name (farmer, processor, consumer)
+   * @param {string} [channel] - Channel name (default: channel of the current request)
+   * @param {string} [identity] - Identity name from the registry (default: identity of the current request)
+   * @returns {Promise<Network>} Fabric network instance
+   */
+  async getNetwork(role, channel = currentChannel(), identity = currentIdentity()) {
+    const connectionKey = `${identityKey(role, identity)}@${channel}`;
+    if (!this.networks.has(connectionKey)) {
+      await this.getContract(role, channel, identity);
+    }
+    return this.networks.get(connectionKey);
+  }
+
+  /**
+   * Get the gateway connection of an identity, creating it on first use
    * @private
    */
-  async _createContract(roleConfig) {
-    const { paths, mspId, peerEndpoint, peerHostAlias } = roleConfig;
+  async _getGateway(role, identity) {
+    const key = identityKey(role, identity);
+    if (!this.gateways.has(key)) {
+      this.gateways.set(key, await this._createGateway(getRoleConfig(role), getIdentityConfig(role, identity)));
+    }
+    return this.gateways.get(key);
+  }
+
+  /**
+   * Create Fabric gateway connection
+   * Without an identity config, the gateway acts as the User1 identity of the role's organization. An offline
+   * identity has no signer: its proposals, transactions and commit status requests are signed by the client
+   * @private
+   */
+  async _createGateway(roleConfig, identityConfig) {
+    const { paths, mspId } = roleConfig;
+
+    // gRPC client of the organization's peer
+    const client = await this._getGrpcClient(roleConfig);
 
-    // Create gRPC client
-    const client = await this._createGrpcClient(paths.tlsCertPath, peerEndpoint, peerHostAlias);
-    
     // Create identity and signer
-    const identity = await this._createIdentity(mspId, paths.certDirectoryPath);
-    const signer = await this._createSigner(paths.keyDirectoryPath);
+    let identity;
+    let signer;
+    if (!identityConfig) {
+      identity = await this._createIdentity(mspId, paths.certDirectoryPath);
+      signer = await this._createSigner(paths.keyDirectoryPath);
+    } else {
+      identity = { mspId: identityConfig.mspId || mspId, credentials: await fs.readFile(identityConfig.certPath) };
+      if (identityConfig.hsm) {
+        signer = this._createHsmSigner(identityConfig);
+      } else if (!identityConfig.offline) {
+        signer = signers.newPrivateKeySigner(crypto.createPrivateKey(await fs.readFile(identityConfig.keyPath)));
+      }
+    }
 
     // Establish gateway connection
-    const gateway = connect({
+    return connect({
       client,
       identity,
       signer,
@@ -65,9 +180,18 @@ class FabricDAO {
       submitOptions: () => ({ deadline: Date.now() + fabric.timeouts.submit }),
       commitStatusOptions: () => ({ deadline: Date.now() + fabric.timeouts.commitStatus }),
     });
+  }
 
-    const network = gateway.getNetwork(fabric.channelName);
-    return network.getContract(fabric.chaincodeName);
+  /**
+   * Get the gRPC client of an organization's peer, creating it on first use
+   * @private
+   */
+  async _getGrpcClient(roleConfig) {
+    const { paths, peerEndpoint, peerHostAlias } = roleConfig;
+    if (!this.clients.has(peerEndpoint)) {
+      this.clients.set(peerEndpoint, await this._createGrpcClient(paths.tlsCertPath, peerEndpoint, peerHostAlias));
+    }
+    return this.clients.get(peerEndpoint);
   }
 
   /**
@@ -104,6 +228,25 @@ class FabricDAO {
     return signers.newPrivateKeySigner(privateKey);
   }
 
+  /**
+   * Create a signer for a key held in a PKCS#11 HSM
+   * The PIN is read from the environment variable named by pinEnv, so it does not have to be kept in the registry
+   * @private
+   */
+  _createHsmSigner(identityConfig) {
+    const { label, pin, pinEnv, identifier } = identityConfig.hsm;
+    if (!this.hsmSignerFactory) {
+      this.hsmSignerFactory = signers.newHSMSignerFactory(fabric.hsmLibrary);
+    }
+    const { signer, close } = this.hsmSignerFactory.newSigner({
+      label,
+      pin: pinEnv ? process.env[pinEnv] : pin,
+      identifier
+    });
+    this.hsmSigners.set(identityConfig.name, close);
+    return signer;
+  }
+
   /**
    * Get the first file in the directory
    * @private
@@ -128,14 +271,21 @@ class FabricDAO {
    * @returns {Promise<any>} Query result
    */
   async evaluateTransaction(role, method, ...args) {
+    const stopTimer = metricsService.fabricTransactionDuration.startTimer({ phase: 'evaluate', method });
     try {
       const contract = await this.getContract(role);
-      const resultBytes = await contract.evaluateTransaction(method, ...args);
+      const resultBytes = await withSpan('fabric.evaluate', { 'fabric.method': method, 'ricetrace.role': role }, () =>
+        contract.evaluateTransaction(method, ...args)
+      );
       const resultJson = new TextDecoder().decode(resultBytes);
+      metricsService.fabricTransactionsTotal.inc({ type: 'evaluate', method, outcome: 'success' });
       return JSON.parse(resultJson);
     } catch (error) {
+      metricsService.fabricTransactionsTotal.inc({ type: 'evaluate', method, outcome: 'error' });
       console.error(`❌ Evaluate transaction failed [${method}]:`, error.message);
       throw new Error(`${errorCodes.FABRIC_ERROR}: ${error.message}`);
+    } finally {
+      stopTimer();
     }
   }
 
@@ -147,58 +297,331 @@ class FabricDAO {
    * @returns {Promise<any>} Submit result
    */
   async submitTransaction(role, method, ...args) {
+    if (isSimulated()) {
+      return this.simulateTransaction(role, method, { arguments: args });
+    }
+    if (await this._isEvaluateOnly(role, method)) {
+      return this._evaluateRaw(role, method, { arguments: args });
+    }
+    return this._submitWithRetry(role, method, { arguments: args });
+  }
+
+  /**
+   * Whether the chaincode marks a transaction as evaluate-only (@Transaction(false))
+   * The tags are read once per channel from the contract metadata. Bare method names belong to the default
+   * contract, the first one the chaincode registers. If the metadata cannot be read, transactions are submitted
+   * as requested
+   * @private
+   * @returns {Promise<boolean>} Whether the transaction does not write and need not be ordered
+   */
+  async _isEvaluateOnly(role, method) {
+    const channel = currentChannel();
+    if (!this.evaluateOnly.has(channel)) {
+      this.evaluateOnly.set(channel, this._loadEvaluateOnlyTransactions(role).catch(error => {
+        console.warn(`Could not read the chaincode metadata on channel ${channel}, submitting every transaction:`, error.message);
+        this.evaluateOnly.delete(channel);
+        return new Set();
+      }));
+    }
+    return (await this.evaluateOnly.get(channel)).has(method);
+  }
+
+  /**
+   * Read the names of the evaluate-only transactions from the chaincode metadata
+   * @private
+   * @returns {Promise<Set<string>>} Contract:Function names, and bare names for the default contract
+   */
+  async _loadEvaluateOnlyTransactions(role) {
+    const contract = await this.getContract(role);
+    const metadata = JSON.parse(new TextDecoder().decode(await contract.evaluateTransaction('org.hyperledger.fabric:GetMetadata')));
+    const contractNames = Object.keys(metadata.contracts || {}).filter(name => !name.startsWith('org.hyperledger.fabric'));
+    const evaluateOnly = new Set();
+    for (const contractName of contractNames) {
+      for (const { name, tag = [] } of metadata.contracts[contractName].transactions || []) {
+        if (tag.includes('submitTx') || tag.includes('SUBMIT')) {
+          continue;
+        }
+        evaluateOnly.add(`${contractName}:${name}`);
+        if (contractName === contractNames[0]) {
+          evaluateOnly.add(name);
+        }
+      }
+    }
+    console.log(`${evaluateOnly.size} evaluate-only transaction names read from the chaincode metadata`);
+    return evaluateOnly;
+  }
+
+  /**
+   * Evaluate a transaction the chaincode marks as evaluate-only instead of submitting it
+   * Returns the raw result like a submit, so callers need not know how the transaction was routed
+   * @private
+   * @returns {Promise<Uint8Array>} Transaction result
+   */
+  async _evaluateRaw(role, method, options) {
+    const stopTimer = metricsService.fabricTransactionDuration.startTimer({ phase: 'evaluate', method });
     try {
       const contract = await this.getContract(role);
-      const result = await contract.submitTransaction(method, ...args);
-      console.log(`Transaction submitted successfully: ${method}`);
+      const result = await withSpan('fabric.evaluate', { 'fabric.method': method, 'ricetrace.role': role }, () =>
+        contract.newProposal(method, options).evaluate()
+      );
+      metricsService.fabricTransactionsTotal.inc({ type: 'evaluate', method, outcome: 'success' });
       return result;
     } catch (error) {
-      console.error(`Submit transaction failed [${method}]:`, error.message);
+      metricsService.fabricTransactionsTotal.inc({ type: 'evaluate', method, outcome: 'error' });
+      console.error(`❌ Evaluate transaction failed [${method}]:`, error.message);
       throw new Error(`${errorCodes.FABRIC_ERROR}: ${error.message}`);
+    } finally {
+      stopTimer();
     }
   }
 
   /**
-   * Asynchronous submit operation
+   * Endorse, submit and wait for the commit status of a transaction
+   * Transactions invalidated by a read conflict are endorsed again with a new transaction ID, up to
+   * fabric.retry.maxAttempts times. Other outcomes are surfaced with a distinct error code:
+   * TRANSACTION_INVALID with the validation code when the transaction was committed as invalid, and
+   * FABRIC_TIMEOUT when a deadline expired (after submission the transaction may still commit).
+   * @private
+   * @returns {Promise<Uint8Array>} Transaction result
+   */
+  async _submitWithRetry(role, method, options) {
+    const { maxAttempts, initialDelay, maxDelay } = fabric.retry;
+    try {
+      const contract = await this.getContract(role);
+
+      for (let attempt = 1; ; attempt++) {
+        const { result, status } = await this._endorseAndCommit(contract, role, method, options);
+        if (status.successful) {
+          metricsService.fabricTransactionsTotal.inc({ type: 'submit', method, outcome: 'success' });
+          console.log(`Transaction submitted successfully: ${method}`);
+          return result;
+        }
+
+        const codeName = validationCodeName(status.code);
+        if (RETRYABLE_VALIDATION_CODES.has(codeName) && attempt < maxAttempts) {
+          metricsService.fabricTransactionsTotal.inc({ type: 'submit', method, outcome: 'retry' });
+          const delay = Math.min(initialDelay * 2 ** (attempt - 1), maxDelay);
+          console.warn(`Transaction ${status.transactionId} [${method}] invalidated by ${codeName}, retrying in ${delay}ms (attempt ${attempt}/${maxAttempts})`);
+          await sleep(delay);
+          continue;
+        }
+
+        throw new Error(`${errorCodes.TRANSACTION_INVALID}: Transaction ${status.transactionId} was committed as invalid with validation code ${codeName} (${status.code}) after ${attempt} attempt(s)`);
+      }
+    } catch (error) {
+      metricsService.fabricTransactionsTotal.inc({ type: 'submit', method, outcome: 'error' });
+      console.error(`Submit transaction failed [${method}]:`, error.message);
+      throw new Error(this._describeSubmitError(error));
+    }
+  }
+
+  /**
+   * Run one endorse / submit / commit status cycle
+   * Endorse and commit are run as separate steps so each phase is timed and traced
+   * @private
+   * @returns {Promise<{result: Uint8Array, status: Object}>} Transaction result and commit status
+   */
+  async _endorseAndCommit(contract, role, method, options) {
+    return withSpan('fabric.submit', { 'fabric.method': method, 'ricetrace.role': role }, async (span) => {
+      const proposal = contract.newProposal(method, options);
+
+      const stopEndorseTimer = metricsService.fabricTransactionDuration.startTimer({ phase: 'endorse', method });
+      const transaction = await withSpan('fabric.endorse', { 'fabric.method': method }, () => proposal.endorse())
+        .finally(() => stopEndorseTimer());
+      if (span) {
+        span.setAttribute('fabric.transaction_id', transaction.getTransactionId());
+      }
+
+      const stopCommitTimer = metricsService.fabricTransactionDuration.startTimer({ phase: 'commit', method });
+      const status = await withSpan('fabric.commit', { 'fabric.method': method }, async () => {
+        const commit = await transaction.submit();
+        return commit.getStatus();
+      }).finally(() => stopCommitTimer());
+      if (span) {
+        span.setAttribute('fabric.validation_code', validationCodeName(status.code));
+      }
+
+      return { result: transaction.getResult(), status };
+    });
+  }
+
+  /**
+   * Build the error message for a failed submission
+   * Chaincode errors are reported by the peers in the error details, so they are appended to the message
+   * @private
+   */
+  _describeSubmitError(error) {
+    const message = error.message || 'Unknown error';
+    if ([errorCodes.TRANSACTION_INVALID, errorCodes.FABRIC_ERROR].some(code => message.startsWith(`${code}:`))) {
+      return message;
+    }
+
+    if (error.code === grpc.status.DEADLINE_EXCEEDED) {
+      // CommitStatusError: the transaction was submitted, so its outcome is unknown rather than failed
+      if (error.name === 'CommitStatusError') {
+        return `${errorCodes.FABRIC_TIMEOUT}: Timed out waiting for the commit status of transaction ${error.transactionId}; it may still be committed`;
+      }
+      return `${errorCodes.FABRIC_TIMEOUT}: Transaction ${error.transactionId} timed out before it was submitted and was not committed`;
+    }
+
+    const details = (error.details || []).map(detail => detail.message).filter(Boolean);
+    return `${errorCodes.FABRIC_ERROR}: ${[message, ...details].join(': ')}`;
+  }
+
+  /**
+   * Evaluate a write transaction without submitting it (dry run)
+   * The chaincode runs on a single peer, so validation errors and the projected result are returned,
+   * but nothing is endorsed or committed
    * @param {string} role - Role
    * @param {string} method - Contract method name
-   * @param {Object} options - Submit options
-   * @returns {Promise<any>} Submit result
+   * @param {Object} options - Proposal options (arguments, transientData)
+   * @returns {Promise<Uint8Array>} Transaction result
    */
-  async submitAsyncTransaction(role, method, options = {}) {
+  async simulateTransaction(role, method, options = {}) {
     try {
       const contract = await this.getContract(role);
-      const commit = await contract.submitAsync(method, options);
-      const result = new TextDecoder().decode(commit.getResult());
-      
-      // Wait for transaction confirmation
-      const status = await commit.getStatus();
-      if (!status.successful) {
-        throw new Error(`Transaction ${status.transactionId} failed to commit`);
-      }
-      
-      console.log(`Async transaction committed successfully: ${method}`);
+      const result = await withSpan('fabric.simulate', { 'fabric.method': method, 'ricetrace.role': role }, () =>
+        contract.newProposal(method, options).evaluate()
+      );
+      metricsService.fabricTransactionsTotal.inc({ type: 'simulate', method, outcome: 'success' });
+      console.log(`Transaction simulated successfully: ${method}`);
       return result;
     } catch (error) {
-      console.error(`Async submit transaction failed [${method}]:`, error.message);
+      metricsService.fabricTransactionsTotal.inc({ type: 'simulate', method, outcome: 'error' });
+      console.error(`Simulate transaction failed [${method}]:`, error.message);
+      throw new Error(`${errorCodes.FABRIC_ERROR}: ${error.message}`);
+    }
+  }
+
+  /**
+   * Submit operation with proposal options (e.g. transient data)
+   * @param {string} role - Role
+   * @param {string} method - Contract method name
+   * @param {Object} options - Submit options (arguments, transientData)
+   * @returns {Promise<string>} Submit result
+   */
+  async submitAsyncTransaction(role, method, options = {}) {
+    if (isSimulated()) {
+      return new TextDecoder().decode(await this.simulateTransaction(role, method, options));
+    }
+    if (await this._isEvaluateOnly(role, method)) {
+      return new TextDecoder().decode(await this._evaluateRaw(role, method, options));
+    }
+
+    return new TextDecoder().decode(await this._submitWithRetry(role, method, options));
+  }
+
+  /**
+   * Build the unsigned proposal of a transaction for an offline identity of the current request
+   * @param {string} role - Role
+   * @param {string} method - Contract method name
+   * @param {Object} options - Proposal options (arguments, transientData)
+   * @returns {Promise<Object>} { transactionId, stage: 'proposal', bytes, digest } - the client signs the digest
+   */
+  async prepareOfflineTransaction(role, method, options = {}) {
+    const identity = currentIdentity();
+    const identityConfig = getIdentityConfig(role, identity);
+    if (!identityConfig || !identityConfig.offline) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Offline signing needs an identity of the registry marked offline (X-Fabric-Identity)`);
+    }
+
+    try {
+      const gateway = await this._getGateway(role, identity);
+      const contract = gateway.getNetwork(currentChannel()).getContract(getChannelConfig(currentChannel()).chaincodeName);
+      const proposal = contract.newProposal(method, options);
+      metricsService.fabricTransactionsTotal.inc({ type: 'offline', method, outcome: 'prepared' });
+      return { transactionId: proposal.getTransactionId(), stage: 'proposal', bytes: proposal.getBytes(), digest: proposal.getDigest() };
+    } catch (error) {
+      console.error(`Prepare offline transaction failed [${method}]:`, error.message);
       throw new Error(`${errorCodes.FABRIC_ERROR}: ${error.message}`);
     }
   }
 
+  /**
+   * Take the client's signature of one stage of an offline-signed transaction and run that stage
+   * A signed proposal is endorsed, a signed transaction is submitted to the orderer and a signed commit status
+   * request waits for the commit. Each stage but the last returns the bytes and digest of the next one
+   * @param {string} role - Role
+   * @param {string} stage - proposal, transaction or commit
+   * @param {Uint8Array} bytes - Bytes of the stage, as returned by the previous step
+   * @param {Uint8Array} signature - Client signature of the digest of the bytes
+   * @returns {Promise<Object>} { transactionId, stage, bytes?, digest?, result?, status? } of the next stage
+   */
+  async signOfflineTransaction(role, stage, bytes, signature) {
+    if (!OFFLINE_STAGES.includes(stage)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: stage must be one of ${OFFLINE_STAGES.join(', ')}`);
+    }
+
+    try {
+      const gateway = await this._getGateway(role, currentIdentity());
+      return await withSpan(`fabric.offline.${stage}`, { 'ricetrace.role': role }, async () => {
+        if (stage === 'proposal') {
+          const transaction = await gateway.newSignedProposal(bytes, signature).endorse();
+          return {
+            transactionId: transaction.getTransactionId(),
+            stage: 'transaction',
+            bytes: transaction.getBytes(),
+            digest: transaction.getDigest(),
+            result: transaction.getResult()
+          };
+        }
+        if (stage === 'transaction') {
+          const transaction = gateway.newSignedTransaction(bytes, signature);
+          const commit = await transaction.submit();
+          return {
+            transactionId: commit.getTransactionId(),
+            stage: 'commit',
+            bytes: commit.getBytes(),
+            digest: commit.getDigest(),
+            result: transaction.getResult()
+          };
+        }
+        const commit = gateway.newSignedCommit(bytes, signature);
+        const status = await commit.getStatus();
+        const validationCode = validationCodeName(status.code);
+        metricsService.fabricTransactionsTotal.inc({ type: 'offline', method: 'commit', outcome: status.successful ? 'success' : 'invalid' });
+        return {
+          transactionId: status.transactionId,
+          stage: 'committed',
+          status: { successful: status.successful, validationCode, blockNumber: String(status.blockNumber) }
+        };
+      });
+    } catch (error) {
+      metricsService.fabricTransactionsTotal.inc({ type: 'offline', method: stage, outcome: 'error' });
+      console.error(`Offline ${stage} failed:`, error.message);
+      throw new Error(this._describeSubmitError(error));
+    }
+  }
+
   /**
    * Clean up all connections
    */
   async cleanup() {
     console.log('Cleaning up Fabric connections...');
-    for (const [role, contract] of this.connections) {
+    for (const [key, gateway] of this.gateways) {
       try {
-        // Here you can add specific cleanup logic, such as closing the gateway
-        console.log(`Connection for ${role} cleaned up`);
+        gateway.close();
+        console.log(`Connection for ${key} cleaned up`);
       } catch (error) {
-        console.error(`Error cleaning up connection for ${role}:`, error.message);
+        console.error(`Error cleaning up connection for ${key}:`, error.message);
       }
     }
+    for (const close of this.hsmSigners.values()) {
+      close();
+    }
+    if (this.hsmSignerFactory) {
+      this.hsmSignerFactory.dispose();
+      this.hsmSignerFactory = null;
+    }
+    for (const client of this.clients.values()) {
+      client.close();
+    }
+    this.gateways.clear();
+    this.hsmSigners.clear();
+    this.clients.clear();
     this.connections.clear();
+    this.networks.clear();
+    this.evaluateOnly.clear();
   }
 
   /**
@@ -206,8 +629,10 @@ class FabricDAO {
    */
   getConnectionStatus() {
     return {
-      totalConnections: this.connections.size,
-      activeRoles: Array.from(this.connections.keys())
+      totalConnections: this.gateways.size,
+      grpcClients: this.clients.size,
+      activeRoles: Array.from(this.gateways.keys()), // role or role/identity
+      activeContracts: Array.from(this.connections.keys()) // role[/identity]@channel
     };
   }
 }
@@ -0,0 +1,34 @@
+const { AsyncLocalStorage } = require('node:async_hooks');
+const { fabric } = require('../../config');
+
+/**
+ * Channel context
+ * Code running inside runInChannel() sends its transactions to the given channel of the registry instead of
+ * the default one. Like the dry-run flag, the channel follows the async call chain, so services need no
+ * extra parameter.
+ */
+
+const channelStorage = new AsyncLocalStorage();
+
+/**
+ * Run a function against a channel
+ * @param {string} channel - Channel name from the registry
+ * @param {Function} fn - Function to run
+ * @returns {any} Function result
+ */
+function runInChannel(channel, fn) {
+  return channelStorage.run(channel, fn);
+}
+
+/**
+ * Channel of the current call chain
+ * @returns {string} Channel name
+ */
+function currentChannel() {
+  return channelStorage.getStore() || fabric.channelName;
+}
+
+module.exports = {
+  runInChannel,
+  currentChannel
+};
@@ -0,0 +1,33 @@
+const { AsyncLocalStorage } = require('node:async_hooks');
+
+/**
+ * Identity context
+ * Code running inside runAsIdentity() signs its transactions with the given identity of the identity registry
+ * instead of the default identity of the role. Like the channel, the identity follows the async call chain, so
+ * services need no extra parameter.
+ */
+
+const identityStorage = new AsyncLocalStorage();
+
+/**
+ * Run a function as an identity
+ * @param {string} identity - Identity name from the registry
+ * @param {Function} fn - Function to run
+ * @returns {any} Function result
+ */
+function runAsIdentity(identity, fn) {
+  return identityStorage.run(identity, fn);
+}
+
+/**
+ * Identity of the current call chain
+ * @returns {string} Identity name, or an empty string for the default identity of the role
+ */
+function currentIdentity() {
+  return identityStorage.getStore() || '';
+}
+
+module.exports = {
+  runAsIdentity,
+  currentIdentity
+};
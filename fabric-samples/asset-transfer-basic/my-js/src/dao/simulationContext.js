@@ -0,0 +1,32 @@
+const { AsyncLocalStorage } = require('node:async_hooks');
+
+/**
+ * Dry-run context
+ * Code running inside runSimulated() has its write transactions evaluated instead of submitted: the chaincode
+ * executes on a peer and returns its result or validation error, but nothing is endorsed or committed.
+ * The flag follows the async call chain, so services need no extra parameter.
+ */
+
+const simulationStorage = new AsyncLocalStorage();
+
+/**
+ * Run a function with write transactions evaluated only
+ * @param {Function} fn - Function to run
+ * @returns {any} Function result
+ */
+function runSimulated(fn) {
+  return simulationStorage.run(true, fn);
+}
+
+/**
+ * Whether the current call chain is a dry run
+ * @returns {boolean}
+ */
+function isSimulated() {
+  return simulationStorage.getStore() === true;
+}
+
+module.exports = {
+  runSimulated,
+  isSimulated
+};
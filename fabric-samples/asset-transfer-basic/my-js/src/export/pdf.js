@@ -0,0 +1,345 @@
+const crypto = require('node:crypto');
+const zlib = require('node:zlib');
+const { encodeQr } = require('./qrcode');
+
+/**
+ * PDF writer for certificates
+ * A document is a list of blocks laid out top to bottom on A4 pages: { type: 'title' | 'heading' | 'text', text },
+ * { type: 'fields', rows: [[label, value]] }, { type: 'table', columns: [{ header, width }], rows: [[cell]] } and
+ * { type: 'qr', text, caption: [line] }. Latin text is set in Helvetica and Chinese in STSong-Light, both standard
+ * fonts of PDF readers, so no font files are embedded. The output only depends on its input, so the same document
+ * always hashes the same.
+ */
+
+const PAGE = { width: 595.28, height: 841.89, margin: 50 };
+const FOOTER_HEIGHT = 36;
+const LINE_SPACING = 1.35;
+const FIELD_LABEL_WIDTH = 150;
+
+// Helvetica advance widths of the printable ASCII characters (32-126), in 1/1000 em
+const HELVETICA_WIDTHS = [
+  278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+  556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+  1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+  667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+  333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+  556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584
+];
+// Helvetica-Bold is about this much wider; close enough for line breaking
+const BOLD_FACTOR = 1.08;
+
+/**
+ * Whether a character is set in the CJK font (anything outside Latin-1)
+ * @private
+ */
+function isWide(char) {
+  return char.charCodeAt(0) > 0xff;
+}
+
+function charWidth(char, size, bold) {
+  if (isWide(char)) {
+    return size;
+  }
+  const code = char.charCodeAt(0);
+  const width = code >= 32 && code <= 126 ? HELVETICA_WIDTHS[code - 32] : 556;
+  return width / 1000 * size * (bold ? BOLD_FACTOR : 1);
+}
+
+function textWidth(text, size, bold) {
+  return [...text].reduce((total, char) => total + charWidth(char, size, bold), 0);
+}
+
+/**
+ * Break text into lines no wider than the given width: at spaces, between CJK characters, or inside words that
+ * are too long for a line
+ * @private
+ */
+function wrapText(text, size, bold, width) {
+  const lines = [];
+  for (const paragraph of String(text === undefined || text === null ? '' : text).split('\n')) {
+    const tokens = paragraph.match(/\s+|[^\u0000-\u00ff]|[^\s\u0100-\uffff]+/g) || [''];
+    let line = '';
+    for (const token of tokens) {
+      if (textWidth(line + token, size, bold) <= width) {
+        line += token;
+        continue;
+      }
+      if (line.trim()) {
+        lines.push(line.trimEnd());
+      }
+      line = /^\s+$/.test(token) ? '' : token;
+      while (textWidth(line, size, bold) > width) {
+        let fit = 1;
+        while (fit < line.length && textWidth(line.slice(0, fit + 1), size, bold) <= width) {
+          fit++;
+        }
+        lines.push(line.slice(0, fit));
+        line = line.slice(fit);
+      }
+    }
+    lines.push(line.trimEnd());
+  }
+  return lines;
+}
+
+/**
+ * Text drawing operators, switching between the Latin and the CJK font for each run of characters
+ * @private
+ */
+function textOperators(text, x, y, size, bold) {
+  const operators = [];
+  let cursor = x;
+  for (const run of text.match(/[\u0000-\u00ff]+|[^\u0000-\u00ff]+/g) || []) {
+    const wide = isWide(run);
+    // Characters outside the Basic Multilingual Plane have no glyph in the standard CJK font
+    const chars = [...run].map(char => (char.length > 1 ? '?' : char)).join('');
+    const encoded = wide
+      ? `<${Buffer.from(chars, 'utf16le').swap16().toString('hex')}>`
+      : `(${chars.replace(/[\\()]/g, match => `\\${match}`).replace(/[\u0000-\u001f]/g, ' ')})`;
+    const font = wide ? 'F3' : (bold ? 'F2' : 'F1');
+    operators.push(`BT /${font} ${size} Tf ${cursor.toFixed(2)} ${y.toFixed(2)} Td ${wide ? encoded : toLatin1Literal(encoded)} Tj ET`);
+    cursor += textWidth(chars, size, bold);
+  }
+  return operators;
+}
+
+/**
+ * Latin-1 characters of a literal string as octal escapes, keeping the content stream ASCII
+ * @private
+ */
+function toLatin1Literal(literal) {
+  return literal.replace(/[\u0080-\u00ff]/g, char => `\\${char.charCodeAt(0).toString(8).padStart(3, '0')}`);
+}
+
+function pdfString(text) {
+  return `<FEFF${Buffer.from(String(text), 'utf16le').swap16().toString('hex').toUpperCase()}>`;
+}
+
+function pdfDate(isoTimestamp) {
+  const date = new Date(isoTimestamp);
+  const pad = (value) => String(value).padStart(2, '0');
+  return `D:${date.getUTCFullYear()}${pad(date.getUTCMonth() + 1)}${pad(date.getUTCDate())}` +
+    `${pad(date.getUTCHours())}${pad(date.getUTCMinutes())}${pad(date.getUTCSeconds())}Z`;
+}
+
+/**
+ * Lays blocks out on pages; each page collects its content stream operators
+ * @private
+ */
+class Layout {
+  constructor() {
+    this.pages = [];
+    this.newPage();
+  }
+
+  newPage() {
+    this.page = [];
+    this.pages.push(this.page);
+    this.y = PAGE.height - PAGE.margin;
+  }
+
+  ensureSpace(height) {
+    if (this.y - height < PAGE.margin + FOOTER_HEIGHT) {
+      this.newPage();
+    }
+  }
+
+  lines(lines, x, size, bold) {
+    const lineHeight = size * LINE_SPACING;
+    for (const line of lines) {
+      this.ensureSpace(lineHeight);
+      this.y -= lineHeight;
+      this.page.push(...textOperators(line, x, this.y + size * 0.25, size, bold));
+    }
+  }
+
+  rule(gap = 4) {
+    this.y -= gap;
+    this.page.push(`0.6 G 0.5 w ${PAGE.margin} ${this.y.toFixed(2)} m ${(PAGE.width - PAGE.margin).toFixed(2)} ${this.y.toFixed(2)} l S 0 G`);
+  }
+
+  title(text) {
+    this.lines(wrapText(text, 20, true, PAGE.width - PAGE.margin * 2), PAGE.margin, 20, true);
+    this.y -= 6;
+  }
+
+  heading(text) {
+    this.ensureSpace(40);
+    this.y -= 12;
+    this.lines(wrapText(text, 13, true, PAGE.width - PAGE.margin * 2), PAGE.margin, 13, true);
+    this.rule();
+    this.y -= 4;
+  }
+
+  text(text) {
+    this.lines(wrapText(text, 10, false, PAGE.width - PAGE.margin * 2), PAGE.margin, 10, false);
+  }
+
+  fields(rows) {
+    const valueX = PAGE.margin + FIELD_LABEL_WIDTH;
+    const valueWidth = PAGE.width - PAGE.margin - valueX;
+    for (const [label, value] of rows) {
+      const labelLines = wrapText(label, 10, true, FIELD_LABEL_WIDTH - 8);
+      const valueLines = wrapText(value, 10, false, valueWidth);
+      const height = Math.max(labelLines.length, valueLines.length) * 10 * LINE_SPACING;
+      this.ensureSpace(height);
+      const top = this.y;
+      this.lines(labelLines, PAGE.margin, 10, true);
+      const labelBottom = this.y;
+      this.y = top;
+      this.lines(valueLines, valueX, 10, false);
+      this.y = Math.min(this.y, labelBottom);
+    }
+  }
+
+  table(columns, rows) {
+    const totalWidth = PAGE.width - PAGE.margin * 2;
+    const weights = columns.reduce((total, column) => total + (column.width || 1), 0);
+    const widths = columns.map(column => totalWidth * (column.width || 1) / weights);
+    const row = (cells, bold, size) => {
+      const wrapped = cells.map((cell, index) => wrapText(cell, size, bold, widths[index] - 6));
+      const top = this.y;
+      let x = PAGE.margin;
+      let bottom = top;
+      wrapped.forEach((lines, index) => {
+        this.y = top;
+        this.lines(lines, x, size, bold);
+        bottom = Math.min(bottom, this.y);
+        x += widths[index];
+      });
+      this.y = bottom;
+    };
+
+    const header = columns.map(column => column.header);
+    this.ensureSpace(9 * LINE_SPACING * 3);
+    row(header, true, 9);
+    this.rule(2);
+    for (const cells of rows) {
+      const height = Math.max(...cells.map((cell, index) => wrapText(cell, 9, false, widths[index] - 6).length)) * 9 * LINE_SPACING;
+      if (this.y - height - 2 < PAGE.margin + FOOTER_HEIGHT) {
+        this.newPage();
+        row(header, true, 9);
+        this.rule(2);
+      }
+      this.y -= 2;
+      row(cells.map(cell => (cell === undefined || cell === null ? '' : String(cell))), false, 9);
+    }
+  }
+
+  qr(text, caption = []) {
+    const { size, modules } = encodeQr(text);
+    const extent = 110;
+    const quietZone = 4;
+    const moduleSize = extent / (size + quietZone * 2);
+    this.ensureSpace(extent + 12);
+    this.y -= 12;
+    const top = this.y;
+    const rectangles = [];
+    modules.forEach((row, my) => row.forEach((dark, mx) => {
+      if (dark) {
+        const x = PAGE.margin + (mx + quietZone) * moduleSize;
+        const y = top - (my + quietZone + 1) * moduleSize;
+        rectangles.push(`${x.toFixed(2)} ${y.toFixed(2)} ${moduleSize.toFixed(3)} ${moduleSize.toFixed(3)} re`);
+      }
+    }));
+    this.page.push(`0 g ${rectangles.join(' ')} f`);
+
+    const captionX = PAGE.margin + extent + 12;
+    this.y = top - 10;
+    for (const line of caption) {
+      this.lines(wrapText(line, 9, false, PAGE.width - PAGE.margin - captionX), captionX, 9, false);
+    }
+    this.y = Math.min(this.y, top - extent);
+  }
+
+  footer(text) {
+    this.pages.forEach((page, index) => {
+      const y = PAGE.margin;
+      page.push(...textOperators(text, PAGE.margin, y, 8, false));
+      const pageNumber = `${index + 1} / ${this.pages.length}`;
+      page.push(...textOperators(pageNumber, PAGE.width - PAGE.margin - textWidth(pageNumber, 8, false), y, 8, false));
+    });
+  }
+}
+
+/**
+ * Render a document as PDF
+ * @param {Object} document - { title, author, createdAt (ISO 8601), footer, blocks }
+ * @returns {Buffer} PDF file
+ */
+function toPdf({ title, author = '', createdAt, footer = '', blocks }) {
+  const layout = new Layout();
+  for (const block of blocks) {
+    switch (block.type) {
+      case 'title': layout.title(block.text); break;
+      case 'heading': layout.heading(block.text); break;
+      case 'text': layout.text(block.text); break;
+      case 'fields': layout.fields(block.rows); break;
+      case 'table': layout.table(block.columns, block.rows); break;
+      case 'qr': layout.qr(block.text, block.caption); break;
+      default: throw new Error(`Unknown PDF block type: ${block.type}`);
+    }
+  }
+  layout.footer(footer);
+
+  const objects = [];
+  const add = (body) => {
+    objects.push(body);
+    return objects.length;
+  };
+  const stream = (dictionary, data) => {
+    const compressed = zlib.deflateSync(Buffer.from(data, 'latin1'));
+    return Buffer.concat([
+      Buffer.from(`<< ${dictionary} /Filter /FlateDecode /Length ${compressed.length} >>\nstream\n`, 'latin1'),
+      compressed,
+      Buffer.from('\nendstream', 'latin1')
+    ]);
+  };
+
+  const catalog = add('<< /Type /Catalog /Pages 2 0 R >>');
+  const pagesRef = add(null);
+  const helvetica = add('<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>');
+  const helveticaBold = add('<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>');
+  const descriptor = add('<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] ' +
+    '/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>');
+  const cidFont = add('<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light ' +
+    `/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor ${descriptor} 0 R /DW 1000 >>`);
+  const cjk = add(`<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [${cidFont} 0 R] >>`);
+  const resources = `<< /Font << /F1 ${helvetica} 0 R /F2 ${helveticaBold} 0 R /F3 ${cjk} 0 R >> >>`;
+
+  const pageRefs = layout.pages.map(operators => {
+    const content = add(stream('', operators.join('\n')));
+    return add(`<< /Type /Page /Parent ${pagesRef} 0 R /MediaBox [0 0 ${PAGE.width} ${PAGE.height}] ` +
+      `/Resources ${resources} /Contents ${content} 0 R >>`);
+  });
+  objects[pagesRef - 1] = `<< /Type /Pages /Kids [${pageRefs.map(ref => `${ref} 0 R`).join(' ')}] /Count ${pageRefs.length} >>`;
+  const info = add(`<< /Title ${pdfString(title)} /Author ${pdfString(author)} /Producer (RiceTrace) ` +
+    `/CreationDate (${pdfDate(createdAt)}) /ModDate (${pdfDate(createdAt)}) >>`);
+
+  const parts = [Buffer.from('%PDF-1.4\n%\xe2\xe3\xcf\xd3\n', 'latin1')];
+  let offset = parts[0].length;
+  const offsets = objects.map((body, index) => {
+    const start = offset;
+    const content = Buffer.isBuffer(body) ? body : Buffer.from(body, 'latin1');
+    const part = Buffer.concat([Buffer.from(`${index + 1} 0 obj\n`, 'latin1'), content, Buffer.from('\nendobj\n', 'latin1')]);
+    parts.push(part);
+    offset += part.length;
+    return start;
+  });
+
+  // The file identifier is derived from the content, keeping the output deterministic
+  const id = crypto.createHash('md5').update(Buffer.concat(parts)).digest('hex');
+  const xref = [
+    'xref',
+    `0 ${objects.length + 1}`,
+    '0000000000 65535 f ',
+    ...offsets.map(start => `${String(start).padStart(10, '0')} 00000 n `)
+  ].join('\n');
+  parts.push(Buffer.from(`${xref}\ntrailer\n<< /Size ${objects.length + 1} /Root ${catalog} 0 R /Info ${info} 0 R ` +
+    `/ID [<${id}> <${id}>] >>\nstartxref\n${offset}\n%%EOF\n`, 'latin1'));
+  return Buffer.concat(parts);
+}
+
+module.exports = {
+  toPdf
+};
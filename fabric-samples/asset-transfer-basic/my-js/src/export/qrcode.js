@@ -0,0 +1,493 @@
+const zlib = require('node:zlib');
+const { crc32 } = require('./spreadsheet');
+
+/**
+ * QR code writers for package labels
+ * Encodes text in byte mode at error correction level M (15% of the code can be damaged or covered), versions
+ * 1 to 10 (up to 213 bytes, enough for a trace URL with a verification code), and renders it as PNG or SVG
+ * without external dependencies.
+ */
+
+// Error correction level M per version: [total codewords, EC codewords per block, [blocks, data codewords per block]...]
+const VERSIONS_M = [
+  null,
+  [26, 10, [1, 16]],
+  [44, 16, [1, 28]],
+  [70, 26, [1, 44]],
+  [100, 18, [2, 32]],
+  [134, 24, [2, 43]],
+  [172, 16, [4, 27]],
+  [196, 18, [4, 31]],
+  [242, 22, [2, 38], [2, 39]],
+  [292, 22, [3, 36], [2, 37]],
+  [346, 26, [4, 43], [1, 44]]
+];
+
+// Centers of the alignment patterns per version
+const ALIGNMENT_POSITIONS = [
+  null, [], [6, 18], [6, 22], [6, 26], [6, 30], [6, 34], [6, 22, 38], [6, 24, 42], [6, 26, 46], [6, 28, 50]
+];
+
+// Format information bits of error correction level M
+const EC_LEVEL_M_BITS = 0;
+
+// Modules of light border required around a code
+const QUIET_ZONE = 4;
+
+const MASKS = [
+  (x, y) => (x + y) % 2 === 0,
+  (x, y) => y % 2 === 0,
+  (x) => x % 3 === 0,
+  (x, y) => (x + y) % 3 === 0,
+  (x, y) => (Math.floor(x / 3) + Math.floor(y / 2)) % 2 === 0,
+  (x, y) => (x * y) % 2 + (x * y) % 3 === 0,
+  (x, y) => ((x * y) % 2 + (x * y) % 3) % 2 === 0,
+  (x, y) => ((x + y) % 2 + (x * y) % 3) % 2 === 0
+];
+
+function bitAt(value, index) {
+  return ((value >>> index) & 1) !== 0;
+}
+
+/**
+ * Multiplication in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+ * @private
+ */
+function gfMultiply(x, y) {
+  let product = 0;
+  for (let i = 7; i >= 0; i--) {
+    product = (product << 1) ^ ((product >>> 7) * 0x11D);
+    product ^= ((y >>> i) & 1) * x;
+  }
+  return product;
+}
+
+/**
+ * Reed-Solomon generator polynomial of a degree, highest coefficient omitted
+ * @private
+ */
+function reedSolomonDivisor(degree) {
+  const divisor = new Array(degree).fill(0);
+  divisor[degree - 1] = 1;
+  let root = 1;
+  for (let i = 0; i < degree; i++) {
+    for (let j = 0; j < divisor.length; j++) {
+      divisor[j] = gfMultiply(divisor[j], root);
+      if (j + 1 < divisor.length) {
+        divisor[j] ^= divisor[j + 1];
+      }
+    }
+    root = gfMultiply(root, 0x02);
+  }
+  return divisor;
+}
+
+/**
+ * Error correction codewords of a block
+ * @private
+ */
+function reedSolomonRemainder(data, divisor) {
+  const remainder = divisor.map(() => 0);
+  for (const byte of data) {
+    const factor = byte ^ remainder.shift();
+    remainder.push(0);
+    divisor.forEach((coefficient, i) => {
+      remainder[i] ^= gfMultiply(coefficient, factor);
+    });
+  }
+  return remainder;
+}
+
+/**
+ * Data codewords of a version with the error correction codewords of each block, interleaved
+ * @private
+ */
+function buildCodewords(bytes, version) {
+  const [, ecPerBlock, ...groups] = VERSIONS_M[version];
+  const dataCapacity = groups.reduce((total, [blocks, size]) => total + blocks * size, 0);
+
+  const bits = [];
+  const append = (value, length) => {
+    for (let i = length - 1; i >= 0; i--) {
+      bits.push((value >>> i) & 1);
+    }
+  };
+  append(0b0100, 4); // Byte mode
+  append(bytes.length, version < 10 ? 8 : 16);
+  for (const byte of bytes) {
+    append(byte, 8);
+  }
+  append(0, Math.min(4, dataCapacity * 8 - bits.length)); // Terminator
+  append(0, (8 - (bits.length % 8)) % 8);
+
+  const data = [];
+  for (let i = 0; i < bits.length; i += 8) {
+    data.push(bits.slice(i, i + 8).reduce((byte, bit) => (byte << 1) | bit, 0));
+  }
+  for (let pad = 0xEC; data.length < dataCapacity; pad ^= 0xEC ^ 0x11) {
+    data.push(pad);
+  }
+
+  const divisor = reedSolomonDivisor(ecPerBlock);
+  const blocks = [];
+  let offset = 0;
+  for (const [count, size] of groups) {
+    for (let i = 0; i < count; i++) {
+      const blockData = data.slice(offset, offset + size);
+      offset += size;
+      blocks.push({ data: blockData, ec: reedSolomonRemainder(blockData, divisor) });
+    }
+  }
+
+  const codewords = [];
+  const longestBlock = Math.max(...blocks.map(block => block.data.length));
+  for (let i = 0; i < longestBlock; i++) {
+    for (const block of blocks) {
+      if (i < block.data.length) {
+        codewords.push(block.data[i]);
+      }
+    }
+  }
+  for (let i = 0; i < ecPerBlock; i++) {
+    for (const block of blocks) {
+      codewords.push(block.ec[i]);
+    }
+  }
+  return codewords;
+}
+
+/**
+ * Module matrix under construction: modules[y][x] is dark, reserved[y][x] is part of a function pattern
+ * @private
+ */
+class QrMatrix {
+  constructor(version) {
+    this.version = version;
+    this.size = version * 4 + 17;
+    this.modules = Array.from({ length: this.size }, () => new Array(this.size).fill(false));
+    this.reserved = Array.from({ length: this.size }, () => new Array(this.size).fill(false));
+  }
+
+  setFunction(x, y, dark) {
+    this.modules[y][x] = dark;
+    this.reserved[y][x] = true;
+  }
+
+  drawFunctionPatterns() {
+    for (let i = 0; i < this.size; i++) {
+      this.setFunction(6, i, i % 2 === 0);
+      this.setFunction(i, 6, i % 2 === 0);
+    }
+    this.drawFinder(3, 3);
+    this.drawFinder(this.size - 4, 3);
+    this.drawFinder(3, this.size - 4);
+
+    const positions = ALIGNMENT_POSITIONS[this.version];
+    const last = positions.length - 1;
+    positions.forEach((x, i) => positions.forEach((y, j) => {
+      // The corners already hold finder patterns
+      if (!((i === 0 && j === 0) || (i === 0 && j === last) || (i === last && j === 0))) {
+        this.drawAlignment(x, y);
+      }
+    }));
+
+    this.drawFormatBits(0); // Reserves the area; redrawn with the chosen mask
+    this.drawVersion();
+  }
+
+  drawFinder(cx, cy) {
+    for (let dy = -4; dy <= 4; dy++) {
+      for (let dx = -4; dx <= 4; dx++) {
+        const distance = Math.max(Math.abs(dx), Math.abs(dy));
+        const x = cx + dx;
+        const y = cy + dy;
+        if (x >= 0 && x < this.size && y >= 0 && y < this.size) {
+          this.setFunction(x, y, distance !== 2 && distance !== 4);
+        }
+      }
+    }
+  }
+
+  drawAlignment(cx, cy) {
+    for (let dy = -2; dy <= 2; dy++) {
+      for (let dx = -2; dx <= 2; dx++) {
+        this.setFunction(cx + dx, cy + dy, Math.max(Math.abs(dx), Math.abs(dy)) !== 1);
+      }
+    }
+  }
+
+  drawFormatBits(mask) {
+    const data = (EC_LEVEL_M_BITS << 3) | mask;
+    let remainder = data;
+    for (let i = 0; i < 10; i++) {
+      remainder = (remainder << 1) ^ ((remainder >>> 9) * 0x537);
+    }
+    const bits = ((data << 10) | remainder) ^ 0x5412;
+
+    // Copy around the top-left finder
+    for (let i = 0; i <= 5; i++) {
+      this.setFunction(8, i, bitAt(bits, i));
+    }
+    this.setFunction(8, 7, bitAt(bits, 6));
+    this.setFunction(8, 8, bitAt(bits, 7));
+    this.setFunction(7, 8, bitAt(bits, 8));
+    for (let i = 9; i < 15; i++) {
+      this.setFunction(14 - i, 8, bitAt(bits, i));
+    }
+
+    // Copy split between the other two finders
+    for (let i = 0; i < 8; i++) {
+      this.setFunction(this.size - 1 - i, 8, bitAt(bits, i));
+    }
+    for (let i = 8; i < 15; i++) {
+      this.setFunction(8, this.size - 15 + i, bitAt(bits, i));
+    }
+    this.setFunction(8, this.size - 8, true); // Dark module
+  }
+
+  drawVersion() {
+    if (this.version < 7) {
+      return;
+    }
+    let remainder = this.version;
+    for (let i = 0; i < 12; i++) {
+      remainder = (remainder << 1) ^ ((remainder >>> 11) * 0x1F25);
+    }
+    const bits = (this.version << 12) | remainder;
+    for (let i = 0; i < 18; i++) {
+      const a = this.size - 11 + (i % 3);
+      const b = Math.floor(i / 3);
+      this.setFunction(a, b, bitAt(bits, i));
+      this.setFunction(b, a, bitAt(bits, i));
+    }
+  }
+
+  drawCodewords(codewords) {
+    let index = 0;
+    // Two-module columns from the right, zigzagging up and down, skipping the vertical timing pattern
+    for (let right = this.size - 1; right >= 1; right -= 2) {
+      if (right === 6) {
+        right = 5;
+      }
+      for (let vertical = 0; vertical < this.size; vertical++) {
+        for (let j = 0; j < 2; j++) {
+          const x = right - j;
+          const upward = ((right + 1) & 2) === 0;
+          const y = upward ? this.size - 1 - vertical : vertical;
+          if (!this.reserved[y][x] && index < codewords.length * 8) {
+            this.modules[y][x] = bitAt(codewords[index >>> 3], 7 - (index & 7));
+            index++;
+          }
+        }
+      }
+    }
+  }
+
+  applyMask(mask) {
+    for (let y = 0; y < this.size; y++) {
+      for (let x = 0; x < this.size; x++) {
+        if (!this.reserved[y][x] && MASKS[mask](x, y)) {
+          this.modules[y][x] = !this.modules[y][x];
+        }
+      }
+    }
+  }
+
+  /**
+   * Penalty score of the current modules (ISO/IEC 18004 section 7.8.3); the mask with the lowest score is used
+   */
+  penalty() {
+    let score = 0;
+    const lines = [];
+    for (let i = 0; i < this.size; i++) {
+      lines.push(this.modules[i].map(dark => (dark ? '1' : '0')).join(''));
+      lines.push(this.modules.map(row => (row[i] ? '1' : '0')).join(''));
+    }
+    for (const line of lines) {
+      // Runs of five or more modules of the same color
+      for (const run of line.match(/0{5,}|1{5,}/g) || []) {
+        score += run.length - 2;
+      }
+      // Patterns resembling a finder
+      for (const pattern of ['10111010000', '00001011101']) {
+        for (let at = line.indexOf(pattern); at !== -1; at = line.indexOf(pattern, at + 1)) {
+          score += 40;
+        }
+      }
+    }
+    // 2x2 blocks of the same color
+    for (let y = 0; y < this.size - 1; y++) {
+      for (let x = 0; x < this.size - 1; x++) {
+        const dark = this.modules[y][x];
+        if (dark === this.modules[y][x + 1] && dark === this.modules[y + 1][x] && dark === this.modules[y + 1][x + 1]) {
+          score += 3;
+        }
+      }
+    }
+    // Imbalance of dark and light modules
+    const darkCount = this.modules.reduce((total, row) => total + row.filter(Boolean).length, 0);
+    const total = this.size * this.size;
+    score += Math.floor(Math.abs(darkCount * 20 - total * 10) / total) * 10;
+    return score;
+  }
+}
+
+/**
+ * Encode text as a QR code
+ * @param {string} text - Text to encode (UTF-8)
+ * @returns {{size: number, modules: Array<Array<boolean>>}} Module matrix, modules[y][x] is dark
+ */
+function encodeQr(text) {
+  const bytes = Buffer.from(text, 'utf8');
+  const version = VERSIONS_M.findIndex((entry, index) => {
+    if (!entry) {
+      return false;
+    }
+    const [, , ...groups] = entry;
+    const capacityBits = groups.reduce((total, [blocks, size]) => total + blocks * size, 0) * 8;
+    return 4 + (index < 10 ? 8 : 16) + bytes.length * 8 <= capacityBits;
+  });
+  if (version === -1) {
+    throw new Error(`Text of ${bytes.length} bytes is too long for a QR code label`);
+  }
+
+  const codewords = buildCodewords(bytes, version);
+  let best = null;
+  for (let mask = 0; mask < MASKS.length; mask++) {
+    const matrix = new QrMatrix(version);
+    matrix.drawFunctionPatterns();
+    matrix.drawCodewords(codewords);
+    matrix.applyMask(mask);
+    matrix.drawFormatBits(mask);
+    const score = matrix.penalty();
+    if (!best || score < best.score) {
+      best = { matrix, score };
+    }
+  }
+  return { size: best.matrix.size, modules: best.matrix.modules };
+}
+
+function pngChunk(type, data) {
+  const length = Buffer.alloc(4);
+  length.writeUInt32BE(data.length);
+  const typeAndData = Buffer.concat([Buffer.from(type, 'ascii'), data]);
+  const crc = Buffer.alloc(4);
+  crc.writeUInt32BE(crc32(typeAndData));
+  return Buffer.concat([length, typeAndData, crc]);
+}
+
+/**
+ * Render a QR code as a grayscale PNG
+ * @param {string} text - Text to encode
+ * Modules are whole pixels, so the image is the multiple of the module count nearest to the requested width
+ * @param {Object} [options] - { width: requested width and height in pixels (default 256) }
+ * @returns {Buffer} PNG file
+ */
+function toPng(text, { width = 256 } = {}) {
+  const { size, modules } = encodeQr(text);
+  const extent = size + QUIET_ZONE * 2;
+  const scale = Math.max(1, Math.round(width / extent));
+  const pixels = extent * scale;
+
+  const rows = [];
+  for (let py = 0; py < pixels; py++) {
+    const row = Buffer.alloc(pixels + 1, 0xFF);
+    row[0] = 0; // Filter type None
+    const y = Math.floor(py / scale) - QUIET_ZONE;
+    if (y >= 0 && y < size) {
+      for (let px = 0; px < pixels; px++) {
+        const x = Math.floor(px / scale) - QUIET_ZONE;
+        if (x >= 0 && x < size && modules[y][x]) {
+          row[px + 1] = 0;
+        }
+      }
+    }
+    rows.push(row);
+  }
+
+  const header = Buffer.alloc(13);
+  header.writeUInt32BE(pixels, 0);
+  header.writeUInt32BE(pixels, 4);
+  header[8] = 8; // Bit depth
+  header[9] = 0; // Grayscale
+  return Buffer.concat([
+    Buffer.from([0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A]),
+    pngChunk('IHDR', header),
+    pngChunk('IDAT', zlib.deflateSync(Buffer.concat(rows))),
+    pngChunk('IEND', Buffer.alloc(0))
+  ]);
+}
+
+/**
+ * Path of the dark modules of a code, in module units offset by the quiet zone
+ * @private
+ */
+function modulePath(modules) {
+  const commands = [];
+  modules.forEach((row, y) => row.forEach((dark, x) => {
+    if (dark) {
+      commands.push(`M${x + QUIET_ZONE} ${y + QUIET_ZONE}h1v1h-1z`);
+    }
+  }));
+  return commands.join('');
+}
+
+function escapeXml(text) {
+  return String(text)
+    .replace(/&/g, '&amp;')
+    .replace(/</g, '&lt;')
+    .replace(/>/g, '&gt;')
+    .replace(/"/g, '&quot;');
+}
+
+/**
+ * Render a QR code as SVG
+ * @param {string} text - Text to encode
+ * @param {Object} [options] - { size: rendered width and height (default 256) }
+ * @returns {string} SVG document
+ */
+function toSvg(text, { size: renderedSize = 256 } = {}) {
+  const { size, modules } = encodeQr(text);
+  const extent = size + QUIET_ZONE * 2;
+  return `<?xml version="1.0" encoding="UTF-8"?>\n` +
+    `<svg xmlns="http://www.w3.org/2000/svg" width="${renderedSize}" height="${renderedSize}" viewBox="0 0 ${extent} ${extent}" shape-rendering="crispEdges">` +
+    `<rect width="${extent}" height="${extent}" fill="#fff"/><path d="${modulePath(modules)}" fill="#000"/></svg>\n`;
+}
+
+/**
+ * Render a printable A4 sheet of labels, each a QR code with a caption under it
+ * @param {Array<{text: string, caption: string}>} labels - Labels, in reading order
+ * @param {Object} [options] - { columns: labels per row (default 3) }
+ * @returns {string} SVG document sized in millimeters
+ */
+function toSvgSheet(labels, { columns = 3 } = {}) {
+  const page = { width: 210, height: 297, margin: 10 };
+  const cellWidth = (page.width - page.margin * 2) / columns;
+  const codeSize = cellWidth - 8;
+  const cellHeight = codeSize + 10;
+  const rowsPerPage = Math.floor((page.height - page.margin * 2) / cellHeight);
+  const pages = Math.max(1, Math.ceil(labels.length / (columns * rowsPerPage)));
+
+  const cells = labels.map((label, index) => {
+    const { size, modules } = encodeQr(label.text);
+    const extent = size + QUIET_ZONE * 2;
+    const slot = index % (columns * rowsPerPage);
+    const x = page.margin + (slot % columns) * cellWidth + (cellWidth - codeSize) / 2;
+    const y = Math.floor(index / (columns * rowsPerPage)) * page.height + page.margin + Math.floor(slot / columns) * cellHeight;
+    return `<svg x="${x}" y="${y}" width="${codeSize}" height="${codeSize}" viewBox="0 0 ${extent} ${extent}" shape-rendering="crispEdges">` +
+      `<path d="${modulePath(modules)}" fill="#000"/></svg>` +
+      `<text x="${x + codeSize / 2}" y="${y + codeSize + 4}" font-family="sans-serif" font-size="3.5" text-anchor="middle">${escapeXml(label.caption)}</text>`;
+  });
+
+  const height = pages * page.height;
+  return `<?xml version="1.0" encoding="UTF-8"?>\n` +
+    `<svg xmlns="http://www.w3.org/2000/svg" width="${page.width}mm" height="${height}mm" viewBox="0 0 ${page.width} ${height}">` +
+    `<rect width="${page.width}" height="${height}" fill="#fff"/>${cells.join('')}</svg>\n`;
+}
+
+module.exports = {
+  encodeQr,
+  toPng,
+  toSvg,
+  toSvgSheet
+};
@@ -0,0 +1,209 @@
+const zlib = require('node:zlib');
+
+/**
+ * Spreadsheet writers for exports
+ * A sheet is { name, columns: [{ key, header }], rows: [Object] }. CSV holds one sheet; XLSX holds several,
+ * written as a minimal Office Open XML workbook (inline strings, bold header row) without external dependencies.
+ */
+
+// Leading characters that make spreadsheet applications evaluate a cell as a formula
+const FORMULA_PREFIX = /^[=+\-@\t\r]/;
+
+/**
+ * Cell text with formula injection neutralized
+ * @private
+ */
+function cellText(value) {
+  if (value === undefined || value === null) {
+    return '';
+  }
+  const text = Array.isArray(value) ? value.join('; ') : String(value);
+  return FORMULA_PREFIX.test(text) ? `'${text}` : text;
+}
+
+/**
+ * Write one sheet as CSV (RFC 4180, UTF-8 with BOM so Excel detects the encoding)
+ * @param {Object} sheet - Sheet to write
+ * @returns {Buffer} CSV file
+ */
+function toCsv(sheet) {
+  const escape = (value) => {
+    const text = typeof value === 'number' ? String(value) : cellText(value);
+    return /[",\r\n]/.test(text) ? `"${text.replace(/"/g, '""')}"` : text;
+  };
+  const lines = [
+    sheet.columns.map(column => escape(column.header)).join(','),
+    ...sheet.rows.map(row => sheet.columns.map(column => escape(row[column.key])).join(','))
+  ];
+  return Buffer.from(`\uFEFF${lines.join('\r\n')}\r\n`, 'utf8');
+}
+
+function escapeXml(text) {
+  return text
+    .replace(/&/g, '&amp;')
+    .replace(/</g, '&lt;')
+    .replace(/>/g, '&gt;')
+    .replace(/"/g, '&quot;')
+    // Control characters are not allowed in XML 1.0
+    .replace(/[\u0000-\u0008\u000B\u000C\u000E-\u001F]/g, '');
+}
+
+function columnLetter(index) {
+  let letters = '';
+  for (let n = index + 1; n > 0; n = Math.floor((n - 1) / 26)) {
+    letters = String.fromCharCode(65 + ((n - 1) % 26)) + letters;
+  }
+  return letters;
+}
+
+function sheetXml(sheet) {
+  const cell = (value, ref, style) => {
+    if (typeof value === 'number' && Number.isFinite(value)) {
+      return `<c r="${ref}"${style}><v>${value}</v></c>`;
+    }
+    if (typeof value === 'boolean') {
+      return `<c r="${ref}"${style} t="b"><v>${value ? 1 : 0}</v></c>`;
+    }
+    return `<c r="${ref}"${style} t="inlineStr"><is><t xml:space="preserve">${escapeXml(cellText(value))}</t></is></c>`;
+  };
+  const row = (values, rowNumber, style = '') =>
+    `<row r="${rowNumber}">${values.map((value, index) => cell(value, `${columnLetter(index)}${rowNumber}`, style)).join('')}</row>`;
+
+  const rows = [
+    row(sheet.columns.map(column => column.header), 1, ' s="1"'),
+    ...sheet.rows.map((data, index) => row(sheet.columns.map(column => data[column.key]), index + 2))
+  ];
+  return '<?xml version="1.0" encoding="UTF-8" standalone="yes"?>' +
+    '<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">' +
+    '<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>' +
+    `<sheetData>${rows.join('')}</sheetData></worksheet>`;
+}
+
+const CRC_TABLE = Array.from({ length: 256 }, (_, n) => {
+  let c = n;
+  for (let k = 0; k < 8; k++) {
+    c = c & 1 ? 0xEDB88320 ^ (c >>> 1) : c >>> 1;
+  }
+  return c >>> 0;
+});
+
+function crc32(buffer) {
+  let crc = 0xFFFFFFFF;
+  for (const byte of buffer) {
+    crc = CRC_TABLE[(crc ^ byte) & 0xFF] ^ (crc >>> 8);
+  }
+  return (crc ^ 0xFFFFFFFF) >>> 0;
+}
+
+/**
+ * Build a ZIP archive of deflated entries
+ * @private
+ * @param {Array<{name: string, data: Buffer}>} entries
+ */
+function zip(entries) {
+  const localParts = [];
+  const centralParts = [];
+  let offset = 0;
+
+  for (const { name, data } of entries) {
+    const nameBuffer = Buffer.from(name, 'utf8');
+    const compressed = zlib.deflateRawSync(data);
+    const crc = crc32(data);
+
+    const local = Buffer.alloc(30);
+    local.writeUInt32LE(0x04034B50, 0);
+    local.writeUInt16LE(20, 4); // version needed to extract
+    local.writeUInt16LE(0x0800, 6); // UTF-8 names
+    local.writeUInt16LE(8, 8); // deflate
+    local.writeUInt32LE(crc, 14);
+    local.writeUInt32LE(compressed.length, 18);
+    local.writeUInt32LE(data.length, 22);
+    local.writeUInt16LE(nameBuffer.length, 26);
+
+    const central = Buffer.alloc(46);
+    central.writeUInt32LE(0x02014B50, 0);
+    central.writeUInt16LE(20, 4); // version made by
+    central.writeUInt16LE(20, 6);
+    central.writeUInt16LE(0x0800, 8);
+    central.writeUInt16LE(8, 10);
+    central.writeUInt32LE(crc, 16);
+    central.writeUInt32LE(compressed.length, 20);
+    central.writeUInt32LE(data.length, 24);
+    central.writeUInt16LE(nameBuffer.length, 28);
+    central.writeUInt32LE(offset, 42);
+
+    localParts.push(local, nameBuffer, compressed);
+    centralParts.push(central, nameBuffer);
+    offset += local.length + nameBuffer.length + compressed.length;
+  }
+
+  const centralDirectory = Buffer.concat(centralParts);
+  const end = Buffer.alloc(22);
+  end.writeUInt32LE(0x06054B50, 0);
+  end.writeUInt16LE(entries.length, 8);
+  end.writeUInt16LE(entries.length, 10);
+  end.writeUInt32LE(centralDirectory.length, 12);
+  end.writeUInt32LE(offset, 16);
+
+  return Buffer.concat([...localParts, centralDirectory, end]);
+}
+
+/**
+ * Write sheets as an XLSX workbook
+ * @param {Array<Object>} sheets - Sheets to write, in tab order
+ * @returns {Buffer} XLSX file
+ */
+function toXlsx(sheets) {
+  // Sheet names are limited to 31 characters and may not contain []:*?/\
+  const names = sheets.map(sheet => sheet.name.replace(/[[\]:*?/\\]/g, ' ').slice(0, 31));
+  const xml = (text) => Buffer.from(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>${text}`, 'utf8');
+
+  return zip([
+    {
+      name: '[Content_Types].xml',
+      data: xml('<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">' +
+        '<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>' +
+        '<Default Extension="xml" ContentType="application/xml"/>' +
+        '<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>' +
+        '<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>' +
+        sheets.map((_, index) => `<Override PartName="/xl/worksheets/sheet${index + 1}.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`).join('') +
+        '</Types>')
+    },
+    {
+      name: '_rels/.rels',
+      data: xml('<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">' +
+        '<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>' +
+        '</Relationships>')
+    },
+    {
+      name: 'xl/workbook.xml',
+      data: xml('<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>' +
+        names.map((name, index) => `<sheet name="${escapeXml(name)}" sheetId="${index + 1}" r:id="rId${index + 1}"/>`).join('') +
+        '</sheets></workbook>')
+    },
+    {
+      name: 'xl/_rels/workbook.xml.rels',
+      data: xml('<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">' +
+        sheets.map((_, index) => `<Relationship Id="rId${index + 1}" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet${index + 1}.xml"/>`).join('') +
+        `<Relationship Id="rId${sheets.length + 1}" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+        '</Relationships>')
+    },
+    {
+      name: 'xl/styles.xml',
+      data: xml('<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">' +
+        '<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>' +
+        '<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>' +
+        '<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>' +
+        '<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>' +
+        '<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>' +
+        '</styleSheet>')
+    },
+    ...sheets.map((sheet, index) => ({ name: `xl/worksheets/sheet${index + 1}.xml`, data: Buffer.from(sheetXml(sheet), 'utf8') }))
+  ]);
+}
+
+module.exports = {
+  toCsv,
+  toXlsx,
+  crc32
+};
@@ -0,0 +1,435 @@
+const riceService = require('../services/RiceService');
+const productService = require('../services/ProductService');
+const attachmentService = require('../services/AttachmentService');
+const consignmentService = require('../services/ConsignmentService');
+const { hasPermission, errorCodes } = require('../../config');
+
+/**
+ * GraphQL schema
+ * Batches, products, their genealogy (product <-> source batch) and history, resolved on demand
+ * so a client fetches exactly the trace data it needs in one round trip
+ */
+const typeDefs = `
+  type Query {
+    batch(id: ID!): Batch
+    batches(step: String, status: String): [Batch!]!
+    product(id: ID!): Product
+    productsByOwner(owner: String!, pageSize: Int, bookmark: String): ProductPage!
+    products(owner: String, batchId: String, status: String, packageDateFrom: String, packageDateTo: String, pageSize: Int, bookmark: String): ProductPage!
+  }
+
+  type Batch {
+    batchId: ID!
+    origin: String
+    variety: String
+    harvestDate: String
+    cropYear: Int
+    season: String
+    currentOwner: String
+    currentState: String
+    status: String
+    workflowId: String
+    quarantined: Boolean
+    quarantineReason: String
+    disposal: Disposal
+    foreignReferences: [ForeignBatchReference!]
+    insurancePolicies: [InsurancePolicy!]
+    insuranceClaims: [InsuranceClaim!]
+    labels: [Label!]!
+    delegations: [Delegation!]
+    giCompliance: GIComplianceCheck
+    consignments: [Consignment!]!
+    history(step: String): [HistoryEvent!]!
+    testResults: [TestResult!]!
+    certificates: [QualityCertificate!]!
+    attachments(category: String): [Attachment!]!
+    products: [Product!]!
+  }
+
+  type Label {
+    key: String!
+    value: String!
+  }
+
+  type Consignment {
+    consignmentId: ID!
+    exporterMspId: String
+    destinationCountry: String!
+    batchIds: [String!]!
+    productIds: [String!]!
+    phytosanitaryCertificateHash: String
+    customsDeclarationRef: String
+    status: String!
+    statusHistory: [ConsignmentStatusChange!]!
+    createdAt: String
+  }
+
+  type ConsignmentStatusChange {
+    status: String!
+    timestamp: String
+    mspId: String
+    note: String
+  }
+
+  type GIComplianceCheck {
+    giId: ID!
+    giName: String
+    ruleVersion: Int
+    plotId: String
+    passed: Boolean!
+    violations: [String!]!
+    checkedAt: String
+    checkedBy: String
+  }
+
+  type Delegation {
+    delegationId: ID!
+    delegateMspId: String!
+    delegateFingerprint: String!
+    permissions: [String!]!
+    grantedByMspId: String!
+    grantedAt: String
+    expiresAt: String!
+    revokedAt: String
+  }
+
+  type ForeignBatchReference {
+    channel: String!
+    chaincodeName: String
+    batchId: ID!
+    stateHash: String!
+    summary: ForeignProvenanceSummary
+    linkedAt: String
+    linkedBy: String
+  }
+
+  type InsurancePolicy {
+    insurer: String!
+    policyNumber: String!
+    coverage: String
+    validFrom: String
+    validTo: String
+    documentHash: String
+    attachedAt: String
+    attachedBy: String
+  }
+
+  type InsuranceClaim {
+    claimId: ID!
+    insurer: String
+    policyNumber: String
+    incidentDate: String
+    description: String
+    evidence: [InsuranceEvidence!]!
+    filedAt: String
+    filedBy: String
+  }
+
+  type InsuranceEvidence {
+    type: String!
+    reference: String!
+    description: String
+  }
+
+  type ForeignProvenanceSummary {
+    origin: String
+    variety: String
+    harvestDate: String
+    currentOwner: String
+    currentState: String
+    historyLength: Int
+  }
+
+  type HistoryEvent {
+    timestamp: String
+    from: String
+    to: String
+    step: String
+    report: ReportDetail
+    signerMspId: String
+    delegationId: String
+    onBehalfOfMspId: String
+  }
+
+  type ReportDetail {
+    reportId: String
+    reportType: String
+    reportHash: String
+    summary: String
+    isVerified: Boolean
+    verificationSource: String
+    destinationCountry: String
+    equipmentId: String
+    geolocation: GeoLocation
+    temperatureLogHash: String
+    inputs: [AgroInputApplication!]
+  }
+
+  type AgroInputApplication {
+    chemicalName: String!
+    inputLotId: String
+    appliedAt: String
+  }
+
+  type GeoLocation {
+    latitude: Float!
+    longitude: Float!
+  }
+
+  type TestResult {
+    testId: ID!
+    sampleId: String
+    testType: String
+    testDate: String
+    testResult: String
+    tester: String
+    isVerified: Boolean
+  }
+
+  type QualityCertificate {
+    certificateId: ID!
+    certificateType: String
+    issueDate: String
+    issuer: String
+    validityPeriod: String
+    standards: String
+    isActive: Boolean
+  }
+
+  type Product {
+    productId: ID!
+    packageDate: String
+    owner: String
+    status: String
+    requiresReinspection: Boolean
+    transfers: [ProductTransfer!]!
+    disposal: Disposal
+    nutrition: NutritionFacts
+    composition: ProductComposition
+    labels: [Label!]!
+    attachments(category: String): [Attachment!]!
+    consignments: [Consignment!]!
+    batch: Batch
+  }
+
+  type Attachment {
+    attachmentId: ID!
+    entityId: String
+    entityType: String
+    category: String
+    title: String
+    fileHash: String
+    mimeType: String
+    uri: String
+    metadata: AttachmentMetadata
+    addedBy: String
+    addedAt: String
+  }
+
+  type AttachmentMetadata {
+    takenAt: String
+    laboratory: String
+    reportDate: String
+    testId: String
+    certificateNumber: String
+    issuer: String
+    validUntil: String
+    counterparty: String
+    signedDate: String
+    declarationNumber: String
+    country: String
+  }
+
+  type NutritionFacts {
+    energyKj: Float
+    proteinG: Float
+    carbohydrateG: Float
+    fatG: Float
+    fiberG: Float
+    sodiumMg: Float
+  }
+
+  type ProductComposition {
+    ingredients: [String!]!
+    allergens: [String!]
+    netWeightG: Float
+    grade: String
+    bestBefore: String
+  }
+
+  type ProductTransfer {
+    timestamp: String
+    from: String
+    to: String
+    type: String
+    reason: String
+  }
+
+  type Disposal {
+    reason: String
+    method: String
+    quantity: String
+    handler: String
+    timestamp: String
+  }
+
+  type ProductPage {
+    products: [Product!]!
+    fetchedRecordsCount: Int
+    bookmark: String
+  }
+`;
+
+/**
+ * Check that the request role has a permission
+ * @private
+ */
+function requirePermission(context, permission) {
+  if (!hasPermission(context.role, permission)) {
+    throw new Error(`${errorCodes.PERMISSION_DENIED}: Role '${context.role}' does not have permission to perform this operation`);
+  }
+}
+
+/**
+ * Load a batch once per request, however many fields reference it
+ * @private
+ */
+function loadBatch(context, batchId) {
+  if (!context.batches.has(batchId)) {
+    context.batches.set(batchId, riceService.getBatchById(context.role, batchId));
+  }
+  return context.batches.get(batchId);
+}
+
+/**
+ * Turn a labels map into a list of key-value pairs (GraphQL has no map type)
+ * @private
+ */
+function toLabels(labels) {
+  return Object.entries(labels || {}).map(([key, value]) => ({ key, value }));
+}
+
+/**
+ * Attach nested field resolvers to a batch
+ * @private
+ */
+function toBatch(batch) {
+  return {
+    ...batch,
+    labels: toLabels(batch.labels),
+    history: ({ step }) => (batch.history || []).filter(event => !step || event.step === step),
+    testResults: (args, context) => {
+      requirePermission(context, 'getById');
+      return riceService.getTestResultsByBatch(context.role, batch.batchId);
+    },
+    certificates: (args, context) => {
+      requirePermission(context, 'getById');
+      return riceService.getCertificatesByBatch(context.role, batch.batchId);
+    },
+    attachments: ({ category }, context) => {
+      requirePermission(context, 'getById');
+      return attachmentService.listAttachments(context.role, batch.batchId, category || '');
+    },
+    consignments: (args, context) => {
+      requirePermission(context, 'getById');
+      return consignmentService.getConsignmentsByEntity(context.role, batch.batchId);
+    },
+    products: async (args, context) => {
+      requirePermission(context, 'getProduct');
+      const products = await productService.getProductsByBatch(context.role, batch.batchId);
+      return products.map(toProduct);
+    }
+  };
+}
+
+/**
+ * Attach nested field resolvers to a product
+ * @private
+ */
+function toProduct(product) {
+  return {
+    ...product,
+    transfers: product.transfers || [],
+    labels: toLabels(product.labels),
+    attachments: ({ category }, context) => {
+      requirePermission(context, 'getById');
+      return attachmentService.listAttachments(context.role, product.productId, category || '');
+    },
+    consignments: (args, context) => {
+      requirePermission(context, 'getById');
+      return consignmentService.getConsignmentsByEntity(context.role, product.productId);
+    },
+    batch: async (args, context) => {
+      requirePermission(context, 'getById');
+      return toBatch(await loadBatch(context, product.batchId));
+    }
+  };
+}
+
+/**
+ * Root resolvers
+ */
+const rootValue = {
+  batch: async ({ id }, context) => {
+    requirePermission(context, 'getById');
+    return toBatch(await loadBatch(context, id));
+  },
+
+  batches: async ({ step, status }, context) => {
+    requirePermission(context, 'getAll');
+    if (status) {
+      const batches = await riceService.getBatchesByStatus(context.role, status);
+      return batches.filter(batch => !step || batch.currentState === step).map(toBatch);
+    }
+    const batches = step
+      ? await riceService.getBatchesByStep(context.role, step)
+      : await riceService.getAllBatches(context.role);
+    return batches.map(toBatch);
+  },
+
+  product: async ({ id }, context) => {
+    requirePermission(context, 'getProduct');
+    const { product, batch } = await productService.getProductById(context.role, id);
+    // ReadProduct already returns the source batch; reuse it for the batch field
+    if (batch) {
+      context.batches.set(batch.batchId, Promise.resolve(batch));
+    }
+    return toProduct(product);
+  },
+
+  productsByOwner: async ({ owner, pageSize, bookmark }, context) => {
+    requirePermission(context, 'getProduct');
+    const page = await productService.getProductsByOwner(context.role, owner, pageSize || 20, bookmark);
+    return {
+      ...page,
+      products: page.products.map(toProduct)
+    };
+  },
+
+  products: async ({ owner, batchId, status, packageDateFrom, packageDateTo, pageSize, bookmark }, context) => {
+    requirePermission(context, 'getProduct');
+    const selector = { owner, batchId, status, packageDateFrom, packageDateTo };
+    const page = await productService.queryProducts(context.role, selector, pageSize || 20, bookmark);
+    return {
+      ...page,
+      products: page.products.map(toProduct)
+    };
+  }
+};
+
+/**
+ * Create the per-request context
+ * @param {string} role - Caller role
+ */
+function createContext(role) {
+  return {
+    role,
+    batches: new Map() // batchId -> Promise<batch>
+  };
+}
+
+module.exports = {
+  typeDefs,
+  rootValue,
+  createContext
+};
@@ -0,0 +1,60 @@
+const protobuf = require('protobufjs');
+
+/**
+ * Proto loader
+ * Builds a @grpc/grpc-js service definition from a .proto file using protobufjs
+ */
+
+// Decode options: keep enums/longs JSON friendly and always include default values
+const toObjectOptions = {
+  longs: Number,
+  enums: String,
+  defaults: true,
+  arrays: true
+};
+
+/**
+ * Create serializer/deserializer pair for a message type
+ * @private
+ */
+function createCodec(type) {
+  return {
+    serialize: (value) => Buffer.from(type.encode(type.fromObject(value || {})).finish()),
+    deserialize: (buffer) => type.toObject(type.decode(buffer), toObjectOptions)
+  };
+}
+
+/**
+ * Load a service definition
+ * @param {string} protoPath - Path of the .proto file
+ * @param {string} serviceName - Fully qualified service name, e.g. ricetrace.v1.TraceabilityService
+ * @returns {Object} grpc-js service definition
+ */
+function loadServiceDefinition(protoPath, serviceName) {
+  const root = protobuf.loadSync(protoPath);
+  const service = root.lookupService(serviceName);
+  const definition = {};
+
+  for (const method of service.methodsArray) {
+    method.resolve();
+    const request = createCodec(method.resolvedRequestType);
+    const response = createCodec(method.resolvedResponseType);
+
+    definition[method.name] = {
+      path: `/${serviceName}/${method.name}`,
+      requestStream: Boolean(method.requestStream),
+      responseStream: Boolean(method.responseStream),
+      requestSerialize: request.serialize,
+      requestDeserialize: request.deserialize,
+      responseSerialize: response.serialize,
+      responseDeserialize: response.deserialize,
+      originalName: method.name
+    };
+  }
+
+  return definition;
+}
+
+module.exports = {
+  loadServiceDefinition
+};
@@ -0,0 +1,201 @@
+const grpc = require('@grpc/grpc-js');
+const riceService = require('../services/RiceService');
+const productService = require('../services/ProductService');
+const { auth, fabric, hasPermission, getAvailableRoles, errorCodes } = require('../../config');
+const authService = require('../services/AuthService');
+const { parseError } = require('../middleware/errorMiddleware');
+const { runInChannel } = require('../dao/channelContext');
+const { runAsIdentity } = require('../dao/identityContext');
+
+/**
+ * gRPC handlers of TraceabilityService
+ * Same services and role permissions as the HTTP API, exposed over gRPC
+ */
+
+// HTTP status returned by parseError -> gRPC status code
+const grpcStatusByHttpStatus = {
+  400: grpc.status.INVALID_ARGUMENT,
+  401: grpc.status.UNAUTHENTICATED,
+  403: grpc.status.PERMISSION_DENIED,
+  404: grpc.status.NOT_FOUND,
+  409: grpc.status.ABORTED,
+  503: grpc.status.UNAVAILABLE,
+  504: grpc.status.DEADLINE_EXCEEDED
+};
+
+/**
+ * Convert a service error into a gRPC error
+ * @private
+ */
+function toGrpcError(error) {
+  const errorInfo = parseError(error);
+  const metadata = new grpc.Metadata();
+  metadata.set('error-code', errorInfo.code);
+
+  return {
+    code: errorInfo.code === errorCodes.ALREADY_EXISTS
+      ? grpc.status.ALREADY_EXISTS
+      : grpcStatusByHttpStatus[errorInfo.statusCode] || grpc.status.INTERNAL,
+    details: errorInfo.message,
+    metadata
+  };
+}
+
+/**
+ * Authenticate the bearer token of a call's authorization metadata entry
+ * With AUTH_ENABLED, the token's role and identity replace the x-user-role and x-fabric-identity metadata, like
+ * authenticate in authMiddleware does for the HTTP headers; metadata naming another role or identity is refused
+ * @private
+ * @returns {Promise<{role: string, identity: string}>} Role and identity the call acts as
+ */
+async function resolveCaller(metadata) {
+  const [role] = metadata.get('x-user-role');
+  const [identity = ''] = metadata.get('x-fabric-identity');
+  if (!auth.enabled) {
+    return { role, identity };
+  }
+
+  const [authorization = ''] = metadata.get('authorization');
+  if (!authorization) {
+    throw new Error(`${errorCodes.UNAUTHENTICATED}: Authentication is required, please provide a Bearer token in the authorization metadata`);
+  }
+  const [scheme, token] = String(authorization).split(' ');
+  if (scheme !== 'Bearer') {
+    throw new Error(`${errorCodes.UNAUTHENTICATED}: Expected a Bearer token in the authorization metadata`);
+  }
+  const user = await authService.authenticate(token);
+
+  if ((role && String(role) !== user.role) || (identity && String(identity) !== user.identity)) {
+    throw new Error(`${errorCodes.PERMISSION_DENIED}: User ${user.subject} acts as role ${user.role}${user.identity ? ` with identity ${user.identity}` : ''}`);
+  }
+  return { role: user.role, identity: user.identity || '' };
+}
+
+/**
+ * Wrap a unary handler with authentication, role extraction and permission check
+ * The role is read from the x-user-role metadata entry, like the X-User-Role HTTP header, and the optional
+ * channel and identity from x-channel and x-fabric-identity, like the X-Channel and X-Fabric-Identity HTTP headers.
+ * With AUTH_ENABLED, a bearer token in the authorization metadata entry is required and sets the role and identity
+ * @param {string} requiredPermission - Required permission
+ * @param {Function} fn - async (role, request) => response
+ * @returns {Function} grpc-js unary handler
+ */
+function unaryHandler(requiredPermission, fn) {
+  const handle = async (call) => {
+    const { role, identity } = await resolveCaller(call.metadata);
+    const validRoles = [...getAvailableRoles(), 'admin'];
+
+    if (!role || !validRoles.includes(String(role))) {
+      throw new Error(`${errorCodes.ROLE_MISSING}: Missing or invalid x-user-role metadata, available roles: ${validRoles.join(', ')}`);
+    }
+    if (!hasPermission(String(role), requiredPermission)) {
+      throw new Error(`${errorCodes.PERMISSION_DENIED}: Role '${role}' does not have permission to perform this operation`);
+    }
+
+    const [channel = fabric.channelName] = call.metadata.get('x-channel');
+    if (!fabric.channels[String(channel)]) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Unknown channel: ${channel}, available channels: ${Object.keys(fabric.channels).join(', ')}`);
+    }
+
+    const identityConfig = identity ? fabric.identities[String(identity)] : null;
+    if (identity && (!identityConfig || identityConfig.role !== String(role))) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Unknown identity ${identity} for role ${role}`);
+    }
+
+    return runInChannel(String(channel), () => runAsIdentity(String(identity), () => Promise.resolve(fn(String(role), call.request))));
+  };
+
+  return (call, callback) => {
+    handle(call)
+      .then(response => callback(null, response))
+      .catch(error => {
+        console.error(`gRPC ${call.getPath()} failed:`, error.message);
+        callback(toGrpcError(error));
+      });
+  };
+}
+
+/**
+ * Build an operation response
+ * @private
+ */
+function operationResponse(message) {
+  return {
+    message,
+    timestamp: new Date().toISOString()
+  };
+}
+
+const handlers = {
+  GetBatch: unaryHandler('getById', (role, request) =>
+    riceService.getBatchById(role, request.batchId)
+  ),
+
+  ListBatches: unaryHandler('getAll', async (role, request) => ({
+    batches: request.step
+      ? await riceService.getBatchesByStep(role, request.step)
+      : await riceService.getAllBatches(role)
+  })),
+
+  CompleteStepAndTransfer: unaryHandler('transfer', async (role, request) => {
+    await riceService.completeStepAndTransfer(
+      role,
+      request.batchId,
+      request.fromOperator,
+      request.toOperator,
+      request.step,
+      request.reportId,
+      {
+        destinationCountry: request.destinationCountry,
+        equipmentId: request.equipmentId,
+        geolocation: request.geolocation,
+        temperatureLogHash: request.temperatureLogHash
+      },
+      request.clientRequestId
+    );
+    return operationResponse(`Step ${request.step} completed and batch ${request.batchId} transferred to ${request.toOperator}`);
+  }),
+
+  GetTestResults: unaryHandler('getById', async (role, request) => ({
+    testResults: await riceService.getTestResultsByBatch(role, request.batchId)
+  })),
+
+  CreateProduct: unaryHandler('createProduct', async (role, request) => {
+    const result = await productService.createProduct(role, {
+      productId: request.productId,
+      batchId: request.batchId,
+      packageDate: request.packageDate,
+      owner: request.owner
+    }, request.clientRequestId);
+    return operationResponse(result.message);
+  }),
+
+  GetProduct: unaryHandler('getProduct', (role, request) =>
+    productService.getProductById(role, request.productId)
+  ),
+
+  ListProductsByOwner: unaryHandler('getProduct', (role, request) =>
+    productService.getProductsByOwner(role, request.owner, request.pageSize || 20, request.bookmark)
+  ),
+
+  ReturnProduct: unaryHandler('returnProduct', async (role, request) => {
+    const result = await productService.returnProduct(role, request.productId, request.reason, request.requireReinspection, request.clientRequestId);
+    return operationResponse(result.message);
+  }),
+
+  TraceProduct: unaryHandler('getProduct', async (role, request) => {
+    const traceability = await productService.getProductTraceability(role, request.productId);
+    const testResults = traceability.batch
+      ? await riceService.getTestResultsByBatch(role, traceability.batch.batchId)
+      : [];
+
+    return {
+      product: traceability.product,
+      batch: traceability.batch,
+      testResults,
+      traceabilityInfo: traceability.traceabilityInfo
+    };
+  })
+};
+
+module.exports = handlers;
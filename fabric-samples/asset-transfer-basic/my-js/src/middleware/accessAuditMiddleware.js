@@ -0,0 +1,35 @@
+const accessAuditService = require('../services/AccessAuditService');
+const { accessAudit } = require('../../config');
+
+/**
+ * Access audit middleware
+ * Records a read of a sensitive view before the view is served. The view is only served once the read has been
+ * recorded: a failed recording fails the request, so no read escapes the audit trail.
+ */
+
+/**
+ * Audit reads of a sensitive view
+ * The reader can state a purpose with the X-Access-Purpose header
+ * @param {string} resourceType - testReport | commercialTerms
+ * @param {string} idParam - Route parameter holding the resource ID
+ * @returns {Function} Middleware function
+ */
+function auditAccess(resourceType, idParam) {
+  return async (req, res, next) => {
+    if (!accessAudit.enabled) {
+      return next();
+    }
+
+    try {
+      const entry = await accessAuditService.recordAccess(req.role, resourceType, req.params[idParam], req.get('X-Access-Purpose') || '');
+      res.set('X-Access-Audit-Tx', entry.txId);
+      next();
+    } catch (error) {
+      next(error);
+    }
+  };
+}
+
+module.exports = {
+  auditAccess
+};
@@ -1,14 +1,64 @@
-const { hasPermission, getAvailableRoles, errorCodes } = require('../../config');
+const { auth, hasPermission, getAvailableRoles, errorCodes } = require('../../config');
+const authService = require('../services/AuthService');
+const { parseError } = require('./errorMiddleware');
 
 /**
  * Authentication middleware
  * Handles role validation and permission checks
  */
 
+/**
+ * Bearer token authentication middleware
+ * With AUTH_ENABLED, a valid token replaces the X-User-Role and X-Fabric-Identity headers with the role and
+ * identity its user is mapped to; headers naming another role or identity are refused. Requests without a token
+ * reach public endpoints only, as extractRole refuses them
+ */
+async function authenticate(req, res, next) {
+  const authorization = req.headers.authorization || '';
+  if (!auth.enabled || !authorization) {
+    return next();
+  }
+
+  const [scheme, token] = authorization.split(' ');
+  let user;
+  try {
+    if (scheme !== 'Bearer') {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: Expected a Bearer token in the Authorization header`);
+    }
+    user = await authService.authenticate(token);
+  } catch (error) {
+    const { code, message, statusCode } = parseError(error);
+    return res.status(statusCode).json({ error: code, message });
+  }
+
+  const requestedRole = req.headers['x-user-role'] || req.query.role;
+  const requestedIdentity = req.headers['x-fabric-identity'] || req.query.identity;
+  if ((requestedRole && requestedRole !== user.role) || (requestedIdentity && requestedIdentity !== user.identity)) {
+    return res.status(403).json({
+      error: errorCodes.PERMISSION_DENIED,
+      message: `User ${user.subject} acts as role ${user.role}${user.identity ? ` with identity ${user.identity}` : ''}`
+    });
+  }
+
+  req.auth = user;
+  req.headers['x-user-role'] = user.role;
+  if (user.identity) {
+    req.headers['x-fabric-identity'] = user.identity;
+  }
+  next();
+}
+
 /**
  * Role extraction middleware - Extract user role from request
  */
 function extractRole(req, res, next) {
+  if (auth.enabled && !req.auth) {
+    return res.status(401).json({
+      error: errorCodes.UNAUTHENTICATED,
+      message: 'Authentication is required, please provide a Bearer token in the Authorization header'
+    });
+  }
+
   // Priority: Header > Query Parameter > Default
   const role = req.headers['x-user-role'] || req.query.role || null;
   
@@ -34,6 +84,7 @@ function extractRole(req, res, next) {
   req.role = role;
   req.userInfo = {
     role,
+    ...(req.auth && { subject: req.auth.subject }),
     timestamp: new Date().toISOString()
   };
 
@@ -164,6 +215,7 @@ function validateParams(requiredParams = []) {
 }
 
 module.exports = {
+  authenticate,
   extractRole,
   requirePermission,
   checkRolePermission,
@@ -0,0 +1,31 @@
+const { fabric, errorCodes } = require('../../config');
+const { runInChannel } = require('../dao/channelContext');
+
+/**
+ * Channel routing middleware
+ * Selects the channel a request is served from, so one API instance can serve several traceability networks
+ */
+
+/**
+ * Channel selection middleware
+ * Priority: X-Channel header > channel query parameter > default channel of the registry
+ */
+function selectChannel(req, res, next) {
+  const channel = req.headers['x-channel'] || req.query.channel || fabric.channelName;
+
+  if (!fabric.channels[channel]) {
+    return res.status(400).json({
+      error: errorCodes.VALIDATION_ERROR,
+      message: `Unknown channel: ${channel}`,
+      availableChannels: Object.keys(fabric.channels)
+    });
+  }
+
+  req.channel = channel;
+  res.set('X-Channel', channel);
+  return runInChannel(channel, next);
+}
+
+module.exports = {
+  selectChannel
+};
@@ -28,7 +28,8 @@ function errorHandler(err, req, res, next) {
     timestamp: new Date().toISOString(),
     path: req.path,
     method: req.method,
-    ...(errorInfo.details && { details: errorInfo.details })
+    ...(errorInfo.details && { details: errorInfo.details }),
+    ...(errorInfo.transaction && { transaction: errorInfo.transaction })
   });
 }
 
@@ -74,6 +75,14 @@ function parseError(err) {
   }
   
   // Check if it's one of our defined error codes
+  if (message.includes(errorCodes.UNAUTHENTICATED)) {
+    return {
+      code: errorCodes.UNAUTHENTICATED,
+      message: message.replace(`${errorCodes.UNAUTHENTICATED}: `, ''),
+      statusCode: 401
+    };
+  }
+
   if (message.includes(errorCodes.PERMISSION_DENIED)) {
     return {
       code: errorCodes.PERMISSION_DENIED,
@@ -106,6 +115,72 @@ function parseError(err) {
     };
   }
   
+  // Duplicate submissions rejected by the chaincode (resubmitted test IDs, repeated processing steps)
+  if (message.includes(errorCodes.ALREADY_EXISTS) ||
+      (message.includes(errorCodes.FABRIC_ERROR) && /already exists/.test(message))) {
+    return {
+      code: errorCodes.ALREADY_EXISTS,
+      message: message.replace(`${errorCodes.ALREADY_EXISTS}: `, '').replace(`${errorCodes.FABRIC_ERROR}: `, ''),
+      statusCode: 409,
+      details: 'The record already exists; the resubmitted request was not applied again'
+    };
+  }
+
+  // Inputs above the chaincode's configurable input limits (report size, bulk list length)
+  if (message.includes(errorCodes.PAYLOAD_TOO_LARGE) ||
+      (message.includes(errorCodes.FABRIC_ERROR) && /above the input limit/.test(message))) {
+    return {
+      code: errorCodes.PAYLOAD_TOO_LARGE,
+      message: message.replace(`${errorCodes.PAYLOAD_TOO_LARGE}: `, '').replace(`${errorCodes.FABRIC_ERROR}: `, ''),
+      statusCode: 413,
+      details: 'See GET /api/input-limits for the limits in force'
+    };
+  }
+
+  if (message.includes(errorCodes.TRANSACTION_INVALID)) {
+    const match = message.match(/Transaction (\S+) was committed as invalid with validation code (\w+) \((\d+)\)/);
+    return {
+      code: errorCodes.TRANSACTION_INVALID,
+      message: message.replace(`${errorCodes.TRANSACTION_INVALID}: `, ''),
+      statusCode: 409,
+      details: 'The transaction was ordered but rejected by the committing peers; nothing was written',
+      ...(match && { transaction: { transactionId: match[1], validationCode: match[2], validationCodeValue: Number(match[3]) } })
+    };
+  }
+
+  if (message.includes(errorCodes.FABRIC_TIMEOUT)) {
+    const match = message.match(/transaction (\S+?);? (it may still be committed|timed out before it was submitted)/i);
+    const outcomeUnknown = /may still be committed/.test(message);
+    return {
+      code: errorCodes.FABRIC_TIMEOUT,
+      message: message.replace(`${errorCodes.FABRIC_TIMEOUT}: `, ''),
+      statusCode: 504,
+      details: outcomeUnknown
+        ? 'The outcome is unknown; retry with the same Idempotency-Key or check the ledger before resubmitting'
+        : 'The transaction was not submitted and can be retried',
+      ...(match && { transaction: { transactionId: match[1], committed: outcomeUnknown ? 'unknown' : false } })
+    };
+  }
+
+  // Off-chain file storage (IPFS node or pinning service) failures
+  if (message.includes(errorCodes.STORAGE_ERROR)) {
+    return {
+      code: errorCodes.STORAGE_ERROR,
+      message: message.replace(`${errorCodes.STORAGE_ERROR}: `, ''),
+      statusCode: 502,
+      details: 'The file storage (IPFS node or pinning service) could not be reached or rejected the request'
+    };
+  }
+
+  if (message.includes(errorCodes.RATE_LIMITED) || message.includes(errorCodes.QUOTA_EXCEEDED)) {
+    const code = message.includes(errorCodes.RATE_LIMITED) ? errorCodes.RATE_LIMITED : errorCodes.QUOTA_EXCEEDED;
+    return {
+      code,
+      message: message.replace(`${code}: `, ''),
+      statusCode: 429
+    };
+  }
+
   if (message.includes(errorCodes.FABRIC_ERROR)) {
     return {
       code: errorCodes.FABRIC_ERROR,
@@ -123,6 +198,15 @@ function parseError(err) {
     };
   }
 
+  // Rejected multipart uploads (file too large, unexpected field)
+  if (err.name === 'MulterError') {
+    return {
+      code: errorCodes.VALIDATION_ERROR,
+      message: `Upload rejected: ${message}`,
+      statusCode: err.code === 'LIMIT_FILE_SIZE' ? 413 : 400
+    };
+  }
+
   // Handle specific Node.js errors
   if (err.code === 'ENOENT') {
     return {
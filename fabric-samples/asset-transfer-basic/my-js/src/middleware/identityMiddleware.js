@@ -0,0 +1,41 @@
+const { fabric, errorCodes } = require('../../config');
+const { runAsIdentity } = require('../dao/identityContext');
+
+/**
+ * Identity selection middleware
+ * Selects the credentials a request is signed with, so one API instance can serve several users of each organization
+ */
+
+/**
+ * Identity selection middleware
+ * Priority: X-Fabric-Identity header > identity query parameter > default identity of the role
+ * The identity must belong to the role of the request (X-User-Role header or role query parameter)
+ */
+function selectIdentity(req, res, next) {
+  const identity = req.headers['x-fabric-identity'] || req.query.identity;
+  if (!identity) {
+    return next();
+  }
+
+  const role = req.headers['x-user-role'] || req.query.role;
+  const identityConfig = fabric.identities[identity];
+  if (!identityConfig || identityConfig.role !== role) {
+    return res.status(400).json({
+      error: errorCodes.VALIDATION_ERROR,
+      message: identityConfig
+        ? `Identity ${identity} belongs to role ${identityConfig.role}, not ${role || 'none'}`
+        : `Unknown identity: ${identity}`,
+      availableIdentities: Object.values(fabric.identities)
+        .filter(candidate => candidate.role === role)
+        .map(candidate => candidate.name)
+    });
+  }
+
+  req.identity = identity;
+  res.set('X-Fabric-Identity', identity);
+  return runAsIdentity(identity, next);
+}
+
+module.exports = {
+  selectIdentity
+};
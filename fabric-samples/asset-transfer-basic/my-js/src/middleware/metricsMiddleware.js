@@ -0,0 +1,49 @@
+const metricsService = require('../services/MetricsService');
+
+/**
+ * Metrics middleware
+ * Counts HTTP requests and records their latency
+ */
+
+/**
+ * Get the route pattern of a request (e.g. /api/batch/:id) to keep label cardinality bounded
+ * @private
+ */
+function getRouteLabel(req) {
+  if (req.route && req.route.path) {
+    return `${req.baseUrl}${req.route.path}`;
+  }
+  return 'unmatched';
+}
+
+/**
+ * Request metrics middleware
+ */
+function metricsMiddleware(req, res, next) {
+  const stopTimer = metricsService.httpRequestDuration.startTimer();
+
+  res.on('finish', () => {
+    const labels = {
+      method: req.method,
+      route: getRouteLabel(req),
+      status: res.statusCode
+    };
+    stopTimer(labels);
+    metricsService.httpRequestsTotal.inc(labels);
+  });
+
+  next();
+}
+
+/**
+ * Prometheus scrape endpoint handler
+ * GET /metrics
+ */
+function metricsHandler(req, res) {
+  res.type('text/plain; version=0.0.4').send(metricsService.render());
+}
+
+module.exports = {
+  metricsMiddleware,
+  metricsHandler
+};
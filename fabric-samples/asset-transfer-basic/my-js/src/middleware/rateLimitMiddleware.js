@@ -0,0 +1,52 @@
+const apiKeyService = require('../services/ApiKeyService');
+const { parseError } = require('./errorMiddleware');
+const { rateLimit, errorCodes } = require('../../config');
+
+/**
+ * Rate limiting middleware
+ * Guards the public trace and verification endpoints: requests with an X-API-Key count against the key's limits,
+ * requests without one against the anonymous limits of their IP address (or are refused with API_KEY_REQUIRED).
+ * Responses carry RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers; refused requests get
+ * 429 with Retry-After
+ */
+async function limitRate(req, res, next) {
+  if (!rateLimit.enabled) {
+    return next();
+  }
+
+  try {
+    const apiKey = req.headers['x-api-key'];
+    let subject;
+    let limits;
+    if (apiKey) {
+      const client = await apiKeyService.resolveKey(apiKey);
+      req.apiClient = { clientId: client.clientId, name: client.name };
+      subject = client.clientId;
+      limits = client;
+    } else if (rateLimit.requireApiKey) {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: An API key is required, please provide it in the X-API-Key header`);
+    } else {
+      subject = `ip:${req.ip}`;
+      limits = rateLimit.anonymous;
+    }
+
+    const usage = await apiKeyService.consume(subject, limits);
+    res.set({
+      'RateLimit-Limit': String(usage.limit),
+      'RateLimit-Remaining': String(usage.remaining),
+      'RateLimit-Reset': String(usage.reset),
+      'X-Quota-Remaining': String(usage.quotaRemaining)
+    });
+    next();
+  } catch (error) {
+    if (error.retryAfter) {
+      res.set('Retry-After', String(error.retryAfter));
+    }
+    const { code, message, statusCode } = parseError(error);
+    res.status(statusCode).json({ error: code, message });
+  }
+}
+
+module.exports = {
+  limitRate
+};
@@ -0,0 +1,104 @@
+const riceService = require('../services/RiceService');
+const productService = require('../services/ProductService');
+const weatherService = require('../services/WeatherService');
+const attachmentService = require('../services/AttachmentService');
+const equipmentService = require('../services/EquipmentService');
+const giService = require('../services/GIService');
+const consignmentService = require('../services/ConsignmentService');
+
+/**
+ * Read-your-writes middleware
+ * A client sending "Prefer: return=representation" (RFC 7240) with a write gets the committed state of the entity
+ * the write changed in the response (committedState), so a UI does not have to poll after submitting. Submits
+ * return once the transaction has committed on the gateway peer, so a read right after them sees the write.
+ */
+
+/**
+ * Committed-state loaders by route path; entity IDs come from the route or, for creations, the response data
+ */
+const ENTITY_LOADERS = [
+  {
+    pattern: /^\/(v2\/)?batch(\/|$)/,
+    id: (req, data) => req.params.id || data.batchId,
+    load: (role, id) => riceService.getCommittedBatch(role, id)
+  },
+  {
+    pattern: /^\/product(\/|$)/,
+    id: (req, data) => req.params.id || data.productId,
+    load: (role, id) => productService.getCommittedProduct(role, id)
+  },
+  {
+    pattern: /^\/weather$/,
+    id: (req, data) => data.dataHash,
+    load: (role, id) => weatherService.getObservation(role, id)
+  },
+  {
+    pattern: /^\/attachments\//,
+    id: (req, data) => data.attachmentId,
+    load: (role, id, req) => attachmentService.getAttachment(role, req.params.entityId, id)
+  },
+  {
+    pattern: /^\/equipment(\/|$)/,
+    id: (req, data) => req.params.equipmentId || data.equipmentId,
+    load: (role, id) => equipmentService.getEquipment(role, id)
+  },
+  {
+    pattern: /^\/gi\//,
+    id: (req, data) => req.params.giId || data.giId,
+    load: (role, id) => giService.getRule(role, id)
+  },
+  {
+    pattern: /^\/consignments(\/|$)/,
+    id: (req, data) => req.params.consignmentId || data.consignmentId,
+    load: (role, id) => consignmentService.getConsignment(role, id)
+  }
+];
+
+/**
+ * Whether the client asked for the committed state
+ * @private
+ */
+function wantsRepresentation(req) {
+  return (req.get('Prefer') || '').split(',').some(preference => preference.trim().toLowerCase() === 'return=representation');
+}
+
+/**
+ * Return the committed state of the changed entity from a write route, when the client asks for it
+ * Writes changing several entities (e.g. an EPCIS capture) have no single state to return and respond as usual
+ * @param {string} path - Route path of the write
+ * @returns {Function} Middleware function
+ */
+function returnCommittedState(path) {
+  const loader = ENTITY_LOADERS.find(candidate => candidate.pattern.test(path));
+
+  return (req, res, next) => {
+    if (!loader || !wantsRepresentation(req)) {
+      return next();
+    }
+
+    const json = res.json.bind(res);
+    res.json = (body) => {
+      const id = body && body.success && res.statusCode < 400 ? loader.id(req, body.data || {}) : null;
+      if (!id) {
+        return json(body);
+      }
+
+      loader.load(req.role, id, req)
+        .then(committedState => {
+          res.set('Preference-Applied', 'return=representation');
+          json({ ...body, committedState });
+        })
+        .catch(error => {
+          // The write has committed; report it even if the follow-up read fails
+          console.warn(`Failed to read committed state of ${id}: ${error.message}`);
+          json(body);
+        });
+      return res;
+    };
+    next();
+  };
+}
+
+module.exports = {
+  returnCommittedState
+};
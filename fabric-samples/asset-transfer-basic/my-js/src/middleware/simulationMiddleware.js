@@ -0,0 +1,26 @@
+const { runSimulated } = require('../dao/simulationContext');
+
+/**
+ * Dry-run middleware
+ * Wraps a write handler for its /simulate route variant: the handler runs unchanged, but its chaincode
+ * transactions are evaluated instead of submitted, and the response is marked as simulated.
+ */
+
+/**
+ * Wrap a route handler to run as a dry run
+ * @param {Function} handler - Express handler of the write operation
+ * @returns {Function} Express handler
+ */
+function simulate(handler) {
+  return (req, res, next) => {
+    const json = res.json.bind(res);
+    res.json = (body) => json(body && typeof body === 'object' && !Array.isArray(body) ? { ...body, simulated: true } : body);
+    res.set('X-Simulated', 'true');
+
+    return runSimulated(() => handler(req, res, next));
+  };
+}
+
+module.exports = {
+  simulate
+};
@@ -3,13 +3,70 @@ const batchController = require('../controllers/batchController');
 const productController = require('../controllers/productController');
 const reportController = require('../controllers/reportController');
 const cacheController = require('../controllers/cacheController');
-const { extractRole, checkRolePermission, validateRequest, validateParams, logUserAction } = require('../middleware/authMiddleware');
+const graphqlController = require('../controllers/graphqlController');
+const epcisController = require('../controllers/epcisController');
+const auditController = require('../controllers/auditController');
+const inspectionController = require('../controllers/inspectionController');
+const retentionController = require('../controllers/retentionController');
+const settlementController = require('../controllers/settlementController');
+const anomalyController = require('../controllers/anomalyController');
+const brandingController = require('../controllers/brandingController');
+const translationController = require('../controllers/translationController');
+const weatherController = require('../controllers/weatherController');
+const priceController = require('../controllers/priceController');
+const attachmentController = require('../controllers/attachmentController');
+const equipmentController = require('../controllers/equipmentController');
+const facilityController = require('../controllers/facilityController');
+const eventController = require('../controllers/eventController');
+const queryController = require('../controllers/queryController');
+const giController = require('../controllers/giController');
+const consignmentController = require('../controllers/consignmentController');
+const recallController = require('../controllers/recallController');
+const notificationController = require('../controllers/notificationController');
+const participantController = require('../controllers/participantController');
+const traceController = require('../controllers/traceController');
+const documentController = require('../controllers/documentController');
+const explorerController = require('../controllers/explorerController');
+const apiKeyController = require('../controllers/apiKeyController');
+const identifierController = require('../controllers/identifierController');
+const inputLimitController = require('../controllers/inputLimitController');
+const resourceUsageController = require('../controllers/resourceUsageController');
+const offlineSigningController = require('../controllers/offlineSigningController');
+const complianceController = require('../controllers/complianceController');
+const { authenticate, extractRole, checkRolePermission, validateRequest, validateParams, logUserAction } = require('../middleware/authMiddleware');
+const { simulate } = require('../middleware/simulationMiddleware');
+const { selectChannel } = require('../middleware/channelMiddleware');
+const { selectIdentity } = require('../middleware/identityMiddleware');
+const { auditAccess } = require('../middleware/accessAuditMiddleware');
+const { returnCommittedState } = require('../middleware/readAfterWriteMiddleware');
+const { limitRate } = require('../middleware/rateLimitMiddleware');
 
 const router = express.Router();
 
 // Apply global logging middleware
 router.use(logUserAction);
 
+// Map bearer tokens to the role and identity of their user (AUTH_ENABLED)
+router.use(authenticate);
+
+// Serve each request from the channel it selects (X-Channel header or channel query parameter)
+router.use(selectChannel);
+
+// Sign each request with the identity it selects (X-Fabric-Identity header or identity query parameter)
+router.use(selectIdentity);
+
+/**
+ * Register a write route together with its dry-run variant at <path>/simulate
+ * The variant runs the same checks and handler, but evaluates chaincode transactions instead of submitting them,
+ * so UIs can pre-validate a form and see the projected result before committing. The write itself can return
+ * the committed state of the changed entity (Prefer: return=representation)
+ */
+function writeRoute(method, path, ...handlers) {
+  const handler = handlers.pop();
+  router[method](`${path}/simulate`, ...handlers, simulate(handler));
+  router[method](path, ...handlers, returnCommittedState(path), handler);
+}
+
 /**
  * Batch related routes
  */
@@ -20,6 +77,94 @@ router.get('/batch/stats',
   batchController.getBatchStats
 );
 
+// Get recorded daily activity statistics (must be placed before dynamic routes)
+router.get('/batch/stats/daily',
+  ...checkRolePermission('getAll'),
+  batchController.getDailyStats
+);
+
+// Get network-level KPIs for operator reporting (must be placed before dynamic routes)
+router.get('/batch/stats/network',
+  ...checkRolePermission('networkKpi'),
+  batchController.getNetworkKpis
+);
+
+// Compare a season year over year, and get the aggregates of one crop year (must be placed before dynamic routes)
+router.get('/batch/stats/seasons',
+  ...checkRolePermission('getAll'),
+  batchController.compareSeasons
+);
+
+router.get('/batch/stats/seasons/:cropYear',
+  ...checkRolePermission('getAll'),
+  validateParams(['cropYear']),
+  batchController.getSeasonStats
+);
+
+// Quality trends of a variety and region month by month (must be placed before dynamic routes)
+router.get('/batch/stats/quality-trends',
+  ...checkRolePermission('getAll'),
+  batchController.getQualityTrends
+);
+
+// Scheduled transfers whose effective time has been reached (must be placed before dynamic routes)
+router.get('/batch/scheduled-transfers/due',
+  ...checkRolePermission('getById'),
+  batchController.getDueScheduledTransfers
+);
+
+// Export a filtered batch list as CSV/XLSX (must be placed before dynamic routes)
+router.get('/batch/export',
+  ...checkRolePermission('getAll'),
+  batchController.exportBatches
+);
+
+// Search batches by free text, tolerating typos (must be placed before dynamic routes)
+router.get('/batch/search',
+  ...checkRolePermission('getAll'),
+  batchController.searchBatches
+);
+
+// Get the batches scoring at least a minimum traceability score (must be placed before dynamic routes)
+router.get('/batch/well-documented',
+  ...checkRolePermission('getAll'),
+  batchController.getWellDocumentedBatches
+);
+
+// Get batches carrying a label (must be placed before dynamic routes)
+router.get('/batch/label/:key',
+  ...checkRolePermission('getAll'),
+  validateParams(['key']),
+  batchController.getBatchesByLabel
+);
+
+// Get the batches and products an owner holds, with totals (must be placed before dynamic routes)
+router.get('/batch/inventory/:owner',
+  ...checkRolePermission('getAll'),
+  validateParams(['owner']),
+  batchController.getOwnerInventory
+);
+
+// Compare offered lots side by side (must be placed before dynamic routes)
+router.get('/batch/compare',
+  ...checkRolePermission('getAll'),
+  batchController.compareBatches
+);
+
+// Get batches currently at a processing step (must be placed before dynamic routes)
+router.get('/batch/step/:step',
+  ...checkRolePermission('getAll'),
+  validateParams(['step']),
+  batchController.getBatchesByStep
+);
+
+// Get batches with a derived status: Active, Quarantined, Recalled, Finalized, Archived or Disposed
+router.get('/batch/status/:status',
+  ...checkRolePermission('getAll'),
+  validateParams(['status']),
+  batchController.getBatchesByStatus
+);
+
 // Get Oracle service status
 router.get('/oracle/status', 
   extractRole, // Only need to extract role, no permission restriction
@@ -29,13 +174,21 @@ router.get('/oracle/status',
 // ===================== New Unified API =====================
 
 // Complete step and transfer - new unified endpoint
-router.post('/v2/batch/:id/event',
+writeRoute('post', '/v2/batch/:id/event',
   ...checkRolePermission('transfer'),
   validateParams(['id']),
   validateRequest(['fromOperator', 'toOperator', 'step', 'reportId']),
   batchController.completeStepAndTransfer
 );
 
+// List every transfer rule a step and transfer would break, without submitting it
+router.post('/v2/batch/:id/event/check',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  validateRequest(['toOperator']),
+  batchController.checkTransfer
+);
+
 // Get current batch owner for auto-fill
 router.get('/batch/:id/owner',
   ...checkRolePermission('getById'),
@@ -75,6 +228,7 @@ router.get('/reports/:reportId/verify',
 router.get('/reports/:reportId',
   extractRole,
   validateParams(['reportId']),
+  auditAccess('testReport', 'reportId'),
   reportController.getReportById
 );
 
@@ -91,8 +245,15 @@ router.get('/batch',
   batchController.getAllBatches
 );
 
+// Draw the next batch ID of the caller's organization under the ID policy. Not a write route: a simulated ID
+// is not reserved and would be handed out again
+router.post('/batch/ids',
+  ...checkRolePermission('create'),
+  identifierController.generateBatchId
+);
+
 // Create batch (requires quality inspection report)
-router.post('/batch', 
+writeRoute('post', '/batch',
   ...checkRolePermission('create'),
   validateRequest(['reportId', 'location', 'variety', 'harvestDate', 'initialTestResult', 'owner', 'initialStep', 'operator']),
   batchController.createBatch
@@ -106,7 +267,7 @@ router.get('/batch/:id/exists',
 );
 
 // Transfer batch ownership (requires quality inspection report)
-router.put('/batch/:id/transfer', 
+writeRoute('put', '/batch/:id/transfer',
   ...checkRolePermission('transfer'),
   validateParams(['id']),
   validateRequest(['reportId', 'newOwner', 'operator']),
@@ -114,21 +275,357 @@ router.put('/batch/:id/transfer',
 );
 
 // Add quality inspection result (supports Oracle verification)
-router.post('/batch/:id/test', 
+writeRoute('post', '/batch/:id/test',
   ...checkRolePermission('addTest'),
   validateParams(['id']),
   // Note: testId is not required, because Oracle mode only requires externalReportId
   batchController.addTestResult
 );
 
+// Check a report file hash against the one registered with a test result
+router.get('/batch/:id/test/:testId/verify-hash',
+  ...checkRolePermission('getById'),
+  validateParams(['id', 'testId']),
+  batchController.verifyTestReportHash
+);
+
+// Withdraw a test result; only the identity that recorded it can revoke it
+writeRoute('post', '/batch/:id/test/:testId/revoke',
+  ...checkRolePermission('addTest'),
+  validateParams(['id', 'testId']),
+  validateRequest(['reason']),
+  batchController.revokeTestResult
+);
+
+// Record the residue levels a test measured, checked against the residue limits of export markets
+writeRoute('post', '/batch/:id/test/:testId/residues',
+  ...checkRolePermission('addTest'),
+  validateParams(['id', 'testId']),
+  validateRequest(['residues']),
+  complianceController.recordResidueLevels
+);
+
+// Record the moisture content a moisture test measured, used by the quality trends
+writeRoute('post', '/batch/:id/test/:testId/moisture',
+  ...checkRolePermission('addTest'),
+  validateParams(['id', 'testId']),
+  validateRequest(['moisturePercent']),
+  batchController.recordMoistureContent
+);
+
+// Privately share the full report of a test with the organization it was made for; only its hash is public
+writeRoute('put', '/batch/:id/test/:testId/report-details',
+  ...checkRolePermission('addTest'),
+  validateParams(['id', 'testId']),
+  validateRequest(['counterpartyMspId', 'details']),
+  batchController.setTestReportDetails
+);
+
+// Get the full report of a test shared with the caller's organization
+router.get('/batch/:id/test/:testId/report-details',
+  ...checkRolePermission('getById'),
+  validateParams(['id', 'testId']),
+  auditAccess('testReport', 'testId'),
+  batchController.getTestReportDetails
+);
+
 // Add processing record
-router.post('/batch/:id/process', 
+writeRoute('post', '/batch/:id/process',
   ...checkRolePermission('addProcess'),
   validateParams(['id']),
   validateRequest(['step', 'operator']),
   batchController.addProcessingRecord
 );
 
+// Privately attach commercial terms to a batch owned by the caller's organization
+writeRoute('put', '/batch/:id/terms',
+  ...checkRolePermission('commercialTerms'),
+  validateParams(['id']),
+  validateRequest(['terms']),
+  batchController.setCommercialTerms
+);
+
+// Get the caller organization's commercial terms for a batch
+router.get('/batch/:id/terms',
+  ...checkRolePermission('commercialTerms'),
+  validateParams(['id']),
+  auditAccess('commercialTerms', 'id'),
+  batchController.getCommercialTerms
+);
+
+// Replace the labels of a batch
+writeRoute('put', '/batch/:id/labels',
+  ...checkRolePermission('label'),
+  validateParams(['id']),
+  validateRequest(['labels']),
+  batchController.setBatchLabels
+);
+
+// Check a batch against a geographic indication rule before it carries the GI claim
+writeRoute('post', '/batch/:id/gi-check',
+  ...checkRolePermission('giCheck'),
+  validateParams(['id']),
+  validateRequest(['giId']),
+  giController.checkCompliance
+);
+
+// Check whether a batch meets the compliance profile of an export market
+router.get('/batch/:id/export-compliance',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  complianceController.checkExportCompliance
+);
+
+// Let a cooperative or broker transfer or process a batch on the farmer's behalf
+writeRoute('post', '/batch/:id/delegates',
+  ...checkRolePermission('delegate'),
+  validateParams(['id']),
+  validateRequest(['delegateIdentity', 'permissions', 'expiry']),
+  batchController.grantDelegate
+);
+
+// Revoke a delegation before it expires
+writeRoute('post', '/batch/:id/delegates/:delegationId/revoke',
+  ...checkRolePermission('delegate'),
+  validateParams(['id', 'delegationId']),
+  batchController.revokeDelegate
+);
+
+// Declare the quantity of a batch that reservations are taken from
+writeRoute('put', '/batch/:id/quantity',
+  ...checkRolePermission('reserve'),
+  validateParams(['id']),
+  validateRequest(['quantityKg']),
+  batchController.setBatchQuantity
+);
+
+// Record the weight a batch lost in a processing step, e.g. drying
+writeRoute('post', '/batch/:id/weight-adjustments',
+  ...checkRolePermission('reserve'),
+  validateParams(['id']),
+  validateRequest(['step', 'beforeKg', 'afterKg', 'reason']),
+  batchController.recordWeightAdjustment
+);
+
+// Reserve quantity of a batch for a pending sale
+writeRoute('post', '/batch/:id/reservations',
+  ...checkRolePermission('reserve'),
+  validateParams(['id']),
+  validateRequest(['buyer', 'quantityKg', 'expiry']),
+  batchController.reserveBatchQuantity
+);
+
+// Release a reservation when the sale falls through
+writeRoute('post', '/batch/:id/reservations/:reservationId/release',
+  ...checkRolePermission('reserve'),
+  validateParams(['id', 'reservationId']),
+  batchController.releaseReservation
+);
+
+// Schedule the handover of a batch for a contractual date
+writeRoute('post', '/batch/:id/scheduled-transfers',
+  ...checkRolePermission('scheduleTransfer'),
+  validateParams(['id']),
+  validateRequest(['newOwner', 'effectiveTime']),
+  batchController.scheduleTransfer
+);
+
+// Activate a scheduled transfer once its effective time is reached; any party can
+writeRoute('post', '/batch/:id/scheduled-transfers/activate',
+  ...checkRolePermission('activateTransfer'),
+  validateParams(['id']),
+  batchController.activateScheduledTransfer
+);
+
+// Cancel the pending scheduled transfer of a batch
+writeRoute('post', '/batch/:id/scheduled-transfers/cancel',
+  ...checkRolePermission('scheduleTransfer'),
+  validateParams(['id']),
+  batchController.cancelScheduledTransfer
+);
+
+// Get the scheduled transfers of a batch
+router.get('/batch/:id/scheduled-transfers',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.getScheduledTransfers
+);
+
+// Get the reservations of a batch and the quantity still available
+router.get('/batch/:id/reservations',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.getBatchReservations
+);
+
+// Add a run of processing records in one transaction, e.g. from a packaging line
+writeRoute('post', '/batch/:id/processing-records',
+  ...checkRolePermission('addProcess'),
+  validateParams(['id']),
+  validateRequest(['records']),
+  batchController.addProcessingRecords
+);
+
+// Get the energy, labor and machine time the caller's organization recorded for a batch's processing steps
+router.get('/batch/:id/resource-usage',
+  ...checkRolePermission('resourceUsage'),
+  validateParams(['id']),
+  resourceUsageController.getBatchUsage
+);
+
+// Sum the caller organization's resource usage over a period, per processing step
+router.get('/resource-usage/summary',
+  ...checkRolePermission('resourceUsage'),
+  resourceUsageController.getSummary
+);
+
+// Correct a mistyped processing record; the original is kept as superseded
+writeRoute('post', '/batch/:id/history/:index/corrections',
+  ...checkRolePermission('correctRecord'),
+  validateParams(['id', 'index']),
+  validateRequest(['reason']),
+  batchController.correctProcessingRecord
+);
+
+// Record whether the handover recorded by a history event was invoiced, paid or disputed
+writeRoute('put', '/batch/:id/history/:index/settlement',
+  ...checkRolePermission('settlement'),
+  validateParams(['id', 'index']),
+  validateRequest(['status']),
+  settlementController.recordSettlement
+);
+
+// Get the settlement of a handover
+router.get('/batch/:id/history/:index/settlement',
+  ...checkRolePermission('settlement'),
+  validateParams(['id', 'index']),
+  settlementController.getSettlement
+);
+
+// Add a photo, tagged with its stage, to the photo journal of a batch
+writeRoute('post', '/batch/:id/photos',
+  ...checkRolePermission('attach'),
+  validateParams(['id']),
+  validateRequest(['stage', 'fileHash', 'uri']),
+  attachmentController.addBatchPhoto
+);
+
+// Get the photo journal of a batch
+router.get('/batch/:id/photos',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  attachmentController.getBatchPhotos
+);
+
+// Reference a batch committed on another channel as a source of this batch
+writeRoute('post', '/batch/:id/foreign-references',
+  ...checkRolePermission('foreignReference'),
+  validateParams(['id']),
+  validateRequest(['channel', 'foreignBatchId', 'stateHash']),
+  batchController.linkForeignBatch
+);
+
+// Compare a referenced foreign batch with its state when linked
+router.get('/batch/:id/foreign-references/:channel/:foreignBatchId/verify',
+  ...checkRolePermission('getById'),
+  validateParams(['id', 'channel', 'foreignBatchId']),
+  batchController.verifyForeignBatchReference
+);
+
+// Get the state hash cited when the batch is referenced from another channel
+router.get('/batch/:id/state-hash',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.getBatchStateHash
+);
+
+// Get how completely a batch is documented, for buyers filtering for well-documented batches
+router.get('/batch/:id/traceability-score',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.getTraceabilityScore
+);
+
+// Get the size of a batch document and its history and test result counts against the storage limits
+router.get('/batch/:id/storage-usage',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.getBatchStorageUsage
+);
+
+// Get the ancestor/descendant graph of a batch (recall blast radius)
+router.get('/batch/:id/genealogy',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.getBatchGenealogy
+);
+
+// Attach an insurance policy to a batch
+writeRoute('post', '/batch/:id/insurance',
+  ...checkRolePermission('insurance'),
+  validateParams(['id']),
+  validateRequest(['insurer', 'policyNumber', 'coverage', 'validFrom', 'validTo', 'documentHash']),
+  batchController.attachInsurancePolicy
+);
+
+// File an insurance claim against a policy attached to a batch
+writeRoute('post', '/batch/:id/insurance/claims',
+  ...checkRolePermission('insurance'),
+  validateParams(['id']),
+  validateRequest(['insurer', 'policyNumber', 'claimId', 'incidentDate', 'description', 'evidence']),
+  batchController.claimInsurance
+);
+
+// Compare two committed versions of a batch
+router.get('/batch/:id/history/diff',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.getBatchHistoryDiff
+);
+
+// List the ledger transactions that touched a batch (transaction explorer index)
+router.get('/batch/:id/transactions',
+  ...checkRolePermission('explorer'),
+  validateParams(['id']),
+  explorerController.getEntityTransactions
+);
+
+// Export a batch's full history as CSV/XLSX
+router.get('/batch/:id/history/export',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.exportBatchHistory
+);
+
+// Export an ISO 22005 traceability audit package of a batch for certification audits
+router.get('/batch/:id/audit-package',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.exportAuditPackage
+);
+
+// QR code label of a batch, encoding its public trace URL
+router.get('/batch/:id/qr',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.getBatchLabel
+);
+
+// Printable A4 sheet of numbered sack labels of a batch
+router.get('/batch/:id/qr-sheet',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  batchController.getBatchLabelSheet
+);
+
+// Issue a PDF traceability certificate of a batch and anchor its hash. Not a write route: a simulated certificate
+// would look genuine without being anchored
+router.post('/batch/:id/certificate',
+  ...checkRolePermission('certificate'),
+  validateParams(['id']),
+  documentController.issueBatchCertificate
+);
+
 // Get batch by ID (must be placed at the end to avoid conflicts with other routes)
 router.get('/batch/:id', 
   ...checkRolePermission('getById'),
@@ -140,13 +637,102 @@ router.get('/batch/:id',
  * Product related routes
  */
 
+// Draw the next product ID of the caller's organization under the ID policy (not a write route, as for batches)
+router.post('/product/ids',
+  ...checkRolePermission('createProduct'),
+  identifierController.generateProductId
+);
+
 // Create product
-router.post('/product', 
+writeRoute('post', '/product',
   ...checkRolePermission('createProduct'),
   validateRequest(['productId', 'batchId', 'packageDate', 'owner']),
   productController.createProduct
 );
 
+// Get products held by an owner (paginated)
+router.get('/product/owner/:owner',
+  ...checkRolePermission('getProduct'),
+  validateParams(['owner']),
+  productController.getProductsByOwner
+);
+
+// Query products by owner, batch, status and package date range (paginated)
+router.get('/product/query',
+  ...checkRolePermission('getProduct'),
+  productController.queryProducts
+);
+
+// Get products carrying a label
+router.get('/product/label/:key',
+  ...checkRolePermission('getProduct'),
+  validateParams(['key']),
+  productController.getProductsByLabel
+);
+
+// Replace the labels of a product
+writeRoute('put', '/product/:id/labels',
+  ...checkRolePermission('label'),
+  validateParams(['id']),
+  validateRequest(['labels']),
+  productController.setProductLabels
+);
+
+// Set product nutrition facts and composition
+writeRoute('put', '/product/:id/nutrition',
+  ...checkRolePermission('createProduct'),
+  validateParams(['id']),
+  productController.setProductNutrition
+);
+
+// Return a sold product to its distributor
+writeRoute('post', '/product/:id/return',
+  ...checkRolePermission('returnProduct'),
+  validateParams(['id']),
+  validateRequest(['reason']),
+  productController.returnProduct
+);
+
+// Register the verification code printed on a product package
+writeRoute('post', '/product/:id/verification-code',
+  ...checkRolePermission('createProduct'),
+  validateParams(['id']),
+  validateRequest(['code']),
+  productController.registerVerificationCode
+);
+
+// Generate a product label with a newly registered verification code. Not a write route: a simulated label
+// would carry a code that is never registered
+router.post('/product/:id/qr',
+  ...checkRolePermission('createProduct'),
+  validateParams(['id']),
+  productController.createProductLabel
+);
+
+// Issue a PDF traceability certificate of a product and anchor its hash (not a write route, as for batches)
+router.post('/product/:id/certificate',
+  ...checkRolePermission('certificate'),
+  validateParams(['id']),
+  documentController.issueProductCertificate
+);
+
+// Check the code on a product package. Not a write route: a /simulate variant would let codes be guessed
+// without the attempts being counted
+router.post('/product/:id/verify',
+  limitRate,
+  ...checkRolePermission('getProduct'),
+  validateParams(['id']),
+  validateRequest(['code']),
+  productController.verifyProduct
+);
+
+// Get the verification attempt counters and lockout of a product
+router.get('/product/:id/verification',
+  ...checkRolePermission('getProduct'),
+  validateParams(['id']),
+  productController.getVerificationStatus
+);
+
 // Check if product exists
 router.get('/product/:id/exists', 
   ...checkRolePermission('getProduct'),
@@ -161,6 +747,27 @@ router.get('/product/:id/traceability',
   productController.getProductTraceability
 );
 
+// List the ledger transactions that touched a product (transaction explorer index)
+router.get('/product/:id/transactions',
+  ...checkRolePermission('explorer'),
+  validateParams(['id']),
+  explorerController.getEntityTransactions
+);
+
+// Get the origins of the rice in a product with their shares
+router.get('/product/:id/origins',
+  ...checkRolePermission('getProduct'),
+  validateParams(['id']),
+  productController.getProductComposition
+);
+
+// Check the trace chain of a product for gaps QA teams have to close
+router.get('/product/:id/chain-validation',
+  ...checkRolePermission('getProduct'),
+  validateParams(['id']),
+  productController.validateTraceChain
+);
+
 // Get product by ID
 router.get('/product/:id', 
   ...checkRolePermission('getProduct'),
@@ -168,6 +775,608 @@ router.get('/product/:id',
   productController.getProductById
 );
 
+/**
+ * Consumer trace routes
+ */
+
+// Aggregated, localized trace of a product for mobile apps. Public: read with the consumer identity, no role needed
+router.get('/trace/:productId',
+  limitRate,
+  validateParams(['productId']),
+  traceController.getProductTrace
+);
+
+/**
+ * EPCIS routes
+ */
+
+// Import an EPCIS 2.0 capture document from a partner system (JSON or JSON-LD body)
+writeRoute('post', '/epcis/capture',
+  express.json({ type: ['application/json', 'application/ld+json'], limit: '10mb' }),
+  ...checkRolePermission('epcisCapture'),
+  epcisController.captureDocument
+);
+
+// Get a logistics unit built from AggregationEvents
+router.get('/epcis/units/:id',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  epcisController.getLogisticsUnit
+);
+
+// Get a shipment imported from a shipping ObjectEvent
+router.get('/epcis/shipments/:id',
+  ...checkRolePermission('getById'),
+  validateParams(['id']),
+  epcisController.getShipment
+);
+
+// Report products referencing missing batches, unregistered transfer parties and stale genealogy edges
+// (signed by an organization administrator identity)
+router.get('/integrity',
+  ...checkRolePermission('integrity'),
+  batchController.validateReferentialIntegrity
+);
+
+// Get the ID policy batch and product IDs must follow
+router.get('/id-policy',
+  ...checkRolePermission('getAll'),
+  identifierController.getIdPolicy
+);
+
+// Get the size limits the chaincode applies to reports, labels and bulk lists
+router.get('/input-limits',
+  ...checkRolePermission('getAll'),
+  inputLimitController.getInputLimits
+);
+
+// Prepare a transaction of an offline identity; its client signs the returned digest (keys in an air-gapped HSM)
+router.post('/offline-transactions',
+  ...checkRolePermission('offlineSign'),
+  validateRequest(['method']),
+  offlineSigningController.prepareTransaction
+);
+
+// Submit the client's signature of the current stage (proposal, transaction, commit) of an offline-signed transaction
+router.post('/offline-transactions/:transactionId/signatures',
+  ...checkRolePermission('offlineSign'),
+  validateParams(['transactionId']),
+  validateRequest(['stage', 'bytes', 'signature']),
+  offlineSigningController.submitSignature
+);
+
+// Anchor a weather observation of a plot
+writeRoute('post', '/weather',
+  ...checkRolePermission('weatherAnchor'),
+  validateRequest(['plotId', 'periodStart', 'periodEnd', 'source', 'summary']),
+  weatherController.anchorWeatherData
+);
+
+// Get the weather observations of a plot overlapping a time range
+router.get('/weather/plot/:plotId',
+  ...checkRolePermission('getById'),
+  validateParams(['plotId']),
+  weatherController.getPlotObservations
+);
+
+// Check raw feed data against an anchored observation
+router.post('/weather/:dataHash/verify',
+  ...checkRolePermission('getById'),
+  validateParams(['dataHash']),
+  validateRequest(['data']),
+  weatherController.verifyWeatherData
+);
+
+// Get a weather observation by its data hash
+router.get('/weather/:dataHash',
+  ...checkRolePermission('getById'),
+  validateParams(['dataHash']),
+  weatherController.getObservation
+);
+
+/**
+ * Anchored document routes
+ */
+
+// Check a PDF (application/pdf body) or { documentHash } against the anchored documents
+router.post('/documents/verify',
+  limitRate,
+  express.raw({ type: 'application/pdf', limit: '10mb' }),
+  ...checkRolePermission('getById'),
+  documentController.verifyDocument
+);
+
+// Get the documents issued about a batch or product
+router.get('/documents/entity/:entityId',
+  ...checkRolePermission('getById'),
+  validateParams(['entityId']),
+  documentController.getEntityDocuments
+);
+
+// Acknowledge having received and reviewed a test report, attachment or anchored document of a batch or product
+writeRoute('post', '/documents/entity/:entityId/acknowledgments',
+  ...checkRolePermission('acknowledge'),
+  validateParams(['entityId']),
+  validateRequest(['documentHash']),
+  documentController.acknowledgeDocument
+);
+
+// Get who acknowledged the documents of a batch or product
+router.get('/documents/entity/:entityId/acknowledgments',
+  ...checkRolePermission('getById'),
+  validateParams(['entityId']),
+  documentController.getAcknowledgments
+);
+
+// Get an anchored document by ID
+router.get('/documents/:documentId',
+  ...checkRolePermission('getById'),
+  validateParams(['documentId']),
+  documentController.getDocument
+);
+
+// Attach a typed document to a batch or product
+writeRoute('post', '/attachments/:entityId',
+  ...checkRolePermission('attach'),
+  validateParams(['entityId']),
+  validateRequest(['category', 'title', 'fileHash', 'mimeType']),
+  attachmentController.addAttachment
+);
+
+// Upload a file to IPFS and attach it to a batch or product. Not a write route: a simulation would still store
+// the file on IPFS
+router.post('/attachments/:entityId/upload',
+  ...checkRolePermission('attach'),
+  validateParams(['entityId']),
+  attachmentController.upload.single('file'),
+  attachmentController.uploadAttachment
+);
+
+// List the attachments of a batch or product
+router.get('/attachments/:entityId',
+  ...checkRolePermission('getById'),
+  validateParams(['entityId']),
+  attachmentController.listAttachments
+);
+
+// Stream the content of an attachment stored on IPFS, checked against its recorded hash
+router.get('/attachments/:entityId/:attachmentId/content',
+  ...checkRolePermission('getById'),
+  validateParams(['entityId', 'attachmentId']),
+  attachmentController.getAttachmentContent
+);
+
+// Get an attachment of a batch or product
+router.get('/attachments/:entityId/:attachmentId',
+  ...checkRolePermission('getById'),
+  validateParams(['entityId', 'attachmentId']),
+  attachmentController.getAttachment
+);
+
+// Register processing equipment operated by the caller's organization
+writeRoute('post', '/equipment',
+  ...checkRolePermission('equipment'),
+  validateRequest(['equipmentId', 'equipmentType', 'name', 'location', 'lastCalibrationDate', 'nextCalibrationDue']),
+  equipmentController.registerEquipment
+);
+
+// Record a calibration of a piece of equipment
+writeRoute('post', '/equipment/:equipmentId/calibrations',
+  ...checkRolePermission('equipment'),
+  validateParams(['equipmentId']),
+  validateRequest(['calibratedAt', 'nextCalibrationDue']),
+  equipmentController.recordCalibration
+);
+
+// Record maintenance of a piece of equipment
+writeRoute('post', '/equipment/:equipmentId/maintenance',
+  ...checkRolePermission('equipment'),
+  validateParams(['equipmentId']),
+  validateRequest(['maintainedAt', 'description']),
+  equipmentController.recordMaintenance
+);
+
+// Get the batches processed on a piece of equipment in a time range
+router.get('/equipment/:equipmentId/usage',
+  ...checkRolePermission('getById'),
+  validateParams(['equipmentId']),
+  equipmentController.getEquipmentUsage
+);
+
+// Get a piece of equipment with its calibration and maintenance log
+router.get('/equipment/:equipmentId',
+  ...checkRolePermission('getById'),
+  validateParams(['equipmentId']),
+  equipmentController.getEquipment
+);
+
+// Register or update a facility (site) operated by the caller's organization, with its lines and shifts
+writeRoute('put', '/facilities/:facilityId',
+  ...checkRolePermission('equipment'),
+  validateParams(['facilityId']),
+  validateRequest(['name', 'facilityType', 'location']),
+  facilityController.registerFacility
+);
+
+// Get the processing steps recorded at a facility, by time range, line and shift
+router.get('/facilities/:facilityId/activity',
+  ...checkRolePermission('getById'),
+  validateParams(['facilityId']),
+  facilityController.getFacilityActivity
+);
+
+// Get a facility with its lines and shifts
+router.get('/facilities/:facilityId',
+  ...checkRolePermission('getById'),
+  validateParams(['facilityId']),
+  facilityController.getFacility
+);
+
+// Create or update the geographic indication rule of a protected origin
+writeRoute('put', '/gi/:giId',
+  ...checkRolePermission('giRule'),
+  validateParams(['giId']),
+  validateRequest(['name', 'allowedRegions']),
+  giController.defineRule
+);
+
+// Get all geographic indication rules
+router.get('/gi',
+  ...checkRolePermission('getAll'),
+  giController.getAllRules
+);
+
+// Get a geographic indication rule
+router.get('/gi/:giId',
+  ...checkRolePermission('getById'),
+  validateParams(['giId']),
+  giController.getRule
+);
+
+// Create or update the compliance profile of an export market
+writeRoute('put', '/compliance-profiles/:market',
+  ...checkRolePermission('complianceProfile'),
+  validateParams(['market']),
+  validateRequest(['name', 'countries']),
+  complianceController.defineProfile
+);
+
+// Get all compliance profiles
+router.get('/compliance-profiles',
+  ...checkRolePermission('getAll'),
+  complianceController.getAllProfiles
+);
+
+// Get the compliance profile of an export market
+router.get('/compliance-profiles/:market',
+  ...checkRolePermission('getById'),
+  validateParams(['market']),
+  complianceController.getProfile
+);
+
+// Configure the country the supply chain operates in; shipments elsewhere are exports
+writeRoute('put', '/domestic-market',
+  ...checkRolePermission('complianceProfile'),
+  validateRequest(['country']),
+  complianceController.defineDomesticMarket
+);
+
+// Get the domestic market
+router.get('/domestic-market',
+  ...checkRolePermission('getAll'),
+  complianceController.getDomesticMarket
+);
+
+// Register or replace the notification preferences of a participant of the caller's organization
+writeRoute('put', '/notifications/preferences/:participantId',
+  ...checkRolePermission('notifications'),
+  validateParams(['participantId']),
+  validateRequest(['channels']),
+  notificationController.setPreference
+);
+
+// Get the notification preferences of a participant
+router.get('/notifications/preferences/:participantId',
+  ...checkRolePermission('notifications'),
+  validateParams(['participantId']),
+  notificationController.getPreference
+);
+
+// Remove the notification preferences of a participant
+writeRoute('delete', '/notifications/preferences/:participantId',
+  ...checkRolePermission('notifications'),
+  validateParams(['participantId']),
+  notificationController.removePreference
+);
+
+// Onboard a participant: register and enroll it with its organization's CA, store the identity and register it
+// on the ledger. Not simulated: the CA registration cannot be dry-run
+router.post('/participants',
+  ...checkRolePermission('enroll'),
+  validateRequest(['participantId', 'name', 'participantRole']),
+  participantController.onboardParticipant
+);
+
+// Get a registered participant
+router.get('/participants/:participantId',
+  ...checkRolePermission('getAll'),
+  validateParams(['participantId']),
+  participantController.getParticipant
+);
+
+// Get the transfers out of a farmer's batches with their settlement statuses and the organization's private terms
+router.get('/participants/:participantId/settlement-report',
+  ...checkRolePermission('settlement'),
+  validateParams(['participantId']),
+  settlementController.getFarmerSettlementReport
+);
+
+// Prepare an export consignment of batches and products
+writeRoute('post', '/consignments',
+  ...checkRolePermission('consignment'),
+  validateRequest(['consignmentId', 'destinationCountry']),
+  consignmentController.createConsignment
+);
+
+// Move a consignment to its next status (Inspected, Cleared, Shipped)
+writeRoute('post', '/consignments/:consignmentId/status',
+  ...checkRolePermission('consignment'),
+  validateParams(['consignmentId']),
+  validateRequest(['status']),
+  consignmentController.advanceConsignment
+);
+
+// Hand every batch and product of a cleared consignment over to the importer, chunk by chunk
+writeRoute('post', '/consignments/:consignmentId/transfer',
+  ...checkRolePermission('consignment'),
+  validateParams(['consignmentId']),
+  validateRequest(['newOwner']),
+  consignmentController.transferConsignment
+);
+
+// Get the consignments a batch or product was exported in (must be placed before dynamic routes)
+router.get('/consignments/entity/:entityId',
+  ...checkRolePermission('getById'),
+  validateParams(['entityId']),
+  consignmentController.getConsignmentsByEntity
+);
+
+// Get a consignment with its status history
+router.get('/consignments/:consignmentId',
+  ...checkRolePermission('getById'),
+  validateParams(['consignmentId']),
+  consignmentController.getConsignment
+);
+
+// Issue a recall of batches and products; the current owners are notified through the event bridge
+writeRoute('post', '/recalls',
+  ...checkRolePermission('recall'),
+  validateRequest(['recallId', 'reason']),
+  recallController.issueRecall
+);
+
+// Get the recalls covering a batch or product (must be placed before dynamic routes)
+router.get('/recalls/entity/:entityId',
+  ...checkRolePermission('getById'),
+  validateParams(['entityId']),
+  recallController.getRecallsByEntity
+);
+
+// Find the batches and products reached by a contaminated agro-chemical (?input=chemical name or input lot ID)
+router.get('/recalls/input-exposure',
+  ...checkRolePermission('recall'),
+  recallController.findInputExposure
+);
+
+// Get a recall with its owner notices
+router.get('/recalls/:recallId',
+  ...checkRolePermission('getById'),
+  validateParams(['recallId']),
+  recallController.getRecall
+);
+
+// Confirm that a notified owner has pulled recalled stock (ownerId, productIds?, disposition)
+writeRoute('post', '/recalls/:recallId/acknowledgments',
+  ...checkRolePermission('acknowledge'),
+  validateParams(['recallId']),
+  validateRequest(['ownerId', 'disposition']),
+  recallController.acknowledgeRecall
+);
+
+// Get the acknowledgments of a recall
+router.get('/recalls/:recallId/acknowledgments',
+  ...checkRolePermission('getById'),
+  validateParams(['recallId']),
+  recallController.getRecallAcknowledgments
+);
+
+// Get the completion dashboard of a recall: which notified owners have responded and which have not
+router.get('/recalls/:recallId/completion',
+  ...checkRolePermission('getById'),
+  validateParams(['recallId']),
+  recallController.getRecallCompletion
+);
+
+// List the named queries of the chaincode's query catalog
+router.get('/queries',
+  ...checkRolePermission('getAll'),
+  queryController.listQueries
+);
+
+// Run a named query (batchesByOwner, failedTestsSince, productsExpiringBefore) with its parameters in the query string
+router.get('/queries/:name',
+  ...checkRolePermission('getAll'),
+  validateParams(['name']),
+  queryController.runQuery
+);
+
+// Get the oracle-recorded price series of a variety in a region
+router.get('/prices/:variety/:region',
+  ...checkRolePermission('getAll'),
+  validateParams(['variety', 'region']),
+  priceController.getPriceSeries
+);
+
+// Get the price in force on a day (latest recorded on or before it)
+router.get('/prices/:variety/:region/reference',
+  ...checkRolePermission('getById'),
+  validateParams(['variety', 'region']),
+  priceController.getReferencePrice
+);
+
+// Get the price recorded for a day
+router.get('/prices/:variety/:region/:date',
+  ...checkRolePermission('getById'),
+  validateParams(['variety', 'region', 'date']),
+  priceController.getMarketPrice
+);
+
+// Get the recorded reads of test reports and commercial terms by an organization (regulator only)
+router.get('/audit/access-log/:mspId',
+  ...checkRolePermission('accessLog'),
+  validateParams(['mspId']),
+  auditController.getAccessLog
+);
+
+// List the alerts raised by the event bridge's anomaly detection rules, and describe the rules
+router.get('/audit/anomalies',
+  ...checkRolePermission('anomalies'),
+  anomalyController.getAlerts
+);
+
+router.get('/audit/anomalies/rules',
+  ...checkRolePermission('anomalies'),
+  anomalyController.getRules
+);
+
+// Get the retention policies of private data of all organizations
+router.get('/retention-policies',
+  ...checkRolePermission('getAll'),
+  retentionController.getRetentionPolicies
+);
+
+// Set how long the caller's organization keeps private test report details or commercial terms
+writeRoute('put', '/retention-policies/:dataType',
+  ...checkRolePermission('retention'),
+  validateParams(['dataType']),
+  validateRequest(['retentionDays']),
+  retentionController.defineRetentionPolicy
+);
+
+// Purge the caller's organization's private data past its retention period or older than a cutoff
+writeRoute('post', '/retention-policies/:dataType/purge',
+  ...checkRolePermission('retention'),
+  validateParams(['dataType']),
+  retentionController.purgePrivateData
+);
+
+// Register the brand (name, logo, story) the caller's organization presents to consumers (organization administrators)
+writeRoute('put', '/organizations/branding',
+  ...checkRolePermission('branding'),
+  validateRequest(['brandName']),
+  brandingController.setBranding
+);
+
+// Get the brands of all organizations, or of one
+router.get('/organizations/branding',
+  ...checkRolePermission('getAll'),
+  brandingController.getAllBrandings
+);
+
+router.get('/organizations/:mspId/branding',
+  ...checkRolePermission('getAll'),
+  validateParams(['mspId']),
+  brandingController.getBranding
+);
+
+// Set or remove the Chinese and English labels of a field name or recorded value (organization administrators)
+writeRoute('put', '/translations/:category/:code',
+  ...checkRolePermission('translate'),
+  validateParams(['category', 'code']),
+  translationController.setTranslation
+);
+
+writeRoute('delete', '/translations/:category/:code',
+  ...checkRolePermission('translate'),
+  validateParams(['category', 'code']),
+  translationController.removeTranslation
+);
+
+// Get the translations on the ledger
+router.get('/translations',
+  ...checkRolePermission('getById'),
+  translationController.getTranslations
+);
+
+// Get the labels of a locale, built-in labels included
+router.get('/translations/dictionary/:locale',
+  ...checkRolePermission('getById'),
+  validateParams(['locale']),
+  translationController.getDictionary
+);
+
+// Draw batches for spot checks, weighted by failed tests and new owners (regulator only)
+writeRoute('post', '/inspections/selections',
+  ...checkRolePermission('inspection'),
+  validateRequest(['count', 'seedTxId']),
+  inspectionController.selectBatches
+);
+
+// Get all spot-check draws
+router.get('/inspections/selections',
+  ...checkRolePermission('getAll'),
+  inspectionController.getSelections
+);
+
+// Get a spot-check draw by its seed
+router.get('/inspections/selections/:seedTxId',
+  ...checkRolePermission('getById'),
+  validateParams(['seedTxId']),
+  inspectionController.getSelection
+);
+
+/**
+ * API key routes (administrators)
+ */
+
+// Issue an API key for the public trace and verification endpoints ({ name, requestsPerMinute?, dailyQuota? })
+router.post('/api-keys',
+  ...checkRolePermission('apiKeys'),
+  validateRequest(['name']),
+  apiKeyController.createApiKey
+);
+
+// List API key clients with today's usage
+router.get('/api-keys',
+  ...checkRolePermission('apiKeys'),
+  apiKeyController.listApiKeys
+);
+
+// Change the limits of an API key
+router.patch('/api-keys/:clientId',
+  ...checkRolePermission('apiKeys'),
+  validateParams(['clientId']),
+  apiKeyController.updateApiKeyLimits
+);
+
+// Revoke an API key
+router.delete('/api-keys/:clientId',
+  ...checkRolePermission('apiKeys'),
+  validateParams(['clientId']),
+  apiKeyController.revokeApiKey
+);
+
+/**
+ * Event bridge routes (administrators)
+ */
+
+// Replay the chaincode events of a block or time range to Kafka or a configured webhook, e.g. after a sink outage
+router.post('/events/replay',
+  ...checkRolePermission('eventReplay'),
+  validateRequest(['sink']),
+  eventController.replayEvents
+);
+
 /**
  * Cache management routes (for debugging and maintenance)
  */
@@ -203,6 +1412,19 @@ router.delete('/cache/batch/:id',
   cacheController.invalidateBatchCache
 );
 
+/**
+ * GraphQL routes
+ */
+
+// Execute GraphQL query (permissions are checked per field)
+router.post('/graphql',
+  extractRole,
+  graphqlController.executeQuery
+);
+
+// Get GraphQL schema
+router.get('/graphql/schema', graphqlController.getSchema);
+
 /**
  * System information routes
  */
@@ -218,6 +1440,41 @@ router.get('/health', (req, res) => {
   });
 });
 
+// Chaincode connectivity and deployed contract version
+router.get('/health/chaincode',
+  extractRole,
+  batchController.getChaincodeStatus
+);
+
+// Channels served by this API instance
+router.get('/channels', (req, res) => {
+  const { fabric } = require('../../config');
+
+  res.json({
+    success: true,
+    data: {
+      defaultChannel: fabric.channelName,
+      channels: Object.values(fabric.channels)
+    },
+    timestamp: new Date().toISOString()
+  });
+});
+
+// Identities requests can act as; key material and HSM PINs are never returned
+router.get('/identities', (req, res) => {
+  const { fabric } = require('../../config');
+
+  res.json({
+    success: true,
+    data: Object.values(fabric.identities).map(identity => ({
+      name: identity.name,
+      role: identity.role,
+      signer: identity.hsm ? 'hsm' : 'file'
+    })),
+    timestamp: new Date().toISOString()
+  });
+});
+
 // API information
 router.get('/info', (req, res) => {
   const { getAvailableRoles, permissions } = require('../../config');
@@ -234,21 +1491,238 @@ router.get('/info', (req, res) => {
         batch: [
           'GET /api/batch - Get all batches',
           'POST /api/batch - Create batch',
-          'GET /api/batch/:id - Get specified batch',
+          'POST /api/batch/ids - Draw the next batch ID of the caller\'s organization under the ID policy',
+          'GET /api/batch/:id - Get specified batch (?locale=zh|en adds labels)',
           'GET /api/batch/:id/exists - Check if batch exists',
           'PUT /api/batch/:id/transfer - Transfer batch ownership',
           'POST /api/batch/:id/test - Add quality inspection result',
+          'GET /api/batch/:id/test/:testId/verify-hash - Check a report file against its registered hash',
+          'POST /api/batch/:id/test/:testId/revoke - Withdraw a test result recorded by the caller',
+          'POST /api/batch/:id/test/:testId/residues - Record the residue levels (mg/kg) a test measured',
+          'POST /api/batch/:id/test/:testId/moisture - Record the moisture content (%) a moisture test measured',
+          'PUT /api/batch/:id/test/:testId/report-details - Privately share the full report of a test with the organization it was made for ({ counterpartyMspId, details })',
+          'GET /api/batch/:id/test/:testId/report-details - Get the full report of a test shared with the organization',
           'POST /api/batch/:id/process - Add processing record',
-          'GET /api/batch/stats - Get batch statistics'
+          'GET /api/batch/stats - Get batch statistics',
+          'GET /api/batch/stats/daily - Get recorded daily activity statistics',
+          'GET /api/batch/stats/network - Get network-level KPIs for operator reporting (?activeDays=90)',
+          'GET /api/batch/stats/seasons - Compare a season year over year (?season=&from=&to=)',
+          'GET /api/batch/stats/seasons/:cropYear - Get the aggregates of a crop year (?season=)',
+          'GET /api/batch/stats/quality-trends - Get failure rate, average moisture and grades month by month (?variety=&region=&period=<start>/<end>)',
+          'GET /api/batch/step/:step - Get batches currently at a processing step',
+          'GET /api/batch/status/:status - Get batches with a derived status (Active, Quarantined, Recalled, Finalized, Archived, Disposed)',
+          'GET /api/batch/label/:key - Get batches carrying a label (?value=)',
+          'GET /api/batch/inventory/:owner - Get an owner\'s batches with remaining quantities, products and totals',
+          'GET /api/batch/compare - Compare 2 to 10 lots side by side: origin, variety, quality tests, grades, certificates (?ids=)',
+          'GET /api/batch/export - Export a filtered batch list (?format=csv|xlsx)',
+          'GET /api/batch/search - Free-text batch search over origin, variety, owner and operators (?q=&fields=&limit=)',
+          'GET /api/batch/:id/history/diff - Get the fields a transaction changed in a batch (?to=txId, optional from=txId)',
+          'GET /api/batch/:id/transactions - List the ledger transactions that touched a batch (?limit=&offset=)',
+          'GET /api/batch/:id/history/export - Export a batch\'s history and test results (?format=csv|xlsx)',
+          'GET /api/batch/:id/audit-package - Export an ISO 22005 traceability audit package (?format=json|xlsx)',
+          'POST /api/batch/:id/certificate - Issue a PDF traceability certificate and anchor its hash',
+          'GET /api/batch/:id/qr - QR code label of the batch trace URL (?format=png|svg&size=)',
+          'GET /api/batch/:id/qr-sheet - Printable A4 sheet of numbered sack labels (?count=&columns=)',
+          'PUT /api/batch/:id/terms - Privately attach commercial terms to an owned batch',
+          'GET /api/batch/:id/terms - Get own organization\'s commercial terms for a batch',
+          'PUT /api/batch/:id/labels - Replace the labels of a batch',
+          'POST /api/batch/:id/delegates - Let another identity transfer or process a batch on the farmer\'s behalf',
+          'POST /api/batch/:id/delegates/:delegationId/revoke - Revoke a delegation',
+          'PUT /api/batch/:id/quantity - Declare the quantity of a batch in kg',
+          'POST /api/batch/:id/weight-adjustments - Record the weight a batch lost in a processing step, e.g. drying',
+          'POST /api/batch/:id/reservations - Reserve quantity of a batch for a pending sale',
+          'POST /api/batch/:id/reservations/:reservationId/release - Release a reservation',
+          'GET /api/batch/:id/reservations - Get the reservations of a batch and the quantity available',
+          'POST /api/batch/:id/scheduled-transfers - Schedule the handover of a batch for a contractual date ({ newOwner, effectiveTime })',
+          'POST /api/batch/:id/scheduled-transfers/activate - Activate the scheduled transfer of a batch once its effective time is reached',
+          'POST /api/batch/:id/scheduled-transfers/cancel - Cancel the pending scheduled transfer of a batch',
+          'GET /api/batch/:id/scheduled-transfers - Get the scheduled transfers of a batch',
+          'GET /api/batch/scheduled-transfers/due - Get the scheduled transfers due for activation',
+          'POST /api/batch/:id/processing-records - Add a run of processing records in one transaction (all or none)',
+          'GET /api/batch/:id/resource-usage - Get own organization\'s private resource usage of a batch\'s processing steps',
+          'GET /api/resource-usage/summary - Sum own organization\'s resource usage per processing step (?period=<start>/<end>)',
+          'POST /api/batch/:id/history/:index/corrections - Correct the step or report of a mistyped processing record',
+          'PUT /api/batch/:id/history/:index/settlement - Record that a handover was invoiced, paid or disputed ({ status, reference? })',
+          'GET /api/batch/:id/history/:index/settlement - Get the settlement of a handover with its status changes',
+          'POST /api/batch/:id/photos - Add a photo to the photo journal of a batch ({ stage, caption?, fileHash, uri })',
+          'GET /api/batch/:id/photos - Get the photo journal of a batch',
+          'POST /api/batch/:id/gi-check - Check a batch against a geographic indication rule',
+          'GET /api/batch/:id/export-compliance - Check a batch against the compliance profile of an export market (?market=)',
+          'POST /api/batch/:id/foreign-references - Reference a batch committed on another channel',
+          'GET /api/batch/:id/foreign-references/:channel/:foreignBatchId/verify - Check a foreign batch against its linked state',
+          'GET /api/batch/:id/state-hash - Get the state hash cited by references from other channels',
+          'GET /api/batch/:id/genealogy - Get the ancestor/descendant graph of a batch (?depth=)',
+          'GET /api/batch/:id/traceability-score - Get how completely a batch is documented (0-100, per criterion)',
+          'GET /api/batch/well-documented - Get the batches scoring at least a minimum traceability score (?minScore=)',
+          'GET /api/batch/:id/storage-usage - Get the storage a batch uses against the batch storage limits',
+          'POST /api/batch/:id/insurance - Attach a crop, storage or transport insurance policy to a batch',
+          'POST /api/batch/:id/insurance/claims - File an insurance claim citing the batch\'s on-chain evidence'
         ],
         product: [
           'POST /api/product - Create product',
-          'GET /api/product/:id - Get product information',
+          'POST /api/product/ids - Draw the next product ID of the caller\'s organization under the ID policy',
+          'PUT /api/product/:id/nutrition - Set product nutrition facts and composition',
+          'PUT /api/product/:id/labels - Replace the labels of a product',
+          'GET /api/product/label/:key - Get products carrying a label (?value=)',
+          'GET /api/product/:id - Get product information (?locale=zh|en adds labels)',
           'GET /api/product/:id/exists - Check if product exists',
-          'GET /api/product/:id/traceability - Get product traceability'
+          'GET /api/product/:id/traceability - Get product traceability',
+          'GET /api/product/:id/origins - Get the origins of the rice in a product with their shares',
+          'GET /api/product/:id/chain-validation - Check the trace chain of a product for gaps (custody, order, tests, operators)',
+          'GET /api/product/:id/transactions - List the ledger transactions that touched a product (?limit=&offset=)',
+          'GET /api/product/owner/:owner - Get products held by an owner (paginated)',
+          'GET /api/product/query - Query products by owner, batchId, status and package date range (paginated)',
+          'POST /api/product/:id/return - Return a sold product to its distributor',
+          'POST /api/product/:id/verification-code - Register the verification code printed on a product package',
+          'POST /api/product/:id/qr - Generate a QR code label with a newly registered verification code (?format=png|svg&size=)',
+          'POST /api/product/:id/certificate - Issue a PDF traceability certificate and anchor its hash',
+          'POST /api/product/:id/verify - Check the code on a product package (attempts are counted)',
+          'GET /api/product/:id/verification - Get the verification attempt counters and lockout of a product'
+        ],
+        trace: [
+          'GET /api/trace/:productId - Public, cacheable trace of a product for mobile apps (?locale=zh|en, ?lang= or Accept-Language)'
+        ],
+        epcis: [
+          'POST /api/epcis/capture - Import an EPCIS 2.0 capture document',
+          'GET /api/epcis/units/:id - Get a logistics unit built from AggregationEvents',
+          'GET /api/epcis/shipments/:id - Get a shipment imported from a shipping ObjectEvent'
+        ],
+        offlineSigning: [
+          'POST /api/offline-transactions - Prepare a transaction of an offline identity and return the proposal digest to sign',
+          'POST /api/offline-transactions/:transactionId/signatures - Submit the signature of a stage (proposal, transaction, commit) and get the next digest'
+        ],
+        identifiers: [
+          'GET /api/id-policy - Get the ID policy batch and product IDs must follow',
+          'GET /api/input-limits - Get the size limits applied to reports, labels and bulk lists'
+        ],
+        integrity: [
+          'GET /api/integrity - Report references to missing batches, unregistered participants and stale genealogy edges (organization administrator identity)'
+        ],
+        weather: [
+          'POST /api/weather - Anchor a weather observation of a plot by the hash of its feed data',
+          'GET /api/weather/plot/:plotId - Get a plot\'s weather observations overlapping a time range (?from=&to=)',
+          'GET /api/weather/:dataHash - Get a weather observation',
+          'POST /api/weather/:dataHash/verify - Check raw feed data against an anchored observation'
+        ],
+        documents: [
+          'POST /api/documents/verify - Check a PDF (application/pdf) or { documentHash } against the anchored documents',
+          'GET /api/documents/entity/:entityId - Get the documents issued about a batch or product',
+          'POST /api/documents/entity/:entityId/acknowledgments - Acknowledge having received and reviewed a test report, attachment or anchored document ({ documentHash, note? })',
+          'GET /api/documents/entity/:entityId/acknowledgments - Get who acknowledged the documents of a batch or product (?documentHash=)',
+          'GET /api/documents/:documentId - Get an anchored document, e.g. by certificate number'
+        ],
+        attachments: [
+          'POST /api/attachments/:entityId - Attach a photo, lab report, certificate, contract or customs document to a batch or product',
+          'POST /api/attachments/:entityId/upload - Upload a file to IPFS and attach it (multipart: file, category, title, metadata)',
+          'GET /api/attachments/:entityId - List the attachments of a batch or product (?category=)',
+          'GET /api/attachments/:entityId/:attachmentId - Get an attachment',
+          'GET /api/attachments/:entityId/:attachmentId/content - Stream the content of an attachment stored on IPFS'
+        ],
+        equipment: [
+          'POST /api/equipment - Register a dryer, mill, color sorter or packaging line',
+          'POST /api/equipment/:equipmentId/calibrations - Record a calibration and when the next one is due',
+          'POST /api/equipment/:equipmentId/maintenance - Record maintenance',
+          'GET /api/equipment/:equipmentId - Get equipment with its calibration and maintenance log',
+          'GET /api/equipment/:equipmentId/usage - Get the batches processed on the equipment (?from=&to=)'
+        ],
+        facilities: [
+          'PUT /api/facilities/:facilityId - Register or update a drying yard, mill, warehouse or packing plant with its lines and shifts',
+          'GET /api/facilities/:facilityId - Get a facility with its lines and shifts',
+          'GET /api/facilities/:facilityId/activity - Get the steps recorded at the facility (?from=&to=&line=&shift=)'
+        ],
+        gi: [
+          'PUT /api/gi/:giId - Create or update the geographic indication rule of a protected origin (admin only)',
+          'GET /api/gi - Get all geographic indication rules',
+          'GET /api/gi/:giId - Get a geographic indication rule'
+        ],
+        compliance: [
+          'PUT /api/compliance-profiles/:market - Create or update the compliance profile of an export market (admin only)',
+          'GET /api/compliance-profiles - Get all compliance profiles',
+          'GET /api/compliance-profiles/:market - Get the compliance profile of an export market',
+          'PUT /api/domestic-market - Configure the domestic market; shipments to other countries are exports (admin only)',
+          'GET /api/domestic-market - Get the domestic market'
+        ],
+        consignments: [
+          'POST /api/consignments - Prepare an export consignment of batches and products',
+          'POST /api/consignments/:consignmentId/status - Record inspection, customs clearance or shipment of a consignment',
+          'POST /api/consignments/:consignmentId/transfer - Transfer every batch and product of a cleared consignment to the importer',
+          'GET /api/consignments/entity/:entityId - Get the consignments a batch or product was exported in',
+          'GET /api/consignments/:consignmentId - Get a consignment with its status history'
+        ],
+        recalls: [
+          'POST /api/recalls - Issue a recall of batches and products and notify their current owners',
+          'GET /api/recalls/entity/:entityId - Get the recalls covering a batch or product',
+          'GET /api/recalls/input-exposure - Find the batches and products reached by an agro-chemical (?input=chemical or lot ID)',
+          'GET /api/recalls/:recallId - Get a recall with its owner notices',
+          'POST /api/recalls/:recallId/acknowledgments - Confirm that a notified owner has pulled recalled stock',
+          'GET /api/recalls/:recallId/acknowledgments - Get the acknowledgments of a recall',
+          'GET /api/recalls/:recallId/completion - Get which notified owners have and have not responded to a recall'
+        ],
+        notifications: [
+          'PUT /api/notifications/preferences/:participantId - Register the events and channels a participant is notified of',
+          'GET /api/notifications/preferences/:participantId - Get the notification preferences of a participant',
+          'DELETE /api/notifications/preferences/:participantId - Remove the notification preferences of a participant'
+        ],
+        participants: [
+          'POST /api/participants - Enroll a participant with its organization\'s CA and register it on the ledger',
+          'GET /api/participants/:participantId - Get a registered participant',
+          'GET /api/participants/:participantId/settlement-report - Get the transfers out of a farmer\'s batches with settlement statuses and own prices (?period=2024, 2024-Late or <start>/<end>)'
+        ],
+        queries: [
+          'GET /api/queries - List the named queries and their parameters',
+          'GET /api/queries/:name - Run a named query, e.g. batchesByOwner?owner=, failedTestsSince?since=, productsExpiringBefore?before= (&pageSize=&bookmark=)'
+        ],
+        prices: [
+          'GET /api/prices/:variety/:region - Get the oracle-recorded price series (?from=&to=)',
+          'GET /api/prices/:variety/:region/reference - Get the price in force on a day (?date=&maxAgeDays=)',
+          'GET /api/prices/:variety/:region/:date - Get the price recorded for a day'
+        ],
+        apiKeys: [
+          'POST /api/api-keys - Issue an API key for the public trace and verification endpoints (admin only)',
+          'GET /api/api-keys - List API key clients with today\'s usage (admin only)',
+          'PATCH /api/api-keys/:clientId - Change the rate limit and daily quota of an API key (admin only)',
+          'DELETE /api/api-keys/:clientId - Revoke an API key (admin only)'
+        ],
+        events: [
+          'POST /api/events/replay - Replay the chaincode events of a block or time range to Kafka or a configured webhook (admin only)'
+        ],
+        audit: [
+          'GET /api/audit/access-log/:mspId - Get an organization\'s reads of test reports and commercial terms (regulator only, ?from=&to=)',
+          'GET /api/audit/anomalies - List the alerts of the anomaly detection rules, newest first (?batchId=&limit=&offset=)',
+          'GET /api/audit/anomalies/rules - Describe the anomaly detection rules'
+        ],
+        retention: [
+          'GET /api/retention-policies - Get the retention periods of private data of all organizations',
+          'PUT /api/retention-policies/:dataType - Set how long the organization keeps testReportDetails or commercialTerms ({ retentionDays }; organization administrators)',
+          'POST /api/retention-policies/:dataType/purge - Purge private data past its retention period or older than { olderThan }; hashes remain (organization administrators)'
+        ],
+        branding: [
+          'PUT /api/organizations/branding - Register the organization\'s brand shown in consumer traces ({ brandName, logoHash?, logoUri?, story? }; organization administrators)',
+          'GET /api/organizations/branding - Get the brands of all organizations',
+          'GET /api/organizations/:mspId/branding - Get the brand of an organization'
+        ],
+        translations: [
+          'PUT /api/translations/:category/:code - Set the labels of a field name or recorded value ({ zh?, en? }; category field or value; organization administrators)',
+          'DELETE /api/translations/:category/:code - Remove the labels of a field name or recorded value',
+          'GET /api/translations - Get the translations on the ledger (?category=)',
+          'GET /api/translations/dictionary/:locale - Get the labels of a locale, built-in labels included'
+        ],
+        inspections: [
+          'POST /api/inspections/selections - Draw batches for spot checks weighted by risk, seeded by a committed transaction ID ({ count, seedTxId }; regulator only)',
+          'GET /api/inspections/selections - Get all spot-check draws, most recent first',
+          'GET /api/inspections/selections/:seedTxId - Get a spot-check draw with the weights of all candidates'
+        ],
+        graphql: [
+          'POST /api/graphql - Execute GraphQL query over batches, products and history',
+          'GET /api/graphql/schema - Get GraphQL schema'
         ],
         system: [
           'GET /api/health - Health check',
+          'GET /api/health/chaincode - Chaincode connectivity and deployed version',
+          'GET /api/channels - Channels served by this instance (select one with the X-Channel header)',
+          'GET /api/identities - Identities requests can act as (select one with the X-Fabric-Identity header)',
+          'POST|PUT <write endpoint>/simulate - Dry run of any write endpoint (evaluated, not committed)',
+          'POST /api/v2/batch/:id/event/check - List every transfer rule a step and transfer would break',
           'GET /api/info - API information'
         ]
       }
@@ -0,0 +1,62 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { organizations, accessAudit, errorCodes } = require('../../config');
+
+/**
+ * Access audit service layer
+ * Records reads of sensitive views on the ledger, in a private collection shared only by the reading organization
+ * and the regulator, and serves the resulting audit trail to the regulator
+ */
+class AccessAuditService {
+
+  /**
+   * Record a read of a sensitive view
+   * What was read is passed as transient data, so it never appears in a block
+   * @param {string} role - Caller role
+   * @param {string} resourceType - testReport | commercialTerms
+   * @param {string} resourceId - Report ID or batch ID
+   * @param {string} [purpose] - Reason for the access given by the reader
+   * @returns {Promise<Object>} Recorded access log entry
+   */
+  async recordAccess(role, resourceType, resourceId, purpose = '') {
+    const organization = organizations[role];
+    if (!organization) {
+      throw new Error(`${errorCodes.PERMISSION_DENIED}: Role '${role}' does not belong to an organization, so its access cannot be audited`);
+    }
+
+    try {
+      const entry = await fabricDAO.submitAsyncTransaction(role, 'AccessAuditContract:RecordAccess', {
+        transientData: { access: JSON.stringify({ resourceType, resourceId, purpose, accessedBy: role }) },
+        // The entry is private data of the caller's organization, so only its own peer can endorse it
+        endorsingOrganizations: [organization.mspId]
+      });
+      return JSON.parse(entry);
+    } catch (error) {
+      throw new Error(`Failed to record access to ${resourceType} ${resourceId}: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the reads recorded by an organization, oldest first
+   * @param {string} role - Caller role (must belong to the regulator organization)
+   * @param {string} mspId - Organization whose access log to read
+   * @param {string} [from] - Start date or time
+   * @param {string} [to] - End date (inclusive) or time
+   * @returns {Promise<Array>} Access log entries
+   */
+  async getAccessLog(role, mspId, from = '', to = '') {
+    if (!mspId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Organization MSP ID cannot be empty`);
+    }
+    if ((organizations[role] || {}).mspId !== accessAudit.regulatorMspId) {
+      throw new Error(`${errorCodes.PERMISSION_DENIED}: Only the regulator organization ${accessAudit.regulatorMspId} can read access logs`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'AccessAuditContract:GetAccessLog', mspId, from, to);
+    } catch (error) {
+      throw new Error(`Failed to get access log: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new AccessAuditService();
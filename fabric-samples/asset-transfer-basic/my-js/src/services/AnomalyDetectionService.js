@@ -0,0 +1,343 @@
+const fs = require('node:fs/promises');
+const path = require('node:path');
+const crypto = require('node:crypto');
+const { checkpointers } = require('@hyperledger/fabric-gateway');
+const fabricDAO = require('../dao/FabricDAO');
+const cacheService = require('./CacheService');
+const { runInChannel, currentChannel } = require('../dao/channelContext');
+const { anomalyDetection, eventBridge, errorCodes, getChannelConfig } = require('../../config');
+
+/**
+ * Handover events of a batch history: the batch changed hands between two parties. Superseded records are
+ * corrected away and do not count
+ */
+const handovers = batch => (batch.history || []).filter(event => event.from && event.to && event.from !== event.to && !event.supersededBy);
+
+/**
+ * Kilograms held by the active reservations of a batch that have not expired
+ */
+const reservedKg = (batch, now) => (batch.reservations || [])
+  .filter(reservation => reservation.status === 'Active' && Date.parse(reservation.expiresAt) > now)
+  .reduce((total, reservation) => total + reservation.quantityKg, 0);
+
+/**
+ * Detection rules
+ * Each rule names the chaincode events it inspects and returns the findings of one event: { severity, message, details }
+ * or null. evaluate receives the event message and a loader of batches from the ledger
+ */
+const RULES = {
+  excessiveTransfers: {
+    description: 'A batch changed hands more often than plausible for a supply chain',
+    events: ['BatchStepCompleted', 'ScheduledTransferActivated'],
+    evaluate: async ({ payload: batch }) => {
+      const events = handovers(batch);
+      const history = batch.history || [];
+      const latest = history[history.length - 1];
+      // Alert on the handover that crossed the limit and each one after it, not on later processing steps
+      if (events.length <= anomalyDetection.rules.maxTransfers || !latest || !events.includes(latest)) {
+        return null;
+      }
+      return {
+        severity: 'warning',
+        message: `Batch ${batch.batchId} was handed over ${events.length} times (plausible: ${anomalyDetection.rules.maxTransfers})`,
+        details: { transfers: events.length, parties: [...new Set(events.flatMap(event => [event.from, event.to]))] }
+      };
+    }
+  },
+  testAfterPackaging: {
+    description: 'A test result dated after its batch was packaged',
+    events: ['TestResultCreated'],
+    evaluate: async ({ payload: test }, loadBatch) => {
+      const batch = await loadBatch(test.batchId);
+      const packaged = batch && (batch.history || []).find(event => event.step === 'Packaged' && !event.supersededBy);
+      if (!packaged || Date.parse(test.testDate) <= Date.parse(packaged.timestamp)) {
+        return null;
+      }
+      return {
+        severity: 'warning',
+        message: `Test result ${test.testId} of batch ${test.batchId} is dated ${test.testDate}, after the batch was packaged at ${packaged.timestamp}`,
+        details: { testId: test.testId, testType: test.testType, testDate: test.testDate, packagedAt: packaged.timestamp }
+      };
+    }
+  },
+  quantityNearLimit: {
+    description: 'Reservations hold nearly all, or more than, the declared quantity of a batch',
+    events: ['BatchQuantityReserved', 'BatchWeightAdjusted'],
+    evaluate: async ({ payload: batch }) => {
+      if (!batch.quantityKg) {
+        return null;
+      }
+      const reserved = reservedKg(batch, Date.now());
+      const ratio = reserved / batch.quantityKg;
+      if (ratio < anomalyDetection.rules.reservedRatio) {
+        return null;
+      }
+      return {
+        severity: ratio > 1 ? 'high' : 'warning',
+        message: `Reservations hold ${reserved} kg of the ${batch.quantityKg} kg of batch ${batch.batchId} (${Math.round(ratio * 100)}%)`,
+        details: { quantityKg: batch.quantityKg, reservedKg: reserved }
+      };
+    }
+  }
+};
+
+/**
+ * Anomaly detection service
+ * Runs in the event bridge process: reads the chaincode events of the channel, applies the detection rules to each
+ * one and raises an alert for every finding. Alerts are stored in Redis, where the API serves them to the audit team,
+ * and POSTed to the audit team's webhooks. Findings are leads for an audit, not proof of fraud
+ */
+class AnomalyDetectionService {
+  constructor() {
+    this.events = null;
+    this.isRunning = false;
+    this.stats = {
+      events: 0,
+      alerts: 0,
+      alertDeliveriesFailed: 0,
+      ruleErrors: 0,
+      lastBlock: null
+    };
+  }
+
+  /**
+   * Start inspecting chaincode events
+   * Resumes from the last checkpoint; replayed events raise the same alerts, which are stored and sent only once
+   */
+  async start() {
+    if (this.isRunning) {
+      return;
+    }
+
+    await fs.mkdir(path.dirname(anomalyDetection.checkpointPath), { recursive: true });
+    const checkpointer = await checkpointers.file(anomalyDetection.checkpointPath);
+    await cacheService.connect();
+
+    const { chaincodeName } = getChannelConfig(anomalyDetection.channel);
+    const network = await fabricDAO.getNetwork(anomalyDetection.role, anomalyDetection.channel);
+    this.events = await network.getChaincodeEvents(chaincodeName, {
+      checkpoint: checkpointer,
+      startBlock: BigInt(anomalyDetection.startBlock)
+    });
+    this.isRunning = true;
+    console.log(`Anomaly detection inspecting ${chaincodeName} events on ${anomalyDetection.channel}`);
+
+    try {
+      for await (const event of this.events) {
+        await this._inspectEvent(event);
+        await checkpointer.checkpointChaincodeEvent(event);
+      }
+    } finally {
+      this.isRunning = false;
+    }
+  }
+
+  /**
+   * Stop inspecting
+   */
+  stop() {
+    if (this.events) {
+      this.events.close();
+      this.events = null;
+    }
+    this.isRunning = false;
+  }
+
+  /**
+   * Get detection status
+   */
+  getStatus() {
+    return {
+      isRunning: this.isRunning,
+      channel: anomalyDetection.channel,
+      rules: Object.keys(RULES),
+      ...this.stats
+    };
+  }
+
+  /**
+   * Describe the detection rules
+   * @returns {Array} { rule, description, events }
+   */
+  getRules() {
+    return Object.entries(RULES).map(([rule, { description, events }]) => ({ rule, description, events }));
+  }
+
+  /**
+   * List the alerts raised on the current channel, newest first
+   * @param {Object} [options] - { batchId, limit, offset }
+   * @returns {Promise<Object>} { alerts, total, limit, offset }
+   */
+  async getAlerts({ batchId, limit, offset } = {}) {
+    const pageSize = limit === undefined ? 50 : parseInt(limit, 10);
+    const start = offset === undefined ? 0 : parseInt(offset, 10);
+    if (!Number.isInteger(pageSize) || pageSize < 1 || pageSize > anomalyDetection.maxPageSize) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: limit must be between 1 and ${anomalyDetection.maxPageSize}`);
+    }
+    if (!Number.isInteger(start) || start < 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: offset must be a non-negative integer`);
+    }
+
+    const client = await cacheService.connect();
+    const channel = currentChannel();
+    const indexKey = batchId ? this._getBatchKey(channel, batchId) : this._getIndexKey(channel);
+    const [total, alertIds] = await Promise.all([
+      client.zCard(indexKey),
+      client.zRange(indexKey, start, start + pageSize - 1, { REV: true })
+    ]);
+    const alerts = alertIds.length > 0 ? await client.hmGet(this._getAlertsKey(channel), alertIds) : [];
+
+    return {
+      alerts: alerts.filter(Boolean).map(alert => JSON.parse(alert)),
+      total,
+      limit: pageSize,
+      offset: start
+    };
+  }
+
+  /**
+   * Apply the rules of an event and raise their findings
+   * A failing rule is logged and skipped, so one bad payload cannot stop detection
+   * @private
+   */
+  async _inspectEvent(event) {
+    const message = this._toMessage(event);
+    this.stats.events++;
+    this.stats.lastBlock = message.blockNumber;
+    if (!message.payload || typeof message.payload !== 'object') {
+      return;
+    }
+
+    const loadBatch = batchId => runInChannel(anomalyDetection.channel, () =>
+      fabricDAO.evaluateTransaction(anomalyDetection.role, 'ReadRiceBatch', batchId).catch(() => null));
+    for (const [rule, { events, evaluate }] of Object.entries(RULES)) {
+      if (!events.includes(message.eventName)) {
+        continue;
+      }
+      try {
+        const finding = await evaluate(message, loadBatch);
+        if (finding) {
+          await this._raiseAlert(rule, message, finding);
+        }
+      } catch (error) {
+        this.stats.ruleErrors++;
+        console.error(`Anomaly rule ${rule} failed on ${message.eventName} (${message.transactionId}): ${error.message}`);
+      }
+    }
+  }
+
+  /**
+   * Store an alert and send it to the audit team; an alert already stored (replayed event) is not sent again
+   * @private
+   */
+  async _raiseAlert(rule, message, finding) {
+    const batchId = message.payload.batchId;
+    const alert = {
+      alertId: `${message.transactionId}:${rule}`,
+      rule,
+      severity: finding.severity,
+      batchId,
+      message: finding.message,
+      details: finding.details,
+      eventName: message.eventName,
+      transactionId: message.transactionId,
+      blockNumber: message.blockNumber,
+      channelName: anomalyDetection.channel,
+      detectedAt: new Date().toISOString()
+    };
+
+    const client = await cacheService.connect();
+    const added = await client.hSetNX(this._getAlertsKey(anomalyDetection.channel), alert.alertId, JSON.stringify(alert));
+    if (!added) {
+      return;
+    }
+    const score = Number(message.blockNumber);
+    await client.zAdd(this._getIndexKey(anomalyDetection.channel), { score, value: alert.alertId });
+    if (batchId) {
+      await client.zAdd(this._getBatchKey(anomalyDetection.channel, batchId), { score, value: alert.alertId });
+    }
+    this.stats.alerts++;
+    console.warn(`Anomaly ${rule}: ${finding.message}`);
+
+    for (const url of anomalyDetection.alertWebhookUrls) {
+      try {
+        await this._postAlert(url, alert);
+      } catch (error) {
+        this.stats.alertDeliveriesFailed++;
+        console.error(`Anomaly alert ${alert.alertId} could not be sent to ${url}: ${error.message}`);
+      }
+    }
+  }
+
+  /**
+   * POST an alert to an audit webhook, signed like bridge webhooks when EVENT_WEBHOOK_SECRET is set
+   * @private
+   */
+  async _postAlert(url, alert) {
+    const body = JSON.stringify(alert);
+    const headers = {
+      'Content-Type': 'application/json',
+      'X-RiceTrace-Event': 'AnomalyDetected',
+      'X-RiceTrace-Transaction': alert.transactionId
+    };
+    if (eventBridge.webhooks.secret) {
+      const signature = crypto.createHmac('sha256', eventBridge.webhooks.secret).update(body).digest('hex');
+      headers['X-RiceTrace-Signature'] = `sha256=${signature}`;
+    }
+
+    const response = await fetch(url, {
+      method: 'POST',
+      headers,
+      body,
+      signal: AbortSignal.timeout(eventBridge.webhooks.timeout)
+    });
+    if (!response.ok) {
+      throw new Error(`Webhook responded with HTTP ${response.status}`);
+    }
+  }
+
+  /**
+   * @private
+   */
+  _toMessage(event) {
+    const payloadText = new TextDecoder().decode(event.payload);
+    let payload;
+    try {
+      payload = JSON.parse(payloadText);
+    } catch {
+      payload = payloadText;
+    }
+    return {
+      eventName: event.eventName,
+      transactionId: event.transactionId,
+      blockNumber: event.blockNumber.toString(),
+      payload
+    };
+  }
+
+  /**
+   * @private
+   */
+  _getAlertsKey(channel) {
+    return `${anomalyDetection.keyPrefix}:${channel}`;
+  }
+
+  /**
+   * @private
+   */
+  _getIndexKey(channel) {
+    return `${anomalyDetection.keyPrefix}:${channel}:index`;
+  }
+
+  /**
+   * @private
+   */
+  _getBatchKey(channel, batchId) {
+    return `${anomalyDetection.keyPrefix}:${channel}:batch:${batchId}`;
+  }
+}
+
+// Create singleton instance
+const anomalyDetectionService = new AnomalyDetectionService();
+
+module.exports = anomalyDetectionService;
@@ -0,0 +1,254 @@
+const crypto = require('node:crypto');
+const cacheService = require('./CacheService');
+const { rateLimit, errorCodes } = require('../../config');
+
+/**
+ * API key service layer
+ * Issues API keys to the apps and partners calling the public trace and verification endpoints, and counts their
+ * requests against a per-minute rate limit and a daily quota. Keys are stored in Redis as SHA-256 hashes only; the
+ * key itself is shown once, when it is created
+ */
+
+const WINDOW_SECONDS = 60;
+const DAY_SECONDS = 24 * 60 * 60;
+
+class ApiKeyService {
+  constructor() {
+    // Counters used while Redis is unavailable: window key -> { count, expiresAt }
+    this.localCounters = new Map();
+  }
+
+  /**
+   * Create an API key
+   * @param {Object} input - { name, requestsPerMinute?, dailyQuota? }
+   * @returns {Promise<Object>} Client with its apiKey
+   */
+  async createKey({ name, requestsPerMinute, dailyQuota } = {}) {
+    if (!name || typeof name !== 'string') {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: name is required`);
+    }
+    const limits = this._validateLimits({
+      requestsPerMinute: requestsPerMinute === undefined ? rateLimit.apiKeyDefaults.requestsPerMinute : requestsPerMinute,
+      dailyQuota: dailyQuota === undefined ? rateLimit.apiKeyDefaults.dailyQuota : dailyQuota
+    });
+
+    const apiKey = `rtk_${crypto.randomBytes(24).toString('base64url')}`;
+    const client = {
+      clientId: `client_${crypto.randomBytes(6).toString('hex')}`,
+      name,
+      ...limits,
+      keyPrefix: apiKey.slice(0, 8),
+      createdAt: new Date().toISOString(),
+      revokedAt: null
+    };
+
+    const redis = await cacheService.connect();
+    await redis.set(this._clientKey(client.clientId), JSON.stringify({ ...client, keyHash: this._hash(apiKey) }));
+    await redis.set(this._hashKey(this._hash(apiKey)), client.clientId);
+
+    return { ...client, apiKey };
+  }
+
+  /**
+   * List API key clients with today's usage
+   * @returns {Promise<Array>} Clients, oldest first
+   */
+  async listKeys() {
+    const redis = await cacheService.connect();
+    const clients = [];
+    for await (const key of redis.scanIterator({ MATCH: `${rateLimit.keyPrefix}:client:*`, COUNT: 100 })) {
+      const stored = await redis.get(key);
+      if (stored) {
+        const client = this._toPublic(JSON.parse(stored));
+        client.usedToday = Number(await redis.get(this._counterKey(client.clientId, 'day'))) || 0;
+        clients.push(client);
+      }
+    }
+    return clients.sort((a, b) => a.createdAt.localeCompare(b.createdAt));
+  }
+
+  /**
+   * Change the limits of an API key
+   * @param {string} clientId - Client ID
+   * @param {Object} limits - { requestsPerMinute?, dailyQuota? }
+   * @returns {Promise<Object>} Updated client
+   */
+  async updateLimits(clientId, { requestsPerMinute, dailyQuota } = {}) {
+    const client = await this._getClient(clientId);
+    const limits = this._validateLimits({
+      requestsPerMinute: requestsPerMinute === undefined ? client.requestsPerMinute : requestsPerMinute,
+      dailyQuota: dailyQuota === undefined ? client.dailyQuota : dailyQuota
+    });
+
+    const updated = { ...client, ...limits };
+    const redis = await cacheService.connect();
+    await redis.set(this._clientKey(clientId), JSON.stringify(updated));
+    return this._toPublic(updated);
+  }
+
+  /**
+   * Revoke an API key; the client record is kept for the audit trail
+   * @param {string} clientId - Client ID
+   * @returns {Promise<Object>} Revoked client
+   */
+  async revokeKey(clientId) {
+    const client = await this._getClient(clientId);
+    if (client.revokedAt) {
+      return this._toPublic(client);
+    }
+
+    const revoked = { ...client, revokedAt: new Date().toISOString() };
+    const redis = await cacheService.connect();
+    await redis.set(this._clientKey(clientId), JSON.stringify(revoked));
+    await redis.del(this._hashKey(client.keyHash));
+    return this._toPublic(revoked);
+  }
+
+  /**
+   * Find the client of an API key
+   * @param {string} apiKey - Key sent in X-API-Key
+   * @returns {Promise<Object>} Client
+   */
+  async resolveKey(apiKey) {
+    const redis = await cacheService.connect();
+    const clientId = await redis.get(this._hashKey(this._hash(apiKey)));
+    const stored = clientId ? await redis.get(this._clientKey(clientId)) : null;
+    const client = stored ? JSON.parse(stored) : null;
+    if (!client || client.revokedAt) {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: Unknown or revoked API key`);
+    }
+    return this._toPublic(client);
+  }
+
+  /**
+   * Count a request against the limits of a subject (client ID or client IP)
+   * @param {string} subject - Who is counted
+   * @param {Object} limits - { requestsPerMinute, dailyQuota }
+   * @returns {Promise<Object>} { limit, remaining, reset, quota, quotaRemaining }; reset is in seconds
+   */
+  async consume(subject, { requestsPerMinute, dailyQuota }) {
+    const now = Math.floor(Date.now() / 1000);
+    const reset = WINDOW_SECONDS - (now % WINDOW_SECONDS);
+    const [requests, usedToday] = await Promise.all([
+      this._increment(this._counterKey(subject, 'minute', now), reset),
+      this._increment(this._counterKey(subject, 'day', now), DAY_SECONDS - (now % DAY_SECONDS))
+    ]);
+
+    if (usedToday > dailyQuota) {
+      const error = new Error(`${errorCodes.QUOTA_EXCEEDED}: Daily quota of ${dailyQuota} requests used up`);
+      error.retryAfter = DAY_SECONDS - (now % DAY_SECONDS);
+      throw error;
+    }
+    if (requests > requestsPerMinute) {
+      const error = new Error(`${errorCodes.RATE_LIMITED}: Rate limit of ${requestsPerMinute} requests per minute exceeded`);
+      error.retryAfter = reset;
+      throw error;
+    }
+
+    return {
+      limit: requestsPerMinute,
+      remaining: requestsPerMinute - requests,
+      reset,
+      quota: dailyQuota,
+      quotaRemaining: dailyQuota - usedToday
+    };
+  }
+
+  /**
+   * Increment a counter that expires with its window, in Redis or, while it is unavailable, in this process
+   * @private
+   */
+  async _increment(key, ttlSeconds) {
+    try {
+      const redis = await cacheService.connect();
+      const count = await redis.incr(key);
+      if (count === 1) {
+        await redis.expire(key, ttlSeconds);
+      }
+      return count;
+    } catch (error) {
+      console.error('Rate limit counter unavailable in Redis, counting locally:', error.message);
+      const now = Date.now();
+      const counter = this.localCounters.get(key);
+      if (!counter || counter.expiresAt <= now) {
+        for (const [staleKey, stale] of this.localCounters) {
+          if (stale.expiresAt <= now) {
+            this.localCounters.delete(staleKey);
+          }
+        }
+        this.localCounters.set(key, { count: 1, expiresAt: now + ttlSeconds * 1000 });
+        return 1;
+      }
+      return ++counter.count;
+    }
+  }
+
+  /**
+   * @private
+   */
+  async _getClient(clientId) {
+    const redis = await cacheService.connect();
+    const stored = await redis.get(this._clientKey(clientId));
+    if (!stored) {
+      throw new Error(`${errorCodes.NOT_FOUND}: API key client ${clientId} does not exist`);
+    }
+    return JSON.parse(stored);
+  }
+
+  /**
+   * @private
+   */
+  _validateLimits(limits) {
+    for (const [field, value] of Object.entries(limits)) {
+      const number = Number(value);
+      if (!Number.isInteger(number) || number <= 0) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${field} must be a positive integer`);
+      }
+      limits[field] = number;
+    }
+    return limits;
+  }
+
+  /**
+   * The stored client without its key hash
+   * @private
+   */
+  _toPublic({ keyHash, ...client }) {
+    return client;
+  }
+
+  /**
+   * @private
+   */
+  _hash(apiKey) {
+    return crypto.createHash('sha256').update(String(apiKey)).digest('hex');
+  }
+
+  /**
+   * @private
+   */
+  _clientKey(clientId) {
+    return `${rateLimit.keyPrefix}:client:${clientId}`;
+  }
+
+  /**
+   * @private
+   */
+  _hashKey(keyHash) {
+    return `${rateLimit.keyPrefix}:hash:${keyHash}`;
+  }
+
+  /**
+   * Counter of a subject in the current minute or UTC day
+   * @private
+   */
+  _counterKey(subject, period, now = Math.floor(Date.now() / 1000)) {
+    const window = period === 'minute' ? Math.floor(now / WINDOW_SECONDS) : new Date(now * 1000).toISOString().slice(0, 10);
+    return `${rateLimit.counterPrefix}:${subject}:${period}:${window}`;
+  }
+}
+
+// Create singleton instance
+const apiKeyService = new ApiKeyService();
+
+module.exports = apiKeyService;
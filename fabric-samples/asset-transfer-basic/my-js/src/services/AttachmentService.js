@@ -0,0 +1,211 @@
+const crypto = require('node:crypto');
+const { Transform } = require('node:stream');
+const fabricDAO = require('../dao/FabricDAO');
+const ipfsClient = require('../clients/IpfsClient');
+const cacheService = require('./CacheService');
+const { errorCodes } = require('../../config');
+
+const CATEGORIES = ['photo', 'labReport', 'certificate', 'contract', 'customsDoc'];
+
+/**
+ * Attachment service layer
+ * Attaches typed documents (photos, lab reports, certificates, contracts, customs documents) to batches and products
+ */
+class AttachmentService {
+
+  /**
+   * Attach a document to a batch or product
+   * The file stays off-chain; the ledger keeps its SHA-256 with category-specific metadata
+   * @param {string} role - Caller role
+   * @param {string} entityId - Batch or product ID
+   * @param {Object} attachment - { category, title, fileHash, mimeType, uri?, cid?, metadata }
+   * @returns {Promise<Object>} Stored attachment
+   */
+  async addAttachment(role, entityId, attachment) {
+    const { category, title, fileHash, mimeType, uri, cid, metadata = {} } = attachment;
+    if (!CATEGORIES.includes(category)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: category must be one of ${CATEGORIES.join(', ')}`);
+    }
+    if (!title || !fileHash || !mimeType) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: title, fileHash and mimeType are required`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'AttachmentContract:AddAttachment', entityId,
+        JSON.stringify({ category, title, fileHash, mimeType, uri, cid, metadata }));
+      // Consumer trace payloads list product attachments; batch attachments show up once the trace cache expires
+      await cacheService.invalidateTrace(entityId);
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('No batch or product')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: No batch or product with ID ${entityId} exists`);
+      }
+      throw new Error(`Failed to add attachment: ${error.message}`);
+    }
+  }
+
+  /**
+   * Store a file on IPFS and attach it to a batch or product: the file is added to the IPFS node, pinned with the
+   * pinning service when one is configured, and its CID and SHA-256 are recorded on-chain
+   * @param {string} role - Caller role
+   * @param {string} entityId - Batch or product ID
+   * @param {Object} file - Uploaded file { buffer, originalname, mimetype }
+   * @param {Object} fields - { category, title, metadata } (metadata as an object or JSON string)
+   * @returns {Promise<Object>} { attachment, pin }
+   */
+  async uploadAttachment(role, entityId, file, fields) {
+    if (!file || !file.buffer || file.buffer.length === 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: A file is required`);
+    }
+    const { category, title } = fields;
+    if (!CATEGORIES.includes(category)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: category must be one of ${CATEGORIES.join(', ')}`);
+    }
+    if (!title) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: title is required`);
+    }
+    let metadata = fields.metadata || {};
+    if (typeof metadata === 'string') {
+      try {
+        metadata = JSON.parse(metadata);
+      } catch (error) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: metadata must be a JSON object`);
+      }
+    }
+
+    const fileHash = crypto.createHash('sha256').update(file.buffer).digest('hex');
+    const { cid } = await ipfsClient.add(file.buffer, file.originalname);
+    const pin = await ipfsClient.pinRemote(cid, `${entityId}/${title}`);
+
+    const attachment = await this.addAttachment(role, entityId, {
+      category,
+      title,
+      fileHash,
+      mimeType: file.mimetype,
+      cid,
+      metadata
+    });
+    return { attachment, pin };
+  }
+
+  /**
+   * Open the content of an attachment stored on IPFS. The stream fails at its end if the content does not match
+   * the SHA-256 recorded on-chain
+   * @param {string} role - Caller role
+   * @param {string} entityId - Batch or product ID
+   * @param {string} attachmentId - Attachment ID
+   * @returns {Promise<Object>} { attachment, stream }
+   */
+  async openAttachmentContent(role, entityId, attachmentId) {
+    const attachment = await this.getAttachment(role, entityId, attachmentId);
+    if (!attachment.cid) {
+      throw new Error(`${errorCodes.NOT_FOUND}: Attachment ${attachmentId} is not stored on IPFS${attachment.uri ? `, fetch it from ${attachment.uri}` : ''}`);
+    }
+
+    const content = await ipfsClient.cat(attachment.cid);
+    const hash = crypto.createHash('sha256');
+    const verifier = new Transform({
+      transform(chunk, encoding, callback) {
+        hash.update(chunk);
+        callback(null, chunk);
+      },
+      flush(callback) {
+        const digest = hash.digest('hex');
+        callback(digest === attachment.fileHash
+          ? null
+          : new Error(`${errorCodes.STORAGE_ERROR}: Content of ${attachment.cid} does not match the recorded hash of attachment ${attachmentId}`));
+      }
+    });
+    content.on('error', error => verifier.destroy(error));
+    return { attachment, stream: content.pipe(verifier) };
+  }
+
+  /**
+   * Add a photo to the photo journal of a batch, tagged with the stage it was taken at
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object} photo - { stage, caption?, fileHash, uri }
+   * @returns {Promise<Object>} Stored photo attachment
+   */
+  async addBatchPhoto(role, batchId, photo) {
+    const { stage, caption = '', fileHash, uri } = photo;
+    if (!stage || !fileHash || !uri) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: stage, fileHash and uri are required`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'AttachmentContract:AddBatchPhoto', batchId, stage, caption, fileHash, uri);
+      await cacheService.invalidateTrace(batchId);
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      if (error.message.includes('Invalid photo stage') || error.message.includes('must be an image')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to add batch photo: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the photo journal of a batch in the order the photos were added
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Array>} Photo attachments
+   */
+  async getBatchPhotos(role, batchId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'AttachmentContract:GetBatchPhotos', batchId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to get batch photos: ${error.message}`);
+    }
+  }
+
+  /**
+   * List the attachments of a batch or product in the order they were added
+   * @param {string} role - Caller role
+   * @param {string} entityId - Batch or product ID
+   * @param {string} [category] - Only attachments of this category
+   * @returns {Promise<Array>} Attachments
+   */
+  async listAttachments(role, entityId, category = '') {
+    if (category && !CATEGORIES.includes(category)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: category must be one of ${CATEGORIES.join(', ')}`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'AttachmentContract:ListAttachments', entityId, category);
+    } catch (error) {
+      throw new Error(`Failed to list attachments: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get an attachment of a batch or product
+   * @param {string} role - Caller role
+   * @param {string} entityId - Batch or product ID
+   * @param {string} attachmentId - Attachment ID
+   * @returns {Promise<Object>} Attachment
+   */
+  async getAttachment(role, entityId, attachmentId) {
+    let attachment;
+    try {
+      attachment = await fabricDAO.evaluateTransaction(role, 'AttachmentContract:ReadAttachment', attachmentId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Attachment ${attachmentId} does not exist`);
+      }
+      throw new Error(`Failed to get attachment: ${error.message}`);
+    }
+    if (attachment.entityId !== entityId) {
+      throw new Error(`${errorCodes.NOT_FOUND}: Attachment ${attachmentId} does not belong to ${entityId}`);
+    }
+    return attachment;
+  }
+}
+
+module.exports = new AttachmentService();
@@ -0,0 +1,175 @@
+const crypto = require('node:crypto');
+const { auth, fabric, getAvailableRoles, errorCodes } = require('../../config');
+
+// Signature algorithms accepted in tokens -> Node digest and signature options
+const ALGORITHMS = {
+  HS256: { digest: 'sha256', hmac: true },
+  RS256: { digest: 'sha256' },
+  ES256: { digest: 'sha256', dsaEncoding: 'ieee-p1363' }
+};
+
+// Shortest interval between two JWKS downloads triggered by unknown key IDs
+const JWKS_REFRESH_INTERVAL = 60 * 1000; // 1 minute
+
+/**
+ * Authentication service layer
+ * Verifies API bearer tokens (HS256 with a shared secret, or RS256/ES256 signed by an OIDC provider) and maps
+ * the application user to a role and a Fabric identity
+ */
+class AuthService {
+  constructor() {
+    this.keys = new Map(); // Signing keys of the OIDC provider by key ID
+    this.keysFetchedAt = 0;
+  }
+
+  /**
+   * Verify a bearer token and resolve the user it authenticates
+   * @param {string} token - Compact JWT
+   * @returns {Promise<Object>} { subject, role, identity }
+   */
+  async authenticate(token) {
+    const claims = await this.verifyToken(token);
+    return this.resolveUser(claims);
+  }
+
+  /**
+   * Verify the signature and validity of a token
+   * @param {string} token - Compact JWT
+   * @returns {Promise<Object>} Token claims
+   */
+  async verifyToken(token) {
+    const segments = (token || '').split('.');
+    if (segments.length !== 3) {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: Malformed bearer token`);
+    }
+    const [encodedHeader, encodedPayload, encodedSignature] = segments;
+
+    let header;
+    let claims;
+    try {
+      header = JSON.parse(Buffer.from(encodedHeader, 'base64url').toString('utf8'));
+      claims = JSON.parse(Buffer.from(encodedPayload, 'base64url').toString('utf8'));
+    } catch {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: Malformed bearer token`);
+    }
+
+    const algorithm = ALGORITHMS[header.alg];
+    if (!algorithm) {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: Unsupported token algorithm ${header.alg}`);
+    }
+    const signedData = Buffer.from(`${encodedHeader}.${encodedPayload}`);
+    const signature = Buffer.from(encodedSignature, 'base64url');
+    if (!(await this._verifySignature(header, algorithm, signedData, signature))) {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: Invalid token signature`);
+    }
+
+    this._checkClaims(claims);
+    return claims;
+  }
+
+  /**
+   * Map token claims to a role and identity. A mapping in the user file takes precedence over the role and
+   * identity claims
+   * @param {Object} claims - Verified token claims
+   * @returns {Object} { subject, role, identity }
+   */
+  resolveUser(claims) {
+    const subject = claims.sub;
+    const mapping = auth.users[subject];
+    const validRoles = [...getAvailableRoles(), 'admin'];
+
+    const claimedRoles = [].concat(claims[auth.roleClaim] || []);
+    const role = mapping ? mapping.role : claimedRoles.find(candidate => validRoles.includes(candidate));
+    if (!role || !validRoles.includes(role)) {
+      throw new Error(`${errorCodes.PERMISSION_DENIED}: User ${subject} has no RiceTrace role`);
+    }
+
+    const identity = (mapping ? mapping.identity : claims[auth.identityClaim]) || '';
+    if (identity && (!fabric.identities[identity] || fabric.identities[identity].role !== role)) {
+      throw new Error(`${errorCodes.PERMISSION_DENIED}: User ${subject} is mapped to identity ${identity}, which is not an identity of role ${role}`);
+    }
+    return { subject, role, identity };
+  }
+
+  /**
+   * @private
+   */
+  async _verifySignature(header, algorithm, signedData, signature) {
+    if (algorithm.hmac) {
+      if (!auth.jwtSecret) {
+        return false;
+      }
+      const expected = crypto.createHmac(algorithm.digest, auth.jwtSecret).update(signedData).digest();
+      return expected.length === signature.length && crypto.timingSafeEqual(expected, signature);
+    }
+
+    const key = await this._getSigningKey(header.kid);
+    if (!key) {
+      return false;
+    }
+    return crypto.verify(algorithm.digest, signedData,
+      algorithm.dsaEncoding ? { key, dsaEncoding: algorithm.dsaEncoding } : key, signature);
+  }
+
+  /**
+   * Check expiry, not-before, issuer and audience
+   * @private
+   */
+  _checkClaims(claims) {
+    const now = Math.floor(Date.now() / 1000);
+    if (typeof claims.exp !== 'number' || claims.exp + auth.clockToleranceSeconds < now) {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: Token has expired`);
+    }
+    if (typeof claims.nbf === 'number' && claims.nbf - auth.clockToleranceSeconds > now) {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: Token is not valid yet`);
+    }
+    if (auth.issuer && claims.iss !== auth.issuer) {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: Token was not issued by ${auth.issuer}`);
+    }
+    if (auth.audience && ![].concat(claims.aud || []).includes(auth.audience)) {
+      throw new Error(`${errorCodes.UNAUTHENTICATED}: Token is not intended for ${auth.audience}`);
+    }
+  }
+
+  /**
+   * Public key of the OIDC provider with the given key ID; the key set is downloaded again, at most once a
+   * minute, when the key is unknown (key rotation)
+   * @private
+   */
+  async _getSigningKey(kid) {
+    if (!this.keys.has(kid) && Date.now() - this.keysFetchedAt > JWKS_REFRESH_INTERVAL && (auth.jwksUri || auth.issuer)) {
+      this.keysFetchedAt = Date.now();
+      try {
+        await this._fetchKeys();
+      } catch (error) {
+        console.error('Failed to download the token signing keys:', error.message);
+      }
+    }
+    return this.keys.get(kid);
+  }
+
+  /**
+   * @private
+   */
+  async _fetchKeys() {
+    let jwksUri = auth.jwksUri;
+    if (!jwksUri) {
+      const discovery = await fetch(`${auth.issuer.replace(/\/$/, '')}/.well-known/openid-configuration`);
+      if (!discovery.ok) {
+        throw new Error(`OIDC discovery returned ${discovery.status}`);
+      }
+      jwksUri = (await discovery.json()).jwks_uri;
+    }
+
+    const response = await fetch(jwksUri);
+    if (!response.ok) {
+      throw new Error(`JWKS endpoint returned ${response.status}`);
+    }
+    const { keys = [] } = await response.json();
+    this.keys = new Map(keys
+      .filter(jwk => !jwk.use || jwk.use === 'sig')
+      .map(jwk => [jwk.kid, crypto.createPublicKey({ key: jwk, format: 'jwk' })]));
+  }
+}
+
+module.exports = new AuthService();
@@ -0,0 +1,67 @@
+const fabricDAO = require('../dao/FabricDAO');
+const cacheService = require('./CacheService');
+const { errorCodes } = require('../../config');
+
+/**
+ * Branding service layer
+ * Registers the brand each organization presents to consumers (name, logo and story), so one network serves several
+ * rice brands, each shown with the products it packaged
+ */
+class BrandingService {
+
+  /**
+   * Register or replace the brand of the caller's organization
+   * @param {string} role - Caller role; its identity must be an organization administrator
+   * @param {Object} branding - { brandName, logoHash?, logoUri?, story? }
+   * @returns {Promise<Object>} Registered branding
+   */
+  async setBranding(role, { brandName, logoHash, logoUri, story }) {
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'OrganizationBrandingContract:SetOrganizationBranding',
+        JSON.stringify({ brandName, logoHash, logoUri, story }));
+      // Cached consumer traces show the previous brand
+      await cacheService.invalidateAllTraces();
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (/brandName|logoHash|logoUri|story|Branding/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to set branding: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the brand of an organization
+   * @param {string} role - Caller role
+   * @param {string} mspId - Organization MSP ID
+   * @returns {Promise<Object>} Branding
+   */
+  async getBranding(role, mspId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'OrganizationBrandingContract:GetOrganizationBranding', mspId);
+    } catch (error) {
+      if (error.message.includes('No branding is registered')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: No branding is registered for ${mspId}`);
+      }
+      throw new Error(`Failed to get branding: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the brands of all organizations
+   * @param {string} role - Caller role
+   * @returns {Promise<Array>} Brandings
+   */
+  async getAllBrandings(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'OrganizationBrandingContract:GetAllOrganizationBrandings');
+    } catch (error) {
+      throw new Error(`Failed to get brandings: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new BrandingService();
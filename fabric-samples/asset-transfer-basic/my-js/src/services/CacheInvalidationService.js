@@ -0,0 +1,140 @@
+const fabricDAO = require('../dao/FabricDAO');
+const cacheService = require('./CacheService');
+const { runInChannel } = require('../dao/channelContext');
+const { fabric, redis } = require('../../config');
+
+/**
+ * Cache invalidation service
+ * Listens for chaincode events on every channel of the registry and clears the cached entries of the batches and
+ * products they announce, in Redis and in this process's memory. Writes made through other gateways, scripts or
+ * the CLI then reach the cache within a block, so read endpoints can be served from the cache during traffic
+ * spikes. Events are not checkpointed: after a restart the cache TTLs bound what was missed
+ */
+class CacheInvalidationService {
+  constructor() {
+    this.streams = new Map();
+    this.isRunning = false;
+    this.stats = {
+      events: 0,
+      invalidations: 0,
+      lastBlock: null
+    };
+  }
+
+  /**
+   * Start listening on every channel; returns once the listeners are started
+   */
+  async start() {
+    if (this.isRunning || !redis.cache.eventInvalidation.enabled) {
+      return;
+    }
+    this.isRunning = true;
+
+    for (const channel of Object.keys(fabric.channels)) {
+      this._listen(channel);
+    }
+  }
+
+  /**
+   * Stop listening
+   */
+  stop() {
+    this.isRunning = false;
+    for (const events of this.streams.values()) {
+      events.close();
+    }
+    this.streams.clear();
+  }
+
+  /**
+   * Get listener status
+   */
+  getStatus() {
+    return {
+      isRunning: this.isRunning,
+      channels: Array.from(this.streams.keys()),
+      ...this.stats
+    };
+  }
+
+  /**
+   * Consume the events of a channel, reconnecting after failures until stopped
+   * @private
+   */
+  async _listen(channel) {
+    const { role, retryDelay } = redis.cache.eventInvalidation;
+
+    while (this.isRunning) {
+      try {
+        const network = await fabricDAO.getNetwork(role, channel);
+        const events = await network.getChaincodeEvents(fabric.channels[channel].chaincodeName);
+        this.streams.set(channel, events);
+        console.log(`Cache invalidation listening for events on ${channel}`);
+
+        for await (const event of events) {
+          await runInChannel(channel, () => this._handleEvent(event));
+        }
+      } catch (error) {
+        if (this.isRunning) {
+          console.error(`Cache invalidation stream on ${channel} failed: ${error.message}`);
+        }
+      } finally {
+        this.streams.delete(channel);
+      }
+
+      if (this.isRunning) {
+        await new Promise(resolve => setTimeout(resolve, retryDelay));
+      }
+    }
+  }
+
+  /**
+   * Clear the entries of the entities an event announces
+   * Product events also carry the product's batchId, but do not change the batch, so only the product is cleared;
+   * entityId (attachments, documents) may name either kind. A brand change clears every consumer trace, a translation
+   * change the label dictionaries
+   * @private
+   */
+  async _handleEvent(event) {
+    this.stats.events++;
+    this.stats.lastBlock = event.blockNumber.toString();
+
+    if (event.eventName === 'OrganizationBrandingChanged') {
+      await cacheService.invalidateAllTraces();
+      this.stats.invalidations++;
+      return;
+    }
+    if (event.eventName === 'LabelTranslationChanged') {
+      await cacheService.invalidateLabelDictionaries();
+      this.stats.invalidations++;
+      return;
+    }
+
+    let payload;
+    try {
+      payload = JSON.parse(new TextDecoder().decode(event.payload));
+    } catch {
+      return;
+    }
+    if (!payload || typeof payload !== 'object' || Array.isArray(payload)) {
+      return;
+    }
+
+    const productIds = [payload.productId, payload.entityId].filter(id => typeof id === 'string' && id);
+    const batchIds = [payload.productId ? undefined : payload.batchId, payload.entityId].filter(id => typeof id === 'string' && id);
+
+    for (const productId of productIds) {
+      await cacheService.invalidateProductCache(productId);
+      this.stats.invalidations++;
+    }
+    for (const batchId of batchIds) {
+      await cacheService.invalidateBatchCache(batchId);
+      this.stats.invalidations++;
+    }
+  }
+}
+
+// Create singleton instance
+const cacheInvalidationService = new CacheInvalidationService();
+
+module.exports = cacheInvalidationService;
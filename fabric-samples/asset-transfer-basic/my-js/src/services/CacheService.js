@@ -1,9 +1,13 @@
 const redis = require('redis');
 const config = require('../../config');
+const { currentChannel } = require('../dao/channelContext');
 
 /**
  * Redis Cache Service
  * Handles caching of batch data to improve query performance
+ * Keys include the channel of the current request, so channels sharing one Redis never see each other's data
+ * Batch details, products and traces are also kept briefly in process memory, in front of Redis, for the hot keys
+ * of consumer traffic spikes; chaincode events clear both layers (see CacheInvalidationService)
  */
 class CacheService {
   constructor() {
@@ -11,6 +15,7 @@ class CacheService {
     this.isConnected = false;
     this.retryCount = 0;
     this.maxRetries = 3;
+    this.memory = new Map();
   }
 
   /**
@@ -73,7 +78,7 @@ class CacheService {
    * @returns {string} Cache key
    */
   _getBatchListKey(role) {
-    return `${config.redis.cache.keys.batchList}:${role}`;
+    return `${config.redis.cache.keys.batchList}:${currentChannel()}:${role}`;
   }
 
   /**
@@ -82,7 +87,7 @@ class CacheService {
    * @returns {string} Cache key
    */
   _getBatchDetailKey(batchId) {
-    return `${config.redis.cache.keys.batchDetail}:${batchId}`;
+    return `${config.redis.cache.keys.batchDetail}:${currentChannel()}:${batchId}`;
   }
 
   /**
@@ -91,7 +96,43 @@ class CacheService {
    * @returns {string} Cache key
    */
   _getBatchExistsKey(batchId) {
-    return `${config.redis.cache.keys.batchExists}:${batchId}`;
+    return `${config.redis.cache.keys.batchExists}:${currentChannel()}:${batchId}`;
+  }
+
+  /**
+   * Get cache key for product detail (product and its batch)
+   * @param {string} productId - Product ID
+   * @returns {string} Cache key
+   */
+  _getProductDetailKey(productId) {
+    return `${config.redis.cache.keys.productDetail}:${currentChannel()}:${productId}`;
+  }
+
+  /**
+   * Get cache key of the products whose cached entries embed a batch
+   * @param {string} batchId - Batch ID
+   * @returns {string} Cache key
+   */
+  _getBatchDependentsKey(batchId) {
+    return `${config.redis.cache.keys.batchDependents}:${currentChannel()}:${batchId}`;
+  }
+
+  /**
+   * Get cache key for the consumer trace payload of a product
+   * @param {string} productId - Product ID
+   * @returns {string} Cache key
+   */
+  _getTraceKey(productId) {
+    return `${config.redis.cache.keys.trace}:${currentChannel()}:${productId}`;
+  }
+
+  /**
+   * Get cache key for the label dictionary of a locale
+   * @param {string} locale - Locale (zh or en)
+   * @returns {string} Cache key
+   */
+  _getLabelsKey(locale) {
+    return `${config.redis.cache.keys.labels}:${currentChannel()}:${locale}`;
   }
 
   /**
@@ -142,14 +183,21 @@ class CacheService {
    * @returns {Promise<Object|null>} Cached batch detail or null if not found
    */
   async getBatchDetail(batchId) {
+    const key = this._getBatchDetailKey(batchId);
+    const remembered = this._memoryGet(key);
+    if (remembered !== undefined) {
+      return remembered;
+    }
+
     try {
       await this.connect();
-      const key = this._getBatchDetailKey(batchId);
       const cached = await this.client.get(key);
       
       if (cached) {
         console.log(`Cache hit: batch detail for ${batchId}`);
-        return JSON.parse(cached);
+        const batch = JSON.parse(cached);
+        this._memorySet(key, batch);
+        return batch;
       }
       
       console.log(`Cache miss: batch detail for ${batchId}`);
@@ -166,9 +214,11 @@ class CacheService {
    * @param {Object} batch - Batch data
    */
   async setBatchDetail(batchId, batch) {
+    const key = this._getBatchDetailKey(batchId);
+    this._memorySet(key, batch);
+
     try {
       await this.connect();
-      const key = this._getBatchDetailKey(batchId);
       const ttl = config.redis.cache.ttl.batchDetail;
       
       await this.client.setEx(key, ttl, JSON.stringify(batch));
@@ -220,6 +270,297 @@ class CacheService {
     }
   }
 
+  /**
+   * Get the consumer trace payload of a product from cache
+   * @param {string} productId - Product ID
+   * @returns {Promise<Object|null>} Cached trace payload or null if not found
+   */
+  async getTrace(productId) {
+    const key = this._getTraceKey(productId);
+    const remembered = this._memoryGet(key);
+    if (remembered !== undefined) {
+      return remembered;
+    }
+
+    try {
+      await this.connect();
+      const cached = await this.client.get(key);
+
+      if (cached) {
+        console.log(`Cache hit: trace for product ${productId}`);
+        const payload = JSON.parse(cached);
+        this._memorySet(key, payload);
+        return payload;
+      }
+
+      console.log(`Cache miss: trace for product ${productId}`);
+      return null;
+    } catch (error) {
+      console.error('Error getting trace from cache:', error);
+      return null;
+    }
+  }
+
+  /**
+   * Set the consumer trace payload of a product in cache
+   * @param {string} productId - Product ID
+   * @param {Object} payload - Trace payload
+   */
+  async setTrace(productId, payload) {
+    const key = this._getTraceKey(productId);
+    this._memorySet(key, payload);
+
+    try {
+      await this.connect();
+      const ttl = config.redis.cache.ttl.trace;
+
+      await this.client.setEx(key, ttl, JSON.stringify(payload));
+      await this._addBatchDependent(payload.batchId, productId);
+      console.log(`Cached trace for product ${productId} with TTL ${ttl}s`);
+    } catch (error) {
+      console.error('Error setting trace in cache:', error);
+    }
+  }
+
+  /**
+   * Invalidate the consumer trace payload of a product
+   * @param {string} productId - Product ID
+   */
+  async invalidateTrace(productId) {
+    const key = this._getTraceKey(productId);
+    this.memory.delete(key);
+
+    try {
+      await this.connect();
+      await this.client.del(key);
+      console.log(`Invalidated trace cache for product ${productId}`);
+    } catch (error) {
+      console.error('Error invalidating trace cache:', error);
+    }
+  }
+
+  /**
+   * Invalidate the consumer trace payloads of every product of the current channel, e.g. when a brand changes
+   */
+  async invalidateAllTraces() {
+    const prefix = `${config.redis.cache.keys.trace}:${currentChannel()}:`;
+    for (const key of this.memory.keys()) {
+      if (key.startsWith(prefix)) {
+        this.memory.delete(key);
+      }
+    }
+
+    try {
+      await this.connect();
+      let cleared = 0;
+      for await (const key of this.client.scanIterator({ MATCH: `${prefix}*`, COUNT: 500 })) {
+        await this.client.del(key);
+        cleared++;
+      }
+      console.log(`Invalidated ${cleared} cached trace(s)`);
+    } catch (error) {
+      console.error('Error invalidating trace caches:', error);
+    }
+  }
+
+  /**
+   * Get the label dictionary of a locale from cache
+   * @param {string} locale - Locale (zh or en)
+   * @returns {Promise<Object|null>} Cached { locale, fields, values } or null if not found
+   */
+  async getLabelDictionary(locale) {
+    const key = this._getLabelsKey(locale);
+    const remembered = this._memoryGet(key);
+    if (remembered !== undefined) {
+      return remembered;
+    }
+
+    try {
+      await this.connect();
+      const cached = await this.client.get(key);
+      if (cached) {
+        const dictionary = JSON.parse(cached);
+        this._memorySet(key, dictionary);
+        return dictionary;
+      }
+      return null;
+    } catch (error) {
+      console.error('Error getting label dictionary from cache:', error);
+      return null;
+    }
+  }
+
+  /**
+   * Set the label dictionary of a locale in cache
+   * @param {string} locale - Locale (zh or en)
+   * @param {Object} dictionary - { locale, fields, values }
+   */
+  async setLabelDictionary(locale, dictionary) {
+    const key = this._getLabelsKey(locale);
+    this._memorySet(key, dictionary);
+
+    try {
+      await this.connect();
+      await this.client.setEx(key, config.redis.cache.ttl.labels, JSON.stringify(dictionary));
+    } catch (error) {
+      console.error('Error setting label dictionary in cache:', error);
+    }
+  }
+
+  /**
+   * Invalidate the label dictionaries of every locale of the current channel, e.g. when a translation changes
+   */
+  async invalidateLabelDictionaries() {
+    const keys = config.trace.languages.map(locale => this._getLabelsKey(locale));
+    keys.forEach(key => this.memory.delete(key));
+
+    try {
+      await this.connect();
+      await this.client.del(keys);
+      console.log('Invalidated label dictionaries');
+    } catch (error) {
+      console.error('Error invalidating label dictionaries:', error);
+    }
+  }
+
+  /**
+   * Get product detail (product and its batch) from cache
+   * @param {string} productId - Product ID
+   * @returns {Promise<Object|null>} Cached { product, batch } or null if not found
+   */
+  async getProductDetail(productId) {
+    const key = this._getProductDetailKey(productId);
+    const remembered = this._memoryGet(key);
+    if (remembered !== undefined) {
+      return remembered;
+    }
+
+    try {
+      await this.connect();
+      const cached = await this.client.get(key);
+
+      if (cached) {
+        console.log(`Cache hit: product detail for ${productId}`);
+        const detail = JSON.parse(cached);
+        this._memorySet(key, detail);
+        return detail;
+      }
+
+      console.log(`Cache miss: product detail for ${productId}`);
+      return null;
+    } catch (error) {
+      console.error('Error getting product detail from cache:', error);
+      return null;
+    }
+  }
+
+  /**
+   * Set product detail in cache
+   * @param {string} productId - Product ID
+   * @param {Object} detail - { product, batch }
+   */
+  async setProductDetail(productId, detail) {
+    const key = this._getProductDetailKey(productId);
+    this._memorySet(key, detail);
+
+    try {
+      await this.connect();
+      const ttl = config.redis.cache.ttl.productDetail;
+
+      await this.client.setEx(key, ttl, JSON.stringify(detail));
+      await this._addBatchDependent(detail.product && detail.product.batchId, productId);
+      console.log(`Cached product detail for ${productId} with TTL ${ttl}s`);
+    } catch (error) {
+      console.error('Error setting product detail in cache:', error);
+    }
+  }
+
+  /**
+   * Invalidate all cache entries for a specific product (detail and trace)
+   * @param {string} productId - Product ID
+   */
+  async invalidateProductCache(productId) {
+    const key = this._getProductDetailKey(productId);
+    this.memory.delete(key);
+
+    try {
+      await this.connect();
+      await this.client.del(key);
+      console.log(`Invalidated product detail cache for ${productId}`);
+    } catch (error) {
+      console.error('Error invalidating product detail cache:', error);
+    }
+    await this.invalidateTrace(productId);
+  }
+
+  /**
+   * Invalidate the product details and traces that embed a batch
+   * @param {string} batchId - Batch ID
+   */
+  async invalidateBatchDependents(batchId) {
+    try {
+      await this.connect();
+      const key = this._getBatchDependentsKey(batchId);
+      const productIds = await this.client.sMembers(key);
+      await this.client.del(key);
+
+      for (const productId of productIds) {
+        await this.invalidateProductCache(productId);
+      }
+    } catch (error) {
+      console.error('Error invalidating batch dependents cache:', error);
+    }
+  }
+
+  /**
+   * Remember that a product's cached entries embed its batch; lives as long as the longest of those entries
+   * @private
+   */
+  async _addBatchDependent(batchId, productId) {
+    if (!batchId) {
+      return;
+    }
+    const key = this._getBatchDependentsKey(batchId);
+    const { ttl } = config.redis.cache;
+    await this.client.sAdd(key, productId);
+    await this.client.expire(key, Math.max(ttl.trace, ttl.productDetail));
+  }
+
+  /**
+   * Read an entry of the in-memory layer; undefined when absent or expired
+   * @private
+   */
+  _memoryGet(key) {
+    const entry = this.memory.get(key);
+    if (!entry) {
+      return undefined;
+    }
+    if (entry.expiresAt <= Date.now()) {
+      this.memory.delete(key);
+      return undefined;
+    }
+    // Move to the end, so the least recently used entry is evicted first
+    this.memory.delete(key);
+    this.memory.set(key, entry);
+    return entry.value;
+  }
+
+  /**
+   * Keep an entry in memory for the memory TTL
+   * @private
+   */
+  _memorySet(key, value) {
+    const { enabled, ttl, maxEntries } = config.redis.cache.memory;
+    if (!enabled) {
+      return;
+    }
+    this.memory.delete(key);
+    this.memory.set(key, { value, expiresAt: Date.now() + ttl * 1000 });
+    if (this.memory.size > maxEntries) {
+      this.memory.delete(this.memory.keys().next().value);
+    }
+  }
+
   /**
    * Invalidate batch list cache for all roles
    */
@@ -243,9 +584,11 @@ class CacheService {
    * @param {string} batchId - Batch ID
    */
   async invalidateBatchDetail(batchId) {
+    const key = this._getBatchDetailKey(batchId);
+    this.memory.delete(key);
+
     try {
       await this.connect();
-      const key = this._getBatchDetailKey(batchId);
       await this.client.del(key);
       console.log(`Invalidated batch detail cache for ${batchId}`);
     } catch (error) {
@@ -279,6 +622,9 @@ class CacheService {
       // Invalidate batch detail and existence cache
       await this.invalidateBatchDetail(batchId);
       await this.invalidateBatchExists(batchId);
+
+      // Products and traces embed the batch
+      await this.invalidateBatchDependents(batchId);
       
       // Also invalidate batch list cache since the batch data has changed
       await this.invalidateBatchList();
@@ -289,11 +635,41 @@ class CacheService {
     }
   }
 
+  /**
+   * Read every cached batch entry of the current channel, without logging each hit
+   * Used to compare the cache with the ledger; the cache is bounded by its TTLs, so it fits in memory
+   * @returns {Promise<Object>} { details: Map<batchId, batch>, exists: Map<batchId, boolean>, lists: Map<role, batches> }
+   */
+  async getBatchCacheSnapshot() {
+    await this.connect();
+    const channel = currentChannel();
+    const { keys } = config.redis.cache;
+
+    const read = async (prefix) => {
+      const entries = new Map();
+      for await (const key of this.client.scanIterator({ MATCH: `${prefix}:${channel}:*`, COUNT: 500 })) {
+        const cached = await this.client.get(key);
+        // Entries may expire between the scan and the read
+        if (cached !== null) {
+          entries.set(key.slice(prefix.length + channel.length + 2), JSON.parse(cached));
+        }
+      }
+      return entries;
+    };
+
+    return {
+      details: await read(keys.batchDetail),
+      exists: await read(keys.batchExists),
+      lists: await read(keys.batchList)
+    };
+  }
+
   /**
    * Clear all cache entries
    */
   async clearAllCache() {
     try {
+      this.memory.clear();
       await this.connect();
       await this.client.flushDb();
       console.log('Cleared all cache entries');
@@ -313,12 +689,14 @@ class CacheService {
       
       return {
         connected: this.isConnected,
+        memoryEntries: this.memory.size,
         info: info
       };
     } catch (error) {
       console.error('Error getting cache stats:', error);
       return {
         connected: this.isConnected,
+        memoryEntries: this.memory.size,
         error: error.message
       };
     }
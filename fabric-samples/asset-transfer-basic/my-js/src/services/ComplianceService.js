@@ -0,0 +1,158 @@
+const fabricDAO = require('../dao/FabricDAO');
+const cacheService = require('./CacheService');
+const { errorCodes } = require('../../config');
+
+/**
+ * Export compliance service layer
+ * Manages the compliance profiles of export markets (e.g. EU MRLs, Japanese import rules) and checks batches
+ * against them; consignments to a market are refused while a batch does not comply
+ */
+class ComplianceService {
+
+  /**
+   * Create or update the compliance profile of an export market
+   * @param {string} role - Caller role
+   * @param {string} market - Market ID, e.g. EU or JP
+   * @param {Object} profile - { name, countries, requiredTests?, residueLimits?, requiredDocuments? }
+   * @returns {Promise<Object>} { market }
+   */
+  async defineProfile(role, market, profile) {
+    const { name, countries, requiredTests = [], residueLimits = [], requiredDocuments = [] } = profile;
+    if (!name || !Array.isArray(countries) || countries.length === 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: name and a non-empty countries list are required`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'ComplianceProfileContract:DefineComplianceProfile', market,
+        JSON.stringify({ name, countries, requiredTests, residueLimits, requiredDocuments }));
+      return { market: market.toUpperCase() };
+    } catch (error) {
+      if (error.message.includes('Invalid') || error.message.includes('already covered') || error.message.includes('must be') ||
+        error.message.includes('requires') || error.message.includes('domestic market')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to define compliance profile: ${error.message}`);
+    }
+  }
+
+  /**
+   * Configure the country the supply chain operates in; shipments to any other country are exports
+   * @param {string} role - Caller role
+   * @param {string} country - ISO 3166-1 alpha-2 code
+   * @returns {Promise<Object>} { country }
+   */
+  async defineDomesticMarket(role, country) {
+    if (!country) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: country is required`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'ComplianceProfileContract:DefineDomesticMarket', country);
+      return { country: country.toUpperCase() };
+    } catch (error) {
+      if (error.message.includes('Invalid') || error.message.includes('export market')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to define the domestic market: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the domestic market in force (CN, version 0, until configured)
+   * @param {string} role - Caller role
+   * @returns {Promise<Object>} { country, version, definedBy?, lastUpdated? }
+   */
+  async getDomesticMarket(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ComplianceProfileContract:GetDomesticMarket');
+    } catch (error) {
+      throw new Error(`Failed to get the domestic market: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the compliance profile of a market
+   * @param {string} role - Caller role
+   * @param {string} market - Market ID
+   * @returns {Promise<Object>} Compliance profile
+   */
+  async getProfile(role, market) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ComplianceProfileContract:ReadComplianceProfile', market);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Compliance profile ${market} does not exist`);
+      }
+      throw new Error(`Failed to get compliance profile: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get all compliance profiles
+   * @param {string} role - Caller role
+   * @returns {Promise<Array>} Compliance profiles
+   */
+  async getAllProfiles(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ComplianceProfileContract:GetAllComplianceProfiles');
+    } catch (error) {
+      throw new Error(`Failed to get compliance profiles: ${error.message}`);
+    }
+  }
+
+  /**
+   * Check whether a batch may be exported to a market; nothing is recorded
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} market - Market ID or a destination country covered by a profile
+   * @returns {Promise<Object>} { batchId, market, profileVersion, compliant, violations, checkedAt }
+   */
+  async checkExportCompliance(role, batchId, market) {
+    if (!market) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: market is required`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ComplianceProfileContract:CheckExportCompliance', batchId, market);
+    } catch (error) {
+      if (error.message.includes('does not exist') || error.message.includes('No compliance profile')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to check export compliance: ${error.message}`);
+    }
+  }
+
+  /**
+   * Record the residue levels a test measured; they cannot be changed afterwards
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} testId - Test ID
+   * @param {Object} residues - { "<substance>": <mg/kg>, ... }
+   * @returns {Promise<Object>} { batchId, testId, residues }
+   */
+  async recordResidueLevels(role, batchId, testId, residues) {
+    if (!residues || typeof residues !== 'object' || Array.isArray(residues)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: residues must be an object of substance names to levels in mg/kg`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'QualityCertificationContract:RecordResidueLevels', batchId, testId, JSON.stringify(residues));
+      await cacheService.invalidateBatchCache(batchId);
+      return { batchId, testId, residues };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Test result ${testId} does not exist`);
+      }
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (error.message.includes('Residue') || error.message.includes('residue levels') || error.message.includes('revoked') ||
+        error.message.includes('belongs to batch')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to record residue levels: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new ComplianceService();
@@ -0,0 +1,158 @@
+const fabricDAO = require('../dao/FabricDAO');
+const cacheService = require('./CacheService');
+const { errorCodes } = require('../../config');
+
+/**
+ * Consignment service layer
+ * Records export consignments of batches and products and their path through phytosanitary inspection,
+ * customs clearance and shipment
+ */
+class ConsignmentService {
+
+  /**
+   * Prepare an export consignment
+   * @param {string} role - Caller role
+   * @param {Object} consignment - { consignmentId, destinationCountry, batchIds?, productIds? }
+   * @returns {Promise<Object>} { consignmentId, status }
+   */
+  async createConsignment(role, consignment) {
+    const { consignmentId, destinationCountry, batchIds = [], productIds = [] } = consignment;
+    if (!consignmentId || !destinationCountry) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: consignmentId and destinationCountry are required`);
+    }
+    if (!Array.isArray(batchIds) || !Array.isArray(productIds) || batchIds.length + productIds.length === 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: batchIds and productIds must be lists with at least one item between them`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'ConsignmentContract:CreateConsignment',
+        consignmentId, destinationCountry, JSON.stringify({ batchIds, productIds }));
+      return { consignmentId, status: 'Prepared' };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message}`);
+      }
+      if (error.message.includes('compliance profile')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to create consignment: ${error.message}`);
+    }
+  }
+
+  /**
+   * Move a consignment to its next status
+   * @param {string} role - Caller role
+   * @param {string} consignmentId - Consignment ID
+   * @param {Object} change - { status: Inspected, Cleared or Shipped, phytosanitaryCertificateHash (Inspected),
+   *   customsDeclarationRef (Cleared), note? }
+   * @returns {Promise<Object>} { consignmentId, status }
+   */
+  async advanceConsignment(role, consignmentId, change) {
+    const { status, phytosanitaryCertificateHash = '', customsDeclarationRef = '', note = '' } = change;
+    const reference = status === 'Inspected' ? phytosanitaryCertificateHash : status === 'Cleared' ? customsDeclarationRef : '';
+
+    try {
+      await fabricDAO.submitTransaction(role, 'ConsignmentContract:AdvanceConsignment', consignmentId, status, reference, note);
+      return { consignmentId, status };
+    } catch (error) {
+      throw this._wrap(error, consignmentId, 'advance consignment');
+    }
+  }
+
+  /**
+   * Hand every batch and product of a cleared or shipped consignment over to the importer
+   * The chaincode hands over at most 100 items per transaction; larger consignments are transferred in
+   * consecutive transactions until none remain. A failed chunk stops the transfer, and a new request resumes it
+   * @param {string} role - Caller role
+   * @param {string} consignmentId - Consignment ID
+   * @param {Object} transfer - { newOwner, newOwnerMspId? }
+   * @param {string} [clientRequestId] - Idempotency key; each chunk is submitted with the key and its position
+   * @returns {Promise<Object>} { consignmentId, newOwner, transferred, transactions, remaining, complete }
+   */
+  async transferConsignment(role, consignmentId, transfer, clientRequestId = '') {
+    const { newOwner, newOwnerMspId = '' } = transfer;
+    if (!newOwner) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: newOwner is required`);
+    }
+
+    let transferred = 0;
+    let transactions = 0;
+    let progress;
+    try {
+      do {
+        const remaining = progress ? progress.remaining : Infinity;
+        const result = await fabricDAO.submitTransaction(role, 'ConsignmentContract:TransferConsignment',
+          consignmentId, newOwner, newOwnerMspId, clientRequestId ? `${clientRequestId}:${transactions}` : '');
+        progress = JSON.parse(new TextDecoder().decode(result));
+        transferred += progress.transferred;
+        transactions++;
+        // A dry run is evaluated and never progresses past its first chunk
+        if (progress.remaining >= remaining) {
+          break;
+        }
+      } while (!progress.complete);
+    } catch (error) {
+      if (/Permission denied/.test(error.message)) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (/being transferred to|already been transferred|can only be transferred once|reserved for|disposed of/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw this._wrap(error, consignmentId, 'transfer consignment');
+    } finally {
+      if (transactions > 0) {
+        await cacheService.invalidateBatchList();
+      }
+    }
+
+    const consignment = await this.getConsignment(role, consignmentId);
+    for (const batchId of consignment.batchIds) {
+      await cacheService.invalidateBatchCache(batchId);
+    }
+    for (const productId of consignment.productIds) {
+      await cacheService.invalidateProductCache(productId);
+    }
+    return { ...progress, transferred, transactions };
+  }
+
+  /**
+   * Get a consignment with its status history
+   * @param {string} role - Caller role
+   * @param {string} consignmentId - Consignment ID
+   * @returns {Promise<Object>} Consignment
+   */
+  async getConsignment(role, consignmentId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ConsignmentContract:ReadConsignment', consignmentId);
+    } catch (error) {
+      throw this._wrap(error, consignmentId, 'get consignment');
+    }
+  }
+
+  /**
+   * Get the consignments a batch or product was exported in
+   * @param {string} role - Caller role
+   * @param {string} entityId - Batch or product ID
+   * @returns {Promise<Array>} Consignments
+   */
+  async getConsignmentsByEntity(role, entityId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ConsignmentContract:GetConsignmentsByEntity', entityId);
+    } catch (error) {
+      throw new Error(`Failed to get consignments: ${error.message}`);
+    }
+  }
+
+  /**
+   * Map chaincode errors about unknown consignments to NOT_FOUND
+   * @private
+   */
+  _wrap(error, consignmentId, action) {
+    if (error.message.includes(`consignment ${consignmentId} does not exist`)) {
+      return new Error(`${errorCodes.NOT_FOUND}: Consignment ${consignmentId} does not exist`);
+    }
+    return new Error(`Failed to ${action}: ${error.message}`);
+  }
+}
+
+module.exports = new ConsignmentService();
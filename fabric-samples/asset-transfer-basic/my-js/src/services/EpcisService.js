@@ -0,0 +1,75 @@
+const fabricDAO = require('../dao/FabricDAO');
+const cacheService = require('./CacheService');
+const { errorCodes } = require('../../config');
+
+/**
+ * EPCIS service layer
+ * Imports EPCIS 2.0 capture documents from partner systems and reads the logistics units and shipments built from them
+ */
+class EpcisService {
+
+  /**
+   * Import an EPCIS 2.0 capture document
+   * Events that cannot be imported are skipped by the chaincode and listed in the result
+   * @param {string} role - Caller role
+   * @param {Object} document - EPCISDocument (JSON / JSON-LD binding)
+   * @returns {Promise<Object>} Import result ({ documentHash, eventCount, processingRecords, shipments, logisticsUnits, batchIds, skipped })
+   */
+  async importDocument(role, document) {
+    if (!document || document.type !== 'EPCISDocument') {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Request body must be an EPCIS capture document (type EPCISDocument)`);
+    }
+    if (!document.epcisBody || !Array.isArray(document.epcisBody.eventList)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: EPCIS document has no epcisBody.eventList`);
+    }
+
+    try {
+      const result = JSON.parse(await fabricDAO.submitAsyncTransaction(role, 'EpcisImportContract:ImportEpcisDocument', {
+        arguments: [JSON.stringify(document)]
+      }));
+
+      for (const batchId of result.batchIds) {
+        await cacheService.invalidateBatchCache(batchId);
+      }
+      return result;
+    } catch (error) {
+      throw new Error(`Failed to import EPCIS document: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get a logistics unit built from AggregationEvents
+   * @param {string} role - Caller role
+   * @param {string} unitId - Parent EPC of the unit (e.g. an SSCC)
+   * @returns {Promise<Object>} Logistics unit
+   */
+  async getLogisticsUnit(role, unitId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'EpcisImportContract:ReadLogisticsUnit', unitId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Logistics unit ${unitId} does not exist`);
+      }
+      throw new Error(`Failed to get logistics unit: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get a shipment imported from a shipping ObjectEvent
+   * @param {string} role - Caller role
+   * @param {string} shipmentId - EPCIS event ID of the shipping event
+   * @returns {Promise<Object>} Shipment
+   */
+  async getShipment(role, shipmentId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'EpcisImportContract:ReadShipment', shipmentId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Shipment ${shipmentId} does not exist`);
+      }
+      throw new Error(`Failed to get shipment: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new EpcisService();
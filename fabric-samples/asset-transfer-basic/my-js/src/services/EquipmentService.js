@@ -0,0 +1,117 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Equipment service layer
+ * Registers processing equipment (dryers, mills, color sorters, packaging lines), records its calibration and
+ * maintenance, and lists the batches processed on it
+ */
+class EquipmentService {
+
+  /**
+   * Register a piece of equipment operated by the caller's organization
+   * @param {string} role - Caller role
+   * @param {Object} equipment - { equipmentId, equipmentType, name, location, lastCalibrationDate, nextCalibrationDue }
+   * @returns {Promise<Object>} { equipmentId }
+   */
+  async registerEquipment(role, equipment) {
+    const { equipmentId, equipmentType, name, location, lastCalibrationDate, nextCalibrationDue } = equipment;
+    if (!equipmentId || !equipmentType || !name || !location || !lastCalibrationDate || !nextCalibrationDue) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: equipmentId, equipmentType, name, location, lastCalibrationDate and nextCalibrationDue are required`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'EquipmentContract:RegisterEquipment',
+        equipmentId, equipmentType, name, location, lastCalibrationDate, nextCalibrationDue);
+      return { equipmentId };
+    } catch (error) {
+      throw new Error(`Failed to register equipment: ${error.message}`);
+    }
+  }
+
+  /**
+   * Record a calibration of a piece of equipment
+   * @param {string} role - Caller role
+   * @param {string} equipmentId - Equipment ID
+   * @param {Object} calibration - { calibratedAt, nextCalibrationDue, description?, documentHash? }
+   * @returns {Promise<Object>} { equipmentId }
+   */
+  async recordCalibration(role, equipmentId, calibration) {
+    const { calibratedAt, nextCalibrationDue, description = '', documentHash = '' } = calibration;
+    if (!calibratedAt || !nextCalibrationDue) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: calibratedAt and nextCalibrationDue are required`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'EquipmentContract:RecordCalibration',
+        equipmentId, calibratedAt, nextCalibrationDue, description, documentHash);
+      return { equipmentId };
+    } catch (error) {
+      throw this._wrap(error, equipmentId, 'record calibration');
+    }
+  }
+
+  /**
+   * Record maintenance of a piece of equipment
+   * @param {string} role - Caller role
+   * @param {string} equipmentId - Equipment ID
+   * @param {Object} maintenance - { maintainedAt, description, documentHash? }
+   * @returns {Promise<Object>} { equipmentId }
+   */
+  async recordMaintenance(role, equipmentId, maintenance) {
+    const { maintainedAt, description, documentHash = '' } = maintenance;
+    if (!maintainedAt || !description) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: maintainedAt and description are required`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'EquipmentContract:RecordMaintenance', equipmentId, maintainedAt, description, documentHash);
+      return { equipmentId };
+    } catch (error) {
+      throw this._wrap(error, equipmentId, 'record maintenance');
+    }
+  }
+
+  /**
+   * Get a piece of equipment with its service log
+   * @param {string} role - Caller role
+   * @param {string} equipmentId - Equipment ID
+   * @returns {Promise<Object>} Equipment
+   */
+  async getEquipment(role, equipmentId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'EquipmentContract:ReadEquipment', equipmentId);
+    } catch (error) {
+      throw this._wrap(error, equipmentId, 'get equipment');
+    }
+  }
+
+  /**
+   * Get the processing steps run on a piece of equipment in a time range
+   * @param {string} role - Caller role
+   * @param {string} equipmentId - Equipment ID
+   * @param {string} [from] - Start date or time
+   * @param {string} [to] - End date (inclusive) or time
+   * @returns {Promise<Array>} { equipmentId, batchId, step, processedAt } in time order
+   */
+  async getEquipmentUsage(role, equipmentId, from = '', to = '') {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'EquipmentContract:GetEquipmentUsage', equipmentId, from, to);
+    } catch (error) {
+      throw this._wrap(error, equipmentId, 'get equipment usage');
+    }
+  }
+
+  /**
+   * Map chaincode errors about unknown equipment to NOT_FOUND
+   * @private
+   */
+  _wrap(error, equipmentId, action) {
+    if (error.message.includes('is not registered')) {
+      return new Error(`${errorCodes.NOT_FOUND}: Equipment ${equipmentId} is not registered`);
+    }
+    return new Error(`Failed to ${action}: ${error.message}`);
+  }
+}
+
+module.exports = new EquipmentService();
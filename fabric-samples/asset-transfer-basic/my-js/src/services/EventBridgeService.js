@@ -0,0 +1,580 @@
+const fs = require('node:fs/promises');
+const path = require('node:path');
+const crypto = require('node:crypto');
+const { checkpointers } = require('@hyperledger/fabric-gateway');
+const { common, peer } = require('@hyperledger/fabric-protos');
+const fabricDAO = require('../dao/FabricDAO');
+const { runInChannel } = require('../dao/channelContext');
+const { eventBridge, errorCodes, getChannelConfig } = require('../../config');
+
+/**
+ * Chaincode events announcing a change of the notification preference registry
+ */
+const PREFERENCE_EVENTS = ['NotificationPreferenceChanged', 'NotificationPreferenceRemoved'];
+
+/**
+ * Chaincode event of a recall, and the message the bridge derives from it for each current owner
+ */
+const RECALL_EVENT = 'RecallIssued';
+const RECALL_NOTICE = 'RecallNotice';
+
+/**
+ * Event bridge service
+ * Consumes chaincode events and publishes them to Kafka topics and webhooks,
+ * so external systems (ERP, notifications) can integrate without talking to Fabric directly.
+ * Participants are also notified by email, SMS or webhook of the events their on-ledger preferences subscribe to.
+ * A recall is split into one RecallNotice per current owner of the recalled rice, delivered like any event and
+ * notified to that owner only. Past events can be replayed to one sink, so a downstream system can catch up
+ * after an outage
+ */
+class EventBridgeService {
+  constructor() {
+    this.events = null;
+    this.producer = null;
+    this.isRunning = false;
+    this.preferences = [];
+    this.stats = {
+      received: 0,
+      delivered: 0,
+      retried: 0,
+      deadLettered: 0,
+      notificationsSkipped: 0,
+      recallNotices: 0,
+      lastBlock: null
+    };
+  }
+
+  /**
+   * Start listening for chaincode events
+   * Resumes from the last checkpoint, so events are not lost across restarts
+   */
+  async start() {
+    if (this.isRunning) {
+      return;
+    }
+
+    if (!this.hasTargets()) {
+      throw new Error('Event bridge has no targets: configure KAFKA_BROKERS, EVENT_WEBHOOK_URLS and/or EVENT_NOTIFICATIONS_ENABLED');
+    }
+
+    await this._connectKafka();
+    await this._loadPreferences();
+
+    await fs.mkdir(path.dirname(eventBridge.checkpointPath), { recursive: true });
+    const checkpointer = await checkpointers.file(eventBridge.checkpointPath);
+
+    const { chaincodeName } = getChannelConfig(eventBridge.channel);
+    const network = await fabricDAO.getNetwork(eventBridge.role, eventBridge.channel);
+    this.events = await network.getChaincodeEvents(chaincodeName, {
+      checkpoint: checkpointer,
+      startBlock: BigInt(eventBridge.startBlock)
+    });
+    this.isRunning = true;
+    console.log(`Event bridge listening for ${chaincodeName} events on ${eventBridge.channel}`);
+
+    try {
+      for await (const event of this.events) {
+        await this._handleEvent(event);
+        await checkpointer.checkpointChaincodeEvent(event);
+      }
+    } finally {
+      this.isRunning = false;
+    }
+  }
+
+  /**
+   * Replay the chaincode events committed between two blocks (or two times) to one sink
+   * Reads full blocks, so the replay ends at the last block of the range even if it holds no event. Events are
+   * delivered as by the live bridge (with recall notices), marked replayed; participant notifications are not
+   * sent again. The bridge checkpoint is not touched
+   * @param {Object} options - { sink, fromBlock?, toBlock?, from?, to? }; sink is 'kafka' or one of EVENT_WEBHOOK_URLS.
+   *   from/to are times resolved to the first block committed at or after from and the last committed at or
+   *   before to; toBlock defaults to the current chain height
+   * @returns {Promise<Object>} { channel, sink, fromBlock, toBlock, events, delivered, deadLettered }
+   */
+  async replay({ sink, fromBlock, toBlock, from, to } = {}) {
+    const target = await this._getReplaySink(sink);
+    const { chaincodeName } = getChannelConfig(eventBridge.channel);
+    const network = await fabricDAO.getNetwork(eventBridge.role, eventBridge.channel);
+    const range = await this._resolveReplayRange(network, { fromBlock, toBlock, from, to });
+
+    const summary = { channel: eventBridge.channel, sink, ...range, events: 0, delivered: 0, deadLettered: 0 };
+    const deliver = async message => {
+      try {
+        await this._withRetry(() => target.send(message), target.name);
+        summary.delivered++;
+      } catch (error) {
+        await this._deadLetter(target.name, message, error);
+        summary.deadLettered++;
+      }
+    };
+
+    if (range.fromBlock <= range.toBlock) {
+      const blocks = await network.getBlockEvents({ startBlock: BigInt(range.fromBlock) });
+      try {
+        for await (const block of blocks) {
+          for (const message of this._blockMessages(block, chaincodeName)) {
+            summary.events++;
+            await deliver(message);
+            for (const notice of this._toRecallNotices(message)) {
+              await deliver(notice);
+            }
+          }
+          if (Number(block.getHeader().getNumber()) >= range.toBlock) {
+            break;
+          }
+        }
+      } finally {
+        blocks.close();
+        await target.close();
+      }
+    }
+    console.log(`Event bridge replayed ${summary.events} event(s) of blocks ${range.fromBlock}-${range.toBlock} to ${target.name}`);
+    return summary;
+  }
+
+  /**
+   * Whether any delivery target is configured
+   */
+  hasTargets() {
+    return eventBridge.kafka.brokers.length > 0 || eventBridge.webhooks.urls.length > 0 || eventBridge.notifications.enabled;
+  }
+
+  /**
+   * Stop listening and disconnect from Kafka
+   */
+  async stop() {
+    if (this.events) {
+      this.events.close();
+      this.events = null;
+    }
+    if (this.producer) {
+      await this.producer.disconnect();
+      this.producer = null;
+    }
+    this.isRunning = false;
+  }
+
+  /**
+   * Get bridge status
+   */
+  getStatus() {
+    return {
+      isRunning: this.isRunning,
+      kafkaBrokers: eventBridge.kafka.brokers,
+      webhooks: eventBridge.webhooks.urls.length,
+      notificationPreferences: this.preferences.length,
+      ...this.stats
+    };
+  }
+
+  /**
+   * Deliver one chaincode event to every configured target
+   * A target that keeps failing is dead-lettered so it does not block the event stream
+   * @private
+   */
+  async _handleEvent(event) {
+    const message = this._toMessage(event);
+    this.stats.received++;
+    this.stats.lastBlock = message.blockNumber;
+
+    // Route with the registry as it is now, so replayed events never restore outdated preferences
+    if (PREFERENCE_EVENTS.includes(message.eventName)) {
+      await this._loadPreferences();
+    }
+
+    await this._deliver(message);
+    for (const notice of this._toRecallNotices(message)) {
+      this.stats.recallNotices++;
+      await this._deliver(notice);
+    }
+  }
+
+  /**
+   * Send a message to every configured target
+   * @private
+   */
+  async _deliver(message) {
+    const targets = [
+      ...(this.producer ? [{ name: `kafka:${this._getTopic(message.eventName)}`, send: () => this._publishToKafka(message) }] : []),
+      ...eventBridge.webhooks.urls.map(url => ({ name: `webhook:${url}`, send: () => this._postWebhook(url, message) })),
+      ...this._getNotificationTargets(message)
+    ];
+
+    for (const target of targets) {
+      try {
+        await this._withRetry(target.send, target.name);
+        this.stats.delivered++;
+      } catch (error) {
+        await this._deadLetter(target.name, message, error);
+      }
+    }
+  }
+
+  /**
+   * Load the notification preferences registered on the ledger
+   * @private
+   */
+  async _loadPreferences() {
+    if (!eventBridge.notifications.enabled) {
+      return;
+    }
+    this.preferences = await runInChannel(eventBridge.channel, () =>
+      fabricDAO.evaluateTransaction(eventBridge.role, 'NotificationPreferenceContract:GetAllNotificationPreferences'));
+    console.log(`Event bridge routing notifications for ${this.preferences.length} participant(s)`);
+  }
+
+  /**
+   * Notification deliveries of an event: one per channel of every participant subscribed to the event type
+   * and, when the event concerns a batch, to that batch
+   * @private
+   */
+  _getNotificationTargets(message) {
+    const batchId = message.payload && typeof message.payload === 'object' ? message.payload.batchId : undefined;
+    // A recall notice goes to the owner it names, whatever the owner subscribed to
+    const subscribed = message.eventName === RECALL_NOTICE
+      ? this.preferences.filter(preference => preference.participantId === (message.payload.participantId || message.payload.owner))
+      : this.preferences.filter(preference =>
+        (preference.eventTypes.length === 0 || preference.eventTypes.includes(message.eventName)) &&
+        (preference.batchIds.length === 0 || (batchId && preference.batchIds.includes(batchId)))
+      );
+
+    const targets = [];
+    for (const preference of subscribed) {
+      for (const channel of preference.channels) {
+        const relayUrl = channel.type === 'email' ? eventBridge.notifications.emailRelayUrl
+          : channel.type === 'sms' ? eventBridge.notifications.smsRelayUrl : undefined;
+        if (channel.type !== 'webhook' && !relayUrl) {
+          this.stats.notificationsSkipped++;
+          continue;
+        }
+        targets.push({
+          name: `notify:${preference.participantId}:${channel.type}`,
+          send: () => channel.type === 'webhook'
+            ? this._postWebhook(channel.target, message)
+            : this._postRelay(relayUrl, channel.target, message)
+        });
+      }
+    }
+    return targets;
+  }
+
+  /**
+   * Split a recall into one message per owner notice, carrying the recall and the items that owner holds
+   * @private
+   */
+  _toRecallNotices(message) {
+    if (message.eventName !== RECALL_EVENT || !message.payload || !Array.isArray(message.payload.notices)) {
+      return [];
+    }
+    const { notices, batchIds, productIds, ...recall } = message.payload;
+    return notices.map(notice => ({
+      ...message,
+      eventName: RECALL_NOTICE,
+      payload: { ...recall, ...notice }
+    }));
+  }
+
+  /**
+   * Hand a notification to the email or SMS relay
+   * @private
+   */
+  async _postRelay(relayUrl, to, message) {
+    const subject = `RiceTrace: ${message.eventName}`;
+    const subjectId = message.payload && typeof message.payload === 'object'
+      ? message.payload.batchId || message.payload.productId
+      : undefined;
+    let text = subjectId ? `${message.eventName} for ${subjectId} (transaction ${message.transactionId})` : `${message.eventName} (transaction ${message.transactionId})`;
+    if (message.eventName === RECALL_NOTICE) {
+      const { recallId, reason, batchIds, productIds } = message.payload;
+      const items = [...batchIds, ...productIds].join(', ');
+      text = `Recall ${recallId}: ${reason}. Stop selling and set aside: ${items} (transaction ${message.transactionId})`;
+    }
+
+    const response = await fetch(relayUrl, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json', 'X-RiceTrace-Event': message.eventName },
+      body: JSON.stringify({ to, subject, text, message }),
+      signal: AbortSignal.timeout(eventBridge.webhooks.timeout)
+    });
+    if (!response.ok) {
+      throw new Error(`Notification relay responded with HTTP ${response.status}`);
+    }
+  }
+
+  /**
+   * Sink of a replay: the configured Kafka brokers or one configured webhook, so events cannot be sent elsewhere
+   * @private
+   */
+  async _getReplaySink(sink) {
+    if (sink === 'kafka') {
+      if (eventBridge.kafka.brokers.length === 0) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: Kafka is not configured (KAFKA_BROKERS)`);
+      }
+      // The API process has no producer of its own; connect one for the replay
+      const connected = !this.producer;
+      if (connected) {
+        await this._connectKafka();
+      }
+      return {
+        name: 'kafka',
+        send: message => this._publishToKafka(message),
+        close: async () => {
+          if (connected && this.producer) {
+            await this.producer.disconnect();
+            this.producer = null;
+          }
+        }
+      };
+    }
+    if (!eventBridge.webhooks.urls.includes(sink)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: sink must be kafka or one of the configured EVENT_WEBHOOK_URLS`);
+    }
+    return { name: `webhook:${sink}`, send: message => this._postWebhook(sink, message), close: async () => {} };
+  }
+
+  /**
+   * Block range of a replay, from block numbers or times, within the blocks committed so far
+   * @private
+   */
+  async _resolveReplayRange(network, { fromBlock, toBlock, from, to }) {
+    if ((fromBlock !== undefined && from) || (toBlock !== undefined && to)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Give the range as block numbers or as times, not both`);
+    }
+    const parseBlock = (value, name) => {
+      const number = Number(value);
+      if (!Number.isSafeInteger(number) || number < 0) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${name} must be a non-negative block number`);
+      }
+      return number;
+    };
+    const parseTime = (value, name) => {
+      const time = Date.parse(value);
+      if (isNaN(time)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${name} must be a date or RFC3339 time`);
+      }
+      return time;
+    };
+
+    const qscc = network.getContract('qscc');
+    const info = common.BlockchainInfo.deserializeBinary(await qscc.evaluateTransaction('GetChainInfo', eventBridge.channel));
+    const lastBlock = Number(info.getHeight()) - 1;
+    const blockTime = async number => {
+      const block = common.Block.deserializeBinary(await qscc.evaluateTransaction('GetBlockByNumber', eventBridge.channel, String(number)));
+      const payload = common.Payload.deserializeBinary(common.Envelope.deserializeBinary(block.getData().getDataList_asU8()[0]).getPayload_asU8());
+      return common.ChannelHeader.deserializeBinary(payload.getHeader().getChannelHeader_asU8()).getTimestamp().toDate().getTime();
+    };
+    // First block committed at or after a time (lastBlock + 1 when there is none); block times only increase
+    const firstBlockFrom = async time => {
+      let low = 0;
+      let high = lastBlock + 1;
+      while (low < high) {
+        const middle = Math.floor((low + high) / 2);
+        if (await blockTime(middle) >= time) {
+          high = middle;
+        } else {
+          low = middle + 1;
+        }
+      }
+      return low;
+    };
+
+    const start = from ? await firstBlockFrom(parseTime(from, 'from'))
+      : fromBlock !== undefined ? parseBlock(fromBlock, 'fromBlock') : 0;
+    // A bare end date includes the whole day
+    const endTime = to && /^\d{4}-\d{2}-\d{2}$/.test(to) ? parseTime(to, 'to') + 24 * 60 * 60 * 1000 - 1 : to ? parseTime(to, 'to') : undefined;
+    const end = endTime !== undefined ? await firstBlockFrom(endTime + 1) - 1
+      : toBlock !== undefined ? Math.min(parseBlock(toBlock, 'toBlock'), lastBlock) : lastBlock;
+    if (start > lastBlock) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: The channel has no block ${start} yet; its last block is ${lastBlock}`);
+    }
+    if (end - start + 1 > eventBridge.replay.maxBlocks) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: A replay covers at most ${eventBridge.replay.maxBlocks} blocks; split blocks ${start}-${end}`);
+    }
+    return { fromBlock: start, toBlock: end };
+  }
+
+  /**
+   * Messages of the chaincode events of the valid transactions in a block, in the published message format
+   * @private
+   */
+  _blockMessages(block, chaincodeName) {
+    const blockNumber = String(block.getHeader().getNumber());
+    const validationCodes = block.getMetadata().getMetadataList_asU8()[common.BlockMetadataIndex.TRANSACTIONS_FILTER];
+    const messages = [];
+    block.getData().getDataList_asU8().forEach((envelopeBytes, txIndex) => {
+      // Like the live stream, events of invalidated transactions are not delivered
+      if (validationCodes[txIndex] !== peer.TxValidationCode.VALID) {
+        return;
+      }
+      const payload = common.Payload.deserializeBinary(common.Envelope.deserializeBinary(envelopeBytes).getPayload_asU8());
+      const channelHeader = common.ChannelHeader.deserializeBinary(payload.getHeader().getChannelHeader_asU8());
+      if (channelHeader.getType() !== common.HeaderType.ENDORSER_TRANSACTION) {
+        return;
+      }
+      for (const action of peer.Transaction.deserializeBinary(payload.getData_asU8()).getActionsList()) {
+        const actionPayload = peer.ChaincodeActionPayload.deserializeBinary(action.getPayload_asU8());
+        const responsePayload = peer.ProposalResponsePayload.deserializeBinary(actionPayload.getAction().getProposalResponsePayload_asU8());
+        const eventBytes = peer.ChaincodeAction.deserializeBinary(responsePayload.getExtension_asU8()).getEvents_asU8();
+        if (eventBytes.length === 0) {
+          continue;
+        }
+        const event = peer.ChaincodeEvent.deserializeBinary(eventBytes);
+        if (event.getChaincodeId() !== chaincodeName) {
+          continue;
+        }
+        messages.push({
+          ...this._toMessage({
+            eventName: event.getEventName(),
+            transactionId: channelHeader.getTxId(),
+            blockNumber,
+            chaincodeName,
+            payload: event.getPayload_asU8()
+          }),
+          replayed: true
+        });
+      }
+    });
+    return messages;
+  }
+
+  /**
+   * Convert a chaincode event into the published message format
+   * @private
+   */
+  _toMessage(event) {
+    const payloadText = new TextDecoder().decode(event.payload);
+    let payload;
+    try {
+      payload = JSON.parse(payloadText);
+    } catch {
+      payload = payloadText;
+    }
+
+    return {
+      eventName: event.eventName,
+      transactionId: event.transactionId,
+      blockNumber: event.blockNumber.toString(),
+      chaincodeName: event.chaincodeName,
+      channelName: eventBridge.channel,
+      payload
+    };
+  }
+
+  /**
+   * Run a delivery with exponential backoff
+   * @private
+   */
+  async _withRetry(send, targetName) {
+    const { maxAttempts, initialDelay, maxDelay } = eventBridge.retry;
+    let delay = initialDelay;
+
+    for (let attempt = 1; ; attempt++) {
+      try {
+        return await send();
+      } catch (error) {
+        if (attempt >= maxAttempts) {
+          throw error;
+        }
+        console.warn(`Event delivery to ${targetName} failed (attempt ${attempt}/${maxAttempts}): ${error.message}`);
+        this.stats.retried++;
+        await new Promise(resolve => setTimeout(resolve, delay));
+        delay = Math.min(delay * 2, maxDelay);
+      }
+    }
+  }
+
+  /**
+   * Append an undeliverable event to the dead-letter file
+   * @private
+   */
+  async _deadLetter(targetName, message, error) {
+    console.error(`Event ${message.eventName} (${message.transactionId}) dead-lettered for ${targetName}: ${error.message}`);
+    this.stats.deadLettered++;
+
+    const entry = {
+      target: targetName,
+      error: error.message,
+      failedAt: new Date().toISOString(),
+      message
+    };
+    await fs.mkdir(path.dirname(eventBridge.deadLetterPath), { recursive: true });
+    await fs.appendFile(eventBridge.deadLetterPath, `${JSON.stringify(entry)}\n`);
+  }
+
+  /**
+   * Connect the Kafka producer if brokers are configured
+   * kafkajs is an optional dependency, only needed when Kafka is used
+   * @private
+   */
+  async _connectKafka() {
+    if (eventBridge.kafka.brokers.length === 0) {
+      return;
+    }
+
+    let Kafka;
+    try {
+      ({ Kafka } = require('kafkajs'));
+    } catch {
+      throw new Error('KAFKA_BROKERS is set but the kafkajs package is not installed (npm install kafkajs)');
+    }
+
+    const kafka = new Kafka({ clientId: eventBridge.kafka.clientId, brokers: eventBridge.kafka.brokers });
+    this.producer = kafka.producer();
+    await this.producer.connect();
+    console.log(`Event bridge connected to Kafka: ${eventBridge.kafka.brokers.join(', ')}`);
+  }
+
+  /**
+   * Get Kafka topic for an event
+   * @private
+   */
+  _getTopic(eventName) {
+    return `${eventBridge.kafka.topicPrefix}.${eventName}`;
+  }
+
+  /**
+   * Publish a message to Kafka, keyed by transaction ID
+   * @private
+   */
+  async _publishToKafka(message) {
+    await this.producer.send({
+      topic: this._getTopic(message.eventName),
+      messages: [{
+        key: message.transactionId,
+        value: JSON.stringify(message),
+        headers: { eventName: message.eventName }
+      }]
+    });
+  }
+
+  /**
+   * POST a message to a webhook
+   * When a secret is configured the body is signed with HMAC-SHA256 (X-RiceTrace-Signature header)
+   * @private
+   */
+  async _postWebhook(url, message) {
+    const body = JSON.stringify(message);
+    const headers = {
+      'Content-Type': 'application/json',
+      'X-RiceTrace-Event': message.eventName,
+      'X-RiceTrace-Transaction': message.transactionId
+    };
+    if (eventBridge.webhooks.secret) {
+      const signature = crypto.createHmac('sha256', eventBridge.webhooks.secret).update(body).digest('hex');
+      headers['X-RiceTrace-Signature'] = `sha256=${signature}`;
+    }
+
+    const response = await fetch(url, {
+      method: 'POST',
+      headers,
+      body,
+      signal: AbortSignal.timeout(eventBridge.webhooks.timeout)
+    });
+    if (!response.ok) {
+      throw new Error(`Webhook responded with HTTP ${response.status}`);
+    }
+  }
+}
+
+module.exports = new EventBridgeService();
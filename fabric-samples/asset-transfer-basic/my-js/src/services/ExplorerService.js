@@ -0,0 +1,280 @@
+const fs = require('node:fs/promises');
+const path = require('node:path');
+const { checkpointers } = require('@hyperledger/fabric-gateway');
+const { common, ledger, msp, peer } = require('@hyperledger/fabric-protos');
+const fabricDAO = require('../dao/FabricDAO');
+const cacheService = require('./CacheService');
+const { currentChannel } = require('../dao/channelContext');
+const { explorer, errorCodes, getChannelConfig } = require('../../config');
+
+/**
+ * State keys of the traceability entities: batch_<batchId> and product_<productId>
+ */
+const ENTITY_KEY_PATTERN = /^(?:batch|product)_(.+)$/;
+
+/**
+ * Event payload fields naming the batch or product an event is about
+ */
+const ENTITY_PAYLOAD_FIELDS = ['batchId', 'productId', 'entityId'];
+
+// Names of the transaction validation codes, e.g. 11 -> MVCC_READ_CONFLICT
+const VALIDATION_CODES = Object.fromEntries(Object.entries(peer.TxValidationCode).map(([name, code]) => [code, name]));
+
+/**
+ * Transaction explorer service
+ * The indexer runs in the event bridge process: it reads every block of the channel, decodes the endorser
+ * transactions (ID, block, function, invoker MSP, validation code, chaincode event) and records each one under the
+ * batches and products it wrote or announced, in Redis. The API serves those per-entity lists to support staff
+ */
+class ExplorerService {
+  constructor() {
+    this.blocks = null;
+    this.isRunning = false;
+    this.stats = {
+      blocks: 0,
+      transactions: 0,
+      indexed: 0,
+      lastBlock: null
+    };
+  }
+
+  /**
+   * Start indexing blocks
+   * Resumes from the last checkpoint; replayed blocks write the same entries again, so the index stays exact
+   */
+  async start() {
+    if (this.isRunning) {
+      return;
+    }
+
+    await fs.mkdir(path.dirname(explorer.checkpointPath), { recursive: true });
+    const checkpointer = await checkpointers.file(explorer.checkpointPath);
+    await cacheService.connect();
+
+    const network = await fabricDAO.getNetwork(explorer.role, explorer.channel);
+    this.blocks = await network.getBlockEvents({
+      checkpoint: checkpointer,
+      startBlock: BigInt(explorer.startBlock)
+    });
+    this.isRunning = true;
+    console.log(`Explorer indexing blocks of ${explorer.channel}`);
+
+    try {
+      for await (const block of this.blocks) {
+        await this._indexBlock(block);
+        await checkpointer.checkpointBlock(BigInt(block.getHeader().getNumber()));
+      }
+    } finally {
+      this.isRunning = false;
+    }
+  }
+
+  /**
+   * Stop indexing
+   */
+  stop() {
+    if (this.blocks) {
+      this.blocks.close();
+      this.blocks = null;
+    }
+    this.isRunning = false;
+  }
+
+  /**
+   * Get indexer status
+   */
+  getStatus() {
+    return {
+      isRunning: this.isRunning,
+      channel: explorer.channel,
+      ...this.stats
+    };
+  }
+
+  /**
+   * List the transactions that touched a batch or product, oldest first
+   * @param {string} entityId - Batch or product ID
+   * @param {Object} [options] - { limit, offset }
+   * @returns {Promise<Object>} { transactions, total, limit, offset }
+   */
+  async getEntityTransactions(entityId, { limit, offset } = {}) {
+    if (!entityId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch or product ID cannot be empty`);
+    }
+    const pageSize = limit === undefined ? 50 : parseInt(limit, 10);
+    const start = offset === undefined ? 0 : parseInt(offset, 10);
+    if (!Number.isInteger(pageSize) || pageSize < 1 || pageSize > explorer.maxPageSize) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: limit must be between 1 and ${explorer.maxPageSize}`);
+    }
+    if (!Number.isInteger(start) || start < 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: offset must be a non-negative integer`);
+    }
+
+    const client = await cacheService.connect();
+    const key = this._getEntityKey(currentChannel(), entityId);
+    const [total, entries] = await Promise.all([
+      client.zCard(key),
+      client.zRange(key, start, start + pageSize - 1)
+    ]);
+
+    return {
+      transactions: entries.map(entry => JSON.parse(entry)),
+      total,
+      limit: pageSize,
+      offset: start
+    };
+  }
+
+  /**
+   * Record the endorser transactions of a block under the entities they touched
+   * @private
+   */
+  async _indexBlock(block) {
+    const blockNumber = String(block.getHeader().getNumber());
+    const validationCodes = block.getMetadata().getMetadataList_asU8()[common.BlockMetadataIndex.TRANSACTIONS_FILTER];
+    const { chaincodeName } = getChannelConfig(explorer.channel);
+    const client = await cacheService.connect();
+
+    const envelopes = block.getData().getDataList_asU8();
+    for (let txIndex = 0; txIndex < envelopes.length; txIndex++) {
+      const transaction = this._decodeTransaction(envelopes[txIndex], chaincodeName);
+      if (!transaction) {
+        continue;
+      }
+      this.stats.transactions++;
+
+      const record = {
+        txId: transaction.txId,
+        blockNumber,
+        txIndex,
+        timestamp: transaction.timestamp,
+        function: transaction.function,
+        invokerMspId: transaction.invokerMspId,
+        validationCode: VALIDATION_CODES[validationCodes[txIndex]] || String(validationCodes[txIndex]),
+        event: transaction.event
+      };
+      // Ordered by position on the ledger; the member is the record itself, so a replay adds nothing
+      const score = Number(blockNumber) * 100000 + txIndex;
+      for (const entityId of transaction.entityIds) {
+        await client.zAdd(this._getEntityKey(explorer.channel, entityId), { score, value: JSON.stringify(record) });
+        this.stats.indexed++;
+      }
+    }
+
+    this.stats.blocks++;
+    this.stats.lastBlock = blockNumber;
+  }
+
+  /**
+   * Decode an endorser transaction of the chaincode; null for config transactions and other chaincodes
+   * @private
+   */
+  _decodeTransaction(envelopeBytes, chaincodeName) {
+    const payload = common.Payload.deserializeBinary(common.Envelope.deserializeBinary(envelopeBytes).getPayload_asU8());
+    const channelHeader = common.ChannelHeader.deserializeBinary(payload.getHeader().getChannelHeader_asU8());
+    if (channelHeader.getType() !== common.HeaderType.ENDORSER_TRANSACTION) {
+      return null;
+    }
+    const signatureHeader = common.SignatureHeader.deserializeBinary(payload.getHeader().getSignatureHeader_asU8());
+    const creator = msp.SerializedIdentity.deserializeBinary(signatureHeader.getCreator_asU8());
+
+    const transaction = {
+      txId: channelHeader.getTxId(),
+      timestamp: channelHeader.getTimestamp().toDate().toISOString(),
+      invokerMspId: creator.getMspid(),
+      function: null,
+      event: null,
+      entityIds: new Set()
+    };
+
+    let touchesChaincode = false;
+    for (const action of peer.Transaction.deserializeBinary(payload.getData_asU8()).getActionsList()) {
+      const actionPayload = peer.ChaincodeActionPayload.deserializeBinary(action.getPayload_asU8());
+      const proposalPayload = peer.ChaincodeProposalPayload.deserializeBinary(actionPayload.getChaincodeProposalPayload_asU8());
+      const invocation = peer.ChaincodeInvocationSpec.deserializeBinary(proposalPayload.getInput_asU8());
+      const spec = invocation.getChaincodeSpec();
+      if (spec.getChaincodeId().getName() !== chaincodeName) {
+        continue;
+      }
+      touchesChaincode = true;
+      // Only the function name: arguments may carry data the entity's readers must not see
+      transaction.function = Buffer.from(spec.getInput().getArgsList_asU8()[0] || []).toString();
+
+      const responsePayload = peer.ProposalResponsePayload.deserializeBinary(actionPayload.getAction().getProposalResponsePayload_asU8());
+      const chaincodeAction = peer.ChaincodeAction.deserializeBinary(responsePayload.getExtension_asU8());
+      this._addWrittenEntities(chaincodeAction.getResults_asU8(), chaincodeName, transaction.entityIds);
+
+      const eventBytes = chaincodeAction.getEvents_asU8();
+      if (eventBytes.length > 0) {
+        const event = peer.ChaincodeEvent.deserializeBinary(eventBytes);
+        transaction.event = { name: event.getEventName(), payload: this._parsePayload(event.getPayload_asU8()) };
+        this._addAnnouncedEntities(transaction.event.payload, transaction.entityIds);
+      }
+    }
+
+    return touchesChaincode ? transaction : null;
+  }
+
+  /**
+   * Batches and products whose state the transaction wrote
+   * @private
+   */
+  _addWrittenEntities(resultsBytes, chaincodeName, entityIds) {
+    if (resultsBytes.length === 0) {
+      return;
+    }
+    const readWriteSet = ledger.rwset.TxReadWriteSet.deserializeBinary(resultsBytes);
+    for (const namespaceSet of readWriteSet.getNsRwsetList()) {
+      if (namespaceSet.getNamespace() !== chaincodeName) {
+        continue;
+      }
+      const kvSet = ledger.rwset.kvrwset.KVRWSet.deserializeBinary(namespaceSet.getRwset_asU8());
+      for (const write of kvSet.getWritesList()) {
+        const match = ENTITY_KEY_PATTERN.exec(write.getKey());
+        if (match) {
+          entityIds.add(match[1]);
+        }
+      }
+    }
+  }
+
+  /**
+   * Batches and products named by the chaincode event, covering transactions that write only records about
+   * the entity, such as anchored documents
+   * @private
+   */
+  _addAnnouncedEntities(payload, entityIds) {
+    if (!payload || typeof payload !== 'object') {
+      return;
+    }
+    for (const field of ENTITY_PAYLOAD_FIELDS) {
+      if (typeof payload[field] === 'string' && payload[field]) {
+        entityIds.add(payload[field]);
+      }
+    }
+  }
+
+  /**
+   * @private
+   */
+  _parsePayload(bytes) {
+    const text = new TextDecoder().decode(bytes);
+    try {
+      return JSON.parse(text);
+    } catch {
+      return text;
+    }
+  }
+
+  /**
+   * @private
+   */
+  _getEntityKey(channel, entityId) {
+    return `${explorer.keyPrefix}:${channel}:${entityId}`;
+  }
+}
+
+// Create singleton instance
+const explorerService = new ExplorerService();
+
+module.exports = explorerService;
@@ -0,0 +1,407 @@
+const riceService = require('./RiceService');
+const productService = require('./ProductService');
+const consignmentService = require('./ConsignmentService');
+const { toCsv, toXlsx } = require('../export/spreadsheet');
+const { errorCodes } = require('../../config');
+
+/**
+ * Export service layer
+ * Builds spreadsheet exports (CSV or XLSX) of batch histories and batch lists for co-op administrators,
+ * and ISO 22005 traceability audit packages for certification audits
+ */
+
+const EXPORT_FORMATS = {
+  csv: 'text/csv; charset=utf-8',
+  xlsx: 'application/vnd.openxmlformats-officedocument.spreadsheetml.sheet'
+};
+
+const AUDIT_FORMATS = {
+  json: 'application/json; charset=utf-8',
+  xlsx: EXPORT_FORMATS.xlsx
+};
+
+const DATE_PATTERN = /^\d{4}-\d{2}-\d{2}$/;
+
+const TIMELINE_COLUMNS = [
+  { key: 'timestamp', header: 'Timestamp' },
+  { key: 'type', header: 'Type' },
+  { key: 'step', header: 'Step / Test' },
+  { key: 'from', header: 'From' },
+  { key: 'to', header: 'To' },
+  { key: 'result', header: 'Result' },
+  { key: 'reportId', header: 'Report ID' },
+  { key: 'verified', header: 'Verified' },
+  { key: 'signerMspId', header: 'Signed By' },
+  { key: 'details', header: 'Details' }
+];
+
+const TEST_COLUMNS = [
+  { key: 'testId', header: 'Test ID' },
+  { key: 'testType', header: 'Test Type' },
+  { key: 'testDate', header: 'Test Date' },
+  { key: 'testResult', header: 'Result' },
+  { key: 'tester', header: 'Tester' },
+  { key: 'laboratory', header: 'Laboratory' },
+  { key: 'certificationNumber', header: 'Certification Number' },
+  { key: 'sampleId', header: 'Sample ID' },
+  { key: 'isVerified', header: 'Verified' },
+  { key: 'verificationSource', header: 'Verification Source' },
+  { key: 'reportId', header: 'Report ID' },
+  { key: 'reportHash', header: 'Report SHA-256' },
+  { key: 'notes', header: 'Notes' }
+];
+
+const LINK_COLUMNS = [
+  { key: 'id', header: 'Lot ID' },
+  { key: 'type', header: 'Type' },
+  { key: 'party', header: 'Party' },
+  { key: 'operation', header: 'Operation' },
+  { key: 'at', header: 'Date' },
+  { key: 'details', header: 'Details' }
+];
+
+const CUSTODY_COLUMNS = [
+  { key: 'sequence', header: 'Record' },
+  { key: 'timestamp', header: 'Timestamp' },
+  { key: 'type', header: 'Type' },
+  { key: 'step', header: 'Step' },
+  { key: 'from', header: 'From' },
+  { key: 'to', header: 'To' },
+  { key: 'signerMspId', header: 'Signed By' },
+  { key: 'onBehalfOfMspId', header: 'On Behalf Of' },
+  { key: 'reportId', header: 'Report ID' },
+  { key: 'reportHash', header: 'Report Hash' },
+  { key: 'supersededBy', header: 'Superseded By' }
+];
+
+const PARTY_COLUMNS = [
+  { key: 'party', header: 'Party' },
+  { key: 'roles', header: 'Roles' },
+  { key: 'organizations', header: 'Organizations' },
+  { key: 'records', header: 'Records' }
+];
+
+const BATCH_COLUMNS = [
+  { key: 'batchId', header: 'Batch ID' },
+  { key: 'origin', header: 'Origin' },
+  { key: 'variety', header: 'Variety' },
+  { key: 'harvestDate', header: 'Harvest Date' },
+  { key: 'currentOwner', header: 'Current Owner' },
+  { key: 'currentState', header: 'Current Step' },
+  { key: 'workflowId', header: 'Workflow' },
+  { key: 'quarantined', header: 'Quarantined' },
+  { key: 'disposed', header: 'Disposed' },
+  { key: 'historyLength', header: 'History Events' },
+  { key: 'lastUpdated', header: 'Last Updated' }
+];
+
+class ExportService {
+
+  /**
+   * Export a batch's full history: transfers, processing records and test results in time order
+   * CSV holds the timeline; XLSX adds a batch summary sheet and a sheet with all test result details
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} format - csv | xlsx
+   * @returns {Promise<{filename: string, contentType: string, body: Buffer}>} Export file
+   */
+  async exportBatchHistory(role, batchId, format) {
+    this._validateFormat(format);
+
+    try {
+      const batch = await riceService.getBatchById(role, batchId);
+      const testResults = await riceService.getTestResultsByBatch(role, batchId);
+
+      const timeline = [
+        ...(batch.history || []).map(event => ({
+          timestamp: event.timestamp,
+          type: this._historyEventType(event),
+          step: event.step,
+          from: event.from,
+          to: event.to,
+          reportId: event.report && event.report.reportId,
+          verified: !!(event.report && event.report.isVerified),
+          signerMspId: event.signerMspId,
+          details: event.report && (event.report.summary || event.report.notes)
+        })),
+        ...testResults.map(test => ({
+          timestamp: test.testDate || test.timestamp,
+          type: 'Test',
+          step: test.testType,
+          result: test.testResult || test.result,
+          reportId: test.reportId,
+          verified: !!test.isVerified,
+          signerMspId: test.signerMspId,
+          details: [test.laboratory, test.tester, test.notes].filter(Boolean).join(' / ')
+        }))
+      ].sort((a, b) => String(a.timestamp).localeCompare(String(b.timestamp)));
+
+      const timelineSheet = { name: 'History', columns: TIMELINE_COLUMNS, rows: timeline };
+      const body = format === 'csv'
+        ? toCsv(timelineSheet)
+        : toXlsx([
+          { name: 'Batch', columns: BATCH_COLUMNS, rows: [this._batchRow(batch)] },
+          timelineSheet,
+          { name: 'Tests', columns: TEST_COLUMNS, rows: testResults }
+        ]);
+
+      return { filename: `batch-${batchId}-history.${format}`, contentType: EXPORT_FORMATS[format], body };
+    } catch (error) {
+      throw new Error(`Failed to export batch history: ${error.message}`);
+    }
+  }
+
+  /**
+   * Export a filtered list of batches
+   * @param {string} role - Caller role
+   * @param {Object} filters - Optional filters: step, owner, variety, origin, harvestedFrom, harvestedTo (YYYY-MM-DD), quarantined
+   * @param {string} format - csv | xlsx
+   * @returns {Promise<{filename: string, contentType: string, body: Buffer}>} Export file
+   */
+  async exportBatchList(role, filters, format) {
+    this._validateFormat(format);
+    for (const field of ['harvestedFrom', 'harvestedTo']) {
+      if (filters[field] && !DATE_PATTERN.test(filters[field])) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${field} must be a date in YYYY-MM-DD format`);
+      }
+    }
+
+    try {
+      const batches = filters.step
+        ? await riceService.getBatchesByStep(role, filters.step)
+        : await riceService.getAllBatches(role);
+
+      const matches = (value, filter) => !filter || String(value || '').toLowerCase() === String(filter).toLowerCase();
+      const rows = batches
+        .filter(batch => matches(batch.currentOwner, filters.owner))
+        .filter(batch => matches(batch.variety, filters.variety))
+        .filter(batch => matches(batch.origin, filters.origin))
+        .filter(batch => !filters.harvestedFrom || String(batch.harvestDate).slice(0, 10) >= filters.harvestedFrom)
+        .filter(batch => !filters.harvestedTo || String(batch.harvestDate).slice(0, 10) <= filters.harvestedTo)
+        .filter(batch => filters.quarantined === undefined || !!batch.quarantined === (filters.quarantined === 'true'))
+        .map(batch => this._batchRow(batch))
+        .sort((a, b) => a.batchId.localeCompare(b.batchId));
+
+      const sheet = { name: 'Batches', columns: BATCH_COLUMNS, rows };
+      const body = format === 'csv' ? toCsv(sheet) : toXlsx([sheet]);
+      const date = new Date().toISOString().slice(0, 10);
+
+      return { filename: `batches-${date}.${format}`, contentType: EXPORT_FORMATS[format], body, count: rows.length };
+    } catch (error) {
+      throw new Error(`Failed to export batches: ${error.message}`);
+    }
+  }
+
+  /**
+   * Build an ISO 22005 traceability audit package of a batch (lot) for certification audits
+   * Holds the lot identification, the one-step-back sources and one-step-forward destinations of the lot, the
+   * custody records, the responsible parties, and the quality records (tests, certificates, corrections)
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} format - json | xlsx
+   * @returns {Promise<{filename: string, contentType: string, body: Buffer}>} Export file
+   */
+  async exportAuditPackage(role, batchId, format) {
+    if (!AUDIT_FORMATS[format]) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Unsupported audit package format ${format}, available formats: ${Object.keys(AUDIT_FORMATS).join(', ')}`);
+    }
+
+    try {
+      const batch = await riceService.getBatchById(role, batchId);
+      const [genealogy, testResults, certificates, products, consignments] = await Promise.all([
+        riceService.getBatchGenealogy(role, batchId, 1),
+        riceService.getTestResultsByBatch(role, batchId),
+        riceService.getCertificatesByBatch(role, batchId),
+        productService.getProductsByBatch(role, batchId),
+        consignmentService.getConsignmentsByEntity(role, batchId)
+      ]);
+      const history = batch.history || [];
+      const nodes = new Map((genealogy.nodes || []).map(node => [node.id, node]));
+
+      const oneStepBack = (genealogy.edges || [])
+        .filter(edge => edge.to === batchId)
+        .map(edge => ({
+          id: edge.from,
+          type: (nodes.get(edge.from) || {}).type,
+          party: (nodes.get(edge.from) || {}).owner,
+          operation: edge.operation,
+          at: edge.at
+        }));
+      if (history.length > 0) {
+        // The registration is the receipt of the lot from its primary producer
+        oneStepBack.unshift({
+          id: batchId,
+          type: 'origin',
+          party: history[0].to,
+          operation: 'harvest',
+          at: batch.harvestDate,
+          details: batch.origin
+        });
+      }
+
+      const oneStepForward = [
+        ...products.map(product => ({
+          id: product.productId,
+          type: 'product',
+          party: product.owner,
+          operation: 'packaging',
+          at: product.packageDate,
+          details: product.status
+        })),
+        ...consignments.map(consignment => ({
+          id: consignment.consignmentId,
+          type: 'consignment',
+          party: consignment.exporterMspId,
+          operation: 'export',
+          at: consignment.createdAt,
+          details: `${consignment.destinationCountry} (${consignment.status})`
+        }))
+      ];
+
+      const custody = history.map((event, index) => ({
+        sequence: index,
+        timestamp: event.timestamp,
+        type: this._historyEventType(event),
+        step: event.step,
+        from: event.from,
+        to: event.to,
+        signerMspId: event.signerMspId,
+        onBehalfOfMspId: event.onBehalfOfMspId,
+        reportId: event.report && event.report.reportId,
+        reportHash: event.report && event.report.reportHash,
+        supersededBy: event.supersededBy
+      }));
+
+      const lot = {
+        lotId: batchId,
+        origin: batch.origin,
+        variety: batch.variety,
+        harvestDate: batch.harvestDate,
+        cropYear: batch.cropYear,
+        season: batch.season,
+        quantityKg: batch.quantityKg,
+        currentOwner: batch.currentOwner,
+        currentState: batch.currentState,
+        quarantined: !!batch.quarantined,
+        disposed: !!batch.disposal,
+        labels: batch.labels || {}
+      };
+      const parties = this._responsibleParties(history, testResults);
+      const generatedAt = new Date().toISOString();
+
+      const body = format === 'json'
+        ? Buffer.from(JSON.stringify({
+          standard: 'ISO 22005:2007',
+          generatedAt,
+          lot,
+          oneStepBack,
+          oneStepForward,
+          custody,
+          responsibleParties: parties,
+          qualityRecords: { tests: testResults, certificates, corrections: batch.corrections || [] },
+          disposal: batch.disposal || null,
+          genealogyTruncated: !!genealogy.truncated
+        }, null, 2))
+        : toXlsx([
+          {
+            name: 'Lot',
+            columns: [{ key: 'field', header: 'Field' }, { key: 'value', header: 'Value' }],
+            rows: [
+              { field: 'Standard', value: 'ISO 22005:2007' },
+              { field: 'Generated At', value: generatedAt },
+              ...Object.entries(lot).map(([field, value]) => ({ field, value: typeof value === 'object' ? JSON.stringify(value) : value }))
+            ]
+          },
+          { name: 'One Step Back', columns: LINK_COLUMNS, rows: oneStepBack },
+          { name: 'One Step Forward', columns: LINK_COLUMNS, rows: oneStepForward },
+          { name: 'Custody', columns: CUSTODY_COLUMNS, rows: custody },
+          { name: 'Parties', columns: PARTY_COLUMNS, rows: parties.map(party => ({ ...party, roles: party.roles.join(', '), organizations: party.organizations.join(', ') })) },
+          { name: 'Tests', columns: TEST_COLUMNS, rows: testResults }
+        ]);
+
+      return { filename: `batch-${batchId}-iso22005-audit.${format}`, contentType: AUDIT_FORMATS[format], body };
+    } catch (error) {
+      throw new Error(`Failed to export audit package: ${error.message}`);
+    }
+  }
+
+  /**
+   * Parties responsible for a lot: holders and processors from the custody records, and testers and
+   * laboratories from the test results, with the organizations that signed for them
+   * @private
+   */
+  _responsibleParties(history, testResults) {
+    const parties = new Map();
+    const add = (party, role, mspId) => {
+      if (!party) {
+        return;
+      }
+      const entry = parties.get(party) || { party, roles: [], organizations: [], records: 0 };
+      if (!entry.roles.includes(role)) {
+        entry.roles.push(role);
+      }
+      if (mspId && !entry.organizations.includes(mspId)) {
+        entry.organizations.push(mspId);
+      }
+      entry.records++;
+      parties.set(party, entry);
+    };
+
+    for (const event of history) {
+      const type = this._historyEventType(event);
+      add(event.to, type === 'Processing' ? 'processor' : 'holder', event.signerMspId);
+      if (type === 'Transfer') {
+        add(event.from, 'supplier', event.signerMspId);
+      }
+    }
+    for (const test of testResults) {
+      add(test.laboratory, 'laboratory', test.signerMspId);
+      add(test.tester, 'tester', test.signerMspId);
+    }
+    return [...parties.values()];
+  }
+
+  /**
+   * Validate the export format
+   * @private
+   */
+  _validateFormat(format) {
+    if (!EXPORT_FORMATS[format]) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Unsupported export format ${format}, available formats: ${Object.keys(EXPORT_FORMATS).join(', ')}`);
+    }
+  }
+
+  /**
+   * Kind of a history event: creation, transfer between owners or processing by the current owner
+   * @private
+   */
+  _historyEventType(event) {
+    if (!event.from) {
+      return 'Created';
+    }
+    return event.from === event.to ? 'Processing' : 'Transfer';
+  }
+
+  /**
+   * Flatten a batch into a spreadsheet row
+   * @private
+   */
+  _batchRow(batch) {
+    const history = batch.history || [];
+    return {
+      batchId: batch.batchId,
+      origin: batch.origin,
+      variety: batch.variety,
+      harvestDate: batch.harvestDate,
+      currentOwner: batch.currentOwner,
+      currentState: batch.currentState,
+      workflowId: batch.workflowId,
+      quarantined: !!batch.quarantined,
+      disposed: !!batch.disposal,
+      historyLength: history.length,
+      lastUpdated: history.length > 0 ? history[history.length - 1].timestamp : ''
+    };
+  }
+}
+
+module.exports = new ExportService();
@@ -0,0 +1,80 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Facility service layer
+ * Registers the sites (drying yards, mills, warehouses, packing plants) processing steps take place at, with their
+ * production lines and shifts, and lists the steps recorded at a site
+ */
+class FacilityService {
+
+  /**
+   * Register a facility operated by the caller's organization, or update one it operates
+   * @param {string} role - Caller role
+   * @param {string} facilityId - Facility ID
+   * @param {Object} facility - { name, facilityType, location, lines?, shifts?: [{ name, start, end }] }
+   * @returns {Promise<Object>} { facilityId }
+   */
+  async registerFacility(role, facilityId, facility) {
+    const { name, facilityType, location, lines, shifts } = facility;
+    if (!facilityId || !name || !facilityType || !location) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: facilityId, name, facilityType and location are required`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'FacilityContract:RegisterFacility', facilityId,
+        JSON.stringify({ name, facilityType, location, lines, shifts }));
+      return { facilityId };
+    } catch (error) {
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (/Invalid facility type|required|must be a list|Shift /.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to register facility: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get a facility with its lines and shifts
+   * @param {string} role - Caller role
+   * @param {string} facilityId - Facility ID
+   * @returns {Promise<Object>} Facility
+   */
+  async getFacility(role, facilityId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'FacilityContract:ReadFacility', facilityId);
+    } catch (error) {
+      throw this._wrap(error, facilityId, 'get facility');
+    }
+  }
+
+  /**
+   * Get the processing steps recorded at a facility
+   * @param {string} role - Caller role
+   * @param {string} facilityId - Facility ID
+   * @param {Object} [filters] - { from?, to?, line?, shift? }
+   * @returns {Promise<Array>} { facilityId, batchId, step, processedAt, line, shift } in time order
+   */
+  async getFacilityActivity(role, facilityId, { from = '', to = '', line = '', shift = '' } = {}) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'FacilityContract:GetFacilityActivity', facilityId, from, to, line, shift);
+    } catch (error) {
+      throw this._wrap(error, facilityId, 'get facility activity');
+    }
+  }
+
+  /**
+   * Map chaincode errors about unknown facilities to NOT_FOUND
+   * @private
+   */
+  _wrap(error, facilityId, action) {
+    if (error.message.includes('is not registered')) {
+      return new Error(`${errorCodes.NOT_FOUND}: Facility ${facilityId} is not registered`);
+    }
+    return new Error(`Failed to ${action}: ${error.message}`);
+  }
+}
+
+module.exports = new FacilityService();
@@ -0,0 +1,86 @@
+const fabricDAO = require('../dao/FabricDAO');
+const cacheService = require('./CacheService');
+const { errorCodes } = require('../../config');
+
+/**
+ * Geographic indication service layer
+ * Manages the GI rules of protected origins (e.g. Wuchang rice) and checks batches against them; a batch carries
+ * the GI claim in product traceability only while its latest check passed
+ */
+class GIService {
+
+  /**
+   * Create or update the rule of a protected origin
+   * @param {string} role - Caller role
+   * @param {string} giId - GI ID, e.g. wuchang
+   * @param {Object} rule - { name, allowedRegions, allowedPlots?, allowedVarieties? }
+   * @returns {Promise<Object>} { giId }
+   */
+  async defineRule(role, giId, rule) {
+    const { name, allowedRegions, allowedPlots = [], allowedVarieties = [] } = rule;
+    if (!name || !Array.isArray(allowedRegions) || allowedRegions.length === 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: name and a non-empty allowedRegions list are required`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'GeographicIndicationContract:DefineGIRule', giId,
+        JSON.stringify({ name, allowedRegions, allowedPlots, allowedVarieties }));
+      return { giId };
+    } catch (error) {
+      throw new Error(`Failed to define GI rule: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get a GI rule
+   * @param {string} role - Caller role
+   * @param {string} giId - GI ID
+   * @returns {Promise<Object>} GI rule
+   */
+  async getRule(role, giId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GeographicIndicationContract:ReadGIRule', giId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: GI rule ${giId} does not exist`);
+      }
+      throw new Error(`Failed to get GI rule: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get all GI rules
+   * @param {string} role - Caller role
+   * @returns {Promise<Array>} GI rules
+   */
+  async getAllRules(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GeographicIndicationContract:GetAllGIRules');
+    } catch (error) {
+      throw new Error(`Failed to get GI rules: ${error.message}`);
+    }
+  }
+
+  /**
+   * Check a batch against a GI rule; the result is recorded on the batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} giId - GI ID
+   * @param {string} [plotId] - Plot the batch was harvested from, required by rules that restrict plots
+   * @returns {Promise<Object>} { giId, giName, ruleVersion, plotId, passed, violations, checkedAt, checkedBy }
+   */
+  async checkCompliance(role, batchId, giId, plotId = '') {
+    try {
+      const check = await fabricDAO.submitTransaction(role, 'GeographicIndicationContract:CheckGICompliance', batchId, giId, plotId);
+      await cacheService.invalidateBatchCache(batchId);
+      return check;
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message}`);
+      }
+      throw new Error(`Failed to check GI compliance: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new GIService();
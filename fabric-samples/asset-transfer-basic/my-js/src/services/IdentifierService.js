@@ -0,0 +1,43 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Identifier service layer
+ * Reads the on-ledger ID policy and draws batch and product IDs that follow it
+ */
+class IdentifierService {
+
+  /**
+   * Get the ID policy in force
+   * @param {string} role - Caller role
+   * @returns {Promise<Object>} { batch?, product?, version }; version 0 and no schemes until configured
+   */
+  async getPolicy(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'IdentifierPolicyContract:GetIdPolicy');
+    } catch (error) {
+      throw new Error(`Failed to get ID policy: ${error.message}`);
+    }
+  }
+
+  /**
+   * Draw the next ID of the caller's organization under the ID policy
+   * @param {string} role - Caller role
+   * @param {string} entity - batch or product
+   * @returns {Promise<string>} New ID
+   */
+  async generateId(role, entity) {
+    const method = entity === 'batch' ? 'GenerateBatchID' : 'GenerateProductID';
+    try {
+      const result = await fabricDAO.submitTransaction(role, `IdentifierPolicyContract:${method}`);
+      return new TextDecoder().decode(result);
+    } catch (error) {
+      if (/defines no .* ID scheme|assigns no .* ID prefix|is exhausted/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to generate ${entity} ID: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new IdentifierService();
@@ -0,0 +1,23 @@
+const fabricDAO = require('../dao/FabricDAO');
+
+/**
+ * Input limit service layer
+ * Reads the on-ledger limits on report sizes, labels and bulk lists, so clients can check requests before submitting
+ */
+class InputLimitService {
+
+  /**
+   * Get the input limits in force
+   * @param {string} role - Caller role
+   * @returns {Promise<Object>} { maxReportBytes, maxLabels, maxBulkItems, version }; version 0 until configured
+   */
+  async getLimits(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'InputLimitContract:GetInputLimits');
+    } catch (error) {
+      throw new Error(`Failed to get input limits: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new InputLimitService();
@@ -0,0 +1,70 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Inspection service layer
+ * Draws batches for regulator spot checks, weighted by risk and seeded by a committed transaction ID, and reads
+ * the recorded draws
+ */
+class InspectionService {
+
+  /**
+   * Draw batches for spot checks
+   * @param {string} role - Caller role
+   * @param {Object} request - { count, seedTxId }; seedTxId is the ID of an already committed transaction
+   * @returns {Promise<Object>} Recorded selection with the weights of all candidates
+   */
+  async selectBatches(role, { count, seedTxId }) {
+    if (!seedTxId || !Number.isInteger(Number(count))) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: an integer count and seedTxId are required`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'InspectionSelectionContract:SelectBatchesForInspection', String(count), seedTxId);
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (error.message.includes('was already used')) {
+        throw new Error(`${errorCodes.ALREADY_EXISTS}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (/Invalid count|seedTxId must|No batches to select/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to select batches for inspection: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get a selection by the seed it was drawn with
+   * @param {string} role - Caller role
+   * @param {string} seedTxId - Seed transaction ID
+   * @returns {Promise<Object>} Selection
+   */
+  async getSelection(role, seedTxId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'InspectionSelectionContract:GetInspectionSelection', seedTxId);
+    } catch (error) {
+      if (error.message.includes('No inspection selection')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: No inspection selection was drawn with seed ${seedTxId}`);
+      }
+      throw new Error(`Failed to get inspection selection: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get all selections, most recent first
+   * @param {string} role - Caller role
+   * @returns {Promise<Array>} Selections
+   */
+  async getSelections(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'InspectionSelectionContract:GetInspectionSelections');
+    } catch (error) {
+      throw new Error(`Failed to get inspection selections: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new InspectionService();
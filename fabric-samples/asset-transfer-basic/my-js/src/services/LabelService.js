@@ -0,0 +1,141 @@
+const crypto = require('node:crypto');
+const riceService = require('./RiceService');
+const productService = require('./ProductService');
+const { toPng, toSvg, toSvgSheet } = require('../export/qrcode');
+const { labels, errorCodes } = require('../../config');
+
+/**
+ * Label service layer
+ * Generates the QR codes packaging lines print: product labels carrying the public trace URL with a fresh
+ * verification code, and batch labels and printable sheets for sacks
+ */
+
+const LABEL_FORMATS = {
+  png: 'image/png',
+  svg: 'image/svg+xml'
+};
+
+// Characters of generated verification codes; no 0/O, 1/I/L or U, which are misread on printed labels
+const CODE_ALPHABET = 'ABCDEFGHJKMNPQRSTVWXYZ23456789';
+
+const MIN_LABEL_SIZE = 64;
+const MAX_LABEL_SIZE = 2048;
+
+class LabelService {
+
+  /**
+   * Generate a product label: a new verification code is registered for the product, replacing the previous one,
+   * and encoded with the public trace URL. Codes cannot be read back from the ledger, so reprinting a label
+   * registers a new code and invalidates the labels printed before
+   * @param {string} role - Caller role
+   * @param {string} productId - Product ID
+   * @param {Object} options - { format: png|svg, size: width in pixels }
+   * @returns {Promise<Object>} { filename, contentType, body, code, url }
+   */
+  async createProductLabel(role, productId, { format = 'png', size } = {}) {
+    const render = this._renderer(format, size);
+    const code = this._generateCode();
+    await productService.registerVerificationCode(role, productId, code);
+
+    const url = `${labels.traceBaseUrl}/product/${encodeURIComponent(productId)}?code=${code}`;
+    return {
+      filename: `product-${productId}.${format}`,
+      contentType: LABEL_FORMATS[format],
+      body: render(url),
+      code,
+      url
+    };
+  }
+
+  /**
+   * Generate the label of a batch, encoding its public trace URL
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object} options - { format: png|svg, size: width in pixels }
+   * @returns {Promise<Object>} { filename, contentType, body, url }
+   */
+  async getBatchLabel(role, batchId, { format = 'png', size } = {}) {
+    const render = this._renderer(format, size);
+    await riceService.getBatchById(role, batchId);
+
+    const url = this._batchUrl(batchId);
+    return {
+      filename: `batch-${batchId}.${format}`,
+      contentType: LABEL_FORMATS[format],
+      body: render(url),
+      url
+    };
+  }
+
+  /**
+   * Generate a printable A4 sheet (SVG) of numbered sack labels for a batch. Each label encodes the batch's public
+   * trace URL with its sack number, and is captioned with the batch, variety and sack number
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object} options - { count: number of sacks, columns: labels per row }
+   * @returns {Promise<Object>} { filename, contentType, body, count }
+   */
+  async getBatchLabelSheet(role, batchId, { count, columns = '3' } = {}) {
+    const sacks = Number(count);
+    if (!Number.isInteger(sacks) || sacks < 1 || sacks > labels.maxSheetLabels) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: count must be an integer from 1 to ${labels.maxSheetLabels}`);
+    }
+    const perRow = Number(columns);
+    if (!Number.isInteger(perRow) || perRow < 1 || perRow > 6) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: columns must be an integer from 1 to 6`);
+    }
+    const batch = await riceService.getBatchById(role, batchId);
+
+    const url = this._batchUrl(batchId);
+    const sheetLabels = Array.from({ length: sacks }, (_, index) => ({
+      text: `${url}?sack=${index + 1}`,
+      caption: [batchId, batch.variety, `sack ${index + 1}/${sacks}`].filter(Boolean).join(' · ')
+    }));
+    return {
+      filename: `batch-${batchId}-sacks.svg`,
+      contentType: LABEL_FORMATS.svg,
+      body: toSvgSheet(sheetLabels, { columns: perRow }),
+      count: sacks
+    };
+  }
+
+  /**
+   * Renderer of a label format and size
+   * @private
+   */
+  _renderer(format, size) {
+    if (!LABEL_FORMATS[format]) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: format must be one of ${Object.keys(LABEL_FORMATS).join(', ')}`);
+    }
+    const width = size === undefined ? 256 : Number(size);
+    if (!Number.isInteger(width) || width < MIN_LABEL_SIZE || width > MAX_LABEL_SIZE) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: size must be an integer from ${MIN_LABEL_SIZE} to ${MAX_LABEL_SIZE}`);
+    }
+
+    return (text) => {
+      try {
+        return format === 'png' ? toPng(text, { width }) : toSvg(text, { size: width });
+      } catch (error) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message}`);
+      }
+    };
+  }
+
+  /**
+   * @private
+   */
+  _batchUrl(batchId) {
+    return `${labels.traceBaseUrl}/batch/${encodeURIComponent(batchId)}`;
+  }
+
+  /**
+   * Random verification code, e.g. K7Q2-9XZ4
+   * @private
+   */
+  _generateCode() {
+    const characters = Array.from({ length: 8 }, () => CODE_ALPHABET[crypto.randomInt(CODE_ALPHABET.length)]);
+    return `${characters.slice(0, 4).join('')}-${characters.slice(4).join('')}`;
+  }
+}
+
+module.exports = new LabelService();
@@ -0,0 +1,91 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Market price service layer
+ * Reads the market price series recorded on the ledger by the designated price oracle
+ */
+
+const DATE_PATTERN = /^\d{4}-\d{2}-\d{2}$/;
+
+class MarketPriceService {
+
+  /**
+   * Get the recorded prices of a variety in a region by date
+   * @param {string} role - Caller role
+   * @param {string} variety - Rice variety
+   * @param {string} region - Region
+   * @param {string} [from] - First date (YYYY-MM-DD)
+   * @param {string} [to] - Last date (YYYY-MM-DD)
+   * @returns {Promise<Array>} Market prices
+   */
+  async getPriceSeries(role, variety, region, from = '', to = '') {
+    this._validateDates({ from, to });
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'MarketPriceContract:GetMarketPriceSeries', variety, region, from, to);
+    } catch (error) {
+      throw new Error(`Failed to get market price series: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the price in force on a day: the latest price recorded on or before it
+   * @param {string} role - Caller role
+   * @param {string} variety - Rice variety
+   * @param {string} region - Region
+   * @param {string} date - Day (YYYY-MM-DD)
+   * @param {string} [maxAgeDays] - Oldest acceptable price, in days before the date (default 7)
+   * @returns {Promise<Object>} Market price
+   */
+  async getReferencePrice(role, variety, region, date, maxAgeDays = '') {
+    if (!date) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: date is required`);
+    }
+    this._validateDates({ date });
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'MarketPriceContract:GetReferencePrice', variety, region, date, String(maxAgeDays));
+    } catch (error) {
+      if (error.message.includes('No market price')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: No market price of ${variety} in ${region} is in force on ${date}`);
+      }
+      throw new Error(`Failed to get reference price: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the price recorded for a day
+   * @param {string} role - Caller role
+   * @param {string} variety - Rice variety
+   * @param {string} region - Region
+   * @param {string} date - Day (YYYY-MM-DD)
+   * @returns {Promise<Object>} Market price
+   */
+  async getMarketPrice(role, variety, region, date) {
+    this._validateDates({ date });
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'MarketPriceContract:ReadMarketPrice', variety, region, date);
+    } catch (error) {
+      if (error.message.includes('No market price')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: No market price of ${variety} in ${region} has been recorded for ${date}`);
+      }
+      throw new Error(`Failed to get market price: ${error.message}`);
+    }
+  }
+
+  /**
+   * Validate YYYY-MM-DD dates
+   * @private
+   */
+  _validateDates(dates) {
+    for (const [field, value] of Object.entries(dates)) {
+      if (value && !DATE_PATTERN.test(value)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${field} must be a date in YYYY-MM-DD format`);
+      }
+    }
+  }
+}
+
+module.exports = new MarketPriceService();
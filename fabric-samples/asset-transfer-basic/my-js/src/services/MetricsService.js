@@ -0,0 +1,142 @@
+/**
+ * Metrics service
+ * Keeps counters and histograms in memory and renders them in the Prometheus text exposition format
+ */
+
+// Default latency buckets in seconds (Fabric commits can take several seconds)
+const DEFAULT_BUCKETS = [0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30];
+
+/**
+ * Render a label set as {name="value",...}
+ * @private
+ */
+function formatLabels(labels) {
+  const entries = Object.entries(labels);
+  if (entries.length === 0) {
+    return '';
+  }
+  const formatted = entries.map(([name, value]) =>
+    `${name}="${String(value).replace(/\\/g, '\\\\').replace(/\n/g, '\\n').replace(/"/g, '\\"')}"`
+  );
+  return `{${formatted.join(',')}}`;
+}
+
+/**
+ * Monotonic counter
+ */
+class Counter {
+  constructor(name, help) {
+    this.name = name;
+    this.help = help;
+    this.values = new Map(); // serialized labels -> { labels, value }
+  }
+
+  inc(labels = {}, value = 1) {
+    const key = formatLabels(labels);
+    const entry = this.values.get(key) || { labels, value: 0 };
+    entry.value += value;
+    this.values.set(key, entry);
+  }
+
+  render() {
+    const lines = [`# HELP ${this.name} ${this.help}`, `# TYPE ${this.name} counter`];
+    for (const [key, entry] of this.values) {
+      lines.push(`${this.name}${key} ${entry.value}`);
+    }
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Histogram with cumulative buckets
+ */
+class Histogram {
+  constructor(name, help, buckets = DEFAULT_BUCKETS) {
+    this.name = name;
+    this.help = help;
+    this.buckets = buckets;
+    this.values = new Map(); // serialized labels -> { labels, counts, sum, count }
+  }
+
+  observe(labels, value) {
+    const key = formatLabels(labels);
+    const entry = this.values.get(key) || { labels, counts: this.buckets.map(() => 0), sum: 0, count: 0 };
+    this.buckets.forEach((bound, index) => {
+      if (value <= bound) {
+        entry.counts[index]++;
+      }
+    });
+    entry.sum += value;
+    entry.count++;
+    this.values.set(key, entry);
+  }
+
+  /**
+   * Start a timer; calling the returned function records the elapsed seconds
+   */
+  startTimer(labels = {}) {
+    const start = process.hrtime.bigint();
+    return (extraLabels = {}) => {
+      const seconds = Number(process.hrtime.bigint() - start) / 1e9;
+      this.observe({ ...labels, ...extraLabels }, seconds);
+      return seconds;
+    };
+  }
+
+  render() {
+    const lines = [`# HELP ${this.name} ${this.help}`, `# TYPE ${this.name} histogram`];
+    for (const entry of this.values.values()) {
+      this.buckets.forEach((bound, index) => {
+        lines.push(`${this.name}_bucket${formatLabels({ ...entry.labels, le: bound })} ${entry.counts[index]}`);
+      });
+      lines.push(`${this.name}_bucket${formatLabels({ ...entry.labels, le: '+Inf' })} ${entry.count}`);
+      lines.push(`${this.name}_sum${formatLabels(entry.labels)} ${entry.sum}`);
+      lines.push(`${this.name}_count${formatLabels(entry.labels)} ${entry.count}`);
+    }
+    return lines.join('\n');
+  }
+}
+
+class MetricsService {
+  constructor() {
+    this.httpRequestsTotal = new Counter(
+      'ricetrace_http_requests_total',
+      'Total HTTP requests by method, route and status code'
+    );
+    this.httpRequestDuration = new Histogram(
+      'ricetrace_http_request_duration_seconds',
+      'HTTP request latency in seconds by method, route and status code'
+    );
+    this.fabricTransactionsTotal = new Counter(
+      'ricetrace_fabric_transactions_total',
+      'Total Fabric transactions by type (evaluate/submit/simulate), chaincode method and outcome (success/error)'
+    );
+    this.fabricTransactionDuration = new Histogram(
+      'ricetrace_fabric_transaction_duration_seconds',
+      'Fabric transaction phase latency in seconds by phase (evaluate/endorse/commit) and chaincode method'
+    );
+
+    this.metrics = [
+      this.httpRequestsTotal,
+      this.httpRequestDuration,
+      this.fabricTransactionsTotal,
+      this.fabricTransactionDuration
+    ];
+  }
+
+  /**
+   * Render all metrics in Prometheus text format
+   * @returns {string} Exposition text
+   */
+  render() {
+    const uptime = [
+      '# HELP ricetrace_process_uptime_seconds Gateway process uptime in seconds',
+      '# TYPE ricetrace_process_uptime_seconds gauge',
+      `ricetrace_process_uptime_seconds ${process.uptime()}`
+    ].join('\n');
+
+    return `${[...this.metrics.map(metric => metric.render()), uptime].join('\n\n')}\n`;
+  }
+}
+
+module.exports = new MetricsService();
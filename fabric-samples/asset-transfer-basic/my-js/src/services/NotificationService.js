@@ -0,0 +1,80 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Notification preference service layer
+ * Manages the on-ledger registry of which events participants want to be notified of, and on which channels;
+ * the event bridge routes email, SMS and webhook notifications by it
+ */
+class NotificationService {
+
+  /**
+   * Register or replace the notification preferences of a participant of the caller's organization
+   * @param {string} role - Caller role
+   * @param {string} participantId - Participant ID
+   * @param {Object} preference - { eventTypes?, batchIds?, channels: [{ type: email|sms|webhook, target }] }
+   * @returns {Promise<Object>} { participantId }
+   */
+  async setPreference(role, participantId, preference) {
+    const { eventTypes = [], batchIds = [], channels } = preference;
+    if (!Array.isArray(channels) || channels.length === 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: a non-empty channels list is required`);
+    }
+    if (!Array.isArray(eventTypes) || !Array.isArray(batchIds)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: eventTypes and batchIds must be lists`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'NotificationPreferenceContract:SetNotificationPreference', participantId,
+        JSON.stringify({ eventTypes, batchIds, channels }));
+      return { participantId };
+    } catch (error) {
+      if (/Unknown notification channel|Invalid (email|sms|webhook) target|must be an array|At most/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to set notification preferences: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the notification preferences of a participant
+   * @param {string} role - Caller role
+   * @param {string} participantId - Participant ID
+   * @returns {Promise<Object>} Notification preferences
+   */
+  async getPreference(role, participantId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'NotificationPreferenceContract:GetNotificationPreference', participantId);
+    } catch (error) {
+      throw this._wrap(error, participantId, 'get notification preferences');
+    }
+  }
+
+  /**
+   * Remove the notification preferences of a participant
+   * @param {string} role - Caller role
+   * @param {string} participantId - Participant ID
+   * @returns {Promise<Object>} { participantId }
+   */
+  async removePreference(role, participantId) {
+    try {
+      await fabricDAO.submitTransaction(role, 'NotificationPreferenceContract:RemoveNotificationPreference', participantId);
+      return { participantId };
+    } catch (error) {
+      throw this._wrap(error, participantId, 'remove notification preferences');
+    }
+  }
+
+  /**
+   * Map chaincode errors about participants without preferences to NOT_FOUND
+   * @private
+   */
+  _wrap(error, participantId, action) {
+    if (error.message.includes('has no notification preferences')) {
+      return new Error(`${errorCodes.NOT_FOUND}: Participant ${participantId} has no notification preferences`);
+    }
+    return new Error(`Failed to ${action}: ${error.message}`);
+  }
+}
+
+module.exports = new NotificationService();
@@ -0,0 +1,197 @@
+const { gateway, peer } = require('@hyperledger/fabric-protos');
+const fabricDAO = require('../dao/FabricDAO');
+const { offlineSignableMethods, canSignOffline, errorCodes } = require('../../config');
+
+// Order of the P-256 curve; Fabric only accepts ECDSA signatures with s in the lower half
+const P256_ORDER = BigInt('0xffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551');
+
+/**
+ * Offline signing service layer
+ * Lets organizations whose signing keys never leave an air-gapped HSM submit transactions through the gateway:
+ * the gateway prepares each message, the client signs its digest offline and posts the signature back. Nothing is
+ * kept between calls; the client carries the bytes of the current stage from one call to the next
+ */
+class OfflineSigningService {
+
+  /**
+   * Prepare the proposal of a transaction for the offline identity of the request
+   * Only the transactions of offlineSignableMethods whose permission the role holds can be prepared
+   * @param {string} role - Caller role
+   * @param {Object} request - { method, args?, transientData? }; method is the chaincode function, e.g. RecallContract:IssueRecall
+   * @returns {Promise<Object>} { transactionId, stage: 'proposal', bytes, digest } with bytes and digest in base64
+   */
+  async prepare(role, { method, args = [], transientData }) {
+    if (!method || typeof method !== 'string') {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: method is required`);
+    }
+    this._checkMethod(role, method);
+    if (!Array.isArray(args) || args.some(arg => typeof arg !== 'string')) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: args must be a list of strings`);
+    }
+    if (transientData !== undefined && (typeof transientData !== 'object' || transientData === null || Array.isArray(transientData))) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: transientData must be an object of strings`);
+    }
+
+    const options = { arguments: args };
+    if (transientData) {
+      options.transientData = Object.fromEntries(Object.entries(transientData)
+        .map(([key, value]) => [key, typeof value === 'string' ? value : JSON.stringify(value)]));
+    }
+    return this._encode(await fabricDAO.prepareOfflineTransaction(role, method, options));
+  }
+
+  /**
+   * Accept the client's signature of the current stage and run it
+   * The bytes are decoded first: they must belong to the transaction of the URL and, for a proposal, call a
+   * transaction the role may sign offline, since the client could post bytes other than the prepared ones
+   * @param {string} role - Caller role
+   * @param {string} transactionId - Transaction ID returned by prepare
+   * @param {Object} request - { stage, bytes, signature }; bytes as returned by the previous call, signature of its
+   *   digest (base64, DER or raw r||s ECDSA)
+   * @returns {Promise<Object>} The next stage to sign, or { stage: 'committed', status } once committed
+   */
+  async sign(role, transactionId, { stage, bytes, signature }) {
+    if (!bytes || !signature) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: bytes and signature are required`);
+    }
+
+    const stageBytes = Buffer.from(bytes, 'base64');
+    const signed = this._decode(stage, stageBytes);
+    if (signed.transactionId !== transactionId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: The signed bytes belong to transaction ${signed.transactionId}, not ${transactionId}`);
+    }
+    if (signed.method !== undefined) {
+      this._checkMethod(role, signed.method);
+    }
+
+    const next = await fabricDAO.signOfflineTransaction(role, stage, stageBytes, this._toFabricSignature(Buffer.from(signature, 'base64')));
+    if (next.status && !next.status.successful) {
+      throw new Error(`${errorCodes.TRANSACTION_INVALID}: Transaction ${transactionId} was committed as invalid with validation code ${next.status.validationCode}`);
+    }
+    return this._encode(next);
+  }
+
+  /**
+   * Refuse chaincode transactions the role may not sign offline
+   * @private
+   */
+  _checkMethod(role, method) {
+    if (!offlineSignableMethods[method]) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: ${method} cannot be signed offline; allowed: ${Object.keys(offlineSignableMethods).join(', ')}`);
+    }
+    if (!canSignOffline(role, method)) {
+      throw new Error(`${errorCodes.PERMISSION_DENIED}: Role '${role}' does not have permission to sign ${method}`);
+    }
+  }
+
+  /**
+   * Read the transaction ID, and the chaincode function of a proposal, from the bytes of a stage
+   * The bytes are the messages the gateway client serializes: ProposedTransaction, PreparedTransaction and
+   * SignedCommitStatusRequest
+   * @private
+   * @returns {Object} { transactionId, method? }
+   */
+  _decode(stage, bytes) {
+    try {
+      if (stage === 'proposal') {
+        const proposed = gateway.ProposedTransaction.deserializeBinary(bytes);
+        const proposal = peer.Proposal.deserializeBinary(proposed.getProposal().getProposalBytes_asU8());
+        const payload = peer.ChaincodeProposalPayload.deserializeBinary(proposal.getPayload_asU8());
+        const spec = peer.ChaincodeInvocationSpec.deserializeBinary(payload.getInput_asU8()).getChaincodeSpec();
+        return {
+          transactionId: proposed.getTransactionId(),
+          method: Buffer.from(spec.getInput().getArgsList_asU8()[0] || []).toString()
+        };
+      }
+      if (stage === 'transaction') {
+        return { transactionId: gateway.PreparedTransaction.deserializeBinary(bytes).getTransactionId() };
+      }
+      if (stage === 'commit') {
+        const signedRequest = gateway.SignedCommitStatusRequest.deserializeBinary(bytes);
+        return { transactionId: gateway.CommitStatusRequest.deserializeBinary(signedRequest.getRequest_asU8()).getTransactionId() };
+      }
+    } catch (error) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: bytes are not the ${stage} of an offline transaction: ${error.message}`);
+    }
+    throw new Error(`${errorCodes.VALIDATION_ERROR}: stage must be one of proposal, transaction, commit`);
+  }
+
+  /**
+   * Encode the binary fields of a stage for JSON; the chaincode result is decoded as text
+   * @private
+   */
+  _encode({ bytes, digest, result, ...stage }) {
+    const encoded = { ...stage };
+    if (bytes) {
+      encoded.bytes = Buffer.from(bytes).toString('base64');
+      encoded.digest = Buffer.from(digest).toString('base64');
+    }
+    if (result) {
+      encoded.result = new TextDecoder().decode(result);
+    }
+    return encoded;
+  }
+
+  /**
+   * Convert an ECDSA P-256 signature to the DER encoding with a low s value that Fabric requires
+   * HSMs commonly return the raw 64-byte r||s form (PKCS#11 CKM_ECDSA)
+   * @private
+   */
+  _toFabricSignature(signature) {
+    let r;
+    let s;
+    if (signature.length === 64) {
+      r = BigInt(`0x${signature.subarray(0, 32).toString('hex')}`);
+      s = BigInt(`0x${signature.subarray(32).toString('hex')}`);
+    } else {
+      [r, s] = this._parseDer(signature);
+    }
+    if (s > P256_ORDER / 2n) {
+      s = P256_ORDER - s;
+    }
+
+    const integer = (value) => {
+      let hex = value.toString(16);
+      if (hex.length % 2) {
+        hex = `0${hex}`;
+      }
+      const bytes = Buffer.from(hex, 'hex');
+      // A leading 1 bit would make the integer negative
+      const content = bytes[0] & 0x80 ? Buffer.concat([Buffer.from([0]), bytes]) : bytes;
+      return Buffer.concat([Buffer.from([0x02, content.length]), content]);
+    };
+    const body = Buffer.concat([integer(r), integer(s)]);
+    return Buffer.concat([Buffer.from([0x30, body.length]), body]);
+  }
+
+  /**
+   * Read r and s from a DER-encoded ECDSA signature
+   * @private
+   */
+  _parseDer(signature) {
+    const invalid = () => new Error(`${errorCodes.VALIDATION_ERROR}: signature must be a DER or raw (r||s) ECDSA P-256 signature`);
+    if (signature.length < 8 || signature[0] !== 0x30 || signature[1] !== signature.length - 2) {
+      throw invalid();
+    }
+    const values = [];
+    let offset = 2;
+    for (let i = 0; i < 2; i++) {
+      if (signature[offset] !== 0x02) {
+        throw invalid();
+      }
+      const length = signature[offset + 1];
+      const value = signature.subarray(offset + 2, offset + 2 + length);
+      if (length === 0 || value.length !== length) {
+        throw invalid();
+      }
+      values.push(BigInt(`0x${value.toString('hex')}`));
+      offset += 2 + length;
+    }
+    if (offset !== signature.length) {
+      throw invalid();
+    }
+    return values;
+  }
+}
+
+module.exports = new OfflineSigningService();
@@ -0,0 +1,161 @@
+const fs = require('node:fs/promises');
+const path = require('node:path');
+const FabricCAServices = require('fabric-ca-client');
+const { User } = require('fabric-common');
+const fabricDAO = require('../dao/FabricDAO');
+const { runAsIdentity } = require('../dao/identityContext');
+const { fabric, enrollment, getRoleConfig, errorCodes } = require('../../config');
+
+const PARTICIPANT_ID_PATTERN = /^[A-Za-z0-9][A-Za-z0-9._-]{2,63}$/;
+
+/**
+ * Participant onboarding service layer
+ * Registers and enrolls new participants with the Fabric CA of their organization, stores the enrolled identity in
+ * the identity registry so requests can act as it, and registers the participant on the ledger with that identity
+ */
+class ParticipantService {
+  constructor() {
+    this.registrars = new Map(); // Enrolled CA registrar per role
+  }
+
+  /**
+   * Onboard a participant: register it with the CA carrying the ricetrace.role attribute, enroll it, store the
+   * identity, then register the participant on the ledger signed by the new identity. A participant whose identity
+   * was stored but whose ledger registration failed is registered again without a new enrollment
+   * @param {Object} participant - { participantId, name, participantRole: farmer|tester|processor, location? }
+   * @returns {Promise<Object>} { participant, identity: { name, role, certPath } }
+   */
+  async onboardParticipant(participant) {
+    const { participantId, name, participantRole, location = '' } = participant;
+    if (!PARTICIPANT_ID_PATTERN.test(participantId || '')) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: participantId must be 3-64 letters, digits, dots, dashes or underscores`);
+    }
+    const role = enrollment.participantRoles[participantRole];
+    if (!role) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: participantRole must be one of ${Object.keys(enrollment.participantRoles).join(', ')}`);
+    }
+
+    const existing = fabric.identities[participantId];
+    if (existing && existing.role !== role) {
+      throw new Error(`${errorCodes.ALREADY_EXISTS}: Identity ${participantId} already exists for role ${existing.role}`);
+    }
+    const identity = existing || await this._enroll(role, participantId, participantRole);
+
+    try {
+      const result = await runAsIdentity(identity.name, () => fabricDAO.submitTransaction(role,
+        'ParticipantRegistryContract:RegisterParticipant', participantId, name || '', participantRole, location));
+      return {
+        participant: JSON.parse(new TextDecoder().decode(result)),
+        identity: { name: identity.name, role, certPath: identity.certPath }
+      };
+    } catch (error) {
+      if (error.message.includes('already exists')) {
+        throw new Error(`${errorCodes.ALREADY_EXISTS}: Participant ${participantId} is already registered`);
+      }
+      if (/is required|Invalid participant role/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to register participant ${participantId} (its identity is enrolled; retry to register it): ${error.message}`);
+    }
+  }
+
+  /**
+   * Get a participant registered on the ledger
+   * @param {string} role - Caller role
+   * @param {string} participantId - Participant ID
+   * @returns {Promise<Object>} Participant
+   */
+  async getParticipant(role, participantId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ParticipantRegistryContract:GetParticipant', participantId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Participant ${participantId} does not exist`);
+      }
+      throw new Error(`Failed to get participant: ${error.message}`);
+    }
+  }
+
+  /**
+   * Register and enroll a participant with the CA of the role's organization, and store the identity
+   * @private
+   */
+  async _enroll(role, participantId, participantRole) {
+    const roleConfig = getRoleConfig(role);
+    try {
+      const ca = await this._getCA(roleConfig);
+      const registrar = await this._getRegistrar(roleConfig, ca);
+      const enrollmentSecret = await ca.register({
+        enrollmentID: participantId,
+        role: 'client',
+        affiliation: enrollment.affiliation,
+        attrs: [{ name: 'ricetrace.role', value: participantRole, ecert: true }]
+      }, registrar);
+      const enrolled = await ca.enroll({ enrollmentID: participantId, enrollmentSecret });
+
+      return await this._storeIdentity({
+        name: participantId,
+        role,
+        mspId: roleConfig.mspId,
+        certificate: enrolled.certificate,
+        privateKey: enrolled.key.toBytes()
+      });
+    } catch (error) {
+      if (/is already registered/.test(error.message)) {
+        throw new Error(`${errorCodes.ALREADY_EXISTS}: ${participantId} is already registered with ${roleConfig.caName}, but its identity is not stored here`);
+      }
+      throw new Error(`Failed to enroll participant ${participantId} with ${roleConfig.caName}: ${error.message}`);
+    }
+  }
+
+  /**
+   * CA client of an organization
+   * @private
+   */
+  async _getCA(roleConfig) {
+    const tlsCert = await fs.readFile(roleConfig.paths.caCertPath, 'utf8');
+    return new FabricCAServices(roleConfig.caEndpoint, { trustedRoots: [tlsCert], verify: true }, roleConfig.caName);
+  }
+
+  /**
+   * Registrar of an organization's CA, enrolled on first use
+   * @private
+   */
+  async _getRegistrar(roleConfig, ca) {
+    if (!this.registrars.has(roleConfig.role)) {
+      const enrolled = await ca.enroll({ enrollmentID: enrollment.registrarId, enrollmentSecret: enrollment.registrarSecret });
+      this.registrars.set(roleConfig.role, User.createUser(enrollment.registrarId, '', roleConfig.mspId, enrolled.certificate, enrolled.key.toBytes()));
+    }
+    return this.registrars.get(roleConfig.role);
+  }
+
+  /**
+   * Write an enrolled identity to the wallet and add it to the identity registry, on disk and in memory
+   * @private
+   */
+  async _storeIdentity({ name, role, mspId, certificate, privateKey }) {
+    const directory = path.resolve(enrollment.walletPath, name);
+    await fs.mkdir(directory, { recursive: true, mode: 0o700 });
+    const certPath = path.join(directory, 'cert.pem');
+    const keyPath = path.join(directory, 'key.pem');
+    await fs.writeFile(certPath, certificate);
+    await fs.writeFile(keyPath, privateKey, { mode: 0o600 });
+
+    let registry = { identities: [] };
+    try {
+      registry = JSON.parse(await fs.readFile(fabric.identitiesPath, 'utf8'));
+    } catch (error) {
+      if (error.code !== 'ENOENT') {
+        throw error;
+      }
+    }
+    const identity = { name, role, mspId, certPath, keyPath };
+    registry.identities = [...(registry.identities || []).filter(entry => entry.name !== name), identity];
+    await fs.writeFile(fabric.identitiesPath, `${JSON.stringify(registry, null, 2)}\n`);
+
+    fabric.identities[name] = identity;
+    return identity;
+  }
+}
+
+module.exports = new ParticipantService();
@@ -1,4 +1,6 @@
 const fabricDAO = require('../dao/FabricDAO');
+const consignmentService = require('./ConsignmentService');
+const cacheService = require('./CacheService');
 const { errorCodes } = require('../../config');
 
 /**
@@ -11,9 +13,10 @@ class ProductService {
    * Create product
    * @param {string} role - Caller role
    * @param {Object} productData - Product data
+   * @param {string} [clientRequestId] - Idempotency key; retries with the same key create the product once
    * @returns {Promise<Object>} Creation result
    */
-  async createProduct(role, productData) {
+  async createProduct(role, productData, clientRequestId = '') {
     // Validate product data
     this._validateProductData(productData);
     
@@ -27,7 +30,7 @@ class ProductService {
       }
 
       // Create product
-      await fabricDAO.submitTransaction(role, 'CreateProduct', productId, batchId, packageDate, owner);
+      await fabricDAO.submitTransaction(role, 'CreateProduct', productId, batchId, packageDate, owner, clientRequestId);
       
       return {
         message: 'Product created successfully',
@@ -36,6 +39,9 @@ class ProductService {
         timestamp: new Date().toISOString()
       };
     } catch (error) {
+      if (/does not follow the ID policy|invalid check digit|assigns no product ID prefix/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
       throw new Error(`Failed to create product: ${error.message}`);
     }
   }
@@ -52,7 +58,39 @@ class ProductService {
     }
 
     try {
-      return await fabricDAO.evaluateTransaction(role, 'ReadProduct', productId);
+      // Try to get from cache first
+      const cachedProduct = await cacheService.getProductDetail(productId);
+      if (cachedProduct !== null) {
+        return cachedProduct;
+      }
+
+      // If not in cache, get from blockchain
+      const product = await fabricDAO.evaluateTransaction(role, 'ReadProduct', productId);
+
+      // Cache the result
+      await cacheService.setProductDetail(productId, product);
+
+      return product;
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Product ${productId} does not exist`);
+      }
+      throw new Error(`Failed to get product information: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get a product as committed on the ledger, bypassing the cache, e.g. right after a write
+   * The cache is refreshed with the committed state
+   * @param {string} role - Caller role
+   * @param {string} productId - Product ID
+   * @returns {Promise<Object>} Product and batch information
+   */
+  async getCommittedProduct(role, productId) {
+    try {
+      const product = await fabricDAO.evaluateTransaction(role, 'ReadProduct', productId);
+      await cacheService.setProductDetail(productId, product);
+      return product;
     } catch (error) {
       if (error.message.includes('does not exist')) {
         throw new Error(`${errorCodes.NOT_FOUND}: Product ${productId} does not exist`);
@@ -61,6 +99,319 @@ class ProductService {
     }
   }
 
+  /**
+   * Get products held by an owner, one page at a time
+   * @param {string} role - Caller role
+   * @param {string} owner - Owner name
+   * @param {number} pageSize - Page size
+   * @param {string} bookmark - Bookmark returned by the previous page (empty for the first page)
+   * @returns {Promise<Object>} { products, fetchedRecordsCount, bookmark }
+   */
+  async getProductsByOwner(role, owner, pageSize = 20, bookmark = '') {
+    if (!owner) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Owner cannot be empty`);
+    }
+
+    const size = parseInt(pageSize, 10);
+    if (!Number.isInteger(size) || size <= 0 || size > 1000) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Page size must be an integer between 1 and 1000`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(
+        role,
+        'ProductManagementContract:GetProductsByOwner',
+        owner,
+        size.toString(),
+        bookmark || ''
+      );
+    } catch (error) {
+      throw new Error(`Failed to get products by owner: ${error.message}`);
+    }
+  }
+
+  /**
+   * Query products by owner, batch, status and package date range, one page at a time
+   * @param {string} role - Caller role
+   * @param {Object} selector - { owner, batchId, status, packageDateFrom, packageDateTo }, all optional;
+   *   status is Active, Sold, Returned, Disposed or Expired
+   * @param {number} pageSize - Page size
+   * @param {string} bookmark - Bookmark returned by the previous page (empty for the first page)
+   * @returns {Promise<Object>} { products, fetchedRecordsCount, bookmark }
+   */
+  async queryProducts(role, selector = {}, pageSize = 20, bookmark = '') {
+    const size = parseInt(pageSize, 10);
+    if (!Number.isInteger(size) || size <= 0 || size > 1000) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Page size must be an integer between 1 and 1000`);
+    }
+
+    // Leave out empty selectors so the chaincode only applies the ones given
+    const selectors = Object.fromEntries(Object.entries(selector).filter(([, value]) => value !== undefined && value !== ''));
+
+    try {
+      return await fabricDAO.evaluateTransaction(
+        role,
+        'ProductManagementContract:QueryProducts',
+        JSON.stringify(selectors),
+        size.toString(),
+        bookmark || ''
+      );
+    } catch (error) {
+      if (/Invalid product status|packageDate|must not be after/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to query products: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get products packaged from a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Array>} Product list
+   */
+  async getProductsByBatch(role, batchId) {
+    if (!batchId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch ID cannot be empty`);
+    }
+
+    try {
+      const products = await fabricDAO.evaluateTransaction(role, 'ProductManagementContract:GetAllProducts');
+      return products.filter(product => product.batchId === batchId);
+    } catch (error) {
+      throw new Error(`Failed to get products by batch: ${error.message}`);
+    }
+  }
+
+  /**
+   * Return a sold product to the distributor that sold it
+   * @param {string} role - Caller role
+   * @param {string} productId - Product ID
+   * @param {string} reason - Documented return reason
+   * @param {boolean} requireReinspection - Block resale until the product is re-inspected
+   * @param {string} [clientRequestId] - Idempotency key; retries with the same key are applied once
+   * @returns {Promise<Object>} Return result
+   */
+  async returnProduct(role, productId, reason, requireReinspection = false, clientRequestId = '') {
+    if (!productId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Product ID cannot be empty`);
+    }
+    if (!reason) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Return reason cannot be empty`);
+    }
+
+    const reinspect = requireReinspection === true || requireReinspection === 'true';
+
+    try {
+      await fabricDAO.submitTransaction(
+        role,
+        'ProductManagementContract:ReturnProduct',
+        productId,
+        reason,
+        reinspect.toString(),
+        clientRequestId
+      );
+      await cacheService.invalidateProductCache(productId);
+
+      return {
+        message: 'Product returned successfully',
+        productId,
+        requiresReinspection: reinspect,
+        timestamp: new Date().toISOString()
+      };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Product ${productId} does not exist`);
+      }
+      throw new Error(`Failed to return product: ${error.message}`);
+    }
+  }
+
+  /**
+   * Register the verification code printed on a product package (only a keyed hash is stored on chain)
+   * @param {string} role - Caller role
+   * @param {string} productId - Product ID
+   * @param {string} code - Verification code, at least 6 characters
+   * @returns {Promise<Object>} { productId }
+   */
+  async registerVerificationCode(role, productId, code) {
+    if (!code || String(code).trim().length < 6) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: code must have at least 6 characters`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'ProductVerificationContract:RegisterVerificationCode', productId, String(code));
+      await cacheService.invalidateTrace(productId);
+      return { productId };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Product ${productId} does not exist`);
+      }
+      throw new Error(`Failed to register verification code: ${error.message}`);
+    }
+  }
+
+  /**
+   * Check the code on a product package
+   * Always submitted, never simulated: every attempt counts towards the lockout. The chaincode only returns the
+   * attempt ID, so the outcome is read from the ledger after the attempt has been committed
+   * @param {string} role - Caller role
+   * @param {string} productId - Product ID
+   * @param {string} code - Code printed on the package
+   * @returns {Promise<Object>} { attemptId, productId, attemptedAt, verified, locked, remainingAttempts, lockedUntil }
+   */
+  async verifyProduct(role, productId, code) {
+    if (!code) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: code is required`);
+    }
+
+    try {
+      const attemptId = new TextDecoder().decode(
+        await fabricDAO.submitTransaction(role, 'ProductVerificationContract:VerifyProduct', productId, String(code))
+      );
+      return await fabricDAO.evaluateTransaction(role, 'ProductVerificationContract:GetVerificationResult', productId, attemptId);
+    } catch (error) {
+      if (error.message.includes('has no verification code')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Product ${productId} has no verification code`);
+      }
+      throw new Error(`Failed to verify product: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the verification attempt counters and lockout of a product
+   * @param {string} role - Caller role
+   * @param {string} productId - Product ID
+   * @returns {Promise<Object>} Verification status
+   */
+  async getVerificationStatus(role, productId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ProductVerificationContract:GetVerificationStatus', productId);
+    } catch (error) {
+      if (error.message.includes('has no verification code')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Product ${productId} has no verification code`);
+      }
+      throw new Error(`Failed to get verification status: ${error.message}`);
+    }
+  }
+
+  /**
+   * Set label nutrition facts (per 100 g) and/or composition of a product
+   * @param {string} role - Caller role
+   * @param {string} productId - Product ID
+   * @param {Object} [nutrition] - { energyKj, proteinG, carbohydrateG, fatG?, fiberG?, sodiumMg? }
+   * @param {Object} [composition] - { ingredients, allergens?, netWeightG?, grade?, bestBefore? }
+   * @returns {Promise<Object>} Operation result
+   */
+  async setProductNutrition(role, productId, nutrition, composition) {
+    if (!productId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Product ID cannot be empty`);
+    }
+    if (!nutrition && !composition) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Nutrition facts or composition is required`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(
+        role,
+        'ProductManagementContract:SetProductNutrition',
+        productId,
+        nutrition ? JSON.stringify(nutrition) : '',
+        composition ? JSON.stringify(composition) : ''
+      );
+      await cacheService.invalidateProductCache(productId);
+
+      return {
+        message: 'Product label information updated successfully',
+        productId,
+        timestamp: new Date().toISOString()
+      };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Product ${productId} does not exist`);
+      }
+      throw new Error(`Failed to set product nutrition: ${error.message}`);
+    }
+  }
+
+  /**
+   * Replace the labels of a product
+   * @param {string} role - Caller role
+   * @param {string} productId - Product ID
+   * @param {Object} labels - { key: value } string labels, e.g. { 'retail-sku': 'DHX-5KG' }; {} removes all labels
+   * @returns {Promise<Object>} { productId, labels }
+   */
+  async setProductLabels(role, productId, labels) {
+    if (!labels || typeof labels !== 'object' || Array.isArray(labels)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: labels must be an object of key-value pairs`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'ProductManagementContract:SetProductLabels', productId, JSON.stringify(labels));
+      await cacheService.invalidateProductCache(productId);
+      return { productId, labels };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Product ${productId} does not exist`);
+      }
+      throw new Error(`Failed to set product labels: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the products carrying a label
+   * @param {string} role - Caller role
+   * @param {string} key - Label key
+   * @param {string} [value] - Label value; any value when empty
+   * @returns {Promise<Array>} Product list
+   */
+  async getProductsByLabel(role, key, value = '') {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ProductManagementContract:GetProductsByLabel', key, value);
+    } catch (error) {
+      throw new Error(`Failed to get products by label: ${error.message}`);
+    }
+  }
+
+  /**
+   * Check the trace chain of a product for gaps: custody links, step order, tests at mandatory checkpoints and
+   * unregistered operators
+   * @param {string} role - Caller role
+   * @param {string} productId - Product ID
+   * @returns {Promise<Object>} { productId, batchId, valid, deficiencies: [{ rule, entityId, index?, step?, message }], criteriaVersion }
+   */
+  async validateTraceChain(role, productId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'TraceabilityScoreContract:ValidateTraceChain', productId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Product ${productId} or its batch does not exist`);
+      }
+      throw new Error(`Failed to validate trace chain: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the origins of the rice in a product with each origin's share, for origin labeling
+   * @param {string} role - Caller role
+   * @param {string} productId - Product ID
+   * @returns {Promise<Object>} { productId, batchId, contributions, linkedSources }
+   */
+  async getProductComposition(role, productId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ProductManagementContract:GetProductComposition', productId);
+    } catch (error) {
+      const missingBatch = /The rice batch (\S+) of product \S+ does not exist/.exec(error.message);
+      if (missingBatch) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${missingBatch[1]} of product ${productId} does not exist`);
+      }
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Product ${productId} does not exist`);
+      }
+      throw new Error(`Failed to get product composition: ${error.message}`);
+    }
+  }
+
   /**
    * Check if product exists
    * @param {string} role - Caller role
@@ -100,7 +451,10 @@ class ProductService {
         traceabilityInfo: {
           totalSteps: this._calculateTotalSteps(productInfo),
           lastUpdated: this._getLastUpdateTime(productInfo),
-          verificationStatus: this._getVerificationStatus(productInfo)
+          verificationStatus: this._getVerificationStatus(productInfo),
+          foreignProvenance: this._getForeignProvenance(productInfo),
+          geographicIndication: this._getGeographicIndication(productInfo),
+          exports: await this._getExports(role, productInfo)
         }
       };
 
@@ -133,6 +487,63 @@ class ProductService {
     }
   }
 
+  /**
+   * Get the export consignments the product or its source batch travelled in, with their customs documentation
+   * @private
+   */
+  async _getExports(role, productInfo) {
+    const entityIds = [productInfo.productId, productInfo.batchId].filter(Boolean);
+    const consignments = new Map();
+    for (const entityId of entityIds) {
+      for (const consignment of await consignmentService.getConsignmentsByEntity(role, entityId)) {
+        consignments.set(consignment.consignmentId, consignment);
+      }
+    }
+
+    return [...consignments.values()].map(consignment => ({
+      consignmentId: consignment.consignmentId,
+      destinationCountry: consignment.destinationCountry,
+      status: consignment.status,
+      phytosanitaryCertificateHash: consignment.phytosanitaryCertificateHash || null,
+      customsDeclarationRef: consignment.customsDeclarationRef || null,
+      statusHistory: consignment.statusHistory
+    }));
+  }
+
+  /**
+   * Get the geographic indication claim of the source batch; null unless its latest GI check passed
+   * @private
+   */
+  _getGeographicIndication(productInfo) {
+    const check = productInfo.batch && productInfo.batch.giCompliance;
+    if (!check || !check.passed) return null;
+
+    return {
+      giId: check.giId,
+      name: check.giName,
+      ruleVersion: check.ruleVersion,
+      plotId: check.plotId,
+      checkedAt: check.checkedAt,
+      checkedBy: check.checkedBy
+    };
+  }
+
+  /**
+   * Get the provenance of source batches on other channels (regional networks)
+   * @private
+   */
+  _getForeignProvenance(productInfo) {
+    if (!productInfo.batch) return [];
+
+    return (productInfo.batch.foreignReferences || []).map(reference => ({
+      channel: reference.channel,
+      batchId: reference.batchId,
+      stateHash: reference.stateHash,
+      linkedAt: reference.linkedAt,
+      ...reference.summary
+    }));
+  }
+
   /**
    * Calculate total steps
    * @private
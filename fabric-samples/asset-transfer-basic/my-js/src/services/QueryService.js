@@ -0,0 +1,42 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Named query service layer
+ * Runs the chaincode's catalog of index-backed queries; clients pick a query by name instead of sending selectors
+ */
+class QueryService {
+
+  /**
+   * List the queries in the catalog and their parameters
+   * @param {string} role - Caller role
+   * @returns {Promise<Array>} { name, description, parameters }
+   */
+  async listQueries(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'QueryCatalogContract:ListNamedQueries');
+    } catch (error) {
+      throw new Error(`Failed to list queries: ${error.message}`);
+    }
+  }
+
+  /**
+   * Run a named query, one page at a time
+   * @param {string} role - Caller role
+   * @param {string} name - Query name, e.g. batchesByOwner, failedTestsSince, productsExpiringBefore
+   * @param {Object} params - Query parameters, plus optional pageSize and bookmark
+   * @returns {Promise<Object>} { name, batches|testResults|products, fetchedRecordsCount, bookmark }
+   */
+  async runQuery(role, name, params = {}) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'QueryCatalogContract:RunNamedQuery', name, JSON.stringify(params));
+    } catch (error) {
+      if (error.message.includes('Unknown query')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Query ${name} is not in the catalog`);
+      }
+      throw new Error(`Failed to run query ${name}: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new QueryService();
@@ -0,0 +1,158 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Recall service layer
+ * Issues recalls of batches and products. The chaincode works out the current owners of the recalled items and
+ * the event bridge sends each of them a RecallNotice
+ */
+class RecallService {
+
+  /**
+   * Issue a recall
+   * @param {string} role - Caller role
+   * @param {Object} recall - { recallId, reason, batchIds?, productIds? }
+   * @returns {Promise<Object>} Recall with its notices
+   */
+  async issueRecall(role, recall) {
+    const { recallId, reason, batchIds = [], productIds = [] } = recall;
+    if (!recallId || !reason) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: recallId and reason are required`);
+    }
+    if (!Array.isArray(batchIds) || !Array.isArray(productIds) || batchIds.length + productIds.length === 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: batchIds and productIds must be lists with at least one item between them`);
+    }
+
+    try {
+      return await fabricDAO.submitTransaction(role, 'RecallContract:IssueRecall',
+        recallId, reason, JSON.stringify({ batchIds, productIds }));
+    } catch (error) {
+      if (error.message.includes(`recall ${recallId} already exists`)) {
+        throw new Error(`${errorCodes.ALREADY_EXISTS}: Recall ${recallId} already exists`);
+      }
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message}`);
+      }
+      throw new Error(`Failed to issue recall: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get a recall with its notices
+   * @param {string} role - Caller role
+   * @param {string} recallId - Recall ID
+   * @returns {Promise<Object>} Recall
+   */
+  async getRecall(role, recallId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'RecallContract:ReadRecall', recallId);
+    } catch (error) {
+      if (error.message.includes(`recall ${recallId} does not exist`)) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Recall ${recallId} does not exist`);
+      }
+      throw new Error(`Failed to get recall: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the recalls covering a batch or product
+   * @param {string} role - Caller role
+   * @param {string} entityId - Batch or product ID
+   * @returns {Promise<Array>} Recalls
+   */
+  async getRecallsByEntity(role, entityId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'RecallContract:GetRecallsByEntity', entityId);
+    } catch (error) {
+      throw new Error(`Failed to get recalls: ${error.message}`);
+    }
+  }
+
+  /**
+   * Confirm that a notified owner has pulled recalled stock
+   * @param {string} role - Caller role
+   * @param {string} recallId - Recall ID
+   * @param {Object} acknowledgment - { ownerId, productIds?, disposition }; productIds defaults to every item of the owner's notice
+   * @returns {Promise<Object>} Recorded acknowledgment
+   */
+  async acknowledgeRecall(role, recallId, acknowledgment) {
+    const { ownerId, productIds = [], disposition } = acknowledgment;
+    if (!ownerId || !disposition) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: ownerId and disposition are required`);
+    }
+    if (!Array.isArray(productIds)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: productIds must be a list of product or batch IDs`);
+    }
+
+    try {
+      return await fabricDAO.submitTransaction(role, 'RecallContract:AcknowledgeRecall',
+        recallId, ownerId, JSON.stringify(productIds), disposition);
+    } catch (error) {
+      if (error.message.includes(`recall ${recallId} does not exist`)) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Recall ${recallId} does not exist`);
+      }
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (error.message.includes('has no notice for') || error.message.includes('Invalid disposition') ||
+          error.message.includes('not part of the recall notice') || error.message.includes('format error')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to acknowledge recall: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the completion dashboard of a recall: which notified owners have responded and which have not
+   * @param {string} role - Caller role
+   * @param {string} recallId - Recall ID
+   * @returns {Promise<Object>} Completion with one status per owner, silent owners first
+   */
+  async getRecallCompletion(role, recallId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'RecallContract:GetRecallCompletion', recallId);
+    } catch (error) {
+      if (error.message.includes(`recall ${recallId} does not exist`)) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Recall ${recallId} does not exist`);
+      }
+      throw new Error(`Failed to get recall completion: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the acknowledgments of a recall, oldest first
+   * @param {string} role - Caller role
+   * @param {string} recallId - Recall ID
+   * @returns {Promise<Array>} Acknowledgments
+   */
+  async getRecallAcknowledgments(role, recallId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'RecallContract:GetRecallAcknowledgments', recallId);
+    } catch (error) {
+      if (error.message.includes(`recall ${recallId} does not exist`)) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Recall ${recallId} does not exist`);
+      }
+      throw new Error(`Failed to get recall acknowledgments: ${error.message}`);
+    }
+  }
+
+  /**
+   * Find the batches that received an agro-chemical and the products packaged from them, to scope a recall
+   * @param {string} role - Caller role
+   * @param {string} input - Chemical name (case-insensitive) or input lot ID
+   * @returns {Promise<Object>} { query, batches, products }
+   */
+  async findInputExposure(role, input) {
+    if (!input || !String(input).trim()) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: input (chemical name or input lot ID) is required`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'AgroInputContract:FindBatchesByInputProduct', String(input).trim());
+    } catch (error) {
+      throw new Error(`Failed to find batches by input product: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new RecallService();
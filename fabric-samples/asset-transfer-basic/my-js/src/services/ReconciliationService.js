@@ -0,0 +1,192 @@
+const fs = require('node:fs/promises');
+const path = require('node:path');
+const riceService = require('./RiceService');
+const cacheService = require('./CacheService');
+const { runInChannel } = require('../dao/channelContext');
+const { reconciliation } = require('../../config');
+
+/**
+ * Reconciliation service
+ * Re-reads every batch from the ledger, page by page, and compares it with the gateway's Redis copy: cached batch
+ * details, existence checks and batch lists. Differences (drift) come from writes that bypassed the gateway, such as
+ * other gateways, seeding scripts and chaincode upgrades, or from lost invalidations. Repairing evicts the drifted
+ * entries rather than overwriting them, so a write committed during the run can never be replaced by older state;
+ * the next read refills them from the ledger
+ */
+class ReconciliationService {
+  constructor() {
+    this.isRunning = false;
+    this.timer = null;
+    this.wake = null;
+    this.lastReport = null;
+  }
+
+  /**
+   * Reconcile every intervalMinutes until stopped
+   * A failed run is logged and retried at the next interval
+   */
+  async start() {
+    if (this.isRunning) {
+      return;
+    }
+    this.isRunning = true;
+    console.log(`Reconciliation of ${reconciliation.channel} every ${reconciliation.intervalMinutes} minute(s)${reconciliation.repair ? ', repairing drift' : ''}`);
+
+    while (this.isRunning) {
+      try {
+        await this.reconcile({ repair: reconciliation.repair });
+      } catch (error) {
+        console.error('Reconciliation failed:', error.message);
+      }
+      await new Promise(resolve => {
+        this.wake = resolve;
+        this.timer = setTimeout(resolve, reconciliation.intervalMinutes * 60 * 1000);
+      });
+    }
+  }
+
+  /**
+   * Stop after the current run
+   */
+  stop() {
+    this.isRunning = false;
+    clearTimeout(this.timer);
+    if (this.wake) {
+      this.wake();
+    }
+  }
+
+  /**
+   * Get reconciliation status
+   */
+  getStatus() {
+    return {
+      isRunning: this.isRunning,
+      channel: reconciliation.channel,
+      lastRun: this.lastReport && {
+        finishedAt: this.lastReport.finishedAt,
+        batches: this.lastReport.batches,
+        drift: this.lastReport.drift.length,
+        repaired: this.lastReport.repaired
+      }
+    };
+  }
+
+  /**
+   * Compare the ledger with the cache once and write the report
+   * @param {Object} [options] - { repair: evict drifted entries, channel }
+   * @returns {Promise<Object>} Report: { channel, startedAt, finishedAt, batches, pages, drift, repaired }
+   */
+  async reconcile({ repair = false, channel = reconciliation.channel } = {}) {
+    const report = await runInChannel(channel, () => this._reconcileChannel(channel, repair));
+
+    await fs.mkdir(path.dirname(reconciliation.reportPath), { recursive: true });
+    await fs.writeFile(reconciliation.reportPath, `${JSON.stringify(report, null, 2)}\n`);
+    this.lastReport = report;
+
+    const summary = `${report.batches} batch(es) on ${channel}, ${report.drift.length} drifted cache entr${report.drift.length === 1 ? 'y' : 'ies'}`;
+    if (report.drift.length > 0) {
+      console.warn(`Reconciliation found drift: ${summary}${repair ? ', evicted' : ''} (report: ${reconciliation.reportPath})`);
+    } else {
+      console.log(`Reconciliation complete: ${summary}`);
+    }
+    return report;
+  }
+
+  /**
+   * @private
+   */
+  async _reconcileChannel(channel, repair) {
+    const startedAt = new Date().toISOString();
+    // Snapshot first: a write committed during the run can make an entry look stale (evicting it is harmless), but
+    // cannot hide drift
+    const cache = await cacheService.getBatchCacheSnapshot();
+    const ledger = new Map();
+    const drift = [];
+
+    let pages = 0;
+    let bookmark = '';
+    do {
+      const page = await riceService.getBatchesPage(reconciliation.role, reconciliation.pageSize, bookmark);
+      pages++;
+      for (const batch of page.batches) {
+        ledger.set(batch.batchId, JSON.stringify(batch));
+
+        const cached = cache.details.get(batch.batchId);
+        if (cached !== undefined && JSON.stringify(cached) !== ledger.get(batch.batchId)) {
+          drift.push({ batchId: batch.batchId, entry: 'detail', kind: 'stale', fields: this._changedFields(cached, batch) });
+        }
+        if (cache.exists.get(batch.batchId) === false) {
+          drift.push({ batchId: batch.batchId, entry: 'exists', kind: 'stale' });
+        }
+      }
+      bookmark = page.bookmark;
+    } while (bookmark);
+
+    // Cached batches the ledger does not have
+    for (const batchId of cache.details.keys()) {
+      if (!ledger.has(batchId)) {
+        drift.push({ batchId, entry: 'detail', kind: 'orphaned' });
+      }
+    }
+    for (const [batchId, exists] of cache.exists) {
+      if (exists && !ledger.has(batchId)) {
+        drift.push({ batchId, entry: 'exists', kind: 'orphaned' });
+      }
+    }
+
+    for (const [role, batches] of cache.lists) {
+      const missing = [...ledger.keys()].filter(batchId => !batches.some(batch => batch.batchId === batchId));
+      const extra = batches.filter(batch => !ledger.has(batch.batchId)).map(batch => batch.batchId);
+      const stale = batches.filter(batch => ledger.has(batch.batchId) && ledger.get(batch.batchId) !== JSON.stringify(batch)).map(batch => batch.batchId);
+      if (missing.length > 0 || extra.length > 0 || stale.length > 0) {
+        drift.push({ role, entry: 'list', kind: 'stale', missing, extra, stale });
+      }
+    }
+
+    if (repair) {
+      await this._repair(drift);
+    }
+
+    return {
+      channel,
+      startedAt,
+      finishedAt: new Date().toISOString(),
+      batches: ledger.size,
+      pages,
+      drift,
+      repaired: repair && drift.length > 0
+    };
+  }
+
+  /**
+   * Evict the drifted entries
+   * @private
+   */
+  async _repair(drift) {
+    for (const item of drift) {
+      if (item.entry === 'detail') {
+        await cacheService.invalidateBatchDetail(item.batchId);
+      } else if (item.entry === 'exists') {
+        await cacheService.invalidateBatchExists(item.batchId);
+      }
+    }
+    if (drift.some(item => item.entry === 'list')) {
+      await cacheService.invalidateBatchList();
+    }
+  }
+
+  /**
+   * Top-level fields whose cached value differs from the ledger
+   * @private
+   */
+  _changedFields(cached, batch) {
+    const fields = new Set([...Object.keys(cached || {}), ...Object.keys(batch)]);
+    return [...fields].filter(field => JSON.stringify(cached[field]) !== JSON.stringify(batch[field])).sort();
+  }
+}
+
+// Create singleton instance
+const reconciliationService = new ReconciliationService();
+
+module.exports = reconciliationService;
@@ -0,0 +1,53 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Resource usage service layer
+ * Reads the energy, labor and machine time the caller's organization recorded with its processing steps. The figures
+ * are kept in the organization's implicit collection, so only its own peers (the gateway's peer for its role) hold them
+ */
+class ResourceUsageService {
+
+  /**
+   * Get the resource usage the caller's organization recorded for the processing steps of a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Object[]>} [{ batchId, eventIndex, step, timestamp, energyKwh?, laborHours?, machineHours? }] in history order
+   */
+  async getBatchUsage(role, batchId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ResourceUsageContract:GetResourceUsage', batchId);
+    } catch (error) {
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to get resource usage: ${error.message}`);
+    }
+  }
+
+  /**
+   * Sum the caller organization's resource usage over a period, in total and per processing step
+   * @param {string} role - Caller role
+   * @param {string} period - Interval "<start>/<end>" of dates or RFC3339 times
+   * @returns {Promise<Object>} { mspId, from, to, batches, totals, byStep }
+   */
+  async getSummary(role, period) {
+    if (!period) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: period is required, e.g. 2024-07-01/2024-09-30`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ResourceUsageContract:GetResourceUsageSummary', period);
+    } catch (error) {
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (/must be an interval|cannot be earlier|period (start|end)/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to get resource usage summary: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new ResourceUsageService();
@@ -0,0 +1,92 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Retention service layer
+ * Sets how long an organization keeps private test report details and commercial terms, and purges them once
+ * due; the hashes on the public ledger remain
+ */
+class RetentionService {
+
+  /**
+   * Set the retention period of a kind of private data for the caller's organization
+   * @param {string} role - Caller role; its identity must be an organization administrator
+   * @param {string} dataType - testReportDetails | commercialTerms
+   * @param {number} retentionDays - Days to keep the data
+   * @returns {Promise<Object>} { dataType, retentionDays }
+   */
+  async defineRetentionPolicy(role, dataType, retentionDays) {
+    if (!Number.isInteger(Number(retentionDays))) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: retentionDays must be a whole number of days`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'PrivateDataRetentionContract:DefineRetentionPolicy', dataType, String(retentionDays));
+      return { dataType, retentionDays: Number(retentionDays) };
+    } catch (error) {
+      throw this._wrap(error, 'define retention policy');
+    }
+  }
+
+  /**
+   * Get the retention policies of all organizations
+   * @param {string} role - Caller role
+   * @returns {Promise<Array>} Retention policies
+   */
+  async getRetentionPolicies(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'PrivateDataRetentionContract:GetRetentionPolicies');
+    } catch (error) {
+      throw new Error(`Failed to get retention policies: ${error.message}`);
+    }
+  }
+
+  /**
+   * Purge the caller's organization's private data of a kind that is past its retention period or older than a cutoff
+   * Submits consecutive transactions until nothing due remains
+   * @param {string} role - Caller role; its identity must be an organization administrator
+   * @param {string} dataType - testReportDetails | commercialTerms
+   * @param {string} [olderThan] - Cutoff date or time; defaults to the start of the retention period
+   * @returns {Promise<Object>} { dataType, cutoff, purged, transactions }
+   */
+  async purgePrivateData(role, dataType, olderThan = '') {
+    const purged = [];
+    let cutoff = '';
+    let transactions = 0;
+    let remaining = Infinity;
+    try {
+      for (;;) {
+        const result = await fabricDAO.submitTransaction(role, 'PrivateDataRetentionContract:PurgePrivateData', dataType, olderThan);
+        const purge = JSON.parse(new TextDecoder().decode(result));
+        transactions++;
+        cutoff = purge.cutoff;
+        purged.push(...purge.purged);
+        // A simulated (dry-run) purge commits nothing, so the same records would be due again
+        if (purge.remaining === 0 || purge.remaining >= remaining) {
+          break;
+        }
+        remaining = purge.remaining;
+      }
+      return { dataType, cutoff, purged, transactions };
+    } catch (error) {
+      throw this._wrap(error, 'purge private data');
+    }
+  }
+
+  /**
+   * Map chaincode errors to API error codes
+   * @private
+   */
+  _wrap(error, action) {
+    const message = error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '');
+    if (error.message.includes('Permission denied')) {
+      return new Error(`${errorCodes.PERMISSION_DENIED}: ${message}`);
+    }
+    if (/Invalid data type|Invalid retention period|no retention policy|in the future|olderThan/.test(error.message)) {
+      return new Error(`${errorCodes.VALIDATION_ERROR}: ${message}`);
+    }
+    return new Error(`Failed to ${action}: ${error.message}`);
+  }
+}
+
+module.exports = new RetentionService();
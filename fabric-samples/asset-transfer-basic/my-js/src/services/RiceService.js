@@ -1,7 +1,8 @@
+const crypto = require('node:crypto');
 const fabricDAO = require('../dao/FabricDAO');
 const oracleClient = require('../clients/OracleClient');
 const cacheService = require('./CacheService');
-const { errorCodes } = require('../../config');
+const { fabric, errorCodes } = require('../../config');
 
 /**
  * Rice batch service layer
@@ -34,6 +35,285 @@ class RiceService {
     }
   }
 
+  /**
+   * Get rice batches from the ledger one page at a time, bypassing the cache
+   * @param {string} role - Caller role
+   * @param {number} pageSize - Page size
+   * @param {string} bookmark - Bookmark returned by the previous page (empty for the first page)
+   * @returns {Promise<Object>} { batches, fetchedRecordsCount, bookmark }
+   */
+  async getBatchesPage(role, pageSize = 100, bookmark = '') {
+    const size = parseInt(pageSize, 10);
+    if (!Number.isInteger(size) || size <= 0 || size > 1000) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Page size must be an integer between 1 and 1000`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GetRiceBatchesWithPagination', size.toString(), bookmark || '');
+    } catch (error) {
+      throw new Error(`Failed to get batch page: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get rice batches currently at a processing step
+   * @param {string} role - Caller role
+   * @param {string} step - Processing step
+   * @returns {Promise<Array>} Batch list
+   */
+  async getBatchesByStep(role, step) {
+    if (!step) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Processing step cannot be empty`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GetRiceBatchesByProcessingStep', step);
+    } catch (error) {
+      throw new Error(`Failed to get batches by processing step: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get rice batches with a derived status
+   * @param {string} role - Caller role
+   * @param {string} status - Active, Quarantined, Recalled, Finalized, Archived or Disposed
+   * @returns {Promise<Array>} Batch list
+   */
+  async getBatchesByStatus(role, status) {
+    if (!status) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch status cannot be empty`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'BatchStatusContract:GetBatchesByStatus', status);
+    } catch (error) {
+      if (error.message.includes('Invalid batch status')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to get batches by status: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the recorded daily activity statistics for a date range
+   * @param {string} role - Caller role
+   * @param {string} from - First day (YYYY-MM-DD)
+   * @param {string} to - Last day (YYYY-MM-DD), inclusive
+   * @returns {Promise<Array>} One summary per recorded day
+   */
+  async getDailyStats(role, from, to) {
+    const datePattern = /^\d{4}-\d{2}-\d{2}$/;
+    if (!datePattern.test(from || '') || !datePattern.test(to || '')) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: from and to must be dates in YYYY-MM-DD format`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GetDailyStats', from, to);
+    } catch (error) {
+      throw new Error(`Failed to get daily statistics: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the network-level KPIs for operator reporting
+   * @param {string} role - Caller role
+   * @param {string} [activeDays] - Window in which parties count as active, in days (90 when omitted)
+   * @returns {Promise<Object>} Participants, batches per stage, harvest-to-packaging lead time, open quarantines and disputes
+   */
+  async getNetworkKpis(role, activeDays) {
+    if (activeDays !== undefined && activeDays !== '' && !/^[1-9]\d*$/.test(String(activeDays))) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: activeDays must be a positive whole number of days`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GetNetworkKpis', activeDays ? String(activeDays) : '');
+    } catch (error) {
+      throw new Error(`Failed to get network KPIs: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the aggregates of a crop year, or of one of its seasons
+   * @param {string} role - Caller role
+   * @param {string} cropYear - Crop year (YYYY)
+   * @param {string} [season] - Early, Middle or Late (empty for the whole crop year)
+   * @returns {Promise<Object>} { cropYear, season, batches, disposedBatches, products, testsRecorded, failedTests, failureRate, varieties }
+   */
+  async getSeasonStats(role, cropYear, season = '') {
+    if (!/^\d{4}$/.test(cropYear || '')) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: cropYear must be a four-digit year`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'CropSeasonContract:GetSeasonStats', cropYear, season || '');
+    } catch (error) {
+      if (error.message.includes('Invalid season')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: season must be Early, Middle or Late`);
+      }
+      throw new Error(`Failed to get season statistics: ${error.message}`);
+    }
+  }
+
+  /**
+   * Compare a season, or whole crop years, year over year
+   * @param {string} role - Caller role
+   * @param {string} [season] - Early, Middle or Late (empty for whole crop years)
+   * @param {string} from - First crop year (YYYY)
+   * @param {string} to - Last crop year (YYYY), at most 20 years after from
+   * @returns {Promise<Array>} One season statistics object per crop year
+   */
+  async compareSeasons(role, season, from, to) {
+    if (!/^\d{4}$/.test(from || '') || !/^\d{4}$/.test(to || '') || Number(from) > Number(to)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: from and to must be four-digit crop years in order`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'CropSeasonContract:CompareSeasons', season || '', from, to);
+    } catch (error) {
+      if (/Invalid season|At most 20 crop years/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to compare seasons: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the quality trends of a variety and region month by month: failure rate, average moisture and grades
+   * Computed on chain from the test outcome index; there is no off-chain mirror to query instead
+   * @param {string} role - Caller role
+   * @param {string} [variety] - Variety (exact, any case; empty for every variety)
+   * @param {string} [region] - Part of the batch origin (any case; empty for every origin)
+   * @param {string} period - Interval of dates <start>/<end>, at most 36 months
+   * @returns {Promise<Object>} { variety, region, from, to, batches, testsRecorded, failedTests, failureRate, moistureReadings, averageMoisturePercent, grades, months }
+   */
+  async getQualityTrends(role, variety, region, period) {
+    if (!period) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: period is required, e.g. 2024-01-01/2024-12-31`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'QualityTrendsContract:GetQualityTrends', variety || '', region || '', period);
+    } catch (error) {
+      if (/must be an interval|cannot be earlier|At most 36 months|Invalid/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to get quality trends: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get test results recorded for a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Array>} Test result list
+   */
+  async getTestResultsByBatch(role, batchId) {
+    if (!batchId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch ID cannot be empty`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'QualityCertificationContract:GetTestResultsByBatch', batchId);
+    } catch (error) {
+      throw new Error(`Failed to get test results: ${error.message}`);
+    }
+  }
+
+  /**
+   * Check a report file hash against the one registered with a test result
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} testId - Test result ID
+   * @param {string} reportHash - SHA-256 (hex) of the report file
+   * @returns {Promise<boolean>} Whether the file is the registered report
+   */
+  async verifyTestReportHash(role, batchId, testId, reportHash) {
+    if (!batchId || !testId || !reportHash) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch ID, test ID and report hash are required`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'QualityCertificationContract:VerifyTestReportHash', batchId, testId, reportHash);
+    } catch (error) {
+      throw new Error(`Failed to verify test report hash: ${error.message}`);
+    }
+  }
+
+  /**
+   * Withdraw a test result (e.g. after an instrument error); it is flagged as revoked, not deleted
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} testId - Test ID
+   * @param {string} reason - Why the result is withdrawn
+   * @returns {Promise<Object>} { batchId, testId, reason }
+   */
+  async revokeTestResult(role, batchId, testId, reason) {
+    if (!reason) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: A reason is required to revoke a test result`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'QualityCertificationContract:RevokeTestResult', batchId, testId, reason);
+      await cacheService.invalidateBatchCache(batchId);
+      return { batchId, testId, reason };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Test result ${testId} does not exist`);
+      }
+      throw new Error(`Failed to revoke test result: ${error.message}`);
+    }
+  }
+
+  /**
+   * Record the moisture content a moisture test measured
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} testId - Test ID
+   * @param {number} moisturePercent - Moisture content in percent of the sample weight (0-100)
+   * @returns {Promise<Object>} { batchId, testId, moisturePercent }
+   */
+  async recordMoistureContent(role, batchId, testId, moisturePercent) {
+    const value = Number(moisturePercent);
+    if (moisturePercent === '' || moisturePercent === null || !Number.isFinite(value) || value < 0 || value > 100) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: moisturePercent must be a number between 0 and 100`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'QualityCertificationContract:RecordMoistureContent', batchId, testId, String(value));
+      await cacheService.invalidateBatchCache(batchId);
+      return { batchId, testId, moisturePercent: value };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Test result ${testId} does not exist`);
+      }
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (/not a moisture test|already recorded|revoked|belongs to batch/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to record moisture content: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get quality certificates issued for a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Array>} Certificate list
+   */
+  async getCertificatesByBatch(role, batchId) {
+    if (!batchId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch ID cannot be empty`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'QualityCertificationContract:GetCertificatesByBatch', batchId);
+    } catch (error) {
+      throw new Error(`Failed to get quality certificates: ${error.message}`);
+    }
+  }
+
   /**
    * Get rice batch by ID
    * @param {string} role - Caller role
@@ -67,6 +347,26 @@ class RiceService {
     }
   }
 
+  /**
+   * Get a batch as committed on the ledger, bypassing the cache, e.g. right after a write
+   * The cache is refreshed with the committed state
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Object>} Batch information
+   */
+  async getCommittedBatch(role, batchId) {
+    try {
+      const batch = await fabricDAO.evaluateTransaction(role, 'ReadRiceBatch', batchId);
+      await cacheService.setBatchDetail(batchId, batch);
+      return batch;
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to get batch details: ${error.message}`);
+    }
+  }
+
   /**
    * Check if batch exists
    * @param {string} role - Caller role
@@ -103,9 +403,10 @@ class RiceService {
    * @param {string} role - Caller role
    * @param {Object} batchData - Batch data
    * @param {string} reportId - Quality inspection report ID
+   * @param {string} [clientRequestId] - Idempotency key; retries with the same key create the batch once
    * @returns {Promise<Object>} Newly created batch information
    */
-  async createBatch(role, batchData, reportId) {
+  async createBatch(role, batchData, reportId, clientRequestId = '') {
     // Data validation
     this._validateBatchData(batchData);
 
@@ -113,7 +414,7 @@ class RiceService {
       throw new Error(`${errorCodes.VALIDATION_ERROR}: Creating batch requires quality inspection report ID`);
     }
     
-    const { location, variety, harvestDate, initialTestResult, owner, initialStep, operator } = batchData;
+    const { location, variety, harvestDate, initialTestResult, owner, initialStep, operator, workflowId, cropYear, season } = batchData;
     
     try {
       // Verify quality inspection report
@@ -127,8 +428,7 @@ class RiceService {
       const reportData = verificationResult.data;
       console.log(`Quality inspection report verification passed: ${reportId}`);
 
-      // Generate batch ID
-      const batchId = this._generateBatchId();
+      const batchId = batchData.batchId || await this._newBatchId(role, clientRequestId);
       
       // Call smart contract to create batch, pass in report hash
       await fabricDAO.submitTransaction(
@@ -154,7 +454,11 @@ class RiceService {
         }),
         owner,
         initialStep,
-        operator
+        operator,
+        workflowId || '',
+        cropYear ? String(cropYear) : '',
+        season || '',
+        clientRequestId
       );
 
       // Invalidate cache after creating new batch
@@ -167,6 +471,9 @@ class RiceService {
         message: 'Rice batch created successfully (associated with quality inspection report)'
       };
     } catch (error) {
+      if (/does not follow the ID policy|invalid check digit|assigns no batch ID prefix/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
       throw new Error(`Failed to create batch: ${error.message}`);
     }
   }
@@ -374,13 +681,34 @@ class RiceService {
     if (missing.length > 0) {
       throw new Error(`${errorCodes.VALIDATION_ERROR}: Missing required fields: ${missing.join(', ')}`);
     }
+    // The chaincode stores the SHA-256 of the lab report so buyers can verify the file they were sent
+    if (!/^[0-9a-f]{64}$/i.test(testData.reportHash || '')) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: reportHash must be the SHA-256 digest (64 hex characters) of the report file`);
+    }
+  }
+
+  /**
+   * ID of a batch created without one: drawn from the caller's organization's sequence when the ID policy has a
+   * batch scheme, otherwise derived from the idempotency key so a retry targets the same batch
+   * @private
+   */
+  async _newBatchId(role, clientRequestId) {
+    const identifierService = require('./IdentifierService');
+    const policy = await identifierService.getPolicy(role);
+    if (policy.batch) {
+      return identifierService.generateId(role, 'batch');
+    }
+    return this._generateBatchId(clientRequestId);
   }
 
   /**
    * Generate batch ID
    * @private
    */
-  _generateBatchId() {
+  _generateBatchId(clientRequestId) {
+    if (clientRequestId) {
+      return `batch_${crypto.createHash('sha256').update(clientRequestId).digest('hex').slice(0, 16)}`;
+    }
     return `batch_${Date.now()}_${Math.random().toString(36).substr(2, 9)}`;
   }
 
@@ -396,6 +724,22 @@ class RiceService {
     }
   }
 
+  /**
+   * Check chaincode connectivity and get the deployed contract version
+   * @param {string} role - Caller role
+   * @returns {Promise<Object>} Chaincode status and version information
+   */
+  async getChaincodeStatus(role) {
+    const start = Date.now();
+    try {
+      await fabricDAO.evaluateTransaction(role, 'Ping');
+      const version = await fabricDAO.evaluateTransaction(role, 'GetContractVersion');
+      return { reachable: true, latencyMs: Date.now() - start, ...version };
+    } catch (error) {
+      return { reachable: false, latencyMs: Date.now() - start, error: error.message };
+    }
+  }
+
   /**
    * Complete step and transfer batch - unified method
    * @param {string} role - Caller role
@@ -404,9 +748,17 @@ class RiceService {
    * @param {string} toOperator - Next operator
    * @param {string} step - Current step
    * @param {string} reportId - Report ID for verification
+   * @param {Object} [stepDetails] - Optional step evidence added to the report:
+   *   destinationCountry (Shipped step), equipmentId (registered equipment the step ran on),
+   *   geolocation ({ latitude, longitude } of the plot or site), temperatureLogHash (SHA-256 of cold-chain logger data),
+   *   inputs (agro-chemicals applied: [{ chemicalName, inputLotId?, appliedAt? }]),
+   *   facilityId, line, shift (registered facility, production line and shift the step took place at; the shift is
+   *   derived from the time when omitted), ambient ({ temperatureC?, humidityPercent? } at the site)
+   * @param {string} [clientRequestId] - Idempotency key; retries with the same key are applied once
    * @returns {Promise<Object>} Transaction result
    */
-  async completeStepAndTransfer(role, batchId, fromOperator, toOperator, step, reportId) {
+  async completeStepAndTransfer(role, batchId, fromOperator, toOperator, step, reportId, stepDetails = {}, clientRequestId = '') {
+    const { destinationCountry, equipmentId, geolocation, temperatureLogHash, inputs, facilityId, line, shift, ambient } = stepDetails;
     // Validate inputs
     if (!batchId || !fromOperator || !toOperator || !step || !reportId) {
       throw new Error(`${errorCodes.VALIDATION_ERROR}: All fields are required`);
@@ -416,6 +768,22 @@ class RiceService {
       // First, verify the report and get ReportDetail
       const reportService = require('./ReportService');
       const reportDetail = await reportService.verifyAndFetchReportDetail(reportId);
+      if (destinationCountry) {
+        reportDetail.destinationCountry = destinationCountry;
+      }
+      if (equipmentId) {
+        reportDetail.equipmentId = equipmentId;
+      }
+      if (geolocation) {
+        reportDetail.geolocation = { latitude: Number(geolocation.latitude), longitude: Number(geolocation.longitude) };
+      }
+      if (temperatureLogHash) {
+        reportDetail.temperatureLogHash = temperatureLogHash;
+      }
+      if (inputs) {
+        reportDetail.inputs = inputs;
+      }
+      Object.assign(reportDetail, this._facilityContext({ facilityId, line, shift, ambient }));
       
       console.log(`Processing step and transfer: ${step} from ${fromOperator} to ${toOperator}`);
       
@@ -427,7 +795,8 @@ class RiceService {
         fromOperator,
         toOperator,
         step,
-        JSON.stringify(reportDetail)
+        JSON.stringify(reportDetail),
+        clientRequestId
       );
 
       // Invalidate cache after completing step and transfer
@@ -443,6 +812,61 @@ class RiceService {
     }
   }
 
+  /**
+   * Check a step and transfer against every chaincode transfer rule without submitting it
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} toOperator - Next operator
+   * @param {string} [step] - Step to record; without it only the step-independent rules are checked
+   * @param {string} [reportId] - Report backing the step
+   * @param {Object} [stepDetails] - Optional step evidence, as for completeStepAndTransfer
+   * @returns {Promise<Object>} { batchId, newOwner, step, allowed, blockers: [{ rule, message }] }
+   */
+  async checkTransfer(role, batchId, toOperator, step = '', reportId = '', stepDetails = {}) {
+    const { destinationCountry, equipmentId, geolocation, temperatureLogHash, inputs, facilityId, line, shift, ambient } = stepDetails;
+    if (!batchId || !toOperator) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch ID and toOperator are required`);
+    }
+
+    try {
+      let reportDetail = { reportId: '', reportType: '', reportHash: '', summary: '', isVerified: false };
+      if (reportId) {
+        const reportService = require('./ReportService');
+        reportDetail = await reportService.verifyAndFetchReportDetail(reportId);
+      }
+      if (destinationCountry) {
+        reportDetail.destinationCountry = destinationCountry;
+      }
+      if (equipmentId) {
+        reportDetail.equipmentId = equipmentId;
+      }
+      if (geolocation) {
+        reportDetail.geolocation = { latitude: Number(geolocation.latitude), longitude: Number(geolocation.longitude) };
+      }
+      if (temperatureLogHash) {
+        reportDetail.temperatureLogHash = temperatureLogHash;
+      }
+      if (inputs) {
+        reportDetail.inputs = inputs;
+      }
+      Object.assign(reportDetail, this._facilityContext({ facilityId, line, shift, ambient }));
+
+      return await fabricDAO.evaluateTransaction(
+        role,
+        'CanTransferRiceBatch',
+        batchId,
+        toOperator,
+        step || '',
+        JSON.stringify(reportDetail)
+      );
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to check transfer: ${error.message}`);
+    }
+  }
+
   /**
    * Get current batch owner for auto-fill
    * @param {string} role - Caller role  
@@ -459,11 +883,788 @@ class RiceService {
   }
 
   /**
-   * Validate date format
-   * @private
+   * Privately attach commercial terms to a batch owned by the caller's organization
+   * The terms travel as transient data into the organization's implicit collection; only their hash is public
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string|Object} terms - Free text or an object (stored as JSON)
+   * @returns {Promise<Object>} Transaction result
    */
-  _isValidDate(dateString) {
-    return !isNaN(Date.parse(dateString));
+  async setCommercialTerms(role, batchId, terms) {
+    if (!batchId || terms === undefined || terms === null || terms === '') {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch ID and terms are required`);
+    }
+
+    const termsText = typeof terms === 'string' ? terms : JSON.stringify(terms);
+    try {
+      return await fabricDAO.submitAsyncTransaction(role, 'SetCommercialTerms', {
+        arguments: [batchId],
+        transientData: { terms: termsText }
+      });
+    } catch (error) {
+      throw new Error(`Failed to set commercial terms: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the commercial terms the caller's organization attached to a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Object>} Commercial terms record
+   */
+  async getCommercialTerms(role, batchId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ReadCommercialTerms', batchId);
+    } catch (error) {
+      throw new Error(`Failed to get commercial terms: ${error.message}`);
+    }
+  }
+
+  /**
+   * Privately share the full report of a test with the organization it was made for
+   * The report travels as transient data into the testReports collection of both organizations; only its hash is public
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} testId - Test result ID
+   * @param {Object} reportDetails - { counterpartyMspId, details }; details is free text or an object (stored as JSON)
+   * @returns {Promise<Object>} Transaction result
+   */
+  async setTestReportDetails(role, batchId, testId, { counterpartyMspId, details }) {
+    if (!counterpartyMspId || details === undefined || details === null || details === '') {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: counterpartyMspId and details are required`);
+    }
+
+    const detailsText = typeof details === 'string' ? details : JSON.stringify(details);
+    try {
+      const result = await fabricDAO.submitAsyncTransaction(role, 'QualityCertificationContract:SetTestReportDetails', {
+        arguments: [batchId, testId, counterpartyMspId],
+        transientData: { reportDetails: detailsText }
+      });
+      await cacheService.invalidateBatchCache(batchId);
+      return result;
+    } catch (error) {
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (error.message.includes('already recorded')) {
+        throw new Error(`${errorCodes.ALREADY_EXISTS}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to set test report details: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the full report of a test shared with the caller's organization
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} testId - Test result ID
+   * @returns {Promise<Object>} Test report details record
+   */
+  async getTestReportDetails(role, batchId, testId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'QualityCertificationContract:ReadTestReportDetails', batchId, testId);
+    } catch (error) {
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (/no report details|only their hash remains/.test(error.message)) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to get test report details: ${error.message}`);
+    }
+  }
+
+  /**
+   * Reference a batch committed on another channel as a source of a batch on the current channel
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID on the current channel
+   * @param {Object} reference - { channel, foreignBatchId, stateHash, chaincodeName }; chaincodeName defaults to
+   *   the one registered for the foreign channel
+   * @returns {Promise<Object>} Transaction result
+   */
+  async linkForeignBatch(role, batchId, reference) {
+    const { channel, foreignBatchId, stateHash } = reference;
+    if (!batchId || !channel || !foreignBatchId || !stateHash) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch ID, channel, foreignBatchId and stateHash are required`);
+    }
+
+    const chaincodeName = reference.chaincodeName || (fabric.channels[channel] || {}).chaincodeName;
+    if (!chaincodeName) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Channel ${channel} is not in the channel registry; chaincodeName is required`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'LinkForeignBatch', batchId, channel, foreignBatchId, stateHash, chaincodeName);
+      await cacheService.invalidateBatchCache(batchId);
+      return result;
+    } catch (error) {
+      throw new Error(`Failed to link foreign batch: ${error.message}`);
+    }
+  }
+
+  /**
+   * Replace the labels of a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object} labels - { key: value } string labels, e.g. { 'export-market': 'JP' }; {} removes all labels
+   * @returns {Promise<Object>} { batchId, labels }
+   */
+  async setBatchLabels(role, batchId, labels) {
+    if (!labels || typeof labels !== 'object' || Array.isArray(labels)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: labels must be an object of key-value pairs`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'SetBatchLabels', batchId, JSON.stringify(labels));
+      await cacheService.invalidateBatchCache(batchId);
+      return { batchId, labels };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to set batch labels: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the batches carrying a label
+   * @param {string} role - Caller role
+   * @param {string} key - Label key
+   * @param {string} [value] - Label value; any value when empty
+   * @returns {Promise<Array>} Batch list
+   */
+  async getBatchesByLabel(role, key, value = '') {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GetRiceBatchesByLabel', key, value);
+    } catch (error) {
+      throw new Error(`Failed to get batches by label: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get what an owner holds: its batches with remaining quantities, its products, and totals by variety and step
+   * @param {string} role - Caller role
+   * @param {string} owner - Owner name
+   * @returns {Promise<Object>} { owner, batches, products, totals, byVariety, byStep }
+   */
+  async getOwnerInventory(role, owner) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GetOwnerInventory', owner);
+    } catch (error) {
+      throw new Error(`Failed to get owner inventory: ${error.message}`);
+    }
+  }
+
+  /**
+   * Put offered lots side by side: origin, variety, available quantity, latest test per type, grades and
+   * active certificates
+   * @param {string} role - Caller role
+   * @param {string[]} batchIds - 2 to 10 batch IDs
+   * @returns {Promise<Object>} { comparedAt, testTypes, certificateTypes, batches }
+   */
+  async compareBatches(role, batchIds) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'CompareBatches', JSON.stringify(batchIds));
+    } catch (error) {
+      if (error.message.includes('different batches can be compared')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ids must name between 2 and 10 different batches`);
+      }
+      const missing = /The rice batch (\S+) does not exist/.exec(error.message);
+      if (missing) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${missing[1]} does not exist`);
+      }
+      throw new Error(`Failed to compare batches: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the traceability completeness score of a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Object>} { batchId, score: 0-100, criteria: [{ criterion, weight, fulfilment, detail }], criteriaVersion }
+   */
+  async getTraceabilityScore(role, batchId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'TraceabilityScoreContract:GetTraceabilityScore', batchId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to get traceability score: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the scores of the batches scoring at least minScore, best documented first
+   * @param {string} role - Caller role
+   * @param {string} [minScore] - Minimum score, 0-100
+   * @returns {Promise<Array>} Traceability scores
+   */
+  async getWellDocumentedBatches(role, minScore = '') {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'TraceabilityScoreContract:GetWellDocumentedBatches', String(minScore));
+    } catch (error) {
+      if (error.message.includes('Invalid minimum score')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: minScore must be a number between 0 and 100`);
+      }
+      throw new Error(`Failed to get well-documented batches: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the storage used by a batch against the configured batch storage limits
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Object>} { batchId, documentBytes, maxDocumentBytes, storedHistoryEvents, maxHistoryEvents,
+   *   archivedHistorySegments, totalHistoryEvents, testResults, maxTestResultsPerBatch }
+   */
+  async getBatchStorageUsage(role, batchId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'BatchStorageContract:GetBatchStorageUsage', batchId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to get batch storage usage: ${error.message}`);
+    }
+  }
+
+  /**
+   * Let another identity (e.g. a cooperative or broker) transfer or process a batch on the farmer's behalf
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object} grant - { delegateIdentity: "<MSP ID>:<certificate fingerprint>", permissions: ['transfer', 'process'], expiry }
+   * @returns {Promise<Object>} Recorded delegation
+   */
+  async grantDelegate(role, batchId, grant) {
+    const { delegateIdentity, permissions, expiry } = grant;
+    if (!batchId || !delegateIdentity || !expiry) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch ID, delegateIdentity and expiry are required`);
+    }
+    if (!Array.isArray(permissions) || permissions.length === 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: permissions must be a non-empty list of transfer and/or process`);
+    }
+
+    try {
+      const delegation = await fabricDAO.submitTransaction(role, 'GrantDelegate', batchId, delegateIdentity, permissions.join(','), expiry);
+      await cacheService.invalidateBatchCache(batchId);
+      return delegation;
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to grant delegation: ${error.message}`);
+    }
+  }
+
+  /**
+   * Revoke a delegation on a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} delegationId - Delegation ID (the granting transaction ID)
+   * @returns {Promise<Object>} { batchId, delegationId }
+   */
+  async revokeDelegate(role, batchId, delegationId) {
+    try {
+      await fabricDAO.submitTransaction(role, 'RevokeDelegate', batchId, delegationId);
+      await cacheService.invalidateBatchCache(batchId);
+      return { batchId, delegationId };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message}`);
+      }
+      throw new Error(`Failed to revoke delegation: ${error.message}`);
+    }
+  }
+
+  /**
+   * Declare the quantity of rice in a batch, which reservations are taken from
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {number} quantityKg - Quantity in kg
+   * @returns {Promise<Object>} { batchId, quantityKg }
+   */
+  async setBatchQuantity(role, batchId, quantityKg) {
+    if (!(Number(quantityKg) > 0)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: quantityKg must be a positive number`);
+    }
+
+    try {
+      await fabricDAO.submitTransaction(role, 'BatchReservationContract:SetBatchQuantity', batchId, String(quantityKg));
+      await cacheService.invalidateBatchCache(batchId);
+      return { batchId, quantityKg: Number(quantityKg) };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      if (error.message.includes('RecordWeightAdjustment')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: Losses of a declared quantity are recorded with POST /api/batch/${batchId}/weight-adjustments`);
+      }
+      throw new Error(`Failed to set batch quantity: ${error.message}`);
+    }
+  }
+
+  /**
+   * Record the weight a batch lost in a processing step, e.g. drying, lowering its declared quantity
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object} adjustment - { step, beforeKg, afterKg, reason }
+   * @returns {Promise<Object>} Recorded adjustment
+   */
+  async recordWeightAdjustment(role, batchId, adjustment) {
+    const { step, beforeKg, afterKg, reason } = adjustment;
+    if (!step || !reason || !(Number(beforeKg) > 0) || !(Number(afterKg) > 0)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: step, reason and positive beforeKg and afterKg are required`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'BatchReservationContract:RecordWeightAdjustment',
+        batchId, step, String(beforeKg), String(afterKg), reason);
+      await cacheService.invalidateBatchCache(batchId);
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      if (/implausible|does not match the declared quantity|not recorded in the history|exceeds the weight before/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to record weight adjustment: ${error.message}`);
+    }
+  }
+
+  /**
+   * Reserve quantity of a batch for a pending sale, so it cannot be sold twice
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object} reservation - { buyer, quantityKg, expiry }
+   * @returns {Promise<Object>} Recorded reservation
+   */
+  async reserveBatchQuantity(role, batchId, reservation) {
+    const { buyer, quantityKg, expiry } = reservation;
+    if (!buyer || !expiry || !(Number(quantityKg) > 0)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: buyer, a positive quantityKg and expiry are required`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'BatchReservationContract:ReserveBatchQuantity', batchId, buyer, String(quantityKg), expiry);
+      await cacheService.invalidateBatchCache(batchId);
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to reserve batch quantity: ${error.message}`);
+    }
+  }
+
+  /**
+   * Release a reservation when the sale falls through
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} reservationId - Reservation ID (the reserving transaction ID)
+   * @returns {Promise<Object>} { batchId, reservationId }
+   */
+  async releaseReservation(role, batchId, reservationId) {
+    try {
+      await fabricDAO.submitTransaction(role, 'BatchReservationContract:ReleaseReservation', batchId, reservationId);
+      await cacheService.invalidateBatchCache(batchId);
+      return { batchId, reservationId };
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message}`);
+      }
+      throw new Error(`Failed to release reservation: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the quantity of a batch, its reservations and the quantity still available
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Object>} { batchId, quantityKg, reservedKg, availableKg, reservations }
+   */
+  async getBatchReservations(role, batchId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'BatchReservationContract:GetBatchReservations', batchId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to get batch reservations: ${error.message}`);
+    }
+  }
+
+  /**
+   * Schedule the handover of a batch for a contractual date; any party can activate it once the date is reached
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object} schedule - { newOwner, effectiveTime }
+   * @returns {Promise<Object>} Scheduled transfer
+   */
+  async scheduleTransfer(role, batchId, schedule) {
+    const { newOwner, effectiveTime } = schedule;
+    if (!newOwner || !effectiveTime) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: newOwner and effectiveTime are required`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'ScheduledTransferContract:ScheduleTransfer', batchId, newOwner, effectiveTime);
+      await cacheService.invalidateBatchCache(batchId);
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to schedule transfer: ${error.message}`);
+    }
+  }
+
+  /**
+   * Activate the pending scheduled transfer of a batch once its effective time is reached
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Object>} Activated transfer
+   */
+  async activateScheduledTransfer(role, batchId) {
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'ScheduledTransferContract:ActivateScheduledTransfer', batchId);
+      await cacheService.invalidateBatchCache(batchId);
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('does not exist') || error.message.includes('no pending scheduled transfer')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to activate scheduled transfer: ${error.message}`);
+    }
+  }
+
+  /**
+   * Cancel the pending scheduled transfer of a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Object>} { batchId }
+   */
+  async cancelScheduledTransfer(role, batchId) {
+    try {
+      await fabricDAO.submitTransaction(role, 'ScheduledTransferContract:CancelScheduledTransfer', batchId);
+      await cacheService.invalidateBatchCache(batchId);
+      return { batchId };
+    } catch (error) {
+      if (error.message.includes('does not exist') || error.message.includes('no pending scheduled transfer')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to cancel scheduled transfer: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the scheduled transfers of a batch, pending and closed
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Array>} Scheduled transfers
+   */
+  async getScheduledTransfers(role, batchId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ScheduledTransferContract:GetScheduledTransfers', batchId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to get scheduled transfers: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the pending scheduled transfers whose effective time has been reached
+   * @param {string} role - Caller role
+   * @returns {Promise<Array>} Due scheduled transfers, oldest first
+   */
+  async getDueScheduledTransfers(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ScheduledTransferContract:GetDueScheduledTransfers');
+    } catch (error) {
+      throw new Error(`Failed to get due scheduled transfers: ${error.message}`);
+    }
+  }
+
+  /**
+   * Correct the step and/or report of a mistyped processing record; the original record is kept as superseded
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {number|string} index - Position of the record in the batch history (0 = registration)
+   * @param {Object} correction - { step?, reportId?, reason }; reportId replaces the report with the verified report
+   * @returns {Promise<Object>} Recorded correction
+   */
+  async correctProcessingRecord(role, batchId, index, correction) {
+    const { step, reportId, reason } = correction;
+    if (!/^\d+$/.test(String(index))) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Record index must be a non-negative integer`);
+    }
+    if (!reason || (!step && !reportId)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: reason and a corrected step or reportId are required`);
+    }
+
+    try {
+      const correctedRecord = {};
+      if (step) {
+        correctedRecord.step = step;
+      }
+      if (reportId) {
+        const reportService = require('./ReportService');
+        correctedRecord.report = await reportService.verifyAndFetchReportDetail(reportId);
+      }
+
+      const result = await fabricDAO.submitTransaction(role, 'CorrectProcessingRecord', batchId, String(index), JSON.stringify(correctedRecord), reason);
+      await cacheService.invalidateBatchCache(batchId);
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      if (/Invalid record index|does not change record/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to correct processing record: ${error.message}`);
+    }
+  }
+
+  /**
+   * Add a run of processing records of a batch without handover in one transaction, e.g. from a packaging line
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object[]} records - [{ step, reportId?, summary?, timestamp?, equipmentId?, inputs?, facilityId?, line?, shift?,
+   *   ambient?, resources? }] in the order they happened; reportId attaches the verified report, otherwise a ProcessingRecord report with the summary is recorded.
+   *   resources ({ energyKwh?, laborHours?, machineHours? }) travel as transient data into the caller organization's implicit collection
+   * @param {string} [clientRequestId] - Idempotency key
+   * @returns {Promise<Object>} { batchId, added }
+   */
+  async addProcessingRecords(role, batchId, records, clientRequestId = '') {
+    if (!Array.isArray(records) || records.length === 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: records must be a non-empty list`);
+    }
+
+    try {
+      const reportService = require('./ReportService');
+      const processingRecords = [];
+      const resources = [];
+      for (const { step, reportId, summary, timestamp, equipmentId, inputs, facilityId, line, shift, ambient, resources: usage } of records) {
+        const report = reportId
+          ? await reportService.verifyAndFetchReportDetail(reportId)
+          : { reportId: '', reportType: 'ProcessingRecord', reportHash: '', summary: summary || step || '', isVerified: false };
+        if (equipmentId) {
+          report.equipmentId = equipmentId;
+        }
+        if (inputs) {
+          report.inputs = inputs;
+        }
+        Object.assign(report, this._facilityContext({ facilityId, line, shift, ambient }));
+        const record = { step, report };
+        if (timestamp) {
+          record.timestamp = timestamp;
+        }
+        processingRecords.push(record);
+        resources.push(usage || null);
+      }
+
+      const args = [batchId, JSON.stringify(processingRecords), clientRequestId];
+      const added = resources.some(Boolean)
+        ? await fabricDAO.submitAsyncTransaction(role, 'AddProcessingRecords', {
+          arguments: args,
+          transientData: { resources: JSON.stringify(resources) }
+        })
+        : new TextDecoder().decode(await fabricDAO.submitTransaction(role, 'AddProcessingRecords', ...args));
+      await cacheService.invalidateBatchCache(batchId);
+      return { batchId, added: Number(added) };
+    } catch (error) {
+      if (error.message.includes(`rice batch ${batchId} does not exist`)) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      if (/are invalid, none were added|must be a list of|Resource usage|must be a non-negative number/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to add processing records: ${error.message}`);
+    }
+  }
+
+  /**
+   * Scan the ledger for products referencing missing batches, unregistered transfer parties and stale genealogy
+   * edges, e.g. after a bulk import. The request must be signed by an organization administrator identity
+   * @param {string} role - Caller role
+   * @returns {Promise<Object>} { batches, products, participants, consistent, issues: [{ rule, entityId, reference, index?, message, repair }] }
+   */
+  async validateReferentialIntegrity(role) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'ValidateReferentialIntegrity');
+    } catch (error) {
+      if (error.message.includes('Only organization administrators')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: The integrity check must be signed by an organization administrator identity (X-Fabric-Identity)`);
+      }
+      throw new Error(`Failed to validate referential integrity: ${error.message}`);
+    }
+  }
+
+  /**
+   * Compare a referenced foreign batch with the state recorded when it was linked
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID on the current channel
+   * @param {string} channel - Channel of the foreign batch
+   * @param {string} foreignBatchId - Batch ID on the foreign channel
+   * @returns {Promise<Object>} Verification result ({ matches, recordedStateHash, currentStateHash, summary })
+   */
+  async verifyForeignBatchReference(role, batchId, channel, foreignBatchId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'VerifyForeignBatchReference', batchId, channel, foreignBatchId);
+    } catch (error) {
+      throw new Error(`Failed to verify foreign batch reference: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the state hash of a batch, to be cited when it is referenced from another channel
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @returns {Promise<Object>} { channel, batchId, stateHash }
+   */
+  async getBatchStateHash(role, batchId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GetBatchStateHash', batchId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      throw new Error(`Failed to get batch state hash: ${error.message}`);
+    }
+  }
+
+  /**
+   * Compare two committed versions of a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string} fromTxId - Transaction that wrote the earlier version; empty for the version before toTxId
+   * @param {string} toTxId - Transaction that wrote the later version
+   * @returns {Promise<Object>} { batchId, fromTxId, fromTimestamp, toTxId, toTimestamp, changes }
+   */
+  async getBatchHistoryDiff(role, batchId, fromTxId, toTxId) {
+    if (!toTxId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: The transaction ID of the later version (to) is required`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GetBatchHistoryDiff', batchId, fromTxId || '', toTxId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      if (error.message.includes('did not write')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Transaction ${fromTxId || toTxId} did not write batch ${batchId}`);
+      }
+      throw new Error(`Failed to get batch history diff: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the ancestor/descendant graph of a batch, e.g. to find every product a recall reaches
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string|number} [depth] - Hops to follow (1-10, default 3)
+   * @returns {Promise<Object>} { batchId, depth, nodes, edges, truncated }
+   */
+  async getBatchGenealogy(role, batchId, depth = '') {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'GetBatchGenealogy', batchId, String(depth));
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Batch ${batchId} does not exist`);
+      }
+      if (error.message.includes('Invalid depth')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: depth must be a whole number of hops from 1 to 10`);
+      }
+      throw new Error(`Failed to get batch genealogy: ${error.message}`);
+    }
+  }
+
+  /**
+   * Attach an insurance policy to a batch; the caller's organization is the policyholder
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object} policy - { insurer, policyNumber, coverage (crop | storage | transport), validFrom, validTo,
+   *   documentHash (SHA-256 of the policy document) }
+   * @returns {Promise<Object>} Transaction result
+   */
+  async attachInsurancePolicy(role, batchId, policy) {
+    const { insurer, policyNumber, coverage, validFrom, validTo, documentHash } = policy;
+    if (!batchId || !insurer || !policyNumber || !coverage || !validFrom || !validTo || !documentHash) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch ID, insurer, policyNumber, coverage, validFrom, validTo and documentHash are required`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'AttachInsurancePolicy', batchId, insurer, policyNumber, coverage, `${validFrom}/${validTo}`, documentHash);
+      await cacheService.invalidateBatchCache(batchId);
+      return result;
+    } catch (error) {
+      throw new Error(`Failed to attach insurance policy: ${error.message}`);
+    }
+  }
+
+  /**
+   * File an insurance claim against a policy attached to a batch
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {Object} claim - { insurer, policyNumber, claimId, incidentDate, description, evidence: [{ type, reference, description }] }
+   * @returns {Promise<Object>} Transaction result
+   */
+  async claimInsurance(role, batchId, claim) {
+    const { insurer, policyNumber, claimId, incidentDate, description, evidence } = claim;
+    if (!batchId || !insurer || !policyNumber || !claimId || !incidentDate || !description) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Batch ID, insurer, policyNumber, claimId, incidentDate and description are required`);
+    }
+    if (!Array.isArray(evidence) || evidence.length === 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: evidence must be a non-empty list of { type, reference }`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'ClaimInsurance', batchId, insurer, policyNumber, claimId, incidentDate, description, JSON.stringify(evidence));
+      await cacheService.invalidateBatchCache(batchId);
+      return result;
+    } catch (error) {
+      throw new Error(`Failed to file insurance claim: ${error.message}`);
+    }
+  }
+
+  /**
+   * Validate date format
+   * @private
+   */
+  _isValidDate(dateString) {
+    return !isNaN(Date.parse(dateString));
+  }
+
+  /**
+   * Facility context fields of a step report, leaving out those not given
+   * @private
+   */
+  _facilityContext({ facilityId, line, shift, ambient }) {
+    const context = {};
+    if (facilityId) {
+      context.facilityId = facilityId;
+    }
+    if (line) {
+      context.line = line;
+    }
+    if (shift) {
+      context.shift = shift;
+    }
+    if (ambient) {
+      context.ambient = ambient;
+    }
+    return context;
   }
 }
 
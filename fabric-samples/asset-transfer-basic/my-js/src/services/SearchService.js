@@ -0,0 +1,159 @@
+const riceService = require('./RiceService');
+const { errorCodes } = require('../../config');
+
+/**
+ * Search service layer
+ * Free-text, typo-tolerant batch search over origin, variety, owner and operator names, which ledger-side
+ * queries cannot do. Runs over the gateway's cached batch list
+ */
+
+const SEARCH_FIELDS = {
+  origin: { weight: 2, values: batch => [batch.origin] },
+  variety: { weight: 2, values: batch => [batch.variety] },
+  owner: { weight: 1.5, values: batch => [batch.currentOwner] },
+  operator: { weight: 1, values: batch => (batch.history || []).flatMap(event => [event.from, event.to]) }
+};
+
+const DEFAULT_SEARCH_LIMIT = 20;
+const MAX_SEARCH_LIMIT = 100;
+
+class SearchService {
+
+  /**
+   * Search batches by free text
+   * Every query term must match a word of one of the searched fields, exactly, as a prefix or substring,
+   * or within a small edit distance (1 for terms of 4+ characters, 2 for 8+). Matching ignores case and accents
+   * @param {string} role - Caller role
+   * @param {string} query - Search text, e.g. "wuchang daohuaxing"
+   * @param {Object} [options] - { fields: comma-separated subset of origin, variety, owner, operator; limit: 1-100 }
+   * @returns {Promise<Array>} { batchId, score, matches: { field: [matched values] }, batch }, best match first
+   */
+  async searchBatches(role, query, options = {}) {
+    const terms = this._tokenize(query);
+    if (terms.length === 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Search text (q) cannot be empty`);
+    }
+    const fields = options.fields ? String(options.fields).split(',').map(field => field.trim()) : Object.keys(SEARCH_FIELDS);
+    const unknown = fields.filter(field => !SEARCH_FIELDS[field]);
+    if (unknown.length > 0) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Unknown search fields ${unknown.join(', ')}; use ${Object.keys(SEARCH_FIELDS).join(', ')}`);
+    }
+    const limit = options.limit === undefined || options.limit === '' ? DEFAULT_SEARCH_LIMIT : Number(options.limit);
+    if (!Number.isInteger(limit) || limit <= 0 || limit > MAX_SEARCH_LIMIT) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: limit must be an integer between 1 and ${MAX_SEARCH_LIMIT}`);
+    }
+
+    let batches;
+    try {
+      batches = await riceService.getAllBatches(role);
+    } catch (error) {
+      throw new Error(`Failed to search batches: ${error.message}`);
+    }
+
+    const hits = [];
+    for (const batch of batches) {
+      const hit = this._scoreBatch(batch, terms, fields);
+      if (hit) {
+        hits.push(hit);
+      }
+    }
+    return hits
+      .sort((a, b) => b.score - a.score || a.batchId.localeCompare(b.batchId))
+      .slice(0, limit);
+  }
+
+  /**
+   * Score a batch against the query terms; null unless every term matches some field
+   * @private
+   */
+  _scoreBatch(batch, terms, fields) {
+    const matches = {};
+    let score = 0;
+
+    for (const term of terms) {
+      let best = null;
+      for (const field of fields) {
+        for (const value of SEARCH_FIELDS[field].values(batch)) {
+          if (!value) {
+            continue;
+          }
+          const quality = this._matchQuality(term, this._normalize(value));
+          if (quality > 0 && (!best || quality * SEARCH_FIELDS[field].weight > best.score)) {
+            best = { field, value, score: quality * SEARCH_FIELDS[field].weight };
+          }
+        }
+      }
+      if (!best) {
+        return null;
+      }
+      score += best.score;
+      matches[best.field] = [...new Set([...(matches[best.field] || []), best.value])];
+    }
+
+    return { batchId: batch.batchId, score: Math.round(score * 100) / 100, matches, batch };
+  }
+
+  /**
+   * How well a term matches a normalized field value: 1 whole word, 0.8 word prefix, 0.6 substring,
+   * 0.5 / 0.3 for a word one / two edits away; 0 for no match
+   * @private
+   */
+  _matchQuality(term, value) {
+    const words = this._tokenize(value);
+    if (words.includes(term)) {
+      return 1;
+    }
+    if (words.some(word => word.startsWith(term))) {
+      return 0.8;
+    }
+    if (value.includes(term)) {
+      return 0.6;
+    }
+    const tolerance = term.length >= 8 ? 2 : term.length >= 4 ? 1 : 0;
+    const distance = Math.min(Infinity, ...words.map(word => this._editDistance(term, word, tolerance)));
+    if (distance <= tolerance) {
+      return distance === 1 ? 0.5 : 0.3;
+    }
+    return 0;
+  }
+
+  /**
+   * Levenshtein distance, giving up (returning tolerance + 1) once it exceeds the tolerance
+   * @private
+   */
+  _editDistance(a, b, tolerance) {
+    if (Math.abs(a.length - b.length) > tolerance) {
+      return tolerance + 1;
+    }
+    let previous = Array.from({ length: b.length + 1 }, (_, index) => index);
+    for (let i = 1; i <= a.length; i++) {
+      const current = [i];
+      for (let j = 1; j <= b.length; j++) {
+        current[j] = Math.min(previous[j] + 1, current[j - 1] + 1, previous[j - 1] + (a[i - 1] === b[j - 1] ? 0 : 1));
+      }
+      if (Math.min(...current) > tolerance) {
+        return tolerance + 1;
+      }
+      previous = current;
+    }
+    return previous[b.length];
+  }
+
+  /**
+   * Lowercase and strip accents, so "Wǔcháng" matches "wuchang"
+   * @private
+   */
+  _normalize(text) {
+    return String(text || '').normalize('NFKD').replace(/[\u0300-\u036f]/g, '').toLowerCase();
+  }
+
+  /**
+   * Split text into normalized words
+   * @private
+   */
+  _tokenize(text) {
+    return this._normalize(text).split(/[^\p{L}\p{N}]+/u).filter(Boolean);
+  }
+}
+
+module.exports = new SearchService();
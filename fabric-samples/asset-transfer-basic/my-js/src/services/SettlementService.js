@@ -0,0 +1,75 @@
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Settlement service layer
+ * Records the settlement status of batch handovers and reports the transfers out of a farmer's batches, so
+ * cooperatives can reconcile payouts per season
+ */
+class SettlementService {
+
+  /**
+   * Record the settlement status of the handover recorded by a history event
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string|number} index - Position of the history event
+   * @param {Object} settlement - { status: invoiced|paid|disputed, reference? }
+   * @returns {Promise<Object>} Settlement with its status changes
+   */
+  async recordSettlement(role, batchId, index, { status, reference = '' }) {
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'SettlementContract:RecordSettlement', batchId, String(index), status, reference);
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (/does not exist|has no history event/.test(error.message)) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (/Invalid settlement status|not a handover/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to record settlement: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the settlement of a handover
+   * @param {string} role - Caller role
+   * @param {string} batchId - Batch ID
+   * @param {string|number} index - Position of the history event
+   * @returns {Promise<Object>} Settlement
+   */
+  async getSettlement(role, batchId, index) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'SettlementContract:GetSettlement', batchId, String(index));
+    } catch (error) {
+      if (error.message.includes('No settlement is recorded')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: No settlement is recorded for history event ${index} of batch ${batchId}`);
+      }
+      throw new Error(`Failed to get settlement: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the transfers out of a farmer's batches in a period with their settlement statuses
+   * Evaluated on a peer of the caller's organization, so the report carries its private commercial terms
+   * @param {string} role - Caller role
+   * @param {string} farmerId - Participant ID or name of the farmer
+   * @param {string} [period] - Crop year (2024), crop season (2024-Late) or interval of transfer dates
+   * @returns {Promise<Object>} { farmerId, farmerNames, period, pricesIncluded, lines, statusCounts, generatedAt }
+   */
+  async getFarmerSettlementReport(role, farmerId, period = '') {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'SettlementContract:GetFarmerSettlementReport', farmerId, period);
+    } catch (error) {
+      if (error.message.includes('Invalid period') || error.message.includes('must be an interval')) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to get farmer settlement report: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new SettlementService();
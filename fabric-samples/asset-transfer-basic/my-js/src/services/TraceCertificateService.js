@@ -0,0 +1,278 @@
+const crypto = require('node:crypto');
+const fabricDAO = require('../dao/FabricDAO');
+const riceService = require('./RiceService');
+const productService = require('./ProductService');
+const { toPdf } = require('../export/pdf');
+const { labels, errorCodes, getRoleConfig } = require('../../config');
+
+/**
+ * Traceability certificate service layer
+ * Renders PDF traceability certificates of batches and products (origin, journey, tests, quality certificates and
+ * a QR code of the public trace page) and anchors the hash of each PDF on the ledger. The anchoring transaction,
+ * signed by the issuing organization's identity, is the certificate's signature: a PDF is genuine when its hash is
+ * anchored, and any change to the file breaks the match
+ */
+
+const DOCUMENT_TYPE = 'traceabilityCertificate';
+
+class TraceCertificateService {
+
+  /**
+   * Issue a traceability certificate of a batch or product
+   * @param {string} role - Caller role
+   * @param {string} entityType - batch | product
+   * @param {string} entityId - Batch or product ID
+   * @returns {Promise<Object>} { filename, contentType, body, certificateId, documentHash, document }
+   */
+  async issueCertificate(role, entityType, entityId) {
+    if (!entityId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: ${entityType === 'product' ? 'Product' : 'Batch'} ID cannot be empty`);
+    }
+
+    let product = null;
+    let batch;
+    if (entityType === 'product') {
+      ({ product, batch } = await productService.getProductById(role, entityId));
+    } else {
+      batch = await riceService.getBatchById(role, entityId);
+    }
+    const [testResults, certificates] = await Promise.all([
+      riceService.getTestResultsByBatch(role, batch.batchId),
+      riceService.getCertificatesByBatch(role, batch.batchId)
+    ]);
+
+    const issuedAt = new Date().toISOString();
+    const certificateId = `RTC-${issuedAt.slice(0, 10).replace(/-/g, '')}-${crypto.randomBytes(4).toString('hex').toUpperCase()}`;
+    const issuer = getRoleConfig(role).mspId;
+    const body = toPdf({
+      title: `Traceability Certificate ${certificateId}`,
+      author: issuer,
+      createdAt: issuedAt,
+      footer: `Certificate ${certificateId} - the SHA-256 of this file is anchored on the RiceTrace ledger`,
+      blocks: this._certificateBlocks({ certificateId, issuedAt, issuer, entityType, entityId, product, batch, testResults, certificates })
+    });
+    const documentHash = crypto.createHash('sha256').update(body).digest('hex');
+
+    let document;
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'DocumentAnchorContract:AnchorDocument',
+        certificateId, entityId, DOCUMENT_TYPE, documentHash);
+      document = JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      throw new Error(`Failed to anchor traceability certificate: ${error.message}`);
+    }
+
+    return {
+      filename: `${certificateId}.pdf`,
+      contentType: 'application/pdf',
+      body,
+      certificateId,
+      documentHash,
+      document
+    };
+  }
+
+  /**
+   * Check a PDF (or the SHA-256 of one) against the anchored documents
+   * @param {string} role - Caller role
+   * @param {Object} input - { file: Buffer } or { documentHash }
+   * @returns {Promise<Object>} { verified, documentHash, document }
+   */
+  async verifyDocument(role, { file, documentHash }) {
+    const hash = file && file.length > 0
+      ? crypto.createHash('sha256').update(file).digest('hex')
+      : String(documentHash || '').toLowerCase();
+    if (!/^[0-9a-f]{64}$/.test(hash)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Send the PDF as application/pdf or its SHA-256 as documentHash`);
+    }
+
+    try {
+      const document = await fabricDAO.evaluateTransaction(role, 'DocumentAnchorContract:GetDocumentByHash', hash);
+      return { verified: true, documentHash: hash, document };
+    } catch (error) {
+      if (error.message.includes('has been anchored')) {
+        return { verified: false, documentHash: hash, document: null };
+      }
+      throw new Error(`Failed to verify document: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get an anchored document by ID, e.g. the certificate number printed on a PDF
+   * @param {string} role - Caller role
+   * @param {string} documentId - Document ID
+   * @returns {Promise<Object>} Anchored document
+   */
+  async getDocument(role, documentId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'DocumentAnchorContract:ReadAnchoredDocument', documentId);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Document ${documentId} does not exist`);
+      }
+      throw new Error(`Failed to get document: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the documents issued about a batch or product, oldest first
+   * @param {string} role - Caller role
+   * @param {string} entityId - Batch or product ID
+   * @returns {Promise<Array>} Anchored documents
+   */
+  async getEntityDocuments(role, entityId) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'DocumentAnchorContract:GetAnchoredDocuments', entityId);
+    } catch (error) {
+      throw new Error(`Failed to get documents: ${error.message}`);
+    }
+  }
+
+  /**
+   * Acknowledge having received and reviewed a quality document of a batch or product
+   * @param {string} role - Caller role
+   * @param {string} entityId - Batch or product ID
+   * @param {Object} acknowledgment - { documentHash, note? }; the hash of a test report, attachment or anchored document
+   * @returns {Promise<Object>} Acknowledgment recorded with the caller's identity
+   */
+  async acknowledgeDocument(role, entityId, { documentHash, note = '' }) {
+    if (!documentHash) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: documentHash is required`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'DocumentAnchorContract:AcknowledgeDocument', entityId, documentHash, note);
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (/No batch or product|No test report, attachment or anchored document/.test(error.message)) {
+        throw new Error(`${errorCodes.NOT_FOUND}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (error.message.includes('already been acknowledged')) {
+        throw new Error(`${errorCodes.ALREADY_EXISTS}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to acknowledge document: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the acknowledgments of the documents of a batch or product, oldest first
+   * @param {string} role - Caller role
+   * @param {string} entityId - Batch or product ID
+   * @param {string} [documentHash] - Only the acknowledgments of this document
+   * @returns {Promise<Array>} Acknowledgments
+   */
+  async getAcknowledgments(role, entityId, documentHash = '') {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'DocumentAnchorContract:GetDocumentAcknowledgments', entityId, documentHash);
+    } catch (error) {
+      throw new Error(`Failed to get document acknowledgments: ${error.message}`);
+    }
+  }
+
+  /**
+   * Content of a certificate
+   * @private
+   */
+  _certificateBlocks({ certificateId, issuedAt, issuer, entityType, entityId, product, batch, testResults, certificates }) {
+    const traceUrl = `${labels.traceBaseUrl}/${entityType}/${encodeURIComponent(entityId)}`;
+    const activeTests = (testResults || []).filter(test => !test.revoked);
+    const gi = batch.giCompliance && batch.giCompliance.passed ? batch.giCompliance : null;
+
+    const blocks = [
+      { type: 'title', text: 'Traceability Certificate' },
+      { type: 'text', text: `This certifies the recorded supply chain of ${entityType} ${entityId} on the RiceTrace ledger as of ${issuedAt}.` },
+      {
+        type: 'fields',
+        rows: [
+          ['Certificate number', certificateId],
+          ['Issued by', issuer],
+          ['Issued at', issuedAt]
+        ]
+      }
+    ];
+
+    if (product) {
+      blocks.push(
+        { type: 'heading', text: 'Product' },
+        {
+          type: 'fields',
+          rows: [
+            ['Product ID', product.productId],
+            ['Package date', product.packageDate],
+            ['Status', product.status || 'Active'],
+            ...(product.composition && product.composition.grade ? [['Grade', product.composition.grade]] : []),
+            ...(product.composition && product.composition.netWeightG ? [['Net weight', `${product.composition.netWeightG} g`]] : [])
+          ]
+        }
+      );
+    }
+
+    blocks.push(
+      { type: 'heading', text: 'Origin' },
+      {
+        type: 'fields',
+        rows: [
+          ['Batch ID', batch.batchId],
+          ['Origin', batch.origin],
+          ['Variety', batch.variety],
+          ['Harvest date', batch.harvestDate],
+          ...(batch.cropYear ? [['Crop year', `${batch.cropYear}${batch.season ? ` (${batch.season})` : ''}`]] : []),
+          ...(gi ? [['Geographic indication', `${gi.giName} (${gi.giId})`]] : [])
+        ]
+      },
+      { type: 'heading', text: 'Journey' },
+      (batch.history || []).length > 0
+        ? {
+          type: 'table',
+          columns: [{ header: 'Date', width: 3 }, { header: 'Step', width: 3 }, { header: 'From', width: 3 }, { header: 'To', width: 3 }, { header: 'Signed by', width: 2 }],
+          rows: batch.history.map(event => [event.timestamp, event.step, event.from, event.to, event.signerMspId || ''])
+        }
+        : { type: 'text', text: 'No steps recorded.' },
+      { type: 'heading', text: 'Quality tests' },
+      activeTests.length > 0
+        ? {
+          type: 'table',
+          columns: [{ header: 'Test', width: 3 }, { header: 'Date', width: 3 }, { header: 'Result', width: 2 }, { header: 'Laboratory', width: 3 }, { header: 'Report hash', width: 3 }],
+          rows: activeTests.map(test => [
+            test.testType || test.testId,
+            test.testDate || test.timestamp,
+            test.testResult || test.result,
+            test.laboratory || test.tester,
+            test.reportHash ? `${test.reportHash.slice(0, 16)}...` : ''
+          ])
+        }
+        : { type: 'text', text: 'No test results recorded.' },
+      { type: 'heading', text: 'Quality certificates' },
+      (certificates || []).length > 0
+        ? {
+          type: 'table',
+          columns: [{ header: 'Certificate', width: 3 }, { header: 'Type', width: 3 }, { header: 'Issuer', width: 3 }, { header: 'Issued', width: 3 }, { header: 'Active', width: 1 }],
+          rows: certificates.map(certificate => [
+            certificate.certificateId,
+            certificate.certificateType,
+            certificate.issuer,
+            certificate.issueDate,
+            certificate.isActive ? 'Yes' : 'No'
+          ])
+        }
+        : { type: 'text', text: 'No quality certificates issued.' },
+      { type: 'heading', text: 'Verification' },
+      {
+        type: 'qr',
+        text: traceUrl,
+        caption: [
+          `Scan to view the live trace of this ${entityType}.`,
+          traceUrl,
+          `To check this certificate, send the PDF file to POST /api/documents/verify or look up ${certificateId}.`
+        ]
+      }
+    );
+
+    return blocks;
+  }
+}
+
+// Create singleton instance
+const traceCertificateService = new TraceCertificateService();
+
+module.exports = traceCertificateService;
@@ -0,0 +1,207 @@
+const crypto = require('node:crypto');
+const productService = require('./ProductService');
+const riceService = require('./RiceService');
+const attachmentService = require('./AttachmentService');
+const brandingService = require('./BrandingService');
+const cacheService = require('./CacheService');
+const translationService = require('./TranslationService');
+const { trace, errorCodes } = require('../../config');
+
+/**
+ * Consumer trace service layer
+ * Builds the single payload mobile apps show after a label is scanned: the brand of the organization that packaged
+ * the product, the public part of the product and its source batch, the journey, quality results, image and
+ * document links and the verification status. The payload
+ * is cached in Redis and localized per request with the label dictionaries, so one cache entry serves every language
+ */
+
+class TraceService {
+
+  /**
+   * Get the localized consumer trace of a product
+   * @param {string} productId - Product ID
+   * @param {string} locale - Locale (zh or en)
+   * @returns {Promise<Object>} { trace, etag }
+   */
+  async getProductTrace(productId, locale) {
+    if (!productId) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Product ID cannot be empty`);
+    }
+    const dictionary = await translationService.getDictionary(locale);
+
+    let payload = await cacheService.getTrace(productId);
+    if (!payload) {
+      payload = await this._buildTrace(productId);
+      await cacheService.setTrace(productId, payload);
+    }
+
+    const localized = this._localize(payload, dictionary);
+    const etag = `"${crypto.createHash('sha256').update(JSON.stringify(localized)).digest('hex').slice(0, 32)}"`;
+    return { trace: localized, etag };
+  }
+
+  /**
+   * Read everything the trace shows with the trace role's identity
+   * @private
+   */
+  async _buildTrace(productId) {
+    const { product, batch } = await productService.getProductById(trace.role, productId);
+
+    const [tests, certificates, productAttachments, batchAttachments, verification, brand] = await Promise.all([
+      riceService.getTestResultsByBatch(trace.role, product.batchId),
+      riceService.getCertificatesByBatch(trace.role, product.batchId),
+      attachmentService.listAttachments(trace.role, productId),
+      attachmentService.listAttachments(trace.role, product.batchId),
+      this._getVerification(productId),
+      this._getBrand(product)
+    ]);
+
+    const attachments = [...(productAttachments || []), ...(batchAttachments || [])]
+      .filter(attachment => trace.attachmentCategories.includes(attachment.category));
+
+    return {
+      brand,
+      productId: product.productId,
+      batchId: product.batchId,
+      packageDate: product.packageDate,
+      status: product.status || 'Active',
+      nutrition: product.nutrition || null,
+      composition: product.composition || null,
+      origin: {
+        origin: batch.origin,
+        variety: batch.variety,
+        harvestDate: batch.harvestDate,
+        cropYear: batch.cropYear || null,
+        season: batch.season || null,
+        geographicIndication: batch.giCompliance && batch.giCompliance.passed
+          ? { giId: batch.giCompliance.giId, name: batch.giCompliance.giName }
+          : null
+      },
+      journey: (batch.history || []).map(event => ({
+        step: event.step,
+        timestamp: event.timestamp,
+        from: event.from,
+        to: event.to
+      })),
+      qualityTests: (tests || [])
+        .filter(test => !test.revoked)
+        .map(test => ({
+          testType: test.testType,
+          testDate: test.testDate || test.timestamp,
+          result: test.testResult || test.result,
+          laboratory: test.laboratory || null,
+          verified: Boolean(test.isVerified)
+        })),
+      certificates: (certificates || [])
+        .filter(certificate => certificate.isActive)
+        .map(certificate => ({
+          certificateType: certificate.certificateType,
+          issuer: certificate.issuer,
+          issueDate: certificate.issueDate,
+          validityPeriod: certificate.validityPeriod,
+          standards: certificate.standards
+        })),
+      images: attachments
+        .filter(attachment => attachment.category === 'photo')
+        .map(attachment => ({
+          ...this._toLink(attachment),
+          stage: (attachment.metadata && attachment.metadata.stage) || null,
+          caption: (attachment.metadata && attachment.metadata.caption) || null
+        })),
+      documents: attachments
+        .filter(attachment => attachment.category !== 'photo')
+        .map(attachment => ({ category: attachment.category, ...this._toLink(attachment) })),
+      verification,
+      generatedAt: new Date().toISOString()
+    };
+  }
+
+  /**
+   * Whether the package carries a registered verification code; never exposes the code hash or the counters
+   * @private
+   */
+  async _getVerification(productId) {
+    try {
+      const verification = await productService.getVerificationStatus(trace.role, productId);
+      return {
+        status: verification.lockedUntil ? 'LOCKED' : 'REGISTERED',
+        lockedUntil: verification.lockedUntil || null,
+        registeredAt: verification.registeredAt
+      };
+    } catch (error) {
+      if (error.message.includes(errorCodes.NOT_FOUND)) {
+        return { status: 'UNREGISTERED', lockedUntil: null, registeredAt: null };
+      }
+      throw error;
+    }
+  }
+
+  /**
+   * Brand of the organization that packaged the product; null when it registered none
+   * Products packaged before the packer was recorded fall back to the owner when they were never transferred
+   * @private
+   */
+  async _getBrand(product) {
+    const mspId = product.packerMspId || (!(product.transfers || []).length ? product.ownerMspId : undefined);
+    if (!mspId) {
+      return null;
+    }
+    try {
+      const branding = await brandingService.getBranding(trace.role, mspId);
+      return {
+        name: branding.brandName,
+        logo: branding.logoHash ? { url: branding.logoUri || null, fileHash: branding.logoHash } : null,
+        story: branding.story || null
+      };
+    } catch (error) {
+      if (error.message.includes(errorCodes.NOT_FOUND)) {
+        return null;
+      }
+      throw error;
+    }
+  }
+
+  /**
+   * @private
+   */
+  _toLink(attachment) {
+    return {
+      title: attachment.title,
+      url: attachment.uri || null,
+      mimeType: attachment.mimeType,
+      fileHash: attachment.fileHash
+    };
+  }
+
+  /**
+   * Add the labels of the locale; lockouts that have ended are shown as registered again
+   * @private
+   */
+  _localize(payload, dictionary) {
+    const label = value => translationService.label(dictionary, value);
+
+    const verification = { ...payload.verification };
+    if (verification.status === 'LOCKED' && new Date(verification.lockedUntil) <= new Date()) {
+      verification.status = 'REGISTERED';
+      verification.lockedUntil = null;
+    }
+
+    return {
+      ...payload,
+      language: dictionary.locale,
+      labels: dictionary.fields,
+      statusLabel: label(payload.status),
+      composition: payload.composition && { ...payload.composition, gradeLabel: label(payload.composition.grade) || null },
+      origin: { ...payload.origin, seasonLabel: label(payload.origin.season) },
+      journey: payload.journey.map(event => ({ ...event, stepLabel: label(event.step) })),
+      qualityTests: payload.qualityTests.map(test => ({ ...test, resultLabel: label(test.result) })),
+      images: payload.images.map(image => ({ ...image, stageLabel: label(image.stage) })),
+      verification: { ...verification, statusLabel: label(verification.status) }
+    };
+  }
+}
+
+// Create singleton instance
+const traceService = new TraceService();
+
+module.exports = traceService;
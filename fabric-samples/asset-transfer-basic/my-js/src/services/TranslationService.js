@@ -0,0 +1,263 @@
+const fabricDAO = require('../dao/FabricDAO');
+const cacheService = require('./CacheService');
+const { trace, errorCodes } = require('../../config');
+
+const CATEGORIES = ['field', 'value'];
+
+// Built-in field labels shown by the apps, per language; translations on the ledger take precedence
+const FIELD_LABELS = {
+  zh: {
+    brand: '品牌',
+    productId: '产品编号',
+    batchId: '批次编号',
+    packageDate: '包装日期',
+    status: '状态',
+    origin: '产地',
+    variety: '品种',
+    harvestDate: '收获日期',
+    cropYear: '年份',
+    season: '季节',
+    geographicIndication: '地理标志',
+    journey: '流通过程',
+    qualityTests: '质量检测',
+    certificates: '质量证书',
+    images: '图片',
+    documents: '文件',
+    nutrition: '营养成分（每100克）',
+    composition: '配料',
+    bestBefore: '保质期至',
+    netWeightG: '净含量（克）',
+    grade: '等级',
+    verification: '防伪验证'
+  },
+  en: {
+    brand: 'Brand',
+    productId: 'Product ID',
+    batchId: 'Batch ID',
+    packageDate: 'Package date',
+    status: 'Status',
+    origin: 'Origin',
+    variety: 'Variety',
+    harvestDate: 'Harvest date',
+    cropYear: 'Crop year',
+    season: 'Season',
+    geographicIndication: 'Geographic indication',
+    journey: 'Journey',
+    qualityTests: 'Quality tests',
+    certificates: 'Quality certificates',
+    images: 'Images',
+    documents: 'Documents',
+    nutrition: 'Nutrition facts (per 100 g)',
+    composition: 'Ingredients',
+    bestBefore: 'Best before',
+    netWeightG: 'Net weight (g)',
+    grade: 'Grade',
+    verification: 'Authenticity check'
+  }
+};
+
+// Built-in names of the common values; values without a translation are shown as recorded
+const VALUE_LABELS = {
+  zh: {
+    Harvested: '收获',
+    Transporting: '运输',
+    QualityInspection: '质量检验',
+    Processing: '加工',
+    Packaged: '包装',
+    Stored: '仓储',
+    Field: '田间',
+    Drying: '晾晒',
+    Milling: '碾米',
+    Active: '在售',
+    Sold: '已售',
+    Returned: '已退货',
+    Disposed: '已销毁',
+    Expired: '已过期',
+    Early: '早稻',
+    Middle: '中稻',
+    Late: '晚稻',
+    Passed: '合格',
+    Failed: '不合格',
+    REGISTERED: '已登记防伪码',
+    LOCKED: '验证已暂时锁定',
+    UNREGISTERED: '未登记防伪码'
+  },
+  en: {
+    QualityInspection: 'Quality inspection',
+    Active: 'On sale',
+    REGISTERED: 'Verification code registered',
+    LOCKED: 'Verification temporarily locked',
+    UNREGISTERED: 'No verification code'
+  }
+};
+
+/**
+ * Translation service layer
+ * Provides the Chinese and English labels of payload fields and recorded values (processing steps, statuses,
+ * grades) that make outputs consumer-ready. Translations are managed on the ledger and take precedence over the
+ * built-in labels, so the network can name new steps and grades without a gateway release
+ */
+class TranslationService {
+
+  /**
+   * Pick the locale of a response: an explicit locale first, then the Accept-Language header, then the default
+   * @param {string} [locale] - Requested locale
+   * @param {string} [acceptLanguage] - Accept-Language header
+   * @returns {string} Locale
+   */
+  negotiateLocale(locale, acceptLanguage = '') {
+    if (locale) {
+      return String(locale).toLowerCase();
+    }
+
+    const preferences = acceptLanguage.split(',')
+      .map((entry, index) => {
+        const [tag, ...params] = entry.trim().split(';');
+        const q = params.map(param => param.trim()).find(param => param.startsWith('q='));
+        return { language: tag.split('-')[0].toLowerCase(), q: q ? Number(q.slice(2)) : 1, index };
+      })
+      .filter(preference => preference.language && preference.q > 0)
+      .sort((a, b) => b.q - a.q || a.index - b.index);
+
+    const match = preferences.find(preference => trace.languages.includes(preference.language));
+    return match ? match.language : trace.defaultLanguage;
+  }
+
+  /**
+   * Get the labels of a locale: the built-in labels overridden by the translations on the ledger
+   * The ledger dictionary is cached; while it cannot be read the built-in labels are used alone
+   * @param {string} locale - Locale (zh or en)
+   * @returns {Promise<Object>} { locale, fields, values }
+   */
+  async getDictionary(locale) {
+    if (!trace.languages.includes(locale)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: locale must be one of ${trace.languages.join(', ')}`);
+    }
+
+    let translations = await cacheService.getLabelDictionary(locale);
+    if (!translations) {
+      try {
+        translations = await fabricDAO.evaluateTransaction(trace.role, 'LabelDictionaryContract:GetLabelDictionary', locale);
+        await cacheService.setLabelDictionary(locale, translations);
+      } catch (error) {
+        console.error(`Failed to read the ${locale} label dictionary: ${error.message}`);
+        translations = { fields: {}, values: {} };
+      }
+    }
+
+    return {
+      locale,
+      fields: { ...FIELD_LABELS[locale], ...translations.fields },
+      values: { ...VALUE_LABELS[locale], ...translations.values }
+    };
+  }
+
+  /**
+   * Label of a recorded value; values without a translation are returned as recorded
+   * @param {Object} dictionary - Dictionary of getDictionary
+   * @param {string} value - Recorded value
+   * @returns {string} Label
+   */
+  label(dictionary, value) {
+    return (value && dictionary.values[value]) || value;
+  }
+
+  /**
+   * Add the labels of a locale to a batch: its state, season and the steps of its history
+   * @param {Object} batch - Batch
+   * @param {Object} dictionary - Dictionary of getDictionary
+   * @returns {Object} Localized batch
+   */
+  localizeBatch(batch, dictionary) {
+    return {
+      ...batch,
+      stateLabel: this.label(dictionary, batch.currentState),
+      seasonLabel: this.label(dictionary, batch.season) || null,
+      history: (batch.history || []).map(event => ({ ...event, stepLabel: this.label(dictionary, event.step) }))
+    };
+  }
+
+  /**
+   * Add the labels of a locale to a product: its status and grade
+   * @param {Object} product - Product
+   * @param {Object} dictionary - Dictionary of getDictionary
+   * @returns {Object} Localized product
+   */
+  localizeProduct(product, dictionary) {
+    const grade = product.composition ? product.composition.grade : undefined;
+    return {
+      ...product,
+      statusLabel: this.label(dictionary, product.status || 'Active'),
+      gradeLabel: this.label(dictionary, grade) || null
+    };
+  }
+
+  /**
+   * Set the labels of a field name or recorded value on the ledger
+   * @param {string} role - Caller role; its identity must be an organization administrator
+   * @param {string} category - field or value
+   * @param {string} code - Field name or value as recorded
+   * @param {Object} labels - { zh?, en? }
+   * @returns {Promise<Object>} Stored translation
+   */
+  async setTranslation(role, category, code, labels) {
+    if (!CATEGORIES.includes(category)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: category must be one of ${CATEGORIES.join(', ')}`);
+    }
+
+    try {
+      const result = await fabricDAO.submitTransaction(role, 'LabelDictionaryContract:SetTranslation', category, code, JSON.stringify(labels));
+      await cacheService.invalidateLabelDictionaries();
+      return JSON.parse(new TextDecoder().decode(result));
+    } catch (error) {
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (/locale|label|Labels|Code is required/.test(error.message)) {
+        throw new Error(`${errorCodes.VALIDATION_ERROR}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      throw new Error(`Failed to set translation: ${error.message}`);
+    }
+  }
+
+  /**
+   * Remove the labels of a field name or recorded value from the ledger
+   * @param {string} role - Caller role; its identity must be an organization administrator
+   * @param {string} category - field or value
+   * @param {string} code - Field name or value as recorded
+   */
+  async removeTranslation(role, category, code) {
+    try {
+      await fabricDAO.submitTransaction(role, 'LabelDictionaryContract:RemoveTranslation', category, code);
+      await cacheService.invalidateLabelDictionaries();
+    } catch (error) {
+      if (error.message.includes('Permission denied')) {
+        throw new Error(`${errorCodes.PERMISSION_DENIED}: ${error.message.replace(`${errorCodes.FABRIC_ERROR}: `, '')}`);
+      }
+      if (error.message.includes('No translation')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: No translation of ${category} ${code} exists`);
+      }
+      throw new Error(`Failed to remove translation: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the translations on the ledger
+   * @param {string} role - Caller role
+   * @param {string} [category] - Only translations of this category
+   * @returns {Promise<Array>} Translations
+   */
+  async getTranslations(role, category = '') {
+    if (category && !CATEGORIES.includes(category)) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: category must be one of ${CATEGORIES.join(', ')}`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'LabelDictionaryContract:GetTranslations', category);
+    } catch (error) {
+      throw new Error(`Failed to get translations: ${error.message}`);
+    }
+  }
+}
+
+module.exports = new TranslationService();
@@ -0,0 +1,108 @@
+const crypto = require('node:crypto');
+const fabricDAO = require('../dao/FabricDAO');
+const { errorCodes } = require('../../config');
+
+/**
+ * Weather service layer
+ * Anchors weather observations of plots by the hash of their raw feed data and checks feeds against them
+ */
+class WeatherService {
+
+  /**
+   * Anchor a weather observation
+   * The raw feed data is hashed here when given as data; otherwise its SHA-256 is expected as dataHash
+   * @param {string} role - Caller role
+   * @param {Object} observation - { plotId, periodStart, periodEnd, source, summary, data | dataHash }
+   * @returns {Promise<Object>} { dataHash }
+   */
+  async anchorWeatherData(role, observation) {
+    const { plotId, periodStart, periodEnd, source, summary, data } = observation;
+    if (!plotId || !periodStart || !periodEnd || !source || !summary) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: plotId, periodStart, periodEnd, source and summary are required`);
+    }
+    if (data === undefined && !observation.dataHash) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: Either the raw feed data or its dataHash is required`);
+    }
+    const dataHash = data !== undefined ? this._hash(data) : String(observation.dataHash).toLowerCase();
+
+    try {
+      await fabricDAO.submitTransaction(role, 'WeatherDataContract:AnchorWeatherData', plotId, `${periodStart}/${periodEnd}`, source, dataHash, summary);
+      return { dataHash };
+    } catch (error) {
+      throw new Error(`Failed to anchor weather data: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get a weather observation by its data hash
+   * @param {string} role - Caller role
+   * @param {string} dataHash - SHA-256 of the feed data
+   * @returns {Promise<Object>} Weather observation
+   */
+  async getObservation(role, dataHash) {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'WeatherDataContract:ReadWeatherObservation', dataHash);
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Weather observation ${dataHash} does not exist`);
+      }
+      throw new Error(`Failed to get weather observation: ${error.message}`);
+    }
+  }
+
+  /**
+   * Get the weather observations of a plot overlapping a time range
+   * @param {string} role - Caller role
+   * @param {string} plotId - Plot ID
+   * @param {string} [from] - Start date or time
+   * @param {string} [to] - End date (inclusive) or time
+   * @returns {Promise<Array>} Weather observations by period start
+   */
+  async getPlotObservations(role, plotId, from = '', to = '') {
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'WeatherDataContract:GetWeatherObservations', plotId, from, to);
+    } catch (error) {
+      throw new Error(`Failed to get weather observations: ${error.message}`);
+    }
+  }
+
+  /**
+   * Check raw feed data against an anchored observation
+   * @param {string} role - Caller role
+   * @param {string} dataHash - SHA-256 of the anchored feed data
+   * @param {*} data - Raw feed data, hashed as anchored (strings as is, other values as JSON)
+   * @returns {Promise<boolean>} Whether the data is the anchored data
+   */
+  async verifyWeatherData(role, dataHash, data) {
+    if (data === undefined) {
+      throw new Error(`${errorCodes.VALIDATION_ERROR}: The raw feed data is required`);
+    }
+
+    try {
+      return await fabricDAO.evaluateTransaction(role, 'WeatherDataContract:VerifyWeatherData', dataHash, this._serialize(data));
+    } catch (error) {
+      if (error.message.includes('does not exist')) {
+        throw new Error(`${errorCodes.NOT_FOUND}: Weather observation ${dataHash} does not exist`);
+      }
+      throw new Error(`Failed to verify weather data: ${error.message}`);
+    }
+  }
+
+  /**
+   * Feed data as hashed: strings as is, other values as JSON
+   * @private
+   */
+  _serialize(data) {
+    return typeof data === 'string' ? data : JSON.stringify(data);
+  }
+
+  /**
+   * SHA-256 (hex) of feed data
+   * @private
+   */
+  _hash(data) {
+    return crypto.createHash('sha256').update(this._serialize(data), 'utf8').digest('hex');
+  }
+}
+
+module.exports = new WeatherService();
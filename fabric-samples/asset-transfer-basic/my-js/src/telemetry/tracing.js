@@ -0,0 +1,54 @@
+/**
+ * Distributed tracing helpers
+ * Uses the OpenTelemetry API when it is installed; spans are exported by whichever SDK is registered
+ * (e.g. node -r @opentelemetry/auto-instrumentations-node/register server.js).
+ * Without the API package, tracing is a no-op.
+ */
+
+let otel = null;
+try {
+  otel = require('@opentelemetry/api');
+} catch {
+  otel = null;
+}
+
+const TRACER_NAME = 'ricetrace-gateway';
+
+/**
+ * Run a function inside an active span
+ * The span records exceptions and is marked as failed when the function throws
+ * @param {string} name - Span name
+ * @param {Object} attributes - Span attributes
+ * @param {Function} fn - async (span) => result; span is null when tracing is disabled
+ * @returns {Promise<any>} Function result
+ */
+async function withSpan(name, attributes, fn) {
+  if (!otel) {
+    return fn(null);
+  }
+
+  const tracer = otel.trace.getTracer(TRACER_NAME);
+  return tracer.startActiveSpan(name, { attributes }, async (span) => {
+    try {
+      return await fn(span);
+    } catch (error) {
+      span.recordException(error);
+      span.setStatus({ code: otel.SpanStatusCode.ERROR, message: error.message });
+      throw error;
+    } finally {
+      span.end();
+    }
+  });
+}
+
+/**
+ * Whether the OpenTelemetry API is available
+ */
+function isTracingAvailable() {
+  return otel !== null;
+}
+
+module.exports = {
+  withSpan,
+  isTracingAvailable
+};
@@ -0,0 +1,133 @@
+const fs = require('node:fs');
+const path = require('node:path');
+const yaml = require('js-yaml');
+
+/**
+ * Private data collection config generator
+ * Expands a declarative YAML of collections, organization pairs and TTLs (my-ts/collections.yaml) into the
+ * collections_config.json passed to chaincode deployment. Collection names follow the chaincode's convention
+ * <name>_<MspA>_<MspB> with the MSP IDs sorted, so both sides always agree.
+ *
+ * Usage: node tools/collections-gen.js [collections.yaml] [collections_config.json] [--check]
+ *   --check  Do not write; exit with an error if the output file is out of date
+ */
+
+const DEFAULT_INPUT = path.resolve(__dirname, '..', '..', 'my-ts', 'collections.yaml');
+const DEFAULT_OUTPUT = path.resolve(__dirname, '..', '..', 'my-ts', 'collections_config.json');
+
+const NAME_PATTERN = /^[A-Za-z0-9]+[A-Za-z0-9-]*$/;
+const MSP_PATTERN = /^[A-Za-z0-9.-]+$/;
+
+/**
+ * Name of the collection shared by a pair of organizations
+ */
+function pairCollectionName(name, mspA, mspB) {
+  return [name, ...[mspA, mspB].sort()].join('_');
+}
+
+function requireNonNegativeInteger(value, field) {
+  if (!Number.isInteger(value) || value < 0) {
+    throw new Error(`${field} must be a non-negative integer, got ${JSON.stringify(value)}`);
+  }
+  return value;
+}
+
+/**
+ * Expand the declarative definition into Fabric collection definitions
+ * @param {Object} definition - Parsed YAML
+ * @returns {Array<Object>} Collection definitions, sorted by name
+ */
+function generateCollections(definition) {
+  const defaults = {
+    requiredPeerCount: 0,
+    maxPeerCount: 1,
+    memberOnlyRead: true,
+    memberOnlyWrite: true,
+    ...(definition.defaults || {})
+  };
+  if (!Array.isArray(definition.collections) || definition.collections.length === 0) {
+    throw new Error('At least one collection must be defined under "collections"');
+  }
+
+  const collections = new Map();
+  for (const entry of definition.collections) {
+    if (!entry.name || !NAME_PATTERN.test(entry.name)) {
+      throw new Error(`Invalid collection name ${JSON.stringify(entry.name)}: use letters, digits and hyphens`);
+    }
+    if (!Array.isArray(entry.pairs) || entry.pairs.length === 0) {
+      throw new Error(`Collection ${entry.name} must list at least one organization pair`);
+    }
+
+    const settings = { ...defaults, ...entry };
+    const blockToLive = requireNonNegativeInteger(settings.blockToLive ?? 0, `${entry.name}.blockToLive`);
+    const requiredPeerCount = requireNonNegativeInteger(settings.requiredPeerCount, `${entry.name}.requiredPeerCount`);
+    const maxPeerCount = requireNonNegativeInteger(settings.maxPeerCount, `${entry.name}.maxPeerCount`);
+    if (maxPeerCount < requiredPeerCount) {
+      throw new Error(`${entry.name}: maxPeerCount cannot be lower than requiredPeerCount`);
+    }
+
+    for (const pair of entry.pairs) {
+      if (!Array.isArray(pair) || pair.length !== 2 || pair[0] === pair[1] || !pair.every(msp => MSP_PATTERN.test(msp || ''))) {
+        throw new Error(`${entry.name}: each pair must name two different MSP IDs, got ${JSON.stringify(pair)}`);
+      }
+
+      const [mspA, mspB] = [...pair].sort();
+      const name = pairCollectionName(entry.name, mspA, mspB);
+      if (collections.has(name)) {
+        throw new Error(`Collection ${name} is defined more than once`);
+      }
+
+      collections.set(name, {
+        name,
+        policy: `OR('${mspA}.member','${mspB}.member')`,
+        requiredPeerCount,
+        maxPeerCount,
+        blockToLive,
+        memberOnlyRead: settings.memberOnlyRead === true,
+        memberOnlyWrite: settings.memberOnlyWrite === true,
+        // Only the two organizations may endorse writes to their private data
+        endorsementPolicy: {
+          signaturePolicy: `OR('${mspA}.peer','${mspB}.peer')`
+        }
+      });
+    }
+  }
+
+  return [...collections.values()].sort((a, b) => a.name.localeCompare(b.name));
+}
+
+function run() {
+  const args = process.argv.slice(2);
+  const check = args.includes('--check');
+  const [inputPath = DEFAULT_INPUT, outputPath = DEFAULT_OUTPUT] = args.filter(arg => !arg.startsWith('--'));
+
+  const definition = yaml.load(fs.readFileSync(inputPath, 'utf8')) || {};
+  const output = `${JSON.stringify(generateCollections(definition), null, 2)}\n`;
+
+  if (check) {
+    const current = fs.existsSync(outputPath) ? fs.readFileSync(outputPath, 'utf8') : '';
+    if (current !== output) {
+      console.error(`${outputPath} is out of date; run: npm run collections`);
+      process.exit(1);
+    }
+    console.log(`${outputPath} is up to date`);
+    return;
+  }
+
+  fs.writeFileSync(outputPath, output);
+  console.log(`Wrote ${JSON.parse(output).length} collections to ${outputPath}`);
+}
+
+if (require.main === module) {
+  try {
+    run();
+  } catch (error) {
+    console.error('Collection config generation failed:', error.message);
+    process.exit(1);
+  }
+}
+
+module.exports = {
+  generateCollections,
+  pairCollectionName
+};
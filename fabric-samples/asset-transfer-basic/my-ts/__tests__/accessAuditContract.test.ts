@@ -0,0 +1,55 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { AccessAuditContract } from '../src/accessAuditContract';
+import { createMockContext, TEST_TIMESTAMP_SECONDS } from '../testing';
+
+describe('AccessAuditContract', () => {
+    let contract: AccessAuditContract;
+
+    beforeEach(() => {
+        contract = new AccessAuditContract();
+    });
+
+    const access = (resourceType: string, resourceId: string) => ({
+        access: JSON.stringify({ resourceType, resourceId, purpose: 'Price review', accessedBy: 'processor' })
+    });
+
+    test('should record reads in the collection shared with the regulator only', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', transient: access('commercialTerms', 'batch123') });
+
+        const entry = await contract.RecordAccess(ctx);
+
+        expect(entry).toEqual(expect.objectContaining({
+            resourceType: 'commercialTerms', resourceId: 'batch123', purpose: 'Price review', accessorMspId: 'Org2MSP', txId: 'tx1'
+        }));
+        expect(ctx.stub.putPrivateData).toHaveBeenCalledWith(
+            'accessAudit_Org2MSP_Org3MSP', `access_${entry.accessedAt}_tx1`, expect.anything()
+        );
+        expect(ctx.stub.putState).not.toHaveBeenCalled();
+        expect(ctx.stub.events).toEqual([]);
+    });
+
+    test('should let the regulator read an organization\'s access log in time order', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', transient: access('testReport', 'report-2') });
+        await contract.RecordAccess(ctx);
+        ctx.stub.nextTransaction();
+        ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS + 86400);
+        await contract.RecordAccess(ctx);
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        const all = await contract.GetAccessLog(ctx, 'Org2MSP', '', '');
+        expect(all.map(entry => entry.txId)).toEqual(['tx1', 'tx2']);
+
+        const firstDay = await contract.GetAccessLog(ctx, 'Org2MSP', '', '2024-09-22');
+        expect(firstDay.map(entry => entry.txId)).toEqual(['tx1']);
+    });
+
+    test('should reject log reads by other organizations and unknown resource types', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', transient: access('batch', 'batch123') });
+
+        await expect(contract.RecordAccess(ctx)).rejects.toThrow('Unknown resource type batch');
+        await expect(contract.GetAccessLog(ctx, 'Org2MSP', '', '')).rejects.toThrow('Permission denied');
+    });
+});
@@ -0,0 +1,63 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { AgroInputContract } from '../src/agroInputContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext, MockContext } from '../testing';
+
+describe('AgroInputContract', () => {
+    let contract: AgroInputContract;
+    let tracer: RiceTracerContract;
+
+    beforeEach(() => {
+        contract = new AgroInputContract();
+        tracer = new RiceTracerContract();
+    });
+
+    const report = (inputs: object[]) => JSON.stringify({
+        reportId: 'r1', reportType: 'FieldApplication', reportHash: '', summary: 'Sprayed', isVerified: false, inputs
+    });
+
+    const putBatch = (ctx: MockContext, batchId: string) => {
+        ctx.stub.putJSON(`batch_${batchId}`, {
+            docType: 'riceBatch', batchId, currentOwner: 'Farmer Zhang', currentState: 'Harvested',
+            history: [{ timestamp: '2024-09-01T00:00:00.000Z', from: '', to: 'Farmer Zhang', step: 'Harvested', signerMspId: 'Org1MSP' }]
+        });
+    };
+
+    test('should find the batches and products reached by a chemical or input lot', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        putBatch(ctx, 'batch1');
+        putBatch(ctx, 'batch2');
+        ctx.stub.putJSON('product_P1', { docType: 'product', productId: 'P1', batchId: 'batch1', owner: 'Shop B', status: 'Active' });
+        ctx.stub.state.set(ctx.stub.createCompositeKey('productBatch~productId', ['batch1', 'P1']), Buffer.from([0x00]));
+
+        await tracer.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Sprayed',
+            report([{ chemicalName: ' Chlorpyrifos ', inputLotId: 'LOT-7', appliedAt: '2024-07-15' }]), '');
+        ctx.stub.nextTransaction();
+        await tracer.CompleteStepAndTransfer(ctx, 'batch2', 'Farmer Zhang', 'Farmer Zhang', 'Sprayed',
+            report([{ chemicalName: 'chlorpyrifos', inputLotId: 'LOT-8' }]), '');
+
+        const byLot = await contract.FindBatchesByInputProduct(ctx, 'LOT-7');
+        expect(byLot.batches).toEqual([{
+            batchId: 'batch1', owner: 'Farmer Zhang', state: 'Sprayed',
+            applications: [{ step: 'Sprayed', chemicalName: 'Chlorpyrifos', inputLotId: 'LOT-7', appliedAt: '2024-07-15T00:00:00.000Z' }]
+        }]);
+        expect(byLot.products).toEqual([{ productId: 'P1', batchId: 'batch1', owner: 'Shop B', status: 'Active' }]);
+
+        const byChemical = await contract.FindBatchesByInputProduct(ctx, 'CHLORPYRIFOS');
+        expect(byChemical.batches.map(batch => batch.batchId)).toEqual(['batch1', 'batch2']);
+        expect(byChemical.batches[0].applications).toHaveLength(1);
+        await expect(contract.FindBatchesByInputProduct(ctx, 'Glyphosate')).resolves.toEqual({ query: 'Glyphosate', batches: [], products: [] });
+    });
+
+    test('should reject applications without a chemical', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        putBatch(ctx, 'batch1');
+
+        await expect(tracer.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Sprayed', report([{ inputLotId: 'LOT-7' }]), ''))
+            .rejects.toThrow('must name the applied chemical');
+        await expect(contract.FindBatchesByInputProduct(ctx, ' ')).rejects.toThrow('is required');
+    });
+});
@@ -0,0 +1,137 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { AttachmentContract } from '../src/attachmentContract';
+import { createMockContext } from '../testing';
+
+describe('AttachmentContract', () => {
+    let contract: AttachmentContract;
+
+    beforeEach(() => {
+        contract = new AttachmentContract();
+    });
+
+    const HASH = 'ab'.repeat(32);
+
+    const setupLedger = () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        ctx.stub.putJSON('batch_B1', { docType: 'riceBatch', batchId: 'B1' });
+        ctx.stub.putJSON('product_P1', { docType: 'product', productId: 'P1', batchId: 'B1' });
+        ctx.stub.putJSON('test_T1', { docType: 'testResult', testId: 'T1', batchId: 'B1' });
+        ctx.stub.putJSON('test_T2', { docType: 'testResult', testId: 'T2', batchId: 'B2' });
+        return ctx;
+    };
+
+    test('should attach typed documents and list them per entity and category', async () => {
+        const ctx = setupLedger();
+
+        const report = await contract.AddAttachment(ctx, 'B1', JSON.stringify({
+            category: 'labReport', title: 'Heavy metals', fileHash: HASH.toUpperCase(), mimeType: 'application/pdf',
+            metadata: { laboratory: 'SGS Harbin', reportDate: '2024-09-18', testId: 'T1' }
+        }));
+        expect(report).toEqual(expect.objectContaining({
+            attachmentId: 'tx1', entityType: 'batch', fileHash: HASH, addedBy: 'Org2MSP',
+            metadata: { laboratory: 'SGS Harbin', reportDate: '2024-09-18T00:00:00.000Z', testId: 'T1' }
+        }));
+        expect(ctx.stub.events[0].name).toBe('AttachmentAdded');
+
+        ctx.stub.nextTransaction();
+        ctx.stub.setTxTimestamp(1727000100);
+        await contract.AddAttachment(ctx, 'B1', JSON.stringify({
+            category: 'photo', title: 'Drying floor', fileHash: HASH, mimeType: 'image/jpeg', metadata: { takenAt: '2024-09-15T08:00:00Z' }
+        }));
+        ctx.stub.nextTransaction();
+        await contract.AddAttachment(ctx, 'P1', JSON.stringify({
+            category: 'customsDoc', title: 'Export declaration', fileHash: HASH, mimeType: 'application/pdf',
+            metadata: { declarationNumber: '2301-2024-0098', country: 'cn' }
+        }));
+
+        const batchDocuments = await contract.ListAttachments(ctx, 'B1', '');
+        expect(batchDocuments.map(attachment => attachment.title)).toEqual(['Heavy metals', 'Drying floor']);
+        await expect(contract.ListAttachments(ctx, 'B1', 'photo')).resolves.toHaveLength(1);
+
+        const [declaration] = await contract.ListAttachments(ctx, 'P1', '');
+        expect(declaration).toEqual(expect.objectContaining({ entityType: 'product', metadata: { declarationNumber: '2301-2024-0098', country: 'CN' } }));
+        await expect(contract.ReadAttachment(ctx, declaration.attachmentId)).resolves.toEqual(declaration);
+    });
+
+    test('should validate file type and metadata per category', async () => {
+        const ctx = setupLedger();
+        const add = (attachment: object) => contract.AddAttachment(ctx, 'B1', JSON.stringify({ title: 'Document', fileHash: HASH, ...attachment }));
+
+        await expect(add({ category: 'invoice', mimeType: 'application/pdf' })).rejects.toThrow('Invalid attachment category');
+        await expect(add({ category: 'photo', mimeType: 'application/pdf' })).rejects.toThrow('must be an image');
+        await expect(add({ category: 'contract', mimeType: 'application/pdf', metadata: { counterparty: 'Harbin Mills' } }))
+            .rejects.toThrow('requires metadata: signedDate');
+        await expect(add({ category: 'photo', mimeType: 'image/png', metadata: { issuer: 'CQC' } })).rejects.toThrow('not used by photo attachments: issuer');
+        await expect(add({ category: 'certificate', mimeType: 'image/png', metadata: { certificateNumber: 'C-1', issuer: 'CQC', validUntil: 'soon' } }))
+            .rejects.toThrow('validUntil');
+        await expect(add({ category: 'customsDoc', mimeType: 'application/pdf', metadata: { declarationNumber: 'D-1', country: 'China' } }))
+            .rejects.toThrow('alpha-2');
+        await expect(add({ category: 'labReport', mimeType: 'application/pdf', metadata: { laboratory: 'SGS', reportDate: '2024-09-18', testId: 'T2' } }))
+            .rejects.toThrow('concerns batch B2, not B1');
+        await expect(add({ category: 'photo', mimeType: 'image/png', fileHash: 'abc' })).rejects.toThrow('SHA-256');
+    });
+
+    test('should record the IPFS CID of a stored file', async () => {
+        const ctx = setupLedger();
+        const cid = 'bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku';
+
+        const photo = await contract.AddAttachment(ctx, 'B1', JSON.stringify({
+            category: 'photo', title: 'Drying floor', fileHash: HASH, mimeType: 'image/jpeg', cid
+        }));
+        expect(photo).toEqual(expect.objectContaining({ cid, uri: `ipfs://${cid}` }));
+
+        ctx.stub.nextTransaction();
+        await expect(contract.AddAttachment(ctx, 'B1', JSON.stringify({
+            category: 'photo', title: 'Drying floor', fileHash: HASH, mimeType: 'image/jpeg', cid: 'not-a-cid'
+        }))).rejects.toThrow('Invalid IPFS CID');
+    });
+
+    test('should keep a photo journal of a batch tagged by stage', async () => {
+        const ctx = setupLedger();
+        const cid = 'bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku';
+
+        const field = await contract.AddBatchPhoto(ctx, 'B1', 'Field', ' Paddies before harvest ', HASH, 'https://photos.example.com/b1/field.JPG?v=2');
+        expect(field).toEqual(expect.objectContaining({
+            entityId: 'B1', category: 'photo', title: 'Paddies before harvest', mimeType: 'image/jpeg',
+            metadata: { stage: 'Field', caption: 'Paddies before harvest' }
+        }));
+        ctx.stub.nextTransaction();
+        ctx.stub.setTxTimestamp(1727000100);
+        const mill = await contract.AddBatchPhoto(ctx, 'B1', 'Milling', '', HASH, `ipfs://${cid}`);
+        expect(mill).toEqual(expect.objectContaining({ title: 'Milling', mimeType: 'image/*', cid, metadata: { stage: 'Milling' } }));
+        ctx.stub.nextTransaction();
+        await contract.AddAttachment(ctx, 'B1', JSON.stringify({ category: 'labReport', title: 'Heavy metals', fileHash: HASH, mimeType: 'application/pdf',
+            metadata: { laboratory: 'SGS Harbin', reportDate: '2024-09-18' } }));
+
+        const journal = await contract.GetBatchPhotos(ctx, 'B1');
+        expect(journal.map(photo => photo.metadata.stage)).toEqual(['Field', 'Milling']);
+        await expect(contract.GetBatchPhotos(ctx, 'B9')).rejects.toThrow('Batch B9 does not exist');
+    });
+
+    test('should validate journal photos', async () => {
+        const ctx = setupLedger();
+
+        await expect(contract.AddBatchPhoto(ctx, 'P1', 'Field', '', HASH, 'https://photos.example.com/p1.png')).rejects.toThrow('Batch P1 does not exist');
+        await expect(contract.AddBatchPhoto(ctx, 'B1', 'Harvest', '', HASH, 'https://photos.example.com/b1.png')).rejects.toThrow('Invalid photo stage: Harvest');
+        await expect(contract.AddBatchPhoto(ctx, 'B1', 'Field', 'x'.repeat(501), HASH, 'https://photos.example.com/b1.png')).rejects.toThrow('at most 500');
+        await expect(contract.AddBatchPhoto(ctx, 'B1', 'Field', '', HASH, '')).rejects.toThrow('needs the uri');
+        await expect(contract.AddBatchPhoto(ctx, 'B1', 'Field', '', HASH, 'https://photos.example.com/b1.pdf')).rejects.toThrow('must be an image');
+        await expect(contract.AddBatchPhoto(ctx, 'B1', 'Field', '', 'abc', 'https://photos.example.com/b1.png')).rejects.toThrow('SHA-256');
+        await expect(contract.AddAttachment(ctx, 'B1', JSON.stringify({ category: 'photo', title: 'Silo', fileHash: HASH, mimeType: 'image/png', metadata: { stage: 'Silo' } })))
+            .rejects.toThrow('Invalid photo stage: Silo');
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        await expect(contract.AddBatchPhoto(ctx, 'B1', 'Field', '', HASH, 'https://photos.example.com/b1.png')).rejects.toThrow('Permission denied');
+    });
+
+    test('should reject consumers and unknown entities', async () => {
+        const ctx = setupLedger();
+        const photo = JSON.stringify({ category: 'photo', title: 'Field', fileHash: HASH, mimeType: 'image/png' });
+
+        await expect(contract.AddAttachment(ctx, 'B9', photo)).rejects.toThrow('No batch or product with ID B9 exists');
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        await expect(contract.AddAttachment(ctx, 'B1', photo)).rejects.toThrow('Permission denied');
+    });
+});
@@ -0,0 +1,108 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { BatchReservationContract } from '../src/batchReservationContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext, MockContext } from '../testing';
+
+describe('BatchReservationContract', () => {
+    let contract: BatchReservationContract;
+    let tracer: RiceTracerContract;
+
+    beforeEach(() => {
+        contract = new BatchReservationContract();
+        tracer = new RiceTracerContract();
+    });
+
+    const report = JSON.stringify({ reportId: 'r1', reportType: 'ProcessingRecord', reportHash: '', summary: 'Sold', isVerified: false });
+
+    const registerBatch = async (ctx: MockContext) => {
+        ctx.stub.putJSON('batch_batch1', {
+            docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farmer Zhang', currentState: 'Drying',
+            history: [{ timestamp: '2024-09-01T00:00:00.000Z', from: '', to: 'Farmer Zhang', step: 'Created', signerMspId: 'Org1MSP' }]
+        });
+        await contract.SetBatchQuantity(ctx, 'batch1', '1000');
+    };
+
+    test('should not reserve the same quantity twice', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        await registerBatch(ctx);
+
+        const reservation = await contract.ReserveBatchQuantity(ctx, 'batch1', 'Mill A', '600', '2024-10-01');
+        expect(reservation).toEqual(expect.objectContaining({ buyer: 'Mill A', quantityKg: 600, status: 'Active', expiresAt: '2024-10-01T23:59:59.999Z' }));
+        expect(ctx.stub.events[0].name).toBe('BatchQuantityReserved');
+
+        ctx.stub.nextTransaction();
+        await expect(contract.ReserveBatchQuantity(ctx, 'batch1', 'Mill B', '500', '2024-10-01')).rejects.toThrow('only 400 kg of 1000 kg are available');
+        await expect(contract.SetBatchQuantity(ctx, 'batch1', '500')).rejects.toThrow('has 600 kg reserved');
+        await expect(contract.ReserveBatchQuantity(ctx, 'batch1', 'Mill B', '100', '2024-09-01')).rejects.toThrow('must be in the future');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await expect(contract.ReserveBatchQuantity(ctx, 'batch1', 'Mill B', '100', '2024-10-01')).rejects.toThrow('Permission denied');
+        await expect(contract.ReleaseReservation(ctx, 'batch1', reservation.reservationId)).rejects.toThrow('was made by Org1MSP');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await contract.ReleaseReservation(ctx, 'batch1', reservation.reservationId);
+        expect(ctx.stub.events[0].name).toBe('BatchReservationReleased');
+        await expect(contract.GetBatchReservations(ctx, 'batch1')).resolves.toEqual(expect.objectContaining({
+            quantityKg: 1000, reservedKg: 0, availableKg: 1000
+        }));
+    });
+
+    test('should let reservations expire', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        await registerBatch(ctx);
+        await contract.ReserveBatchQuantity(ctx, 'batch1', 'Mill A', '1000', '2024-09-23');
+
+        ctx.stub.setTxTimestamp(Date.parse('2024-09-24T00:00:00Z') / 1000);
+        const summary = await contract.GetBatchReservations(ctx, 'batch1');
+        expect(summary).toEqual(expect.objectContaining({ reservedKg: 0, availableKg: 1000 }));
+        expect(summary.reservations[0].status).toBe('Expired');
+
+        ctx.stub.nextTransaction();
+        await expect(contract.ReserveBatchQuantity(ctx, 'batch1', 'Mill B', '1000', '2024-10-01')).resolves.toBeDefined();
+    });
+
+    test('should consume the reservations on the transfer to the buyer and block transfers to others', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        await registerBatch(ctx);
+        await contract.ReserveBatchQuantity(ctx, 'batch1', 'Mill A', '800', '2024-10-01');
+
+        ctx.stub.nextTransaction();
+        const check = await tracer.CanTransferRiceBatch(ctx, 'batch1', 'Mill B', 'Sold', report);
+        expect(check.blockers).toEqual([{ rule: 'reservations', message: expect.stringContaining('800 kg reserved for Mill A') }]);
+        await expect(tracer.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Mill B', 'Sold', report, ''))
+            .rejects.toThrow('release the reservations before transferring it to Mill B');
+
+        // Processing steps without a handover are not affected
+        await tracer.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Milled', report, '');
+        ctx.stub.nextTransaction();
+        await tracer.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Mill A', 'Sold', report, '');
+        expect(ctx.stub.getJSON('batch_batch1').reservations[0]).toEqual(expect.objectContaining({
+            status: 'Consumed', closedAt: '2024-09-22T10:13:20.000Z'
+        }));
+    });
+
+    test('should lower the quantity by plausible losses of recorded steps only', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        await registerBatch(ctx);
+
+        const adjustment = await contract.RecordWeightAdjustment(ctx, 'batch1', 'Drying', '1000', '820', 'Dried from 28% to 14% moisture');
+        expect(adjustment).toEqual(expect.objectContaining({ step: 'Drying', beforeKg: 1000, afterKg: 820, lossPercent: 18, recordedByMspId: 'Org1MSP' }));
+        expect(ctx.stub.getJSON('batch_batch1')).toEqual(expect.objectContaining({ quantityKg: 820, weightAdjustments: [adjustment] }));
+        expect(ctx.stub.events[0].name).toBe('BatchWeightAdjusted');
+
+        ctx.stub.nextTransaction();
+        await expect(contract.SetBatchQuantity(ctx, 'batch1', '700')).rejects.toThrow('RecordWeightAdjustment');
+        await expect(contract.RecordWeightAdjustment(ctx, 'batch1', 'Drying', '1000', '900', 'Dried')).rejects.toThrow('does not match the declared quantity');
+        await expect(contract.RecordWeightAdjustment(ctx, 'batch1', 'Drying', '820', '500', 'Dried')).rejects.toThrow('implausible for Drying');
+        await expect(contract.RecordWeightAdjustment(ctx, 'batch1', 'Created', '820', '800', 'Spilled')).rejects.toThrow('at most 1% is expected');
+        await expect(contract.RecordWeightAdjustment(ctx, 'batch1', 'Milling', '820', '600', 'Milled')).rejects.toThrow('not recorded in the history');
+        await expect(contract.RecordWeightAdjustment(ctx, 'batch1', 'Drying', '820', '830', 'Rewetted')).rejects.toThrow('exceeds the weight before it');
+        await expect(contract.RecordWeightAdjustment(ctx, 'batch1', 'Drying', '820', '800', '')).rejects.toThrow('reason is required');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await expect(contract.RecordWeightAdjustment(ctx, 'batch1', 'Drying', '820', '800', 'Dried')).rejects.toThrow('Permission denied');
+    });
+});
@@ -0,0 +1,83 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { BatchStatusContract } from '../src/batchStatusContract';
+import { ProductManagementContract } from '../src/productManagementContract';
+import { RecallContract } from '../src/recallContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext, MockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org1.example.com::/C=US/ST=North Carolina/O=org1.example.com/CN=ca.org1.example.com';
+
+describe('BatchStatusContract', () => {
+    let contract: BatchStatusContract;
+    let riceContract: RiceTracerContract;
+
+    const storeBatch = (ctx: MockContext, currentState: string) => {
+        ctx.stub.putJSON('batch_batch1', {
+            docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Mill A', currentState, harvestDate: '2024-09-01T00:00:00.000Z',
+            history: [{ timestamp: '2024-09-01T00:00:00.000Z', from: '', to: 'Mill A', step: currentState, signerMspId: 'Org2MSP' }]
+        });
+    };
+    const batchIds = async (ctx: MockContext, status: string) =>
+        (await contract.GetBatchesByStatus(ctx, status)).map(batch => batch.batchId);
+
+    beforeEach(() => {
+        contract = new BatchStatusContract();
+        riceContract = new RiceTracerContract();
+    });
+
+    test('should follow quarantine, recall and disposal', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        storeBatch(ctx, 'Milled');
+
+        await riceContract.QuarantineBatch(ctx, 'batch1', 'Pest sighting');
+        expect(ctx.stub.getJSON('batch_batch1').status).toBe('Quarantined');
+        await expect(batchIds(ctx, 'quarantined')).resolves.toEqual(['batch1']);
+
+        await riceContract.ReleaseQuarantine(ctx, 'batch1');
+        expect(ctx.stub.getJSON('batch_batch1').status).toBe('Active');
+        await expect(batchIds(ctx, 'Quarantined')).resolves.toEqual([]);
+        await expect(batchIds(ctx, 'Active')).resolves.toEqual(['batch1']);
+
+        await new RecallContract().IssueRecall(ctx, 'RC-001', 'Aflatoxin above limit', JSON.stringify({ batchIds: ['batch1'] }));
+        expect(ctx.stub.getJSON('batch_batch1').status).toBe('Recalled');
+
+        await riceContract.DisposeBatch(ctx, 'batch1', 'Recall RC-001', 'Incineration', '1000kg', 'Waste Co');
+        expect(ctx.stub.getJSON('batch_batch1').status).toBe('Disposed');
+        await expect(batchIds(ctx, 'Disposed')).resolves.toEqual(['batch1']);
+        await expect(batchIds(ctx, 'Recalled')).resolves.toEqual([]);
+    });
+
+    test('should finalize a packaged batch and archive it once its products are sold', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        storeBatch(ctx, 'Packaged');
+        const products = new ProductManagementContract();
+
+        await products.CreateProduct(ctx, 'P1', 'batch1', '2024-09-20', 'Distributor A', '');
+        expect(ctx.stub.getJSON('batch_batch1').status).toBe('Finalized');
+
+        await products.TransferProduct(ctx, 'P1', 'Retailer B', '', '');
+        expect(ctx.stub.getJSON('batch_batch1').status).toBe('Archived');
+        await expect(batchIds(ctx, 'Archived')).resolves.toEqual(['batch1']);
+
+        await products.ReturnProduct(ctx, 'P1', 'Damaged packaging', false, '');
+        expect(ctx.stub.getJSON('batch_batch1').status).toBe('Finalized');
+    });
+
+    test('should derive the status of batches stored before it was maintained', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+        storeBatch(ctx, 'Milled');
+        ctx.stub.putJSON('batch_batch2', { docType: 'riceBatch', batchId: 'batch2', currentOwner: 'Mill A', currentState: 'Milled', quarantined: true, history: [] });
+
+        await expect(contract.RebuildStatusIndex(ctx)).resolves.toBe(2);
+        await expect(batchIds(ctx, 'Active')).resolves.toEqual(['batch1']);
+        await expect(batchIds(ctx, 'Quarantined')).resolves.toEqual(['batch2']);
+        await expect(contract.RebuildStatusIndex(ctx)).resolves.toBe(0);
+
+        await expect(contract.GetBatchesByStatus(ctx, 'Shipped')).rejects.toThrow('Invalid batch status Shipped');
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(contract.RebuildStatusIndex(ctx)).rejects.toThrow('Only organization administrators');
+    });
+});
@@ -0,0 +1,92 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { BatchStorageContract } from '../src/batchStorageContract';
+import { QualityCertificationContract } from '../src/qualityCertificationContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext, MockContext } from '../testing';
+
+// SHA-256 of the lab report file registered with test results
+const REPORT_HASH = 'a3f5c1d2e4b6a8c0e2f4a6b8c0d2e4f6a8b0c2d4e6f8a0b2c4d6e8f0a2b4c6d8';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+
+describe('BatchStorageContract', () => {
+    let contract: BatchStorageContract;
+    let riceTracer: RiceTracerContract;
+
+    beforeEach(() => {
+        contract = new BatchStorageContract();
+        riceTracer = new RiceTracerContract();
+    });
+
+    const defineLimits = async (ctx: MockContext, limits: object) => {
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await contract.DefineBatchStorageLimits(ctx, JSON.stringify(limits));
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+    };
+
+    const recordStep = (ctx: MockContext, reportId: string) => {
+        ctx.nextTransaction();
+        return riceTracer.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', JSON.stringify({
+            reportId, reportType: 'ProcessingRecord', reportHash: `hash-${reportId}`, summary: 'Dried', isVerified: false
+        }), '');
+    };
+
+    test('should move the oldest history events to continuation keys', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        await defineLimits(ctx, { maxHistoryEvents: 2, maxTestResultsPerBatch: 10, maxDocumentBytes: 100000 });
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farmer Zhang', currentState: 'Harvested', history: [] });
+
+        for (const reportId of ['r1', 'r2', 'r3', 'r4', 'r5']) {
+            await recordStep(ctx, reportId);
+        }
+
+        const stored = ctx.stub.getJSON('batch_batch1');
+        expect(stored.history.map((event: any) => event.report.reportId)).toEqual(['r5']);
+        expect(stored).toEqual(expect.objectContaining({ archivedHistorySegments: 2, archivedHistoryEvents: 4 }));
+        expect(ctx.stub.getJSON('batchhistory_batch1_000002')).toEqual(expect.objectContaining({ firstIndex: 2 }));
+
+        const history = await riceTracer.GetBatchHistory(ctx, 'batch1');
+        expect(history.map(event => event.report.reportId)).toEqual(['r1', 'r2', 'r3', 'r4', 'r5']);
+        await expect(riceTracer.GetAllRiceBatches(ctx)).resolves.toEqual([expect.objectContaining({ history })]);
+
+        await expect(contract.GetBatchStorageUsage(ctx, 'batch1')).resolves.toEqual(expect.objectContaining({
+            storedHistoryEvents: 1, archivedHistorySegments: 2, totalHistoryEvents: 5, maxHistoryEvents: 2, testResults: 0
+        }));
+    });
+
+    test('should reject writes beyond the document size and test result limits', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        await defineLimits(ctx, { maxHistoryEvents: 50, maxTestResultsPerBatch: 1, maxDocumentBytes: 600 });
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farmer Zhang', currentState: 'Harvested', harvestDate: '2024-09-01T00:00:00.000Z', history: [] });
+
+        await recordStep(ctx, 'r1');
+        await expect(recordStep(ctx, 'r2')).rejects.toThrow('above the limit of 600 bytes');
+
+        const quality = new QualityCertificationContract();
+        await quality.RecordSample(ctx, 'batch1', 'sample1', '500g', 'Inspector Li', 'Silo 3');
+        await quality.CreateTestResult(ctx, 'test1', 'batch1', 'sample1', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, '');
+        await expect(quality.CreateTestResult(ctx, 'test2', 'batch1', 'sample1', 'Moisture', '2024-09-21', 'Passed', 'Lab A', '', REPORT_HASH, ''))
+            .rejects.toThrow('already has 1 test results, the maximum of 1');
+
+        const usage = await contract.GetBatchStorageUsage(ctx, 'batch1');
+        expect(usage).toEqual(expect.objectContaining({ testResults: 1, maxDocumentBytes: 600 }));
+        expect(usage.documentBytes).toBeLessThanOrEqual(600);
+    });
+
+    test('should use default limits until an administrator defines them', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await expect(contract.GetBatchStorageLimits(ctx)).resolves.toEqual(expect.objectContaining({ maxHistoryEvents: 200, version: 0 }));
+
+        await expect(contract.DefineBatchStorageLimits(ctx, JSON.stringify({ maxHistoryEvents: 0, maxTestResultsPerBatch: 1, maxDocumentBytes: 1 })))
+            .rejects.toThrow('maxHistoryEvents must be a positive integer');
+        await contract.DefineBatchStorageLimits(ctx, JSON.stringify({ maxHistoryEvents: 10, maxTestResultsPerBatch: 1, maxDocumentBytes: 5000 }));
+        await expect(contract.GetBatchStorageLimits(ctx)).resolves.toEqual(expect.objectContaining({ maxHistoryEvents: 10, version: 1 }));
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(contract.DefineBatchStorageLimits(ctx, '{}')).rejects.toThrow();
+        await expect(contract.GetBatchStorageUsage(ctx, 'missing')).rejects.toThrow('does not exist');
+    });
+});
@@ -0,0 +1,95 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { ComplianceProfileContract } from '../src/complianceProfileContract';
+import { ConsignmentContract } from '../src/consignmentContract';
+import { QualityCertificationContract } from '../src/qualityCertificationContract';
+import { createMockContext } from '../testing';
+
+// SHA-256 of the lab report file registered with test results
+const REPORT_HASH = 'a3f5c1d2e4b6a8c0e2f4a6b8c0d2e4f6a8b0c2d4e6f8a0b2c4d6e8f0a2b4c6d8';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org1.example.com::/C=US/ST=North Carolina/O=org1.example.com/CN=ca.org1.example.com';
+
+describe('ComplianceProfileContract', () => {
+    let contract: ComplianceProfileContract;
+
+    beforeEach(() => {
+        contract = new ComplianceProfileContract();
+    });
+
+    const EU_PROFILE = {
+        name: 'EU MRLs',
+        countries: ['DE', 'fr'],
+        requiredTests: ['Pesticide Residue', 'Moisture'],
+        residueLimits: [{ substance: 'Chlorpyrifos', maxMgPerKg: 0.01 }, { substance: 'cadmium', maxMgPerKg: 0.2 }],
+        requiredDocuments: ['labReport', 'certificate']
+    };
+
+    test('should list every requirement a batch misses and refuse consignments to the market', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await contract.DefineComplianceProfile(ctx, 'eu', JSON.stringify(EU_PROFILE));
+        expect(await contract.ReadComplianceProfile(ctx, 'EU')).toEqual(expect.objectContaining({
+            market: 'EU', countries: ['DE', 'FR'], residueLimits: [{ substance: 'chlorpyrifos', maxMgPerKg: 0.01 }, { substance: 'cadmium', maxMgPerKg: 0.2 }], version: 1
+        }));
+
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', harvestDate: '2024-09-15T00:00:00.000Z', history: [] });
+        ctx.stub.putJSON('attachment_A1', { docType: 'attachment', attachmentId: 'A1', entityId: 'batch1', category: 'labReport' });
+        ctx.stub.state.set(ctx.stub.createCompositeKey('entity~attachmentId', ['batch1', 'A1']), Buffer.from([0x00]));
+        const quality = new QualityCertificationContract();
+        await quality.RecordSample(ctx, 'batch1', 'sample1', '500g', 'Inspector Li', 'Silo 3');
+        await quality.CreateTestResult(ctx, 'test1', 'batch1', 'sample1', 'Pesticide Residue', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, '');
+        await quality.RecordResidueLevels(ctx, 'batch1', 'test1', JSON.stringify({ Chlorpyrifos: 0.02 }));
+        await expect(quality.RecordResidueLevels(ctx, 'batch1', 'test1', '{"cadmium": 0.1}')).rejects.toThrow('already recorded');
+
+        const check = await contract.CheckExportCompliance(ctx, 'batch1', 'de');
+        expect(check).toEqual(expect.objectContaining({ batchId: 'batch1', market: 'EU', profileVersion: 1, compliant: false }));
+        expect(check.violations.map(violation => [violation.rule, violation.requirement])).toEqual([
+            ['requiredTest', 'Moisture'],
+            ['residueLimit', 'chlorpyrifos'],
+            ['residueLimit', 'cadmium'],
+            ['requiredDocument', 'certificate']
+        ]);
+        expect(check.violations[1].message).toBe('chlorpyrifos residue of 0.02 mg/kg exceeds the limit of 0.01 mg/kg');
+
+        const consignments = new ConsignmentContract();
+        await expect(consignments.CreateConsignment(ctx, 'EXP-001', 'FR', JSON.stringify({ batchIds: ['batch1'] })))
+            .rejects.toThrow('The rice batch batch1 does not meet the EU compliance profile: No passed Moisture test is recorded');
+        // Destinations without a profile are not restricted
+        await consignments.CreateConsignment(ctx, 'EXP-001', 'JP', JSON.stringify({ batchIds: ['batch1'] }));
+    });
+
+    test('should configure the domestic market outside every export market', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await expect(contract.GetDomesticMarket(ctx)).resolves.toEqual(expect.objectContaining({ country: 'CN', version: 0 }));
+        await contract.DefineComplianceProfile(ctx, 'EU', JSON.stringify(EU_PROFILE));
+
+        await expect(contract.DefineDomesticMarket(ctx, 'de')).rejects.toThrow('Country DE is an export market of the EU compliance profile');
+        await expect(contract.DefineDomesticMarket(ctx, 'Thailand')).rejects.toThrow('Invalid country Thailand');
+        await contract.DefineDomesticMarket(ctx, 'th');
+        await expect(contract.GetDomesticMarket(ctx)).resolves.toEqual(expect.objectContaining({ country: 'TH', version: 1, definedBy: 'Org2MSP' }));
+
+        await expect(contract.DefineComplianceProfile(ctx, 'ASEAN', JSON.stringify({ name: 'ASEAN', countries: ['TH'] })))
+            .rejects.toThrow('TH is the domestic market');
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', history: [] });
+        await expect(new ConsignmentContract().CreateConsignment(ctx, 'EXP-001', 'TH', JSON.stringify({ batchIds: ['batch1'] })))
+            .rejects.toThrow('TH is the domestic market');
+    });
+
+    test('should reject invalid profiles and overlapping markets', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+        const define = (market: string, profile: object) => contract.DefineComplianceProfile(ctx, market, JSON.stringify({ ...EU_PROFILE, ...profile }));
+
+        await define('EU', {});
+        await expect(define('DE-ONLY', { countries: ['DE'] })).rejects.toThrow('Country DE is already covered by the EU compliance profile');
+        await expect(define('CN', { countries: ['CN'] })).rejects.toThrow('domestic market');
+        await expect(define('JP', { countries: [] })).rejects.toThrow('at least one destination country');
+        await expect(define('JP', { countries: ['JP'], requiredDocuments: ['invoice'] })).rejects.toThrow('Invalid required document invoice');
+        await expect(define('JP', { countries: ['JP'], residueLimits: [{ substance: 'cadmium', maxMgPerKg: -1 }] })).rejects.toThrow('non-negative');
+        await expect(contract.CheckExportCompliance(ctx, 'batch1', 'JP')).rejects.toThrow('does not exist');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(define('EU', {})).rejects.toThrow('Only organization administrators');
+    });
+});
@@ -0,0 +1,107 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { ConsignmentContract } from '../src/consignmentContract';
+import { createMockContext, MockContext } from '../testing';
+
+describe('ConsignmentContract', () => {
+    let contract: ConsignmentContract;
+
+    beforeEach(() => {
+        contract = new ConsignmentContract();
+    });
+
+    const PHYTO_HASH = 'AB'.repeat(32);
+
+    const putItems = (ctx: MockContext) => {
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentState: 'Packaged', history: [] });
+        ctx.stub.putJSON('batch_batch2', { docType: 'riceBatch', batchId: 'batch2', currentState: 'Disposed', history: [] });
+        ctx.stub.putJSON('product_P1', { docType: 'product', productId: 'P1', batchId: 'batch1', status: 'Active', transfers: [] });
+    };
+
+    test('should move a consignment through inspection, clearance and shipment', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        putItems(ctx);
+
+        await contract.CreateConsignment(ctx, 'EXP-001', 'jp', JSON.stringify({ batchIds: ['batch1'], productIds: ['P1'] }));
+        expect(ctx.stub.events[0].name).toBe('ConsignmentCreated');
+
+        await expect(contract.AdvanceConsignment(ctx, 'EXP-001', 'Cleared', 'CD-2024-0001', '')).rejects.toThrow('can only move to Inspected');
+        await expect(contract.AdvanceConsignment(ctx, 'EXP-001', 'Inspected', 'not-a-hash', '')).rejects.toThrow('SHA-256 digest');
+        await contract.AdvanceConsignment(ctx, 'EXP-001', 'Inspected', PHYTO_HASH, 'Inspected at Dalian port');
+        await expect(contract.AdvanceConsignment(ctx, 'EXP-001', 'Cleared', '', '')).rejects.toThrow('Customs declaration reference is required');
+        await contract.AdvanceConsignment(ctx, 'EXP-001', 'Cleared', 'CD-2024-0001', '');
+        await contract.AdvanceConsignment(ctx, 'EXP-001', 'Shipped', '', '');
+        expect(ctx.stub.events[0].name).toBe('ConsignmentStatusChanged');
+        await expect(contract.AdvanceConsignment(ctx, 'EXP-001', 'Shipped', '', '')).rejects.toThrow('already shipped');
+
+        const consignment = await contract.ReadConsignment(ctx, 'EXP-001');
+        expect(consignment).toEqual(expect.objectContaining({
+            destinationCountry: 'JP',
+            exporterMspId: 'Org2MSP',
+            status: 'Shipped',
+            phytosanitaryCertificateHash: 'ab'.repeat(32),
+            customsDeclarationRef: 'CD-2024-0001'
+        }));
+        expect(consignment.statusHistory.map(change => change.status)).toEqual(['Prepared', 'Inspected', 'Cleared', 'Shipped']);
+        expect(consignment.statusHistory[1].note).toBe('Inspected at Dalian port');
+
+        const provenance = await contract.GetConsignmentsByEntity(ctx, 'P1');
+        expect(provenance.map(item => item.consignmentId)).toEqual(['EXP-001']);
+    });
+
+    test('should hand a cleared consignment over to the importer in chunks', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        const batchIds = Array.from({ length: 101 }, (_, index) => `batch${index + 1}`);
+        for (const batchId of batchIds) {
+            ctx.stub.putJSON(`batch_${batchId}`, { docType: 'riceBatch', batchId, currentOwner: 'Exporter Co', currentState: 'Packaged', history: [] });
+        }
+        ctx.stub.putJSON('product_P1', { docType: 'product', productId: 'P1', batchId: 'batch1', owner: 'Exporter Co', ownerMspId: 'Org2MSP', status: 'Active', transfers: [] });
+        await contract.CreateConsignment(ctx, 'EXP-001', 'JP', JSON.stringify({ batchIds, productIds: ['P1'] }));
+        await expect(contract.TransferConsignment(ctx, 'EXP-001', 'Tokyo Importers', 'Org3MSP', '')).rejects.toThrow('only be transferred once Cleared');
+
+        await contract.AdvanceConsignment(ctx, 'EXP-001', 'Inspected', PHYTO_HASH, '');
+        await contract.AdvanceConsignment(ctx, 'EXP-001', 'Cleared', 'CD-2024-0001', '');
+        await expect(contract.TransferConsignment(ctx, 'EXP-001', 'Tokyo Importers', 'Org3MSP', 'req-1'))
+            .resolves.toEqual({ consignmentId: 'EXP-001', newOwner: 'Tokyo Importers', transferred: 100, remaining: 2, complete: false });
+        expect(ctx.stub.events[0].name).toBe('ConsignmentTransferred');
+        // A retry of the same chunk does not hand over the next one
+        await expect(contract.TransferConsignment(ctx, 'EXP-001', 'Tokyo Importers', 'Org3MSP', 'req-1')).resolves.toEqual(expect.objectContaining({ transferred: 0, remaining: 2 }));
+        await expect(contract.TransferConsignment(ctx, 'EXP-001', 'Osaka Foods', '', '')).rejects.toThrow('being transferred to Tokyo Importers');
+
+        await expect(contract.TransferConsignment(ctx, 'EXP-001', 'Tokyo Importers', 'Org3MSP', 'req-2'))
+            .resolves.toEqual({ consignmentId: 'EXP-001', newOwner: 'Tokyo Importers', transferred: 2, remaining: 0, complete: true });
+        const batch = ctx.stub.getJSON('batch_batch1');
+        expect(batch).toEqual(expect.objectContaining({ currentOwner: 'Tokyo Importers', currentState: 'Packaged' }));
+        expect(batch.history[0]).toEqual(expect.objectContaining({
+            from: 'Exporter Co', to: 'Tokyo Importers', step: 'Packaged',
+            report: expect.objectContaining({ reportId: 'EXP-001', reportType: 'ExportConsignment', destinationCountry: 'JP' })
+        }));
+        expect(ctx.stub.getJSON('product_P1')).toEqual(expect.objectContaining({ owner: 'Tokyo Importers', ownerMspId: 'Org3MSP', status: 'Sold' }));
+        await expect(contract.TransferConsignment(ctx, 'EXP-001', 'Tokyo Importers', 'Org3MSP', '')).rejects.toThrow('already been transferred');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(contract.TransferConsignment(ctx, 'EXP-001', 'Tokyo Importers', '', '')).rejects.toThrow('Only the exporting organization Org2MSP');
+    });
+
+    test('should reject invalid consignments and items already awaiting export', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        putItems(ctx);
+
+        await expect(contract.CreateConsignment(ctx, 'EXP-001', 'CN', JSON.stringify({ batchIds: ['batch1'] }))).rejects.toThrow('domestic market');
+        await expect(contract.CreateConsignment(ctx, 'EXP-001', 'Japan', JSON.stringify({ batchIds: ['batch1'] }))).rejects.toThrow('alpha-2');
+        await expect(contract.CreateConsignment(ctx, 'EXP-001', 'JP', '{}')).rejects.toThrow('at least one batch or product');
+        await expect(contract.CreateConsignment(ctx, 'EXP-001', 'JP', JSON.stringify({ batchIds: ['batch2'] }))).rejects.toThrow('disposed of');
+        await expect(contract.CreateConsignment(ctx, 'EXP-001', 'JP', JSON.stringify({ productIds: ['P9'] }))).rejects.toThrow('does not exist');
+
+        await contract.CreateConsignment(ctx, 'EXP-001', 'JP', JSON.stringify({ batchIds: ['batch1'] }));
+        await expect(contract.CreateConsignment(ctx, 'EXP-002', 'SG', JSON.stringify({ batchIds: ['batch1'] })))
+            .rejects.toThrow('already in consignment EXP-001');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await expect(contract.AdvanceConsignment(ctx, 'EXP-001', 'Inspected', PHYTO_HASH, '')).rejects.toThrow('Only the exporting organization Org1MSP');
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        await expect(contract.CreateConsignment(ctx, 'EXP-003', 'JP', JSON.stringify({ productIds: ['P1'] }))).rejects.toThrow('Permission denied');
+    });
+});
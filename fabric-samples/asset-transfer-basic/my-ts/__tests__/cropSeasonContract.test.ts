@@ -0,0 +1,94 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { CropSeasonContract, resolveCropSeason } from '../src/cropSeasonContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext, MockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+
+describe('CropSeasonContract', () => {
+    let contract: CropSeasonContract;
+
+    beforeEach(() => {
+        contract = new CropSeasonContract();
+    });
+
+    describe('resolveCropSeason', () => {
+        test('should derive the crop year and season from the harvest date', () => {
+            expect(resolveCropSeason('2024-06-20T00:00:00.000Z', '', '')).toEqual({ cropYear: 2024, season: 'Early' });
+            expect(resolveCropSeason('2024-09-15T00:00:00.000Z', '2024', 'middle')).toEqual({ cropYear: 2024, season: 'Middle' });
+            // A January harvest closes the previous crop year's late season
+            expect(resolveCropSeason('2025-01-10T00:00:00.000Z', '2024', 'Late')).toEqual({ cropYear: 2024, season: 'Late' });
+        });
+
+        test('should reject values inconsistent with the harvest date', () => {
+            expect(() => resolveCropSeason('2024-09-15T00:00:00.000Z', '', 'Early')).toThrow('falls in the Middle season');
+            expect(() => resolveCropSeason('2025-01-10T00:00:00.000Z', '2025', '')).toThrow('belongs to crop year 2024');
+            expect(() => resolveCropSeason('2024-09-15T00:00:00.000Z', '24', '')).toThrow('four-digit year');
+            expect(() => resolveCropSeason('2024-09-15T00:00:00.000Z', '', 'Winter')).toThrow('Invalid season Winter');
+        });
+    });
+
+    describe('Season Statistics', () => {
+        const putBatch = (ctx: MockContext, batchId: string, harvestDate: string, variety: string, extra: object = {}) =>
+            ctx.stub.putJSON(`batch_${batchId}`, { docType: 'riceBatch', batchId, variety, harvestDate, currentState: 'Harvested', history: [], ...extra });
+        const putTest = (ctx: MockContext, testId: string, batchId: string, testResult: string) =>
+            ctx.stub.putJSON(`test_${testId}`, { docType: 'testResult', testId, batchId, testType: 'Moisture', testResult });
+
+        const storeSeasons = async (ctx: MockContext) => {
+            putBatch(ctx, 'b2023', '2023-09-10T00:00:00.000Z', 'Japonica');
+            putBatch(ctx, 'b2024a', '2024-09-12T00:00:00.000Z', 'Japonica');
+            putBatch(ctx, 'b2024b', '2024-08-30T00:00:00.000Z', 'Indica', { currentState: 'Disposed' });
+            putBatch(ctx, 'b2024c', '2024-06-30T00:00:00.000Z', 'Indica');
+            putTest(ctx, 'T1', 'b2023', 'Passed');
+            putTest(ctx, 'T2', 'b2024a', 'Passed');
+            putTest(ctx, 'T3', 'b2024b', 'Failed');
+            ctx.stub.state.set(ctx.stub.createCompositeKey('productBatch~productId', ['b2024a', 'P1']), Buffer.from([0x00]));
+            ctx.stub.state.set(ctx.stub.createCompositeKey('productBatch~productId', ['b2024a', 'P2']), Buffer.from([0x00]));
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', id: ADMIN_ID });
+            await expect(contract.BackfillCropSeasons(ctx)).resolves.toBe(4);
+            await expect(contract.BackfillCropSeasons(ctx)).resolves.toBe(0);
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        };
+
+        test('should aggregate the batches of a season', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            await storeSeasons(ctx);
+
+            expect(ctx.stub.getJSON('batch_b2024c')).toEqual(expect.objectContaining({ cropYear: 2024, season: 'Early' }));
+            await expect(contract.GetSeasonStats(ctx, '2024', 'middle')).resolves.toEqual({
+                cropYear: 2024, season: 'Middle', batches: 2, disposedBatches: 1, products: 2,
+                testsRecorded: 2, failedTests: 1, failureRate: 0.5, varieties: { Japonica: 1, Indica: 1 }
+            });
+            await expect(contract.GetSeasonStats(ctx, '2024', '')).resolves.toEqual(expect.objectContaining({ season: '', batches: 3 }));
+        });
+
+        test('should compare a season year over year', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            await storeSeasons(ctx);
+
+            const comparison = await contract.CompareSeasons(ctx, 'Middle', '2022', '2024');
+            expect(comparison.map(stats => [stats.cropYear, stats.batches, stats.failureRate])).toEqual([[2022, 0, 0], [2023, 1, 0], [2024, 2, 0.5]]);
+
+            await expect(contract.CompareSeasons(ctx, 'Middle', '2024', '2023')).rejects.toThrow('Invalid crop year range');
+            await expect(contract.CompareSeasons(ctx, 'Middle', '2000', '2024')).rejects.toThrow('At most 20 crop years');
+            await expect(contract.GetSeasonStats(ctx, '2024', 'Winter')).rejects.toThrow('Invalid season Winter');
+        });
+
+        test('should record and index the season of new batches', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            const riceTracer = new RiceTracerContract();
+            const create = (batchId: string, cropYear: string, season: string) => riceTracer.CreateRiceBatch(
+                ctx, batchId, 'Heilongjiang', 'Japonica', '2024-09-15', '{}', 'Farmer Zhang', 'Harvested', 'Farmer Zhang', '', cropYear, season, ''
+            );
+
+            await expect(create('batch1', '2024', 'Early')).rejects.toThrow('Season Early does not match harvestDate');
+            await create('batch1', '', '');
+            expect(ctx.stub.getJSON('batch_batch1')).toEqual(expect.objectContaining({ cropYear: 2024, season: 'Middle' }));
+            await expect(contract.GetSeasonStats(ctx, '2024', 'Middle')).resolves.toEqual(expect.objectContaining({ batches: 1 }));
+        });
+    });
+});
@@ -0,0 +1,79 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { createHash } from 'crypto';
+import { DocumentAnchorContract } from '../src/documentAnchorContract';
+import { getCallerFingerprint } from '../src/utils';
+import { createMockContext } from '../testing';
+
+describe('DocumentAnchorContract', () => {
+    let contract: DocumentAnchorContract;
+
+    beforeEach(() => {
+        contract = new DocumentAnchorContract();
+    });
+
+    const hashOf = (data: string) => createHash('sha256').update(data).digest('hex');
+
+    const setupLedger = (mspId = 'Org2MSP') => {
+        const ctx = createMockContext({ mspId });
+        ctx.stub.putJSON('batch_B1', { docType: 'riceBatch', batchId: 'B1' });
+        ctx.stub.putJSON('product_P1', { docType: 'product', productId: 'P1', batchId: 'B1' });
+        return ctx;
+    };
+
+    test('should anchor a document and find it by ID, hash and entity', async () => {
+        const ctx = setupLedger();
+        const hash = hashOf('%PDF-1.4 certificate of B1');
+
+        const document = await contract.AnchorDocument(ctx, 'RTC-B1-0001', 'B1', 'traceabilityCertificate', hash.toUpperCase());
+        expect(document).toEqual(expect.objectContaining({
+            documentId: 'RTC-B1-0001', entityType: 'batch', documentHash: hash, issuedBy: 'Org2MSP',
+            issuerFingerprint: getCallerFingerprint(ctx)
+        }));
+        expect(ctx.stub.events[0].name).toBe('DocumentAnchored');
+
+        ctx.stub.nextTransaction();
+        await contract.AnchorDocument(ctx, 'RTC-P1-0001', 'P1', 'traceabilityCertificate', hashOf('%PDF-1.4 certificate of P1'));
+
+        await expect(contract.GetDocumentByHash(ctx, hash)).resolves.toEqual(expect.objectContaining({ documentId: 'RTC-B1-0001' }));
+        await expect(contract.ReadAnchoredDocument(ctx, 'RTC-P1-0001')).resolves.toEqual(expect.objectContaining({ entityType: 'product' }));
+        const documents = await contract.GetAnchoredDocuments(ctx, 'B1');
+        expect(documents.map(entry => entry.documentId)).toEqual(['RTC-B1-0001']);
+    });
+
+    test('should reject reused IDs and files, unknown entities and consumers', async () => {
+        const ctx = setupLedger();
+        const hash = hashOf('%PDF-1.4 certificate');
+        await contract.AnchorDocument(ctx, 'RTC-1', 'B1', 'traceabilityCertificate', hash);
+
+        await expect(contract.AnchorDocument(ctx, 'RTC-1', 'B1', 'traceabilityCertificate', hashOf('other'))).rejects.toThrow('already exists');
+        await expect(contract.AnchorDocument(ctx, 'RTC-2', 'P1', 'traceabilityCertificate', hash)).rejects.toThrow('already been anchored as RTC-1');
+        await expect(contract.AnchorDocument(ctx, 'RTC-3', 'B9', 'traceabilityCertificate', hashOf('x'))).rejects.toThrow('No batch or product');
+        await expect(contract.AnchorDocument(ctx, 'RTC-4', 'B1', 'traceabilityCertificate', 'abc')).rejects.toThrow('SHA-256');
+        await expect(contract.GetDocumentByHash(ctx, hashOf('unknown'))).rejects.toThrow('has been anchored');
+
+        await expect(contract.AnchorDocument(setupLedger('Org3MSP'), 'RTC-5', 'B1', 'traceabilityCertificate', hashOf('y')))
+            .rejects.toThrow('Permission denied');
+    });
+
+    test('should record who acknowledged a test report of a product\'s batch and refuse unrelated hashes', async () => {
+        const ctx = setupLedger('Org3MSP');
+        const reportHash = hashOf('%PDF-1.4 moisture report');
+        ctx.stub.putJSON('test_T1', { docType: 'testResult', testId: 'T1', batchId: 'B1', reportHash });
+        ctx.stub.state.set(ctx.stub.createCompositeKey('batchId~testId', ['B1', 'T1']), Buffer.from([0x00]));
+
+        const acknowledgment = await contract.AcknowledgeDocument(ctx, 'P1', reportHash.toUpperCase(), 'Reviewed before accepting delivery');
+        expect(acknowledgment).toEqual(expect.objectContaining({
+            entityId: 'P1', documentHash: reportHash, documentSource: 'testReport', documentRef: 'T1',
+            acknowledgedByMspId: 'Org3MSP', acknowledgedByFingerprint: getCallerFingerprint(ctx), note: 'Reviewed before accepting delivery'
+        }));
+        expect(ctx.stub.events[0].name).toBe('DocumentAcknowledged');
+        await expect(contract.AcknowledgeDocument(ctx, 'P1', reportHash, '')).rejects.toThrow('already been acknowledged');
+
+        await expect(contract.GetDocumentAcknowledgments(ctx, 'P1', reportHash)).resolves.toEqual([acknowledgment]);
+        await expect(contract.GetDocumentAcknowledgments(ctx, 'B1', '')).resolves.toEqual([]);
+        await expect(contract.AcknowledgeDocument(ctx, 'P1', hashOf('forged report'), '')).rejects.toThrow('No test report, attachment or anchored document of P1');
+    });
+});
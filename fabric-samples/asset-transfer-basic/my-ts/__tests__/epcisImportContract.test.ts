@@ -0,0 +1,156 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { EpcisImportContract } from '../src/epcisImportContract';
+import { createMockContext, MockContext } from '../testing';
+
+describe('EpcisImportContract', () => {
+    let contract: EpcisImportContract;
+
+    beforeEach(() => {
+        contract = new EpcisImportContract();
+    });
+
+    const storeLedger = (ctx: MockContext) => {
+        ctx.stub.putJSON('batch_batch123', {
+            docType: 'riceBatch',
+            batchId: 'batch123',
+            origin: 'Heilongjiang',
+            variety: 'Japonica',
+            harvestDate: '2024-09-15T00:00:00.000Z',
+            currentOwner: 'Processor A',
+            currentState: 'Milling',
+            history: [{ timestamp: '2024-09-16T00:00:00.000Z', from: 'Farm A', to: 'Processor A', step: 'Milling' }]
+        });
+        ctx.stub.putJSON('product_product123', { docType: 'product', productId: 'product123', batchId: 'batch123' });
+    };
+
+    const captureDocument = (eventList: object[]) => JSON.stringify({
+        '@context': ['https://ref.gs1.org/standards/epcis/2.0.0/epcis-context.jsonld'],
+        type: 'EPCISDocument',
+        schemaVersion: '2.0',
+        creationDate: '2024-09-20T00:00:00.000Z',
+        epcisBody: { eventList }
+    });
+
+    const packEvent = {
+        type: 'AggregationEvent',
+        eventID: 'ni:///pack-1',
+        eventTime: '2024-09-17T08:00:00.000Z',
+        action: 'ADD',
+        bizStep: 'packing',
+        parentID: 'urn:epc:id:sscc:0614141.1234567890',
+        childEPCs: ['urn:epc:id:sgtin:0614141.107346.product123'],
+        childQuantityList: [{ epcClass: 'urn:epc:class:lgtin:0614141.107346.batch123', quantity: 200 }]
+    };
+
+    const shipEvent = {
+        type: 'ObjectEvent',
+        eventID: 'ni:///ship-1',
+        eventTime: '2024-09-18T08:00:00.000Z',
+        action: 'OBSERVE',
+        bizStep: 'urn:epcglobal:cbv:bizstep:shipping',
+        epcList: ['urn:epc:id:sscc:0614141.1234567890'],
+        bizLocation: { id: 'urn:epc:id:sgln:0614141.00777.0' },
+        sourceList: [{ type: 'owning_party', source: 'urn:epc:id:pgln:0614141.00001' }],
+        destinationList: [{ type: 'owning_party', destination: 'urn:epc:id:pgln:0614141.00002' }]
+    };
+
+    test('should pack EPCs into a logistics unit and ship it', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        storeLedger(ctx);
+
+        const result = await contract.ImportEpcisDocument(ctx, captureDocument([shipEvent, packEvent]));
+
+        expect(result).toEqual(expect.objectContaining({ eventCount: 2, processingRecords: 1, shipments: 1, logisticsUnits: 1, skipped: [] }));
+        const unit = await contract.ReadLogisticsUnit(ctx, 'urn:epc:id:sscc:0614141.1234567890');
+        expect(unit.packed).toBe(true);
+        expect(unit.batchIds).toEqual(['batch123']);
+        expect(unit.productIds).toEqual(['product123']);
+
+        const shipment = await contract.ReadShipment(ctx, 'ni:///ship-1');
+        expect(shipment.batchIds).toEqual(['batch123']);
+        expect(shipment.unitIds).toEqual(['urn:epc:id:sscc:0614141.1234567890']);
+        expect(shipment.destination).toBe('urn:epc:id:pgln:0614141.00002');
+
+        const batch = ctx.stub.getJSON('batch_batch123');
+        expect(batch.currentState).toBe('Milling');
+        expect(batch.history[1]).toEqual(expect.objectContaining({
+            step: 'EPCIS:shipping', from: 'Processor A', to: 'urn:epc:id:pgln:0614141.00002', signerMspId: 'Org2MSP'
+        }));
+        expect(batch.history[1].report.reportId).toBe('ni:///ship-1');
+        expect(ctx.stub.events[0].name).toBe('EpcisDocumentImported');
+    });
+
+    test('should record other business steps as processing records', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        storeLedger(ctx);
+
+        const result = await contract.ImportEpcisDocument(ctx, captureDocument([{
+            type: 'ObjectEvent',
+            eventTime: '2024-09-17T00:00:00.000Z',
+            action: 'OBSERVE',
+            bizStep: 'https://ref.gs1.org/cbv/BizStep-inspecting',
+            epcList: ['https://id.gs1.org/01/00614141073467/10/batch123']
+        }]));
+
+        expect(result.processingRecords).toBe(1);
+        expect(result.shipments).toBe(0);
+        const batch = ctx.stub.getJSON('batch_batch123');
+        expect(batch.history[1].step).toBe('EPCIS:inspecting');
+        expect(batch.history[1].report.reportId).toMatch(/^ni:\/\/\/sha-256;[0-9a-f]{64}$/);
+    });
+
+    test('should skip events that cannot be imported and keep the rest', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        storeLedger(ctx);
+
+        const result = await contract.ImportEpcisDocument(ctx, captureDocument([
+            packEvent,
+            { ...packEvent, eventID: 'ni:///unpack-1', action: 'DELETE', parentID: 'urn:epc:id:sscc:0614141.0000000000' },
+            { ...shipEvent, eventID: 'ni:///ship-early', eventTime: '2024-09-15T00:00:00.000Z', epcList: [], quantityList: [{ epcClass: 'urn:epc:class:lgtin:0614141.107346.batch123' }] },
+            { ...shipEvent, eventID: 'ni:///ship-unknown', epcList: ['urn:epc:id:sgtin:0614141.107346.unknown'] },
+            { type: 'TransformationEvent', eventID: 'ni:///transform-1', eventTime: '2024-09-17T00:00:00.000Z' }
+        ]));
+
+        expect(result.logisticsUnits).toBe(1);
+        expect(result.skipped.map(skipped => skipped.eventId))
+            .toEqual(['ni:///ship-early', 'ni:///transform-1', 'ni:///unpack-1', 'ni:///ship-unknown']);
+        expect(result.skipped[0].reason).toContain('earlier than the latest history event');
+        expect(ctx.stub.getJSON('batch_batch123').history).toHaveLength(1);
+    });
+
+    test('should unpack a logistics unit and not import an event twice', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        storeLedger(ctx);
+        await contract.ImportEpcisDocument(ctx, captureDocument([packEvent]));
+
+        ctx.stub.nextTransaction();
+        const result = await contract.ImportEpcisDocument(ctx, captureDocument([
+            packEvent,
+            { ...packEvent, eventID: 'ni:///unpack-1', eventTime: '2024-09-19T00:00:00.000Z', action: 'DELETE', childEPCs: [], childQuantityList: [] }
+        ]));
+
+        expect(result.skipped).toEqual([{ eventId: 'ni:///pack-1', reason: 'Event has already been imported' }]);
+        const unit = await contract.ReadLogisticsUnit(ctx, 'urn:epc:id:sscc:0614141.1234567890');
+        expect(unit.packed).toBe(false);
+        expect(unit.childEpcs).toEqual([]);
+        expect(unit.lastEventId).toBe('ni:///unpack-1');
+    });
+
+    test('should reject documents that are not EPCIS 2.0 capture documents', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+
+        await expect(contract.ImportEpcisDocument(ctx, '<EPCISDocument/>')).rejects.toThrow('EPCIS document format error');
+        await expect(contract.ImportEpcisDocument(ctx, JSON.stringify({ type: 'EPCISQueryDocument' })))
+            .rejects.toThrow('Expected an EPCIS capture document');
+        await expect(contract.ImportEpcisDocument(ctx, JSON.stringify({ type: 'EPCISDocument', schemaVersion: '1.2', epcisBody: { eventList: [] } })))
+            .rejects.toThrow('Unsupported EPCIS schema version');
+    });
+
+    test('should reject consumers', async () => {
+        const ctx = createMockContext({ mspId: 'Org3MSP' });
+        await expect(contract.ImportEpcisDocument(ctx, captureDocument([]))).rejects.toThrow('Permission denied');
+    });
+});
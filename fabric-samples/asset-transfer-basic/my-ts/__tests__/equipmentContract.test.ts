@@ -0,0 +1,85 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { EquipmentContract } from '../src/equipmentContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext, MockContext, TEST_TIMESTAMP_SECONDS } from '../testing';
+
+describe('EquipmentContract', () => {
+    let contract: EquipmentContract;
+
+    beforeEach(() => {
+        contract = new EquipmentContract();
+    });
+
+    const DAY_SECONDS = 24 * 60 * 60;
+
+    const registerMill = async (ctx: MockContext) =>
+        contract.RegisterEquipment(ctx, 'mill-2', 'mill', 'Satake NPX mill, line 2', 'Harbin plant', '2024-03-01', '2024-09-30');
+
+    const millBatch = async (ctx: MockContext, batchId: string) => {
+        ctx.stub.putJSON(`batch_${batchId}`, { docType: 'riceBatch', batchId, currentOwner: 'Processor A', currentState: 'Drying', history: [] });
+        await new RiceTracerContract().CompleteStepAndTransfer(ctx, batchId, 'Processor A', 'Processor A', 'Milling', JSON.stringify({
+            reportId: `r-${batchId}`, reportType: 'ProcessingRecord', reportHash: '', summary: 'Milled', isVerified: false, equipmentId: 'mill-2'
+        }), '');
+        ctx.stub.nextTransaction();
+    };
+
+    test('should register equipment and keep its calibration and maintenance log', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        await registerMill(ctx);
+        expect(ctx.stub.events[0].name).toBe('EquipmentRegistered');
+
+        ctx.stub.nextTransaction();
+        await contract.RecordMaintenance(ctx, 'mill-2', '2024-09-10', 'Replaced rubber rollers', '');
+        await contract.RecordCalibration(ctx, 'mill-2', '2024-09-20', '2025-03-20', 'Whiteness meter calibration', 'AB'.repeat(32));
+
+        const equipment = await contract.ReadEquipment(ctx, 'mill-2');
+        expect(equipment).toEqual(expect.objectContaining({
+            ownerMspId: 'Org2MSP',
+            lastCalibrationDate: '2024-09-20T00:00:00.000Z',
+            nextCalibrationDue: '2025-03-20T23:59:59.999Z',
+            lastMaintenanceDate: '2024-09-10T00:00:00.000Z'
+        }));
+        expect(equipment.serviceLog.map(record => record.type)).toEqual(['maintenance', 'calibration']);
+        expect(equipment.serviceLog[1].documentHash).toBe('ab'.repeat(32));
+
+        await expect(contract.RecordMaintenance(ctx, 'mill-2', '2024-10-01', 'Future', '')).rejects.toThrow('before it is performed');
+        await expect(contract.RegisterEquipment(ctx, 'sorter-1', 'oven', 'Oven', 'Harbin plant', '2024-03-01', '2024-09-30'))
+            .rejects.toThrow('Invalid equipment type');
+        await expect(registerMill(ctx)).rejects.toThrow('already registered');
+    });
+
+    test('should link processing steps to equipment and list the batches processed in a time range', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', timestampSeconds: TEST_TIMESTAMP_SECONDS - 2 * DAY_SECONDS });
+        await registerMill(ctx);
+        await millBatch(ctx, 'batch1');
+        ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS);
+        await millBatch(ctx, 'batch2');
+
+        const batch = ctx.stub.getJSON('batch_batch1');
+        expect(batch.history[0].report.equipmentId).toBe('mill-2');
+
+        await expect(contract.GetEquipmentUsage(ctx, 'mill-2', '', '')).resolves.toEqual([
+            { equipmentId: 'mill-2', batchId: 'batch1', step: 'Milling', processedAt: '2024-09-20T10:13:20.000Z' },
+            { equipmentId: 'mill-2', batchId: 'batch2', step: 'Milling', processedAt: '2024-09-22T10:13:20.000Z' }
+        ]);
+        const faultWindow = await contract.GetEquipmentUsage(ctx, 'mill-2', '2024-09-21', '2024-09-22');
+        expect(faultWindow.map(usage => usage.batchId)).toEqual(['batch2']);
+    });
+
+    test('should refuse steps on another organization\'s or uncalibrated equipment', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        await registerMill(ctx);
+
+        ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS + 10 * DAY_SECONDS);
+        await expect(millBatch(ctx, 'batch1')).rejects.toThrow('overdue for calibration');
+
+        ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS);
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(millBatch(ctx, 'batch1')).rejects.toThrow('operated by Org2MSP');
+        await expect(contract.RecordCalibration(ctx, 'mill-2', '2024-09-20', '2025-03-20', '', '')).rejects.toThrow('Permission denied');
+        await expect(contract.GetEquipmentUsage(ctx, 'mill-3', '', '')).rejects.toThrow('not registered');
+    });
+});
@@ -0,0 +1,68 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { FacilityContract } from '../src/facilityContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext, MockContext, TEST_TIMESTAMP_SECONDS } from '../testing';
+
+describe('FacilityContract', () => {
+    let contract: FacilityContract;
+
+    beforeEach(() => {
+        contract = new FacilityContract();
+    });
+
+    const HOUR_SECONDS = 60 * 60;
+
+    const registerMill = async (ctx: MockContext) => contract.RegisterFacility(ctx, 'harbin-mill', JSON.stringify({
+        name: 'Harbin mill', facilityType: 'mill', location: 'Harbin', lines: ['L1', 'L2'],
+        shifts: [{ name: 'day', start: '06:00', end: '18:00' }, { name: 'night', start: '18:00', end: '06:00' }]
+    }));
+
+    const millBatch = async (ctx: MockContext, batchId: string, context: object) => {
+        ctx.stub.putJSON(`batch_${batchId}`, { docType: 'riceBatch', batchId, currentOwner: 'Processor A', currentState: 'Drying', history: [] });
+        await new RiceTracerContract().CompleteStepAndTransfer(ctx, batchId, 'Processor A', 'Processor A', 'Milling', JSON.stringify({
+            reportId: `r-${batchId}`, reportType: 'ProcessingRecord', reportHash: '', summary: 'Milled', isVerified: false, ...context
+        }), '');
+        ctx.stub.nextTransaction();
+    };
+
+    test('should record the facility, line and shift of processing steps and list them by shift', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        await registerMill(ctx);
+        expect(ctx.stub.events[0].name).toBe('FacilityRegistered');
+
+        // 10:13 UTC falls in the day shift, which is derived when the report names none
+        await millBatch(ctx, 'batch1', { facilityId: 'harbin-mill', line: 'L1', ambient: { temperatureC: 24.5, humidityPercent: 61 } });
+        ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS + 10 * HOUR_SECONDS);
+        await millBatch(ctx, 'batch2', { facilityId: 'harbin-mill', line: 'L2' });
+
+        expect(ctx.stub.getJSON('batch_batch1').history[0].report).toEqual(expect.objectContaining({
+            facilityId: 'harbin-mill', line: 'L1', shift: 'day', ambient: { temperatureC: 24.5, humidityPercent: 61 }
+        }));
+        await expect(contract.GetFacilityActivity(ctx, 'harbin-mill', '', '', '', 'night')).resolves.toEqual([
+            { facilityId: 'harbin-mill', batchId: 'batch2', step: 'Milling', processedAt: '2024-09-22T20:13:20.000Z', line: 'L2', shift: 'night' }
+        ]);
+        const lineOne = await contract.GetFacilityActivity(ctx, 'harbin-mill', '2024-09-22', '2024-09-22', 'L1', '');
+        expect(lineOne.map(activity => activity.batchId)).toEqual(['batch1']);
+    });
+
+    test('should refuse unknown lines, shifts, implausible conditions and other organizations\' facilities', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        await registerMill(ctx);
+
+        await expect(millBatch(ctx, 'batch1', { facilityId: 'harbin-mill', line: 'L9' })).rejects.toThrow('has no line L9');
+        await expect(millBatch(ctx, 'batch1', { facilityId: 'harbin-mill', shift: 'weekend' })).rejects.toThrow('has no shift weekend');
+        await expect(millBatch(ctx, 'batch1', { facilityId: 'harbin-mill', ambient: { humidityPercent: 140 } })).rejects.toThrow('humidityPercent');
+        await expect(millBatch(ctx, 'batch1', { line: 'L1' })).rejects.toThrow('only be recorded with a facilityId');
+        await expect(millBatch(ctx, 'batch1', { facilityId: 'unknown' })).rejects.toThrow('not registered');
+        await expect(contract.RegisterFacility(ctx, 'yard', JSON.stringify({
+            name: 'Yard', facilityType: 'dryingYard', location: 'Wuchang', shifts: [{ name: 'a', start: '06:00', end: '14:00' }, { name: 'b', start: '12:00', end: '20:00' }]
+        }))).rejects.toThrow('Shift b overlaps shift a');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(millBatch(ctx, 'batch1', { facilityId: 'harbin-mill' })).rejects.toThrow('operated by Org2MSP');
+        await expect(registerMill(ctx)).rejects.toThrow('Permission denied');
+    });
+});
@@ -0,0 +1,81 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { GeographicIndicationContract } from '../src/geographicIndicationContract';
+import { createMockContext, MockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+
+describe('GeographicIndicationContract', () => {
+    let contract: GeographicIndicationContract;
+
+    beforeEach(() => {
+        contract = new GeographicIndicationContract();
+    });
+
+    const WUCHANG_RULE = JSON.stringify({
+        name: 'Wuchang Rice',
+        allowedRegions: ['Wuchang'],
+        allowedPlots: ['WC-001', 'WC-002'],
+        allowedVarieties: ['Daohuaxiang No. 2']
+    });
+
+    const defineRule = async (ctx: MockContext) => {
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await contract.DefineGIRule(ctx, 'wuchang', WUCHANG_RULE);
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+    };
+
+    const putBatch = (ctx: MockContext, origin: string, variety: string) =>
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', origin, variety, currentState: 'Harvested', history: [] });
+
+    test('should record a passed GI check on a batch from a registered plot', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        await defineRule(ctx);
+        putBatch(ctx, 'wuchang', 'Daohuaxiang No. 2');
+
+        const check = await contract.CheckGICompliance(ctx, 'batch1', 'wuchang', 'WC-002');
+        expect(check).toEqual(expect.objectContaining({ giName: 'Wuchang Rice', ruleVersion: 1, plotId: 'WC-002', passed: true, violations: [] }));
+        expect(ctx.stub.getJSON('batch_batch1').giCompliance).toEqual(check);
+        expect(ctx.stub.events[0].name).toBe('GIComplianceChecked');
+    });
+
+    test('should record every violation and emit a violation event', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        await defineRule(ctx);
+        putBatch(ctx, 'Heilongjiang', 'Japonica');
+
+        const check = await contract.CheckGICompliance(ctx, 'batch1', 'wuchang', 'HLJ-404');
+        expect(check.passed).toBe(false);
+        expect(check.violations).toEqual([
+            'Origin Heilongjiang is outside the protected regions Wuchang',
+            'Plot HLJ-404 is not registered for Wuchang Rice',
+            'Variety Japonica is not permitted for Wuchang Rice; allowed: Daohuaxiang No. 2'
+        ]);
+        expect(ctx.stub.events[0].name).toBe('GIComplianceViolation');
+        expect(ctx.stub.events[0].payload.giCompliance.violations).toHaveLength(3);
+
+        await expect(contract.CheckGICompliance(ctx, 'batch1', 'wuchang', '')).resolves.toEqual(expect.objectContaining({
+            violations: expect.arrayContaining(['A registered plot is required for Wuchang Rice'])
+        }));
+    });
+
+    test('should version rules and restrict who defines and checks them', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await contract.DefineGIRule(ctx, 'wuchang', WUCHANG_RULE);
+        await contract.DefineGIRule(ctx, 'wuchang', JSON.stringify({ name: 'Wuchang Rice', allowedRegions: ['Wuchang', 'Wuchang City'] }));
+        await expect(contract.ReadGIRule(ctx, 'wuchang')).resolves.toEqual(expect.objectContaining({ version: 2, allowedPlots: [] }));
+        await expect(contract.GetAllGIRules(ctx)).resolves.toHaveLength(1);
+
+        await expect(contract.DefineGIRule(ctx, 'panjin', JSON.stringify({ name: 'Panjin Rice' }))).rejects.toThrow('at least one allowed region');
+        await expect(contract.DefineGIRule(ctx, 'panjin', JSON.stringify({ name: 'Panjin Rice', allowedRegions: 'Panjin' })))
+            .rejects.toThrow('allowedRegions must be a list');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(contract.DefineGIRule(ctx, 'panjin', JSON.stringify({ name: 'Panjin Rice', allowedRegions: ['Panjin'] }))).rejects.toThrow();
+        await expect(contract.CheckGICompliance(ctx, 'batch1', 'wuchang', '')).rejects.toThrow('does not exist');
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        await expect(contract.CheckGICompliance(ctx, 'batch1', 'wuchang', '')).rejects.toThrow('Permission denied');
+    });
+});
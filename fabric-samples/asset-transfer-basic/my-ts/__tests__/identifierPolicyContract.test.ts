@@ -0,0 +1,59 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { IdentifierPolicyContract } from '../src/identifierPolicyContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org1.example.com::/C=US/ST=North Carolina/O=org1.example.com/CN=ca.org1.example.com';
+
+describe('IdentifierPolicyContract', () => {
+    let contract: IdentifierPolicyContract;
+
+    beforeEach(() => {
+        contract = new IdentifierPolicyContract();
+    });
+
+    const batchScheme = { prefixes: { Org1MSP: '0614141', Org2MSP: '0614142' }, sequenceDigits: 5, checkDigit: 'gs1', gs1Compatible: true };
+
+    test('should generate GS1 check-digit IDs and enforce them on new batches', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+        await contract.DefineIdPolicy(ctx, JSON.stringify({ batch: batchScheme }));
+        await expect(contract.GetIdPolicy(ctx)).resolves.toEqual(expect.objectContaining({ batch: batchScheme, version: 1, definedBy: 'Org1MSP' }));
+
+        // An ID already taken by hand is skipped
+        ctx.stub.putJSON('batch_0614141000029', { docType: 'riceBatch', batchId: '0614141000029' });
+        await expect(contract.GenerateBatchID(ctx)).resolves.toBe('0614141000012');
+        ctx.stub.nextTransaction();
+        await expect(contract.GenerateBatchID(ctx)).resolves.toBe('0614141000036');
+
+        const tracer = new RiceTracerContract();
+        const create = (batchId: string) => tracer.CreateRiceBatch(
+            ctx, batchId, 'Heilongjiang', 'Japonica', '2024-09-15', '{}', 'Farmer Zhang', 'Harvested', 'Farmer Zhang', '', '', '', ''
+        );
+        await expect(create('batch1')).rejects.toThrow('expected 0614141 followed by 6 digits');
+        await expect(create('0614141000013')).rejects.toThrow('invalid check digit');
+        await expect(create('0614142000019')).rejects.toThrow('expected 0614141');
+        await create('0614141000012');
+        expect(ctx.stub.getJSON('batch_0614141000012').batchId).toBe('0614141000012');
+
+        // Products have no scheme and keep free-form IDs
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await expect(contract.GenerateProductID(ctx)).rejects.toThrow('defines no product ID scheme');
+    });
+
+    test('should reject ambiguous or oversized schemes', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+        const define = (scheme: object) => contract.DefineIdPolicy(ctx, JSON.stringify({ product: { ...batchScheme, ...scheme } }));
+
+        await expect(define({ prefixes: { Org1MSP: 'RT', Org2MSP: 'RT' }, checkDigit: 'none' })).rejects.toThrow('cannot share the product ID prefix RT');
+        await expect(define({ prefixes: { Org1MSP: 'RT-F' } })).rejects.toThrow('must be digits for a gs1 check digit');
+        await expect(define({ sequenceDigits: 13 })).rejects.toThrow('from 3 to 12');
+        await expect(define({ prefixes: { Org1MSP: '061414100000' }, sequenceDigits: 12 })).rejects.toThrow('above the GS1 limit of 20');
+        await expect(contract.DefineIdPolicy(ctx, '{}')).rejects.toThrow('must define a batch or product scheme');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(contract.DefineIdPolicy(ctx, JSON.stringify({ batch: batchScheme }))).rejects.toThrow('Only organization administrators');
+    });
+});
@@ -0,0 +1,57 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { InputLimitContract, assertBulkSize, assertReportSize } from '../src/inputLimitContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org1.example.com::/C=US/ST=North Carolina/O=org1.example.com/CN=ca.org1.example.com';
+
+describe('InputLimitContract', () => {
+    let contract: InputLimitContract;
+
+    beforeEach(() => {
+        contract = new InputLimitContract();
+    });
+
+    test('should apply the built-in limits until configured', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+
+        await expect(contract.GetInputLimits(ctx)).resolves.toEqual(expect.objectContaining({
+            maxReportBytes: 65536, maxLabels: 20, maxBulkItems: 500, version: 0
+        }));
+        await expect(assertReportSize(ctx, JSON.stringify({ notes: 'x'.repeat(70000) }), 'The report of step Milling'))
+            .rejects.toThrow('The report of step Milling has 70012 bytes, above the input limit of 65536 bytes');
+        await expect(assertBulkSize(ctx, 500, 'Recall RC-001')).resolves.toBeUndefined();
+        await expect(assertBulkSize(ctx, 501, 'Recall RC-001')).rejects.toThrow('Recall RC-001 has 501 items, above the input limit of 500 items per transaction');
+    });
+
+    test('should let administrators change the limits', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+
+        await contract.DefineInputLimits(ctx, JSON.stringify({ maxReportBytes: 1024, maxLabels: 2, maxBulkItems: 10 }));
+        await expect(contract.GetInputLimits(ctx)).resolves.toEqual(expect.objectContaining({
+            maxReportBytes: 1024, maxLabels: 2, maxBulkItems: 10, version: 1, definedBy: 'Org1MSP'
+        }));
+        await expect(assertBulkSize(ctx, 11, 'The EPCIS event list')).rejects.toThrow('above the input limit of 10 items');
+
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farmer Zhang', currentState: 'Harvested', history: [] });
+        await expect(new RiceTracerContract().SetBatchLabels(ctx, 'batch1', JSON.stringify({ a: '1', b: '2', c: '3' })))
+            .rejects.toThrow('At most 2 labels can be set, got 3');
+        await expect(new RiceTracerContract().CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Mill A', 'Stored', JSON.stringify({ notes: 'x'.repeat(2000) }), ''))
+            .rejects.toThrow('The report of step Stored has 2012 bytes, above the input limit of 1024 bytes');
+    });
+
+    test('should validate the limits and require an administrator', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+
+        await expect(contract.DefineInputLimits(ctx, '{')).rejects.toThrow('Input limits format error');
+        await expect(contract.DefineInputLimits(ctx, JSON.stringify({ maxReportBytes: 1024, maxLabels: 0, maxBulkItems: 10 })))
+            .rejects.toThrow('maxLabels must be a positive integer');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(contract.DefineInputLimits(ctx, JSON.stringify({ maxReportBytes: 1024, maxLabels: 2, maxBulkItems: 10 })))
+            .rejects.toThrow('Only organization administrators');
+    });
+});
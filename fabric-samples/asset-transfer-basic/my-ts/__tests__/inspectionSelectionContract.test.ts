@@ -0,0 +1,65 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { InspectionSelectionContract } from '../src/inspectionSelectionContract';
+import { createMockContext, MockContext } from '../testing';
+
+describe('InspectionSelectionContract', () => {
+    let contract: InspectionSelectionContract;
+
+    beforeEach(() => {
+        contract = new InspectionSelectionContract();
+    });
+
+    const SEED = 'c0ffee'.repeat(10) + 'beef';
+
+    const putBatches = (ctx: MockContext) => {
+        const event = (from: string, to: string) => ({ timestamp: '2024-09-01T00:00:00.000Z', from, to, step: 'Harvested', signerMspId: 'Org1MSP' });
+        ctx.stub.putJSON('participant_P1', { docType: 'participant', participantId: 'P1', name: 'Farmer Zhang', role: 'Farmer', mspId: 'Org1MSP', location: 'Wuchang' });
+        ctx.stub.putJSON('participant_P2', {
+            docType: 'participant', participantId: 'P2', name: 'Farmer Li', role: 'Farmer', mspId: 'Org1MSP', location: 'Wuchang', registeredAt: '2024-09-01T00:00:00.000Z'
+        });
+        ctx.stub.putJSON('batch_clean', { docType: 'riceBatch', batchId: 'clean', currentOwner: 'Farmer Zhang', currentState: 'Harvested', history: [event('', 'Farmer Zhang')] });
+        ctx.stub.putJSON('batch_failed', { docType: 'riceBatch', batchId: 'failed', currentOwner: 'Farmer Zhang', currentState: 'Harvested', history: [event('', 'Farmer Zhang')] });
+        ctx.stub.putJSON('batch_newcomer', { docType: 'riceBatch', batchId: 'newcomer', currentOwner: 'Farmer Li', currentState: 'Harvested', history: [event('', 'Farmer Li')] });
+        ctx.stub.putJSON('batch_gone', { docType: 'riceBatch', batchId: 'gone', currentOwner: 'Farmer Zhang', currentState: 'Disposed', history: [event('', 'Farmer Zhang')] });
+        ctx.stub.putJSON('test_T1', { docType: 'testResult', testId: 'T1', batchId: 'failed', testType: 'Moisture', testResult: 'Failed' });
+        ctx.stub.putJSON('test_T2', { docType: 'testResult', testId: 'T2', batchId: 'failed', testType: 'Cadmium', testResult: 'Failed', revoked: true });
+    };
+
+    test('should weigh batches by failed tests and new owners and record a reproducible draw', async () => {
+        const ctx = createMockContext({ mspId: 'Org3MSP' });
+        putBatches(ctx);
+
+        const selection = await contract.SelectBatchesForInspection(ctx, '3', SEED);
+        expect(selection.weights).toEqual({ clean: 1, failed: 4, newcomer: 3 });
+        expect(selection.selected.map(candidate => candidate.batchId).sort()).toEqual(['clean', 'failed', 'newcomer']);
+        expect(selection.selected.find(candidate => candidate.batchId === 'failed')).toEqual(
+            { batchId: 'failed', weight: 4, failedTests: ['T1'], newParticipants: [] }
+        );
+        expect(selection.selected.find(candidate => candidate.batchId === 'newcomer')!.newParticipants).toEqual(['Farmer Li']);
+        expect(ctx.stub.events[0].name).toBe('InspectionSelected');
+        await expect(contract.GetInspectionSelection(ctx, SEED)).resolves.toEqual(selection);
+
+        // The same ledger and seed draw the same batches in the same order
+        const replay = createMockContext({ mspId: 'Org3MSP' });
+        putBatches(replay);
+        const again = await contract.SelectBatchesForInspection(replay, '3', SEED);
+        expect(again.selected).toEqual(selection.selected);
+
+        ctx.stub.nextTransaction();
+        await expect(contract.SelectBatchesForInspection(ctx, '1', SEED)).rejects.toThrow('already used');
+    });
+
+    test('should only let the regulator draw, with a valid seed and count', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        putBatches(ctx);
+        await expect(contract.SelectBatchesForInspection(ctx, '1', SEED)).rejects.toThrow('Permission denied');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        await expect(contract.SelectBatchesForInspection(ctx, '0', SEED)).rejects.toThrow('Invalid count');
+        await expect(contract.SelectBatchesForInspection(ctx, '1', 'tx1')).rejects.toThrow('committed transaction');
+        await expect(contract.GetInspectionSelections(ctx)).resolves.toEqual([]);
+    });
+});
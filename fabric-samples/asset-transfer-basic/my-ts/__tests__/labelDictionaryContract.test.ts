@@ -0,0 +1,54 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { LabelDictionaryContract } from '../src/labelDictionaryContract';
+import { createMockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+
+describe('LabelDictionaryContract', () => {
+    let contract: LabelDictionaryContract;
+
+    beforeEach(() => {
+        contract = new LabelDictionaryContract();
+    });
+
+    test('should keep translations and build the dictionary of a locale', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+
+        const milling = await contract.SetTranslation(ctx, 'value', 'Milling', JSON.stringify({ zh: ' 碾米 ', en: 'Milling' }));
+        expect(milling).toEqual(expect.objectContaining({
+            category: 'value', code: 'Milling', labels: { zh: '碾米', en: 'Milling' }, updatedBy: 'Org2MSP', lastUpdated: '2024-09-22T10:13:20.000Z'
+        }));
+        expect(ctx.stub.events[0].name).toBe('LabelTranslationChanged');
+        ctx.stub.nextTransaction();
+        await contract.SetTranslation(ctx, 'value', 'Grade 1', JSON.stringify({ zh: '一级' }));
+        ctx.stub.nextTransaction();
+        await contract.SetTranslation(ctx, 'field', 'grade', JSON.stringify({ zh: '等级', en: 'Grade' }));
+
+        await expect(contract.GetTranslations(ctx, 'value')).resolves.toHaveLength(2);
+        await expect(contract.GetTranslations(ctx, '')).resolves.toHaveLength(3);
+        await expect(contract.GetLabelDictionary(ctx, 'en')).resolves.toEqual({ locale: 'en', fields: { grade: 'Grade' }, values: { Milling: 'Milling' } });
+
+        ctx.stub.nextTransaction();
+        await contract.RemoveTranslation(ctx, 'value', 'Milling');
+        await expect(contract.GetLabelDictionary(ctx, 'zh')).resolves.toEqual({ locale: 'zh', fields: { grade: '等级' }, values: { 'Grade 1': '一级' } });
+        await expect(contract.RemoveTranslation(ctx, 'value', 'Milling')).rejects.toThrow('No translation of value Milling exists');
+    });
+
+    test('should validate translations and require an administrator', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+
+        await expect(contract.SetTranslation(ctx, 'unit', 'kg', JSON.stringify({ zh: '千克' }))).rejects.toThrow('Invalid label category: unit');
+        await expect(contract.SetTranslation(ctx, 'value', ' Packaged', JSON.stringify({ zh: '包装' }))).rejects.toThrow('without surrounding spaces');
+        await expect(contract.SetTranslation(ctx, 'value', 'Packaged', '{')).rejects.toThrow('Labels format error');
+        await expect(contract.SetTranslation(ctx, 'value', 'Packaged', JSON.stringify({ fr: 'Emballé' }))).rejects.toThrow('Unsupported locale: fr');
+        await expect(contract.SetTranslation(ctx, 'value', 'Packaged', JSON.stringify({ zh: ' ' }))).rejects.toThrow('The zh label must be text');
+        await expect(contract.SetTranslation(ctx, 'value', 'Packaged', '{}')).rejects.toThrow('At least one label');
+        await expect(contract.GetLabelDictionary(ctx, 'ja')).rejects.toThrow('Unsupported locale: ja');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await expect(contract.SetTranslation(ctx, 'value', 'Packaged', JSON.stringify({ zh: '包装' }))).rejects.toThrow('Only organization administrators');
+    });
+});
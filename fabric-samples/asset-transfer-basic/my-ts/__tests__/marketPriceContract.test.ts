@@ -0,0 +1,66 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { MarketPriceContract } from '../src/marketPriceContract';
+import { certificateFingerprint } from '../src/utils';
+import { createMockContext, TEST_CERT_PEM } from '../testing';
+
+describe('MarketPriceContract', () => {
+    let contract: MarketPriceContract;
+
+    beforeEach(() => {
+        contract = new MarketPriceContract();
+        // Fingerprints are often copied from openssl output, with colons and in upper case
+        const fingerprint = certificateFingerprint(TEST_CERT_PEM).toUpperCase().replace(/(..)(?!$)/g, '$1:');
+        process.env.RICETRACE_PRICE_ORACLES = `Org2MSP:${fingerprint}`;
+    });
+
+    afterEach(() => {
+        delete process.env.RICETRACE_PRICE_ORACLES;
+    });
+
+    const SOURCE = 'Heilongjiang Grain Exchange daily close';
+
+    test('should record prices from the designated oracle identity only', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+
+        await contract.RecordMarketPrice(ctx, 'Daohuaxiang', 'Heilongjiang', '2024-09-20', '5200.50', SOURCE);
+
+        await expect(contract.ReadMarketPrice(ctx, 'Daohuaxiang', 'Heilongjiang', '2024-09-20')).resolves.toEqual(expect.objectContaining({
+            price: 5200.5, source: SOURCE, recordedBy: 'Org2MSP', oracleFingerprint: certificateFingerprint(TEST_CERT_PEM)
+        }));
+        expect(ctx.stub.events[0].name).toBe('MarketPriceRecorded');
+        await expect(contract.RecordMarketPrice(ctx, 'Daohuaxiang', 'Heilongjiang', '2024-09-20', '5300', SOURCE))
+            .rejects.toThrow('already been recorded');
+
+        const otherCert = createMockContext({ mspId: 'Org2MSP', certPEM: '-----BEGIN CERTIFICATE-----\nAAED\n-----END CERTIFICATE-----\n' });
+        await expect(contract.RecordMarketPrice(otherCert, 'Daohuaxiang', 'Heilongjiang', '2024-09-21', '5200', SOURCE)).rejects.toThrow('Permission denied');
+        const otherOrg = createMockContext({ mspId: 'Org1MSP' });
+        await expect(contract.RecordMarketPrice(otherOrg, 'Daohuaxiang', 'Heilongjiang', '2024-09-21', '5200', SOURCE)).rejects.toThrow('Permission denied');
+    });
+
+    test('should serve the price series and the reference price in force on a day', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        for (const [date, price] of [['2024-09-13', '5000'], ['2024-09-19', '5100'], ['2024-09-20', '5200']]) {
+            await contract.RecordMarketPrice(ctx, 'Daohuaxiang', 'Heilongjiang', date, price, SOURCE);
+        }
+        await contract.RecordMarketPrice(ctx, 'Daohuaxiang', 'Jilin', '2024-09-21', '4900', SOURCE);
+
+        const series = await contract.GetMarketPriceSeries(ctx, 'Daohuaxiang', 'Heilongjiang', '2024-09-14', '');
+        expect(series.map(marketPrice => marketPrice.price)).toEqual([5100, 5200]);
+
+        // A Sunday settlement uses Friday's close
+        await expect(contract.GetReferencePrice(ctx, 'Daohuaxiang', 'Heilongjiang', '2024-09-22', '')).resolves.toEqual(expect.objectContaining({ date: '2024-09-20' }));
+        await expect(contract.GetReferencePrice(ctx, 'Daohuaxiang', 'Heilongjiang', '2024-09-18', '3')).rejects.toThrow('between 2024-09-15 and 2024-09-18');
+    });
+
+    test('should reject invalid prices and dates', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+
+        await expect(contract.RecordMarketPrice(ctx, 'Daohuaxiang', 'Heilongjiang', '2024-09-20', '-5', SOURCE)).rejects.toThrow('positive decimal');
+        await expect(contract.RecordMarketPrice(ctx, 'Daohuaxiang', 'Heilongjiang', '2024-09-20', '0', SOURCE)).rejects.toThrow('positive decimal');
+        await expect(contract.RecordMarketPrice(ctx, 'Daohuaxiang', 'Heilongjiang', '20/09/2024', '5200', SOURCE)).rejects.toThrow('expected YYYY-MM-DD');
+        await expect(contract.RecordMarketPrice(ctx, 'Daohuaxiang', 'Heilongjiang', '2024-09-30', '5200', SOURCE)).rejects.toThrow('future date');
+    });
+});
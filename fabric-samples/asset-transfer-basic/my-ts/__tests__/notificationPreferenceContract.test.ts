@@ -0,0 +1,62 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { NotificationPreferenceContract } from '../src/notificationPreferenceContract';
+import { createMockContext } from '../testing';
+
+describe('NotificationPreferenceContract', () => {
+    let contract: NotificationPreferenceContract;
+
+    beforeEach(() => {
+        contract = new NotificationPreferenceContract();
+    });
+
+    const preference = {
+        eventTypes: ['BatchQuarantined', 'BatchDisposed'],
+        batchIds: ['batch1'],
+        channels: [{ type: 'Email', target: 'quality@coop.example.com' }, { type: 'webhook', target: 'https://erp.example.com/hooks/alerts' }]
+    };
+
+    test('should register and replace the preferences of a participant', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+
+        await contract.SetNotificationPreference(ctx, 'farmer-zhang', JSON.stringify(preference));
+        expect(ctx.stub.events[0].name).toBe('NotificationPreferenceChanged');
+        await expect(contract.GetNotificationPreference(ctx, 'farmer-zhang')).resolves.toEqual(expect.objectContaining({
+            mspId: 'Org1MSP', eventTypes: ['BatchQuarantined', 'BatchDisposed'], version: 1,
+            channels: [{ type: 'email', target: 'quality@coop.example.com' }, { type: 'webhook', target: 'https://erp.example.com/hooks/alerts' }]
+        }));
+
+        ctx.stub.nextTransaction();
+        await contract.SetNotificationPreference(ctx, 'farmer-zhang', JSON.stringify({ channels: [{ type: 'sms', target: '+8613800138000' }] }));
+        await expect(contract.GetAllNotificationPreferences(ctx)).resolves.toEqual([
+            expect.objectContaining({ participantId: 'farmer-zhang', eventTypes: [], batchIds: [], version: 2 })
+        ]);
+
+        ctx.stub.nextTransaction();
+        await contract.RemoveNotificationPreference(ctx, 'farmer-zhang');
+        expect(ctx.stub.events[0]).toEqual({ name: 'NotificationPreferenceRemoved', payload: { participantId: 'farmer-zhang', mspId: 'Org1MSP' } });
+        await expect(contract.GetNotificationPreference(ctx, 'farmer-zhang')).rejects.toThrow('has no notification preferences');
+    });
+
+    test('should reject invalid channels and other organizations', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        ctx.stub.putJSON('participant_farmer-zhang', { docType: 'participant', participantId: 'farmer-zhang', name: 'Farmer Zhang', mspId: 'Org1MSP' });
+
+        await expect(contract.SetNotificationPreference(ctx, 'farmer-zhang', JSON.stringify(preference))).rejects.toThrow('belongs to Org1MSP');
+
+        await contract.SetNotificationPreference(ctx, 'mill-a', JSON.stringify(preference));
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        await expect(contract.RemoveNotificationPreference(ctx, 'mill-a')).rejects.toThrow('managed by Org2MSP');
+
+        await expect(contract.SetNotificationPreference(ctx, 'shop-b', JSON.stringify({ channels: [{ type: 'pager', target: '123' }] })))
+            .rejects.toThrow('Unknown notification channel pager');
+        await expect(contract.SetNotificationPreference(ctx, 'shop-b', JSON.stringify({ channels: [{ type: 'sms', target: '13800138000' }] })))
+            .rejects.toThrow('Invalid sms target');
+        await expect(contract.SetNotificationPreference(ctx, 'shop-b', JSON.stringify({ eventTypes: [''], channels: preference.channels })))
+            .rejects.toThrow('eventTypes must be an array of non-empty strings');
+        await expect(contract.SetNotificationPreference(ctx, 'shop-b', JSON.stringify({ channels: [] })))
+            .rejects.toThrow('At least one notification channel is required');
+    });
+});
@@ -0,0 +1,54 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { OrganizationBrandingContract } from '../src/organizationBrandingContract';
+import { createMockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+const LOGO_HASH = 'a3f5c1d2e4b6a8c0e2f4a6b8c0d2e4f6a8b0c2d4e6f8a0b2c4d6e8f0a2b4c6d8';
+
+describe('OrganizationBrandingContract', () => {
+    let contract: OrganizationBrandingContract;
+
+    beforeEach(() => {
+        contract = new OrganizationBrandingContract();
+    });
+
+    test('should register and replace the brand of the administrator\'s organization', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+
+        const branding = await contract.SetOrganizationBranding(ctx, JSON.stringify({
+            brandName: ' Wuchang Gold ', logoHash: LOGO_HASH, logoUri: 'ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi', story: 'Grown on black soil since 1835.'
+        }));
+        expect(branding).toEqual(expect.objectContaining({
+            mspId: 'Org2MSP', brandName: 'Wuchang Gold', logoHash: LOGO_HASH, story: 'Grown on black soil since 1835.',
+            lastUpdated: '2024-09-22T10:13:20.000Z'
+        }));
+        expect(ctx.stub.events[0].name).toBe('OrganizationBrandingChanged');
+
+        ctx.stub.nextTransaction();
+        await contract.SetOrganizationBranding(ctx, JSON.stringify({ brandName: 'Wuchang Gold Select' }));
+        const replaced = await contract.GetOrganizationBranding(ctx, 'Org2MSP');
+        expect(replaced.brandName).toBe('Wuchang Gold Select');
+        expect(replaced.logoUri).toBeUndefined();
+        await expect(contract.GetAllOrganizationBrandings(ctx)).resolves.toHaveLength(1);
+        await expect(contract.GetOrganizationBranding(ctx, 'Org1MSP')).rejects.toThrow('No branding is registered for Org1MSP');
+    });
+
+    test('should validate the branding and require an administrator', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+
+        await expect(contract.SetOrganizationBranding(ctx, '{')).rejects.toThrow('Branding format error');
+        await expect(contract.SetOrganizationBranding(ctx, JSON.stringify({ brandName: '' }))).rejects.toThrow('brandName is required');
+        await expect(contract.SetOrganizationBranding(ctx, JSON.stringify({ brandName: 'A', logoHash: 'abc' }))).rejects.toThrow('logoHash must be');
+        await expect(contract.SetOrganizationBranding(ctx, JSON.stringify({ brandName: 'A', logoHash: LOGO_HASH, logoUri: 'http://example.com/logo.png' })))
+            .rejects.toThrow('logoUri must be');
+        await expect(contract.SetOrganizationBranding(ctx, JSON.stringify({ brandName: 'A', logoUri: 'https://example.com/logo.png' })))
+            .rejects.toThrow('needs the logoHash');
+        await expect(contract.SetOrganizationBranding(ctx, JSON.stringify({ brandName: 'A', story: 'x'.repeat(2001) }))).rejects.toThrow('at most 2000');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await expect(contract.SetOrganizationBranding(ctx, JSON.stringify({ brandName: 'A' }))).rejects.toThrow('Only organization administrators');
+    });
+});
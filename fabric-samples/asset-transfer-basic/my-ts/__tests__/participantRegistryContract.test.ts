@@ -0,0 +1,42 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { ParticipantRegistryContract } from '../src/participantRegistryContract';
+import { createMockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org1.example.com::/C=US/ST=North Carolina/O=org1.example.com/CN=ca.org1.example.com';
+
+describe('ParticipantRegistryContract', () => {
+    let contract: ParticipantRegistryContract;
+
+    beforeEach(() => {
+        contract = new ParticipantRegistryContract();
+    });
+
+    test('should register an enrolled identity as the participant of its role', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP', attributes: { 'ricetrace.role': 'farmer', 'hf.EnrollmentID': 'farmer-zhang' } });
+
+        await expect(contract.RegisterParticipant(ctx, 'farmer-zhang', 'Farmer Zhang', 'farmer', 'Wuchang')).resolves.toEqual(expect.objectContaining({
+            mspId: 'Org1MSP', role: 'farmer', enrollmentId: 'farmer-zhang', registeredAt: '2024-09-22T10:13:20.000Z'
+        }));
+        expect(ctx.stub.events[0].name).toBe('ParticipantRegistered');
+        await expect(contract.GetParticipant(ctx, 'farmer-zhang')).resolves.toEqual(expect.objectContaining({ name: 'Farmer Zhang' }));
+
+        await expect(contract.RegisterParticipant(ctx, 'farmer-zhang', 'Farmer Zhang', 'farmer', '')).rejects.toThrow('already exists');
+        await expect(contract.RegisterParticipant(ctx, 'lab-1', 'Lab 1', 'tester', '')).rejects.toThrow('enrolled as farmer, not tester');
+        await expect(contract.RegisterParticipant(ctx, 'shop-1', 'Shop 1', 'retailer', '')).rejects.toThrow('Invalid participant role retailer');
+    });
+
+    test('should let only administrators register participants without a role attribute', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+
+        await expect(contract.RegisterParticipant(ctx, 'lab-1', 'Lab 1', 'tester', '')).rejects.toThrow('Permission denied');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await expect(contract.RegisterParticipant(ctx, 'lab-1', 'Lab 1', 'tester', '')).resolves.toEqual(expect.objectContaining({
+            mspId: 'Org2MSP', role: 'tester', enrollmentId: ''
+        }));
+        await expect(contract.GetParticipant(ctx, 'lab-2')).rejects.toThrow('does not exist');
+    });
+});
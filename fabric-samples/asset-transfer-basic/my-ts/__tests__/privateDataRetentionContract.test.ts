@@ -0,0 +1,89 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { PrivateDataRetentionContract } from '../src/privateDataRetentionContract';
+import { QualityCertificationContract } from '../src/qualityCertificationContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext, MockContext, TEST_TIMESTAMP_SECONDS } from '../testing';
+
+// SHA-256 of the lab report file registered with test results
+const REPORT_HASH = 'a3f5c1d2e4b6a8c0e2f4a6b8c0d2e4f6a8b0c2d4e6f8a0b2c4d6e8f0a2b4c6d8';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+
+describe('PrivateDataRetentionContract', () => {
+    let contract: PrivateDataRetentionContract;
+    let quality: QualityCertificationContract;
+
+    beforeEach(() => {
+        contract = new PrivateDataRetentionContract();
+        quality = new QualityCertificationContract();
+    });
+
+    const DAY_SECONDS = 24 * 60 * 60;
+
+    const shareReport = async (ctx: MockContext) => {
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', harvestDate: '2024-09-15T00:00:00.000Z', history: [] });
+        await quality.RecordSample(ctx, 'batch1', 'sample1', '500g', 'Inspector Li', 'Silo 3');
+        await quality.CreateTestResult(ctx, 'test1', 'batch1', 'sample1', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, '');
+        ctx.stub.nextTransaction();
+        ctx.stub.setTransient({ reportDetails: '{"moisture":13.8,"method":"GB/T 21305"}' });
+        await quality.SetTestReportDetails(ctx, 'batch1', 'test1', 'Org1MSP');
+        ctx.stub.nextTransaction();
+    };
+
+    test('should share report details privately and purge them after the retention period, keeping the hash', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await shareReport(ctx);
+
+        const test = ctx.stub.getJSON('test_test1');
+        expect(test.reportDetailsCollection).toBe('testReports_Org1MSP_Org2MSP');
+        expect(test.reportDetailsHash).toMatch(/^[0-9a-f]{64}$/);
+        await expect(quality.ReadTestReportDetails(ctx, 'batch1', 'test1')).resolves.toEqual(expect.objectContaining({
+            details: '{"moisture":13.8,"method":"GB/T 21305"}', counterpartyMspId: 'Org1MSP'
+        }));
+
+        await contract.DefineRetentionPolicy(ctx, 'testReportDetails', '30');
+        await expect(contract.PurgePrivateData(ctx, 'testReportDetails', '')).resolves.toEqual(expect.objectContaining({ purged: [], remaining: 0 }));
+
+        ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS + 31 * DAY_SECONDS);
+        const purge = await contract.PurgePrivateData(ctx, 'testReportDetails', '');
+        expect(purge).toEqual(expect.objectContaining({ mspId: 'Org2MSP', purged: ['test1'], remaining: 0 }));
+        expect(ctx.stub.events[0].name).toBe('PrivateDataPurged');
+        expect(ctx.stub.privateData.get('testReports_Org1MSP_Org2MSP')?.has('report_test1')).toBe(false);
+        expect(ctx.stub.getJSON('test_test1')).toEqual(expect.objectContaining({ reportDetailsHash: test.reportDetailsHash, reportDetailsPurgedAt: purge.purgedAt }));
+        await expect(quality.ReadTestReportDetails(ctx, 'batch1', 'test1')).rejects.toThrow('only their hash remains');
+    });
+
+    test('should purge commercial terms older than a cutoff and keep the public commitment', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID.replace(/org2/g, 'org1') });
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farmer Zhang', history: [{ to: 'Farmer Zhang', signerMspId: 'Org1MSP' }] });
+        ctx.stub.setTransient({ terms: 'CNY 5.20/kg, net 30' });
+        await new RiceTracerContract().SetCommercialTerms(ctx, 'batch1');
+        ctx.stub.nextTransaction();
+
+        await expect(contract.PurgePrivateData(ctx, 'commercialTerms', '')).rejects.toThrow('no retention policy');
+        await expect(contract.PurgePrivateData(ctx, 'accessAudit', '2024-09-01')).rejects.toThrow('Invalid data type');
+        await expect(contract.PurgePrivateData(ctx, 'commercialTerms', '2030-01-01')).rejects.toThrow('in the future');
+
+        const purge = await contract.PurgePrivateData(ctx, 'commercialTerms', '2024-09-22');
+        expect(purge.purged).toEqual(['batch1']);
+        expect(ctx.stub.privateData.get('_implicit_org_Org1MSP')?.has('terms_batch1')).toBe(false);
+        expect(ctx.stub.getJSON('terms_batch1_Org1MSP')).toEqual(expect.objectContaining({ termsHash: expect.any(String), purgedAt: purge.purgedAt }));
+        await expect(new RiceTracerContract().VerifyCommercialTerms(ctx, 'batch1', 'Org1MSP', 'CNY 5.20/kg, net 30')).resolves.toBe(true);
+    });
+
+    test('should only let organization administrators define policies and purge', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        await expect(contract.DefineRetentionPolicy(ctx, 'testReportDetails', '30')).rejects.toThrow('administrator');
+        await expect(contract.PurgePrivateData(ctx, 'testReportDetails', '2024-09-01')).rejects.toThrow('administrator');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await expect(contract.DefineRetentionPolicy(ctx, 'testReportDetails', '0')).rejects.toThrow('Invalid retention period');
+        await contract.DefineRetentionPolicy(ctx, 'commercialTerms', '365');
+        await expect(contract.GetRetentionPolicies(ctx)).resolves.toEqual([
+            expect.objectContaining({ mspId: 'Org2MSP', dataType: 'commercialTerms', retentionDays: 365 })
+        ]);
+    });
+});
@@ -0,0 +1,146 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { ProcessingWorkflowContract } from '../src/processingWorkflowContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext, MockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+const CLIENT_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=client/CN=User1@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+const ORG1_ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org1.example.com::/C=US/ST=North Carolina/O=org1.example.com/CN=ca.org1.example.com';
+
+describe('ProcessingWorkflowContract', () => {
+    let contract: ProcessingWorkflowContract;
+
+    beforeEach(() => {
+        contract = new ProcessingWorkflowContract();
+    });
+
+    const millWorkflow = JSON.stringify({
+        name: 'Standard mill',
+        steps: [
+            { name: 'Dried' },
+            { name: 'Stored', optional: true },
+            { name: 'Milling', requiredTests: ['Moisture'] },
+            { name: 'Packaged' }
+        ]
+    });
+
+    test('should store a workflow defined by an organization admin', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+
+        await contract.DefineWorkflow(ctx, 'mill-a', millWorkflow);
+        const workflow = await contract.ReadWorkflow(ctx, 'mill-a');
+
+        expect(workflow.version).toBe(1);
+        expect(workflow.definedBy).toBe('Org2MSP');
+        expect(workflow.steps.map(step => step.name)).toEqual(['Dried', 'Stored', 'Milling', 'Packaged']);
+        expect(workflow.steps[1].optional).toBe(true);
+        expect(workflow.steps[2].requiredTests).toEqual(['Moisture']);
+    });
+
+    test('should increment the version when a workflow is redefined', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+
+        await contract.DefineWorkflow(ctx, 'mill-a', millWorkflow);
+        await contract.DefineWorkflow(ctx, 'mill-a', millWorkflow);
+
+        expect((await contract.ReadWorkflow(ctx, 'mill-a')).version).toBe(2);
+    });
+
+    test('should only let the defining organization redefine a workflow', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await contract.DefineWorkflow(ctx, 'mill-a', millWorkflow);
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP', id: ORG1_ADMIN_ID });
+        await expect(contract.DefineWorkflow(ctx, 'mill-a', JSON.stringify({ steps: [{ name: 'Packaged' }] })))
+            .rejects.toThrow('Workflow mill-a was defined by Org2MSP; Org1MSP cannot redefine it');
+
+        const workflow = await contract.ReadWorkflow(ctx, 'mill-a');
+        expect(workflow.definedBy).toBe('Org2MSP');
+        expect(workflow.version).toBe(1);
+    });
+
+    describe('Workflow Enforcement', () => {
+        const report = JSON.stringify({ reportId: 'r1', reportType: 'Processing', reportHash: '', summary: 'Done', isVerified: false });
+
+        const putBatch = (ctx: MockContext, steps: string[]) => {
+            ctx.stub.putJSON('batch_batch1', {
+                docType: 'riceBatch', batchId: 'batch1', workflowId: 'mill-a', currentOwner: 'Mill A', currentState: steps[steps.length - 1],
+                history: steps.map(step => ({ timestamp: '2024-09-01T00:00:00.000Z', from: 'Mill A', to: 'Mill A', step, signerMspId: 'Org2MSP' }))
+            });
+        };
+
+        test('should reject a step the batch has already moved past', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+            await contract.DefineWorkflow(ctx, 'mill-a', millWorkflow);
+            putBatch(ctx, ['Dried', 'Milling']);
+
+            await expect(new RiceTracerContract().CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Mill A', 'Dried', report, ''))
+                .rejects.toThrow('Step Dried cannot be recorded: batch batch1 has already reached Milling in workflow mill-a');
+        });
+
+        test('should reject a step that skips a required step', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+            await contract.DefineWorkflow(ctx, 'mill-a', millWorkflow);
+            putBatch(ctx, ['Dried']);
+
+            await expect(new RiceTracerContract().CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Mill A', 'Packaged', report, ''))
+                .rejects.toThrow('required step(s) Milling of workflow mill-a have not been completed');
+            await expect(new RiceTracerContract().CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Mill A', 'Polished', report, ''))
+                .rejects.toThrow('Step Polished is not part of workflow mill-a');
+        });
+
+        test('should keep a batch on the workflow definition it was created with', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+            const riceTracer = new RiceTracerContract();
+            const create = (batchId: string) => riceTracer.CreateRiceBatch(
+                ctx, batchId, 'Heilongjiang', 'Japonica', '2024-09-15', '{}', 'Farmer Zhang', 'Dried', 'Farmer Zhang', 'mill-a', '', '', ''
+            );
+            await contract.DefineWorkflow(ctx, 'mill-a', millWorkflow);
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP', id: ORG1_ADMIN_ID });
+            await create('batch1');
+            expect(ctx.stub.getJSON('batch_batch1')).toEqual(expect.objectContaining({ workflowId: 'mill-a', workflowVersion: 1 }));
+
+            // Redefined without the optional Stored step while batch1 is in progress
+            ctx.stub.nextTransaction();
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', id: ADMIN_ID });
+            await contract.DefineWorkflow(ctx, 'mill-a', JSON.stringify({ steps: [{ name: 'Dried' }, { name: 'Sorted' }, { name: 'Packaged' }] }));
+
+            ctx.stub.nextTransaction();
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP', id: ORG1_ADMIN_ID });
+            await riceTracer.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Stored', report, '');
+            expect(ctx.stub.getJSON('batch_batch1').currentState).toBe('Stored');
+
+            // Batches created after the redefinition follow the new steps
+            ctx.stub.nextTransaction();
+            await create('batch2');
+            expect(ctx.stub.getJSON('batch_batch2').workflowVersion).toBe(2);
+            ctx.stub.nextTransaction();
+            await expect(riceTracer.CompleteStepAndTransfer(ctx, 'batch2', 'Farmer Zhang', 'Farmer Zhang', 'Stored', report, ''))
+                .rejects.toThrow('Step Stored is not part of workflow mill-a');
+        });
+
+        test('should reject a batch referencing an undefined workflow', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            await expect(new RiceTracerContract().CreateRiceBatch(
+                ctx, 'batch1', 'Heilongjiang', 'Japonica', '2024-09-15', '{}', 'Farmer Zhang', 'Dried', 'Farmer Zhang', 'mill-b', '', '', ''
+            )).rejects.toThrow('Workflow mill-b referenced by batch batch1 does not exist');
+        });
+    });
+
+    test('should reject non-admin callers', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: CLIENT_ID });
+        await expect(contract.DefineWorkflow(ctx, 'mill-a', millWorkflow)).rejects.toThrow('Permission denied');
+    });
+
+    test('should reject invalid step definitions', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+
+        await expect(contract.DefineWorkflow(ctx, 'empty', JSON.stringify({ steps: [] })))
+            .rejects.toThrow('at least one step');
+        await expect(contract.DefineWorkflow(ctx, 'dup', JSON.stringify({ steps: [{ name: 'Dried' }, { name: 'Dried' }] })))
+            .rejects.toThrow('defined more than once');
+    });
+});
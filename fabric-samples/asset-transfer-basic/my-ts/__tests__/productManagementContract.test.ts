@@ -4,6 +4,8 @@
 
 import { ProductManagementContract } from '../src/productManagementContract';
 import { OrganizationType } from '../src/types';
+import { KeyEndorsementPolicy } from 'fabric-shim';
+import { createMockContext, MockContext, TEST_TIMESTAMP_SECONDS } from '../testing';
 
 describe('ProductManagementContract', () => {
     let contract: ProductManagementContract;
@@ -169,4 +171,460 @@ describe('ProductManagementContract', () => {
             }).toThrow('Batch ID must be at least 3 characters');
         });
     });
+
+    describe('Product Returns', () => {
+        const storeProduct = (ctx: MockContext) => {
+            ctx.stub.putJSON('product_product123', {
+                docType: 'product',
+                productId: 'product123',
+                batchId: 'batch123',
+                packageDate: '2024-10-01T00:00:00.000Z',
+                owner: 'Distributor A',
+                ownerMspId: 'Org2MSP',
+                status: 'Active',
+                transfers: []
+            });
+        };
+        const readProduct = (ctx: MockContext) => ctx.stub.getJSON('product_product123');
+
+        test('should return a sold product to the distributor that sold it', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+
+            await contract.TransferProduct(ctx, 'product123', 'Retailer B', 'Org3MSP', '');
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+            await contract.ReturnProduct(ctx, 'product123', 'Damaged packaging', false, '');
+
+            const product = readProduct(ctx);
+            expect(product.owner).toBe('Distributor A');
+            expect(product.ownerMspId).toBe('Org2MSP');
+            expect(product.status).toBe('Returned');
+            expect(product.transfers[1]).toEqual(expect.objectContaining({
+                from: 'Retailer B', to: 'Distributor A', type: 'Return', reason: 'Damaged packaging'
+            }));
+            expect(ctx.stub.hasCompositeKey('owner~productId', ['Retailer B', 'product123'])).toBe(false);
+            expect(ctx.stub.hasCompositeKey('owner~productId', ['Distributor A', 'product123'])).toBe(true);
+        });
+
+        test('should reject sales and returns backdated before the previous transfer', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+
+            await contract.TransferProduct(ctx, 'product123', 'Retailer B', 'Org3MSP', '');
+            ctx.stub.nextTransaction();
+            ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS - 3600);
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+            await expect(contract.ReturnProduct(ctx, 'product123', 'Damaged packaging', false, ''))
+                .rejects.toThrow('Transfer time (2024-09-22T09:13:20.000Z) cannot be earlier than previous Sale of product product123 (2024-09-22T10:13:20.000Z)');
+
+            ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS + 3600);
+            await contract.ReturnProduct(ctx, 'product123', 'Damaged packaging', false, '');
+            ctx.stub.nextTransaction();
+            ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS);
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+            await expect(contract.TransferProduct(ctx, 'product123', 'Retailer C', 'Org3MSP', ''))
+                .rejects.toThrow('Transfer time (2024-09-22T10:13:20.000Z) cannot be earlier than previous Return of product product123 (2024-09-22T11:13:20.000Z)');
+            expect(readProduct(ctx).transfers).toHaveLength(2);
+        });
+
+        test('should only let the organization holding a product return it', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+
+            await contract.TransferProduct(ctx, 'product123', 'Retailer B', 'Org3MSP', '');
+            await expect(contract.ReturnProduct(ctx, 'product123', 'Damaged packaging', false, ''))
+                .rejects.toThrow('Permission denied: Product product123 is held by Org3MSP');
+            expect(readProduct(ctx).owner).toBe('Retailer B');
+        });
+
+        test('should block resale until a flagged product passes re-inspection', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+
+            await contract.TransferProduct(ctx, 'product123', 'Retailer B', 'Org3MSP', '');
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+            await contract.ReturnProduct(ctx, 'product123', 'Suspected moisture damage', true, '');
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+            await expect(contract.TransferProduct(ctx, 'product123', 'Retailer C', '', '')).rejects.toThrow('must be re-inspected');
+
+            await contract.ClearReinspection(ctx, 'product123', 'Failed', 'Mould on two bags');
+            expect(readProduct(ctx).requiresReinspection).toBe(true);
+            await expect(contract.TransferProduct(ctx, 'product123', 'Retailer C', '', '')).rejects.toThrow('must be re-inspected');
+
+            await contract.ClearReinspection(ctx, 'product123', 'Passed', '');
+            expect(readProduct(ctx).reinspections).toEqual([
+                expect.objectContaining({ outcome: 'Failed', notes: 'Mould on two bags', inspectedByMspId: 'Org2MSP', inspectedBySubject: '/OU=client/CN=User1@Org2MSP' }),
+                expect.objectContaining({ outcome: 'Passed', inspectedByMspId: 'Org2MSP', timestamp: '2024-09-22T10:13:20.000Z' })
+            ]);
+            await contract.TransferProduct(ctx, 'product123', 'Retailer C', '', '');
+            expect(readProduct(ctx).owner).toBe('Retailer C');
+        });
+
+        test('should only let the holding organization record a re-inspection', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+            ctx.stub.putJSON('product_product123', { ...readProduct(ctx), ownerMspId: 'Org4MSP', requiresReinspection: true });
+
+            await expect(contract.ClearReinspection(ctx, 'product123', 'Passed', ''))
+                .rejects.toThrow('Permission denied: Product product123 is held by Org4MSP');
+            await expect(contract.ClearReinspection(ctx, 'product123', 'Fine', ''))
+                .rejects.toThrow('Invalid re-inspection outcome: Fine');
+            expect(readProduct(ctx).requiresReinspection).toBe(true);
+        });
+
+        test('should reject returns of unsold products or without a reason', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+
+            await expect(contract.ReturnProduct(ctx, 'product123', 'Changed mind', false, '')).rejects.toThrow('has not been sold');
+            await expect(contract.ReturnProduct(ctx, 'product123', '', false, '')).rejects.toThrow('Return reason is required');
+        });
+    });
+
+    describe('Product Endorsement Policy', () => {
+        let addOrgs: any;
+
+        beforeEach(() => {
+            addOrgs = jest.spyOn(KeyEndorsementPolicy.prototype, 'addOrgs');
+        });
+
+        afterEach(() => {
+            addOrgs.mockRestore();
+            delete process.env.RICETRACE_PRODUCT_ORIGINATOR_ENDORSEMENT;
+        });
+
+        const storeProduct = (ctx: MockContext) => {
+            ctx.stub.putJSON('product_product123', {
+                docType: 'product',
+                productId: 'product123',
+                batchId: 'batch123',
+                owner: 'Distributor A',
+                ownerMspId: 'Org2MSP',
+                originMspId: 'Org1MSP',
+                status: 'Active',
+                transfers: []
+            });
+        };
+
+        test('should hand endorsement of a sold product to the buyer\'s organization and back on return', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+
+            await contract.TransferProduct(ctx, 'product123', 'Retailer B', 'Org3MSP', '');
+            expect(addOrgs).toHaveBeenLastCalledWith('MEMBER', 'Org3MSP');
+            expect(ctx.stub.validationParameters.has('product_product123')).toBe(true);
+            expect(ctx.stub.getJSON('product_product123').transfers[0]).toEqual(expect.objectContaining({
+                fromMspId: 'Org2MSP', toMspId: 'Org3MSP'
+            }));
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+            await contract.ReturnProduct(ctx, 'product123', 'Damaged packaging', false, '');
+            expect(addOrgs).toHaveBeenLastCalledWith('MEMBER', 'Org2MSP');
+            expect(ctx.stub.getJSON('product_product123').ownerMspId).toBe('Org2MSP');
+        });
+
+        test('should require the batch originator as well when configured', async () => {
+            process.env.RICETRACE_PRODUCT_ORIGINATOR_ENDORSEMENT = 'true';
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+
+            await contract.TransferProduct(ctx, 'product123', 'Retailer B', 'Org3MSP', '');
+            expect(addOrgs).toHaveBeenLastCalledWith('MEMBER', 'Org1MSP', 'Org3MSP');
+        });
+
+        test('should leave products without a recorded owning organization on the chaincode policy', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('product_legacy', {
+                docType: 'product', productId: 'legacy', batchId: 'batch123', owner: 'Distributor A', transfers: []
+            });
+
+            await contract.TransferProduct(ctx, 'legacy', 'Retailer B', '', '');
+            expect(ctx.stub.setStateValidationParameter).not.toHaveBeenCalled();
+        });
+    });
+
+    describe('Idempotent Retries', () => {
+        test('should apply a retried transfer only once', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('product_product123', {
+                docType: 'product',
+                productId: 'product123',
+                batchId: 'batch123',
+                owner: 'Distributor A',
+                status: 'Active',
+                transfers: []
+            });
+
+            await contract.TransferProduct(ctx, 'product123', 'Retailer B', '', 'req-1');
+            ctx.stub.nextTransaction();
+            await contract.TransferProduct(ctx, 'product123', 'Retailer B', '', 'req-1');
+
+            const product = ctx.stub.getJSON('product_product123');
+            expect(product.transfers).toHaveLength(1);
+            expect(ctx.stub.events).toHaveLength(0);
+            expect(ctx.stub.getJSON('request_Org2MSP_req-1')).toEqual(expect.objectContaining({
+                operation: 'TransferProduct', txId: 'tx1'
+            }));
+        });
+
+        test('should reject a request ID reused for another operation', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('request_Org2MSP_req-1', { docType: 'processedRequest', clientRequestId: 'req-1', operation: 'CreateProduct' });
+
+            await expect(contract.TransferProduct(ctx, 'product123', 'Retailer B', '', 'req-1'))
+                .rejects.toThrow('Client request ID req-1 was already used for CreateProduct');
+        });
+    });
+
+    describe('Product Disposal', () => {
+        test('should remove a disposed product from its owner\'s inventory', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            ctx.stub.putJSON('product_product123', {
+                docType: 'product',
+                productId: 'product123',
+                batchId: 'batch123',
+                packageDate: '2024-10-01T00:00:00.000Z',
+                owner: 'Retailer B',
+                status: 'Sold',
+                transfers: []
+            });
+            ctx.stub.state.set(ctx.stub.createCompositeKey('owner~productId', ['Retailer B', 'product123']), Buffer.from([0x00]));
+
+            await contract.DisposeProduct(ctx, 'product123', 'Past expiry', 'Composting', '5kg', 'Retailer B');
+
+            const product = ctx.stub.getJSON('product_product123');
+            expect(product.status).toBe('Disposed');
+            expect(product.disposal.method).toBe('Composting');
+            expect(ctx.stub.hasCompositeKey('owner~productId', ['Retailer B', 'product123'])).toBe(false);
+            await expect(contract.ReturnProduct(ctx, 'product123', 'Damaged', false, '')).rejects.toThrow('has been disposed of');
+        });
+
+        test.each([
+            ['Org1MSP', false],
+            ['Org2MSP', true],
+            ['Org3MSP', true]
+        ])('should only let product holders dispose of products (%s allowed: %s)', async (mspId: string, allowed: boolean) => {
+            const ctx = createMockContext({ mspId });
+            ctx.stub.putJSON('product_product123', {
+                docType: 'product',
+                productId: 'product123',
+                batchId: 'batch123',
+                owner: 'Retailer B',
+                status: 'Sold',
+                transfers: []
+            });
+
+            const dispose = contract.DisposeProduct(ctx, 'product123', 'Past expiry', 'Composting', '5kg', 'Retailer B');
+            if (allowed) {
+                await dispose;
+                expect(ctx.stub.events).toEqual([
+                    { name: 'ProductDisposed', payload: expect.objectContaining({ productId: 'product123' }) }
+                ]);
+            } else {
+                await expect(dispose).rejects.toThrow('Permission denied');
+                expect(ctx.stub.events).toHaveLength(0);
+            }
+        });
+    });
+
+    describe('Nutrition and Composition', () => {
+        // Typical polished japonica rice per 100 g
+        const RICE_NUTRITION = { energyKj: 1460, proteinG: 7.4, carbohydrateG: 77.9, fatG: 0.8, sodiumMg: 2 };
+        const RICE_COMPOSITION = { ingredients: ['Japonica rice (100%)'], allergens: [], netWeightG: 5000, grade: 'Grade 1 (GB/T 1354)' };
+
+        const storeProduct = (ctx: MockContext) => {
+            ctx.stub.putJSON('product_product123', {
+                docType: 'product',
+                productId: 'product123',
+                batchId: 'batch123',
+                owner: 'Distributor A',
+                status: 'Active',
+                transfers: []
+            });
+        };
+
+        test('should store plausible nutrition facts and composition on the product', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+
+            await contract.SetProductNutrition(ctx, 'product123', JSON.stringify(RICE_NUTRITION), JSON.stringify(RICE_COMPOSITION));
+
+            const product = ctx.stub.getJSON('product_product123');
+            expect(product.nutrition).toEqual(RICE_NUTRITION);
+            expect(product.composition).toEqual(RICE_COMPOSITION);
+            expect(product.owner).toBe('Distributor A');
+        });
+
+        test.each([
+            [{ ...RICE_NUTRITION, proteinG: -1 }, 'proteinG must be a non-negative number'],
+            [{ energyKj: 1460, proteinG: 7.4 }, 'carbohydrateG is required'],
+            [{ energyKj: 2000, proteinG: 30, carbohydrateG: 80 }, 'more than the 100 g'],
+            [{ ...RICE_NUTRITION, energyKj: 600 }, 'does not match'],
+            [{ ...RICE_NUTRITION, sodiumMg: 50000 }, 'implausible']
+        ])('should reject implausible nutrition facts %#', async (nutrition: object, message: string) => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+
+            await expect(contract.SetProductNutrition(ctx, 'product123', JSON.stringify(nutrition), '')).rejects.toThrow(message);
+            expect(ctx.stub.getJSON('product_product123').nutrition).toBeUndefined();
+        });
+
+        test('should validate composition and permissions', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeProduct(ctx);
+
+            await expect(contract.SetProductNutrition(ctx, 'product123', '', JSON.stringify({ ingredients: [] }))).rejects.toThrow('at least one ingredient');
+            await expect(contract.SetProductNutrition(ctx, 'product123', '', '')).rejects.toThrow('Nutrition facts or composition is required');
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+            await expect(contract.SetProductNutrition(ctx, 'product123', JSON.stringify(RICE_NUTRITION), '')).rejects.toThrow('Permission denied');
+        });
+    });
+
+    describe('Origin Composition', () => {
+        test('should attribute the product to its batch and list linked sources without shares', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            ctx.stub.putJSON('product_product123', { docType: 'product', productId: 'product123', batchId: 'batch123', owner: 'Shop B', status: 'Active' });
+            ctx.stub.putJSON('batch_batch123', {
+                docType: 'riceBatch', batchId: 'batch123', origin: 'Wuchang, Heilongjiang', variety: 'Daohuaxiang 2', harvestDate: '2024-09-20',
+                history: [{ step: 'Harvested', to: 'Farm A', signerMspId: 'Org1MSP' }, { step: 'Packaged', to: 'Mill A', signerMspId: 'Org2MSP' }],
+                foreignReferences: [{ channel: 'channel2', batchId: 'import1', summary: { origin: 'Niigata', variety: 'Koshihikari', harvestDate: '2024-09-01' } }]
+            });
+
+            const composition = await contract.GetProductComposition(ctx, 'product123');
+
+            expect(composition.contributions).toEqual([{
+                channel: 'channel1', batchId: 'batch123', origin: 'Wuchang, Heilongjiang', variety: 'Daohuaxiang 2',
+                harvestDate: '2024-09-20', farm: 'Farm A', farmMspId: 'Org1MSP', percentage: 100
+            }]);
+            expect(composition.linkedSources).toEqual([{
+                channel: 'channel2', batchId: 'import1', origin: 'Niigata', variety: 'Koshihikari', harvestDate: '2024-09-01', farm: ''
+            }]);
+            await expect(contract.GetProductComposition(ctx, 'missing')).rejects.toThrow('does not exist');
+        });
+    });
+
+    describe('Product Queries', () => {
+        const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+
+        const storeProducts = async (ctx: MockContext) => {
+            const product = (productId: string, batchId: string, packageDate: string, owner: string, status: string, bestBefore?: string) =>
+                ctx.stub.putJSON(`product_${productId}`, {
+                    docType: 'product', productId, batchId, packageDate, owner, status, transfers: [],
+                    ...(bestBefore ? { composition: { ingredients: ['Japonica rice (100%)'], bestBefore } } : {})
+                });
+            product('P1', 'batch1', '2024-09-01T00:00:00.000Z', 'Distributor A', 'Active', '2025-09-01T00:00:00.000Z');
+            product('P2', 'batch1', '2024-09-10T00:00:00.000Z', 'Retailer B', 'Sold', '2024-01-01T00:00:00.000Z');
+            product('P3', 'batch2', '2024-09-15T00:00:00.000Z', 'Distributor A', 'Active');
+            product('P4', 'batch2', '2024-09-20T00:00:00.000Z', 'Distributor A', 'Disposed');
+            ctx.stub.state.set(ctx.stub.createCompositeKey('bestBefore~productId', ['2024-01-01T00:00:00.000Z', 'P2']), Buffer.from([0x00]));
+            ctx.stub.state.set(ctx.stub.createCompositeKey('bestBefore~productId', ['2025-09-01T00:00:00.000Z', 'P1']), Buffer.from([0x00]));
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', id: ADMIN_ID });
+            await contract.RebuildOwnerIndex(ctx);
+            await expect(contract.RebuildProductQueryIndexes(ctx)).resolves.toBe(4);
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        };
+
+        const query = async (ctx: MockContext, selector: object, pageSize = 10, bookmark = '') =>
+            contract.QueryProducts(ctx, JSON.stringify(selector), pageSize, bookmark);
+        const ids = (result: { products: { productId: string }[] }) => result.products.map(product => product.productId);
+
+        test('should combine owner, batch, status and package date selectors', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            await storeProducts(ctx);
+
+            expect(ids(await query(ctx, {}))).toEqual(['P1', 'P2', 'P3', 'P4']);
+            expect(ids(await query(ctx, { batchId: 'batch2' }))).toEqual(['P3', 'P4']);
+            expect(ids(await query(ctx, { owner: 'Distributor A' }))).toEqual(['P1', 'P3']);
+            expect(ids(await query(ctx, { owner: 'Distributor A', packageDateFrom: '2024-09-05' }))).toEqual(['P3']);
+            expect(ids(await query(ctx, { status: 'disposed' }))).toEqual(['P4']);
+            expect(ids(await query(ctx, { status: 'Expired' }))).toEqual(['P2']);
+            expect(ids(await query(ctx, { packageDateFrom: '2024-09-10', packageDateTo: '2024-09-15' }))).toEqual(['P2', 'P3']);
+        });
+
+        test('should page through results and keep the status index current', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            await storeProducts(ctx);
+
+            const first = await query(ctx, {}, 3);
+            expect(ids(first)).toEqual(['P1', 'P2', 'P3']);
+            expect(ids(await query(ctx, {}, 3, first.bookmark))).toEqual(['P4']);
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+            await contract.TransferProduct(ctx, 'P1', 'Retailer C', '', '');
+            expect(ids(await query(ctx, { status: 'Sold' }))).toEqual(['P1', 'P2']);
+            expect(ids(await query(ctx, { status: 'Active' }))).toEqual(['P3']);
+        });
+
+        test('should list the products an owner holds one page at a time', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            await storeProducts(ctx);
+
+            const first = await contract.GetProductsByOwner(ctx, 'Distributor A', 1, '');
+            expect(ids(first)).toEqual(['P1']);
+            expect(first.fetchedRecordsCount).toBe(1);
+            expect(first.bookmark).not.toBe('');
+            const second = await contract.GetProductsByOwner(ctx, 'Distributor A', 1, first.bookmark);
+            expect(ids(second)).toEqual(['P3']);
+            expect(second.bookmark).toBe('');
+            // Disposed products have left the owner's inventory
+            expect(ids(await contract.GetProductsByOwner(ctx, 'Distributor A', 10, ''))).toEqual(['P1', 'P3']);
+            expect(ids(await contract.GetProductsByOwner(ctx, 'Nobody', 10, ''))).toEqual([]);
+
+            await expect(contract.GetProductsByOwner(ctx, '', 10, '')).rejects.toThrow('Owner is required');
+            await expect(contract.GetProductsByOwner(ctx, 'Distributor A', 0, '')).rejects.toThrow('Invalid page size 0');
+            await expect(contract.GetProductsByOwner(ctx, 'Distributor A', 1001, '')).rejects.toThrow('Invalid page size 1001');
+        });
+
+        test('should move a sold product to its buyer in the owner index', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            await storeProducts(ctx);
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+            await contract.TransferProduct(ctx, 'P3', 'Retailer B', 'Org3MSP', '');
+
+            expect(ctx.stub.hasCompositeKey('owner~productId', ['Distributor A', 'P3'])).toBe(false);
+            expect(ctx.stub.hasCompositeKey('owner~productId', ['Retailer B', 'P3'])).toBe(true);
+            expect(ids(await contract.GetProductsByOwner(ctx, 'Distributor A', 10, ''))).toEqual(['P1']);
+            expect(ids(await contract.GetProductsByOwner(ctx, 'Retailer B', 10, ''))).toEqual(['P2', 'P3']);
+            expect(ids(await query(ctx, { owner: 'Retailer B', batchId: 'batch2' }))).toEqual(['P3']);
+        });
+
+        test('should skip stale owner index entries', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            await storeProducts(ctx);
+            ctx.stub.state.set(ctx.stub.createCompositeKey('owner~productId', ['Retailer B', 'P1']), Buffer.from([0x00]));
+
+            const result = await contract.GetProductsByOwner(ctx, 'Retailer B', 10, '');
+            expect(ids(result)).toEqual(['P2']);
+            expect(result.fetchedRecordsCount).toBe(2);
+        });
+
+        test('should reject invalid selectors', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+
+            await expect(query(ctx, { status: 'Recalled' })).rejects.toThrow('Invalid product status Recalled');
+            await expect(query(ctx, { color: 'white' })).rejects.toThrow('Unknown product selector(s): color');
+            await expect(query(ctx, { packageDateFrom: '2024-09-20', packageDateTo: '2024-09-10' })).rejects.toThrow('must not be after');
+            await expect(query(ctx, {}, 0)).rejects.toThrow('Invalid page size');
+        });
+    });
+
+    describe('Labels', () => {
+        test('should label products and find them by label', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('product_product123', { docType: 'product', productId: 'product123', batchId: 'batch123', owner: 'Distributor A', status: 'Active' });
+
+            await contract.SetProductLabels(ctx, 'product123', JSON.stringify({ 'retail-sku': 'DHX-5KG' }));
+            expect(ctx.stub.events[0].name).toBe('ProductLabelsChanged');
+            await expect(contract.GetProductsByLabel(ctx, 'retail-sku', 'DHX-5KG')).resolves.toEqual([
+                expect.objectContaining({ productId: 'product123', labels: { 'retail-sku': 'DHX-5KG' } })
+            ]);
+
+            await contract.SetProductLabels(ctx, 'product123', '{}');
+            await expect(contract.GetProductsByLabel(ctx, 'retail-sku', '')).resolves.toEqual([]);
+            await expect(contract.GetProductsByLabel(ctx, '', '')).rejects.toThrow('Label key is required');
+        });
+    });
 }); 
\ No newline at end of file
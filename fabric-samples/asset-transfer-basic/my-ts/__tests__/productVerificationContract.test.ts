@@ -0,0 +1,106 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { ProductVerificationContract } from '../src/productVerificationContract';
+import { createMockContext, MockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+
+describe('ProductVerificationContract', () => {
+    let contract: ProductVerificationContract;
+
+    beforeEach(() => {
+        contract = new ProductVerificationContract();
+        process.env.RICETRACE_VERIFICATION_SECRET = 'test-secret';
+    });
+
+    afterEach(() => {
+        delete process.env.RICETRACE_VERIFICATION_SECRET;
+    });
+
+    const registerCode = async (ctx: MockContext) => {
+        ctx.stub.putJSON('product_P1', { docType: 'product', productId: 'P1', batchId: 'batch1', owner: 'Mill A', ownerMspId: 'Org2MSP', status: 'Active' });
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await contract.RegisterVerificationCode(ctx, 'P1', 'K7Q2-9XZ4');
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+    };
+
+    test('should verify the registered code without storing it', async () => {
+        const ctx = createMockContext({ mspId: 'Org3MSP' });
+        await registerCode(ctx);
+
+        expect(JSON.stringify(ctx.stub.getJSON('verification_P1'))).not.toContain('K7Q2-9XZ4');
+        ctx.stub.nextTransaction('tx-verify-1');
+        await expect(contract.VerifyProduct(ctx, 'P1', 'K7Q2-9XZ4')).resolves.toBe('tx-verify-1');
+        await expect(contract.GetVerificationResult(ctx, 'P1', 'tx-verify-1')).resolves.toEqual({
+            docType: 'productVerificationResult', attemptId: 'tx-verify-1', productId: 'P1', attemptedAt: '2024-09-22T10:13:20.000Z',
+            verified: true, locked: false, remainingAttempts: 5, lockedUntil: ''
+        });
+        ctx.stub.nextTransaction('tx-verify-2');
+        await contract.VerifyProduct(ctx, 'P1', 'WRONG-CODE');
+        await expect(contract.GetVerificationResult(ctx, 'P1', 'tx-verify-2')).resolves.toEqual(expect.objectContaining({ verified: false, remainingAttempts: 4 }));
+        await expect(contract.GetVerificationResult(ctx, 'P2', 'tx-verify-2')).rejects.toThrow('does not exist or is not committed yet');
+        await expect(contract.GetVerificationResult(ctx, 'P1', 'tx-unknown')).rejects.toThrow('does not exist or is not committed yet');
+        await expect(contract.GetVerificationStatus(ctx, 'P1')).resolves.toEqual(expect.objectContaining({
+            successfulVerifications: 1, failedAttempts: 1, consecutiveFailures: 1
+        }));
+
+        await expect(contract.VerifyProduct(ctx, 'P2', 'K7Q2-9XZ4')).rejects.toThrow('has no verification code');
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(contract.RegisterVerificationCode(ctx, 'P1', 'ANOTHER-CODE')).rejects.toThrow('held by Org2MSP');
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await expect(contract.RegisterVerificationCode(ctx, 'P1', '123')).rejects.toThrow('at least 6 characters');
+    });
+
+    test('should lock a product after repeated failures and report the probing', async () => {
+        const ctx = createMockContext({ mspId: 'Org3MSP' });
+        await registerCode(ctx);
+
+        for (let attempt = 1; attempt <= 4; attempt++) {
+            await contract.VerifyProduct(ctx, 'P1', `GUESS-${attempt}`);
+        }
+        expect(ctx.stub.events).toHaveLength(0);
+
+        const lockingAttempt = await contract.VerifyProduct(ctx, 'P1', 'GUESS-5');
+        await expect(contract.GetVerificationResult(ctx, 'P1', lockingAttempt)).resolves.toEqual(expect.objectContaining({
+            verified: false, locked: true, remainingAttempts: 0, lockedUntil: '2024-09-22T11:13:20.000Z'
+        }));
+        expect(ctx.stub.events[0]).toEqual({ name: 'SuspiciousVerification', payload: expect.objectContaining({ reason: 'lockout', failedAttempts: 5, lockouts: 1 }) });
+
+        // The right code is not even checked during the lockout
+        ctx.stub.nextTransaction();
+        const lockedAttempt = await contract.VerifyProduct(ctx, 'P1', 'K7Q2-9XZ4');
+        await expect(contract.GetVerificationResult(ctx, 'P1', lockedAttempt)).resolves.toEqual(expect.objectContaining({ verified: false, locked: true }));
+        expect(ctx.stub.events[0].payload).toEqual(expect.objectContaining({ reason: 'attemptWhileLocked', attemptsWhileLocked: 1 }));
+
+        ctx.stub.nextTransaction();
+        ctx.stub.setTxTimestamp(Date.parse('2024-09-22T11:13:21Z') / 1000);
+        const unlockedAttempt = await contract.VerifyProduct(ctx, 'P1', 'K7Q2-9XZ4');
+        await expect(contract.GetVerificationResult(ctx, 'P1', unlockedAttempt)).resolves.toEqual(expect.objectContaining({ verified: true, locked: false }));
+    });
+
+    test('should report a code verified more often than a genuine package would be', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await contract.DefineVerificationGuard(ctx, JSON.stringify({ maxFailedAttempts: 3, lockoutMinutes: 10, suspiciousSuccessCount: 2 }));
+        await expect(contract.GetVerificationGuard(ctx)).resolves.toEqual(expect.objectContaining({ suspiciousSuccessCount: 2, version: 1 }));
+        await registerCode(ctx);
+
+        await contract.VerifyProduct(ctx, 'P1', 'K7Q2-9XZ4');
+        expect(ctx.stub.events).toHaveLength(0);
+        await contract.VerifyProduct(ctx, 'P1', 'K7Q2-9XZ4');
+        expect(ctx.stub.events[0].payload).toEqual(expect.objectContaining({ reason: 'repeatedSuccess', successfulVerifications: 2 }));
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', id: ADMIN_ID });
+        await expect(contract.DefineVerificationGuard(ctx, JSON.stringify({ maxFailedAttempts: 3, lockoutMinutes: 0, suspiciousSuccessCount: 2 })))
+            .rejects.toThrow('lockoutMinutes must be a positive integer');
+    });
+
+    test('should refuse codes while the peers have no verification secret', async () => {
+        delete process.env.RICETRACE_VERIFICATION_SECRET;
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        ctx.stub.putJSON('product_P1', { docType: 'product', productId: 'P1', batchId: 'batch1', owner: 'Mill A', ownerMspId: 'Org2MSP' });
+
+        await expect(contract.RegisterVerificationCode(ctx, 'P1', 'K7Q2-9XZ4')).rejects.toThrow('set RICETRACE_VERIFICATION_SECRET');
+    });
+});
@@ -4,6 +4,10 @@
 
 import { QualityCertificationContract } from '../src/qualityCertificationContract';
 import { OrganizationType } from '../src/types';
+import { createMockContext, MockContext } from '../testing';
+
+// SHA-256 of the lab report file registered with test results
+const REPORT_HASH = 'a3f5c1d2e4b6a8c0e2f4a6b8c0d2e4f6a8b0c2d4e6f8a0b2c4d6e8f0a2b4c6d8';
 
 describe('QualityCertificationContract', () => {
     let contract: QualityCertificationContract;
@@ -261,4 +265,311 @@ describe('QualityCertificationContract', () => {
             }).toThrow('Verification source is required');
         });
     });
-}); 
\ No newline at end of file
+
+    describe('Sample Registration', () => {
+        const storeBatch = (ctx: MockContext, batchId: string) => {
+            ctx.stub.putJSON(`batch_${batchId}`, {
+                docType: 'riceBatch',
+                batchId,
+                harvestDate: '2024-09-15T00:00:00.000Z',
+                history: []
+            });
+        };
+
+        test('should link a test result to its registered sample', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeBatch(ctx, 'batch123');
+
+            await contract.RecordSample(ctx, 'batch123', 'sample1', '500g', 'Inspector Li', 'Silo 3');
+            await contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, '');
+
+            const sample = await contract.ReadSample(ctx, 'sample1');
+            expect(sample.recordedBy).toBe('Org2MSP');
+            expect(sample.location).toBe('Silo 3');
+            expect((await contract.ReadTestResult(ctx, 'test1')).sampleId).toBe('sample1');
+        });
+
+        test('should reject test results without a registered sample from the same batch', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeBatch(ctx, 'batch123');
+            storeBatch(ctx, 'batch456');
+            await contract.RecordSample(ctx, 'batch456', 'sample2', '500g', 'Inspector Li', 'Silo 1');
+
+            await expect(contract.CreateTestResult(ctx, 'test1', 'batch123', 'missing', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, ''))
+                .rejects.toThrow('is not registered');
+            await expect(contract.CreateTestResult(ctx, 'test2', 'batch123', 'sample2', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, ''))
+                .rejects.toThrow('was drawn from batch batch456');
+        });
+
+        test('should reject a resubmitted test ID', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeBatch(ctx, 'batch123');
+            storeBatch(ctx, 'batch456');
+            await contract.RecordSample(ctx, 'batch123', 'sample1', '500g', 'Inspector Li', 'Silo 3');
+            await contract.RecordSample(ctx, 'batch456', 'sample2', '500g', 'Inspector Li', 'Silo 1');
+            await contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, '');
+
+            await expect(contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, ''))
+                .rejects.toThrow('Test result test1 already exists for batch batch123');
+            await expect(contract.CreateTestResult(ctx, 'test1', 'batch456', 'sample2', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, ''))
+                .rejects.toThrow('already exists (recorded for batch batch123)');
+        });
+
+        test('should reject test dates before the batch was harvested or created', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('batch_batch123', {
+                docType: 'riceBatch', batchId: 'batch123', harvestDate: '2024-09-15T00:00:00.000Z',
+                history: [{ timestamp: '2024-09-18T00:00:00.000Z', from: '', to: 'Farm A', step: 'Harvested', signerMspId: 'Org1MSP' }]
+            });
+            await contract.RecordSample(ctx, 'batch123', 'sample1', '500g', 'Inspector Li', 'Silo 3');
+
+            await expect(contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-10', 'Passed', 'Lab A', '', REPORT_HASH, ''))
+                .rejects.toThrow('cannot be earlier than harvestDate of batch batch123');
+            // Between harvest and creation on the ledger
+            await expect(contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-16', 'Passed', 'Lab A', '', REPORT_HASH, ''))
+                .rejects.toThrow('testDate (2024-09-16T00:00:00.000Z) cannot be earlier than creation of batch batch123 (2024-09-18T00:00:00.000Z)');
+            await contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-18', 'Passed', 'Lab A', '', REPORT_HASH, '');
+            expect((await contract.ReadTestResult(ctx, 'test1')).testDate).toBe('2024-09-18T00:00:00.000Z');
+        });
+
+        test('should date the batch creation from its archived history', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('batch_batch123', {
+                docType: 'riceBatch', batchId: 'batch123', harvestDate: '2024-09-15T00:00:00.000Z', archivedHistorySegments: 1, archivedHistoryEvents: 1,
+                history: [{ timestamp: '2024-09-19T00:00:00.000Z', from: 'Farm A', to: 'Mill A', step: 'Milling', signerMspId: 'Org2MSP' }]
+            });
+            ctx.stub.putJSON('batchhistory_batch123_000001', {
+                docType: 'batchHistorySegment', batchId: 'batch123', segment: 1, firstIndex: 0,
+                events: [{ timestamp: '2024-09-17T00:00:00.000Z', from: '', to: 'Farm A', step: 'Harvested', signerMspId: 'Org1MSP' }]
+            });
+            await contract.RecordSample(ctx, 'batch123', 'sample1', '500g', 'Inspector Li', 'Silo 3');
+
+            await expect(contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-16', 'Passed', 'Lab A', '', REPORT_HASH, ''))
+                .rejects.toThrow('cannot be earlier than creation of batch batch123 (2024-09-17T00:00:00.000Z)');
+            await contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-17', 'Passed', 'Lab A', '', REPORT_HASH, '');
+        });
+
+        test('should reject samples for unknown batches', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            await expect(contract.RecordSample(ctx, 'nobatch', 'sample1', '500g', 'Farmer Zhang', 'Field 2'))
+                .rejects.toThrow('Batch nobatch does not exist');
+        });
+    });
+
+    describe('Test Result Revocation', () => {
+        const OTHER_CERT = '-----BEGIN CERTIFICATE-----\nAAEF\n-----END CERTIFICATE-----\n';
+
+        const recordTest = async (ctx: MockContext) => {
+            ctx.stub.putJSON('batch_batch123', {
+                docType: 'riceBatch', batchId: 'batch123', harvestDate: '2024-09-15T00:00:00.000Z', quarantined: true, history: []
+            });
+            await contract.RecordSample(ctx, 'batch123', 'sample1', '500g', 'Inspector Li', 'Silo 3');
+            await contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-20', 'Failed', 'Lab A', '', REPORT_HASH, '');
+            ctx.stub.nextTransaction();
+        };
+
+        test('should flag a result as revoked and move it out of the outcome index', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            await recordTest(ctx);
+
+            await contract.RevokeTestResult(ctx, 'batch123', 'test1', 'Moisture meter out of calibration');
+            await expect(contract.ReadTestResult(ctx, 'test1')).resolves.toEqual(expect.objectContaining({
+                testResult: 'Failed', revoked: true, revocationReason: 'Moisture meter out of calibration', revokedAt: '2024-09-22T10:13:20.000Z'
+            }));
+            expect(ctx.stub.events[0]).toEqual({ name: 'TestResultRevoked', payload: expect.objectContaining({ testId: 'test1', batchQuarantined: true }) });
+            expect(ctx.stub.hasCompositeKey('testOutcome~testDate~testId', ['failed', '2024-09-20T00:00:00.000Z', 'test1'])).toBe(false);
+            expect(ctx.stub.hasCompositeKey('testOutcome~testDate~testId', ['revoked', '2024-09-20T00:00:00.000Z', 'test1'])).toBe(true);
+
+            await expect(contract.RevokeTestResult(ctx, 'batch123', 'test1', 'Again')).rejects.toThrow('already revoked');
+        });
+
+        test('should only let the identity that recorded a result revoke it', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            await recordTest(ctx);
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', certPEM: OTHER_CERT });
+            await expect(contract.RevokeTestResult(ctx, 'batch123', 'test1', 'Instrument error')).rejects.toThrow('Permission denied');
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+            await expect(contract.RevokeTestResult(ctx, 'batch456', 'test1', 'Instrument error')).rejects.toThrow('belongs to batch batch123');
+            await expect(contract.RevokeTestResult(ctx, 'batch123', 'test1', '')).rejects.toThrow('A reason is required');
+        });
+    });
+
+    describe('Moisture Content', () => {
+        test('should record the moisture content of a moisture test once', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('batch_batch123', { docType: 'riceBatch', batchId: 'batch123', harvestDate: '2024-09-15T00:00:00.000Z', history: [] });
+            await contract.RecordSample(ctx, 'batch123', 'sample1', '500g', 'Inspector Li', 'Silo 3');
+            await contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, '');
+            await contract.CreateTestResult(ctx, 'test2', 'batch123', 'sample1', 'Pesticide Residue', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, '');
+            ctx.stub.nextTransaction();
+
+            await expect(contract.RecordMoistureContent(ctx, 'batch123', 'test1', '101')).rejects.toThrow('between 0 and 100');
+            await expect(contract.RecordMoistureContent(ctx, 'batch123', 'test1', 'dry')).rejects.toThrow('between 0 and 100');
+            await expect(contract.RecordMoistureContent(ctx, 'batch123', 'test2', '14.2')).rejects.toThrow('not a moisture test');
+            await contract.RecordMoistureContent(ctx, 'batch123', 'test1', '14.2');
+            await expect(contract.ReadTestResult(ctx, 'test1')).resolves.toEqual(expect.objectContaining({ moisturePercent: 14.2 }));
+            expect(ctx.stub.events[0].name).toBe('MoistureContentRecorded');
+            await expect(contract.RecordMoistureContent(ctx, 'batch123', 'test1', '13.9')).rejects.toThrow('already recorded');
+        });
+    });
+
+    describe('Report Hash Verification', () => {
+        const storeTest = (ctx: MockContext, reportHash: string) => {
+            ctx.stub.putJSON('test_test1', { docType: 'testResult', testId: 'test1', batchId: 'batch123', reportHash });
+        };
+
+        const storeSample = async (ctx: MockContext) => {
+            ctx.stub.putJSON('batch_batch123', { docType: 'riceBatch', batchId: 'batch123', harvestDate: '2024-09-15T00:00:00.000Z', history: [] });
+            await contract.RecordSample(ctx, 'batch123', 'sample1', '500g', 'Inspector Li', 'Silo 3');
+        };
+        const recordTest = (ctx: MockContext, reportHash: string) =>
+            contract.CreateTestResult(ctx, 'test1', 'batch123', 'sample1', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', reportHash, '');
+
+        test('should verify a report file against the hash registered with the test result', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            await storeSample(ctx);
+            await recordTest(ctx, REPORT_HASH.toUpperCase());
+            expect((await contract.ReadTestResult(ctx, 'test1')).reportHash).toBe(REPORT_HASH);
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+            await expect(contract.VerifyTestReportHash(ctx, 'batch123', 'test1', REPORT_HASH)).resolves.toBe(true);
+            await expect(contract.VerifyTestReportHash(ctx, 'batch123', 'test1', REPORT_HASH.toUpperCase())).resolves.toBe(true);
+            await expect(contract.VerifyTestReportHash(ctx, 'batch123', 'test1', 'b'.repeat(64))).resolves.toBe(false);
+        });
+
+        test('should reject test results whose report hash is not a SHA-256 digest', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            await storeSample(ctx);
+
+            await expect(recordTest(ctx, '')).rejects.toThrow('64 hex characters');
+            await expect(recordTest(ctx, 'hash_test1')).rejects.toThrow('64 hex characters');
+            await expect(recordTest(ctx, REPORT_HASH.slice(1))).rejects.toThrow('64 hex characters');
+            expect(ctx.stub.state.has('test_test1')).toBe(false);
+        });
+
+        test('should reject mismatched batches, malformed hashes and tests without a file hash', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            storeTest(ctx, REPORT_HASH);
+
+            await expect(contract.VerifyTestReportHash(ctx, 'batch456', 'test1', REPORT_HASH)).rejects.toThrow('belongs to batch batch123');
+            await expect(contract.VerifyTestReportHash(ctx, 'batch123', 'test1', 'hash_test1')).rejects.toThrow('64 hex characters');
+
+            storeTest(ctx, 'hash_test1_2024-09-20T00:00:00.000Z');
+            await expect(contract.VerifyTestReportHash(ctx, 'batch123', 'test1', REPORT_HASH)).rejects.toThrow('no report file hash registered');
+        });
+    });
+
+    describe('Tenant Isolation', () => {
+        const COOP_A = { 'ricetrace.tenant': 'coop-a' };
+        const COOP_B = { 'ricetrace.tenant': 'coop-b' };
+
+        const storeBatch = (ctx: MockContext, batchId: string, tenantId: string) => {
+            ctx.stub.putJSON(`batch_${batchId}`, { docType: 'riceBatch', batchId, tenantId, harvestDate: '2024-09-15T00:00:00.000Z', history: [] });
+        };
+
+        const recordCoopARecords = async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP', attributes: COOP_A });
+            storeBatch(ctx, 'batchA', 'coop-a');
+            await contract.RecordSample(ctx, 'batchA', 'sampleA', '500g', 'Inspector Li', 'Silo 3');
+            await contract.CreateTestResult(ctx, 'testA', 'batchA', 'sampleA', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, '');
+            await contract.CreateQualityCertificate(ctx, 'certA', 'batchA', 'testA', 'Export phytosanitary', '2024-09-21', 'CIQ Heilongjiang', '1 year', 'GB 1354');
+            return ctx;
+        };
+
+        test('should stamp samples, test results and certificates with the tenant of the batch', async () => {
+            const ctx = await recordCoopARecords();
+
+            expect(ctx.stub.getJSON('sample_sampleA').tenantId).toBe('coop-a');
+            expect(ctx.stub.getJSON('test_testA').tenantId).toBe('coop-a');
+            expect(ctx.stub.getJSON('cert_certA').tenantId).toBe('coop-a');
+            expect((await contract.GetSamplesByBatch(ctx, 'batchA')).map(sample => sample.sampleId)).toEqual(['sampleA']);
+            expect((await contract.GetTestResultsByBatch(ctx, 'batchA')).map(test => test.testId)).toEqual(['testA']);
+            expect((await contract.GetCertificatesByBatch(ctx, 'batchA')).map(cert => cert.certificateId)).toEqual(['certA']);
+        });
+
+        test('should hide them from other tenants and from identities without a tenant', async () => {
+            const ctx = await recordCoopARecords();
+            // A record of a batch registered without a tenant stays visible to everyone
+            ctx.stub.putJSON('test_shared', { docType: 'testResult', testId: 'shared', batchId: 'batchShared' });
+
+            for (const identity of [{ mspId: 'Org2MSP', attributes: COOP_B }, { mspId: 'Org3MSP' }]) {
+                ctx.clientIdentity.setIdentity(identity);
+
+                await expect(contract.ReadSample(ctx, 'sampleA')).rejects.toThrow('does not exist');
+                await expect(contract.ReadTestResult(ctx, 'testA')).rejects.toThrow('does not exist');
+                await expect(contract.ReadQualityCertificate(ctx, 'certA')).rejects.toThrow('does not exist');
+                await expect(contract.VerifyTestReportHash(ctx, 'batchA', 'testA', REPORT_HASH)).rejects.toThrow('does not exist');
+                await expect(contract.GetSamplesByBatch(ctx, 'batchA')).resolves.toEqual([]);
+                await expect(contract.GetTestResultsByBatch(ctx, 'batchA')).resolves.toEqual([]);
+                await expect(contract.GetCertificatesByBatch(ctx, 'batchA')).resolves.toEqual([]);
+                await expect(contract.GetAllQualityCertificates(ctx)).resolves.toEqual([]);
+                expect((await contract.GetAllTestResults(ctx)).map(test => test.testId)).toEqual(['shared']);
+            }
+        });
+
+        test('should refuse IDs taken by another tenant without disclosing their batch', async () => {
+            const ctx = await recordCoopARecords();
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', attributes: COOP_B });
+            storeBatch(ctx, 'batchB', 'coop-b');
+            await contract.RecordSample(ctx, 'batchB', 'sampleB', '500g', 'Inspector Wang', 'Silo 1');
+
+            await expect(contract.RecordSample(ctx, 'batchB', 'sampleA', '500g', 'Inspector Wang', 'Silo 1')).rejects.toThrow('Sample sampleA already exists');
+            await expect(contract.CreateTestResult(ctx, 'testA', 'batchB', 'sampleB', 'Moisture', '2024-09-20', 'Passed', 'Lab B', '', REPORT_HASH, ''))
+                .rejects.toThrow(/^Test result testA already exists$/);
+            await expect(contract.CreateQualityCertificate(ctx, 'certA', 'batchB', 'testA', 'Export phytosanitary', '2024-09-21', 'CIQ', '1 year', 'GB 1354'))
+                .rejects.toThrow('Quality certificate certA already exists');
+            expect(ctx.stub.getJSON('test_testA').batchId).toBe('batchA');
+        });
+    });
+
+    describe('Certificate Expiry Alerts', () => {
+        const storeCertificate = (ctx: MockContext, certificateId: string, validityPeriod: string, isActive = true) => {
+            ctx.stub.putJSON(`cert_${certificateId}`, {
+                docType: 'qualityCertificate', certificateId, batchId: 'batch123', certificateType: 'Export phytosanitary',
+                issueDate: '2023-10-01T00:00:00.000Z', issuer: 'CIQ Heilongjiang', validityPeriod, isActive
+            });
+        };
+
+        const expiryContext = () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('batch_batch123', {
+                docType: 'riceBatch', batchId: 'batch123', currentOwner: 'Mill A',
+                history: [{ timestamp: '2024-09-20T00:00:00.000Z', from: 'Farm A', to: 'Mill A', step: 'Milling', signerMspId: 'Org2MSP' }]
+            });
+            storeCertificate(ctx, 'cert-soon', '12 months');
+            storeCertificate(ctx, 'cert-later', '2024-12-31');
+            storeCertificate(ctx, 'cert-expired', '2024-09-01');
+            storeCertificate(ctx, 'cert-revoked', '12 months', false);
+            return ctx;
+        };
+
+        test('should report active certificates expiring within the window once, with the batch owner', async () => {
+            const ctx = expiryContext();
+
+            const report = await contract.CheckExpiringCertifications(ctx, '');
+
+            expect(report.withinDays).toBe(30);
+            expect(report.expiring).toEqual([expect.objectContaining({
+                certificateId: 'cert-soon', expiresAt: '2024-10-01T00:00:00.000Z', daysRemaining: 8, owner: 'Mill A', ownerMspId: 'Org2MSP'
+            })]);
+            expect(ctx.stub.events[0]).toEqual({ name: 'CertificationExpiring', payload: report });
+            expect(ctx.stub.getJSON('cert_cert-soon').expiryNotifiedAt).toBe(report.checkedAt);
+
+            const rerun = await contract.CheckExpiringCertifications(ctx, '');
+            expect(rerun.expiring).toEqual([]);
+            expect(ctx.stub.setEvent).toHaveBeenCalledTimes(1);
+        });
+
+        test('should honour the look-ahead window', async () => {
+            const ctx = expiryContext();
+
+            await expect(contract.CheckExpiringCertifications(ctx, '5')).resolves.toEqual(expect.objectContaining({ expiring: [] }));
+            const report = await contract.CheckExpiringCertifications(ctx, '120');
+            expect(report.expiring.map(certificate => certificate.certificateId)).toEqual(['cert-later', 'cert-soon']);
+
+            await expect(contract.CheckExpiringCertifications(ctx, '0')).rejects.toThrow('Invalid withinDays 0');
+            await expect(contract.CheckExpiringCertifications(ctx, '1.5')).rejects.toThrow('Invalid withinDays 1.5');
+        });
+    });
+});
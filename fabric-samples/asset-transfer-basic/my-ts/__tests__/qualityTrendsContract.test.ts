@@ -0,0 +1,74 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { QualityTrendsContract } from '../src/qualityTrendsContract';
+import { createMockContext, MockContext } from '../testing';
+
+describe('QualityTrendsContract', () => {
+    let contract: QualityTrendsContract;
+
+    beforeEach(() => {
+        contract = new QualityTrendsContract();
+    });
+
+    const putBatch = (ctx: MockContext, batchId: string, variety: string, origin: string) =>
+        ctx.stub.putJSON(`batch_${batchId}`, { docType: 'riceBatch', batchId, variety, origin, currentState: 'Tested', history: [] });
+    const putTest = (ctx: MockContext, testId: string, batchId: string, testDate: string, outcome: string, extra: object = {}) => {
+        ctx.stub.putJSON(`test_${testId}`, { docType: 'testResult', testId, batchId, testType: 'Moisture', testDate, testResult: outcome, ...extra });
+        ctx.stub.state.set(ctx.stub.createCompositeKey('testOutcome~testDate~testId', [outcome.toLowerCase(), testDate, testId]), Buffer.from([0x00]));
+    };
+    const putProduct = (ctx: MockContext, productId: string, batchId: string, packageDate: string, grade: string) =>
+        ctx.stub.putJSON(`product_${productId}`, { docType: 'product', productId, batchId, packageDate, owner: 'Shop', composition: { ingredients: [], grade } });
+
+    const storeQualityData = (ctx: MockContext) => {
+        putBatch(ctx, 'b1', 'Daohuaxiang', 'Wuchang, Heilongjiang');
+        putBatch(ctx, 'b2', 'daohuaxiang', 'Wuchang, Heilongjiang');
+        putBatch(ctx, 'b3', 'Daohuaxiang', 'Panjin, Liaoning');
+        putBatch(ctx, 'b4', 'Koshihikari', 'Wuchang, Heilongjiang');
+        putTest(ctx, 'T1', 'b1', '2024-09-20T00:00:00.000Z', 'Passed', { moisturePercent: 14 });
+        putTest(ctx, 'T2', 'b2', '2024-09-25T00:00:00.000Z', 'Failed', { moisturePercent: 16.5 });
+        putTest(ctx, 'T3', 'b1', '2024-10-05T00:00:00.000Z', 'Passed');
+        putTest(ctx, 'T4', 'b3', '2024-09-21T00:00:00.000Z', 'Failed');
+        putTest(ctx, 'T5', 'b4', '2024-09-22T00:00:00.000Z', 'Failed');
+        putTest(ctx, 'T6', 'b1', '2024-12-01T00:00:00.000Z', 'Passed');
+        // Revoked results stay out of the passed and failed outcomes
+        ctx.stub.putJSON('test_T7', { docType: 'testResult', testId: 'T7', batchId: 'b1', testType: 'Moisture', testDate: '2024-09-26T00:00:00.000Z', testResult: 'Failed', revoked: true });
+        ctx.stub.state.set(ctx.stub.createCompositeKey('testOutcome~testDate~testId', ['revoked', '2024-09-26T00:00:00.000Z', 'T7']), Buffer.from([0x00]));
+        putProduct(ctx, 'P1', 'b1', '2024-10-10T00:00:00.000Z', 'Grade 1');
+        putProduct(ctx, 'P2', 'b1', '2024-10-11T00:00:00.000Z', 'Grade 1');
+        putProduct(ctx, 'P3', 'b2', '2024-10-12T00:00:00.000Z', 'Grade 2');
+        putProduct(ctx, 'P4', 'b4', '2024-10-12T00:00:00.000Z', 'Grade 1');
+    };
+
+    test('should aggregate tests and grades of a variety and region month by month', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        storeQualityData(ctx);
+
+        const trends = await contract.GetQualityTrends(ctx, 'DAOHUAXIANG', 'wuchang', '2024-09-01/2024-10-31');
+        expect(trends).toEqual(expect.objectContaining({
+            variety: 'DAOHUAXIANG', region: 'wuchang', from: '2024-09-01T00:00:00.000Z', batches: 2,
+            testsRecorded: 3, failedTests: 1, failureRate: 0.3333, moistureReadings: 2, averageMoisturePercent: 15.25,
+            grades: { 'Grade 1': 2, 'Grade 2': 1 }
+        }));
+        expect(trends.months).toEqual([
+            { month: '2024-09', testsRecorded: 2, failedTests: 1, failureRate: 0.5, moistureReadings: 2, averageMoisturePercent: 15.25, grades: {} },
+            { month: '2024-10', testsRecorded: 1, failedTests: 0, failureRate: 0, moistureReadings: 0, grades: { 'Grade 1': 2, 'Grade 2': 1 } }
+        ]);
+
+        const all = await contract.GetQualityTrends(ctx, '', '', '2024-09-01/2024-12-31');
+        expect(all.months.map(month => [month.month, month.testsRecorded, month.failedTests])).toEqual([
+            ['2024-09', 4, 3], ['2024-10', 1, 0], ['2024-11', 0, 0], ['2024-12', 1, 0]
+        ]);
+        expect(all.batches).toBe(4);
+    });
+
+    test('should validate the period', async () => {
+        const ctx = createMockContext({ mspId: 'Org3MSP' });
+
+        await expect(contract.GetQualityTrends(ctx, '', '', '2024')).rejects.toThrow('period must be an interval');
+        await expect(contract.GetQualityTrends(ctx, '', '', '2024-10-01/2024-09-01')).rejects.toThrow('cannot be earlier');
+        await expect(contract.GetQualityTrends(ctx, '', '', '2020-01-01/2024-12-31')).rejects.toThrow('At most 36 months');
+        await expect(contract.GetQualityTrends(ctx, '', '', '2022-01-01/2024-12-31')).resolves.toEqual(expect.objectContaining({ testsRecorded: 0 }));
+    });
+});
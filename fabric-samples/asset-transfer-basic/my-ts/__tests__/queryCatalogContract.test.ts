@@ -0,0 +1,92 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { QueryCatalogContract } from '../src/queryCatalogContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { QualityCertificationContract } from '../src/qualityCertificationContract';
+import { ProductManagementContract } from '../src/productManagementContract';
+import { createMockContext } from '../testing';
+
+// SHA-256 of the lab report file registered with test results
+const REPORT_HASH = 'a3f5c1d2e4b6a8c0e2f4a6b8c0d2e4f6a8b0c2d4e6f8a0b2c4d6e8f0a2b4c6d8';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+
+describe('QueryCatalogContract', () => {
+    let contract: QueryCatalogContract;
+
+    beforeEach(() => {
+        contract = new QueryCatalogContract();
+    });
+
+    test('should page through the batches held by an owner', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+        for (const batchId of ['B1', 'B2', 'B3']) {
+            ctx.stub.putJSON(`batch_${batchId}`, { docType: 'riceBatch', batchId, currentOwner: 'Farmer Zhang', currentState: 'Drying', history: [] });
+        }
+        await expect(contract.RebuildQueryIndexes(ctx)).resolves.toBe(3);
+
+        await new RiceTracerContract().CompleteStepAndTransfer(ctx, 'B2', 'Farmer Zhang', 'Processor A', 'Milling', JSON.stringify({
+            reportId: 'r-B2', reportType: 'ProcessingRecord', reportHash: '', summary: 'Milled', isVerified: false
+        }), '');
+
+        const firstPage = await contract.RunNamedQuery(ctx, 'batchesByOwner', JSON.stringify({ owner: 'Farmer Zhang', pageSize: 1 }));
+        expect(firstPage.batches!.map(batch => batch.batchId)).toEqual(['B1']);
+        expect(firstPage.bookmark).not.toBe('');
+
+        const secondPage = await contract.RunNamedQuery(ctx, 'batchesByOwner', JSON.stringify({ owner: 'Farmer Zhang', pageSize: 1, bookmark: firstPage.bookmark }));
+        expect(secondPage.batches!.map(batch => batch.batchId)).toEqual(['B3']);
+        expect(secondPage.bookmark).toBe('');
+
+        const processor = await contract.RunNamedQuery(ctx, 'batchesByOwner', JSON.stringify({ owner: 'Processor A' }));
+        expect(processor).toEqual(expect.objectContaining({ name: 'batchesByOwner', fetchedRecordsCount: 1 }));
+        expect(processor.batches!.map(batch => batch.batchId)).toEqual(['B2']);
+    });
+
+    test('should find failed tests since a date and products expiring before a date', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        const quality = new QualityCertificationContract();
+        ctx.stub.putJSON('batch_B1', { docType: 'riceBatch', batchId: 'B1', harvestDate: '2024-09-01T00:00:00.000Z', history: [] });
+        const outcomes: [string, string, string][] = [['T1', '2024-09-05', 'Failed'], ['T2', '2024-09-10', 'Passed'], ['T3', '2024-09-15', 'failed']];
+        for (const [testId, testDate, outcome] of outcomes) {
+            await quality.RecordSample(ctx, 'B1', `S-${testId}`, '500g', 'Inspector Li', 'Silo 3');
+            await quality.CreateTestResult(ctx, testId, 'B1', `S-${testId}`, 'Moisture', testDate, outcome, 'Lab A', '', REPORT_HASH, '');
+        }
+
+        const failed = await contract.RunNamedQuery(ctx, 'failedTestsSince', JSON.stringify({ since: '2024-09-05' }));
+        expect(failed.testResults!.map(test => test.testId)).toEqual(['T1', 'T3']);
+        const recent = await contract.RunNamedQuery(ctx, 'failedTestsSince', JSON.stringify({ since: '2024-09-06' }));
+        expect(recent.testResults!.map(test => test.testId)).toEqual(['T3']);
+
+        const products = new ProductManagementContract();
+        const label = (bestBefore: string) => JSON.stringify({ ingredients: ['Japonica rice (100%)'], bestBefore });
+        for (const productId of ['P1', 'P2', 'P3']) {
+            ctx.stub.putJSON(`product_${productId}`, { docType: 'product', productId, batchId: 'B1', owner: 'Distributor A', status: 'Active', transfers: [] });
+        }
+        await products.SetProductNutrition(ctx, 'P1', '', label('2025-03-01'));
+        await products.SetProductNutrition(ctx, 'P2', '', label('2024-12-01'));
+        await products.SetProductNutrition(ctx, 'P3', '', label('2025-09-01'));
+        await products.SetProductNutrition(ctx, 'P3', '', label('2024-11-01'));
+        expect(ctx.stub.getJSON('product_P3').composition.bestBefore).toBe('2024-11-01T23:59:59.999Z');
+
+        const expiring = await contract.RunNamedQuery(ctx, 'productsExpiringBefore', JSON.stringify({ before: '2025-01-01' }));
+        expect(expiring.products!.map(product => product.productId)).toEqual(['P3', 'P2']);
+    });
+
+    test('should only run catalog queries with their declared parameters', async () => {
+        const ctx = createMockContext({ mspId: 'Org3MSP' });
+
+        await expect(contract.ListNamedQueries(ctx)).resolves.toEqual(expect.arrayContaining([
+            expect.objectContaining({ name: 'failedTestsSince', parameters: ['since', 'pageSize', 'bookmark'] })
+        ]));
+        await expect(contract.RunNamedQuery(ctx, 'allBatches', '{}')).rejects.toThrow('Unknown query allBatches');
+        await expect(contract.RunNamedQuery(ctx, 'batchesByOwner', '{}')).rejects.toThrow('requires parameter owner');
+        await expect(contract.RunNamedQuery(ctx, 'batchesByOwner', JSON.stringify({ owner: 'Farmer Zhang', selector: { variety: 'Japonica' } })))
+            .rejects.toThrow('Parameters not used by query batchesByOwner: selector');
+        await expect(contract.RunNamedQuery(ctx, 'batchesByOwner', JSON.stringify({ owner: 'Farmer Zhang', pageSize: 500 })))
+            .rejects.toThrow('Invalid page size 500');
+        await expect(contract.RunNamedQuery(ctx, 'failedTestsSince', JSON.stringify({ since: 'last week' }))).rejects.toThrow('since');
+        await expect(contract.RebuildQueryIndexes(ctx)).rejects.toThrow('Permission denied');
+    });
+});
@@ -0,0 +1,112 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { RecallContract } from '../src/recallContract';
+import { createMockContext, MockContext } from '../testing';
+
+describe('RecallContract', () => {
+    let contract: RecallContract;
+
+    beforeEach(() => {
+        contract = new RecallContract();
+    });
+
+    const putItems = (ctx: MockContext) => {
+        ctx.stub.putJSON('batch_batch1', {
+            docType: 'riceBatch', batchId: 'batch1', currentState: 'Packaged', currentOwner: 'Mill A',
+            history: [{ step: 'Packaged', to: 'Mill A', signerMspId: 'Org2MSP' }]
+        });
+        ctx.stub.putJSON('batch_batch2', { docType: 'riceBatch', batchId: 'batch2', currentState: 'Disposed', currentOwner: 'Mill A', history: [] });
+        const putProduct = (productId: string, batchId: string, owner: string, ownerMspId: string, status: string) => {
+            ctx.stub.putJSON(`product_${productId}`, { docType: 'product', productId, batchId, owner, ownerMspId, status });
+            ctx.stub.state.set(ctx.stub.createCompositeKey('productBatch~productId', [batchId, productId]), Buffer.from([0x00]));
+        };
+        putProduct('P1', 'batch1', 'Mill A', 'Org2MSP', 'Active');
+        putProduct('P2', 'batch1', 'Shop B', 'Org3MSP', 'Sold');
+        putProduct('P3', 'batch1', 'Shop B', 'Org3MSP', 'Disposed');
+        putProduct('P4', 'batch2', 'Shop C', 'Org3MSP', 'Active');
+        ctx.stub.putJSON('participant_shop-b', { docType: 'participant', participantId: 'shop-b', name: 'Shop B', role: 'Retailer', mspId: 'Org3MSP' });
+    };
+
+    test('should group the current owners of the recalled batches and products into notices', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        putItems(ctx);
+
+        const recall = await contract.IssueRecall(ctx, 'RC-001', 'Aflatoxin above limit',
+            JSON.stringify({ batchIds: ['batch1', 'batch2'], productIds: ['P2'] }));
+
+        expect(recall.batchIds).toEqual(['batch1', 'batch2']);
+        expect(recall.productIds).toEqual(['P1', 'P2', 'P4']);
+        expect(recall.notices).toEqual([
+            { owner: 'Mill A', ownerMspId: 'Org2MSP', batchIds: ['batch1'], productIds: ['P1'] },
+            { owner: 'Shop B', ownerMspId: 'Org3MSP', participantId: 'shop-b', batchIds: [], productIds: ['P2'] },
+            { owner: 'Shop C', ownerMspId: 'Org3MSP', batchIds: [], productIds: ['P4'] }
+        ]);
+        expect(recall.issuedBy).toBe('Org2MSP');
+        expect(ctx.stub.events).toEqual([{ name: 'RecallIssued', payload: expect.objectContaining({ recallId: 'RC-001', notices: recall.notices }) }]);
+
+        await expect(contract.ReadRecall(ctx, 'RC-001')).resolves.toEqual(expect.objectContaining({ reason: 'Aflatoxin above limit' }));
+        expect((await contract.GetRecallsByEntity(ctx, 'P4')).map(item => item.recallId)).toEqual(['RC-001']);
+        await expect(contract.GetRecallsByEntity(ctx, 'P3')).resolves.toEqual([]);
+    });
+
+    test('should reject invalid recalls', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        putItems(ctx);
+
+        await expect(contract.IssueRecall(ctx, 'RC-001', '', JSON.stringify({ batchIds: ['batch1'] }))).rejects.toThrow('reason is required');
+        await expect(contract.IssueRecall(ctx, 'RC-001', 'Mould', '{}')).rejects.toThrow('at least one batch or product');
+        await expect(contract.IssueRecall(ctx, 'RC-001', 'Mould', JSON.stringify({ batchIds: 'batch1' }))).rejects.toThrow('batchIds must be a list');
+        await expect(contract.IssueRecall(ctx, 'RC-001', 'Mould', JSON.stringify({ batchIds: ['missing'] }))).rejects.toThrow('does not exist');
+        await contract.IssueRecall(ctx, 'RC-001', 'Mould', JSON.stringify({ productIds: ['P1'] }));
+        await expect(contract.IssueRecall(ctx, 'RC-001', 'Mould', JSON.stringify({ productIds: ['P1'] }))).rejects.toThrow('already exists');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        await expect(contract.IssueRecall(ctx, 'RC-002', 'Mould', JSON.stringify({ productIds: ['P1'] }))).rejects.toThrow('Permission denied');
+        await expect(contract.ReadRecall(ctx, 'RC-404')).rejects.toThrow('does not exist');
+    });
+
+    test('should track which notified owners have pulled the recalled stock', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        putItems(ctx);
+        await contract.IssueRecall(ctx, 'RC-001', 'Aflatoxin above limit', JSON.stringify({ batchIds: ['batch1', 'batch2'], productIds: ['P2'] }));
+
+        ctx.stub.nextTransaction();
+        ctx.stub.setTxTimestamp(Date.parse('2024-09-23T08:00:00Z') / 1000);
+        const acknowledgment = await contract.AcknowledgeRecall(ctx, 'RC-001', 'Mill A', JSON.stringify(['P1']), 'Quarantined');
+        expect(acknowledgment).toEqual(expect.objectContaining({
+            recallId: 'RC-001', owner: 'Mill A', ownerMspId: 'Org2MSP', itemIds: ['P1'], disposition: 'Quarantined',
+            acknowledgedByMspId: 'Org2MSP', acknowledgedAt: '2024-09-23T08:00:00.000Z'
+        }));
+        expect(ctx.stub.events[0].name).toBe('RecallAcknowledged');
+
+        ctx.stub.nextTransaction();
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        await contract.AcknowledgeRecall(ctx, 'RC-001', 'shop-b', '[]', 'Returned');
+
+        const completion = await contract.GetRecallCompletion(ctx, 'RC-001');
+        expect(completion).toEqual(expect.objectContaining({
+            owners: 3, respondedOwners: 1, partialOwners: 1, silentOwners: 1, items: 4, acknowledgedItems: 2, completionRate: 0.5
+        }));
+        expect(completion.ownerStatuses.map(status => [status.owner, status.status, status.pendingItemIds])).toEqual([
+            ['Shop C', 'NoResponse', ['P4']],
+            ['Mill A', 'Partial', ['batch1']],
+            ['Shop B', 'Responded', []]
+        ]);
+        expect(completion.ownerStatuses[1]).toEqual(expect.objectContaining({ dispositions: { Quarantined: 1 }, lastAcknowledgedAt: '2024-09-23T08:00:00.000Z' }));
+        expect(completion.ownerStatuses[2]).toEqual(expect.objectContaining({ participantId: 'shop-b', dispositions: { Returned: 1 } }));
+    });
+
+    test('should only let the notified owner acknowledge its own items', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        putItems(ctx);
+        await contract.IssueRecall(ctx, 'RC-001', 'Mould', JSON.stringify({ batchIds: ['batch1'] }));
+
+        await expect(contract.AcknowledgeRecall(ctx, 'RC-001', 'Shop Z', '[]', 'Returned')).rejects.toThrow('has no notice for Shop Z');
+        await expect(contract.AcknowledgeRecall(ctx, 'RC-001', 'Shop B', '[]', 'Returned')).rejects.toThrow('Permission denied');
+        await expect(contract.AcknowledgeRecall(ctx, 'RC-001', 'Mill A', '[]', 'Sold')).rejects.toThrow('Invalid disposition: Sold');
+        await expect(contract.AcknowledgeRecall(ctx, 'RC-001', 'Mill A', JSON.stringify(['P2']), 'Returned')).rejects.toThrow('P2 is not part of the recall notice to Mill A');
+        await expect(contract.AcknowledgeRecall(ctx, 'RC-404', 'Mill A', '[]', 'Returned')).rejects.toThrow('does not exist');
+    });
+});
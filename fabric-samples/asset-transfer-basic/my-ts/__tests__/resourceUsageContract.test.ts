@@ -0,0 +1,71 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { ResourceUsageContract } from '../src/resourceUsageContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext } from '../testing';
+
+describe('ResourceUsageContract', () => {
+    let contract: ResourceUsageContract;
+    let riceContract: RiceTracerContract;
+
+    const record = (step: string, reportId: string, timestamp?: string) => ({
+        step, timestamp, report: { reportId, reportType: 'ProcessingRecord', reportHash: `hash-${reportId}`, summary: step, isVerified: false }
+    });
+
+    beforeEach(() => {
+        contract = new ResourceUsageContract();
+        riceContract = new RiceTracerContract();
+    });
+
+    test('should keep the resource usage of processing records private to the processing organization', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Mill A', currentState: 'Milled', history: [] });
+        ctx.stub.setTransient({ resources: JSON.stringify([{ energyKwh: 120.5, machineHours: 2 }, null, { energyKwh: 30, laborHours: 1.5 }]) });
+
+        await riceContract.AddProcessingRecords(ctx, 'batch1', JSON.stringify([
+            record('Polishing', 'r1', '2024-09-21T08:00:00Z'), record('Sorting', 'r2', '2024-09-21T09:00:00Z'), record('Polishing', 'r3')
+        ]), '');
+
+        // Only the processing organization's implicit collection holds the figures
+        const collection = ctx.stub.privateData.get('_implicit_org_Org2MSP');
+        expect(Array.from(collection?.keys() || [])).toEqual(['resource_batch1_000000', 'resource_batch1_000002']);
+        expect(JSON.stringify(ctx.stub.getJSON('batch_batch1'))).not.toContain('energyKwh');
+
+        const usage = await contract.GetResourceUsage(ctx, 'batch1');
+        expect(usage).toHaveLength(2);
+        expect(usage[0]).toEqual(expect.objectContaining({ batchId: 'batch1', eventIndex: 0, step: 'Polishing', energyKwh: 120.5, machineHours: 2, mspId: 'Org2MSP' }));
+        expect(usage[1]).toEqual(expect.objectContaining({ eventIndex: 2, timestamp: '2024-09-22T10:13:20.000Z', laborHours: 1.5 }));
+
+        const summary = await contract.GetResourceUsageSummary(ctx, '2024-09-01/2024-09-30');
+        expect(summary.batches).toBe(1);
+        expect(summary.totals).toEqual({ records: 2, energyKwh: 150.5, laborHours: 1.5, machineHours: 2 });
+        expect(summary.byStep.Polishing.records).toBe(2);
+        await expect(contract.GetResourceUsageSummary(ctx, '2024-10-01/2024-10-31')).resolves.toEqual(expect.objectContaining({ batches: 0 }));
+    });
+
+    test('should reject malformed usage and reads from another organization\'s peer', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Mill A', currentState: 'Milled', history: [] });
+
+        ctx.stub.setTransient({ resources: JSON.stringify([{ energyKwh: 1 }]) });
+        await expect(riceContract.AddProcessingRecords(ctx, 'batch1', JSON.stringify([record('Polishing', 'r1'), record('Sorting', 'r2')]), ''))
+            .rejects.toThrow('one entry (or null) per processing record, 2 in all');
+        ctx.stub.setTransient({ resources: JSON.stringify([{ energyKwh: -1 }]) });
+        await expect(riceContract.AddProcessingRecords(ctx, 'batch1', JSON.stringify([record('Polishing', 'r1')]), ''))
+            .rejects.toThrow('energyKwh of record 0 must be a non-negative number');
+        ctx.stub.setTransient({ resources: JSON.stringify([{}]) });
+        await expect(riceContract.AddProcessingRecords(ctx, 'batch1', JSON.stringify([record('Polishing', 'r1')]), ''))
+            .rejects.toThrow('must give energyKwh, laborHours, machineHours or be null');
+
+        ctx.stub.setPeerMspId('Org1MSP');
+        ctx.stub.setTransient({ resources: JSON.stringify([{ energyKwh: 1 }]) });
+        await expect(riceContract.AddProcessingRecords(ctx, 'batch1', JSON.stringify([record('Polishing', 'r1')]), '')).rejects.toThrow();
+        await expect(contract.GetResourceUsage(ctx, 'batch1')).rejects.toThrow();
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        ctx.stub.setPeerMspId('Org3MSP');
+        await expect(contract.GetResourceUsage(ctx, 'batch1')).rejects.toThrow('Permission denied');
+    });
+});
@@ -2,8 +2,15 @@
  * SPDX-License-Identifier: Apache-2.0
  */
 
+import { createHash } from 'crypto';
 import { RiceTracerContract } from '../src/riceTracerContract';
+import { ComplianceProfileContract } from '../src/complianceProfileContract';
+import { ProductManagementContract } from '../src/productManagementContract';
+import { certificateFingerprint } from '../src/utils';
 import { OrganizationType } from '../src/types';
+import { createMockContext, MockContext, TEST_CERT_PEM, TEST_TIMESTAMP_SECONDS } from '../testing';
+
+const packageJson = require('../package.json');
 
 describe('RiceTracerContract', () => {
     let contract: RiceTracerContract;
@@ -24,6 +31,27 @@ describe('RiceTracerContract', () => {
         });
     });
 
+    describe('Health Check', () => {
+        test('should answer Ping without touching the ledger', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            await expect(contract.Ping(ctx)).resolves.toBe(true);
+            expect(ctx.stub.getState).not.toHaveBeenCalled();
+            expect(ctx.stub.putState).not.toHaveBeenCalled();
+        });
+
+        test('should report the package version and build information', async () => {
+            process.env.RICETRACE_BUILD_COMMIT = '3f2a9c1';
+            try {
+                const version = await contract.GetContractVersion(createMockContext());
+                expect(version.contractVersion).toBe(packageJson.version);
+                expect(version.buildCommit).toBe('3f2a9c1');
+                expect(version.nodeVersion).toBe(process.version);
+            } finally {
+                delete process.env.RICETRACE_BUILD_COMMIT;
+            }
+        });
+    });
+
     describe('Organization Type Logic', () => {
         test('should validate organization type enum', () => {
             expect(OrganizationType.FARM).toBe(1);
@@ -147,4 +175,1347 @@ describe('RiceTracerContract', () => {
             expect(data).toBeNull();
         });
     });
-}); 
\ No newline at end of file
+
+    describe('Batch Disposal', () => {
+        const storeBatch = (ctx: MockContext) => {
+            ctx.stub.putJSON('batch_batch123', {
+                docType: 'riceBatch',
+                batchId: 'batch123',
+                origin: 'Heilongjiang',
+                variety: 'Japonica',
+                harvestDate: '2024-09-15T00:00:00.000Z',
+                currentOwner: 'Processor A',
+                currentState: 'Stored',
+                history: []
+            });
+        };
+
+        test('should record the disposition and move the batch to the Disposed state', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeBatch(ctx);
+
+            await contract.DisposeBatch(ctx, 'batch123', 'Mould contamination', 'Incineration', '1200kg', 'Waste Co');
+
+            const batch = ctx.stub.getJSON('batch_batch123');
+            expect(batch.currentState).toBe('Disposed');
+            expect(batch.disposal).toEqual(expect.objectContaining({
+                reason: 'Mould contamination', method: 'Incineration', quantity: '1200kg', handler: 'Waste Co', recordedBy: 'Org2MSP'
+            }));
+            expect(batch.history[0].step).toBe('Disposed');
+            expect(ctx.stub.hasCompositeKey('step~batchId', ['Stored', 'batch123'])).toBe(false);
+            expect(ctx.stub.hasCompositeKey('step~batchId', ['Disposed', 'batch123'])).toBe(true);
+        });
+
+        test('should treat Disposed as a terminal state', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            storeBatch(ctx);
+            await contract.DisposeBatch(ctx, 'batch123', 'Recalled', 'Animal feed', '800kg', 'Feed Mill B');
+
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch123', 'Waste Co', 'Processor A', 'Milling', '{}', ''))
+                .rejects.toThrow('has been disposed of');
+            await expect(contract.DisposeBatch(ctx, 'batch123', 'Recalled', 'Animal feed', '800kg', 'Feed Mill B'))
+                .rejects.toThrow('has been disposed of');
+        });
+
+        test('should require a complete disposition record', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            storeBatch(ctx);
+            await expect(contract.DisposeBatch(ctx, 'batch123', 'Spoiled', '', '100kg', 'Farmer Zhang'))
+                .rejects.toThrow('Disposal reason, method, quantity and handler are required');
+        });
+    });
+
+    describe('Fixture Seeding', () => {
+        const fixtures = {
+            batches: [{
+                batchId: 'qa-batch1',
+                origin: 'Jilin',
+                variety: 'Japonica',
+                harvestDate: '2024-09-10',
+                currentOwner: 'Mill A',
+                currentState: 'Milling',
+                history: []
+            }],
+            products: [{ productId: 'qa-product1', batchId: 'qa-batch1', packageDate: '2024-09-18', owner: 'Retailer B' }],
+            participants: [{ participantId: 'mill-a', name: 'Mill A', role: 'Processor', mspId: 'Org2MSP', location: 'Changchun' }]
+        };
+
+        test('should seed batches, products and participants from transient fixtures', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP', transient: { fixtures: JSON.stringify(fixtures) } });
+
+            await contract.InitLedger(ctx);
+
+            expect(ctx.stub.getJSON('batch_batch1')).toBeUndefined();
+            expect(ctx.stub.getJSON('batch_qa-batch1').harvestDate).toBe('2024-09-10T00:00:00.000Z');
+            expect(ctx.stub.getJSON('product_qa-product1')).toEqual(expect.objectContaining({ docType: 'product', status: 'Active', transfers: [] }));
+            expect(ctx.stub.getJSON('participant_mill-a').docType).toBe('participant');
+            expect(ctx.stub.hasCompositeKey('step~batchId', ['Milling', 'qa-batch1'])).toBe(true);
+            expect(ctx.stub.hasCompositeKey('owner~productId', ['Retailer B', 'qa-product1'])).toBe(true);
+        });
+
+        test('should seed the built-in demo batches without fixtures', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            await contract.InitLedger(ctx);
+            expect(ctx.stub.getJSON('batch_batch1')).toBeDefined();
+            expect(ctx.stub.getJSON('batch_batch2').history[0]).toEqual(expect.objectContaining({
+                signerMspId: 'Org1MSP', signerFingerprint: certificateFingerprint(TEST_CERT_PEM)
+            }));
+
+            // The seeding organization is the registrant of the demo batches
+            ctx.stub.nextTransaction();
+            const delegation = await contract.GrantDelegate(ctx, 'batch1', `Org3MSP:${'ab'.repeat(32)}`, 'transfer', '2024-12-31');
+            ctx.stub.nextTransaction();
+            await contract.RevokeDelegate(ctx, 'batch1', delegation.delegationId);
+            ctx.stub.nextTransaction();
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+            await expect(contract.GrantDelegate(ctx, 'batch2', `Org3MSP:${'ab'.repeat(32)}`, 'transfer', '2024-12-31'))
+                .rejects.toThrow('Only the organization that registered batch batch2 (Org1MSP) can grant delegations');
+        });
+
+        test('should reject invalid fixtures', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+
+            ctx.stub.setTransient({ fixtures: JSON.stringify({ products: [{ productId: 'p1', batchId: 'nobatch', packageDate: '2024-09-18', owner: 'B' }] }) });
+            await expect(contract.InitLedger(ctx)).rejects.toThrow('references unknown batch nobatch');
+
+            ctx.stub.setTransient({ fixtures: JSON.stringify({ batches: [fixtures.batches[0], fixtures.batches[0]] }) });
+            await expect(contract.InitLedger(ctx)).rejects.toThrow('batch_qa-batch1 is defined more than once');
+        });
+    });
+
+    describe('Ledger Reset', () => {
+        const ADMIN_ID = 'x509::/C=US/O=Hyperledger/OU=admin/CN=Admin@org1.example.com::/C=US/O=org1.example.com/CN=ca.org1.example.com';
+
+        afterEach(() => {
+            delete process.env.RICETRACE_ALLOW_LEDGER_RESET;
+        });
+
+        const seedLedger = (ctx: MockContext) => {
+            ctx.stub.putJSON('batch_batch123', { docType: 'riceBatch', batchId: 'batch123', currentState: 'Stored', history: [] });
+            ctx.stub.putJSON('product_product123', { docType: 'product', productId: 'product123', owner: 'Retailer B' });
+            ctx.stub.putJSON('test_test1', { docType: 'testResult', testId: 'test1', batchId: 'batch123' });
+            ctx.stub.putJSON('workflow_mill-a', { docType: 'processingWorkflow', workflowId: 'mill-a', steps: [] });
+            ctx.stub.state.set(ctx.stub.createCompositeKey('step~batchId', ['Stored', 'batch123']), Buffer.from([0x00]));
+            ctx.stub.state.set(ctx.stub.createCompositeKey('owner~productId', ['Retailer B', 'product123']), Buffer.from([0x00]));
+        };
+
+        test('should delete traceability data and indexes when enabled', async () => {
+            process.env.RICETRACE_ALLOW_LEDGER_RESET = 'true';
+            const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+            seedLedger(ctx);
+
+            expect(await contract.ResetLedgerState(ctx)).toBe(5);
+            expect([...ctx.stub.state.keys()]).toEqual(['workflow_mill-a']);
+        });
+
+        test('should not leave recalls behind that re-seeded batches would inherit', async () => {
+            process.env.RICETRACE_ALLOW_LEDGER_RESET = 'true';
+            const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+            seedLedger(ctx);
+            ctx.stub.putJSON('recall_RC-001', { docType: 'recall', recallId: 'RC-001', batchIds: ['batch123'] });
+            ctx.stub.state.set(ctx.stub.createCompositeKey('recallEntity~recallId', ['batch123', 'RC-001']), Buffer.from([0x00]));
+
+            expect(await contract.ResetLedgerState(ctx)).toBe(7);
+            expect(ctx.stub.hasCompositeKey('recallEntity~recallId', ['batch123', 'RC-001'])).toBe(false);
+        });
+
+        test('should delete anchored documents and their indexes', async () => {
+            process.env.RICETRACE_ALLOW_LEDGER_RESET = 'true';
+            const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+            seedLedger(ctx);
+            ctx.stub.putJSON('document_DOC-1', { docType: 'anchoredDocument', documentId: 'DOC-1', entityId: 'batch123' });
+            ctx.stub.state.set(ctx.stub.createCompositeKey('entity~documentId', ['batch123', 'DOC-1']), Buffer.from([0x00]));
+            ctx.stub.state.set(ctx.stub.createCompositeKey('documentHash~documentId', ['ab'.repeat(32), 'DOC-1']), Buffer.from([0x00]));
+
+            expect(await contract.ResetLedgerState(ctx)).toBe(8);
+            expect([...ctx.stub.state.keys()]).toEqual(['workflow_mill-a']);
+        });
+
+        test('should refuse to reset unless enabled on the chaincode', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+            seedLedger(ctx);
+
+            await expect(contract.ResetLedgerState(ctx)).rejects.toThrow('Ledger reset is disabled');
+            expect(ctx.stub.getJSON('batch_batch123')).toBeDefined();
+        });
+
+        test('should reject non-admin callers', async () => {
+            process.env.RICETRACE_ALLOW_LEDGER_RESET = 'true';
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            await expect(contract.ResetLedgerState(ctx)).rejects.toThrow('Permission denied');
+        });
+    });
+
+    describe('Referential Integrity', () => {
+        const ADMIN_ID = 'x509::/C=US/O=Hyperledger/OU=admin/CN=Admin@org1.example.com::/C=US/O=org1.example.com/CN=ca.org1.example.com';
+
+        test('should report products without batches, unregistered parties and stale genealogy edges', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP', id: ADMIN_ID });
+            ctx.stub.putJSON('participant_farm-a', { docType: 'participant', participantId: 'farm-a', name: 'Farm A', mspId: 'Org1MSP' });
+            ctx.stub.putJSON('batch_batch1', {
+                docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Mill A', currentState: 'Milled',
+                history: [
+                    { timestamp: '2024-09-01T00:00:00.000Z', from: '', to: 'Farm A', step: 'Harvested', signerMspId: 'Org1MSP' },
+                    { timestamp: '2024-09-10T00:00:00.000Z', from: 'farm-a', to: 'Mill A', step: 'Milled', signerMspId: 'Org2MSP' },
+                    { timestamp: '2024-09-11T00:00:00.000Z', from: 'Mill A', to: 'Mill A', step: 'Polished', signerMspId: 'Org2MSP' }
+                ]
+            });
+            ctx.stub.putJSON('product_P1', { docType: 'product', productId: 'P1', batchId: 'batch1', owner: 'Farm A' });
+            ctx.stub.putJSON('product_P2', { docType: 'product', productId: 'P2', batchId: 'imported9', owner: 'Farm A' });
+            const packaged = (batchId: string, productId: string) =>
+                ctx.stub.state.set(ctx.stub.createCompositeKey('productBatch~productId', [batchId, productId]), Buffer.from([0x00]));
+            packaged('batch1', 'P1');
+            packaged('imported9', 'P2');
+            packaged('deleted7', 'P1');
+            packaged('batch1', 'P3');
+
+            const report = await contract.ValidateReferentialIntegrity(ctx);
+            expect(report).toEqual(expect.objectContaining({ batches: 1, products: 2, participants: 1, consistent: false }));
+            expect(report.issues.map(issue => [issue.rule, issue.entityId, issue.reference])).toEqual([
+                ['missingBatch', 'P2', 'imported9'],
+                ['unregisteredParticipant', 'batch1', 'Mill A'],
+                ['danglingGenealogyEdge', 'P3', 'batch1'],
+                ['danglingGenealogyEdge', 'P1', 'deleted7']
+            ]);
+            expect(report.issues[1]).toEqual(expect.objectContaining({ index: 1, message: expect.stringContaining('named in 3 record(s)') }));
+            expect(report.issues[3].repair).toContain('RebuildProductQueryIndexes');
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+            await expect(contract.ValidateReferentialIntegrity(ctx)).rejects.toThrow('Permission denied');
+        });
+    });
+
+    describe('Record Signer Verification', () => {
+        const OTHER_CERT = '-----BEGIN CERTIFICATE-----\nAAEF\n-----END CERTIFICATE-----\n';
+
+        test('should match the certificate that signed a batch history event', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            await contract.CreateRiceBatch(
+                ctx, 'batch1', 'Heilongjiang', 'Japonica', '2024-09-15', '{}', 'Farmer Zhang', 'Harvested', 'Farmer Zhang', '', '', '', ''
+            );
+
+            await expect(contract.VerifyRecordSigner(ctx, 'batch1', 0, TEST_CERT_PEM)).resolves.toBe(true);
+            await expect(contract.VerifyRecordSigner(ctx, 'batch1', 0, OTHER_CERT)).resolves.toBe(false);
+        });
+
+        test('should reject record indexes outside the batch history', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            await contract.CreateRiceBatch(
+                ctx, 'batch1', 'Heilongjiang', 'Japonica', '2024-09-15', '{}', 'Farmer Zhang', 'Harvested', 'Farmer Zhang', '', '', '', ''
+            );
+
+            await expect(contract.VerifyRecordSigner(ctx, 'batch1', 1, TEST_CERT_PEM))
+                .rejects.toThrow('Record index 1 is out of range for batch batch1 (1 records)');
+            await expect(contract.VerifyRecordSigner(ctx, 'batch1', -1, TEST_CERT_PEM)).rejects.toThrow('Record index -1 is out of range');
+            await expect(contract.VerifyRecordSigner(ctx, 'batch1', 0.5, TEST_CERT_PEM)).rejects.toThrow('Record index 0.5 is out of range');
+        });
+
+        test('should match the certificate that signed a test result, ignoring the record index', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('test_test1', { docType: 'testResult', testId: 'test1', batchId: 'batch1', signerFingerprint: certificateFingerprint(TEST_CERT_PEM) });
+            ctx.stub.putJSON('test_legacy', { docType: 'testResult', testId: 'legacy', batchId: 'batch1' });
+
+            await expect(contract.VerifyRecordSigner(ctx, 'test1', 7, TEST_CERT_PEM)).resolves.toBe(true);
+            await expect(contract.VerifyRecordSigner(ctx, 'test1', 0, OTHER_CERT)).resolves.toBe(false);
+            await expect(contract.VerifyRecordSigner(ctx, 'legacy', 0, TEST_CERT_PEM)).rejects.toThrow('Test result legacy has no signer fingerprint');
+            await expect(contract.VerifyRecordSigner(ctx, 'unknown', 0, TEST_CERT_PEM)).rejects.toThrow('No batch, product or test result found with ID unknown');
+        });
+
+        test('should match the certificate that signed a product sale or return', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            const products = new ProductManagementContract();
+            ctx.stub.putJSON('product_product1', {
+                docType: 'product', productId: 'product1', batchId: 'batch1', owner: 'Distributor A', ownerMspId: 'Org2MSP', status: 'Active', transfers: []
+            });
+            ctx.stub.putJSON('product_legacy', {
+                docType: 'product', productId: 'legacy', batchId: 'batch1', owner: 'Shop B', transfers: [{ timestamp: '2024-09-12T00:00:00.000Z', from: 'Mill A', to: 'Shop B', type: 'Sale' }]
+            });
+
+            await products.TransferProduct(ctx, 'product1', 'Retailer B', 'Org3MSP', '');
+            ctx.stub.nextTransaction();
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+            await products.ReturnProduct(ctx, 'product1', 'Damaged packaging', false, '');
+
+            expect(ctx.stub.getJSON('product_product1').transfers[0].signerFingerprint).toBe(certificateFingerprint(TEST_CERT_PEM));
+            await expect(contract.VerifyRecordSigner(ctx, 'product1', 0, TEST_CERT_PEM)).resolves.toBe(true);
+            await expect(contract.VerifyRecordSigner(ctx, 'product1', 1, TEST_CERT_PEM)).resolves.toBe(true);
+            await expect(contract.VerifyRecordSigner(ctx, 'product1', 1, OTHER_CERT)).resolves.toBe(false);
+            await expect(contract.VerifyRecordSigner(ctx, 'product1', 2, TEST_CERT_PEM))
+                .rejects.toThrow('Record index 2 is out of range for product product1 (2 transfers)');
+            await expect(contract.VerifyRecordSigner(ctx, 'legacy', 0, TEST_CERT_PEM)).rejects.toThrow('Transfer 0 of product legacy has no signer fingerprint');
+        });
+    });
+
+    describe('Processing Step Index', () => {
+        const ADMIN_ID = 'x509::/C=US/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/O=org2.example.com/CN=ca.org2.example.com';
+        const REPORT = JSON.stringify({ reportId: 'r1', reportType: 'Processing', reportHash: '', summary: 'Done', isVerified: false });
+        const ids = (batches: { batchId: string }[]) => batches.map(batch => batch.batchId);
+
+        const createBatches = async (ctx: MockContext) => {
+            for (const batchId of ['batch1', 'batch2']) {
+                await contract.CreateRiceBatch(
+                    ctx, batchId, 'Heilongjiang', 'Japonica', '2024-09-15', '{}', 'Farmer Zhang', 'Harvested', 'Farmer Zhang', '', '', '', ''
+                );
+            }
+        };
+
+        test('should list batches by their current step and move them between steps and owners', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            await createBatches(ctx);
+            expect(ids(await contract.GetRiceBatchesByProcessingStep(ctx, 'Harvested'))).toEqual(['batch1', 'batch2']);
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+            await contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Mill A', 'Milling', REPORT, '');
+
+            expect(ids(await contract.GetRiceBatchesByProcessingStep(ctx, 'Harvested'))).toEqual(['batch2']);
+            expect(ids(await contract.GetRiceBatchesByProcessingStep(ctx, 'Milling'))).toEqual(['batch1']);
+            expect(await contract.GetRiceBatchesByProcessingStep(ctx, 'Stored')).toEqual([]);
+            expect(ctx.stub.hasCompositeKey('step~batchId', ['Harvested', 'batch1'])).toBe(false);
+            expect(ctx.stub.hasCompositeKey('batchOwner~batchId', ['Farmer Zhang', 'batch1'])).toBe(false);
+            expect(ctx.stub.hasCompositeKey('batchOwner~batchId', ['Mill A', 'batch1'])).toBe(true);
+            expect(ctx.stub.hasCompositeKey('batchOwner~batchId', ['Farmer Zhang', 'batch2'])).toBe(true);
+
+            await expect(contract.GetRiceBatchesByProcessingStep(ctx, '')).rejects.toThrow('Processing step is required');
+        });
+
+        test('should skip stale step index entries and rebuild the index', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            await createBatches(ctx);
+            ctx.stub.state.set(ctx.stub.createCompositeKey('step~batchId', ['Milling', 'batch2']), Buffer.from([0x00]));
+            ctx.stub.putJSON('batch_legacy', { docType: 'riceBatch', batchId: 'legacy', currentOwner: 'Mill A', currentState: 'Milling', history: [] });
+
+            expect(await contract.GetRiceBatchesByProcessingStep(ctx, 'Milling')).toEqual([]);
+
+            await expect(contract.RebuildStepIndex(ctx)).rejects.toThrow('Permission denied');
+            ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP', id: ADMIN_ID });
+            await expect(contract.RebuildStepIndex(ctx)).resolves.toBe(3);
+            expect(ids(await contract.GetRiceBatchesByProcessingStep(ctx, 'Milling'))).toEqual(['legacy']);
+        });
+    });
+
+    describe('Tenant Isolation', () => {
+        test('should hide batches of other tenants and stamp new batches with the caller tenant', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP', attributes: { 'ricetrace.tenant': 'coop-a' } });
+            const create = (batchId: string) => contract.CreateRiceBatch(
+                ctx, batchId, 'Heilongjiang', 'Japonica', '2024-09-15', '{}', 'Farmer Zhang', 'Harvested', 'Farmer Zhang', '', '', '', ''
+            );
+            await create('batchA');
+            expect(ctx.stub.getJSON('batch_batchA').tenantId).toBe('coop-a');
+            ctx.stub.putJSON('batch_batchB', { docType: 'riceBatch', batchId: 'batchB', tenantId: 'coop-b', history: [] });
+            ctx.stub.putJSON('batch_shared', { docType: 'riceBatch', batchId: 'shared', history: [] });
+
+            expect((await contract.GetAllRiceBatches(ctx)).map(batch => batch.batchId)).toEqual(['batchA', 'shared']);
+            await expect(contract.ReadRiceBatch(ctx, 'batchB')).rejects.toThrow('does not exist');
+            await expect(contract.SetBatchLabels(ctx, 'batchB', '{}')).rejects.toThrow('does not exist');
+            // IDs are unique across tenants
+            await expect(create('batchB')).rejects.toThrow('already exists');
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP', attributes: { 'ricetrace.tenant': 'coop-b' } });
+            await expect(contract.ReadRiceBatch(ctx, 'batchA')).rejects.toThrow('does not exist');
+
+            // Identities without a tenant only see batches registered without one
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+            expect((await contract.GetAllRiceBatches(ctx)).map(batch => batch.batchId)).toEqual(['shared']);
+            await expect(contract.ReadRiceBatch(ctx, 'batchA')).rejects.toThrow('does not exist');
+        });
+
+        test('should not disclose the history of another tenant\'s batch', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP', attributes: { 'ricetrace.tenant': 'coop-a' } });
+            await contract.CreateRiceBatch(
+                ctx, 'batchA', 'Heilongjiang', 'Japonica', '2024-09-15', '{}', 'Farmer Zhang', 'Harvested', 'Farmer Zhang', '', '', '', ''
+            );
+            await expect(contract.GetBatchHistoryDiff(ctx, 'batchA', '', 'tx1')).resolves.toEqual(expect.objectContaining({ toTxId: 'tx1' }));
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP', attributes: { 'ricetrace.tenant': 'coop-b' } });
+            await expect(contract.GetBatchHistoryDiff(ctx, 'batchA', '', 'tx1')).rejects.toThrow('The rice batch batchA does not exist');
+        });
+
+        test('should not let another tenant commit values on a batch', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP', attributes: { 'ricetrace.tenant': 'coop-b' } });
+            ctx.stub.putJSON('batch_batchA', { docType: 'riceBatch', batchId: 'batchA', tenantId: 'coop-a', history: [] });
+
+            await expect(contract.CommitValue(ctx, 'batchA', 'pricePerTonne', 'a'.repeat(64))).rejects.toThrow('No batch, product or test result batchA');
+        });
+    });
+
+    describe('Commercial Terms', () => {
+        const TERMS = JSON.stringify({ pricePerTonne: 5200, currency: 'CNY', paymentDays: 30 });
+
+        const seedBatch = (ctx: MockContext, ownerMspId: string) => {
+            ctx.stub.putJSON('batch_batch123', {
+                docType: 'riceBatch',
+                batchId: 'batch123',
+                currentState: 'Milling',
+                history: [{ timestamp: '2024-09-20T00:00:00.000Z', from: 'Farm A', to: 'Mill A', step: 'Milling', signerMspId: ownerMspId }]
+            });
+        };
+
+        test('should store terms in the owning organization\'s implicit collection and commit their hash', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP', transient: { terms: TERMS } });
+            seedBatch(ctx, 'Org2MSP');
+
+            await contract.SetCommercialTerms(ctx, 'batch123');
+
+            const stored = ctx.stub.privateData.get('_implicit_org_Org2MSP')?.get('terms_batch123');
+            expect(JSON.parse((stored as Buffer).toString())).toEqual(expect.objectContaining({ batchId: 'batch123', mspId: 'Org2MSP', terms: TERMS }));
+            const commitment = ctx.stub.getJSON('terms_batch123_Org2MSP');
+            expect(commitment.termsHash).toHaveLength(64);
+            expect(JSON.stringify(commitment)).not.toContain('pricePerTonne');
+            expect(ctx.stub.validationParameters.has('terms_batch123_Org2MSP')).toBe(true);
+
+            await expect(contract.ReadCommercialTerms(ctx, 'batch123')).resolves.toEqual(expect.objectContaining({ terms: TERMS }));
+            await expect(contract.VerifyCommercialTerms(ctx, 'batch123', 'Org2MSP', TERMS)).resolves.toBe(true);
+            await expect(contract.VerifyCommercialTerms(ctx, 'batch123', 'Org2MSP', '{}')).resolves.toBe(false);
+        });
+
+        test('should reject organizations that do not own the batch', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP', transient: { terms: TERMS } });
+            seedBatch(ctx, 'Org2MSP');
+
+            await expect(contract.SetCommercialTerms(ctx, 'batch123')).rejects.toThrow('owned by Org2MSP');
+        });
+
+        test('should require a peer of the caller\'s organization and transient terms', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP', peerMspId: 'Org1MSP', transient: { terms: TERMS } });
+            seedBatch(ctx, 'Org2MSP');
+            await expect(contract.SetCommercialTerms(ctx, 'batch123')).rejects.toThrow('cannot use private data on a peer of Org1MSP');
+            await expect(contract.ReadCommercialTerms(ctx, 'batch123')).rejects.toThrow('cannot use private data on a peer of Org1MSP');
+
+            ctx.stub.setPeerMspId('');
+            ctx.stub.setTransient({});
+            await expect(contract.SetCommercialTerms(ctx, 'batch123')).rejects.toThrow('"terms" transient field');
+        });
+    });
+
+    describe('Value Commitments', () => {
+        const SALT = '9f1c2e7a4b6d8e0f1a2b3c4d';
+        const saltedHash = (salt: string, value: string) => createHash('sha256').update(`${salt}${value}`).digest('hex');
+
+        const seedBatch = (ctx: MockContext) => {
+            ctx.stub.putJSON('batch_batch123', { docType: 'riceBatch', batchId: 'batch123', currentState: 'Milling', history: [] });
+        };
+        const readCommitment = (ctx: MockContext, entityId: string, field: string, mspId: string) =>
+            ctx.stub.getJSON(ctx.stub.createCompositeKey('commit', [entityId, field, mspId]));
+
+        test('should commit a salted hash and accept the matching reveal', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            seedBatch(ctx);
+
+            await contract.CommitValue(ctx, 'batch123', 'pricePerTonne', saltedHash(SALT, '5200'));
+            const committed = readCommitment(ctx, 'batch123', 'pricePerTonne', 'Org2MSP');
+            expect(committed).toEqual(expect.objectContaining({ committedBy: 'Org2MSP', revealed: false }));
+            expect(committed.value).toBeUndefined();
+
+            ctx.stub.nextTransaction();
+            await contract.RevealValue(ctx, 'batch123', 'pricePerTonne', '5200', SALT);
+            expect(readCommitment(ctx, 'batch123', 'pricePerTonne', 'Org2MSP')).toEqual(expect.objectContaining({ revealed: true, value: '5200', salt: SALT, revealedBy: 'Org2MSP' }));
+            expect(ctx.stub.events[0].name).toBe('ValueRevealed');
+        });
+
+        test('should reject a reveal that does not match the commitment', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            seedBatch(ctx);
+            await contract.CommitValue(ctx, 'batch123', 'pricePerTonne', saltedHash(SALT, '5200'));
+
+            await expect(contract.RevealValue(ctx, 'batch123', 'pricePerTonne', '4800', SALT)).rejects.toThrow('do not match the commitment');
+            await expect(contract.RevealValue(ctx, 'batch123', 'pricePerTonne', '5200', 'short')).rejects.toThrow('Salt must be at least 16 characters');
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+            await expect(contract.RevealValue(ctx, 'batch123', 'pricePerTonne', '5200', SALT)).rejects.toThrow('Org1MSP has not committed a value for pricePerTonne of batch123');
+        });
+
+        test('should keep the commitments of each organization apart', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            seedBatch(ctx);
+            await contract.CommitValue(ctx, 'batch123', 'pricePerTonne', saltedHash(SALT, '5100'));
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+            await contract.CommitValue(ctx, 'batch123', 'pricePerTonne', saltedHash(SALT, '5200'));
+            await contract.RevealValue(ctx, 'batch123', 'pricePerTonne', '5200', SALT);
+
+            await expect(contract.GetValueCommitment(ctx, 'batch123', 'pricePerTonne', 'Org2MSP')).resolves.toEqual(expect.objectContaining({ committedBy: 'Org2MSP', revealed: false }));
+            await expect(contract.GetValueCommitment(ctx, 'batch123', 'pricePerTonne', 'Org1MSP')).resolves.toEqual(expect.objectContaining({ committedBy: 'Org1MSP', revealed: true, value: '5200' }));
+        });
+
+        test('should not confuse entity and field names containing underscores', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('batch_a_b', { docType: 'riceBatch', batchId: 'a_b', currentState: 'Milling', history: [] });
+            ctx.stub.putJSON('batch_a', { docType: 'riceBatch', batchId: 'a', currentState: 'Milling', history: [] });
+
+            await contract.CommitValue(ctx, 'a_b', 'c', saltedHash(SALT, '1'));
+            await contract.CommitValue(ctx, 'a', 'b_c', saltedHash(SALT, '2'));
+            expect(readCommitment(ctx, 'a', 'b_c', 'Org2MSP').saltedHash).toBe(saltedHash(SALT, '2'));
+        });
+
+        test('should not replace a commitment or commit to unknown entities', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            seedBatch(ctx);
+            await contract.CommitValue(ctx, 'batch123', 'moisture', saltedHash(SALT, '13.5'));
+
+            await expect(contract.CommitValue(ctx, 'batch123', 'moisture', saltedHash(SALT, '12.0'))).rejects.toThrow('Org2MSP has already committed a value for moisture of batch123');
+            await expect(contract.CommitValue(ctx, 'nobatch', 'moisture', saltedHash(SALT, '12.0'))).rejects.toThrow('No batch, product or test result nobatch');
+            await expect(contract.CommitValue(ctx, 'batch123', 'price', 'not-a-hash')).rejects.toThrow('64 hex characters');
+        });
+    });
+
+    describe('Daily Statistics', () => {
+        const seedActivity = (ctx: MockContext) => {
+            ctx.stub.putJSON('batch_batch1', {
+                docType: 'riceBatch',
+                batchId: 'batch1',
+                currentState: 'Milling',
+                history: [
+                    { timestamp: '2024-09-21T08:00:00.000Z', step: 'Harvested' },
+                    { timestamp: '2024-09-21T12:00:00.000Z', step: 'Milling' }
+                ]
+            });
+            ctx.stub.putJSON('batch_batch2', {
+                docType: 'riceBatch',
+                batchId: 'batch2',
+                currentState: 'Disposed',
+                history: [
+                    { timestamp: '2024-09-20T08:00:00.000Z', step: 'Harvested' },
+                    { timestamp: '2024-09-21T09:00:00.000Z', step: 'Disposed' }
+                ],
+                disposal: { reason: 'Recalled: aflatoxin', timestamp: '2024-09-21T09:00:00.000Z' }
+            });
+            ctx.stub.putJSON('product_product1', {
+                docType: 'product',
+                productId: 'product1',
+                batchId: 'batch1',
+                transfers: [{ timestamp: '2024-09-21T15:00:00.000Z', type: 'Sale' }, { timestamp: '2024-09-22T09:00:00.000Z', type: 'Return' }]
+            });
+            ctx.stub.putJSON('test_test1', { docType: 'testResult', testId: 'test1', batchId: 'batch1', testDate: '2024-09-21T00:00:00.000Z', testResult: 'Failed' });
+            ctx.stub.putJSON('test_test2', { docType: 'testResult', testId: 'test2', batchId: 'batch1', testDate: '2024-09-21T00:00:00.000Z', testResult: 'Passed' });
+        };
+
+        test('should summarize a completed day once', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            seedActivity(ctx);
+
+            const stats = await contract.SnapshotDailyStats(ctx, '2024-09-21');
+
+            expect(stats).toEqual(expect.objectContaining({
+                batchesCreated: 1,
+                batchTransfers: 1,
+                productTransfers: 1,
+                testsRecorded: 2,
+                failedTests: 1,
+                recalls: 1
+            }));
+            expect(ctx.stub.getJSON('stats_2024-09-21')).toEqual(stats);
+            await expect(contract.GetDailyStats(ctx, '2024-09-01', '2024-09-30')).resolves.toEqual([stats]);
+            await expect(contract.SnapshotDailyStats(ctx, '2024-09-21')).rejects.toThrow('already been recorded');
+        });
+
+        test('should only snapshot valid, completed days', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+
+            await expect(contract.SnapshotDailyStats(ctx, '2024-09-22')).rejects.toThrow('once the day is over');
+            await expect(contract.SnapshotDailyStats(ctx, '2024-02-30')).rejects.toThrow('out of range');
+            await expect(contract.SnapshotDailyStats(ctx, '21/09/2024')).rejects.toThrow('expected YYYY-MM-DD');
+        });
+    });
+
+    describe('Network KPIs', () => {
+        test('should report participants, stages, lead time and open issues', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('participant_farm-zhang', { docType: 'participant', participantId: 'farm-zhang', name: 'Farmer Zhang', role: 'Farmer', mspId: 'Org1MSP' });
+            ctx.stub.putJSON('participant_mill-a', { docType: 'participant', participantId: 'mill-a', name: 'Mill A', role: 'Processor', mspId: 'Org2MSP' });
+            ctx.stub.putJSON('participant_shop-b', { docType: 'participant', participantId: 'shop-b', name: 'Shop B', role: 'Retailer', mspId: 'Org3MSP' });
+            ctx.stub.putJSON('batch_batch1', {
+                docType: 'riceBatch', batchId: 'batch1', harvestDate: '2024-09-01T00:00:00.000Z', currentState: 'Packaged', currentOwner: 'Mill A',
+                history: [
+                    { timestamp: '2024-09-01T00:00:00.000Z', from: '', to: 'Farmer Zhang', step: 'Harvested' },
+                    { timestamp: '2024-09-05T00:00:00.000Z', from: 'Farmer Zhang', to: 'Mill A', step: 'Milling' },
+                    { timestamp: '2024-09-11T00:00:00.000Z', from: 'Mill A', to: 'Mill A', step: 'Packaged' }
+                ]
+            });
+            ctx.stub.putJSON('batch_batch2', {
+                docType: 'riceBatch', batchId: 'batch2', harvestDate: '2024-09-10T00:00:00.000Z', currentState: 'Stored', currentOwner: 'Farmer Li',
+                quarantined: true,
+                history: [{ timestamp: '2024-09-10T00:00:00.000Z', from: '', to: 'Farmer Li', step: 'Stored' }]
+            });
+            ctx.stub.putJSON('batch_batch3', {
+                docType: 'riceBatch', batchId: 'batch3', harvestDate: '2023-09-01T00:00:00.000Z', currentState: 'Packaged', currentOwner: 'Mill A',
+                history: [
+                    { timestamp: '2023-09-01T00:00:00.000Z', from: '', to: 'Farmer Wu', step: 'Harvested' },
+                    { timestamp: '2023-09-04T00:00:00.000Z', from: 'Farmer Wu', to: 'Mill A', step: 'Packaged' }
+                ]
+            });
+            ctx.stub.putJSON('product_P1', {
+                docType: 'product', productId: 'P1', batchId: 'batch1', owner: 'Shop B',
+                transfers: [{ timestamp: '2024-09-12T00:00:00.000Z', from: 'Mill A', to: 'Shop B', type: 'Sale' }]
+            });
+            ctx.stub.putJSON('settlement_batch1_1', { docType: 'settlement', batchId: 'batch1', eventIndex: 1, status: 'disputed' });
+            ctx.stub.putJSON('settlement_batch3_1', { docType: 'settlement', batchId: 'batch3', eventIndex: 1, status: 'paid' });
+
+            const kpis = await contract.GetNetworkKpis(ctx, '');
+
+            expect(kpis).toEqual(expect.objectContaining({
+                generatedAt: '2024-09-22T10:13:20.000Z',
+                activeSince: '2024-06-24T10:13:20.000Z',
+                registeredParticipants: 3,
+                activeParticipants: 3,
+                activeParties: 4,
+                batches: 3,
+                batchesByStage: { Packaged: 2, Stored: 1 },
+                averageHarvestToPackagingDays: 6.5,
+                packagedBatches: 2,
+                openQuarantines: 1,
+                openDisputes: 1
+            }));
+            await expect(contract.GetNetworkKpis(ctx, '5')).resolves.toEqual(expect.objectContaining({ activeParticipants: 0, activeParties: 0 }));
+            await expect(contract.GetNetworkKpis(ctx, '-1')).rejects.toThrow('activeDays must be a positive whole number');
+        });
+    });
+
+    describe('Cross-channel References', () => {
+        const FOREIGN_BATCH = {
+            docType: 'riceBatch',
+            batchId: 'hlj-001',
+            origin: 'Wuchang, Heilongjiang',
+            variety: 'Daohuaxiang',
+            harvestDate: '2024-09-10',
+            currentOwner: 'Export Hub',
+            currentState: 'Shipped',
+            history: [{ timestamp: '2024-09-12T00:00:00.000Z', from: 'Farm A', to: 'Export Hub', step: 'Shipped' }]
+        };
+
+        const foreignStateHash = async (batch: object) => {
+            const foreignCtx = createMockContext({ channelId: 'channel2' });
+            foreignCtx.stub.putJSON('batch_hlj-001', batch);
+            const { channel, stateHash } = await contract.GetBatchStateHash(foreignCtx, 'hlj-001');
+            expect(channel).toBe('channel2');
+            return stateHash;
+        };
+
+        const homeContext = (foreignBatch: object) => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('batch_js-001', { docType: 'riceBatch', batchId: 'js-001', currentState: 'Received', history: [] });
+            (ctx.stub.invokeChaincode as jest.Mock).mockResolvedValue({
+                status: 200,
+                message: '',
+                payload: Buffer.from(JSON.stringify(foreignBatch))
+            });
+            return ctx;
+        };
+
+        test('should link a foreign batch whose state hash matches and record its provenance', async () => {
+            const ctx = homeContext(FOREIGN_BATCH);
+
+            await contract.LinkForeignBatch(ctx, 'js-001', 'channel2', 'hlj-001', await foreignStateHash(FOREIGN_BATCH), 'basic');
+
+            expect(ctx.stub.invokeChaincode).toHaveBeenCalledWith('basic', ['ReadRiceBatch', 'hlj-001'], 'channel2');
+            const [reference] = ctx.stub.getJSON('batch_js-001').foreignReferences;
+            expect(reference).toEqual(expect.objectContaining({ channel: 'channel2', batchId: 'hlj-001', linkedBy: 'Org2MSP' }));
+            expect(reference.summary).toEqual(expect.objectContaining({ origin: 'Wuchang, Heilongjiang', variety: 'Daohuaxiang', historyLength: 1 }));
+            expect(ctx.stub.events[0].name).toBe('ForeignBatchLinked');
+
+            await expect(contract.LinkForeignBatch(ctx, 'js-001', 'channel2', 'hlj-001', reference.stateHash, 'basic'))
+                .rejects.toThrow('already references batch hlj-001');
+        });
+
+        test('should reject a stale hash, the own channel and unreachable channels', async () => {
+            const ctx = homeContext({ ...FOREIGN_BATCH, currentState: 'Disposed' });
+            const hash = await foreignStateHash(FOREIGN_BATCH);
+
+            await expect(contract.LinkForeignBatch(ctx, 'js-001', 'channel2', 'hlj-001', hash, 'basic')).rejects.toThrow('State hash does not match');
+            await expect(contract.LinkForeignBatch(ctx, 'js-001', 'channel1', 'hlj-001', hash, 'basic')).rejects.toThrow('foreign references must point to another channel');
+
+            (ctx.stub.invokeChaincode as jest.Mock).mockRejectedValueOnce(new Error('channel channel3 not found'));
+            await expect(contract.LinkForeignBatch(ctx, 'js-001', 'channel3', 'hlj-001', hash, 'basic')).rejects.toThrow('Cannot read batch hlj-001 from channel channel3');
+        });
+
+        test('should report whether the foreign batch changed since it was linked', async () => {
+            const ctx = homeContext(FOREIGN_BATCH);
+            await contract.LinkForeignBatch(ctx, 'js-001', 'channel2', 'hlj-001', await foreignStateHash(FOREIGN_BATCH), 'basic');
+
+            await expect(contract.VerifyForeignBatchReference(ctx, 'js-001', 'channel2', 'hlj-001'))
+                .resolves.toEqual(expect.objectContaining({ matches: true }));
+
+            (ctx.stub.invokeChaincode as jest.Mock).mockResolvedValueOnce({
+                status: 200,
+                message: '',
+                payload: Buffer.from(JSON.stringify({ ...FOREIGN_BATCH, currentOwner: 'Recycler' }))
+            });
+            const verification = await contract.VerifyForeignBatchReference(ctx, 'js-001', 'channel2', 'hlj-001');
+            expect(verification.matches).toBe(false);
+            expect(verification.summary.currentOwner).toBe('Recycler');
+
+            await expect(contract.VerifyForeignBatchReference(ctx, 'js-001', 'channel2', 'other')).rejects.toThrow('has no reference');
+        });
+    });
+
+    describe('Insurance', () => {
+        const POLICY_HASH = createHash('sha256').update('policy PICC-2024-0815').digest('hex');
+        const LOGGER_HASH = createHash('sha256').update('logger export truck 12').digest('hex');
+
+        const insuredContext = async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('batch_batch123', {
+                docType: 'riceBatch',
+                batchId: 'batch123',
+                currentState: 'Shipped',
+                history: [{ timestamp: '2024-09-20T00:00:00.000Z', from: 'Mill A', to: 'Carrier B', step: 'Shipped', signerMspId: 'Org2MSP' }]
+            });
+            ctx.stub.putJSON('test_test9', { docType: 'testResult', testId: 'test9', batchId: 'batch123', testResult: 'FAIL' });
+            ctx.stub.putJSON('test_other', { docType: 'testResult', testId: 'other', batchId: 'batch456', testResult: 'PASS' });
+            await contract.AttachInsurancePolicy(ctx, 'batch123', 'PICC', 'PICC-2024-0815', 'transport', '2024-09-01/2024-09-30', POLICY_HASH);
+            return ctx;
+        };
+
+        test('should attach a policy to the batch and file claims citing the batch\'s records', async () => {
+            const ctx = await insuredContext();
+            const [policy] = ctx.stub.getJSON('batch_batch123').insurancePolicies;
+            expect(policy).toEqual(expect.objectContaining({
+                insurer: 'PICC', coverage: 'transport', validFrom: '2024-09-01T00:00:00.000Z', validTo: '2024-09-30T23:59:59.999Z', attachedBy: 'Org2MSP'
+            }));
+            expect(ctx.stub.events[0].name).toBe('InsurancePolicyAttached');
+
+            await contract.ClaimInsurance(ctx, 'batch123', 'PICC', 'PICC-2024-0815', 'claim-1', '2024-09-30T14:00:00Z', 'Water damage in transit', JSON.stringify([
+                { type: 'testResult', reference: 'test9' },
+                { type: 'historyEvent', reference: '0' },
+                { type: 'document', reference: LOGGER_HASH.toUpperCase(), description: 'Humidity logger export' }
+            ]));
+
+            const [claim] = ctx.stub.getJSON('batch_batch123').insuranceClaims;
+            expect(claim).toEqual(expect.objectContaining({ claimId: 'claim-1', policyNumber: 'PICC-2024-0815', filedBy: 'Org2MSP' }));
+            expect(claim.evidence[2]).toEqual({ type: 'document', reference: LOGGER_HASH, description: 'Humidity logger export' });
+            expect(ctx.stub.events[0].name).toBe('InsuranceClaimFiled');
+        });
+
+        test('should accept weather observations covering the incident as evidence', async () => {
+            const ctx = await insuredContext();
+            ctx.stub.putJSON(`weather_${LOGGER_HASH}`, {
+                docType: 'weatherObservation', dataHash: LOGGER_HASH, plotId: 'plot-7', periodStart: '2024-09-20T00:00:00.000Z', periodEnd: '2024-09-21T23:59:59.999Z'
+            });
+            const claim = (claimId: string, incidentDate: string) => contract.ClaimInsurance(
+                ctx, 'batch123', 'PICC', 'PICC-2024-0815', claimId, incidentDate, 'Storm damage', JSON.stringify([{ type: 'weather', reference: LOGGER_HASH }])
+            );
+
+            await expect(claim('claim-1', '2024-09-25')).rejects.toThrow('does not cover the incident date');
+            await claim('claim-2', '2024-09-21T18:00:00Z');
+            expect(ctx.stub.getJSON('batch_batch123').insuranceClaims[0].evidence).toEqual([{ type: 'weather', reference: LOGGER_HASH }]);
+        });
+
+        test('should reject invalid policies', async () => {
+            const ctx = await insuredContext();
+
+            await expect(contract.AttachInsurancePolicy(ctx, 'batch123', 'PICC', 'PICC-2024-0815', 'transport', '2024-09-01/2024-09-30', POLICY_HASH))
+                .rejects.toThrow('already attached');
+            await expect(contract.AttachInsurancePolicy(ctx, 'batch123', 'PICC', 'P-2', 'fire', '2024-09-01/2024-09-30', POLICY_HASH))
+                .rejects.toThrow('Unknown insurance coverage fire');
+            await expect(contract.AttachInsurancePolicy(ctx, 'batch123', 'PICC', 'P-2', 'crop', '2024-09-30/2024-09-01', POLICY_HASH))
+                .rejects.toThrow('cannot be earlier than');
+            await expect(contract.AttachInsurancePolicy(ctx, 'batch123', 'PICC', 'P-2', 'crop', '2024-09-01', POLICY_HASH))
+                .rejects.toThrow('interval');
+            await expect(contract.AttachInsurancePolicy(ctx, 'batch123', 'PICC', 'P-2', 'crop', '2024-09-01/2024-09-30', 'abc'))
+                .rejects.toThrow('SHA-256');
+        });
+
+        test('should only accept claims by the policyholder within the validity, citing the batch\'s own records', async () => {
+            const ctx = await insuredContext();
+            const claim = (incidentDate: string, evidence: object[]) => contract.ClaimInsurance(
+                ctx, 'batch123', 'PICC', 'PICC-2024-0815', 'claim-1', incidentDate, 'Water damage in transit', JSON.stringify(evidence)
+            );
+
+            await expect(claim('2024-10-01', [{ type: 'testResult', reference: 'test9' }])).rejects.toThrow('outside the policy validity');
+            await expect(claim('2024-09-21', [])).rejects.toThrow('at least one piece of evidence');
+            await expect(claim('2024-09-21', [{ type: 'testResult', reference: 'other' }])).rejects.toThrow('does not concern the rice batch batch123');
+            await expect(claim('2024-09-21', [{ type: 'shipment', reference: 'missing' }])).rejects.toThrow('does not exist');
+            await expect(claim('2024-09-21', [{ type: 'historyEvent', reference: '1' }])).rejects.toThrow('has no history event 1');
+            await expect(claim('2024-09-21', [{ type: 'photo', reference: 'x' }])).rejects.toThrow('Unknown evidence type photo');
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+            await expect(claim('2024-09-21', [{ type: 'testResult', reference: 'test9' }])).rejects.toThrow('held by Org2MSP');
+        });
+    });
+
+    describe('Genealogy', () => {
+        const seedGenealogy = (ctx: MockContext) => {
+            ctx.stub.putJSON('batch_batch1', {
+                docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Mill', currentState: 'Packaging', history: [],
+                foreignReferences: [{ channel: 'farm-channel', batchId: 'hlj-001', linkedAt: '2024-09-15T00:00:00.000Z' }]
+            });
+            ctx.stub.putJSON('batch_batch2', { docType: 'riceBatch', batchId: 'batch2', currentOwner: 'Farm', currentState: 'Harvested', history: [] });
+            ctx.stub.putJSON('product_p1', { docType: 'product', productId: 'p1', batchId: 'batch1', owner: 'Shop', status: 'Sold', packageDate: '2024-09-20' });
+            ctx.stub.putJSON('product_p2', { docType: 'product', productId: 'p2', batchId: 'batch1', owner: 'Mill', status: 'Active', packageDate: '2024-09-20' });
+            ctx.stub.putJSON('product_p3', { docType: 'product', productId: 'p3', batchId: 'batch2', owner: 'Farm', status: 'Active', packageDate: '2024-09-21' });
+        };
+
+        test('should return the products and linked batches of a batch with annotated edges', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            seedGenealogy(ctx);
+
+            const genealogy = await contract.GetBatchGenealogy(ctx, 'batch1', '');
+
+            expect(genealogy.depth).toBe(3);
+            expect(genealogy.truncated).toBe(false);
+            expect(genealogy.nodes).toEqual([
+                { id: 'batch1', type: 'batch', generation: 0, owner: 'Mill', state: 'Packaging' },
+                { id: 'p1', type: 'product', generation: 1, owner: 'Shop', state: 'Sold' },
+                { id: 'p2', type: 'product', generation: 1, owner: 'Mill', state: 'Active' },
+                { id: 'farm-channel/hlj-001', type: 'foreignBatch', generation: -1 }
+            ]);
+            expect(genealogy.edges).toEqual([
+                { from: 'batch1', to: 'p1', operation: 'packaging', at: '2024-09-20' },
+                { from: 'batch1', to: 'p2', operation: 'packaging', at: '2024-09-20' },
+                { from: 'farm-channel/hlj-001', to: 'batch1', operation: 'link', at: '2024-09-15T00:00:00.000Z' }
+            ]);
+        });
+
+        test('should follow the requested depth and validate it', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            seedGenealogy(ctx);
+
+            const genealogy = await contract.GetBatchGenealogy(ctx, 'batch2', '1');
+            expect(genealogy.nodes.map(node => node.id)).toEqual(['batch2', 'p3']);
+            expect(genealogy.truncated).toBe(false);
+
+            await expect(contract.GetBatchGenealogy(ctx, 'batch1', '0')).rejects.toThrow('Invalid depth 0');
+            await expect(contract.GetBatchGenealogy(ctx, 'batch1', '11')).rejects.toThrow('from 1 to 10');
+            await expect(contract.GetBatchGenealogy(ctx, 'missing', '')).rejects.toThrow('does not exist');
+        });
+    });
+
+    describe('History Diff', () => {
+        const writeVersions = async (ctx: MockContext) => {
+            const batch = { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farm', currentState: 'Harvested', history: [{ step: 'Harvested' }] };
+            await ctx.stub.putState('batch_batch1', Buffer.from(JSON.stringify(batch)));
+            ctx.stub.nextTransaction();
+            ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS + 60);
+            const milled = { ...batch, currentOwner: 'Mill', currentState: 'Milling', history: [...batch.history, { step: 'Milling' }] };
+            await ctx.stub.putState('batch_batch1', Buffer.from(JSON.stringify(milled)));
+            ctx.stub.nextTransaction();
+            ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS + 120);
+            await ctx.stub.putState('batch_batch1', Buffer.from(JSON.stringify({ ...milled, quarantined: true })));
+        };
+
+        test('should show what a transaction modified, or the changes between two versions', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            await writeVersions(ctx);
+
+            const diff = await contract.GetBatchHistoryDiff(ctx, 'batch1', '', 'tx2');
+            expect(diff).toEqual(expect.objectContaining({ fromTxId: 'tx1', toTxId: 'tx2', toTimestamp: '2024-09-22T10:14:20.000Z' }));
+            expect(diff.changes).toEqual([
+                { path: 'currentOwner', change: 'modified', before: '"Farm"', after: '"Mill"' },
+                { path: 'currentState', change: 'modified', before: '"Harvested"', after: '"Milling"' },
+                { path: 'history[1]', change: 'added', after: '{"step":"Milling"}' }
+            ]);
+
+            const overall = await contract.GetBatchHistoryDiff(ctx, 'batch1', 'tx1', 'tx3');
+            expect(overall.changes.map(change => change.path)).toEqual(['currentOwner', 'currentState', 'history[1]', 'quarantined']);
+
+            const creation = await contract.GetBatchHistoryDiff(ctx, 'batch1', '', 'tx1');
+            expect(creation.fromTxId).toBe('');
+            expect(creation.changes.every(change => change.change === 'added')).toBe(true);
+        });
+
+        test('should reject transactions that did not write the batch', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            await writeVersions(ctx);
+
+            await expect(contract.GetBatchHistoryDiff(ctx, 'batch1', 'tx9', 'tx2')).rejects.toThrow('Transaction tx9 did not write the rice batch batch1');
+            await expect(contract.GetBatchHistoryDiff(ctx, 'missing', '', 'tx1')).rejects.toThrow('does not exist');
+        });
+    });
+
+    describe('Labels', () => {
+        test('should label batches and find them by label', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', history: [] });
+            ctx.stub.putJSON('batch_batch2', { docType: 'riceBatch', batchId: 'batch2', history: [] });
+
+            await contract.SetBatchLabels(ctx, 'batch1', JSON.stringify({ 'export-market': 'JP', 'coop-id': 'HLJ-017' }));
+            await contract.SetBatchLabels(ctx, 'batch2', JSON.stringify({ 'export-market': 'SG' }));
+            expect(ctx.stub.events[0].name).toBe('BatchLabelsChanged');
+            expect(ctx.stub.getJSON('batch_batch1').labels).toEqual({ 'export-market': 'JP', 'coop-id': 'HLJ-017' });
+
+            const byMarket = async (value: string) => (await contract.GetRiceBatchesByLabel(ctx, 'export-market', value)).map(batch => batch.batchId);
+            expect(await byMarket('JP')).toEqual(['batch1']);
+            expect(await byMarket('')).toEqual(['batch1', 'batch2']);
+
+            // Replacing the labels moves the batch in the index
+            await contract.SetBatchLabels(ctx, 'batch1', JSON.stringify({ 'export-market': 'SG' }));
+            expect(await byMarket('JP')).toEqual([]);
+            expect(await byMarket('SG')).toEqual(['batch1', 'batch2']);
+            await expect(contract.GetRiceBatchesByLabel(ctx, 'coop-id', '')).resolves.toEqual([]);
+        });
+
+        test('should reject invalid labels and consumers', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', history: [] });
+
+            await expect(contract.SetBatchLabels(ctx, 'batch1', '{"fabric.mspid":"Org2MSP"}')).rejects.toThrow('reserved prefix');
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+            await expect(contract.SetBatchLabels(ctx, 'batch1', '{"export-market":"JP"}')).rejects.toThrow('Permission denied');
+        });
+    });
+
+    describe('Step Evidence', () => {
+        test('should store geolocation and temperature log evidence and reject malformed evidence', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farmer Zhang', currentState: 'Planted', history: [] });
+            const report = (evidence: object) => JSON.stringify({ reportId: 'r1', reportType: 'HarvestLog', reportHash: '', summary: 'Harvested', isVerified: false, ...evidence });
+
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Harvested', report({ geolocation: { latitude: 95, longitude: 127.16 } }), ''))
+                .rejects.toThrow('latitude (-90 to 90)');
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Harvested', report({ temperatureLogHash: 'abc' }), ''))
+                .rejects.toThrow('Temperature log hash');
+
+            await contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Harvested', report({
+                geolocation: { latitude: 44.92, longitude: 127.16 }, temperatureLogHash: 'AB'.repeat(32)
+            }), '');
+            const stored = ctx.stub.getJSON('batch_batch1').history[0].report;
+            expect(stored.geolocation).toEqual({ latitude: 44.92, longitude: 127.16 });
+            expect(stored.temperatureLogHash).toBe('ab'.repeat(32));
+        });
+    });
+
+    describe('Duplicate Steps', () => {
+        test('should reject a step identical to the last recorded one', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farmer Zhang', currentState: 'Harvested', history: [] });
+            const report = (reportId: string) => JSON.stringify({ reportId, reportType: 'ProcessingRecord', reportHash: `hash-${reportId}`, summary: 'Dried', isVerified: false });
+
+            await contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', report('r1'), '');
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', report('r1'), ''))
+                .rejects.toThrow('Step Drying of batch batch1 already exists');
+
+            // A second pass backed by its own report is a new step
+            await contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', report('r2'), '');
+            expect(ctx.stub.getJSON('batch_batch1').history).toHaveLength(2);
+        });
+    });
+
+    describe('Step Chronology', () => {
+        const report = (fields: object = {}) => JSON.stringify({ reportId: 'r1', reportType: 'ProcessingRecord', reportHash: '', summary: 'Dried', isVerified: true, ...fields });
+        const putBatch = (ctx: MockContext, timestamp: string) => ctx.stub.putJSON('batch_batch1', {
+            docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farmer Zhang', currentState: 'Harvested',
+            history: [{ timestamp, from: '', to: 'Farmer Zhang', step: 'Harvested', signerMspId: 'Org1MSP' }]
+        });
+
+        test('should reject a step submitted before the previous record', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            putBatch(ctx, '2024-09-23T00:00:00.000Z');
+
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', report(), ''))
+                .rejects.toThrow('Transfer time (2024-09-22T10:13:20.000Z) cannot be earlier than previous Harvested event (2024-09-23T00:00:00.000Z)');
+        });
+
+        test('should reject a step whose report is backdated before the previous record', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            putBatch(ctx, '2024-09-10T00:00:00.000Z');
+
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', report({ verificationTimestamp: '2024-09-01' }), ''))
+                .rejects.toThrow('Report verificationTimestamp (2024-09-01T00:00:00.000Z) cannot be earlier than previous Harvested event (2024-09-10T00:00:00.000Z)');
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', report({ verificationTimestamp: 'last week' }), ''))
+                .rejects.toThrow('Invalid Report verificationTimestamp "last week"');
+            await expect(contract.AddProcessingRecords(ctx, 'batch1', JSON.stringify([
+                { step: 'Drying', report: { reportId: 'r1', reportType: 'ProcessingRecord', reportHash: '', summary: 'Dried', isVerified: true, verificationTimestamp: '2024-09-01' } }
+            ]), '')).rejects.toThrow('Report verificationTimestamp (2024-09-01T00:00:00.000Z) cannot be earlier than previous Harvested event');
+            const check = await contract.CanTransferRiceBatch(ctx, 'batch1', 'Farmer Zhang', 'Drying', report({ verificationTimestamp: '2024-09-01' }));
+            expect(check.blockers.map(blocker => blocker.rule)).toEqual(['chronology']);
+            expect(ctx.stub.getJSON('batch_batch1').history).toHaveLength(1);
+
+            await contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', report({ verificationTimestamp: '2024-09-22T09:00:00Z' }), '');
+            expect(ctx.stub.getJSON('batch_batch1').history[1].report.verificationTimestamp).toBe('2024-09-22T09:00:00.000Z');
+        });
+    });
+
+    describe('Processing Record Batches', () => {
+        const record = (step: string, reportId: string, timestamp?: string) => ({
+            step, timestamp, report: { reportId, reportType: 'ProcessingRecord', reportHash: `hash-${reportId}`, summary: step, isVerified: false }
+        });
+
+        test('should add a run of processing records in one transaction', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Mill A', currentState: 'Milled', history: [] });
+
+            const added = await contract.AddProcessingRecords(ctx, 'batch1', JSON.stringify([
+                record('Polishing', 'r1', '2024-09-21T08:00:00Z'), record('Sorting', 'r2', '2024-09-21T08:00:05Z'), record('Packaging', 'r3')
+            ]), 'req-1');
+            expect(added).toBe(3);
+
+            const batch = ctx.stub.getJSON('batch_batch1');
+            expect(batch.history.map((event: any) => event.step)).toEqual(['Polishing', 'Sorting', 'Packaging']);
+            expect(batch.history[1]).toEqual(expect.objectContaining({ timestamp: '2024-09-21T08:00:05.000Z', from: 'Mill A', to: 'Mill A', signerMspId: 'Org2MSP' }));
+            expect(batch.currentState).toBe('Packaging');
+            expect(ctx.stub.events).toHaveLength(1);
+            expect(ctx.stub.events[0].name).toBe('BatchStepCompleted');
+
+            // A retry of the same request is not applied twice
+            ctx.stub.nextTransaction();
+            await expect(contract.AddProcessingRecords(ctx, 'batch1', JSON.stringify([record('Polishing', 'r4')]), 'req-1')).resolves.toBe(0);
+            expect(ctx.stub.getJSON('batch_batch1').history).toHaveLength(3);
+        });
+
+        test('should add none of the records when one is invalid', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Mill A', currentState: 'Milled', history: [] });
+
+            await expect(contract.AddProcessingRecords(ctx, 'batch1', JSON.stringify([
+                record('Polishing', 'r1', '2024-09-21T08:00:00Z'), record('Polishing', 'r1', '2024-09-21T08:00:01Z'), { report: {} }
+            ]), '')).rejects.toThrow(/2 of 3 processing records of batch batch1 are invalid, none were added: record 1: .*identical.*; record 2: step is required/);
+            expect(ctx.stub.getJSON('batch_batch1').history).toHaveLength(0);
+
+            await expect(contract.AddProcessingRecords(ctx, 'batch1', '[]', '')).rejects.toThrow('1 to 100 records');
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+            await expect(contract.AddProcessingRecords(ctx, 'batch1', JSON.stringify([record('Polishing', 'r1')]), '')).rejects.toThrow();
+        });
+    });
+
+    describe('Quality Gates', () => {
+        const ADMIN_ID = 'x509::/C=US/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/O=org2.example.com/CN=ca.org2.example.com';
+        const report = (fields: object = {}) => JSON.stringify({ reportId: 'r1', reportType: 'Processing', reportHash: '', summary: 'Done', isVerified: false, ...fields });
+
+        const putBatch = (ctx: MockContext, fields: object = {}) => {
+            ctx.stub.putJSON('batch_batch1', {
+                docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Mill A', currentState: 'Dried',
+                history: [
+                    { timestamp: '2024-09-01T00:00:00.000Z', from: '', to: 'Farmer Zhang', step: 'Harvested', signerMspId: 'Org1MSP' },
+                    { timestamp: '2024-09-10T00:00:00.000Z', from: 'Farmer Zhang', to: 'Mill A', step: 'Dried', signerMspId: 'Org2MSP' }
+                ],
+                ...fields
+            });
+        };
+        const putMoistureTest = (ctx: MockContext, testDate: string) => {
+            ctx.stub.putJSON('test_t1', { docType: 'testResult', testId: 't1', batchId: 'batch1', testType: 'Moisture', testDate, testResult: 'Passed' });
+        };
+        const putCertificate = (ctx: MockContext, fields: object = {}) => {
+            ctx.stub.putJSON('cert_c1', {
+                docType: 'qualityCertificate', certificateId: 'c1', batchId: 'batch1', certificateType: 'Phytosanitary',
+                issueDate: '2024-09-15T00:00:00.000Z', validityPeriod: '6 months', isActive: true, ...fields
+            });
+        };
+
+        test('should only package a batch with a passed moisture test taken after drying', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            putBatch(ctx);
+            putMoistureTest(ctx, '2024-09-05T00:00:00.000Z');
+
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Mill A', 'Packaged', report(), ''))
+                .rejects.toThrow('cannot be Packaged without a passed moisture test after it was Dried (2024-09-10T00:00:00.000Z)');
+
+            putMoistureTest(ctx, '2024-09-12T00:00:00.000Z');
+            await contract.CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Mill A', 'Packaged', report(), '');
+            expect(ctx.stub.getJSON('batch_batch1').currentState).toBe('Packaged');
+        });
+
+        test('should not ship a quarantined batch', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            putBatch(ctx, { quarantined: true, quarantineReason: 'Pest sighting' });
+
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Carrier B', 'Shipped', report({ destinationCountry: 'CN' }), ''))
+                .rejects.toThrow('cannot be Shipped while quarantined: Pest sighting');
+        });
+
+        test('should require the destination of a shipment', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            putBatch(ctx);
+
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Carrier B', 'Shipped', report(), ''))
+                .rejects.toThrow('cannot be Shipped without the destination country');
+            await contract.CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Carrier B', 'Shipped', report({ destinationCountry: 'cn' }), '');
+            expect(ctx.stub.getJSON('batch_batch1').currentOwner).toBe('Carrier B');
+        });
+
+        test('should only export a batch with a phytosanitary certificate in force', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            putBatch(ctx);
+            putCertificate(ctx, { isActive: false });
+
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Carrier B', 'Shipped', report({ destinationCountry: 'JP' }), ''))
+                .rejects.toThrow('cannot be Shipped to JP without a valid phytosanitary certificate');
+
+            putCertificate(ctx, { validityPeriod: '3 days' });
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Carrier B', 'Shipped', report({ destinationCountry: 'JP' }), ''))
+                .rejects.toThrow('without a valid phytosanitary certificate');
+
+            putCertificate(ctx);
+            await contract.CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Carrier B', 'Shipped', report({ destinationCountry: 'JP' }), '');
+            expect(ctx.stub.getJSON('batch_batch1').currentState).toBe('Shipped');
+        });
+
+        test('should treat shipments to the configured domestic market as domestic', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+            putBatch(ctx);
+            await new ComplianceProfileContract().DefineDomesticMarket(ctx, 'th');
+
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Carrier B', 'Shipped', report({ destinationCountry: 'CN' }), ''))
+                .rejects.toThrow('cannot be Shipped to CN without a valid phytosanitary certificate');
+            await contract.CompleteStepAndTransfer(ctx, 'batch1', 'Mill A', 'Carrier B', 'Shipped', report({ destinationCountry: 'TH' }), '');
+            expect(ctx.stub.getJSON('batch_batch1').currentState).toBe('Shipped');
+        });
+    });
+
+    describe('Transfer Checks', () => {
+        test('should list every rule that would reject a transfer without recording it', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            ctx.stub.putJSON('batch_batch1', {
+                docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farmer Zhang', currentState: 'Harvested',
+                quarantined: true, quarantineReason: 'Pest sighting',
+                history: [{ timestamp: '2024-09-01T00:00:00.000Z', from: '', to: 'Farmer Zhang', step: 'Harvested', signerMspId: 'Org1MSP' }]
+            });
+            const report = JSON.stringify({ reportId: 'r1', reportType: 'ShippingManifest', reportHash: '', summary: 'Shipped', isVerified: false, equipmentId: 'missing' });
+
+            const check = await contract.CanTransferRiceBatch(ctx, 'batch1', 'Distributor B', 'Shipped', report);
+            expect(check.allowed).toBe(false);
+            expect(check.blockers.map(blocker => blocker.rule)).toEqual(['permission', 'qualityGates', 'equipment']);
+            expect(check.blockers[1].message).toContain('cannot be Shipped while quarantined: Pest sighting');
+            expect(ctx.stub.getJSON('batch_batch1').history).toHaveLength(1);
+
+            // Without a step only the step-independent rules apply
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+            await expect(contract.CanTransferRiceBatch(ctx, 'batch1', 'Distributor B', '', '')).resolves.toEqual({
+                batchId: 'batch1', newOwner: 'Distributor B', step: '', allowed: true, blockers: []
+            });
+            await expect(contract.CanTransferRiceBatch(ctx, 'missing', 'Distributor B', '', '')).rejects.toThrow('does not exist');
+        });
+    });
+
+    describe('Owner Inventory', () => {
+        test('should total an owner\'s batches by variety and step and list its products', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            const storeBatch = (batchId: string, variety: string, currentState: string, extra: object = {}) => {
+                ctx.stub.putJSON(`batch_${batchId}`, { docType: 'riceBatch', batchId, variety, currentState, currentOwner: 'Mill A', history: [], ...extra });
+                ctx.stub.state.set(ctx.stub.createCompositeKey('batchOwner~batchId', ['Mill A', batchId]), Buffer.from([0x00]));
+            };
+            storeBatch('batch1', 'Japonica', 'Milled', {
+                quantityKg: 1000,
+                reservations: [{ reservationId: 'r1', buyer: 'Shop B', quantityKg: 300, status: 'Active', expiresAt: '2024-10-01T00:00:00.000Z' }]
+            });
+            storeBatch('batch2', 'Japonica', 'Dried', { quantityKg: 500 });
+            storeBatch('batch3', 'Indica', 'Milled');
+            storeBatch('batch4', 'Indica', 'Disposed', { quantityKg: 200 });
+            // Stale index entry of a batch that has moved on
+            ctx.stub.state.set(ctx.stub.createCompositeKey('batchOwner~batchId', ['Mill A', 'batch5']), Buffer.from([0x00]));
+            ctx.stub.putJSON('batch_batch5', { docType: 'riceBatch', batchId: 'batch5', variety: 'Indica', currentState: 'Shipped', currentOwner: 'Shop B', history: [] });
+            for (const [productId, status] of [['P1', 'Active'], ['P2', 'Sold']]) {
+                ctx.stub.putJSON(`product_${productId}`, { docType: 'product', productId, batchId: 'batch1', owner: 'Mill A', status });
+                ctx.stub.state.set(ctx.stub.createCompositeKey('owner~productId', ['Mill A', productId]), Buffer.from([0x00]));
+            }
+
+            const inventory = await contract.GetOwnerInventory(ctx, 'Mill A');
+            expect(inventory.batches.map(batch => batch.batchId)).toEqual(['batch1', 'batch2', 'batch3']);
+            expect(inventory.batches[0]).toEqual({ batchId: 'batch1', variety: 'Japonica', currentState: 'Milled', quantityKg: 1000, reservedKg: 300, availableKg: 700 });
+            expect(inventory.products.map(product => product.productId)).toEqual(['P1']);
+            expect(inventory.totals).toEqual({ batches: 3, batchesWithoutQuantity: 1, quantityKg: 1500, reservedKg: 300, availableKg: 1200 });
+            expect(inventory.byVariety.Japonica).toEqual(expect.objectContaining({ batches: 2, availableKg: 1200 }));
+            expect(inventory.byStep.Milled).toEqual(expect.objectContaining({ batches: 2, batchesWithoutQuantity: 1, quantityKg: 1000 }));
+            await expect(contract.GetOwnerInventory(ctx, '')).rejects.toThrow('Owner is required');
+        });
+    });
+
+    describe('Batch Comparison', () => {
+        test('should put lots side by side with their latest tests, grades and active certificates', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            ctx.stub.putJSON('batch_batch1', {
+                docType: 'riceBatch', batchId: 'batch1', origin: 'Wuchang', variety: 'Daohuaxiang', harvestDate: '2024-09-01T00:00:00.000Z',
+                currentState: 'Milled', currentOwner: 'Mill A', history: [], quantityKg: 1000,
+                reservations: [{ reservationId: 'r1', buyer: 'Shop B', quantityKg: 400, status: 'Active', expiresAt: '2024-10-01T00:00:00.000Z' }],
+                giCompliance: { giId: 'wuchang', giName: 'Wuchang Rice', passed: true, violations: [] }
+            });
+            ctx.stub.putJSON('batch_batch2', {
+                docType: 'riceBatch', batchId: 'batch2', origin: 'Panjin', variety: 'Japonica', harvestDate: '2024-09-05T00:00:00.000Z',
+                currentState: 'Dried', currentOwner: 'Farmer Li', history: [], quarantined: true
+            });
+            const storeTest = (testId: string, batchId: string, testType: string, testResult: string, testDate: string, extra: object = {}) =>
+                ctx.stub.putJSON(`test_${testId}`, { docType: 'testResult', testId, batchId, testType, testResult, testDate, isVerified: false, ...extra });
+            storeTest('t1', 'batch1', 'Moisture', 'Fail', '2024-09-10T00:00:00.000Z');
+            storeTest('t2', 'batch1', 'Moisture', 'Pass', '2024-09-12T00:00:00.000Z', { laboratory: 'Lab A', isVerified: true });
+            storeTest('t3', 'batch1', 'Heavy metals', 'Pass', '2024-09-15T00:00:00.000Z', { revoked: true });
+            storeTest('t4', 'batch2', 'Pesticide residue', 'Pass', '2024-09-11T00:00:00.000Z');
+            const storeCert = (certificateId: string, certificateType: string, isActive: boolean, validityPeriod: string) =>
+                ctx.stub.putJSON(`cert_${certificateId}`, {
+                    docType: 'qualityCertificate', certificateId, batchId: 'batch1', certificateType, issuer: 'CQC', standards: 'GB/T 19630',
+                    issueDate: '2024-01-01T00:00:00.000Z', validityPeriod, isActive
+                });
+            storeCert('c1', 'Organic', true, '1 year');
+            storeCert('c2', 'Green Food', true, '6 months');
+            storeCert('c3', 'Phytosanitary', false, '');
+            for (const [productId, grade] of [['P1', 'Grade 1 (GB/T 1354)'], ['P2', 'Grade 1 (GB/T 1354)'], ['P3', 'Grade 2 (GB/T 1354)']]) {
+                ctx.stub.putJSON(`product_${productId}`, { docType: 'product', productId, batchId: 'batch1', composition: { ingredients: [], grade } });
+                ctx.stub.state.set(ctx.stub.createCompositeKey('productBatch~productId', ['batch1', productId]), Buffer.from([0x00]));
+            }
+
+            const comparison = await contract.CompareBatches(ctx, JSON.stringify(['batch2', 'batch1', 'batch2']));
+            expect(comparison.batches.map(batch => batch.batchId)).toEqual(['batch2', 'batch1']);
+            expect(comparison.testTypes).toEqual(['Moisture', 'Pesticide residue']);
+            expect(comparison.certificateTypes).toEqual(['Organic']);
+
+            const [second, first] = comparison.batches;
+            expect(first).toEqual(expect.objectContaining({
+                origin: 'Wuchang', variety: 'Daohuaxiang', availableKg: 600, geographicIndication: 'Wuchang Rice', quarantined: false,
+                grades: ['Grade 1 (GB/T 1354)', 'Grade 2 (GB/T 1354)']
+            }));
+            expect(first.qualityMetrics).toEqual({
+                Moisture: { testId: 't2', result: 'Pass', passed: true, testDate: '2024-09-12T00:00:00.000Z', laboratory: 'Lab A', isVerified: true }
+            });
+            expect(first.certifications).toEqual([
+                { certificateId: 'c1', certificateType: 'Organic', issuer: 'CQC', standards: 'GB/T 19630', expiresAt: '2025-01-01T00:00:00.000Z' }
+            ]);
+            expect(second).toEqual(expect.objectContaining({ availableKg: undefined, geographicIndication: undefined, quarantined: true, grades: [], certifications: [] }));
+            expect(Object.keys(second.qualityMetrics)).toEqual(['Pesticide residue']);
+        });
+
+        test('should reject malformed, too short and unknown batch lists', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            ctx.stub.putJSON('batch_batch1', { docType: 'riceBatch', batchId: 'batch1', history: [] });
+
+            await expect(contract.CompareBatches(ctx, 'batch1,batch2')).rejects.toThrow('Batch IDs format error');
+            await expect(contract.CompareBatches(ctx, JSON.stringify(['batch1', 7]))).rejects.toThrow('must be a list of batch IDs');
+            await expect(contract.CompareBatches(ctx, JSON.stringify(['batch1', 'batch1']))).rejects.toThrow('Between 2 and 10 different batches');
+            await expect(contract.CompareBatches(ctx, JSON.stringify(Array.from({ length: 11 }, (_, i) => `batch${i}`)))).rejects.toThrow('got 11');
+            await expect(contract.CompareBatches(ctx, JSON.stringify(['batch1', 'missing']))).rejects.toThrow('The rice batch missing does not exist');
+        });
+    });
+
+    describe('Paginated Batch Listing', () => {
+        test('should page through every batch in ID order', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            for (const batchId of ['batch3', 'batch1', 'batch2']) {
+                ctx.stub.putJSON(`batch_${batchId}`, { docType: 'riceBatch', batchId, currentOwner: 'Farm A', history: [] });
+            }
+            ctx.stub.putJSON('product_P1', { docType: 'product', productId: 'P1', batchId: 'batch1' });
+
+            const first = await contract.GetRiceBatchesWithPagination(ctx, 2, '');
+            expect(first.batches.map(batch => batch.batchId)).toEqual(['batch1', 'batch2']);
+            expect(first.bookmark).not.toBe('');
+
+            const second = await contract.GetRiceBatchesWithPagination(ctx, 2, first.bookmark);
+            expect(second.batches.map(batch => batch.batchId)).toEqual(['batch3']);
+            expect(second.bookmark).toBe('');
+
+            await expect(contract.GetRiceBatchesWithPagination(ctx, 0, '')).rejects.toThrow('Invalid page size');
+        });
+    });
+
+    describe('Processing Record Corrections', () => {
+        const registerBatch = (ctx: MockContext) => ctx.stub.putJSON('batch_batch1', {
+            docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Mill A', currentState: 'Milld',
+            history: [
+                { timestamp: '2024-09-01T00:00:00.000Z', from: '', to: 'Farmer Zhang', step: 'Created', signerMspId: 'Org1MSP',
+                    report: { reportId: 'h1', reportType: 'HarvestLog', reportHash: '', summary: 'Harvest', isVerified: false } },
+                { timestamp: '2024-09-10T00:00:00.000Z', from: 'Farmer Zhang', to: 'Mill A', step: 'Milld', signerMspId: 'Org2MSP',
+                    report: { reportId: 'p1', reportType: 'ProcessingRecord', reportHash: '', summary: 'Milled 1000 kg', isVerified: false } }
+            ]
+        });
+
+        test('should supersede a mistyped record and keep the original', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            registerBatch(ctx);
+
+            const correction = await contract.CorrectProcessingRecord(ctx, 'batch1', '1', JSON.stringify({ step: 'Milled' }), 'Typo in step name');
+            expect(correction).toEqual(expect.objectContaining({ index: 1, step: 'Milled', reason: 'Typo in step name', correctedByMspId: 'Org2MSP' }));
+            expect(ctx.stub.events[0].name).toBe('ProcessingRecordCorrected');
+
+            const batch = ctx.stub.getJSON('batch_batch1');
+            expect(batch.history[1]).toEqual(expect.objectContaining({ step: 'Milld', supersededBy: correction.correctionId }));
+            expect(batch.corrections).toHaveLength(1);
+            expect(batch.currentState).toBe('Milled');
+
+            // A second correction supersedes the first and keeps its step
+            ctx.stub.nextTransaction();
+            const report = { reportId: 'p1', reportType: 'ProcessingRecord', reportHash: '', summary: 'Milled 980 kg', isVerified: false };
+            const second = await contract.CorrectProcessingRecord(ctx, 'batch1', '1', JSON.stringify({ report }), 'Wrong weight');
+            expect(second).toEqual(expect.objectContaining({ step: 'Milled', report }));
+            const corrected = ctx.stub.getJSON('batch_batch1');
+            expect(corrected.corrections[0].supersededBy).toBe(second.correctionId);
+            expect(corrected.history[1].supersededBy).toBe(second.correctionId);
+        });
+
+        test('should reject corrections by other organizations and of other fields', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            registerBatch(ctx);
+
+            await expect(contract.CorrectProcessingRecord(ctx, 'batch1', '0', JSON.stringify({ step: 'Harvested' }), 'Typo'))
+                .rejects.toThrow('Only the organization that signed record 0');
+            await expect(contract.CorrectProcessingRecord(ctx, 'batch1', '1', JSON.stringify({ to: 'Mill B' }), 'Wrong mill'))
+                .rejects.toThrow('not: to');
+            await expect(contract.CorrectProcessingRecord(ctx, 'batch1', '2', JSON.stringify({ step: 'Milled' }), 'Typo'))
+                .rejects.toThrow('has 2 history records');
+            await expect(contract.CorrectProcessingRecord(ctx, 'batch1', '1', JSON.stringify({ step: 'Milled' }), ' '))
+                .rejects.toThrow('A reason is required');
+            await expect(contract.CorrectProcessingRecord(ctx, 'batch1', '1', JSON.stringify({ step: 'Milld' }), 'Typo'))
+                .rejects.toThrow('does not change record 1');
+        });
+    });
+
+    describe('Delegation', () => {
+        const BROKER_CERT = '-----BEGIN CERTIFICATE-----\nAAED\n-----END CERTIFICATE-----\n';
+        const BROKER_IDENTITY = `Org3MSP:${certificateFingerprint(BROKER_CERT).toUpperCase().match(/../g)!.join(':')}`;
+        const report = JSON.stringify({ reportId: 'r1', reportType: 'ProcessingRecord', reportHash: '', summary: 'Sold at auction', isVerified: false });
+
+        const registerBatch = (ctx: MockContext) => ctx.stub.putJSON('batch_batch1', {
+            docType: 'riceBatch', batchId: 'batch1', currentOwner: 'Farmer Zhang', currentState: 'Drying',
+            history: [{ timestamp: '2024-09-01T00:00:00.000Z', from: '', to: 'Farmer Zhang', step: 'Created', signerMspId: 'Org1MSP' }]
+        });
+
+        test('should let a delegate transfer a batch on the farmer\'s behalf and attribute the step to both', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            registerBatch(ctx);
+
+            const delegation = await contract.GrantDelegate(ctx, 'batch1', BROKER_IDENTITY, 'transfer', '2024-12-31');
+            expect(delegation).toEqual(expect.objectContaining({
+                delegateMspId: 'Org3MSP',
+                delegateFingerprint: certificateFingerprint(BROKER_CERT),
+                permissions: ['transfer'],
+                grantedByMspId: 'Org1MSP',
+                expiresAt: '2024-12-31T23:59:59.999Z'
+            }));
+            expect(ctx.stub.events[0].name).toBe('DelegateGranted');
+
+            ctx.stub.nextTransaction();
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP', certPEM: BROKER_CERT });
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', report, ''))
+                .rejects.toThrow('Permission denied');
+            await contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Buyer Wang', 'Sold', report, '');
+
+            const event = ctx.stub.getJSON('batch_batch1').history[1];
+            expect(event).toEqual(expect.objectContaining({
+                signerMspId: 'Org3MSP',
+                signerFingerprint: certificateFingerprint(BROKER_CERT),
+                delegationId: delegation.delegationId,
+                onBehalfOfMspId: 'Org1MSP',
+                onBehalfOfFingerprint: certificateFingerprint(TEST_CERT_PEM)
+            }));
+        });
+
+        test('should stop honouring revoked and expired delegations', async () => {
+            const ctx = createMockContext({ mspId: 'Org1MSP' });
+            registerBatch(ctx);
+            const delegation = await contract.GrantDelegate(ctx, 'batch1', BROKER_IDENTITY, 'transfer,process', '2024-09-30');
+
+            ctx.stub.nextTransaction();
+            await contract.RevokeDelegate(ctx, 'batch1', delegation.delegationId);
+            expect(ctx.stub.events[0].name).toBe('DelegateRevoked');
+            await expect(contract.RevokeDelegate(ctx, 'batch1', delegation.delegationId)).rejects.toThrow('already revoked');
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP', certPEM: BROKER_CERT });
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', report, ''))
+                .rejects.toThrow('Permission denied');
+
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+            ctx.stub.nextTransaction();
+            await contract.GrantDelegate(ctx, 'batch1', BROKER_IDENTITY, 'process', '2024-09-30');
+            ctx.stub.setTxTimestamp(TEST_TIMESTAMP_SECONDS + 10 * 24 * 60 * 60);
+            ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP', certPEM: BROKER_CERT });
+            await expect(contract.CompleteStepAndTransfer(ctx, 'batch1', 'Farmer Zhang', 'Farmer Zhang', 'Drying', report, ''))
+                .rejects.toThrow('Permission denied');
+        });
+
+        test('should only let the registering organization grant valid delegations', async () => {
+            const ctx = createMockContext({ mspId: 'Org2MSP' });
+            registerBatch(ctx);
+
+            await expect(contract.GrantDelegate(ctx, 'batch1', BROKER_IDENTITY, 'transfer', '2024-12-31')).rejects.toThrow('registered batch batch1 (Org1MSP)');
+            ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+            await expect(contract.GrantDelegate(ctx, 'batch1', 'Org3MSP', 'transfer', '2024-12-31')).rejects.toThrow('Delegate identity');
+            await expect(contract.GrantDelegate(ctx, 'batch1', BROKER_IDENTITY, 'transfer,sell', '2024-12-31')).rejects.toThrow('Invalid delegation permissions');
+            await expect(contract.GrantDelegate(ctx, 'batch1', BROKER_IDENTITY, 'transfer', '2024-09-01')).rejects.toThrow('must be in the future');
+            await expect(contract.RevokeDelegate(ctx, 'batch1', 'tx9')).rejects.toThrow('does not exist');
+        });
+    });
+});
@@ -0,0 +1,88 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { ScheduledTransferContract } from '../src/scheduledTransferContract';
+import { createMockContext, MockContext } from '../testing';
+
+describe('ScheduledTransferContract', () => {
+    let contract: ScheduledTransferContract;
+
+    beforeEach(() => {
+        contract = new ScheduledTransferContract();
+    });
+
+    const registerBatch = (ctx: MockContext, batchId = 'batch1') =>
+        ctx.stub.putJSON(`batch_${batchId}`, {
+            docType: 'riceBatch', batchId, currentOwner: 'Farmer Zhang', currentState: 'Stored',
+            history: [{ timestamp: '2024-09-01T00:00:00.000Z', from: '', to: 'Farmer Zhang', step: 'Stored', signerMspId: 'Org1MSP' }]
+        });
+
+    test('should hand a batch over once the effective time is reached', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        registerBatch(ctx);
+
+        const schedule = await contract.ScheduleTransfer(ctx, 'batch1', ' Mill A ', '2024-10-01T00:00:00+08:00');
+        expect(schedule).toEqual(expect.objectContaining({
+            batchId: 'batch1', fromOwner: 'Farmer Zhang', newOwner: 'Mill A', effectiveTime: '2024-09-30T16:00:00.000Z',
+            scheduledByMspId: 'Org1MSP', status: 'Pending'
+        }));
+        expect(ctx.stub.events[0].name).toBe('TransferScheduled');
+
+        // Any party can activate, but not before the effective time
+        ctx.stub.nextTransaction();
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await expect(contract.ActivateScheduledTransfer(ctx, 'batch1')).rejects.toThrow('cannot be activated before 2024-09-30T16:00:00.000Z');
+        await expect(contract.GetDueScheduledTransfers(ctx)).resolves.toEqual([]);
+
+        ctx.stub.setTxTimestamp(Date.parse('2024-10-01T00:00:00Z') / 1000);
+        await expect(contract.GetDueScheduledTransfers(ctx)).resolves.toEqual([expect.objectContaining({ scheduleId: schedule.scheduleId })]);
+        const activated = await contract.ActivateScheduledTransfer(ctx, 'batch1');
+        expect(activated).toEqual(expect.objectContaining({ status: 'Activated', closedAt: '2024-10-01T00:00:00.000Z', activatedByMspId: 'Org2MSP' }));
+        expect(ctx.stub.events[0].name).toBe('ScheduledTransferActivated');
+
+        const batch = ctx.stub.getJSON('batch_batch1');
+        expect(batch.currentOwner).toBe('Mill A');
+        expect(batch.currentState).toBe('Stored');
+        expect(batch.history[1]).toEqual(expect.objectContaining({
+            from: 'Farmer Zhang', to: 'Mill A', step: 'Stored', signerMspId: 'Org2MSP',
+            report: expect.objectContaining({ reportId: schedule.scheduleId, reportType: 'ScheduledTransfer' })
+        }));
+        await expect(contract.GetDueScheduledTransfers(ctx)).resolves.toEqual([]);
+        await expect(contract.ActivateScheduledTransfer(ctx, 'batch1')).rejects.toThrow('has no pending scheduled transfer');
+    });
+
+    test('should allow one pending transfer, cancelled by its scheduler', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        registerBatch(ctx);
+
+        await expect(contract.ScheduleTransfer(ctx, 'batch1', 'Mill A', '2024-09-01T00:00:00Z')).rejects.toThrow('must be in the future');
+        await expect(contract.ScheduleTransfer(ctx, 'batch1', 'Farmer Zhang', '2024-10-01T00:00:00Z')).rejects.toThrow('already held by Farmer Zhang');
+        await contract.ScheduleTransfer(ctx, 'batch1', 'Mill A', '2024-10-01T00:00:00Z');
+        ctx.stub.nextTransaction();
+        await expect(contract.ScheduleTransfer(ctx, 'batch1', 'Mill B', '2024-10-02T00:00:00Z')).rejects.toThrow('already has a transfer to Mill A');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await expect(contract.ScheduleTransfer(ctx, 'batch1', 'Mill B', '2024-10-02T00:00:00Z')).rejects.toThrow('Permission denied');
+        await expect(contract.CancelScheduledTransfer(ctx, 'batch1')).rejects.toThrow('was scheduled by Org1MSP');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await contract.CancelScheduledTransfer(ctx, 'batch1');
+        expect(ctx.stub.events[0].name).toBe('ScheduledTransferCancelled');
+        const [cancelled] = await contract.GetScheduledTransfers(ctx, 'batch1');
+        expect(cancelled).toEqual(expect.objectContaining({ status: 'Cancelled', closedAt: '2024-09-22T10:13:20.000Z' }));
+        await expect(contract.ScheduleTransfer(ctx, 'batch1', 'Mill B', '2024-10-02T00:00:00Z')).resolves.toEqual(expect.objectContaining({ status: 'Pending' }));
+    });
+
+    test('should not activate a transfer after the batch changed hands', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        registerBatch(ctx);
+        await contract.ScheduleTransfer(ctx, 'batch1', 'Mill A', '2024-10-01T00:00:00Z');
+
+        const batch = ctx.stub.getJSON('batch_batch1');
+        ctx.stub.putJSON('batch_batch1', { ...batch, currentOwner: 'Trader Wang' });
+        ctx.stub.nextTransaction();
+        ctx.stub.setTxTimestamp(Date.parse('2024-10-02T00:00:00Z') / 1000);
+        await expect(contract.ActivateScheduledTransfer(ctx, 'batch1')).rejects.toThrow('was handed over to Trader Wang');
+    });
+});
@@ -0,0 +1,93 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { SettlementContract } from '../src/settlementContract';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { createMockContext, MockContext } from '../testing';
+
+describe('SettlementContract', () => {
+    let contract: SettlementContract;
+
+    beforeEach(() => {
+        contract = new SettlementContract();
+    });
+
+    const event = (timestamp: string, step: string, from: string, to: string, signerMspId: string) => ({
+        timestamp, from, to, step, signerMspId,
+        report: { reportId: '', reportType: 'ProcessingRecord', reportHash: '', summary: step, isVerified: false }
+    });
+
+    const putBatches = (ctx: MockContext) => {
+        ctx.stub.putJSON('participant_F1', { docType: 'participant', participantId: 'F1', name: 'Farmer Zhang', role: 'Farmer', mspId: 'Org1MSP', location: 'Wuchang' });
+        ctx.stub.putJSON('batch_late', {
+            docType: 'riceBatch', batchId: 'late', harvestDate: '2024-10-12T00:00:00.000Z', cropYear: 2024, season: 'Late', quantityKg: 1200,
+            currentOwner: 'Processor A', history: [
+                event('2024-10-12T08:00:00.000Z', 'Harvested', '', 'Farmer Zhang', 'Org1MSP'),
+                event('2024-10-20T08:00:00.000Z', 'Drying', 'Farmer Zhang', 'Farmer Zhang', 'Org1MSP'),
+                event('2024-11-02T08:00:00.000Z', 'Transporting', 'Farmer Zhang', 'Processor A', 'Org1MSP'),
+                event('2024-11-05T08:00:00.000Z', 'Milling', 'Processor A', 'Distributor B', 'Org2MSP')
+            ]
+        });
+        ctx.stub.putJSON('batch_early', {
+            docType: 'riceBatch', batchId: 'early', harvestDate: '2024-06-30T00:00:00.000Z', quantityKg: 800,
+            currentOwner: 'Processor A', history: [
+                event('2024-06-30T08:00:00.000Z', 'Harvested', '', 'F1', 'Org1MSP'),
+                event('2024-07-03T08:00:00.000Z', 'Transporting', 'F1', 'Processor A', 'Org1MSP')
+            ]
+        });
+    };
+
+    test('should report the transfers out of a farmer\'s batches by season with settlement statuses and own terms', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        putBatches(ctx);
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org2MSP' });
+        await contract.RecordSettlement(ctx, 'late', '2', 'invoiced', 'INV-1021');
+        ctx.stub.nextTransaction();
+        const settlement = await contract.RecordSettlement(ctx, 'late', '2', 'paid', 'PAY-88');
+        expect(settlement.changes.map(change => change.status)).toEqual(['invoiced', 'paid']);
+        expect(ctx.stub.events[0].name).toBe('SettlementStatusChanged');
+
+        const all = await contract.GetFarmerSettlementReport(ctx, 'F1', '2024');
+        expect(all.farmerNames).toEqual(['F1', 'Farmer Zhang']);
+        expect(all.lines.map(line => [line.batchId, line.eventIndex, line.status])).toEqual([['early', 1, 'unsettled'], ['late', 2, 'paid']]);
+        expect(all.statusCounts).toEqual({ unsettled: 1, paid: 1 });
+        expect(all.lines[1]).toEqual(expect.objectContaining({ to: 'Processor A', reference: 'PAY-88', settledAt: '2024-09-22T10:13:20.000Z', quantityKg: 1200 }));
+
+        const late = await contract.GetFarmerSettlementReport(ctx, 'Farmer Zhang', '2024-late');
+        expect(late.lines.map(line => line.batchId)).toEqual(['late']);
+        const november = await contract.GetFarmerSettlementReport(ctx, 'F1', '2024-11-01/2024-11-30');
+        expect(november.lines.map(line => line.batchId)).toEqual(['late']);
+        await expect(contract.GetFarmerSettlementReport(ctx, 'F1', '2024-Monsoon')).rejects.toThrow('Invalid period');
+    });
+
+    test('should include the caller\'s private terms only on its own peers', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        putBatches(ctx);
+        ctx.stub.setTransient({ terms: 'CNY 4.80/kg' });
+        await new RiceTracerContract().SetCommercialTerms(ctx, 'early');
+        ctx.stub.nextTransaction();
+
+        const report = await contract.GetFarmerSettlementReport(ctx, 'F1', '2024-Early');
+        expect(report.pricesIncluded).toBe(true);
+        expect(report.lines[0]).toEqual(expect.objectContaining({ batchId: 'early', commercialTerms: 'CNY 4.80/kg', termsHash: expect.any(String) }));
+
+        ctx.stub.setPeerMspId('Org2MSP');
+        const remote = await contract.GetFarmerSettlementReport(ctx, 'F1', '2024-Early');
+        expect(remote.pricesIncluded).toBe(false);
+        expect(remote.lines[0].commercialTerms).toBeUndefined();
+    });
+
+    test('should only let parties record settlements of handovers', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP' });
+        putBatches(ctx);
+        await expect(contract.RecordSettlement(ctx, 'late', '1', 'paid', '')).rejects.toThrow('not a handover');
+        await expect(contract.RecordSettlement(ctx, 'late', '9', 'paid', '')).rejects.toThrow('no history event 9');
+        await expect(contract.RecordSettlement(ctx, 'late', '2', 'refunded', '')).rejects.toThrow('Invalid settlement status');
+        await expect(contract.RecordSettlement(ctx, 'early', '1', 'paid', '')).rejects.toThrow('not a party');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org3MSP' });
+        await expect(contract.GetFarmerSettlementReport(ctx, 'F1', '')).rejects.toThrow('Permission denied');
+    });
+});
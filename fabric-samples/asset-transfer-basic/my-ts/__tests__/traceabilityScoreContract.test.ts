@@ -0,0 +1,131 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { TraceabilityScoreContract } from '../src/traceabilityScoreContract';
+import { createMockContext, MockContext } from '../testing';
+
+const ADMIN_ID = 'x509::/C=US/ST=North Carolina/O=Hyperledger/OU=admin/CN=Admin@org2.example.com::/C=US/ST=North Carolina/O=org2.example.com/CN=ca.org2.example.com';
+
+describe('TraceabilityScoreContract', () => {
+    let contract: TraceabilityScoreContract;
+
+    beforeEach(() => {
+        contract = new TraceabilityScoreContract();
+    });
+
+    const event = (step: string, from: string, to: string, report: object = {}) => ({
+        timestamp: '2024-09-01T00:00:00.000Z', from, to, step, signerMspId: 'Org1MSP',
+        report: { reportId: '', reportType: 'ProcessingRecord', reportHash: '', summary: step, isVerified: false, ...report }
+    });
+
+    const putBatches = (ctx: MockContext) => {
+        // Fully documented: geolocated harvest, moisture test before packaging, continuous custody, logged shipment
+        ctx.stub.putJSON('batch_good', { docType: 'riceBatch', batchId: 'good', history: [
+            event('Harvested', '', 'Farmer Zhang', { geolocation: { latitude: 44.92, longitude: 127.16 } }),
+            event('Packaged', 'Farmer Zhang', 'Processor A'),
+            event('Shipped', 'Processor A', 'Distributor B', { temperatureLogHash: 'ab'.repeat(32) })
+        ] });
+        ctx.stub.putJSON('test_T1', { docType: 'testResult', testId: 'T1', batchId: 'good', testType: 'Moisture', testResult: 'Passed' });
+
+        // No geolocation, no moisture test, a custody gap and an unlogged shipment
+        ctx.stub.putJSON('batch_poor', { docType: 'riceBatch', batchId: 'poor', history: [
+            event('Harvested', '', 'Farmer Li'),
+            event('Packaged', 'Someone Else', 'Processor A'),
+            event('Shipped', 'Processor A', 'Distributor B')
+        ] });
+    };
+
+    test('should score each criterion with the default weights', async () => {
+        const ctx = createMockContext({ mspId: 'Org3MSP' });
+        putBatches(ctx);
+
+        await expect(contract.GetTraceabilityScore(ctx, 'good')).resolves.toEqual(expect.objectContaining({ score: 100, criteriaVersion: 0 }));
+
+        const poor = await contract.GetTraceabilityScore(ctx, 'poor');
+        expect(poor.criteria.map(criterion => [criterion.criterion, criterion.fulfilment])).toEqual([
+            ['plotGeolocation', 0], ['requiredTests', 0], ['custodyChain', 2 / 3], ['coldChain', 0]
+        ]);
+        expect(poor.score).toBe(17);
+        expect(poor.criteria[1].detail).toBe('Missing passed test(s): Moisture');
+
+        const wellDocumented = await contract.GetWellDocumentedBatches(ctx, '50');
+        expect(wellDocumented.map(score => score.batchId)).toEqual(['good']);
+        await expect(contract.GetWellDocumentedBatches(ctx, '')).resolves.toHaveLength(2);
+        await expect(contract.GetWellDocumentedBatches(ctx, '120')).rejects.toThrow('between 0 and 100');
+    });
+
+    test('should apply configured criteria', async () => {
+        const ctx = createMockContext({ mspId: 'Org2MSP', id: ADMIN_ID });
+        putBatches(ctx);
+
+        await contract.DefineScoringCriteria(ctx, JSON.stringify({
+            plotGeolocationWeight: 0, requiredTestsWeight: 1, custodyChainWeight: 3, coldChainWeight: 0, requiredTests: {}
+        }));
+        const poor = await contract.GetTraceabilityScore(ctx, 'poor');
+        expect(poor.criteria.map(criterion => criterion.criterion)).toEqual(['requiredTests', 'custodyChain']);
+        expect(poor).toEqual(expect.objectContaining({ score: 75, criteriaVersion: 1 }));
+
+        await expect(contract.DefineScoringCriteria(ctx, JSON.stringify({
+            plotGeolocationWeight: 0, requiredTestsWeight: 0, custodyChainWeight: 0, coldChainWeight: 0
+        }))).rejects.toThrow('At least one scoring weight must be positive');
+        await expect(contract.DefineScoringCriteria(ctx, JSON.stringify({
+            plotGeolocationWeight: -1, requiredTestsWeight: 0, custodyChainWeight: 1, coldChainWeight: 0
+        }))).rejects.toThrow('plotGeolocationWeight must be a non-negative number');
+
+        ctx.clientIdentity.setIdentity({ mspId: 'Org1MSP' });
+        await expect(contract.DefineScoringCriteria(ctx, '{}')).rejects.toThrow();
+    });
+
+    describe('Trace Chain Validation', () => {
+        const putParticipants = (ctx: MockContext, names: string[]) => {
+            for (const name of names) {
+                const participantId = name.toLowerCase().replace(/ /g, '-');
+                ctx.stub.putJSON(`participant_${participantId}`, { docType: 'participant', participantId, name, role: 'processor', mspId: 'Org2MSP' });
+            }
+        };
+
+        test('should accept a complete chain', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            putBatches(ctx);
+            ctx.stub.putJSON('test_T1', { docType: 'testResult', testId: 'T1', batchId: 'good', testType: 'Moisture', testResult: 'Passed', testDate: '2024-08-30' });
+            ctx.stub.putJSON('product_P1', {
+                docType: 'product', productId: 'P1', batchId: 'good', packageDate: '2024-09-02', owner: 'Shop C', status: 'Sold',
+                transfers: [{ timestamp: '2024-09-03T00:00:00.000Z', from: 'Distributor B', to: 'Shop C', type: 'Sale' }]
+            });
+            putParticipants(ctx, ['Farmer Zhang', 'Processor A', 'Distributor B', 'Shop C']);
+
+            await expect(contract.ValidateTraceChain(ctx, 'P1')).resolves.toEqual({
+                productId: 'P1', batchId: 'good', valid: true, deficiencies: [], criteriaVersion: 0
+            });
+        });
+
+        test('should list every gap in the chain', async () => {
+            const ctx = createMockContext({ mspId: 'Org3MSP' });
+            putBatches(ctx);
+            ctx.stub.putJSON('product_P2', {
+                docType: 'product', productId: 'P2', batchId: 'poor', packageDate: '2024-08-01', owner: 'Shop D', status: 'Sold',
+                transfers: [
+                    { timestamp: '2024-09-03T00:00:00.000Z', from: 'Outsider', to: 'Shop C', type: 'Sale' },
+                    { timestamp: '2024-09-04T00:00:00.000Z', from: 'Shop X', to: 'Shop D', type: 'Sale' }
+                ]
+            });
+            putParticipants(ctx, ['Farmer Li', 'Processor A', 'Distributor B', 'Shop C', 'Shop D']);
+
+            const validation = await contract.ValidateTraceChain(ctx, 'P2');
+
+            expect(validation.valid).toBe(false);
+            expect(validation.deficiencies).toEqual([
+                expect.objectContaining({ rule: 'custodyLink', entityId: 'poor', index: 1, step: 'Packaged' }),
+                expect.objectContaining({ rule: 'missingTest', entityId: 'poor', index: 1, message: expect.stringContaining('No passed Moisture test') }),
+                expect.objectContaining({ rule: 'custodyLink', entityId: 'P2', message: expect.stringContaining('packaged by Outsider, who never held batch poor') }),
+                expect.objectContaining({ rule: 'stepOrder', entityId: 'P2', message: expect.stringContaining('before batch poor was registered') }),
+                expect.objectContaining({ rule: 'custodyLink', entityId: 'P2', index: 1 }),
+                { rule: 'unregisteredOperator', entityId: 'poor', index: 1, step: 'Packaged', message: 'Someone Else is not a registered participant' },
+                expect.objectContaining({ rule: 'unregisteredOperator', entityId: 'P2', index: 0, message: 'Outsider is not a registered participant' }),
+                expect.objectContaining({ rule: 'unregisteredOperator', entityId: 'P2', index: 1, message: 'Shop X is not a registered participant' })
+            ]);
+            await expect(contract.ValidateTraceChain(ctx, 'missing')).rejects.toThrow('does not exist');
+        });
+    });
+});
@@ -0,0 +1,32 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+const { contracts } = require('../src/index');
+
+/**
+ * Read functions must be tagged evaluate-only in the contract metadata: the gateway reads the tags to route them to
+ * Evaluate, so they never go through ordering and commit
+ */
+describe('Transaction Metadata', () => {
+    const READ_FUNCTION = /^(Read|Get|Query|Trace)|History$/;
+
+    test('should mark every read function as an evaluate transaction', () => {
+        const submitted: string[] = [];
+        let reads = 0;
+        for (const contract of contracts) {
+            const transactions = (Reflect as any).getMetadata('fabric:transactions', contract.prototype) || [];
+            for (const { name, tag } of transactions) {
+                const isSubmit = tag.includes('submitTx') || tag.includes('SUBMIT');
+                if (READ_FUNCTION.test(name)) {
+                    reads++;
+                    if (isSubmit) {
+                        submitted.push(`${contract.name}:${name}`);
+                    }
+                }
+            }
+        }
+        expect(reads).toBeGreaterThan(0);
+        expect(submitted).toEqual([]);
+    });
+});
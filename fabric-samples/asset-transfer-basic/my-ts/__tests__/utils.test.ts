@@ -0,0 +1,166 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import {
+    applyPatch, normalizeTimestamp, assertNotBefore, getCertificateExpiry, certificateFingerprint, diffDocuments, parseLabels, StoredDocument,
+    checkOrgType, getOrganizationType
+} from '../src/utils';
+import { OrganizationType, RiceBatch } from '../src/types';
+import { createMockContext } from '../testing';
+
+describe('Contract Utilities', () => {
+    describe('applyPatch', () => {
+        test('should keep fields written by newer chaincode versions', () => {
+            const stored = {
+                docType: 'riceBatch',
+                batchId: 'batch123',
+                origin: 'Heilongjiang',
+                variety: 'Japonica',
+                harvestDate: '2024-09-15',
+                currentOwner: 'Farmer Zhang',
+                currentState: 'Harvested',
+                history: [],
+                futureField: { certifiedBy: 'Org9MSP' }
+            } as StoredDocument<RiceBatch>;
+
+            const updated = applyPatch<RiceBatch>(stored, { currentOwner: 'Processor A', currentState: 'Milling' });
+
+            expect(updated.currentOwner).toBe('Processor A');
+            expect(updated.currentState).toBe('Milling');
+            expect(updated.futureField).toEqual({ certifiedBy: 'Org9MSP' });
+            expect(updated.origin).toBe('Heilongjiang');
+        });
+
+        test('should not mutate the stored document', () => {
+            const stored = { batchId: 'batch123', currentOwner: 'Farmer Zhang' } as StoredDocument<RiceBatch>;
+            applyPatch<RiceBatch>(stored, { currentOwner: 'Processor A' });
+            expect(stored.currentOwner).toBe('Farmer Zhang');
+        });
+    });
+
+    describe('normalizeTimestamp', () => {
+        test('should normalize RFC3339 timestamps to UTC', () => {
+            expect(normalizeTimestamp('2024-09-15T08:00:00+08:00', 'harvestDate')).toBe('2024-09-15T00:00:00.000Z');
+            expect(normalizeTimestamp('2024-09-15T08:00:00.5Z', 'harvestDate')).toBe('2024-09-15T08:00:00.500Z');
+        });
+
+        test('should treat plain dates as midnight UTC', () => {
+            expect(normalizeTimestamp('2024-09-15', 'harvestDate')).toBe('2024-09-15T00:00:00.000Z');
+        });
+
+        test('should reject invalid formats and out-of-range values', () => {
+            expect(() => normalizeTimestamp('2024/09/15', 'harvestDate')).toThrow('Invalid harvestDate');
+            expect(() => normalizeTimestamp('Sept 15 2024', 'packageDate')).toThrow('Invalid packageDate');
+            expect(() => normalizeTimestamp('2023-02-29', 'testDate')).toThrow('out of range');
+            expect(() => normalizeTimestamp('2024-09-15T24:00:00Z', 'testDate')).toThrow('out of range');
+            expect(() => normalizeTimestamp('', 'testDate')).toThrow('testDate is required');
+        });
+
+        test('should produce values that sort chronologically', () => {
+            const values = ['2024-09-15T08:00:00+08:00', '2024-09-14T23:00:00Z', '2024-09-15'];
+            const normalized = values.map(value => normalizeTimestamp(value, 'timestamp')).sort();
+            expect(normalized).toEqual([
+                '2024-09-14T23:00:00.000Z',
+                '2024-09-15T00:00:00.000Z',
+                '2024-09-15T00:00:00.000Z'
+            ]);
+        });
+    });
+
+    describe('assertNotBefore', () => {
+        test('should accept ordered and equal timestamps', () => {
+            expect(() => assertNotBefore('2024-10-01T00:00:00.000Z', 'packageDate', '2024-09-15T00:00:00.000Z', 'harvestDate')).not.toThrow();
+            expect(() => assertNotBefore('2024-09-15T00:00:00.000Z', 'packageDate', '2024-09-15T00:00:00.000Z', 'harvestDate')).not.toThrow();
+        });
+
+        test('should reject a timestamp that precedes its reference', () => {
+            expect(() => assertNotBefore('2024-09-01T00:00:00.000Z', 'packageDate', '2024-09-15T00:00:00.000Z', 'harvestDate'))
+                .toThrow('packageDate (2024-09-01T00:00:00.000Z) cannot be earlier than harvestDate (2024-09-15T00:00:00.000Z)');
+        });
+
+        test('should skip legacy values that cannot be parsed', () => {
+            expect(() => assertNotBefore('2024-09-01', 'packageDate', 'last autumn', 'harvestDate')).not.toThrow();
+        });
+    });
+
+    describe('getCertificateExpiry', () => {
+        test('should add duration periods to the issue date', () => {
+            expect(getCertificateExpiry('2024-01-31T00:00:00.000Z', '30 days')).toBe('2024-03-01T00:00:00.000Z');
+            expect(getCertificateExpiry('2024-01-15T00:00:00.000Z', '6 months')).toBe('2024-07-15T00:00:00.000Z');
+            expect(getCertificateExpiry('2024-01-15T00:00:00.000Z', '1 year')).toBe('2025-01-15T00:00:00.000Z');
+        });
+
+        test('should accept an explicit expiry date', () => {
+            expect(getCertificateExpiry('2024-01-15T00:00:00.000Z', '2024-12-31')).toBe('2024-12-31T00:00:00.000Z');
+        });
+
+        test('should return null when no expiry can be determined', () => {
+            expect(getCertificateExpiry('2024-01-15T00:00:00.000Z', '')).toBeNull();
+            expect(getCertificateExpiry('2024-01-15T00:00:00.000Z', 'until revoked')).toBeNull();
+        });
+    });
+
+    describe('certificateFingerprint', () => {
+        const pem = '-----BEGIN CERTIFICATE-----\nAAEC\n-----END CERTIFICATE-----\n';
+
+        test('should hash the DER bytes of the certificate', () => {
+            expect(certificateFingerprint(pem)).toBe('ae4b3280e56e2faf83f414a6e3dabe9d5fbe18976544c05fed121accb85b53fc');
+        });
+
+        test('should ignore PEM line wrapping', () => {
+            expect(certificateFingerprint(pem.replace('AAEC', 'AA\r\nEC'))).toBe(certificateFingerprint(pem));
+        });
+
+        test('should reject empty certificates', () => {
+            expect(() => certificateFingerprint('')).toThrow('Invalid certificate');
+        });
+    });
+
+    describe('diffDocuments', () => {
+        test('should list changed, added and removed fields with nested paths', () => {
+            const before = { currentOwner: 'Farm', history: [{ step: 'Harvested' }], report: { summary: 'ok', hash: 'a' } };
+            const after = { currentOwner: 'Mill', history: [{ step: 'Harvested' }, { step: 'Milling' }], report: { summary: 'ok' }, quarantined: false };
+
+            expect(diffDocuments(before, after)).toEqual([
+                { path: 'currentOwner', change: 'modified', before: '"Farm"', after: '"Mill"' },
+                { path: 'history[1]', change: 'added', after: '{"step":"Milling"}' },
+                { path: 'quarantined', change: 'added', after: 'false' },
+                { path: 'report.hash', change: 'removed', before: '"a"' }
+            ]);
+            expect(diffDocuments(after, after)).toEqual([]);
+        });
+    });
+
+    describe('parseLabels', () => {
+        test('should accept lowercase keys with string values', () => {
+            expect(parseLabels('{"export-market":"JP","coop.id/region":"HLJ-017"}')).toEqual({ 'export-market': 'JP', 'coop.id/region': 'HLJ-017' });
+            expect(parseLabels('{}')).toEqual({});
+        });
+
+        test('should enforce key format, reserved prefixes, value size and label count', () => {
+            expect(() => parseLabels('{"Export Market":"JP"}')).toThrow('Invalid label key');
+            expect(() => parseLabels('{"ricetrace.owner":"x"}')).toThrow('reserved prefix ricetrace.');
+            expect(() => parseLabels('{"grade":1}')).toThrow('non-empty string');
+            expect(() => parseLabels(JSON.stringify({ note: 'x'.repeat(257) }))).toThrow('at most 256 characters');
+            expect(() => parseLabels(JSON.stringify({ note: 'a\u0000b' }))).toThrow('control characters');
+            const tooMany = Object.fromEntries(Array.from({ length: 21 }, (_, index) => [`label${index}`, 'x']));
+            expect(() => parseLabels(JSON.stringify(tooMany))).toThrow('At most 20 labels');
+            expect(() => parseLabels('["JP"]')).toThrow('must be an object');
+        });
+    });
+
+    describe('checkOrgType', () => {
+        test('should map MSPs to organization types, treating unknown MSPs as consumers', () => {
+            expect(getOrganizationType('Org1MSP')).toBe(OrganizationType.FARM);
+            expect(getOrganizationType('Org2MSP')).toBe(OrganizationType.MIDDLEMAN_TESTER);
+            expect(getOrganizationType('Org9MSP')).toBe(OrganizationType.CONSUMER);
+        });
+
+        test('should only let the allowed organization types call', () => {
+            expect(() => checkOrgType(createMockContext({ mspId: 'Org1MSP' }), [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER])).not.toThrow();
+            expect(() => checkOrgType(createMockContext({ mspId: 'Org3MSP' }), [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]))
+                .toThrow('Permission denied: Only the following organization types can call: Farm, Middleman/tester');
+        });
+    });
+});
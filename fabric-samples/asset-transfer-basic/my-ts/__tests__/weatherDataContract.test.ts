@@ -0,0 +1,61 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { createHash } from 'crypto';
+import { WeatherDataContract } from '../src/weatherDataContract';
+import { createMockContext } from '../testing';
+
+describe('WeatherDataContract', () => {
+    let contract: WeatherDataContract;
+
+    beforeEach(() => {
+        contract = new WeatherDataContract();
+    });
+
+    const FEED = (day: string) => JSON.stringify({ station: '50953', day, rainfallMm: 0, maxHumidity: 58 });
+    const hashOf = (data: string) => createHash('sha256').update(data).digest('hex');
+
+    test('should anchor an observation by its data hash and verify the raw feed against it', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        const hash = hashOf(FEED('2024-09-10'));
+
+        await contract.AnchorWeatherData(ctx, 'plot-7', '2024-09-10/2024-09-12', 'CMA station 50953', hash.toUpperCase(), 'Dry window: 0 mm rain');
+
+        const observation = await contract.ReadWeatherObservation(ctx, hash);
+        expect(observation).toEqual(expect.objectContaining({
+            plotId: 'plot-7', periodStart: '2024-09-10T00:00:00.000Z', periodEnd: '2024-09-12T23:59:59.999Z', anchoredBy: 'Org1MSP', dataHash: hash
+        }));
+        expect(ctx.stub.events[0].name).toBe('WeatherDataAnchored');
+        await expect(contract.VerifyWeatherData(ctx, hash, FEED('2024-09-10'))).resolves.toBe(true);
+        await expect(contract.VerifyWeatherData(ctx, hash, FEED('2024-09-11'))).resolves.toBe(false);
+
+        await expect(contract.AnchorWeatherData(ctx, 'plot-8', '2024-09-10/2024-09-12', 'CMA station 50953', hash, 'Copy'))
+            .rejects.toThrow('already been anchored for plot plot-7');
+    });
+
+    test('should list a plot\'s observations overlapping a time range', async () => {
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        for (const [period, day] of [['2024-09-01/2024-09-05', '1'], ['2024-09-06/2024-09-10', '2'], ['2024-09-11/2024-09-15', '3']]) {
+            await contract.AnchorWeatherData(ctx, 'plot-7', period, 'CMA station 50953', hashOf(FEED(day)), `Period ${day}`);
+        }
+        await contract.AnchorWeatherData(ctx, 'plot-9', '2024-09-06/2024-09-10', 'CMA station 50953', hashOf(FEED('other')), 'Other plot');
+
+        const all = await contract.GetWeatherObservations(ctx, 'plot-7', '', '');
+        expect(all.map(observation => observation.summary)).toEqual(['Period 1', 'Period 2', 'Period 3']);
+
+        const harvestWindow = await contract.GetWeatherObservations(ctx, 'plot-7', '2024-09-05', '2024-09-06');
+        expect(harvestWindow.map(observation => observation.summary)).toEqual(['Period 1', 'Period 2']);
+    });
+
+    test('should reject consumers, future periods and malformed hashes', async () => {
+        const hash = hashOf(FEED('2024-09-10'));
+        await expect(contract.AnchorWeatherData(createMockContext({ mspId: 'Org3MSP' }), 'plot-7', '2024-09-10/2024-09-12', 'CMA', hash, 'Dry'))
+            .rejects.toThrow('Permission denied');
+
+        const ctx = createMockContext({ mspId: 'Org1MSP' });
+        await expect(contract.AnchorWeatherData(ctx, 'plot-7', '2024-10-01/2024-10-05', 'CMA', hash, 'Dry')).rejects.toThrow('cannot start in the future');
+        await expect(contract.AnchorWeatherData(ctx, 'plot-7', '2024-09-12/2024-09-10', 'CMA', hash, 'Dry')).rejects.toThrow('cannot be earlier than');
+        await expect(contract.AnchorWeatherData(ctx, 'plot-7', '2024-09-10/2024-09-12', 'CMA', 'abc', 'Dry')).rejects.toThrow('SHA-256');
+    });
+});
@@ -0,0 +1,130 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import 'reflect-metadata';
+import { RiceTracerContract } from '../src/riceTracerContract';
+import { QualityCertificationContract } from '../src/qualityCertificationContract';
+import { ProductManagementContract } from '../src/productManagementContract';
+import { SimulatedLedger, SimulatedIdentity, SimulatedTransaction } from './simulatedLedger';
+
+/**
+ * Chaincode benchmarks against the simulated ledger
+ * Measures simulation throughput and MVCC conflict rates when many transactions touching the same
+ * keys land in one block. Run with: npm run bench (BENCH_TX_COUNT sets transactions per scenario)
+ */
+
+const TX_COUNT = parseInt(process.env.BENCH_TX_COUNT || '', 10) || 200;
+const REPORT_HASH = 'a3f5c1d2e4b6a8c0e2f4a6b8c0d2e4f6a8b0c2d4e6f8a0b2c4d6e8f0a2b4c6d8';
+
+const FARM: SimulatedIdentity = { mspId: 'Org1MSP' };
+const TESTER: SimulatedIdentity = { mspId: 'Org2MSP' };
+
+const riceTracer = new RiceTracerContract();
+const quality = new QualityCertificationContract();
+const products = new ProductManagementContract();
+
+interface ScenarioResult {
+    scenario: string;
+    transactions: number;
+    simulationTxPerSecond: number;
+    valid: number;
+    conflicts: number;
+    conflictRate: string;
+}
+
+const results: ScenarioResult[] = [];
+
+async function createBatch(ledger: SimulatedLedger, batchId: string): Promise<void> {
+    await ledger.execute(FARM, ctx => riceTracer.CreateRiceBatch(
+        ctx, batchId, 'Heilongjiang', 'Japonica', '2024-09-15', '{}', 'Farmer Zhang', 'Harvested', 'Farmer Zhang', '', '', '', ''
+    ));
+}
+
+/**
+ * Simulate all transactions against the same committed state, then commit them in one block
+ */
+async function runScenario(
+    scenario: string,
+    ledger: SimulatedLedger,
+    identity: SimulatedIdentity,
+    txFn: (index: number) => Parameters<SimulatedLedger['simulate']>[1]
+): Promise<ScenarioResult> {
+    const transactions: SimulatedTransaction[] = [];
+
+    const start = process.hrtime.bigint();
+    for (let index = 0; index < TX_COUNT; index++) {
+        transactions.push(await ledger.simulate(identity, txFn(index)));
+    }
+    const seconds = Number(process.hrtime.bigint() - start) / 1e9;
+
+    const block = ledger.commitBlock(transactions);
+    const result: ScenarioResult = {
+        scenario,
+        transactions: TX_COUNT,
+        simulationTxPerSecond: Math.round(TX_COUNT / seconds),
+        valid: block.valid,
+        conflicts: block.conflicts,
+        conflictRate: `${((block.conflicts / TX_COUNT) * 100).toFixed(1)}%`
+    };
+    results.push(result);
+    return result;
+}
+
+describe('Chaincode benchmarks', () => {
+    afterAll(() => {
+        process.stdout.write(`\nChaincode benchmark (${TX_COUNT} transactions per scenario, one block)\n`);
+        console.table(results);
+    });
+
+    test('CreateTestResult on the same batch does not conflict', async () => {
+        const ledger = new SimulatedLedger();
+        await createBatch(ledger, 'hot');
+        await ledger.execute(TESTER, ctx => quality.RecordSample(ctx, 'hot', 'sample1', '500g', 'Inspector Li', 'Silo 3'));
+
+        const result = await runScenario('CreateTestResult x N, same batch', ledger, TESTER, index => ctx =>
+            quality.CreateTestResult(ctx, `test${index}`, 'hot', 'sample1', 'Moisture', '2024-09-20', 'Passed', 'Lab A', '', REPORT_HASH, '')
+        );
+
+        // Test results are separate keys that only read the batch, so concurrent tests never collide
+        expect(result.conflicts).toBe(0);
+    });
+
+    test('CompleteStepAndTransfer on the same batch serializes', async () => {
+        const ledger = new SimulatedLedger();
+        await createBatch(ledger, 'hot');
+
+        const result = await runScenario('CompleteStepAndTransfer x N, same batch', ledger, TESTER, index => ctx =>
+            riceTracer.CompleteStepAndTransfer(ctx, 'hot', 'Farmer Zhang', `Processor ${index}`, 'Milling', '{}', '')
+        );
+
+        // Every transfer rewrites the batch document: only the first in a block can commit
+        expect(result.valid).toBe(1);
+        expect(result.conflicts).toBe(TX_COUNT - 1);
+    });
+
+    test('CompleteStepAndTransfer on distinct batches does not conflict', async () => {
+        const ledger = new SimulatedLedger();
+        for (let index = 0; index < TX_COUNT; index++) {
+            await createBatch(ledger, `batch${index}`);
+        }
+
+        const result = await runScenario('CompleteStepAndTransfer x N, distinct batches', ledger, TESTER, index => ctx =>
+            riceTracer.CompleteStepAndTransfer(ctx, `batch${index}`, 'Farmer Zhang', 'Processor A', 'Milling', '{}', '')
+        );
+
+        // Step index entries are per batch, so moving batches between steps does not collide
+        expect(result.conflicts).toBe(0);
+    });
+
+    test('CreateProduct from the same batch does not conflict', async () => {
+        const ledger = new SimulatedLedger();
+        await createBatch(ledger, 'hot');
+
+        const result = await runScenario('CreateProduct x N, same batch', ledger, TESTER, index => ctx =>
+            products.CreateProduct(ctx, `product${index}`, 'hot', '2024-10-01', 'Distributor A', '')
+        );
+
+        expect(result.conflicts).toBe(0);
+    });
+});
@@ -0,0 +1,176 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context } from 'fabric-contract-api';
+
+/**
+ * In-memory ledger with Fabric's execute-order-validate semantics.
+ * Transactions are simulated against the committed state, recording a read set (key -> version)
+ * and a write set; at commit time a transaction is invalidated with MVCC_READ_CONFLICT when a key it
+ * read was changed by an earlier transaction in the same block, or with PHANTOM_READ_CONFLICT when
+ * a range it scanned gained or lost keys.
+ */
+
+interface VersionedValue {
+    value: Buffer;
+    version: number;
+}
+
+export interface SimulatedTransaction {
+    txId: string;
+    readSet: Map<string, number>; // key -> version read (0 = key did not exist)
+    rangeReads: { startKey: string; endKey: string; keys: string[] }[];
+    writeSet: Map<string, Buffer | null>; // null = delete
+    events: { name: string; payload: Buffer }[];
+}
+
+export interface BlockResult {
+    valid: number;
+    conflicts: number;
+}
+
+export interface SimulatedIdentity {
+    mspId: string;
+    id?: string;
+    certPEM?: string;
+    attributes?: Record<string, string>;
+}
+
+const DEFAULT_CERT_PEM = '-----BEGIN CERTIFICATE-----\nAAEC\n-----END CERTIFICATE-----\n';
+const COMPOSITE_KEY_NAMESPACE = '\x00';
+const MAX_UNICODE_RUNE = '\u{10FFFF}';
+
+export class SimulatedLedger {
+    private state = new Map<string, VersionedValue>();
+    private txCounter = 0;
+    private clockSeconds = 1727000000;
+
+    /**
+     * Simulate a transaction function against the committed state
+     * The returned transaction is not applied until it is committed in a block
+     */
+    public async simulate(identity: SimulatedIdentity, fn: (ctx: Context) => Promise<unknown>): Promise<SimulatedTransaction> {
+        const tx: SimulatedTransaction = {
+            txId: `tx${++this.txCounter}`,
+            readSet: new Map(),
+            rangeReads: [],
+            writeSet: new Map(),
+            events: []
+        };
+        // Every transaction gets a later timestamp, as proposals arriving over time would
+        const seconds = ++this.clockSeconds;
+
+        await fn(this.createContext(tx, identity, seconds));
+        return tx;
+    }
+
+    /**
+     * Validate and commit transactions in order as one block
+     */
+    public commitBlock(transactions: SimulatedTransaction[]): BlockResult {
+        const result: BlockResult = { valid: 0, conflicts: 0 };
+
+        for (const tx of transactions) {
+            const stale = [...tx.readSet].some(([key, version]) => this.getVersion(key) !== version);
+            const phantom = tx.rangeReads.some(range =>
+                this.keysInRange(range.startKey, range.endKey).join('\n') !== range.keys.join('\n')
+            );
+            if (stale || phantom) {
+                result.conflicts++;
+                continue;
+            }
+
+            for (const [key, value] of tx.writeSet) {
+                if (value === null) {
+                    this.state.delete(key);
+                } else {
+                    this.state.set(key, { value, version: this.getVersion(key) + 1 });
+                }
+            }
+            result.valid++;
+        }
+
+        return result;
+    }
+
+    /**
+     * Simulate and immediately commit a single transaction (setup helper)
+     */
+    public async execute(identity: SimulatedIdentity, fn: (ctx: Context) => Promise<unknown>): Promise<void> {
+        const tx = await this.simulate(identity, fn);
+        const result = this.commitBlock([tx]);
+        if (result.valid !== 1) {
+            throw new Error(`Transaction ${tx.txId} was invalidated`);
+        }
+    }
+
+    /**
+     * Read committed state directly (assertions)
+     */
+    public get(key: string): unknown {
+        const entry = this.state.get(key);
+        return entry ? JSON.parse(entry.value.toString()) : undefined;
+    }
+
+    private getVersion(key: string): number {
+        const entry = this.state.get(key);
+        return entry ? entry.version : 0;
+    }
+
+    private read(tx: SimulatedTransaction, key: string): Buffer {
+        tx.readSet.set(key, this.getVersion(key));
+        const entry = this.state.get(key);
+        return entry ? entry.value : Buffer.from('');
+    }
+
+    private keysInRange(startKey: string, endKey: string): string[] {
+        return [...this.state.keys()].filter(key => key >= startKey && key < endKey).sort();
+    }
+
+    private rangeIterator(tx: SimulatedTransaction, startKey: string, endKey: string) {
+        const keys = this.keysInRange(startKey, endKey);
+        tx.rangeReads.push({ startKey, endKey, keys });
+        const results = keys.map(key => ({ key, value: this.read(tx, key) }));
+        let index = 0;
+        return {
+            next: async () => index < results.length
+                ? { value: results[index++], done: false }
+                : { value: undefined, done: true },
+            close: async () => undefined
+        };
+    }
+
+    private createContext(tx: SimulatedTransaction, identity: SimulatedIdentity, seconds: number): Context {
+        const stub = {
+            getTxID: () => tx.txId,
+            getTxTimestamp: () => ({ seconds: { toNumber: () => seconds }, nanos: 0 }),
+            getState: async (key: string) => this.read(tx, key),
+            putState: async (key: string, value: Buffer) => { tx.writeSet.set(key, Buffer.from(value)); },
+            deleteState: async (key: string) => { tx.writeSet.set(key, null); },
+            getStateByRange: async (startKey: string, endKey: string) => this.rangeIterator(tx, startKey, endKey),
+            createCompositeKey: (objectType: string, attributes: string[]) =>
+                `${COMPOSITE_KEY_NAMESPACE}${objectType}${COMPOSITE_KEY_NAMESPACE}${attributes.map(attribute => `${attribute}${COMPOSITE_KEY_NAMESPACE}`).join('')}`,
+            splitCompositeKey: (compositeKey: string) => {
+                const parts = compositeKey.split(COMPOSITE_KEY_NAMESPACE).slice(1, -1);
+                return { objectType: parts[0], attributes: parts.slice(1) };
+            },
+            getStateByPartialCompositeKey: async (objectType: string, attributes: string[]) => {
+                const prefix = stub.createCompositeKey(objectType, attributes);
+                return this.rangeIterator(tx, prefix, `${prefix}${MAX_UNICODE_RUNE}`);
+            },
+            // Key-level endorsement policies are not evaluated by the simulation
+            setStateValidationParameter: async () => undefined,
+            setEvent: (name: string, payload: Buffer) => { tx.events = [{ name, payload }]; }
+        };
+
+        const clientIdentity = {
+            getMSPID: () => identity.mspId,
+            getID: () => identity.id || `x509::/OU=client/CN=User1@${identity.mspId}::/CN=ca.${identity.mspId}`,
+            getIDBytes: () => Buffer.from(identity.certPEM || DEFAULT_CERT_PEM),
+            getAttributeValue: (name: string) => (identity.attributes || {})[name] || null
+        };
+
+        return { stub, clientIdentity } as unknown as Context;
+    }
+}
@@ -0,0 +1,39 @@
+#!/usr/bin/env bash
+#
+# SPDX-License-Identifier: Apache-2.0
+#
+# Start the RiceTrace chaincode in one of two modes:
+#   - Chaincode-as-a-Service, when CHAINCODE_SERVER_ADDRESS and CHAINCODE_ID are set: the chaincode listens on
+#     CHAINCODE_SERVER_ADDRESS and the peer connects to it (container deployments, e.g. Kubernetes)
+#   - Legacy, otherwise: the peer launches the chaincode and passes --peer.address; the chaincode connects to the peer
+#
+# CCaaS TLS is enabled when CHAINCODE_TLS_KEY_FILE and CHAINCODE_TLS_CERT_FILE are set; add
+# CHAINCODE_TLS_CLIENT_CACERT_FILE to require peers to present a client certificate issued by that CA.
+
+set -euo pipefail
+
+if [[ -z "${CHAINCODE_SERVER_ADDRESS:-}" && -z "${CHAINCODE_ID:-}" ]]; then
+  exec fabric-chaincode-node start "$@"
+fi
+
+if [[ -z "${CHAINCODE_SERVER_ADDRESS:-}" || -z "${CHAINCODE_ID:-}" ]]; then
+  echo "Chaincode-as-a-Service needs both CHAINCODE_SERVER_ADDRESS and CHAINCODE_ID" >&2
+  exit 1
+fi
+
+args=(--chaincode-address="${CHAINCODE_SERVER_ADDRESS}" --chaincode-id="${CHAINCODE_ID}")
+
+if [[ -n "${CHAINCODE_TLS_KEY_FILE:-}" || -n "${CHAINCODE_TLS_CERT_FILE:-}" ]]; then
+  if [[ -z "${CHAINCODE_TLS_KEY_FILE:-}" || -z "${CHAINCODE_TLS_CERT_FILE:-}" ]]; then
+    echo "Chaincode TLS needs both CHAINCODE_TLS_KEY_FILE and CHAINCODE_TLS_CERT_FILE" >&2
+    exit 1
+  fi
+  args+=(--chaincode-tls-key-file="${CHAINCODE_TLS_KEY_FILE}" --chaincode-tls-cert-file="${CHAINCODE_TLS_CERT_FILE}")
+  if [[ -n "${CHAINCODE_TLS_CLIENT_CACERT_FILE:-}" ]]; then
+    args+=(--chaincode-tls-client-cacert-file="${CHAINCODE_TLS_CLIENT_CACERT_FILE}")
+  fi
+else
+  echo "Warning: starting Chaincode-as-a-Service without TLS" >&2
+fi
+
+exec fabric-chaincode-node server "${args[@]}" "$@"
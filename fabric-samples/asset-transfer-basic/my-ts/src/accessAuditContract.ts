@@ -0,0 +1,152 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import stringify from 'json-stringify-deterministic';
+import sortKeysRecursive from 'sort-keys-recursive';
+import { AccessLogEntry, OrganizationType } from './types';
+import {
+    ACCESS_AUDIT_COLLECTION, pairCollectionName, implicitCollectionName, assertPeerOrgMatchesClient, getCallerFingerprint,
+    getTxTimestamp, normalizeTimestamp, checkOrgType
+} from './utils';
+
+/**
+ * Environment variable naming the regulator organization that can read the access logs and draw spot checks
+ * Must be set identically on all peers; the test network's Org3 (consumer/regulatory organization) is the default
+ */
+const REGULATOR_MSP_ENV = 'RICETRACE_REGULATOR_MSP';
+const DEFAULT_REGULATOR_MSP = 'Org3MSP';
+
+/**
+ * MSP ID of the regulator organization
+ */
+export function getRegulatorMspId(): string {
+    return process.env[REGULATOR_MSP_ENV] || DEFAULT_REGULATOR_MSP;
+}
+
+/**
+ * Transient data key carrying the access record passed to RecordAccess
+ * Transaction arguments are stored in the block, so what was read is only passed as transient data
+ */
+const ACCESS_TRANSIENT_KEY = 'access';
+
+/**
+ * Sensitive views whose reads are logged
+ */
+const AUDITED_RESOURCE_TYPES = ['testReport', 'commercialTerms'];
+
+@Info({ title: 'AccessAuditContract', description: 'Smart contract keeping an access audit trail of sensitive views for the regulator' })
+export class AccessAuditContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "AccessAuditContract Method Permission Configuration": {
+                "RecordAccess": ["All Organizations"],
+                "GetAccessLog": ["Regulator"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Record a read of a sensitive view
+     * The record ({ resourceType, resourceId, purpose, accessedBy }) is passed in the "access" transient field and
+     * stored in the accessAudit collection the caller's organization shares with the regulator, keyed by time, so
+     * neither the other organizations nor the public ledger learn what was read. Reads by the regulator itself go
+     * to its implicit collection. Must be endorsed by a peer of the caller's organization.
+     * Permission: No restriction
+     */
+    @Transaction()
+    @Returns('AccessLogEntry')
+    public async RecordAccess(ctx: Context): Promise<AccessLogEntry> {
+        assertPeerOrgMatchesClient(ctx);
+
+        const accessBytes = ctx.stub.getTransient().get(ACCESS_TRANSIENT_KEY);
+        if (!accessBytes || accessBytes.length === 0) {
+            throw new Error(`The access record must be passed in the "${ACCESS_TRANSIENT_KEY}" transient field`);
+        }
+        let access: { resourceType?: string; resourceId?: string; purpose?: string; accessedBy?: string };
+        try {
+            access = JSON.parse(Buffer.from(accessBytes).toString('utf8'));
+        } catch (error) {
+            throw new Error(`Access record format error: ${error}`);
+        }
+        if (!AUDITED_RESOURCE_TYPES.includes(access.resourceType || '')) {
+            throw new Error(`Unknown resource type ${access.resourceType}, expected one of ${AUDITED_RESOURCE_TYPES.join(', ')}`);
+        }
+        if (!access.resourceId) {
+            throw new Error('The ID of the accessed resource is required');
+        }
+
+        const mspId = ctx.clientIdentity.getMSPID();
+        const entry: AccessLogEntry = {
+            docType: 'accessLogEntry',
+            resourceType: access.resourceType as string,
+            resourceId: access.resourceId,
+            purpose: access.purpose || '',
+            accessedBy: access.accessedBy || '',
+            accessorMspId: mspId,
+            accessorFingerprint: getCallerFingerprint(ctx),
+            accessedAt: getTxTimestamp(ctx),
+            txId: ctx.stub.getTxID()
+        };
+
+        await ctx.stub.putPrivateData(
+            this.auditCollection(mspId),
+            `access_${entry.accessedAt}_${entry.txId}`,
+            Buffer.from(stringify(sortKeysRecursive(entry)))
+        );
+        return entry;
+    }
+
+    /**
+     * Get the recorded reads of an organization, oldest first, optionally limited to a time range
+     * Must be evaluated on a peer of the regulator, the only other member of the organization's audit collection
+     * Permission: Regulator only
+     */
+    @Transaction(false)
+    @Returns('AccessLogEntry[]')
+    public async GetAccessLog(ctx: Context, mspId: string, from: string, to: string): Promise<AccessLogEntry[]> {
+        // Check permission: Only consumer/regulatory organizations read audit trails; of those, only the regulator
+        checkOrgType(ctx, [OrganizationType.CONSUMER]);
+        const regulatorMspId = getRegulatorMspId();
+        if (ctx.clientIdentity.getMSPID() !== regulatorMspId) {
+            throw new Error(`Permission denied: Only the regulator ${regulatorMspId} can read access logs`);
+        }
+        assertPeerOrgMatchesClient(ctx);
+
+        const startKey = from ? `access_${normalizeTimestamp(from, 'from')}` : 'access_';
+        // A bare end date includes the whole day
+        const endKey = to
+            ? `access_${/^\d{4}-\d{2}-\d{2}$/.test(to.trim()) ? `${to.trim()}T23:59:59.999Z~` : `${normalizeTimestamp(to, 'to')}~`}`
+            : 'access_~';
+
+        const entries: AccessLogEntry[] = [];
+        const iterator = await ctx.stub.getPrivateDataByRange(this.auditCollection(mspId), startKey, endKey);
+        let result = await iterator.next();
+        while (!result.done) {
+            entries.push(JSON.parse(Buffer.from(result.value.value).toString('utf8')));
+            result = await iterator.next();
+        }
+        await iterator.close();
+        return entries;
+    }
+
+    /**
+     * Collection shared by an organization and the regulator (the regulator's own implicit collection for itself)
+     */
+    private auditCollection(mspId: string): string {
+        const regulatorMspId = getRegulatorMspId();
+        return mspId === regulatorMspId
+            ? implicitCollectionName(regulatorMspId)
+            : pairCollectionName(ACCESS_AUDIT_COLLECTION, mspId, regulatorMspId);
+    }
+}
@@ -0,0 +1,126 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { AgroInputApplication, AgroInputExposure, ExposedBatch, Product, RiceBatch } from './types';
+import { PRODUCT_BATCH_INDEX } from './productManagementContract';
+import { readDocument, normalizeTimestamp, putIndexEntry, getIndexEntries } from './utils';
+
+/**
+ * Composite key index of agro-chemical applications by chemical name and by input lot
+ * The first attribute is chemical:<lower-case name> or lot:<input lot ID>
+ */
+export const AGRO_INPUT_INDEX = 'agroInput~batchId~appliedAt~step~chemicalName~inputLotId';
+
+/**
+ * Check and normalize the agro-chemical applications listed in a report
+ */
+export function validateAgroInputs(inputs: unknown): AgroInputApplication[] {
+    if (!Array.isArray(inputs)) {
+        throw new Error('Report inputs must be a list of agro-chemical applications');
+    }
+    return inputs.map((input, index) => {
+        if (!input || typeof input !== 'object' || typeof input.chemicalName !== 'string' || !input.chemicalName.trim()) {
+            throw new Error(`Report input ${index} must name the applied chemical (chemicalName)`);
+        }
+        if (input.inputLotId !== undefined && (typeof input.inputLotId !== 'string' || !input.inputLotId.trim())) {
+            throw new Error(`Report input ${index} has an invalid inputLotId`);
+        }
+        const application: AgroInputApplication = { chemicalName: input.chemicalName.trim() };
+        if (input.inputLotId !== undefined) {
+            application.inputLotId = input.inputLotId.trim();
+        }
+        if (input.appliedAt !== undefined) {
+            application.appliedAt = normalizeTimestamp(input.appliedAt, `Report input ${index} appliedAt`);
+        }
+        return application;
+    });
+}
+
+/**
+ * Record that a step of a batch applied agro-chemicals, so the batch is found when one of them is recalled
+ */
+export async function recordAgroInputs(ctx: Context, inputs: AgroInputApplication[], batchId: string, step: string, recordedAt: string): Promise<void> {
+    for (const input of inputs) {
+        const attributes = [batchId, input.appliedAt || recordedAt, step, input.chemicalName, input.inputLotId || ''];
+        await putIndexEntry(ctx, AGRO_INPUT_INDEX, [`chemical:${input.chemicalName.toLowerCase()}`, ...attributes]);
+        if (input.inputLotId) {
+            await putIndexEntry(ctx, AGRO_INPUT_INDEX, [`lot:${input.inputLotId}`, ...attributes]);
+        }
+    }
+}
+
+@Info({ title: 'AgroInputContract', description: 'Smart contract tracing agro-chemical applications to the batches and products they reached' })
+export class AgroInputContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "AgroInputContract Method Permission Configuration": {
+                "FindBatchesByInputProduct": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Find every batch that received an agro-chemical, and the products packaged from those batches, e.g. when a
+     * pesticide lot is found contaminated
+     * input is a chemical name (case-insensitive) or an input lot ID. Applications are those listed in the inputs
+     * of step reports, corrections included. Products are found through the packaging of the batches; batches
+     * continued on other channels are not reached from this channel
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('AgroInputExposure')
+    public async FindBatchesByInputProduct(ctx: Context, input: string): Promise<AgroInputExposure> {
+        const query = (input || '').trim();
+        if (!query) {
+            throw new Error('A chemical name or input lot ID is required');
+        }
+
+        const entries = [
+            ...await getIndexEntries(ctx, AGRO_INPUT_INDEX, [`chemical:${query.toLowerCase()}`]),
+            ...await getIndexEntries(ctx, AGRO_INPUT_INDEX, [`lot:${query}`])
+        ];
+        const exposed = new Map<string, ExposedBatch>();
+        const seen = new Set<string>();
+        for (const [, batchId, appliedAt, step, chemicalName, inputLotId] of entries) {
+            // An application with a lot is indexed under both keys and can match both
+            const applicationKey = [batchId, appliedAt, step, chemicalName, inputLotId].join('\u0000');
+            if (seen.has(applicationKey)) {
+                continue;
+            }
+            seen.add(applicationKey);
+            if (!exposed.has(batchId)) {
+                const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+                if (!batch) {
+                    continue;
+                }
+                exposed.set(batchId, { batchId, owner: batch.currentOwner, state: batch.currentState, applications: [] });
+            }
+            (exposed.get(batchId) as ExposedBatch).applications.push({ step, chemicalName, inputLotId, appliedAt });
+        }
+
+        const exposure: AgroInputExposure = { query, batches: [], products: [] };
+        for (const batch of [...exposed.values()].sort((a, b) => a.batchId.localeCompare(b.batchId))) {
+            batch.applications.sort((a, b) => a.appliedAt.localeCompare(b.appliedAt));
+            exposure.batches.push(batch);
+            for (const [, productId] of await getIndexEntries(ctx, PRODUCT_BATCH_INDEX, [batch.batchId])) {
+                const product = await readDocument<Product>(ctx, `product_${productId}`);
+                // Guard against stale index entries
+                if (product && product.batchId === batch.batchId) {
+                    exposure.products.push({ productId, batchId: batch.batchId, owner: product.owner, status: product.status });
+                }
+            }
+        }
+        return exposure;
+    }
+}
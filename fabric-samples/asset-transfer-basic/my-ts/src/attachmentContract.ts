@@ -0,0 +1,324 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { Attachment, AttachmentMetadata, OrganizationType, Product, RiceBatch, TestResult } from './types';
+import { readDocument, writeDocument, normalizeTimestamp, normalizeEndTimestamp, getTxTimestamp, emitEvent, putIndexEntry, getIndexEntries, checkOrgType, inheritTenant } from './utils';
+
+/**
+ * Composite key index of attachments by the batch or product they belong to
+ */
+export const ENTITY_ATTACHMENT_INDEX = 'entity~attachmentId';
+
+type MetadataField = keyof AttachmentMetadata;
+
+interface AttachmentCategory {
+    mimeTypes: (mimeType: string) => boolean;
+    mimeDescription: string;
+    required: MetadataField[];
+    optional: MetadataField[];
+}
+
+const isPdf = (mimeType: string) => mimeType === 'application/pdf';
+const isImage = (mimeType: string) => mimeType.startsWith('image/');
+
+/**
+ * Attachment categories, the file types they accept and the metadata each requires
+ */
+export const ATTACHMENT_CATEGORIES: Record<string, AttachmentCategory> = {
+    photo: { mimeTypes: isImage, mimeDescription: 'an image', required: [], optional: ['takenAt', 'stage', 'caption'] },
+    labReport: { mimeTypes: isPdf, mimeDescription: 'a PDF', required: ['laboratory', 'reportDate'], optional: ['testId'] },
+    certificate: {
+        mimeTypes: mimeType => isPdf(mimeType) || isImage(mimeType),
+        mimeDescription: 'a PDF or an image',
+        required: ['certificateNumber', 'issuer'],
+        optional: ['validUntil']
+    },
+    contract: { mimeTypes: isPdf, mimeDescription: 'a PDF', required: ['counterparty', 'signedDate'], optional: [] },
+    customsDoc: { mimeTypes: isPdf, mimeDescription: 'a PDF', required: ['declarationNumber', 'country'], optional: [] }
+};
+
+/**
+ * Stages of the photo journal of a batch, from the field to the package, in supply chain order
+ */
+export const PHOTO_STAGES = ['Field', 'Harvested', 'Drying', 'Stored', 'Transporting', 'QualityInspection', 'Processing', 'Milling', 'Packaged'];
+
+/**
+ * Image types of photo journal files, by file extension of their uri
+ */
+const PHOTO_MIME_TYPES: Record<string, string> = {
+    jpg: 'image/jpeg',
+    jpeg: 'image/jpeg',
+    png: 'image/png',
+    webp: 'image/webp',
+    heic: 'image/heic'
+};
+
+const MAX_CAPTION_LENGTH = 500;
+
+const DATE_FIELDS: MetadataField[] = ['takenAt', 'reportDate', 'validUntil', 'signedDate'];
+
+// IPFS content identifiers: CIDv0 (base58btc, "Qm...") or CIDv1 in the default base32 encoding ("b...")
+const CID_PATTERN = /^(Qm[1-9A-HJ-NP-Za-km-z]{44}|b[a-z2-7]{58,})$/;
+
+@Info({ title: 'AttachmentContract', description: 'Smart contract for typed document attachments of batches and products' })
+export class AttachmentContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "AttachmentContract Method Permission Configuration": {
+                "AddAttachment": ["Farm", "Middleman/Tester"],
+                "ReadAttachment": ["All Organizations"],
+                "ListAttachments": ["All Organizations"],
+                "AddBatchPhoto": ["Farm", "Middleman/Tester"],
+                "GetBatchPhotos": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Attach a document to a batch or product
+     * attachmentJSON is { category, title, fileHash, mimeType, uri?, cid?, metadata }, where fileHash is the SHA-256
+     * (hex) of the file and metadata carries the fields of the category (see ATTACHMENT_CATEGORIES). cid is the IPFS
+     * content identifier of a file stored on IPFS; its uri defaults to ipfs://<cid>. The attachment ID is the
+     * transaction ID
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    @Returns('Attachment')
+    public async AddAttachment(ctx: Context, entityId: string, attachmentJSON: string): Promise<Attachment> {
+        // Check permission: Only the organizations producing the documents can attach them
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        const { entityType, batchId, source } = await this.resolveEntity(ctx, entityId);
+
+        let input: any;
+        try {
+            input = JSON.parse(attachmentJSON);
+        } catch (error) {
+            throw new Error(`Attachment format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || !input.title) {
+            throw new Error('Attachment title is required');
+        }
+        const category = ATTACHMENT_CATEGORIES[input.category];
+        if (!category) {
+            throw new Error(`Invalid attachment category: ${input.category}. Allowed values: ${Object.keys(ATTACHMENT_CATEGORIES).join(', ')}`);
+        }
+        const fileHash = String(input.fileHash || '').toLowerCase();
+        if (!/^[0-9a-f]{64}$/.test(fileHash)) {
+            throw new Error('Attachment file hash must be a SHA-256 digest (64 hex characters)');
+        }
+        const mimeType = String(input.mimeType || '').toLowerCase();
+        if (!category.mimeTypes(mimeType)) {
+            throw new Error(`A ${input.category} attachment must be ${category.mimeDescription}, got '${input.mimeType || ''}'`);
+        }
+        if (input.cid && !CID_PATTERN.test(String(input.cid))) {
+            throw new Error(`Invalid IPFS CID: ${input.cid}`);
+        }
+        const metadata = await this.validateMetadata(ctx, input.category, input.metadata || {}, batchId);
+
+        const attachment: Attachment = {
+            docType: 'attachment',
+            attachmentId: ctx.stub.getTxID(),
+            entityId,
+            entityType,
+            category: input.category,
+            title: input.title,
+            fileHash,
+            mimeType,
+            metadata,
+            addedBy: ctx.clientIdentity.getMSPID(),
+            addedAt: getTxTimestamp(ctx)
+        };
+        if (input.cid) {
+            attachment.cid = String(input.cid);
+        }
+        if (input.uri || input.cid) {
+            attachment.uri = input.uri || `ipfs://${input.cid}`;
+        }
+        inheritTenant(attachment, source);
+        await writeDocument(ctx, `attachment_${attachment.attachmentId}`, attachment);
+        await putIndexEntry(ctx, ENTITY_ATTACHMENT_INDEX, [entityId, attachment.attachmentId]);
+        emitEvent(ctx, 'AttachmentAdded', attachment);
+        return attachment;
+    }
+
+    /**
+     * Read an attachment by ID
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Attachment')
+    public async ReadAttachment(ctx: Context, attachmentId: string): Promise<Attachment> {
+        const attachment = await readDocument<Attachment>(ctx, `attachment_${attachmentId}`);
+        if (!attachment) {
+            throw new Error(`Attachment ${attachmentId} does not exist`);
+        }
+        return attachment;
+    }
+
+    /**
+     * List the attachments of a batch or product in the order they were added, e.g. for a documents tab
+     * category is optional and limits the list to one attachment category
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Attachment[]')
+    public async ListAttachments(ctx: Context, entityId: string, category: string): Promise<Attachment[]> {
+        if (!entityId) {
+            throw new Error('Entity ID is required');
+        }
+        if (category && !ATTACHMENT_CATEGORIES[category]) {
+            throw new Error(`Invalid attachment category: ${category}. Allowed values: ${Object.keys(ATTACHMENT_CATEGORIES).join(', ')}`);
+        }
+
+        const attachments: Attachment[] = [];
+        for (const [, attachmentId] of await getIndexEntries(ctx, ENTITY_ATTACHMENT_INDEX, [entityId])) {
+            const attachment = await readDocument<Attachment>(ctx, `attachment_${attachmentId}`);
+            if (attachment && (!category || attachment.category === category)) {
+                attachments.push(attachment);
+            }
+        }
+        return attachments.sort((a, b) => a.addedAt.localeCompare(b.addedAt));
+    }
+
+    /**
+     * Add a photo to the photo journal of a batch: a photo attachment tagged with the stage it was taken at, e.g. the
+     * field before harvest or the mill, shown to consumers in the trace story and kept as inspection evidence
+     * hash is the SHA-256 (hex) of the image file and uri where it can be fetched; an ipfs://<cid> uri records the CID.
+     * The image type follows the file extension of the uri
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    @Returns('Attachment')
+    public async AddBatchPhoto(ctx: Context, batchId: string, stage: string, caption: string, hash: string, uri: string): Promise<Attachment> {
+        if (!(await readDocument(ctx, `batch_${batchId}`))) {
+            throw new Error(`Batch ${batchId} does not exist`);
+        }
+        if (!PHOTO_STAGES.includes(stage)) {
+            throw new Error(`Invalid photo stage: ${stage}. Allowed values: ${PHOTO_STAGES.join(', ')}`);
+        }
+        const text = (caption || '').trim();
+        if (text.length > MAX_CAPTION_LENGTH) {
+            throw new Error(`Caption can have at most ${MAX_CAPTION_LENGTH} characters`);
+        }
+        if (!uri) {
+            throw new Error('A journal photo needs the uri it can be fetched from');
+        }
+
+        const cid = uri.startsWith('ipfs://') ? uri.slice('ipfs://'.length) : undefined;
+        const extension = (uri.split(/[?#]/)[0].match(/\.([a-z0-9]+)$/i) || [])[1];
+        const metadata: AttachmentMetadata = { stage };
+        if (text) {
+            metadata.caption = text;
+        }
+        return this.AddAttachment(ctx, batchId, JSON.stringify({
+            category: 'photo',
+            title: text || stage,
+            fileHash: hash,
+            // Photos stored on IPFS are addressed by CID alone, without a file name to tell the image type
+            mimeType: (extension && PHOTO_MIME_TYPES[extension.toLowerCase()]) || (cid ? 'image/*' : ''),
+            uri,
+            cid,
+            metadata
+        }));
+    }
+
+    /**
+     * Get the photo journal of a batch: its photos in the order they were added, each tagged with its stage when
+     * added through AddBatchPhoto
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Attachment[]')
+    public async GetBatchPhotos(ctx: Context, batchId: string): Promise<Attachment[]> {
+        if (!(await readDocument(ctx, `batch_${batchId}`))) {
+            throw new Error(`Batch ${batchId} does not exist`);
+        }
+        return this.ListAttachments(ctx, batchId, 'photo');
+    }
+
+    /**
+     * Find whether an entity ID names a batch or a product, the batch it belongs to and its document
+     */
+    private async resolveEntity(ctx: Context, entityId: string): Promise<{ entityType: string; batchId: string; source: RiceBatch | Product }> {
+        if (!entityId) {
+            throw new Error('Entity ID is required');
+        }
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${entityId}`);
+        if (batch) {
+            return { entityType: 'batch', batchId: entityId, source: batch };
+        }
+        const product = await readDocument<Product>(ctx, `product_${entityId}`);
+        if (product) {
+            return { entityType: 'product', batchId: product.batchId, source: product };
+        }
+        throw new Error(`No batch or product with ID ${entityId} exists`);
+    }
+
+    /**
+     * Check the metadata of an attachment against its category: required fields present, no fields of
+     * other categories, dates well-formed, and a referenced test result concerning the same batch
+     */
+    private async validateMetadata(ctx: Context, categoryName: string, metadata: any, batchId: string): Promise<AttachmentMetadata> {
+        if (typeof metadata !== 'object' || Array.isArray(metadata)) {
+            throw new Error('Attachment metadata must be an object');
+        }
+        const category = ATTACHMENT_CATEGORIES[categoryName];
+        const allowed = [...category.required, ...category.optional];
+        const unexpected = Object.keys(metadata).filter(field => !allowed.includes(field as MetadataField));
+        if (unexpected.length > 0) {
+            throw new Error(`Metadata fields not used by ${categoryName} attachments: ${unexpected.join(', ')}`);
+        }
+        const missing = category.required.filter(field => !metadata[field]);
+        if (missing.length > 0) {
+            throw new Error(`A ${categoryName} attachment requires metadata: ${missing.join(', ')}`);
+        }
+
+        const validated: AttachmentMetadata = {};
+        for (const field of allowed) {
+            if (metadata[field] === undefined || metadata[field] === '') {
+                continue;
+            }
+            const value = String(metadata[field]);
+            if (field === 'validUntil') {
+                validated[field] = normalizeEndTimestamp(value, field); // a certificate is valid through its last day
+            } else {
+                validated[field] = DATE_FIELDS.includes(field) ? normalizeTimestamp(value, field) : value;
+            }
+        }
+
+        if (validated.country !== undefined) {
+            validated.country = validated.country.toUpperCase();
+            if (!/^[A-Z]{2}$/.test(validated.country)) {
+                throw new Error('Country must be an ISO 3166-1 alpha-2 code');
+            }
+        }
+        if (validated.stage !== undefined && !PHOTO_STAGES.includes(validated.stage)) {
+            throw new Error(`Invalid photo stage: ${validated.stage}. Allowed values: ${PHOTO_STAGES.join(', ')}`);
+        }
+        if (validated.caption !== undefined && validated.caption.length > MAX_CAPTION_LENGTH) {
+            throw new Error(`Caption can have at most ${MAX_CAPTION_LENGTH} characters`);
+        }
+        if (validated.testId !== undefined) {
+            const test = await readDocument<TestResult>(ctx, `test_${validated.testId}`);
+            if (!test) {
+                throw new Error(`Test result ${validated.testId} does not exist`);
+            }
+            if (test.batchId !== batchId) {
+                throw new Error(`Test result ${validated.testId} concerns batch ${test.batchId}, not ${batchId}`);
+            }
+        }
+        return validated;
+    }
+}
@@ -0,0 +1,317 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { BatchReservation, BatchReservationSummary, RiceBatch, WeightAdjustment } from './types';
+import { readDocument, patchDocument, normalizeEndTimestamp, getTxTimestamp, emitEvent, DISPOSED_STATE } from './utils';
+
+/**
+ * Largest plausible weight loss of a processing step, in percent of the weight before it. Drying removes moisture
+ * (harvested paddy at 25-30% down to 14%), hulling and milling remove husk and bran; other steps only lose handling
+ * spillage
+ */
+export const WEIGHT_LOSS_LIMITS: Record<string, number> = {
+    Drying: 30,
+    Cleaning: 5,
+    Hulling: 25,
+    Milling: 40,
+    Milled: 40,
+    Processing: 40,
+    Polishing: 10,
+    Stored: 3,
+    Storage: 3
+};
+
+/**
+ * Largest plausible weight loss of steps not listed in WEIGHT_LOSS_LIMITS, in percent
+ */
+export const DEFAULT_WEIGHT_LOSS_LIMIT = 1;
+
+/**
+ * Effective status of a reservation at a point in time: Active reservations past their expiry are Expired
+ */
+export function reservationStatus(reservation: BatchReservation, now: string): string {
+    return reservation.status === 'Active' && reservation.expiresAt <= now ? 'Expired' : reservation.status;
+}
+
+/**
+ * Reservations of a batch still holding quantity
+ */
+function activeReservations(batch: RiceBatch, now: string): BatchReservation[] {
+    return (batch.reservations || []).filter(reservation => reservationStatus(reservation, now) === 'Active');
+}
+
+/**
+ * Quantity of a batch held by its active reservations, in kg
+ */
+export function reservedQuantity(batch: RiceBatch, now: string): number {
+    return activeReservations(batch, now).reduce((total, reservation) => total + reservation.quantityKg, 0);
+}
+
+/**
+ * Apply a handover of a batch to its reservations: the handover to the buyer consumes the buyer's reservations,
+ * and a handover to anyone else is refused while other buyers hold reservations. Returns the updated reservations,
+ * or undefined when they do not change (processing steps without a handover, or nothing reserved)
+ */
+export function consumeReservations(batch: RiceBatch, toOperator: string, now: string): BatchReservation[] | undefined {
+    if (toOperator === batch.currentOwner) {
+        return undefined;
+    }
+    const active = activeReservations(batch, now);
+    if (active.length === 0) {
+        return undefined;
+    }
+
+    const others = active.filter(reservation => reservation.buyer !== toOperator);
+    if (others.length > 0) {
+        const reservedKg = others.reduce((total, reservation) => total + reservation.quantityKg, 0);
+        const buyers = [...new Set(others.map(reservation => reservation.buyer))].join(', ');
+        throw new Error(`Batch ${batch.batchId} has ${reservedKg} kg reserved for ${buyers}; release the reservations before transferring it to ${toOperator}`);
+    }
+    return (batch.reservations || []).map(reservation =>
+        active.includes(reservation) ? { ...reservation, status: 'Consumed', closedAt: now } : reservation
+    );
+}
+
+@Info({ title: 'BatchReservationContract', description: 'Smart contract reserving batch quantity for pending sales' })
+export class BatchReservationContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "BatchReservationContract Method Permission Configuration": {
+                "SetBatchQuantity": ["Organization owning the batch"],
+                "RecordWeightAdjustment": ["Organization owning the batch"],
+                "ReserveBatchQuantity": ["Organization owning the batch"],
+                "ReleaseReservation": ["Organization that made the reservation"],
+                "GetBatchReservations": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Declare the quantity of rice in a batch, in kg. It cannot drop below the quantity currently reserved.
+     * Once declared, the quantity is only lowered by RecordWeightAdjustment, so every loss is explained
+     * Permission: The organization owning the batch (signer of its latest history event)
+     */
+    @Transaction()
+    public async SetBatchQuantity(ctx: Context, batchId: string, quantityKg: string): Promise<void> {
+        const batch = await this.readOwnedBatch(ctx, batchId, 'set its quantity');
+        const quantity = this.parseQuantity(quantityKg);
+
+        const reservedKg = reservedQuantity(batch, getTxTimestamp(ctx));
+        if (quantity < reservedKg) {
+            throw new Error(`Batch ${batchId} has ${reservedKg} kg reserved; its quantity cannot be set to ${quantity} kg`);
+        }
+        if (batch.quantityKg && quantity < batch.quantityKg) {
+            throw new Error(`Batch ${batchId} weighs ${batch.quantityKg} kg; record the loss of a processing step with RecordWeightAdjustment instead of lowering its quantity to ${quantity} kg`);
+        }
+        await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, { quantityKg: quantity });
+    }
+
+    /**
+     * Record the weight a batch lost in a processing step, e.g. moisture removed by drying, and lower its quantity
+     * to the weight after the step. before must match the declared quantity, so losses chain without gaps, and the
+     * loss must stay within the plausible range of the step (WEIGHT_LOSS_LIMITS). step is a step recorded in the
+     * batch history. Returns the adjustment
+     * Permission: The organization owning the batch (signer of its latest history event)
+     */
+    @Transaction()
+    @Returns('WeightAdjustment')
+    public async RecordWeightAdjustment(
+        ctx: Context,
+        batchId: string,
+        step: string,
+        before: string,
+        after: string,
+        reason: string
+    ): Promise<WeightAdjustment> {
+        const batch = await this.readOwnedBatch(ctx, batchId, 'adjust its weight');
+        if (!batch.quantityKg) {
+            throw new Error(`Batch ${batchId} has no declared quantity; set it with SetBatchQuantity first`);
+        }
+        if (!step || (batch.currentState !== step && !batch.history.some(event => event.step === step))) {
+            throw new Error(`Step ${step} is not recorded in the history of batch ${batchId}`);
+        }
+        if (!reason || !reason.trim()) {
+            throw new Error('Weight adjustment reason is required');
+        }
+        const beforeKg = this.parseQuantity(before);
+        const afterKg = this.parseQuantity(after);
+        if (beforeKg !== batch.quantityKg) {
+            throw new Error(`Weight before ${step} (${beforeKg} kg) does not match the declared quantity of batch ${batchId} (${batch.quantityKg} kg)`);
+        }
+        if (afterKg > beforeKg) {
+            throw new Error(`Weight after ${step} (${afterKg} kg) exceeds the weight before it (${beforeKg} kg)`);
+        }
+
+        const lossPercent = Math.round((beforeKg - afterKg) / beforeKg * 10000) / 100;
+        const limit = step in WEIGHT_LOSS_LIMITS ? WEIGHT_LOSS_LIMITS[step] : DEFAULT_WEIGHT_LOSS_LIMIT;
+        if (lossPercent > limit) {
+            throw new Error(`A weight loss of ${lossPercent}% is implausible for ${step}: at most ${limit}% is expected`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const reservedKg = reservedQuantity(batch, now);
+        if (afterKg < reservedKg) {
+            throw new Error(`Batch ${batchId} has ${reservedKg} kg reserved; release reservations before recording a weight of ${afterKg} kg`);
+        }
+
+        const adjustment: WeightAdjustment = {
+            adjustmentId: ctx.stub.getTxID(),
+            step,
+            beforeKg,
+            afterKg,
+            lossPercent,
+            reason: reason.trim(),
+            recordedByMspId: ctx.clientIdentity.getMSPID(),
+            recordedAt: now
+        };
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            quantityKg: afterKg,
+            weightAdjustments: [...(batch.weightAdjustments || []), adjustment]
+        });
+        emitEvent(ctx, 'BatchWeightAdjusted', updated);
+        return adjustment;
+    }
+
+    /**
+     * Reserve quantity of a batch for a pending sale to buyer until expiry (a date or RFC3339 time in the future)
+     * Reserved quantity cannot be reserved again; the reservation lapses at its expiry, and the handover of the
+     * batch to the buyer consumes it. Returns the reservation
+     * Permission: The organization owning the batch (signer of its latest history event)
+     */
+    @Transaction()
+    @Returns('BatchReservation')
+    public async ReserveBatchQuantity(ctx: Context, batchId: string, buyer: string, quantity: string, expiry: string): Promise<BatchReservation> {
+        const batch = await this.readOwnedBatch(ctx, batchId, 'reserve it');
+        if (!batch.quantityKg) {
+            throw new Error(`Batch ${batchId} has no declared quantity; set it with SetBatchQuantity first`);
+        }
+        if (!buyer || !buyer.trim()) {
+            throw new Error('Buyer is required');
+        }
+        if (buyer.trim() === batch.currentOwner) {
+            throw new Error(`Batch ${batchId} is already held by ${batch.currentOwner}`);
+        }
+        const quantityKg = this.parseQuantity(quantity);
+
+        const now = getTxTimestamp(ctx);
+        const expiresAt = normalizeEndTimestamp(expiry, 'expiry');
+        if (expiresAt <= now) {
+            throw new Error(`Reservation expiry ${expiresAt} must be in the future`);
+        }
+
+        const reservedKg = reservedQuantity(batch, now);
+        const availableKg = batch.quantityKg - reservedKg;
+        if (quantityKg > availableKg) {
+            throw new Error(`Cannot reserve ${quantityKg} kg of batch ${batchId}: only ${availableKg} kg of ${batch.quantityKg} kg are available`);
+        }
+
+        const reservation: BatchReservation = {
+            reservationId: ctx.stub.getTxID(),
+            buyer: buyer.trim(),
+            quantityKg,
+            reservedByMspId: ctx.clientIdentity.getMSPID(),
+            reservedAt: now,
+            expiresAt,
+            status: 'Active'
+        };
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            reservations: [...(batch.reservations || []), reservation]
+        });
+        emitEvent(ctx, 'BatchQuantityReserved', updated);
+        return reservation;
+    }
+
+    /**
+     * Release a reservation before it is consumed, e.g. when the sale falls through
+     * Permission: The organization that made the reservation
+     */
+    @Transaction()
+    public async ReleaseReservation(ctx: Context, batchId: string, reservationId: string): Promise<void> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        const reservations = batch.reservations || [];
+        const reservation = reservations.find(candidate => candidate.reservationId === reservationId);
+        if (!reservation) {
+            throw new Error(`The reservation ${reservationId} does not exist on batch ${batchId}`);
+        }
+        if (reservation.reservedByMspId !== ctx.clientIdentity.getMSPID()) {
+            throw new Error(`Permission denied: The reservation ${reservationId} was made by ${reservation.reservedByMspId}`);
+        }
+        if (reservation.status !== 'Active') {
+            throw new Error(`The reservation ${reservationId} was already ${reservation.status.toLowerCase()} at ${reservation.closedAt}`);
+        }
+
+        const closedAt = getTxTimestamp(ctx);
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            reservations: reservations.map(candidate => candidate === reservation ? { ...candidate, status: 'Released', closedAt } : candidate)
+        });
+        emitEvent(ctx, 'BatchReservationReleased', updated);
+    }
+
+    /**
+     * Get the declared quantity of a batch, its reservations with their current status, and the quantity available
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('BatchReservationSummary')
+    public async GetBatchReservations(ctx: Context, batchId: string): Promise<BatchReservationSummary> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const reservedKg = reservedQuantity(batch, now);
+        return {
+            batchId,
+            quantityKg: batch.quantityKg || 0,
+            reservedKg,
+            availableKg: Math.max((batch.quantityKg || 0) - reservedKg, 0),
+            reservations: (batch.reservations || []).map(reservation => ({ ...reservation, status: reservationStatus(reservation, now) }))
+        };
+    }
+
+    /**
+     * Read a batch that is not disposed of and is owned by the caller's organization: the signer of its latest
+     * history event
+     */
+    private async readOwnedBatch(ctx: Context, batchId: string, action: string): Promise<RiceBatch> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        if (batch.disposal || batch.currentState === DISPOSED_STATE) {
+            throw new Error(`The rice batch ${batchId} has been disposed of and cannot be changed`);
+        }
+        const lastEvent = batch.history[batch.history.length - 1];
+        const ownerMspId = lastEvent && lastEvent.signerMspId ? lastEvent.signerMspId : '';
+        if (ownerMspId !== ctx.clientIdentity.getMSPID()) {
+            throw new Error(`Permission denied: Only the organization owning batch ${batchId} (${ownerMspId || 'unknown'}) can ${action}`);
+        }
+        return batch;
+    }
+
+    /**
+     * Parse a positive quantity in kg
+     */
+    private parseQuantity(value: string): number {
+        const quantity = Number(value);
+        if (!value || !Number.isFinite(quantity) || quantity <= 0) {
+            throw new Error(`Invalid quantity ${value}: expected a positive number of kg`);
+        }
+        return quantity;
+    }
+}
@@ -0,0 +1,187 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { Product, RiceBatch } from './types';
+import { PRODUCT_BATCH_INDEX } from './productManagementContract';
+import { RECALL_ITEM_INDEX } from './recallContract';
+import { readDocument, writeDocument, patchDocument, putIndexEntry, deleteIndexEntry, getIndexEntries, checkOrgAdmin, DISPOSED_STATE } from './utils';
+
+/**
+ * Composite key index of batches by derived status, used by GetBatchesByStatus
+ */
+export const BATCH_STATUS_INDEX = 'batchStatus~batchId';
+
+/**
+ * Derived batch statuses, from the most to the least significant; a batch has the first that applies:
+ * - Disposed: the batch was disposed of (terminal)
+ * - Recalled: a recall covers the batch
+ * - Quarantined: the batch is under quarantine
+ * - Archived: the batch was finalized and every product packaged from it was sold or disposed of
+ * - Finalized: the batch was Packaged or products were packaged from it
+ * - Active: none of the above
+ */
+export const BATCH_STATUSES = ['Disposed', 'Recalled', 'Quarantined', 'Archived', 'Finalized', 'Active'];
+
+/**
+ * Product statuses of rice that has left the supply chain
+ */
+const CLOSED_PRODUCT_STATUSES = ['Sold', DISPOSED_STATE];
+
+/**
+ * Facts about a batch written by the current transaction, which the ledger cannot return until it is committed
+ * recalled: a recall covering the batch is being issued; products: products of the batch being created or updated
+ */
+export interface BatchStatusFacts {
+    recalled?: boolean;
+    products?: Product[];
+}
+
+/**
+ * Derive the status of a batch from its fields, the recalls covering it and the products packaged from it
+ */
+export async function deriveBatchStatus(ctx: Context, batch: RiceBatch, facts: BatchStatusFacts = {}): Promise<string> {
+    if (batch.disposal || batch.currentState === DISPOSED_STATE) {
+        return 'Disposed';
+    }
+    if (facts.recalled || (await getIndexEntries(ctx, RECALL_ITEM_INDEX, [batch.batchId])).length > 0) {
+        return 'Recalled';
+    }
+    if (batch.quarantined) {
+        return 'Quarantined';
+    }
+
+    const products = new Map<string, Product>();
+    for (const [, productId] of await getIndexEntries(ctx, PRODUCT_BATCH_INDEX, [batch.batchId])) {
+        const product = await readDocument<Product>(ctx, `product_${productId}`);
+        // Guard against stale index entries
+        if (product && product.batchId === batch.batchId) {
+            products.set(productId, product);
+        }
+    }
+    for (const product of facts.products || []) {
+        products.set(product.productId, product);
+    }
+    if (products.size > 0 && [...products.values()].every(product => CLOSED_PRODUCT_STATUSES.includes(product.status || 'Active'))) {
+        return 'Archived';
+    }
+    if (products.size > 0 || batch.currentState === 'Packaged') {
+        return 'Finalized';
+    }
+    return 'Active';
+}
+
+/**
+ * Add the derived status of the batch, as the patch leaves it, to a batch patch and move the batch in the status index
+ * The status is written with the patch: a second write in the same transaction would not see the first
+ */
+export async function withBatchStatus(
+    ctx: Context, batch: RiceBatch, patch: Partial<RiceBatch>, facts: BatchStatusFacts = {}
+): Promise<Partial<RiceBatch>> {
+    const status = await deriveBatchStatus(ctx, { ...batch, ...patch } as RiceBatch, facts);
+    if (status === batch.status) {
+        return patch;
+    }
+    if (batch.status) {
+        await deleteIndexEntry(ctx, BATCH_STATUS_INDEX, [batch.status, batch.batchId]);
+    }
+    await putIndexEntry(ctx, BATCH_STATUS_INDEX, [status, batch.batchId]);
+    return { ...patch, status };
+}
+
+/**
+ * Update the status of a batch the current transaction does not otherwise write, e.g. when one of its products
+ * is sold. Returns the new status
+ */
+export async function refreshBatchStatus(ctx: Context, batchId: string, facts: BatchStatusFacts = {}): Promise<string | undefined> {
+    const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+    if (!batch) {
+        return undefined;
+    }
+    const patch = await withBatchStatus(ctx, batch, {}, facts);
+    if (patch.status) {
+        await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, patch);
+    }
+    return patch.status || batch.status;
+}
+
+@Info({ title: 'BatchStatusContract', description: 'Smart contract querying batches by their derived status' })
+export class BatchStatusContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "BatchStatusContract Method Permission Configuration": {
+                "GetBatchesByStatus": ["All Organizations"],
+                "RebuildStatusIndex": ["Organization Administrators"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Get all rice batches with a derived status (Active, Quarantined, Recalled, Finalized, Archived or Disposed),
+     * using the status index
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('RiceBatch[]')
+    public async GetBatchesByStatus(ctx: Context, status: string): Promise<RiceBatch[]> {
+        const matched = BATCH_STATUSES.find(candidate => candidate.toLowerCase() === String(status || '').toLowerCase());
+        if (!matched) {
+            throw new Error(`Invalid batch status ${status}, expected one of: ${BATCH_STATUSES.join(', ')}`);
+        }
+
+        const batches: RiceBatch[] = [];
+        for (const [, batchId] of await getIndexEntries(ctx, BATCH_STATUS_INDEX, [matched])) {
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+            // Guard against stale index entries
+            if (batch && batch.status === matched) {
+                batches.push(batch);
+            }
+        }
+        return batches;
+    }
+
+    /**
+     * Derive the status of every stored batch and rebuild the status index
+     * Needed once after upgrading from a version that did not maintain the status. Returns the number of batches
+     * whose status changed
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    @Returns('number')
+    public async RebuildStatusIndex(ctx: Context): Promise<number> {
+        checkOrgAdmin(ctx);
+
+        let changed = 0;
+        const iterator = await ctx.stub.getStateByRange('batch_', 'batch_\uffff');
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                const batch: RiceBatch = JSON.parse(result.value.value.toString());
+                if (batch.batchId) {
+                    const status = await deriveBatchStatus(ctx, batch);
+                    await putIndexEntry(ctx, BATCH_STATUS_INDEX, [status, batch.batchId]);
+                    if (status !== batch.status) {
+                        if (batch.status) {
+                            await deleteIndexEntry(ctx, BATCH_STATUS_INDEX, [batch.status, batch.batchId]);
+                        }
+                        await writeDocument(ctx, `batch_${batch.batchId}`, { ...batch, status });
+                        changed++;
+                    }
+                }
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+        return changed;
+    }
+}
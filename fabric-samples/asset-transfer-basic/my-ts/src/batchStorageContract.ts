@@ -0,0 +1,277 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import stringify from 'json-stringify-deterministic';
+import sortKeysRecursive from 'sort-keys-recursive';
+import { BatchHistorySegment, BatchStorageLimits, BatchStorageUsage, HistoryEvent, RiceBatch } from './types';
+import { readDocument, writeDocument, getTxTimestamp, checkOrgAdmin, getIndexEntries, inheritTenant } from './utils';
+
+/**
+ * Ledger key of the configured batch storage limits
+ */
+const BATCH_LIMITS_KEY = 'limits_batchStorage';
+
+/**
+ * Key prefix of the continuation keys holding archived batch history
+ * (deliberately not batch_, so batch range scans do not return segments)
+ */
+export const BATCH_HISTORY_PREFIX = 'batchhistory_';
+
+/**
+ * Composite key index of test results by batch, counted against maxTestResultsPerBatch
+ */
+export const BATCH_TEST_INDEX = 'batchId~testId';
+
+/**
+ * Limits used until an administrator configures others
+ */
+const DEFAULT_BATCH_LIMITS: BatchStorageLimits = {
+    docType: 'batchStorageLimits',
+    maxHistoryEvents: 200,
+    maxTestResultsPerBatch: 100,
+    maxDocumentBytes: 1024 * 1024,
+    version: 0
+};
+
+/**
+ * Get the batch storage limits in force
+ */
+export async function readBatchLimits(ctx: Context): Promise<BatchStorageLimits> {
+    return (await readDocument<BatchStorageLimits>(ctx, BATCH_LIMITS_KEY)) || DEFAULT_BATCH_LIMITS;
+}
+
+/**
+ * Key of a history continuation segment; zero-padded so segments sort in order
+ */
+function historySegmentKey(batchId: string, segment: number): string {
+    return `${BATCH_HISTORY_PREFIX}${batchId}_${String(segment).padStart(6, '0')}`;
+}
+
+/**
+ * Time the batch was created on the ledger: its first history event, read from the first continuation segment
+ * once the start of the history has been archived. Empty for a batch without history
+ */
+export async function getBatchCreationTime(ctx: Context, batch: RiceBatch): Promise<string> {
+    if (batch.archivedHistorySegments) {
+        const first = await readDocument<BatchHistorySegment>(ctx, historySegmentKey(batch.batchId, 1));
+        if (first && first.events.length > 0) {
+            return first.events[0].timestamp;
+        }
+    }
+    return batch.history.length > 0 ? batch.history[0].timestamp : '';
+}
+
+/**
+ * Return the batch with its full history: the archived segments followed by the events on the document
+ */
+export async function withArchivedHistory<T extends RiceBatch>(ctx: Context, batch: T): Promise<T> {
+    if (!batch.archivedHistorySegments) {
+        return batch;
+    }
+    const archived: HistoryEvent[] = [];
+    for (let segment = 1; segment <= batch.archivedHistorySegments; segment++) {
+        const stored = await readDocument<BatchHistorySegment>(ctx, historySegmentKey(batch.batchId, segment));
+        if (!stored) {
+            throw new Error(`History segment ${segment} of batch ${batch.batchId} is missing`);
+        }
+        archived.push(...stored.events);
+    }
+    return { ...batch, history: [...archived, ...batch.history] };
+}
+
+/**
+ * Work out how an event is appended to a stored batch document under the limits: the batch patch, and the
+ * continuation segment to write first when the stored history has reached maxHistoryEvents.
+ * Throws if the resulting batch document would exceed maxDocumentBytes
+ */
+function planHistoryAppend(limits: BatchStorageLimits, batch: RiceBatch, event: HistoryEvent): { patch: Partial<RiceBatch>; continuation?: BatchHistorySegment } {
+    const patch: Partial<RiceBatch> = { history: [...batch.history, event] };
+    let continuation: BatchHistorySegment | undefined;
+
+    if (batch.history.length >= limits.maxHistoryEvents) {
+        const segment = (batch.archivedHistorySegments || 0) + 1;
+        const archivedEvents = batch.archivedHistoryEvents || 0;
+        continuation = {
+            docType: 'batchHistorySegment',
+            batchId: batch.batchId,
+            segment,
+            firstIndex: archivedEvents,
+            events: batch.history
+        };
+        inheritTenant(continuation, batch);
+        patch.history = [event];
+        patch.archivedHistorySegments = segment;
+        patch.archivedHistoryEvents = archivedEvents + batch.history.length;
+    }
+
+    const documentBytes = serializedSize({ ...batch, ...patch });
+    if (documentBytes > limits.maxDocumentBytes) {
+        throw new Error(`The rice batch ${batch.batchId} would grow to ${documentBytes} bytes, above the limit of ${limits.maxDocumentBytes} bytes`);
+    }
+    return { patch, continuation };
+}
+
+/**
+ * Build the patch appending an event to the history of a stored batch document
+ * When the stored history has reached maxHistoryEvents it moves to a new continuation segment first.
+ * Throws if the resulting batch document would exceed maxDocumentBytes
+ */
+export async function appendHistoryEvent(ctx: Context, batch: RiceBatch, event: HistoryEvent): Promise<Partial<RiceBatch>> {
+    const { patch, continuation } = planHistoryAppend(await readBatchLimits(ctx), batch, event);
+    if (continuation) {
+        await writeDocument(ctx, historySegmentKey(batch.batchId, continuation.segment), continuation);
+    }
+    return patch;
+}
+
+/**
+ * Check, without writing anything, that an event can be appended to a stored batch document under the limits
+ */
+export async function assertHistoryEventFits(ctx: Context, batch: RiceBatch, event: HistoryEvent): Promise<void> {
+    planHistoryAppend(await readBatchLimits(ctx), batch, event);
+}
+
+/**
+ * Build the patch setting fields on the event at index of the full history of a stored batch document
+ * Events moved to a continuation segment are updated in their segment
+ */
+export async function updateHistoryEvent(ctx: Context, batch: RiceBatch, index: number, fields: Partial<HistoryEvent>): Promise<Partial<RiceBatch>> {
+    const archivedEvents = batch.archivedHistoryEvents || 0;
+    if (index >= archivedEvents && index < archivedEvents + batch.history.length) {
+        const history = [...batch.history];
+        history[index - archivedEvents] = { ...history[index - archivedEvents], ...fields };
+        return { history };
+    }
+
+    for (let segment = 1; segment <= (batch.archivedHistorySegments || 0); segment++) {
+        const key = historySegmentKey(batch.batchId, segment);
+        const stored = await readDocument<BatchHistorySegment>(ctx, key);
+        if (!stored) {
+            throw new Error(`History segment ${segment} of batch ${batch.batchId} is missing`);
+        }
+        if (index >= stored.firstIndex && index < stored.firstIndex + stored.events.length) {
+            stored.events[index - stored.firstIndex] = { ...stored.events[index - stored.firstIndex], ...fields };
+            await writeDocument(ctx, key, stored);
+            return {};
+        }
+    }
+    throw new Error(`History event ${index} of batch ${batch.batchId} does not exist`);
+}
+
+/**
+ * Reject a new test result once the batch has maxTestResultsPerBatch of them
+ */
+export async function assertTestResultCapacity(ctx: Context, batchId: string): Promise<void> {
+    const limits = await readBatchLimits(ctx);
+    const count = (await getIndexEntries(ctx, BATCH_TEST_INDEX, [batchId])).length;
+    if (count >= limits.maxTestResultsPerBatch) {
+        throw new Error(`Batch ${batchId} already has ${count} test results, the maximum of ${limits.maxTestResultsPerBatch}`);
+    }
+}
+
+/**
+ * Size in bytes of a document as it is written to world state
+ */
+function serializedSize(doc: object): number {
+    return Buffer.byteLength(stringify(sortKeysRecursive(doc)));
+}
+
+@Info({ title: 'BatchStorageContract', description: 'Smart contract capping how much a batch accumulates and reporting its storage usage' })
+export class BatchStorageContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "BatchStorageContract Method Permission Configuration": {
+                "DefineBatchStorageLimits": ["Organization Administrators"],
+                "GetBatchStorageLimits": ["All Organizations"],
+                "GetBatchStorageUsage": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Configure the batch storage limits
+     * limitsJSON: { maxHistoryEvents, maxTestResultsPerBatch, maxDocumentBytes }, all positive integers.
+     * Lowering maxHistoryEvents takes effect on the next event recorded on each batch
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async DefineBatchStorageLimits(ctx: Context, limitsJSON: string): Promise<void> {
+        checkOrgAdmin(ctx);
+
+        let input: any;
+        try {
+            input = JSON.parse(limitsJSON);
+        } catch (error) {
+            throw new Error(`Batch storage limits format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('Batch storage limits must be an object');
+        }
+        for (const limit of ['maxHistoryEvents', 'maxTestResultsPerBatch', 'maxDocumentBytes']) {
+            if (!Number.isInteger(input[limit]) || input[limit] <= 0) {
+                throw new Error(`${limit} must be a positive integer`);
+            }
+        }
+
+        const existing = await readDocument<BatchStorageLimits>(ctx, BATCH_LIMITS_KEY);
+        const limits: BatchStorageLimits = {
+            docType: 'batchStorageLimits',
+            maxHistoryEvents: input.maxHistoryEvents,
+            maxTestResultsPerBatch: input.maxTestResultsPerBatch,
+            maxDocumentBytes: input.maxDocumentBytes,
+            version: existing ? existing.version + 1 : 1,
+            definedBy: ctx.clientIdentity.getMSPID(),
+            lastUpdated: getTxTimestamp(ctx)
+        };
+
+        await writeDocument(ctx, BATCH_LIMITS_KEY, limits);
+    }
+
+    /**
+     * Get the batch storage limits in force (the built-in defaults, version 0, until configured)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('BatchStorageLimits')
+    public async GetBatchStorageLimits(ctx: Context): Promise<BatchStorageLimits> {
+        return readBatchLimits(ctx);
+    }
+
+    /**
+     * Report the size of a batch document and its history and test result counts against the limits
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('BatchStorageUsage')
+    public async GetBatchStorageUsage(ctx: Context, batchId: string): Promise<BatchStorageUsage> {
+        const data = await ctx.stub.getState(`batch_${batchId}`);
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        const limits = await readBatchLimits(ctx);
+
+        return {
+            batchId,
+            documentBytes: data.length,
+            maxDocumentBytes: limits.maxDocumentBytes,
+            storedHistoryEvents: batch.history.length,
+            maxHistoryEvents: limits.maxHistoryEvents,
+            archivedHistorySegments: batch.archivedHistorySegments || 0,
+            totalHistoryEvents: (batch.archivedHistoryEvents || 0) + batch.history.length,
+            testResults: (await getIndexEntries(ctx, BATCH_TEST_INDEX, [batchId])).length,
+            maxTestResultsPerBatch: limits.maxTestResultsPerBatch
+        };
+    }
+}
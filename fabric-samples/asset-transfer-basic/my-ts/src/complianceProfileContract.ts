@@ -0,0 +1,376 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { Attachment, ComplianceProfile, ComplianceViolation, DomesticMarket, ExportComplianceCheck, ResidueLimit, RiceBatch, TestResult } from './types';
+import { ATTACHMENT_CATEGORIES, ENTITY_ATTACHMENT_INDEX } from './attachmentContract';
+import { BATCH_TEST_INDEX } from './batchStorageContract';
+import { readDocument, writeDocument, getTxTimestamp, checkOrgAdmin, getIndexEntries, isPassingResult } from './utils';
+
+/**
+ * Key prefix of compliance profiles, followed by the market ID
+ */
+export const COMPLIANCE_PROFILE_PREFIX = 'compliance_';
+
+/**
+ * Ledger key of the configured domestic market
+ */
+const DOMESTIC_MARKET_KEY = 'market_domestic';
+
+/**
+ * Domestic market used until an administrator configures another
+ */
+const DEFAULT_DOMESTIC_MARKET: DomesticMarket = {
+    docType: 'domesticMarket',
+    country: 'CN',
+    version: 0
+};
+
+/**
+ * Get the country the supply chain operates in; shipments to any other country are exports
+ */
+export async function readDomesticCountry(ctx: Context): Promise<string> {
+    return ((await readDocument<DomesticMarket>(ctx, DOMESTIC_MARKET_KEY)) || DEFAULT_DOMESTIC_MARKET).country;
+}
+
+/**
+ * Read the compliance profile covering a destination country, if any
+ */
+async function findProfileByCountry(ctx: Context, country: string): Promise<ComplianceProfile | null> {
+    for (const profile of await readAllProfiles(ctx)) {
+        if (profile.countries.includes(country)) {
+            return profile;
+        }
+    }
+    return null;
+}
+
+/**
+ * Read every compliance profile, in market ID order
+ */
+async function readAllProfiles(ctx: Context): Promise<ComplianceProfile[]> {
+    const resultsIterator = await ctx.stub.getStateByRange(COMPLIANCE_PROFILE_PREFIX, `${COMPLIANCE_PROFILE_PREFIX}\uffff`);
+    const profiles: ComplianceProfile[] = [];
+
+    let result = await resultsIterator.next();
+    while (!result.done) {
+        if (result.value && result.value.value.toString()) {
+            try {
+                const profile: ComplianceProfile = JSON.parse(result.value.value.toString());
+                if (profile.market) {
+                    profiles.push(profile);
+                }
+            } catch (error) {
+                // Skip invalid data
+                console.warn(`Skipping invalid compliance profile data: ${error}`);
+            }
+        }
+        result = await resultsIterator.next();
+    }
+
+    await resultsIterator.close();
+    return profiles;
+}
+
+/**
+ * Check a batch against a compliance profile: passed tests, residue levels and attached documents
+ */
+async function checkBatch(ctx: Context, batchId: string, profile: ComplianceProfile): Promise<ExportComplianceCheck> {
+    const tests: TestResult[] = [];
+    for (const [, testId] of await getIndexEntries(ctx, BATCH_TEST_INDEX, [batchId])) {
+        const test = await readDocument<TestResult>(ctx, `test_${testId}`);
+        if (test && !test.revoked) {
+            tests.push(test);
+        }
+    }
+    const categories = new Set<string>();
+    for (const [, attachmentId] of await getIndexEntries(ctx, ENTITY_ATTACHMENT_INDEX, [batchId])) {
+        const attachment = await readDocument<Attachment>(ctx, `attachment_${attachmentId}`);
+        if (attachment) {
+            categories.add(attachment.category);
+        }
+    }
+
+    const violations: ComplianceViolation[] = [];
+    for (const testType of profile.requiredTests) {
+        const passed = tests.some(test =>
+            (test.testType || '').trim().toLowerCase() === testType.toLowerCase() && isPassingResult(test.testResult || test.result));
+        if (!passed) {
+            violations.push({ rule: 'requiredTest', requirement: testType, message: `No passed ${testType} test is recorded` });
+        }
+    }
+    for (const limit of profile.residueLimits) {
+        const levels = tests
+            .filter(test => test.residues && test.residues[limit.substance] !== undefined)
+            .map(test => (test.residues as Record<string, number>)[limit.substance]);
+        if (levels.length === 0) {
+            violations.push({ rule: 'residueLimit', requirement: limit.substance, message: `No ${limit.substance} residue level is recorded` });
+        } else if (Math.max(...levels) > limit.maxMgPerKg) {
+            violations.push({
+                rule: 'residueLimit',
+                requirement: limit.substance,
+                message: `${limit.substance} residue of ${Math.max(...levels)} mg/kg exceeds the limit of ${limit.maxMgPerKg} mg/kg`
+            });
+        }
+    }
+    for (const category of profile.requiredDocuments) {
+        if (!categories.has(category)) {
+            violations.push({ rule: 'requiredDocument', requirement: category, message: `No ${category} document is attached` });
+        }
+    }
+
+    return {
+        batchId,
+        market: profile.market,
+        profileVersion: profile.version,
+        compliant: violations.length === 0,
+        violations,
+        checkedAt: getTxTimestamp(ctx)
+    };
+}
+
+/**
+ * Check that batches meet the compliance profile covering a destination country before they are exported
+ * Destinations without a profile are not restricted
+ */
+export async function assertExportCompliance(ctx: Context, destinationCountry: string, batchIds: string[]): Promise<void> {
+    const profile = await findProfileByCountry(ctx, destinationCountry);
+    if (!profile) {
+        return;
+    }
+    for (const batchId of batchIds) {
+        const check = await checkBatch(ctx, batchId, profile);
+        if (!check.compliant) {
+            throw new Error(`The rice batch ${batchId} does not meet the ${profile.market} compliance profile: ` +
+                check.violations.map(violation => violation.message).join('; '));
+        }
+    }
+}
+
+@Info({ title: 'ComplianceProfileContract', description: 'Smart contract checking batches against the import requirements of export markets' })
+export class ComplianceProfileContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "ComplianceProfileContract Method Permission Configuration": {
+                "DefineComplianceProfile": ["Organization Administrators"],
+                "DefineDomesticMarket": ["Organization Administrators"],
+                "GetDomesticMarket": ["All Organizations"],
+                "ReadComplianceProfile": ["All Organizations"],
+                "GetAllComplianceProfiles": ["All Organizations"],
+                "CheckExportCompliance": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Create or update the compliance profile of an export market, e.g. EU MRLs or Japanese import rules
+     * market is a market ID such as EU or JP; profileJSON: { name, countries, requiredTests, residueLimits,
+     * requiredDocuments }. countries lists the ISO 3166-1 alpha-2 destinations the profile applies to, at least
+     * one and none covered by another market. requiredTests are test types a batch must have passed,
+     * residueLimits [{ substance, maxMgPerKg }] the MRLs its recorded residue levels must stay within, and
+     * requiredDocuments attachment categories it must carry. Updating a profile bumps its version
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async DefineComplianceProfile(ctx: Context, market: string, profileJSON: string): Promise<void> {
+        checkOrgAdmin(ctx);
+
+        const marketId = (market || '').trim().toUpperCase();
+        if (!/^[A-Z0-9-]{2,16}$/.test(marketId)) {
+            throw new Error(`Invalid market ${market}: expected 2 to 16 letters, digits or dashes, e.g. EU or JP`);
+        }
+
+        let definition: { name?: string; countries?: unknown; requiredTests?: unknown; residueLimits?: unknown; requiredDocuments?: unknown };
+        try {
+            definition = JSON.parse(profileJSON);
+        } catch (error) {
+            throw new Error(`Compliance profile format error: ${error}`);
+        }
+        if (!definition || typeof definition !== 'object' || !definition.name) {
+            throw new Error('Compliance profile requires a name');
+        }
+
+        const countries = this.parseList(definition.countries, 'countries').map(country => country.toUpperCase());
+        if (countries.length === 0) {
+            throw new Error('Compliance profile requires at least one destination country');
+        }
+        const domesticCountry = await readDomesticCountry(ctx);
+        for (const country of countries) {
+            if (!/^[A-Z]{2}$/.test(country)) {
+                throw new Error(`Invalid country ${country}: expected an ISO 3166-1 alpha-2 code`);
+            }
+            if (country === domesticCountry) {
+                throw new Error(`Compliance profiles are for exports; ${country} is the domestic market`);
+            }
+            const covering = await findProfileByCountry(ctx, country);
+            if (covering && covering.market !== marketId) {
+                throw new Error(`Country ${country} is already covered by the ${covering.market} compliance profile`);
+            }
+        }
+
+        const requiredDocuments = this.parseList(definition.requiredDocuments, 'requiredDocuments');
+        for (const category of requiredDocuments) {
+            if (!ATTACHMENT_CATEGORIES[category]) {
+                throw new Error(`Invalid required document ${category}. Allowed values: ${Object.keys(ATTACHMENT_CATEGORIES).join(', ')}`);
+            }
+        }
+
+        const now = getTxTimestamp(ctx);
+        const existing = await readDocument<ComplianceProfile>(ctx, `${COMPLIANCE_PROFILE_PREFIX}${marketId}`);
+
+        const profile: ComplianceProfile = {
+            docType: 'complianceProfile',
+            market: marketId,
+            name: definition.name,
+            countries,
+            requiredTests: this.parseList(definition.requiredTests, 'requiredTests'),
+            residueLimits: this.parseResidueLimits(definition.residueLimits),
+            requiredDocuments,
+            version: existing ? existing.version + 1 : 1,
+            definedBy: ctx.clientIdentity.getMSPID(),
+            createdTimestamp: existing ? existing.createdTimestamp : now,
+            lastUpdated: now
+        };
+
+        await writeDocument(ctx, `${COMPLIANCE_PROFILE_PREFIX}${marketId}`, profile);
+    }
+
+    /**
+     * Configure the country the supply chain operates in (CN until configured)
+     * country is an ISO 3166-1 alpha-2 code not covered by a compliance profile. Shipped steps to any other country
+     * are exports and need a phytosanitary certificate, and consignments cannot go to the domestic market
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async DefineDomesticMarket(ctx: Context, country: string): Promise<void> {
+        checkOrgAdmin(ctx);
+
+        const code = (country || '').trim().toUpperCase();
+        if (!/^[A-Z]{2}$/.test(code)) {
+            throw new Error(`Invalid country ${country}: expected an ISO 3166-1 alpha-2 code`);
+        }
+        const covering = await findProfileByCountry(ctx, code);
+        if (covering) {
+            throw new Error(`Country ${code} is an export market of the ${covering.market} compliance profile`);
+        }
+
+        const existing = await readDocument<DomesticMarket>(ctx, DOMESTIC_MARKET_KEY);
+        const market: DomesticMarket = {
+            docType: 'domesticMarket',
+            country: code,
+            version: existing ? existing.version + 1 : 1,
+            definedBy: ctx.clientIdentity.getMSPID(),
+            lastUpdated: getTxTimestamp(ctx)
+        };
+
+        await writeDocument(ctx, DOMESTIC_MARKET_KEY, market);
+    }
+
+    /**
+     * Get the domestic market in force (the built-in default CN, version 0, until configured)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('DomesticMarket')
+    public async GetDomesticMarket(ctx: Context): Promise<DomesticMarket> {
+        return (await readDocument<DomesticMarket>(ctx, DOMESTIC_MARKET_KEY)) || DEFAULT_DOMESTIC_MARKET;
+    }
+
+    /**
+     * Read the compliance profile of a market
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ComplianceProfile')
+    public async ReadComplianceProfile(ctx: Context, market: string): Promise<ComplianceProfile> {
+        const profile = await readDocument<ComplianceProfile>(ctx, `${COMPLIANCE_PROFILE_PREFIX}${(market || '').trim().toUpperCase()}`);
+        if (!profile) {
+            throw new Error(`Compliance profile ${market} does not exist`);
+        }
+        return profile;
+    }
+
+    /**
+     * Get all compliance profiles
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ComplianceProfile[]')
+    public async GetAllComplianceProfiles(ctx: Context): Promise<ComplianceProfile[]> {
+        return readAllProfiles(ctx);
+    }
+
+    /**
+     * Check whether a batch may be exported to a market, listing every requirement it does not meet
+     * market is a market ID or a destination country covered by a profile. A required test counts when a result of
+     * that type passed and was not revoked; a residue limit is met when the highest level recorded for the substance
+     * by a result that was not revoked is within it. Consignments to the market are refused while this fails
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ExportComplianceCheck')
+    public async CheckExportCompliance(ctx: Context, batchId: string, market: string): Promise<ExportComplianceCheck> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+
+        const marketId = (market || '').trim().toUpperCase();
+        const profile = await readDocument<ComplianceProfile>(ctx, `${COMPLIANCE_PROFILE_PREFIX}${marketId}`) ||
+            await findProfileByCountry(ctx, marketId);
+        if (!profile) {
+            throw new Error(`No compliance profile is defined for ${market}`);
+        }
+        return checkBatch(ctx, batchId, profile);
+    }
+
+    /**
+     * Validate an optional list of non-empty strings from a profile definition
+     */
+    private parseList(value: unknown, fieldName: string): string[] {
+        if (value === undefined || value === null) {
+            return [];
+        }
+        if (!Array.isArray(value) || value.some(item => typeof item !== 'string' || !item.trim())) {
+            throw new Error(`${fieldName} must be a list of non-empty strings`);
+        }
+        return [...new Set(value.map(item => item.trim()))];
+    }
+
+    /**
+     * Validate residue limits; substances are matched case-insensitively against recorded residue levels
+     */
+    private parseResidueLimits(value: unknown): ResidueLimit[] {
+        if (value === undefined || value === null) {
+            return [];
+        }
+        if (!Array.isArray(value)) {
+            throw new Error('residueLimits must be a list of { substance, maxMgPerKg }');
+        }
+        const limits: ResidueLimit[] = [];
+        for (const item of value) {
+            const substance = item && typeof item.substance === 'string' ? item.substance.trim().toLowerCase() : '';
+            if (!substance) {
+                throw new Error('Every residue limit requires a substance');
+            }
+            if (typeof item.maxMgPerKg !== 'number' || !Number.isFinite(item.maxMgPerKg) || item.maxMgPerKg < 0) {
+                throw new Error(`Residue limit of ${substance} must be a non-negative number of mg/kg`);
+            }
+            if (limits.some(limit => limit.substance === substance)) {
+                throw new Error(`Residue limit of ${substance} is defined more than once`);
+            }
+            limits.push({ substance, maxMgPerKg: item.maxMgPerKg });
+        }
+        return limits;
+    }
+}
@@ -0,0 +1,377 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { Consignment, ConsignmentTransfer, ConsignmentTransferProgress, HistoryEvent, OrganizationType, Product, RiceBatch } from './types';
+import { BATCH_OWNER_INDEX } from './riceTracerContract';
+import { ProductManagementContract } from './productManagementContract';
+import { assertExportCompliance, readDomesticCountry } from './complianceProfileContract';
+import { appendHistoryEvent } from './batchStorageContract';
+import { consumeReservations } from './batchReservationContract';
+import { assertBulkSize } from './inputLimitContract';
+import {
+    readDocument, writeDocument, patchDocument, getTxTimestamp, emitEvent, putIndexEntry, getIndexEntries, deleteIndexEntry,
+    getCallerFingerprint, isProcessedRequest, markRequestProcessed, DISPOSED_STATE, checkOrgType, inheritTenant
+} from './utils';
+
+/**
+ * Composite key index of consignments by the batches and products they contain
+ */
+export const CONSIGNMENT_ITEM_INDEX = 'entity~consignmentId';
+
+/**
+ * Consignment statuses, in the order a consignment moves through them
+ */
+const CONSIGNMENT_STATUSES = ['Prepared', 'Inspected', 'Cleared', 'Shipped'];
+
+/**
+ * Most batches and products one TransferConsignment transaction hands over; larger consignments take several
+ */
+const MAX_TRANSFER_ITEMS_PER_TRANSACTION = 100;
+
+@Info({ title: 'ConsignmentContract', description: 'Smart contract recording export consignments and their customs documentation' })
+export class ConsignmentContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "ConsignmentContract Method Permission Configuration": {
+                "CreateConsignment": ["Farm", "Middleman/Tester"],
+                "AdvanceConsignment": ["Exporting organization"],
+                "TransferConsignment": ["Exporting organization (Middleman/Tester for consignments with products)"],
+                "ReadConsignment": ["All Organizations"],
+                "GetConsignmentsByEntity": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Prepare an export consignment of batches and products
+     * itemsJSON: { batchIds: [...], productIds: [...] }, at least one item; items must exist, not be disposed of
+     * and not be in another consignment that has not shipped yet. destinationCountry is an ISO 3166-1 alpha-2 code
+     * outside the domestic market. When a compliance profile covers the destination, every batch and the source batch
+     * of every product must pass CheckExportCompliance for it
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async CreateConsignment(ctx: Context, consignmentId: string, destinationCountry: string, itemsJSON: string): Promise<void> {
+        // Check permission: Only supply chain organizations export rice
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!consignmentId) {
+            throw new Error('Consignment ID is required');
+        }
+        const destination = (destinationCountry || '').trim().toUpperCase();
+        if (!/^[A-Z]{2}$/.test(destination)) {
+            throw new Error(`Invalid destination country ${destinationCountry}: expected an ISO 3166-1 alpha-2 code`);
+        }
+        if (destination === await readDomesticCountry(ctx)) {
+            throw new Error(`Consignments are for exports; ${destination} is the domestic market`);
+        }
+        // Consignment IDs are unique across tenants
+        const existing = await ctx.stub.getState(`consignment_${consignmentId}`);
+        if (existing && existing.length > 0) {
+            throw new Error(`The consignment ${consignmentId} already exists`);
+        }
+
+        let items: { batchIds?: unknown; productIds?: unknown };
+        try {
+            items = JSON.parse(itemsJSON);
+        } catch (error) {
+            throw new Error(`Consignment items format error: ${error}`);
+        }
+        const batchIds = this.parseIds(items?.batchIds, 'batchIds');
+        const productIds = this.parseIds(items?.productIds, 'productIds');
+        if (batchIds.length + productIds.length === 0) {
+            throw new Error('A consignment must contain at least one batch or product');
+        }
+        await assertBulkSize(ctx, batchIds.length + productIds.length, `Consignment ${consignmentId}`);
+
+        const sources: Array<RiceBatch | Product> = [];
+        for (const batchId of batchIds) {
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+            if (!batch) {
+                throw new Error(`The rice batch ${batchId} does not exist`);
+            }
+            if (batch.disposal || batch.currentState === DISPOSED_STATE) {
+                throw new Error(`The rice batch ${batchId} has been disposed of and cannot be exported`);
+            }
+            sources.push(batch);
+        }
+        const exportedBatchIds = new Set(batchIds);
+        for (const productId of productIds) {
+            const product = await readDocument<Product>(ctx, `product_${productId}`);
+            if (!product) {
+                throw new Error(`The product ${productId} does not exist`);
+            }
+            if (product.status === DISPOSED_STATE) {
+                throw new Error(`The product ${productId} has been disposed of and cannot be exported`);
+            }
+            exportedBatchIds.add(product.batchId);
+            sources.push(product);
+        }
+        // The batches, and the source batches of the products, must meet the destination market's requirements
+        await assertExportCompliance(ctx, destination, [...exportedBatchIds]);
+        for (const entityId of [...batchIds, ...productIds]) {
+            const pending = (await this.GetConsignmentsByEntity(ctx, entityId)).find(consignment => consignment.status !== 'Shipped');
+            if (pending) {
+                throw new Error(`${entityId} is already in consignment ${pending.consignmentId}, which has not shipped yet`);
+            }
+        }
+
+        const now = getTxTimestamp(ctx);
+        const exporterMspId = ctx.clientIdentity.getMSPID();
+        const consignment: Consignment = {
+            docType: 'consignment',
+            consignmentId,
+            exporterMspId,
+            destinationCountry: destination,
+            batchIds,
+            productIds,
+            status: 'Prepared',
+            statusHistory: [{ status: 'Prepared', timestamp: now, mspId: exporterMspId }],
+            createdAt: now
+        };
+        // The items are all visible to the caller, so those of a tenant belong to the caller's tenant
+        sources.forEach(source => inheritTenant(consignment, source));
+
+        await writeDocument(ctx, `consignment_${consignmentId}`, consignment);
+        for (const entityId of [...batchIds, ...productIds]) {
+            await putIndexEntry(ctx, CONSIGNMENT_ITEM_INDEX, [entityId, consignmentId]);
+        }
+        emitEvent(ctx, 'ConsignmentCreated', consignment);
+    }
+
+    /**
+     * Move a consignment to its next status: Prepared -> Inspected -> Cleared -> Shipped
+     * reference is the SHA-256 of the phytosanitary certificate when moving to Inspected, and the customs
+     * declaration reference when moving to Cleared; it is not used for Shipped
+     * Permission: Only the exporting organization can call
+     */
+    @Transaction()
+    public async AdvanceConsignment(ctx: Context, consignmentId: string, status: string, reference: string, note: string): Promise<void> {
+        const consignment = await this.ReadConsignment(ctx, consignmentId);
+        const mspId = ctx.clientIdentity.getMSPID();
+        if (consignment.exporterMspId !== mspId) {
+            throw new Error(`Permission denied: Only the exporting organization ${consignment.exporterMspId} can advance consignment ${consignmentId}`);
+        }
+
+        const next = CONSIGNMENT_STATUSES[CONSIGNMENT_STATUSES.indexOf(consignment.status) + 1];
+        if (!next) {
+            throw new Error(`The consignment ${consignmentId} has already shipped`);
+        }
+        if (status !== next) {
+            throw new Error(`The consignment ${consignmentId} is ${consignment.status} and can only move to ${next}, not ${status}`);
+        }
+
+        const changes: Partial<Consignment> = { status };
+        if (status === 'Inspected') {
+            const hash = (reference || '').toLowerCase();
+            if (!/^[0-9a-f]{64}$/.test(hash)) {
+                throw new Error('Phytosanitary certificate hash must be a SHA-256 digest (64 hex characters)');
+            }
+            changes.phytosanitaryCertificateHash = hash;
+        } else if (status === 'Cleared') {
+            if (!reference) {
+                throw new Error('Customs declaration reference is required to clear a consignment');
+            }
+            changes.customsDeclarationRef = reference;
+        }
+        changes.statusHistory = [
+            ...consignment.statusHistory,
+            { status, timestamp: getTxTimestamp(ctx), mspId, ...(note ? { note } : {}) }
+        ];
+
+        const updated = await patchDocument<Consignment>(ctx, `consignment_${consignmentId}`, changes);
+        emitEvent(ctx, 'ConsignmentStatusChanged', updated);
+    }
+
+    /**
+     * Hand every batch and product of a cleared or shipped consignment over to the importer
+     * Batches get a handover event in their history (their processing step is unchanged) and products a sale to
+     * newOwner, as TransferProduct records it. At most 100 items are handed over per transaction: while remaining
+     * is above 0, call again with the same newOwner to continue. Items already owned by newOwner count as handed over
+     * newOwnerMspId: organization of the importer, which endorses later product updates (empty to keep the current organization)
+     * clientRequestId: idempotency key - a retry with the same ID is not applied twice (empty to disable)
+     * Permission: Only the exporting organization can call; products also require a middleman/tester, like TransferProduct
+     */
+    @Transaction()
+    @Returns('ConsignmentTransferProgress')
+    public async TransferConsignment(ctx: Context, consignmentId: string, newOwner: string, newOwnerMspId: string, clientRequestId: string): Promise<ConsignmentTransferProgress> {
+        const consignment = await this.ReadConsignment(ctx, consignmentId);
+        const mspId = ctx.clientIdentity.getMSPID();
+        if (consignment.exporterMspId !== mspId) {
+            throw new Error(`Permission denied: Only the exporting organization ${consignment.exporterMspId} can transfer consignment ${consignmentId}`);
+        }
+
+        // A gateway retry of an already processed chunk reports the progress without handing over the next one
+        if (await isProcessedRequest(ctx, clientRequestId, 'TransferConsignment')) {
+            return this.transferProgress(consignment, 0);
+        }
+
+        if (!newOwner) {
+            throw new Error('New owner is required');
+        }
+        if (consignment.status !== 'Cleared' && consignment.status !== 'Shipped') {
+            throw new Error(`The consignment ${consignmentId} is ${consignment.status}; it can only be transferred once Cleared`);
+        }
+        const now = getTxTimestamp(ctx);
+        const transfer: ConsignmentTransfer = consignment.transfer || {
+            newOwner,
+            newOwnerMspId: newOwnerMspId || '',
+            transferredBatchIds: [],
+            transferredProductIds: [],
+            startedAt: now
+        };
+        if (transfer.newOwner !== newOwner) {
+            throw new Error(`The consignment ${consignmentId} is being transferred to ${transfer.newOwner}, not ${newOwner}`);
+        }
+        if (transfer.completedAt) {
+            throw new Error(`The consignment ${consignmentId} has already been transferred to ${newOwner}`);
+        }
+
+        const pendingBatchIds = consignment.batchIds.filter(batchId => !transfer.transferredBatchIds.includes(batchId));
+        const pendingProductIds = consignment.productIds.filter(productId => !transfer.transferredProductIds.includes(productId));
+        let budget = MAX_TRANSFER_ITEMS_PER_TRANSACTION;
+        let transferred = 0;
+
+        for (const batchId of pendingBatchIds.slice(0, budget)) {
+            await this.handOverBatch(ctx, consignment, batchId, newOwner, now);
+            transfer.transferredBatchIds = [...transfer.transferredBatchIds, batchId];
+            transferred++;
+        }
+        budget -= transferred;
+
+        const products = new ProductManagementContract();
+        for (const productId of pendingProductIds.slice(0, budget)) {
+            const product = await readDocument<Product>(ctx, `product_${productId}`);
+            if (!product) {
+                throw new Error(`The product ${productId} of consignment ${consignmentId} does not exist`);
+            }
+            if (product.owner !== newOwner) {
+                await products.TransferProduct(ctx, productId, newOwner, transfer.newOwnerMspId, '');
+            }
+            transfer.transferredProductIds = [...transfer.transferredProductIds, productId];
+            transferred++;
+        }
+
+        const remaining = pendingBatchIds.length + pendingProductIds.length - transferred;
+        if (remaining === 0) {
+            transfer.completedAt = now;
+        }
+        const updated = await patchDocument<Consignment>(ctx, `consignment_${consignmentId}`, { transfer });
+        await markRequestProcessed(ctx, clientRequestId, 'TransferConsignment');
+        // Fabric keeps one event per transaction: the consignment event replaces those of the individual transfers
+        emitEvent(ctx, 'ConsignmentTransferred', updated);
+        return this.transferProgress(updated, transferred);
+    }
+
+    /**
+     * Read a consignment
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Consignment')
+    public async ReadConsignment(ctx: Context, consignmentId: string): Promise<Consignment> {
+        const consignment = await readDocument<Consignment>(ctx, `consignment_${consignmentId}`);
+        if (!consignment) {
+            throw new Error(`The consignment ${consignmentId} does not exist`);
+        }
+        return consignment;
+    }
+
+    /**
+     * Get the consignments a batch or product was exported in, for its provenance
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Consignment[]')
+    public async GetConsignmentsByEntity(ctx: Context, entityId: string): Promise<Consignment[]> {
+        const consignments: Consignment[] = [];
+        for (const [, consignmentId] of await getIndexEntries(ctx, CONSIGNMENT_ITEM_INDEX, [entityId])) {
+            const consignment = await readDocument<Consignment>(ctx, `consignment_${consignmentId}`);
+            if (consignment) {
+                consignments.push(consignment);
+            }
+        }
+        return consignments;
+    }
+
+    /**
+     * Record the handover of a batch of a consignment to the importer, keeping its processing step
+     */
+    private async handOverBatch(ctx: Context, consignment: Consignment, batchId: string, newOwner: string, now: string): Promise<void> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} of consignment ${consignment.consignmentId} does not exist`);
+        }
+        if (batch.currentOwner === newOwner) {
+            return;
+        }
+        if (batch.disposal || batch.currentState === DISPOSED_STATE) {
+            throw new Error(`The rice batch ${batchId} has been disposed of and cannot be transferred`);
+        }
+
+        // Quantity reserved for a pending sale only goes to its buyer
+        const reservations = consumeReservations(batch, newOwner, now);
+        const historyEvent: HistoryEvent = {
+            timestamp: now,
+            from: batch.currentOwner,
+            to: newOwner,
+            step: batch.currentState,
+            report: {
+                reportId: consignment.consignmentId,
+                reportType: 'ExportConsignment',
+                reportHash: consignment.phytosanitaryCertificateHash || '',
+                summary: `Handed over to ${newOwner} with export consignment ${consignment.consignmentId}`,
+                isVerified: false,
+                destinationCountry: consignment.destinationCountry
+            },
+            signerMspId: ctx.clientIdentity.getMSPID(),
+            signerFingerprint: getCallerFingerprint(ctx)
+        };
+        await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            ...await appendHistoryEvent(ctx, batch, historyEvent),
+            ...(reservations ? { reservations } : {}),
+            currentOwner: newOwner
+        });
+        await deleteIndexEntry(ctx, BATCH_OWNER_INDEX, [batch.currentOwner, batchId]);
+        await putIndexEntry(ctx, BATCH_OWNER_INDEX, [newOwner, batchId]);
+    }
+
+    /**
+     * Progress of the transfer of a consignment after a transaction that handed over transferred items
+     */
+    private transferProgress(consignment: Consignment, transferred: number): ConsignmentTransferProgress {
+        const transfer = consignment.transfer;
+        const done = transfer ? transfer.transferredBatchIds.length + transfer.transferredProductIds.length : 0;
+        return {
+            consignmentId: consignment.consignmentId,
+            newOwner: transfer ? transfer.newOwner : '',
+            transferred,
+            remaining: consignment.batchIds.length + consignment.productIds.length - done,
+            complete: !!(transfer && transfer.completedAt)
+        };
+    }
+
+    /**
+     * Validate an optional list of entity IDs from the consignment items
+     */
+    private parseIds(value: unknown, fieldName: string): string[] {
+        if (value === undefined || value === null) {
+            return [];
+        }
+        if (!Array.isArray(value) || value.some(id => typeof id !== 'string' || !id)) {
+            throw new Error(`${fieldName} must be a list of IDs`);
+        }
+        return [...new Set(value as string[])];
+    }
+}
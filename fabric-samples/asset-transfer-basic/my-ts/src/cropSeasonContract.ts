@@ -0,0 +1,191 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { RiceBatch, SeasonStats } from './types';
+import { QualityCertificationContract } from './qualityCertificationContract';
+import { PRODUCT_BATCH_INDEX } from './productManagementContract';
+import { readDocument, patchDocument, putIndexEntry, getIndexEntries, checkOrgAdmin, isPassingResult, DISPOSED_STATE, isVisibleToCaller } from './utils';
+
+/**
+ * Composite key index of batches by crop year and season
+ */
+export const CROP_SEASON_INDEX = 'cropYear~season~batchId';
+
+/**
+ * Rice growing seasons and the UTC months (1-12) their harvests fall in. Late-season harvests may run into
+ * January and February of the following calendar year; they still belong to the crop year the rice was planted in
+ */
+const CROP_SEASONS: Record<string, number[]> = {
+    Early: [3, 4, 5, 6, 7],
+    Middle: [8, 9],
+    Late: [10, 11, 12, 1, 2]
+};
+
+/**
+ * Work out the crop year and season of a harvest, checking the ones given (either may be empty) against it
+ * harvestDate is a normalized UTC RFC3339 timestamp
+ */
+export function resolveCropSeason(harvestDate: string, cropYear: string, season: string): { cropYear: number; season: string } {
+    const year = Number(harvestDate.slice(0, 4));
+    const month = Number(harvestDate.slice(5, 7));
+    const derivedSeason = Object.keys(CROP_SEASONS).find(name => CROP_SEASONS[name].includes(month)) as string;
+    // January and February harvests close the previous crop year's late season
+    const derivedYear = month <= 2 ? year - 1 : year;
+
+    if (season) {
+        const named = Object.keys(CROP_SEASONS).find(name => name.toLowerCase() === season.trim().toLowerCase());
+        if (!named) {
+            throw new Error(`Invalid season ${season}, expected one of: ${Object.keys(CROP_SEASONS).join(', ')}`);
+        }
+        if (named !== derivedSeason) {
+            throw new Error(`Season ${named} does not match harvestDate ${harvestDate}, which falls in the ${derivedSeason} season`);
+        }
+    }
+    if (cropYear) {
+        if (!/^\d{4}$/.test(cropYear.trim())) {
+            throw new Error(`Invalid crop year ${cropYear}: expected a four-digit year`);
+        }
+        if (Number(cropYear) !== derivedYear) {
+            throw new Error(`Crop year ${cropYear} does not match harvestDate ${harvestDate}, which belongs to crop year ${derivedYear}`);
+        }
+    }
+    return { cropYear: derivedYear, season: derivedSeason };
+}
+
+@Info({ title: 'CropSeasonContract', description: 'Smart contract aggregating batches by crop year and harvest season' })
+export class CropSeasonContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "CropSeasonContract Method Permission Configuration": {
+                "GetSeasonStats": ["All Organizations"],
+                "CompareSeasons": ["All Organizations"],
+                "BackfillCropSeasons": ["Organization Administrators"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Aggregate the batches of a crop year, or of one season of it when season is not empty: batch and product
+     * volumes, disposals, and test failure rate
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('SeasonStats')
+    public async GetSeasonStats(ctx: Context, cropYear: string, season: string): Promise<SeasonStats> {
+        if (!/^\d{4}$/.test(cropYear || '')) {
+            throw new Error(`Invalid crop year ${cropYear}: expected a four-digit year`);
+        }
+        const seasonName = season ? Object.keys(CROP_SEASONS).find(name => name.toLowerCase() === season.toLowerCase()) : '';
+        if (seasonName === undefined) {
+            throw new Error(`Invalid season ${season}, expected one of: ${Object.keys(CROP_SEASONS).join(', ')}`);
+        }
+
+        const stats: SeasonStats = {
+            cropYear: Number(cropYear),
+            season: seasonName,
+            batches: 0,
+            disposedBatches: 0,
+            products: 0,
+            testsRecorded: 0,
+            failedTests: 0,
+            failureRate: 0,
+            varieties: {}
+        };
+
+        const batchIds = new Set<string>();
+        for (const [, , batchId] of await getIndexEntries(ctx, CROP_SEASON_INDEX, seasonName ? [cropYear, seasonName] : [cropYear])) {
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+            if (!batch) {
+                continue;
+            }
+            batchIds.add(batchId);
+            stats.batches++;
+            stats.varieties[batch.variety] = (stats.varieties[batch.variety] || 0) + 1;
+            if (batch.disposal || batch.currentState === DISPOSED_STATE) {
+                stats.disposedBatches++;
+            }
+            stats.products += (await getIndexEntries(ctx, PRODUCT_BATCH_INDEX, [batchId])).length;
+        }
+
+        if (batchIds.size > 0) {
+            for (const test of await new QualityCertificationContract().GetAllTestResults(ctx)) {
+                if (batchIds.has(test.batchId) && !test.revoked) {
+                    stats.testsRecorded++;
+                    if (!isPassingResult(test.testResult || test.result)) {
+                        stats.failedTests++;
+                    }
+                }
+            }
+        }
+        stats.failureRate = stats.testsRecorded === 0 ? 0 : Math.round(10000 * stats.failedTests / stats.testsRecorded) / 10000;
+        return stats;
+    }
+
+    /**
+     * Year-over-year comparison: the statistics of each crop year from fromYear to toYear (at most 20 years),
+     * for one season or, when season is empty, whole crop years
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('SeasonStats[]')
+    public async CompareSeasons(ctx: Context, season: string, fromYear: string, toYear: string): Promise<SeasonStats[]> {
+        const from = Number(fromYear);
+        const to = Number(toYear);
+        if (!/^\d{4}$/.test(fromYear || '') || !/^\d{4}$/.test(toYear || '') || from > to) {
+            throw new Error(`Invalid crop year range ${fromYear}-${toYear}: expected two four-digit years in order`);
+        }
+        if (to - from >= 20) {
+            throw new Error('At most 20 crop years can be compared at once');
+        }
+
+        const stats: SeasonStats[] = [];
+        for (let year = from; year <= to; year++) {
+            stats.push(await this.GetSeasonStats(ctx, String(year), season));
+        }
+        return stats;
+    }
+
+    /**
+     * Set the crop year and season of batches registered before they were recorded, derived from the harvest date,
+     * and index them. Returns the number of batches updated
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async BackfillCropSeasons(ctx: Context): Promise<number> {
+        checkOrgAdmin(ctx);
+
+        let updated = 0;
+        const iterator = await ctx.stub.getStateByRange('batch_', 'batch_\uffff');
+        const batches: RiceBatch[] = [];
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                batches.push(JSON.parse(result.value.value.toString()));
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+
+        for (const batch of batches) {
+            if (!batch.batchId || !batch.harvestDate || (batch.cropYear && batch.season) || !isVisibleToCaller(ctx, batch)) {
+                continue;
+            }
+            const { cropYear, season } = resolveCropSeason(batch.harvestDate, '', '');
+            await patchDocument<RiceBatch>(ctx, `batch_${batch.batchId}`, { cropYear, season });
+            await putIndexEntry(ctx, CROP_SEASON_INDEX, [String(cropYear), season, batch.batchId]);
+            updated++;
+        }
+        return updated;
+    }
+}
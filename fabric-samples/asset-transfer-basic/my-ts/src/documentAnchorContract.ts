@@ -0,0 +1,268 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { AnchoredDocument, Attachment, DocumentAcknowledgment, OrganizationType, Product, RiceBatch, TestResult } from './types';
+import { ENTITY_ATTACHMENT_INDEX } from './attachmentContract';
+import { BATCH_TEST_INDEX } from './batchStorageContract';
+import { readDocument, writeDocument, getTxTimestamp, emitEvent, putIndexEntry, getIndexEntries, getCallerFingerprint, checkOrgType, inheritTenant } from './utils';
+
+/**
+ * Composite key indexes of anchored documents by the batch or product they concern and by file hash
+ */
+export const ENTITY_DOCUMENT_INDEX = 'entity~documentId';
+export const DOCUMENT_HASH_INDEX = 'documentHash~documentId';
+
+/**
+ * Composite key index of document acknowledgments by batch or product, document hash and acknowledging identity
+ */
+export const DOCUMENT_ACKNOWLEDGMENT_INDEX = 'entity~documentHash~fingerprint';
+
+@Info({ title: 'DocumentAnchorContract', description: 'Smart contract anchoring the hashes of documents issued about batches and products' })
+export class DocumentAnchorContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "DocumentAnchorContract Method Permission Configuration": {
+                "AnchorDocument": ["Farm", "Middleman/Tester"],
+                "ReadAnchoredDocument": ["All Organizations"],
+                "GetDocumentByHash": ["All Organizations"],
+                "GetAnchoredDocuments": ["All Organizations"],
+                "AcknowledgeDocument": ["All Organizations"],
+                "GetDocumentAcknowledgments": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Anchor the hash of a document issued about a batch or product, e.g. a PDF traceability certificate
+     * documentId is printed on the document; documentHash is the SHA-256 (hex) of the final file. The transaction,
+     * signed by the issuing identity, is the document's signature: a file is genuine when its hash is anchored
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    @Returns('AnchoredDocument')
+    public async AnchorDocument(
+        ctx: Context,
+        documentId: string,
+        entityId: string,
+        documentType: string,
+        documentHash: string
+    ): Promise<AnchoredDocument> {
+        // Check permission: Only the organizations producing the batches and products issue documents about them
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!documentId || !documentType) {
+            throw new Error('Document ID and document type are required');
+        }
+        const entity = await this.resolveEntity(ctx, entityId);
+        const hash = (documentHash || '').toLowerCase();
+        if (!/^[0-9a-f]{64}$/.test(hash)) {
+            throw new Error('Document hash must be a SHA-256 digest (64 hex characters)');
+        }
+        // Document IDs are unique across tenants
+        const existing = await ctx.stub.getState(`document_${documentId}`);
+        if (existing && existing.length > 0) {
+            throw new Error(`Document ${documentId} already exists`);
+        }
+        const [sameFile] = await getIndexEntries(ctx, DOCUMENT_HASH_INDEX, [hash]);
+        if (sameFile) {
+            throw new Error(`A document with hash ${hash} has already been anchored as ${sameFile[1]}`);
+        }
+
+        const document: AnchoredDocument = {
+            docType: 'anchoredDocument',
+            documentId,
+            entityId,
+            entityType: entity.entityType,
+            documentType,
+            documentHash: hash,
+            issuedBy: ctx.clientIdentity.getMSPID(),
+            issuerFingerprint: getCallerFingerprint(ctx),
+            issuedAt: getTxTimestamp(ctx)
+        };
+        inheritTenant(document, entity.source);
+        await writeDocument(ctx, `document_${documentId}`, document);
+        await putIndexEntry(ctx, ENTITY_DOCUMENT_INDEX, [entityId, documentId]);
+        await putIndexEntry(ctx, DOCUMENT_HASH_INDEX, [hash, documentId]);
+        emitEvent(ctx, 'DocumentAnchored', document);
+        return document;
+    }
+
+    /**
+     * Read an anchored document by ID
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('AnchoredDocument')
+    public async ReadAnchoredDocument(ctx: Context, documentId: string): Promise<AnchoredDocument> {
+        const document = await readDocument<AnchoredDocument>(ctx, `document_${documentId}`);
+        if (!document) {
+            throw new Error(`Document ${documentId} does not exist`);
+        }
+        return document;
+    }
+
+    /**
+     * Find the anchored document a file is, by the SHA-256 (hex) of the file
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('AnchoredDocument')
+    public async GetDocumentByHash(ctx: Context, documentHash: string): Promise<AnchoredDocument> {
+        const hash = (documentHash || '').toLowerCase();
+        const [entry] = await getIndexEntries(ctx, DOCUMENT_HASH_INDEX, [hash]);
+        if (!entry) {
+            throw new Error(`No document with hash ${hash} has been anchored`);
+        }
+        return this.ReadAnchoredDocument(ctx, entry[1]);
+    }
+
+    /**
+     * Get the documents issued about a batch or product, oldest first
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('AnchoredDocument[]')
+    public async GetAnchoredDocuments(ctx: Context, entityId: string): Promise<AnchoredDocument[]> {
+        if (!entityId) {
+            throw new Error('Entity ID is required');
+        }
+
+        const documents: AnchoredDocument[] = [];
+        for (const [, documentId] of await getIndexEntries(ctx, ENTITY_DOCUMENT_INDEX, [entityId])) {
+            const document = await readDocument<AnchoredDocument>(ctx, `document_${documentId}`);
+            if (document) {
+                documents.push(document);
+            }
+        }
+        return documents.sort((a, b) => a.issuedAt.localeCompare(b.issuedAt));
+    }
+
+    /**
+     * Acknowledge having received and reviewed a quality document of a batch or product, e.g. before accepting it
+     * documentHash is the hash of a test report of the batch (of the product's source batch for a product), of an
+     * attachment of the batch or product, or of a document anchored about it. The acknowledgment records the
+     * caller's organization, certificate fingerprint and subject, so it can be produced in a later dispute. Each
+     * identity acknowledges a document once
+     * Permission: No restriction
+     */
+    @Transaction()
+    @Returns('DocumentAcknowledgment')
+    public async AcknowledgeDocument(ctx: Context, entityId: string, documentHash: string, note: string): Promise<DocumentAcknowledgment> {
+        const entity = await this.resolveEntity(ctx, entityId);
+        const hash = (documentHash || '').trim().toLowerCase();
+        if (!hash) {
+            throw new Error('Document hash is required');
+        }
+        const source = await this.findEntityDocument(ctx, entityId, entity.entityType, hash);
+        if (!source) {
+            throw new Error(`No test report, attachment or anchored document of ${entityId} has hash ${hash}`);
+        }
+
+        const fingerprint = getCallerFingerprint(ctx);
+        const key = `docack_${entityId}_${hash}_${fingerprint}`;
+        if (await readDocument(ctx, key)) {
+            throw new Error(`Document ${hash} of ${entityId} has already been acknowledged by this identity`);
+        }
+
+        const acknowledgment: DocumentAcknowledgment = {
+            docType: 'documentAcknowledgment',
+            entityId,
+            documentHash: hash,
+            documentSource: source.documentSource,
+            documentRef: source.documentRef,
+            acknowledgedByMspId: ctx.clientIdentity.getMSPID(),
+            acknowledgedByFingerprint: fingerprint,
+            // getID() returns "x509::<subject DN>::<issuer DN>"
+            acknowledgedBySubject: ctx.clientIdentity.getID().split('::')[1] || '',
+            acknowledgedAt: getTxTimestamp(ctx)
+        };
+        if (note) {
+            acknowledgment.note = note;
+        }
+        inheritTenant(acknowledgment, entity.source);
+        await writeDocument(ctx, key, acknowledgment);
+        await putIndexEntry(ctx, DOCUMENT_ACKNOWLEDGMENT_INDEX, [entityId, hash, fingerprint]);
+        emitEvent(ctx, 'DocumentAcknowledged', acknowledgment);
+        return acknowledgment;
+    }
+
+    /**
+     * Get the acknowledgments of the documents of a batch or product, oldest first
+     * documentHash narrows the list to one document (empty for all)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('DocumentAcknowledgment[]')
+    public async GetDocumentAcknowledgments(ctx: Context, entityId: string, documentHash: string): Promise<DocumentAcknowledgment[]> {
+        if (!entityId) {
+            throw new Error('Entity ID is required');
+        }
+        const hash = (documentHash || '').trim().toLowerCase();
+
+        const acknowledgments: DocumentAcknowledgment[] = [];
+        for (const [, ackHash, fingerprint] of await getIndexEntries(ctx, DOCUMENT_ACKNOWLEDGMENT_INDEX, hash ? [entityId, hash] : [entityId])) {
+            const acknowledgment = await readDocument<DocumentAcknowledgment>(ctx, `docack_${entityId}_${ackHash}_${fingerprint}`);
+            if (acknowledgment) {
+                acknowledgments.push(acknowledgment);
+            }
+        }
+        return acknowledgments.sort((a, b) => a.acknowledgedAt.localeCompare(b.acknowledgedAt));
+    }
+
+    /**
+     * Find the quality document of a batch or product with a hash
+     */
+    private async findEntityDocument(ctx: Context, entityId: string, entityType: string, hash: string): Promise<{ documentSource: string; documentRef: string } | undefined> {
+        // Test reports belong to batches; a product's buyer reviews those of its source batch
+        const product = entityType === 'product' ? await readDocument<Product>(ctx, `product_${entityId}`) : null;
+        const batchId = product ? product.batchId : entityId;
+        for (const [, testId] of await getIndexEntries(ctx, BATCH_TEST_INDEX, [batchId])) {
+            const test = await readDocument<TestResult>(ctx, `test_${testId}`);
+            if (test && (test.reportHash || '').toLowerCase() === hash) {
+                return { documentSource: 'testReport', documentRef: testId };
+            }
+        }
+        for (const [, attachmentId] of await getIndexEntries(ctx, ENTITY_ATTACHMENT_INDEX, [entityId])) {
+            const attachment = await readDocument<Attachment>(ctx, `attachment_${attachmentId}`);
+            if (attachment && attachment.fileHash.toLowerCase() === hash) {
+                return { documentSource: 'attachment', documentRef: attachmentId };
+            }
+        }
+        for (const [, documentId] of await getIndexEntries(ctx, ENTITY_DOCUMENT_INDEX, [entityId])) {
+            const document = await readDocument<AnchoredDocument>(ctx, `document_${documentId}`);
+            if (document && document.documentHash === hash) {
+                return { documentSource: 'anchoredDocument', documentRef: documentId };
+            }
+        }
+        return undefined;
+    }
+
+    /**
+     * Find whether an entity ID names a batch or a product, and read it
+     */
+    private async resolveEntity(ctx: Context, entityId: string): Promise<{ entityType: string; source: RiceBatch | Product }> {
+        if (!entityId) {
+            throw new Error('Entity ID is required');
+        }
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${entityId}`);
+        if (batch) {
+            return { entityType: 'batch', source: batch };
+        }
+        const product = await readDocument<Product>(ctx, `product_${entityId}`);
+        if (product) {
+            return { entityType: 'product', source: product };
+        }
+        throw new Error(`No batch or product with ID ${entityId} exists`);
+    }
+}
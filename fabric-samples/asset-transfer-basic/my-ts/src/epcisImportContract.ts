@@ -0,0 +1,470 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { RiceBatch, Product, HistoryEvent, LogisticsUnit, Shipment, EpcisImportResult, OrganizationType } from './types';
+import {
+    readDocument, writeDocument, normalizeTimestamp, getTxTimestamp, getCallerFingerprint, emitEvent, documentHash,
+    isProcessedRequest, markRequestProcessed, StoredDocument, DISPOSED_STATE, checkOrgType, getCallerTenant, inheritTenant
+} from './utils';
+import { assertBulkSize } from './inputLimitContract';
+import { withBatchStatus } from './batchStatusContract';
+
+/**
+ * Prefixes of CBV business step identifiers (URN and Web URI forms); the short name is kept
+ */
+const BIZ_STEP_PREFIXES = ['urn:epcglobal:cbv:bizstep:', 'https://ref.gs1.org/cbv/BizStep-'];
+
+/**
+ * Business steps imported as shipments
+ */
+const SHIPPING_BIZ_STEPS = ['shipping', 'departing'];
+
+/**
+ * Prefix of the history step of imported events
+ * Imported records use their own step names, so they never advance a processing workflow or pass a quality gate
+ */
+const EPCIS_STEP_PREFIX = 'EPCIS:';
+
+/**
+ * Operation name under which imported event IDs are recorded, so a re-imported event is skipped
+ */
+const EPCIS_OPERATION = 'ImportEpcisEvent';
+
+/**
+ * Subset of an EPCIS 2.0 event (JSON/JSON-LD binding) read by the import
+ */
+interface EpcisEvent {
+    type?: string;
+    eventID?: string;
+    eventTime?: string;
+    action?: string;
+    bizStep?: string;
+    disposition?: string;
+    epcList?: string[];
+    quantityList?: { epcClass?: string }[];
+    parentID?: string;
+    childEPCs?: string[];
+    childQuantityList?: { epcClass?: string }[];
+    readPoint?: { id?: string };
+    bizLocation?: { id?: string };
+    sourceList?: { type?: string; source?: string }[];
+    destinationList?: { type?: string; destination?: string }[];
+}
+
+/**
+ * Documents read and changed by one import; Fabric does not return a transaction's own writes,
+ * so every event of a document works on these copies and they are written once at the end
+ */
+interface ImportState {
+    batches: Map<string, StoredDocument<RiceBatch> | null>;
+    products: Map<string, boolean>;
+    units: Map<string, StoredDocument<LogisticsUnit> | null>;
+    changedBatches: Set<string>;
+    changedUnits: Set<string>;
+    importedEventIds: Set<string>;
+}
+
+/**
+ * Ledger entity an EPC identifies
+ */
+interface ResolvedEpc {
+    kind: 'batch' | 'product' | 'unit' | 'unknown';
+    id: string;
+}
+
+@Info({ title: 'EpcisImportContract', description: 'Smart contract importing EPCIS 2.0 capture documents from partner systems' })
+export class EpcisImportContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "EpcisImportContract Method Permission Configuration": {
+                "ImportEpcisDocument": ["Farm", "Middleman/Tester"],
+                "ReadLogisticsUnit": ["All Organizations"],
+                "ReadShipment": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Import an EPCIS 2.0 capture document (JSON binding) from a partner system
+     * ObjectEvents become processing records in the history of the batches they identify; those with the
+     * shipping or departing business step are also recorded as shipments. AggregationEvents pack EPCs into,
+     * or unpack them from, the logistics unit identified by parentID. Events are imported in event time order;
+     * an event that cannot be imported is skipped with the reason and does not fail the document.
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    @Returns('EpcisImportResult')
+    public async ImportEpcisDocument(ctx: Context, documentJSON: string): Promise<EpcisImportResult> {
+        // Check permission: Only organizations that handle rice report supply chain events
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        let document: { type?: string; schemaVersion?: string; epcisBody?: { eventList?: EpcisEvent[] } };
+        try {
+            document = JSON.parse(documentJSON);
+        } catch (error) {
+            throw new Error(`EPCIS document format error: ${error}`);
+        }
+
+        if (document.type !== 'EPCISDocument') {
+            throw new Error('Expected an EPCIS capture document (type EPCISDocument)');
+        }
+        if (document.schemaVersion && !String(document.schemaVersion).startsWith('2.')) {
+            throw new Error(`Unsupported EPCIS schema version ${document.schemaVersion}, expected 2.x`);
+        }
+        const events = document.epcisBody && document.epcisBody.eventList;
+        if (!Array.isArray(events)) {
+            throw new Error('EPCIS document has no epcisBody.eventList');
+        }
+        await assertBulkSize(ctx, events.length, 'The EPCIS event list');
+
+        const result: EpcisImportResult = {
+            documentHash: documentHash(document),
+            eventCount: events.length,
+            processingRecords: 0,
+            shipments: 0,
+            logisticsUnits: 0,
+            batchIds: [],
+            skipped: []
+        };
+        const state: ImportState = {
+            batches: new Map(),
+            products: new Map(),
+            units: new Map(),
+            changedBatches: new Set(),
+            changedUnits: new Set(),
+            importedEventIds: new Set()
+        };
+        const now = getTxTimestamp(ctx);
+
+        // Import in event time order so batch histories stay chronological (unparseable times are skipped below)
+        const ordered = [...events].sort((a, b) => (Date.parse(a.eventTime || '') || 0) - (Date.parse(b.eventTime || '') || 0));
+
+        for (const event of ordered) {
+            const eventId = event.eventID || `ni:///sha-256;${documentHash(event)}`;
+            try {
+                if (state.importedEventIds.has(eventId) || await isProcessedRequest(ctx, eventId, EPCIS_OPERATION)) {
+                    throw new Error('Event has already been imported');
+                }
+
+                const eventTime = normalizeTimestamp(event.eventTime as string, 'eventTime');
+                if (eventTime > now) {
+                    throw new Error(`eventTime ${eventTime} is in the future`);
+                }
+
+                switch (event.type) {
+                    case 'ObjectEvent':
+                        await this.importObjectEvent(ctx, state, event, eventId, eventTime, result);
+                        break;
+                    case 'AggregationEvent':
+                        await this.importAggregationEvent(ctx, state, event, eventId, eventTime);
+                        break;
+                    default:
+                        throw new Error(`${event.type || 'Untyped'} events are not imported`);
+                }
+
+                state.importedEventIds.add(eventId);
+                await markRequestProcessed(ctx, eventId, EPCIS_OPERATION);
+            } catch (error) {
+                result.skipped.push({ eventId, reason: (error as Error).message });
+            }
+        }
+
+        for (const batchId of state.changedBatches) {
+            const batch = state.batches.get(batchId) as RiceBatch;
+            await writeDocument(ctx, `batch_${batchId}`, { ...batch, ...await withBatchStatus(ctx, batch, {}) });
+        }
+        for (const unitId of state.changedUnits) {
+            await writeDocument(ctx, `unit_${unitId}`, state.units.get(unitId) as LogisticsUnit);
+        }
+        result.logisticsUnits = state.changedUnits.size;
+        result.batchIds = [...state.changedBatches];
+
+        emitEvent(ctx, 'EpcisDocumentImported', result);
+        return result;
+    }
+
+    /**
+     * Read a logistics unit built from AggregationEvents
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('LogisticsUnit')
+    public async ReadLogisticsUnit(ctx: Context, unitId: string): Promise<LogisticsUnit> {
+        const unit = await readDocument<LogisticsUnit>(ctx, `unit_${unitId}`);
+        if (!unit) {
+            throw new Error(`The logistics unit ${unitId} does not exist`);
+        }
+        return unit;
+    }
+
+    /**
+     * Read a shipment imported from a shipping ObjectEvent
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Shipment')
+    public async ReadShipment(ctx: Context, shipmentId: string): Promise<Shipment> {
+        const shipment = await readDocument<Shipment>(ctx, `shipment_${shipmentId}`);
+        if (!shipment) {
+            throw new Error(`The shipment ${shipmentId} does not exist`);
+        }
+        return shipment;
+    }
+
+    /**
+     * Record an ObjectEvent in the history of the batches it identifies, directly or through logistics units
+     * All batches are checked before any is changed, so a skipped event leaves no partial record
+     */
+    private async importObjectEvent(
+        ctx: Context,
+        state: ImportState,
+        event: EpcisEvent,
+        eventId: string,
+        eventTime: string,
+        result: EpcisImportResult
+    ): Promise<void> {
+        if (event.action === 'DELETE') {
+            throw new Error('ObjectEvents with action DELETE are not imported');
+        }
+        const bizStep = this.normalizeBizStep(event.bizStep);
+        if (!bizStep) {
+            throw new Error('ObjectEvent has no bizStep');
+        }
+
+        const epcs = [...(event.epcList || []), ...(event.quantityList || []).map(quantity => quantity.epcClass || '')];
+        const batchIds = new Set<string>();
+        const productIds = new Set<string>();
+        const unitIds = new Set<string>();
+        for (const epc of epcs.filter(Boolean)) {
+            const resolved = await this.resolveEpc(ctx, state, epc);
+            if (resolved.kind === 'batch') {
+                batchIds.add(resolved.id);
+            } else if (resolved.kind === 'product') {
+                productIds.add(resolved.id);
+            } else if (resolved.kind === 'unit') {
+                const unit = state.units.get(resolved.id) as LogisticsUnit;
+                unitIds.add(resolved.id);
+                unit.batchIds.forEach(batchId => batchIds.add(batchId));
+                unit.productIds.forEach(productId => productIds.add(productId));
+            }
+        }
+        if (batchIds.size === 0 && productIds.size === 0) {
+            throw new Error('No EPC of the event identifies a batch, product or logistics unit on the ledger');
+        }
+
+        const isShipment = SHIPPING_BIZ_STEPS.includes(bizStep);
+        const destination = this.partyOrLocation(event.destinationList, 'destination');
+        const batches: StoredDocument<RiceBatch>[] = [];
+        for (const batchId of batchIds) {
+            const batch = await this.loadBatch(ctx, state, batchId) as StoredDocument<RiceBatch>;
+            if (batch.disposal || batch.currentState === DISPOSED_STATE) {
+                throw new Error(`Batch ${batchId} has been disposed of`);
+            }
+            if (isShipment && batch.quarantined) {
+                throw new Error(`Batch ${batchId} cannot be shipped while quarantined`);
+            }
+            const lastEvent = batch.history[batch.history.length - 1];
+            if (lastEvent && Date.parse(eventTime) < Date.parse(lastEvent.timestamp)) {
+                throw new Error(`eventTime ${eventTime} is earlier than the latest history event of batch ${batchId} (${lastEvent.timestamp})`);
+            }
+            batches.push(batch);
+        }
+
+        const bizLocation = (event.bizLocation && event.bizLocation.id) || '';
+        for (const batch of batches) {
+            const historyEvent: HistoryEvent = {
+                timestamp: eventTime,
+                from: batch.currentOwner,
+                to: isShipment && destination ? destination : batch.currentOwner,
+                step: `${EPCIS_STEP_PREFIX}${bizStep}`,
+                report: {
+                    reportId: eventId,
+                    reportType: 'EPCIS ObjectEvent',
+                    reportHash: documentHash(event),
+                    summary: [event.action, bizStep, event.disposition, bizLocation].filter(Boolean).join(' '),
+                    isVerified: false,
+                    verificationSource: 'EPCIS'
+                },
+                signerMspId: ctx.clientIdentity.getMSPID(),
+                signerFingerprint: getCallerFingerprint(ctx)
+            };
+            batch.history = [...batch.history, historyEvent];
+            state.changedBatches.add(batch.batchId);
+            result.processingRecords++;
+        }
+
+        if (isShipment) {
+            const shipment: Shipment = {
+                docType: 'shipment',
+                shipmentId: eventId,
+                eventTime,
+                batchIds: [...batchIds],
+                productIds: [...productIds],
+                unitIds: [...unitIds],
+                source: this.partyOrLocation(event.sourceList, 'source'),
+                destination,
+                bizLocation,
+                importedBy: ctx.clientIdentity.getMSPID()
+            };
+            inheritTenant(shipment, { tenantId: getCallerTenant(ctx) });
+            await writeDocument(ctx, `shipment_${eventId}`, shipment);
+            result.shipments++;
+        }
+    }
+
+    /**
+     * Pack EPCs into (ADD), unpack them from (DELETE) or observe (OBSERVE) the logistics unit of parentID
+     */
+    private async importAggregationEvent(
+        ctx: Context,
+        state: ImportState,
+        event: EpcisEvent,
+        eventId: string,
+        eventTime: string
+    ): Promise<void> {
+        const unitId = event.parentID;
+        if (!unitId) {
+            throw new Error('AggregationEvent has no parentID');
+        }
+        const children = [...(event.childEPCs || []), ...(event.childQuantityList || []).map(quantity => quantity.epcClass || '')]
+            .filter(Boolean);
+
+        let unit = await this.loadUnit(ctx, state, unitId);
+        if (!unit) {
+            if (event.action !== 'ADD') {
+                throw new Error(`The logistics unit ${unitId} does not exist`);
+            }
+            // Unit IDs (SSCCs) are unique across tenants
+            const existing = await ctx.stub.getState(`unit_${unitId}`);
+            if (existing && existing.length > 0) {
+                throw new Error(`The logistics unit ${unitId} is held by another tenant`);
+            }
+            unit = {
+                docType: 'logisticsUnit',
+                unitId,
+                childEpcs: [],
+                batchIds: [],
+                productIds: [],
+                packed: false,
+                bizLocation: '',
+                lastEventId: '',
+                createdAt: eventTime,
+                updatedAt: eventTime
+            } as StoredDocument<LogisticsUnit>;
+            inheritTenant(unit, { tenantId: getCallerTenant(ctx) });
+            state.units.set(unitId, unit);
+        }
+
+        if (event.action === 'ADD') {
+            unit.childEpcs = [...new Set([...unit.childEpcs, ...children])];
+        } else if (event.action === 'DELETE') {
+            // Without child EPCs, DELETE unpacks the whole unit
+            unit.childEpcs = children.length > 0 ? unit.childEpcs.filter(epc => !children.includes(epc)) : [];
+        } else if (event.action !== 'OBSERVE') {
+            throw new Error(`Unknown AggregationEvent action ${event.action}`);
+        }
+
+        const batchIds = new Set<string>();
+        const productIds = new Set<string>();
+        for (const epc of unit.childEpcs) {
+            const resolved = await this.resolveEpc(ctx, state, epc);
+            if (resolved.kind === 'batch') {
+                batchIds.add(resolved.id);
+            } else if (resolved.kind === 'product') {
+                productIds.add(resolved.id);
+            }
+        }
+
+        unit.batchIds = [...batchIds];
+        unit.productIds = [...productIds];
+        unit.packed = unit.childEpcs.length > 0;
+        unit.bizLocation = (event.bizLocation && event.bizLocation.id) || unit.bizLocation;
+        unit.lastEventId = eventId;
+        unit.updatedAt = eventTime;
+        state.changedUnits.add(unitId);
+    }
+
+    /**
+     * Resolve an EPC to a ledger entity
+     * LGTIN classes (urn:epc:class:lgtin:...LOT or GS1 Digital Link .../10/LOT) identify the batch LOT, SGTINs
+     * (urn:epc:id:sgtin:...SERIAL or .../21/SERIAL) the product SERIAL, and known parent IDs a logistics unit;
+     * any other EPC is looked up as a batch or product ID as is
+     */
+    private async resolveEpc(ctx: Context, state: ImportState, epc: string): Promise<ResolvedEpc> {
+        if (await this.loadUnit(ctx, state, epc)) {
+            return { kind: 'unit', id: epc };
+        }
+
+        const lot = /^urn:epc:class:lgtin:[^.]+\.[^.]+\.(.+)$/.exec(epc) || /\/10\/([^/?]+)/.exec(epc);
+        if (lot && await this.loadBatch(ctx, state, decodeURIComponent(lot[1]))) {
+            return { kind: 'batch', id: decodeURIComponent(lot[1]) };
+        }
+        const serial = /^urn:epc:id:sgtin:[^.]+\.[^.]+\.(.+)$/.exec(epc) || /\/21\/([^/?]+)/.exec(epc);
+        if (serial && await this.productExists(ctx, state, decodeURIComponent(serial[1]))) {
+            return { kind: 'product', id: decodeURIComponent(serial[1]) };
+        }
+
+        if (await this.loadBatch(ctx, state, epc)) {
+            return { kind: 'batch', id: epc };
+        }
+        if (await this.productExists(ctx, state, epc)) {
+            return { kind: 'product', id: epc };
+        }
+        return { kind: 'unknown', id: epc };
+    }
+
+    private async loadBatch(ctx: Context, state: ImportState, batchId: string): Promise<StoredDocument<RiceBatch> | null> {
+        if (!state.batches.has(batchId)) {
+            state.batches.set(batchId, await readDocument<RiceBatch>(ctx, `batch_${batchId}`));
+        }
+        return state.batches.get(batchId) as StoredDocument<RiceBatch> | null;
+    }
+
+    private async loadUnit(ctx: Context, state: ImportState, unitId: string): Promise<StoredDocument<LogisticsUnit> | null> {
+        if (!state.units.has(unitId)) {
+            state.units.set(unitId, await readDocument<LogisticsUnit>(ctx, `unit_${unitId}`));
+        }
+        return state.units.get(unitId) as StoredDocument<LogisticsUnit> | null;
+    }
+
+    private async productExists(ctx: Context, state: ImportState, productId: string): Promise<boolean> {
+        if (!state.products.has(productId)) {
+            state.products.set(productId, !!await readDocument<Product>(ctx, `product_${productId}`));
+        }
+        return state.products.get(productId) as boolean;
+    }
+
+    /**
+     * Short CBV name of a business step, e.g. urn:epcglobal:cbv:bizstep:shipping -> shipping
+     */
+    private normalizeBizStep(bizStep?: string): string {
+        let name = bizStep || '';
+        for (const prefix of BIZ_STEP_PREFIXES) {
+            if (name.startsWith(prefix)) {
+                name = name.slice(prefix.length);
+            }
+        }
+        return name;
+    }
+
+    /**
+     * Owning party of a source/destination list, or the location when no party is given
+     */
+    private partyOrLocation(list: { type?: string; [key: string]: string | undefined }[] | undefined, field: string): string {
+        const entries = list || [];
+        const party = entries.find(entry => (entry.type || '').endsWith('owning_party'));
+        const location = entries.find(entry => (entry.type || '').endsWith('location'));
+        return ((party || location || {}) as Record<string, string | undefined>)[field] || '';
+    }
+}
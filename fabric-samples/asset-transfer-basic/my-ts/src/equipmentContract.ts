@@ -0,0 +1,232 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { Equipment, EquipmentServiceRecord, EquipmentUsage, OrganizationType } from './types';
+import {
+    readDocument, writeDocument, patchDocument, normalizeTimestamp, normalizeEndTimestamp, assertNotBefore, getTxTimestamp, emitEvent,
+    putIndexEntry, getIndexEntries, checkOrgType
+} from './utils';
+
+/**
+ * Composite key index of processing steps by the equipment they were processed on, in time order
+ */
+export const EQUIPMENT_USAGE_INDEX = 'equipment~processedAt~batchId~step';
+
+/**
+ * Kinds of processing equipment that can be registered
+ */
+const EQUIPMENT_TYPES = ['dryer', 'mill', 'colorSorter', 'packagingLine'];
+
+/**
+ * Check that a piece of equipment can run a step at processedAt: it must be operated by the caller's organization
+ * and be within its calibration
+ */
+export async function assertEquipmentUsable(ctx: Context, equipmentId: string, processedAt: string): Promise<void> {
+    const equipment = await readDocument<Equipment>(ctx, `equipment_${equipmentId}`);
+    if (!equipment) {
+        throw new Error(`Equipment ${equipmentId} is not registered`);
+    }
+    const mspId = ctx.clientIdentity.getMSPID();
+    if (equipment.ownerMspId !== mspId) {
+        throw new Error(`Permission denied: Equipment ${equipmentId} is operated by ${equipment.ownerMspId}, not ${mspId}`);
+    }
+    if (processedAt > equipment.nextCalibrationDue) {
+        throw new Error(`Equipment ${equipmentId} is overdue for calibration (due ${equipment.nextCalibrationDue})`);
+    }
+}
+
+/**
+ * Record that a processing step of a batch ran on a piece of equipment
+ * The equipment must be operated by the caller's organization and be within its calibration
+ */
+export async function recordEquipmentUsage(ctx: Context, equipmentId: string, batchId: string, step: string, processedAt: string): Promise<void> {
+    await assertEquipmentUsable(ctx, equipmentId, processedAt);
+    await putIndexEntry(ctx, EQUIPMENT_USAGE_INDEX, [equipmentId, processedAt, batchId, step]);
+}
+
+@Info({ title: 'EquipmentContract', description: 'Smart contract registering processing equipment and its calibration and maintenance' })
+export class EquipmentContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "EquipmentContract Method Permission Configuration": {
+                "RegisterEquipment": ["Farm", "Middleman/Tester"],
+                "RecordCalibration": ["Operating organization"],
+                "RecordMaintenance": ["Operating organization"],
+                "ReadEquipment": ["All Organizations"],
+                "GetEquipmentUsage": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Register a piece of processing equipment operated by the caller's organization
+     * equipmentType is dryer, mill, colorSorter or packagingLine. Calibration dates are dates or RFC3339 times;
+     * a bare nextCalibrationDue date includes the whole day
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async RegisterEquipment(
+        ctx: Context,
+        equipmentId: string,
+        equipmentType: string,
+        name: string,
+        location: string,
+        lastCalibrationDate: string,
+        nextCalibrationDue: string
+    ): Promise<void> {
+        // Check permission: Farms run dryers, processors run mills, sorters and packaging lines
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!equipmentId || !name || !location) {
+            throw new Error('Equipment ID, name and location are required');
+        }
+        if (!EQUIPMENT_TYPES.includes(equipmentType)) {
+            throw new Error(`Invalid equipment type: ${equipmentType}. Allowed values: ${EQUIPMENT_TYPES.join(', ')}`);
+        }
+        if (await readDocument<Equipment>(ctx, `equipment_${equipmentId}`)) {
+            throw new Error(`Equipment ${equipmentId} is already registered`);
+        }
+        const calibratedAt = normalizeTimestamp(lastCalibrationDate, 'lastCalibrationDate');
+        const calibrationDue = normalizeEndTimestamp(nextCalibrationDue, 'nextCalibrationDue');
+        assertNotBefore(calibrationDue, 'nextCalibrationDue', calibratedAt, 'lastCalibrationDate');
+        const now = getTxTimestamp(ctx);
+        if (calibratedAt > now) {
+            throw new Error(`lastCalibrationDate cannot be in the future (${calibratedAt})`);
+        }
+
+        const equipment: Equipment = {
+            docType: 'equipment',
+            equipmentId,
+            equipmentType,
+            name,
+            location,
+            ownerMspId: ctx.clientIdentity.getMSPID(),
+            lastCalibrationDate: calibratedAt,
+            nextCalibrationDue: calibrationDue,
+            serviceLog: [],
+            registeredAt: now
+        };
+        await writeDocument(ctx, `equipment_${equipmentId}`, equipment);
+        emitEvent(ctx, 'EquipmentRegistered', equipment);
+    }
+
+    /**
+     * Record a calibration of a piece of equipment and when the next one is due
+     * documentHash is the SHA-256 (hex) of the calibration certificate, or empty
+     * Permission: Only the organization operating the equipment can call
+     */
+    @Transaction()
+    public async RecordCalibration(
+        ctx: Context,
+        equipmentId: string,
+        calibratedAt: string,
+        nextCalibrationDue: string,
+        description: string,
+        documentHash: string
+    ): Promise<void> {
+        const equipment = await this.readOwnEquipment(ctx, equipmentId);
+        const performedAt = normalizeTimestamp(calibratedAt, 'calibratedAt');
+        const calibrationDue = normalizeEndTimestamp(nextCalibrationDue, 'nextCalibrationDue');
+        assertNotBefore(calibrationDue, 'nextCalibrationDue', performedAt, 'calibratedAt');
+        assertNotBefore(performedAt, 'calibratedAt', equipment.lastCalibrationDate, 'the previous calibration');
+
+        const record = this.createServiceRecord(ctx, 'calibration', performedAt, description || 'Calibration', documentHash);
+        const updated = await patchDocument<Equipment>(ctx, `equipment_${equipmentId}`, {
+            lastCalibrationDate: performedAt,
+            nextCalibrationDue: calibrationDue,
+            serviceLog: [...equipment.serviceLog, record]
+        });
+        emitEvent(ctx, 'EquipmentServiced', updated);
+    }
+
+    /**
+     * Record maintenance of a piece of equipment (e.g. roller replacement)
+     * documentHash is the SHA-256 (hex) of the service report, or empty
+     * Permission: Only the organization operating the equipment can call
+     */
+    @Transaction()
+    public async RecordMaintenance(ctx: Context, equipmentId: string, maintainedAt: string, description: string, documentHash: string): Promise<void> {
+        const equipment = await this.readOwnEquipment(ctx, equipmentId);
+        if (!description) {
+            throw new Error('Maintenance description is required');
+        }
+        const performedAt = normalizeTimestamp(maintainedAt, 'maintainedAt');
+
+        const record = this.createServiceRecord(ctx, 'maintenance', performedAt, description, documentHash);
+        const updated = await patchDocument<Equipment>(ctx, `equipment_${equipmentId}`, {
+            lastMaintenanceDate: !equipment.lastMaintenanceDate || performedAt > equipment.lastMaintenanceDate ? performedAt : equipment.lastMaintenanceDate,
+            serviceLog: [...equipment.serviceLog, record]
+        });
+        emitEvent(ctx, 'EquipmentServiced', updated);
+    }
+
+    /**
+     * Read a piece of equipment
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Equipment')
+    public async ReadEquipment(ctx: Context, equipmentId: string): Promise<Equipment> {
+        const equipment = await readDocument<Equipment>(ctx, `equipment_${equipmentId}`);
+        if (!equipment) {
+            throw new Error(`Equipment ${equipmentId} is not registered`);
+        }
+        return equipment;
+    }
+
+    /**
+     * Get the processing steps run on a piece of equipment in a time range, in time order, e.g. every batch
+     * processed on a mill while it was faulty. from and to are optional; a bare end date includes the whole day
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('EquipmentUsage[]')
+    public async GetEquipmentUsage(ctx: Context, equipmentId: string, from: string, to: string): Promise<EquipmentUsage[]> {
+        await this.ReadEquipment(ctx, equipmentId);
+        const rangeStart = from ? normalizeTimestamp(from, 'from') : '';
+        const rangeEnd = to ? normalizeEndTimestamp(to, 'to') : '';
+
+        return (await getIndexEntries(ctx, EQUIPMENT_USAGE_INDEX, [equipmentId]))
+            .filter(([, processedAt]) => (!rangeStart || processedAt >= rangeStart) && (!rangeEnd || processedAt <= rangeEnd))
+            .map(([, processedAt, batchId, step]) => ({ equipmentId, batchId, step, processedAt }));
+    }
+
+    /**
+     * Read a piece of equipment operated by the caller's organization
+     */
+    private async readOwnEquipment(ctx: Context, equipmentId: string): Promise<Equipment> {
+        const equipment = await this.ReadEquipment(ctx, equipmentId);
+        const mspId = ctx.clientIdentity.getMSPID();
+        if (equipment.ownerMspId !== mspId) {
+            throw new Error(`Permission denied: Equipment ${equipmentId} is operated by ${equipment.ownerMspId}`);
+        }
+        return equipment;
+    }
+
+    private createServiceRecord(ctx: Context, type: string, performedAt: string, description: string, documentHash: string): EquipmentServiceRecord {
+        const now = getTxTimestamp(ctx);
+        if (performedAt > now) {
+            throw new Error(`The ${type} cannot be recorded before it is performed (${performedAt})`);
+        }
+        const record: EquipmentServiceRecord = { type, performedAt, description, recordedAt: now };
+        if (documentHash) {
+            const hash = documentHash.toLowerCase();
+            if (!/^[0-9a-f]{64}$/.test(hash)) {
+                throw new Error('Document hash must be a SHA-256 digest (64 hex characters)');
+            }
+            record.documentHash = hash;
+        }
+        return record;
+    }
+}
@@ -0,0 +1,243 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { AmbientConditions, Facility, FacilityActivity, FacilityShift, OrganizationType, ReportDetail } from './types';
+import { readDocument, writeDocument, normalizeTimestamp, normalizeEndTimestamp, getTxTimestamp, emitEvent, putIndexEntry, getIndexEntries, checkOrgType } from './utils';
+
+/**
+ * Composite key index of processing steps by the facility they took place at, in time order
+ */
+export const FACILITY_ACTIVITY_INDEX = 'facility~processedAt~batchId~step~line~shift';
+
+/**
+ * Kinds of facilities that can be registered
+ */
+const FACILITY_TYPES = ['dryingYard', 'mill', 'warehouse', 'packingPlant'];
+
+/**
+ * Time of day as HH:mm (24-hour)
+ */
+const TIME_OF_DAY_PATTERN = /^([01]\d|2[0-3]):[0-5]\d$/;
+
+/**
+ * Shift of a facility covering a time: HH:mm of the UTC timestamp within [start, end), wrapping past midnight
+ */
+function shiftAt(shifts: FacilityShift[], processedAt: string): FacilityShift | undefined {
+    const time = processedAt.slice(11, 16);
+    return shifts.find(shift => shift.start <= shift.end
+        ? time >= shift.start && time < shift.end
+        : time >= shift.start || time < shift.end);
+}
+
+/**
+ * Check the facility context of a step report: the facility must be operated by the caller's organization and
+ * list the line and shift, and ambient conditions must be plausible. A shift is derived from processedAt when
+ * the report names none and the facility defines shifts. Returns the report with the derived shift
+ */
+export async function assertFacilityContext(ctx: Context, report: ReportDetail, processedAt: string): Promise<ReportDetail> {
+    if (report.ambient !== undefined) {
+        const ambient: AmbientConditions = report.ambient || {};
+        const { temperatureC, humidityPercent } = ambient;
+        if (temperatureC !== undefined && (typeof temperatureC !== 'number' || temperatureC < -50 || temperatureC > 80)) {
+            throw new Error('Ambient temperatureC must be a number from -50 to 80');
+        }
+        if (humidityPercent !== undefined && (typeof humidityPercent !== 'number' || humidityPercent < 0 || humidityPercent > 100)) {
+            throw new Error('Ambient humidityPercent must be a number from 0 to 100');
+        }
+    }
+    if (!report.facilityId) {
+        if (report.line || report.shift || report.ambient) {
+            throw new Error('A line, shift or ambient conditions can only be recorded with a facilityId');
+        }
+        return report;
+    }
+
+    const facility = await readDocument<Facility>(ctx, `facility_${report.facilityId}`);
+    if (!facility) {
+        throw new Error(`Facility ${report.facilityId} is not registered`);
+    }
+    const mspId = ctx.clientIdentity.getMSPID();
+    if (facility.ownerMspId !== mspId) {
+        throw new Error(`Permission denied: Facility ${report.facilityId} is operated by ${facility.ownerMspId}, not ${mspId}`);
+    }
+    if (report.line && facility.lines.length > 0 && !facility.lines.includes(report.line)) {
+        throw new Error(`Facility ${report.facilityId} has no line ${report.line}; lines: ${facility.lines.join(', ')}`);
+    }
+    if (report.shift) {
+        if (facility.shifts.length > 0 && !facility.shifts.some(shift => shift.name === report.shift)) {
+            throw new Error(`Facility ${report.facilityId} has no shift ${report.shift}; shifts: ${facility.shifts.map(shift => shift.name).join(', ')}`);
+        }
+        return report;
+    }
+    const shift = shiftAt(facility.shifts, processedAt);
+    return shift ? { ...report, shift: shift.name } : report;
+}
+
+/**
+ * Record that a processing step of a batch took place at a facility
+ */
+export async function recordFacilityActivity(ctx: Context, report: ReportDetail, batchId: string, step: string, processedAt: string): Promise<void> {
+    if (report.facilityId) {
+        await putIndexEntry(ctx, FACILITY_ACTIVITY_INDEX, [report.facilityId, processedAt, batchId, step, report.line || '-', report.shift || '-']);
+    }
+}
+
+@Info({ title: 'FacilityContract', description: 'Smart contract registering the facilities, lines and shifts processing steps take place at' })
+export class FacilityContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "FacilityContract Method Permission Configuration": {
+                "RegisterFacility": ["Farm", "Middleman/Tester (operating organization for updates)"],
+                "ReadFacility": ["All Organizations"],
+                "GetFacilityActivity": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Register a facility operated by the caller's organization, or update one it operates
+     * facilityJSON: { name, facilityType, location, lines?, shifts? }. facilityType is dryingYard, mill, warehouse or
+     * packingPlant; shifts are [{ name, start, end }] with HH:mm UTC times and must not overlap. Steps already
+     * recorded keep the line and shift they named
+     * Permission: Farm and middleman/tester can call; only the operating organization can update a facility
+     */
+    @Transaction()
+    public async RegisterFacility(ctx: Context, facilityId: string, facilityJSON: string): Promise<void> {
+        // Check permission: Farms run drying yards, processors run mills, warehouses and packing plants
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!facilityId) {
+            throw new Error('Facility ID is required');
+        }
+        let input: { name?: string; facilityType?: string; location?: string; lines?: unknown; shifts?: unknown };
+        try {
+            input = JSON.parse(facilityJSON);
+        } catch (error) {
+            throw new Error(`Facility format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || !input.name || !input.location) {
+            throw new Error('Facility name and location are required');
+        }
+        if (!FACILITY_TYPES.includes(input.facilityType || '')) {
+            throw new Error(`Invalid facility type: ${input.facilityType}. Allowed values: ${FACILITY_TYPES.join(', ')}`);
+        }
+
+        const mspId = ctx.clientIdentity.getMSPID();
+        const existing = await readDocument<Facility>(ctx, `facility_${facilityId}`);
+        if (existing && existing.ownerMspId !== mspId) {
+            throw new Error(`Permission denied: Facility ${facilityId} is operated by ${existing.ownerMspId}`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const facility: Facility = {
+            docType: 'facility',
+            facilityId,
+            name: input.name,
+            facilityType: input.facilityType as string,
+            location: input.location,
+            ownerMspId: mspId,
+            lines: this.parseLines(input.lines),
+            shifts: this.parseShifts(input.shifts),
+            registeredAt: existing ? existing.registeredAt : now,
+            lastUpdated: now
+        };
+        await writeDocument(ctx, `facility_${facilityId}`, facility);
+        emitEvent(ctx, existing ? 'FacilityUpdated' : 'FacilityRegistered', facility);
+    }
+
+    /**
+     * Read a facility
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Facility')
+    public async ReadFacility(ctx: Context, facilityId: string): Promise<Facility> {
+        const facility = await readDocument<Facility>(ctx, `facility_${facilityId}`);
+        if (!facility) {
+            throw new Error(`Facility ${facilityId} is not registered`);
+        }
+        return facility;
+    }
+
+    /**
+     * Get the processing steps recorded at a facility in a time range, in time order, e.g. every batch processed
+     * on the night shift of a line while a problem went unnoticed. from and to are optional (a bare end date
+     * includes the whole day); line and shift are optional filters
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('FacilityActivity[]')
+    public async GetFacilityActivity(ctx: Context, facilityId: string, from: string, to: string, line: string, shift: string): Promise<FacilityActivity[]> {
+        await this.ReadFacility(ctx, facilityId);
+        const rangeStart = from ? normalizeTimestamp(from, 'from') : '';
+        const rangeEnd = to ? normalizeEndTimestamp(to, 'to') : '';
+
+        return (await getIndexEntries(ctx, FACILITY_ACTIVITY_INDEX, [facilityId]))
+            .map(([, processedAt, batchId, step, recordedLine, recordedShift]) => ({
+                facilityId,
+                batchId,
+                step,
+                processedAt,
+                line: recordedLine === '-' ? '' : recordedLine,
+                shift: recordedShift === '-' ? '' : recordedShift
+            }))
+            .filter(activity => (!rangeStart || activity.processedAt >= rangeStart) && (!rangeEnd || activity.processedAt <= rangeEnd) &&
+                (!line || activity.line === line) && (!shift || activity.shift === shift));
+    }
+
+    /**
+     * Validate the optional list of line names of a facility
+     */
+    private parseLines(value: unknown): string[] {
+        if (value === undefined || value === null) {
+            return [];
+        }
+        if (!Array.isArray(value) || value.some(item => typeof item !== 'string' || !item.trim())) {
+            throw new Error('lines must be a list of non-empty strings');
+        }
+        return [...new Set(value.map(item => item.trim()))];
+    }
+
+    /**
+     * Validate the optional shifts of a facility: unique names, HH:mm times and no overlaps
+     */
+    private parseShifts(value: unknown): FacilityShift[] {
+        if (value === undefined || value === null) {
+            return [];
+        }
+        if (!Array.isArray(value)) {
+            throw new Error('shifts must be a list of { name, start, end }');
+        }
+        const shifts: FacilityShift[] = [];
+        for (const item of value) {
+            const name = item && typeof item.name === 'string' ? item.name.trim() : '';
+            if (!name) {
+                throw new Error('Every shift requires a name');
+            }
+            if (!TIME_OF_DAY_PATTERN.test(item.start) || !TIME_OF_DAY_PATTERN.test(item.end) || item.start === item.end) {
+                throw new Error(`Shift ${name} requires different start and end times as HH:mm`);
+            }
+            if (shifts.some(shift => shift.name === name)) {
+                throw new Error(`Shift ${name} is defined more than once`);
+            }
+            const shift = { name, start: item.start, end: item.end };
+            const overlapping = shifts.find(other => shiftAt([other], `0000-00-00T${shift.start}`) || shiftAt([shift], `0000-00-00T${other.start}`));
+            if (overlapping) {
+                throw new Error(`Shift ${name} overlaps shift ${overlapping.name}`);
+            }
+            shifts.push(shift);
+        }
+        return shifts;
+    }
+}
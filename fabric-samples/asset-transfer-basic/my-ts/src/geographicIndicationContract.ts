@@ -0,0 +1,194 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { GIComplianceCheck, GIRule, OrganizationType, RiceBatch } from './types';
+import { readDocument, writeDocument, patchDocument, getTxTimestamp, emitEvent, checkOrgAdmin, DISPOSED_STATE, checkOrgType } from './utils';
+
+@Info({ title: 'GeographicIndicationContract', description: 'Smart contract checking batches against geographic indication (GI) rules of protected origins' })
+export class GeographicIndicationContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "GeographicIndicationContract Method Permission Configuration": {
+                "DefineGIRule": ["Organization Administrators"],
+                "ReadGIRule": ["All Organizations"],
+                "GetAllGIRules": ["All Organizations"],
+                "CheckGICompliance": ["Farm", "Middleman/Tester"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Create or update the rule of a protected origin
+     * ruleJSON: { name, allowedRegions, allowedPlots, allowedVarieties }; allowedRegions is required, empty plot
+     * or variety lists allow any. Updating a rule bumps its version; batches checked against an older version
+     * keep their result until checked again
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async DefineGIRule(ctx: Context, giId: string, ruleJSON: string): Promise<void> {
+        checkOrgAdmin(ctx);
+
+        if (!giId) {
+            throw new Error('GI ID is required');
+        }
+
+        let definition: { name?: string; allowedRegions?: unknown; allowedPlots?: unknown; allowedVarieties?: unknown };
+        try {
+            definition = JSON.parse(ruleJSON);
+        } catch (error) {
+            throw new Error(`GI rule format error: ${error}`);
+        }
+        if (!definition || typeof definition !== 'object' || !definition.name) {
+            throw new Error('GI rule requires a name');
+        }
+        const allowedRegions = this.parseList(definition.allowedRegions, 'allowedRegions');
+        if (allowedRegions.length === 0) {
+            throw new Error('GI rule requires at least one allowed region');
+        }
+
+        const now = getTxTimestamp(ctx);
+        const existing = await readDocument<GIRule>(ctx, `gi_${giId}`);
+
+        const rule: GIRule = {
+            docType: 'giRule',
+            giId,
+            name: definition.name,
+            allowedRegions,
+            allowedPlots: this.parseList(definition.allowedPlots, 'allowedPlots'),
+            allowedVarieties: this.parseList(definition.allowedVarieties, 'allowedVarieties'),
+            version: existing ? existing.version + 1 : 1,
+            definedBy: ctx.clientIdentity.getMSPID(),
+            createdTimestamp: existing ? existing.createdTimestamp : now,
+            lastUpdated: now
+        };
+
+        await writeDocument(ctx, `gi_${giId}`, rule);
+    }
+
+    /**
+     * Read a GI rule
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('GIRule')
+    public async ReadGIRule(ctx: Context, giId: string): Promise<GIRule> {
+        const rule = await readDocument<GIRule>(ctx, `gi_${giId}`);
+        if (!rule) {
+            throw new Error(`GI rule ${giId} does not exist`);
+        }
+        return rule;
+    }
+
+    /**
+     * Get all GI rules
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('GIRule[]')
+    public async GetAllGIRules(ctx: Context): Promise<GIRule[]> {
+        const resultsIterator = await ctx.stub.getStateByRange('gi_', 'gi_\uffff');
+        const rules: GIRule[] = [];
+
+        let result = await resultsIterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                try {
+                    const rule: GIRule = JSON.parse(result.value.value.toString());
+                    if (rule.giId) {
+                        rules.push(rule);
+                    }
+                } catch (error) {
+                    // Skip invalid data
+                    console.warn(`Skipping invalid GI rule data: ${error}`);
+                }
+            }
+            result = await resultsIterator.next();
+        }
+
+        await resultsIterator.close();
+        return rules;
+    }
+
+    /**
+     * Check a batch against a GI rule and record the result on the batch
+     * plotId is the plot the batch was harvested from; it is required when the rule restricts plots.
+     * The batch carries the GI claim in its trace output only while its latest check passed.
+     * A failed check emits GIComplianceViolation with the violations; a passed check emits GIComplianceChecked
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    @Returns('GIComplianceCheck')
+    public async CheckGICompliance(ctx: Context, batchId: string, giId: string, plotId: string): Promise<GIComplianceCheck> {
+        // Check permission: Producers claim the GI, processors and testers verify it
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        if (batch.disposal || batch.currentState === DISPOSED_STATE) {
+            throw new Error(`The rice batch ${batchId} has been disposed of and cannot be changed`);
+        }
+        const rule = await this.ReadGIRule(ctx, giId);
+
+        const violations: string[] = [];
+        if (!this.listIncludes(rule.allowedRegions, batch.origin)) {
+            violations.push(`Origin ${batch.origin || '(none)'} is outside the protected regions ${rule.allowedRegions.join(', ')}`);
+        }
+        if (rule.allowedPlots.length > 0 && !plotId) {
+            violations.push(`A registered plot is required for ${rule.name}`);
+        } else if (rule.allowedPlots.length > 0 && !rule.allowedPlots.includes(plotId)) {
+            violations.push(`Plot ${plotId} is not registered for ${rule.name}`);
+        }
+        if (rule.allowedVarieties.length > 0 && !this.listIncludes(rule.allowedVarieties, batch.variety)) {
+            violations.push(`Variety ${batch.variety || '(none)'} is not permitted for ${rule.name}; allowed: ${rule.allowedVarieties.join(', ')}`);
+        }
+
+        const check: GIComplianceCheck = {
+            giId,
+            giName: rule.name,
+            ruleVersion: rule.version,
+            plotId: plotId || '',
+            passed: violations.length === 0,
+            violations,
+            checkedAt: getTxTimestamp(ctx),
+            checkedBy: ctx.clientIdentity.getMSPID()
+        };
+
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, { giCompliance: check });
+        emitEvent(ctx, check.passed ? 'GIComplianceChecked' : 'GIComplianceViolation', updated);
+        return check;
+    }
+
+    /**
+     * Validate an optional list of non-empty strings from a rule definition
+     */
+    private parseList(value: unknown, fieldName: string): string[] {
+        if (value === undefined || value === null) {
+            return [];
+        }
+        if (!Array.isArray(value) || value.some(item => typeof item !== 'string' || !item.trim())) {
+            throw new Error(`${fieldName} must be a list of non-empty strings`);
+        }
+        return [...new Set(value.map(item => item.trim()))];
+    }
+
+    /**
+     * Case-insensitive membership, so "wuchang" matches a rule listing "Wuchang"
+     */
+    private listIncludes(list: string[], value: string): boolean {
+        const normalized = (value || '').trim().toLowerCase();
+        return list.some(item => item.toLowerCase() === normalized);
+    }
+}
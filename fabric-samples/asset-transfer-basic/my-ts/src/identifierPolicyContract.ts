@@ -0,0 +1,255 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { IdPolicy, IdScheme, IdSequence, OrganizationType } from './types';
+import { readDocument, writeDocument, getTxTimestamp, checkOrgAdmin, checkOrgType } from './utils';
+
+/**
+ * Ledger key of the configured ID policy
+ */
+const ID_POLICY_KEY = 'limits_idPolicy';
+
+/**
+ * Key prefix of the per-organization ID sequences
+ */
+export const ID_SEQUENCE_PREFIX = 'idseq_';
+
+/**
+ * Kinds of entities whose IDs the policy governs; the entity is also the prefix of their ledger keys
+ */
+type IdEntity = 'batch' | 'product';
+
+/**
+ * Fewest and most digits of the sequence part of an ID
+ */
+const MIN_SEQUENCE_DIGITS = 3;
+const MAX_SEQUENCE_DIGITS = 12;
+
+/**
+ * Longest GS1 lot (AI 10) or serial number (AI 21)
+ */
+const GS1_MAX_LENGTH = 20;
+
+/**
+ * Characters of a prefix: letters, digits and hyphens, a subset of GS1 character set 82 safe in URLs and keys
+ */
+const PREFIX_PATTERN = /^[A-Za-z0-9][A-Za-z0-9-]{0,11}$/;
+
+/**
+ * GS1 mod-10 check digit of a digit string: weights 3 and 1 alternating from the rightmost digit
+ */
+function gs1CheckDigit(digits: string): number {
+    let sum = 0;
+    for (let position = 0; position < digits.length; position++) {
+        const digit = Number(digits[digits.length - 1 - position]);
+        sum += position % 2 === 0 ? digit * 3 : digit;
+    }
+    return (10 - sum % 10) % 10;
+}
+
+/**
+ * Check and normalize the scheme of one kind of entity
+ */
+function validateScheme(entity: IdEntity, input: any): IdScheme {
+    if (!input || typeof input !== 'object' || Array.isArray(input)) {
+        throw new Error(`The ${entity} ID scheme must be an object`);
+    }
+    if (!input.prefixes || typeof input.prefixes !== 'object' || Array.isArray(input.prefixes) || Object.keys(input.prefixes).length === 0) {
+        throw new Error(`The ${entity} ID scheme must map at least one MSP ID to a prefix`);
+    }
+    if (!Number.isInteger(input.sequenceDigits) || input.sequenceDigits < MIN_SEQUENCE_DIGITS || input.sequenceDigits > MAX_SEQUENCE_DIGITS) {
+        throw new Error(`The ${entity} ID sequenceDigits must be a whole number from ${MIN_SEQUENCE_DIGITS} to ${MAX_SEQUENCE_DIGITS}`);
+    }
+    const checkDigit = input.checkDigit === undefined ? 'none' : input.checkDigit;
+    if (!['none', 'gs1'].includes(checkDigit)) {
+        throw new Error(`Unknown ${entity} ID checkDigit ${checkDigit}: expected none or gs1`);
+    }
+    const gs1Compatible = input.gs1Compatible === true;
+
+    const owners = new Map<string, string>();
+    for (const [mspId, prefix] of Object.entries(input.prefixes)) {
+        if (typeof prefix !== 'string' || !PREFIX_PATTERN.test(prefix)) {
+            throw new Error(`The ${entity} ID prefix of ${mspId} must be 1 to 12 letters, digits or hyphens`);
+        }
+        if (checkDigit === 'gs1' && !/^\d+$/.test(prefix)) {
+            throw new Error(`The ${entity} ID prefix of ${mspId} must be digits for a gs1 check digit`);
+        }
+        // IDs of different organizations are unique because their prefixes differ
+        if (owners.has(prefix)) {
+            throw new Error(`${mspId} and ${owners.get(prefix)} cannot share the ${entity} ID prefix ${prefix}`);
+        }
+        owners.set(prefix, mspId);
+        const length = prefix.length + input.sequenceDigits + (checkDigit === 'gs1' ? 1 : 0);
+        if (gs1Compatible && length > GS1_MAX_LENGTH) {
+            throw new Error(`The ${entity} IDs of ${mspId} would have ${length} characters, above the GS1 limit of ${GS1_MAX_LENGTH}`);
+        }
+    }
+    return { prefixes: { ...input.prefixes }, sequenceDigits: input.sequenceDigits, checkDigit, gs1Compatible };
+}
+
+/**
+ * Get the ID policy in force (an empty policy, version 0, until configured)
+ */
+async function readIdPolicy(ctx: Context): Promise<IdPolicy> {
+    return (await readDocument<IdPolicy>(ctx, ID_POLICY_KEY)) || { docType: 'idPolicy', version: 0 };
+}
+
+/**
+ * Check that an ID the caller's organization creates follows the policy of its kind of entity
+ * IDs are not restricted until the policy defines a scheme for the entity
+ */
+export async function assertIdConforms(ctx: Context, entity: IdEntity, id: string): Promise<void> {
+    const scheme = (await readIdPolicy(ctx))[entity];
+    if (!scheme) {
+        return;
+    }
+    const mspId = ctx.clientIdentity.getMSPID();
+    const prefix = scheme.prefixes[mspId];
+    if (!prefix) {
+        throw new Error(`The ID policy assigns no ${entity} ID prefix to ${mspId}`);
+    }
+    const checkDigits = scheme.checkDigit === 'gs1' ? 1 : 0;
+    const expected = `${prefix} followed by ${scheme.sequenceDigits + checkDigits} digits`;
+    if (!id.startsWith(prefix) || !new RegExp(`^\\d{${scheme.sequenceDigits + checkDigits}}$`).test(id.slice(prefix.length))) {
+        throw new Error(`The ${entity} ID ${id} does not follow the ID policy: expected ${expected}`);
+    }
+    if (checkDigits && gs1CheckDigit(id.slice(0, -1)) !== Number(id.slice(-1))) {
+        throw new Error(`The ${entity} ID ${id} has an invalid check digit`);
+    }
+}
+
+@Info({ title: 'IdentifierPolicyContract', description: 'Smart contract defining and generating the IDs of batches and products' })
+export class IdentifierPolicyContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "IdentifierPolicyContract Method Permission Configuration": {
+                "DefineIdPolicy": ["Organization Administrators"],
+                "GetIdPolicy": ["All Organizations"],
+                "GenerateBatchID": ["Farm"],
+                "GenerateProductID": ["Middleman/Tester"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Configure how batch and product IDs are formed
+     * policyJSON: { batch?: scheme, product?: scheme }, where a scheme is { prefixes: { <MSP ID>: prefix },
+     * sequenceDigits, checkDigit?: 'none' | 'gs1', gs1Compatible?: boolean }. IDs are the prefix of the creating
+     * organization followed by a zero-padded sequence and, with a gs1 check digit, the GS1 mod-10 check digit, so a
+     * numeric prefix such as a GS1 company prefix yields GTIN-style IDs. gs1Compatible limits IDs to the 20
+     * characters of a GS1 lot or serial number. Once defined, CreateRiceBatch and CreateProduct reject IDs that do
+     * not follow the scheme of their kind; an entity without a scheme keeps free-form IDs. Existing IDs are unaffected
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async DefineIdPolicy(ctx: Context, policyJSON: string): Promise<void> {
+        checkOrgAdmin(ctx);
+
+        let input: any;
+        try {
+            input = JSON.parse(policyJSON);
+        } catch (error) {
+            throw new Error(`ID policy format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('ID policy must be an object');
+        }
+        if (input.batch === undefined && input.product === undefined) {
+            throw new Error('ID policy must define a batch or product scheme');
+        }
+
+        const existing = await readDocument<IdPolicy>(ctx, ID_POLICY_KEY);
+        const policy: IdPolicy = {
+            docType: 'idPolicy',
+            version: existing ? existing.version + 1 : 1,
+            definedBy: ctx.clientIdentity.getMSPID(),
+            lastUpdated: getTxTimestamp(ctx)
+        };
+        if (input.batch !== undefined) {
+            policy.batch = validateScheme('batch', input.batch);
+        }
+        if (input.product !== undefined) {
+            policy.product = validateScheme('product', input.product);
+        }
+
+        await writeDocument(ctx, ID_POLICY_KEY, policy);
+    }
+
+    /**
+     * Get the ID policy in force (without schemes, version 0, until configured)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('IdPolicy')
+    public async GetIdPolicy(ctx: Context): Promise<IdPolicy> {
+        return readIdPolicy(ctx);
+    }
+
+    /**
+     * Draw the next batch ID of the caller's organization under the ID policy
+     * Submit it as a transaction: the sequence is advanced, so concurrent callers get distinct IDs
+     * Permission: Only farm can call
+     */
+    @Transaction()
+    @Returns('string')
+    public async GenerateBatchID(ctx: Context): Promise<string> {
+        checkOrgType(ctx, [OrganizationType.FARM]);
+        return this.generateId(ctx, 'batch');
+    }
+
+    /**
+     * Draw the next product ID of the caller's organization under the ID policy
+     * Submit it as a transaction: the sequence is advanced, so concurrent callers get distinct IDs
+     * Permission: Only middleman/tester can call
+     */
+    @Transaction()
+    @Returns('string')
+    public async GenerateProductID(ctx: Context): Promise<string> {
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
+        return this.generateId(ctx, 'product');
+    }
+
+    /**
+     * Draw the next unused ID from the caller's organization's sequence, skipping IDs already taken
+     * (e.g. created by hand before the policy was defined)
+     */
+    private async generateId(ctx: Context, entity: IdEntity): Promise<string> {
+        const scheme = (await readIdPolicy(ctx))[entity];
+        if (!scheme) {
+            throw new Error(`The ID policy defines no ${entity} ID scheme`);
+        }
+        const mspId = ctx.clientIdentity.getMSPID();
+        const prefix = scheme.prefixes[mspId];
+        if (!prefix) {
+            throw new Error(`The ID policy assigns no ${entity} ID prefix to ${mspId}`);
+        }
+
+        const sequenceKey = `${ID_SEQUENCE_PREFIX}${entity}_${mspId}`;
+        const sequence = (await readDocument<IdSequence>(ctx, sequenceKey)) || { docType: 'idSequence', entity, mspId, next: 1 };
+        const limit = 10 ** scheme.sequenceDigits;
+        for (let next = sequence.next; next < limit; next++) {
+            let id = `${prefix}${String(next).padStart(scheme.sequenceDigits, '0')}`;
+            if (scheme.checkDigit === 'gs1') {
+                id += gs1CheckDigit(id);
+            }
+            // IDs share one key space across tenants, so the raw key is checked
+            const taken = await ctx.stub.getState(`${entity}_${id}`);
+            if (!taken || taken.length === 0) {
+                await writeDocument(ctx, sequenceKey, { ...sequence, next: next + 1 });
+                return id;
+            }
+        }
+        throw new Error(`The ${entity} ID sequence of ${mspId} is exhausted: raise sequenceDigits in the ID policy`);
+    }
+}
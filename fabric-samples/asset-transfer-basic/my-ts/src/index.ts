@@ -5,8 +5,74 @@
 import { RiceTracerContract } from './riceTracerContract';
 import { ProductManagementContract } from './productManagementContract';
 import { QualityCertificationContract } from './qualityCertificationContract';
+import { ProcessingWorkflowContract } from './processingWorkflowContract';
+import { EpcisImportContract } from './epcisImportContract';
+import { AccessAuditContract } from './accessAuditContract';
+import { WeatherDataContract } from './weatherDataContract';
+import { MarketPriceContract } from './marketPriceContract';
+import { AttachmentContract } from './attachmentContract';
+import { EquipmentContract } from './equipmentContract';
+import { QueryCatalogContract } from './queryCatalogContract';
+import { GeographicIndicationContract } from './geographicIndicationContract';
+import { ConsignmentContract } from './consignmentContract';
+import { TraceabilityScoreContract } from './traceabilityScoreContract';
+import { BatchStorageContract } from './batchStorageContract';
+import { CropSeasonContract } from './cropSeasonContract';
+import { NotificationPreferenceContract } from './notificationPreferenceContract';
+import { ProductVerificationContract } from './productVerificationContract';
+import { BatchReservationContract } from './batchReservationContract';
+import { ParticipantRegistryContract } from './participantRegistryContract';
+import { DocumentAnchorContract } from './documentAnchorContract';
+import { RecallContract } from './recallContract';
+import { AgroInputContract } from './agroInputContract';
+import { IdentifierPolicyContract } from './identifierPolicyContract';
+import { ComplianceProfileContract } from './complianceProfileContract';
+import { FacilityContract } from './facilityContract';
+import { InspectionSelectionContract } from './inspectionSelectionContract';
+import { PrivateDataRetentionContract } from './privateDataRetentionContract';
+import { SettlementContract } from './settlementContract';
+import { QualityTrendsContract } from './qualityTrendsContract';
+import { OrganizationBrandingContract } from './organizationBrandingContract';
+import { LabelDictionaryContract } from './labelDictionaryContract';
+import { ScheduledTransferContract } from './scheduledTransferContract';
+import { InputLimitContract } from './inputLimitContract';
+import { ResourceUsageContract } from './resourceUsageContract';
+import { BatchStatusContract } from './batchStatusContract';
 
 module.exports.RiceTracerContract = RiceTracerContract;
 module.exports.ProductManagementContract = ProductManagementContract;
 module.exports.QualityCertificationContract = QualityCertificationContract;
-module.exports.contracts = [RiceTracerContract, ProductManagementContract, QualityCertificationContract]; 
\ No newline at end of file
+module.exports.ProcessingWorkflowContract = ProcessingWorkflowContract;
+module.exports.EpcisImportContract = EpcisImportContract;
+module.exports.AccessAuditContract = AccessAuditContract;
+module.exports.WeatherDataContract = WeatherDataContract;
+module.exports.MarketPriceContract = MarketPriceContract;
+module.exports.AttachmentContract = AttachmentContract;
+module.exports.EquipmentContract = EquipmentContract;
+module.exports.QueryCatalogContract = QueryCatalogContract;
+module.exports.GeographicIndicationContract = GeographicIndicationContract;
+module.exports.ConsignmentContract = ConsignmentContract;
+module.exports.TraceabilityScoreContract = TraceabilityScoreContract;
+module.exports.BatchStorageContract = BatchStorageContract;
+module.exports.CropSeasonContract = CropSeasonContract;
+module.exports.NotificationPreferenceContract = NotificationPreferenceContract;
+module.exports.ProductVerificationContract = ProductVerificationContract;
+module.exports.BatchReservationContract = BatchReservationContract;
+module.exports.ParticipantRegistryContract = ParticipantRegistryContract;
+module.exports.DocumentAnchorContract = DocumentAnchorContract;
+module.exports.RecallContract = RecallContract;
+module.exports.AgroInputContract = AgroInputContract;
+module.exports.IdentifierPolicyContract = IdentifierPolicyContract;
+module.exports.ComplianceProfileContract = ComplianceProfileContract;
+module.exports.FacilityContract = FacilityContract;
+module.exports.InspectionSelectionContract = InspectionSelectionContract;
+module.exports.PrivateDataRetentionContract = PrivateDataRetentionContract;
+module.exports.SettlementContract = SettlementContract;
+module.exports.QualityTrendsContract = QualityTrendsContract;
+module.exports.OrganizationBrandingContract = OrganizationBrandingContract;
+module.exports.LabelDictionaryContract = LabelDictionaryContract;
+module.exports.ScheduledTransferContract = ScheduledTransferContract;
+module.exports.InputLimitContract = InputLimitContract;
+module.exports.ResourceUsageContract = ResourceUsageContract;
+module.exports.BatchStatusContract = BatchStatusContract;
+module.exports.contracts = [RiceTracerContract, ProductManagementContract, QualityCertificationContract, ProcessingWorkflowContract, EpcisImportContract, AccessAuditContract, WeatherDataContract, MarketPriceContract, AttachmentContract, EquipmentContract, QueryCatalogContract, GeographicIndicationContract, ConsignmentContract, TraceabilityScoreContract, BatchStorageContract, CropSeasonContract, NotificationPreferenceContract, ProductVerificationContract, BatchReservationContract, ParticipantRegistryContract, DocumentAnchorContract, RecallContract, AgroInputContract, IdentifierPolicyContract, ComplianceProfileContract, FacilityContract, InspectionSelectionContract, PrivateDataRetentionContract, SettlementContract, QualityTrendsContract, OrganizationBrandingContract, LabelDictionaryContract, ScheduledTransferContract, InputLimitContract, ResourceUsageContract, BatchStatusContract]; 
\ No newline at end of file
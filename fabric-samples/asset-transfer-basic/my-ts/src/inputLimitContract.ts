@@ -0,0 +1,123 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { InputLimits } from './types';
+import { readDocument, writeDocument, getTxTimestamp, checkOrgAdmin, DEFAULT_MAX_LABELS } from './utils';
+
+/**
+ * Ledger key of the configured input limits
+ */
+const INPUT_LIMITS_KEY = 'limits_input';
+
+/**
+ * Limits used until an administrator configures others
+ */
+const DEFAULT_INPUT_LIMITS: InputLimits = {
+    docType: 'inputLimits',
+    maxReportBytes: 64 * 1024,
+    maxLabels: DEFAULT_MAX_LABELS,
+    maxBulkItems: 500,
+    version: 0
+};
+
+/**
+ * Get the input limits in force
+ */
+export async function readInputLimits(ctx: Context): Promise<InputLimits> {
+    return (await readDocument<InputLimits>(ctx, INPUT_LIMITS_KEY)) || DEFAULT_INPUT_LIMITS;
+}
+
+/**
+ * Reject a report (JSON string) larger than maxReportBytes, before it is parsed and stored in a history event
+ */
+export async function assertReportSize(ctx: Context, reportJSON: string, description: string): Promise<void> {
+    const { maxReportBytes } = await readInputLimits(ctx);
+    const bytes = Buffer.byteLength(reportJSON || '', 'utf8');
+    if (bytes > maxReportBytes) {
+        throw new Error(`${description} has ${bytes} bytes, above the input limit of ${maxReportBytes} bytes`);
+    }
+}
+
+/**
+ * Reject a list of more than maxBulkItems items submitted in one transaction
+ */
+export async function assertBulkSize(ctx: Context, count: number, description: string): Promise<void> {
+    const { maxBulkItems } = await readInputLimits(ctx);
+    if (count > maxBulkItems) {
+        throw new Error(`${description} has ${count} items, above the input limit of ${maxBulkItems} items per transaction`);
+    }
+}
+
+@Info({ title: 'InputLimitContract', description: 'Smart contract configuring the size limits of transaction inputs' })
+export class InputLimitContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "InputLimitContract Method Permission Configuration": {
+                "DefineInputLimits": ["Organization Administrators"],
+                "GetInputLimits": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Configure the input limits
+     * limitsJSON: { maxReportBytes, maxLabels, maxBulkItems }, all positive integers. maxReportBytes caps the report
+     * JSON of a recorded step, maxLabels the labels of a batch or product, and maxBulkItems the events of an EPCIS
+     * import and the items of a recall or consignment. Inputs above a limit are rejected before anything is written.
+     * Functions with a lower built-in cap keep it
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async DefineInputLimits(ctx: Context, limitsJSON: string): Promise<void> {
+        checkOrgAdmin(ctx);
+
+        let input: any;
+        try {
+            input = JSON.parse(limitsJSON);
+        } catch (error) {
+            throw new Error(`Input limits format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('Input limits must be an object');
+        }
+        for (const limit of ['maxReportBytes', 'maxLabels', 'maxBulkItems']) {
+            if (!Number.isInteger(input[limit]) || input[limit] <= 0) {
+                throw new Error(`${limit} must be a positive integer`);
+            }
+        }
+
+        const existing = await readDocument<InputLimits>(ctx, INPUT_LIMITS_KEY);
+        const limits: InputLimits = {
+            docType: 'inputLimits',
+            maxReportBytes: input.maxReportBytes,
+            maxLabels: input.maxLabels,
+            maxBulkItems: input.maxBulkItems,
+            version: existing ? existing.version + 1 : 1,
+            definedBy: ctx.clientIdentity.getMSPID(),
+            lastUpdated: getTxTimestamp(ctx)
+        };
+
+        await writeDocument(ctx, INPUT_LIMITS_KEY, limits);
+    }
+
+    /**
+     * Get the input limits in force (the built-in defaults, version 0, until configured)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('InputLimits')
+    public async GetInputLimits(ctx: Context): Promise<InputLimits> {
+        return readInputLimits(ctx);
+    }
+}
@@ -0,0 +1,212 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { InspectionCandidate, InspectionSelection, OrganizationType, Participant, RiceBatch } from './types';
+import { RiceTracerContract } from './riceTracerContract';
+import { QualityCertificationContract, isPassedTest } from './qualityCertificationContract';
+import { getParticipantsByIdAndName } from './participantRegistryContract';
+import { getRegulatorMspId } from './accessAuditContract';
+import {
+    DISPOSED_STATE, readDocument, writeDocument, getTxTimestamp, emitEvent, getCallerFingerprint, sha256Hex, checkOrgType,
+    getCallerTenant, inheritTenant, isVisibleToCaller
+} from './utils';
+
+/**
+ * Most batches drawn in one selection
+ */
+const MAX_INSPECTION_COUNT = 100;
+
+/**
+ * Weight of a batch without risk factors, added to for each failed test and each new owner
+ */
+const BASE_WEIGHT = 1;
+const FAILED_TEST_WEIGHT = 3;
+const NEW_PARTICIPANT_WEIGHT = 2;
+
+/**
+ * Participants registered within this many days of the draw count as new
+ */
+const NEW_PARTICIPANT_DAYS = 90;
+
+/**
+ * Fabric transaction ID: SHA-256 in lowercase hex
+ */
+const TX_ID_PATTERN = /^[0-9a-f]{64}$/;
+
+@Info({ title: 'InspectionSelectionContract', description: 'Smart contract drawing batches for regulator spot checks, weighted by risk' })
+export class InspectionSelectionContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "InspectionSelectionContract Method Permission Configuration": {
+                "SelectBatchesForInspection": ["Regulator"],
+                "GetInspectionSelection": ["All Organizations"],
+                "GetInspectionSelections": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Draw count batches for spot checks, without replacement, each with a chance proportional to its weight:
+     * 1, plus 3 per failed (unrevoked) test and 2 per owner registered within 90 days or not registered at all.
+     * Disposed batches are not drawn. The draw is seeded with seedTxId, the ID of an already committed
+     * transaction, so the regulator cannot choose the outcome by resubmitting; each seed draws once. The weights
+     * of all candidates are recorded with the selection so anyone can recompute the draw
+     * Permission: Regulator only
+     */
+    @Transaction()
+    @Returns('InspectionSelection')
+    public async SelectBatchesForInspection(ctx: Context, count: string, seedTxId: string): Promise<InspectionSelection> {
+        // Check permission: Only consumer/regulatory organizations draw spot checks; of those, only the regulator
+        checkOrgType(ctx, [OrganizationType.CONSUMER]);
+        const regulatorMspId = getRegulatorMspId();
+        if (ctx.clientIdentity.getMSPID() !== regulatorMspId) {
+            throw new Error(`Permission denied: Only the regulator ${regulatorMspId} can select batches for inspection`);
+        }
+
+        const requestedCount = Number(count);
+        if (!Number.isInteger(requestedCount) || requestedCount < 1 || requestedCount > MAX_INSPECTION_COUNT) {
+            throw new Error(`Invalid count ${count}: must be an integer from 1 to ${MAX_INSPECTION_COUNT}`);
+        }
+        if (!TX_ID_PATTERN.test(seedTxId || '')) {
+            throw new Error('seedTxId must be the ID of a committed transaction (64 lowercase hex characters)');
+        }
+        if (seedTxId === ctx.stub.getTxID()) {
+            throw new Error('seedTxId must be the ID of an earlier transaction, not of this one');
+        }
+        // A seed draws once across tenants
+        const existing = await ctx.stub.getState(`inspection_${seedTxId}`);
+        if (existing && existing.length > 0) {
+            throw new Error(`Seed ${seedTxId} was already used for a selection`);
+        }
+
+        const candidates = await this.weighCandidates(ctx);
+        if (candidates.length === 0) {
+            throw new Error('No batches to select for inspection');
+        }
+
+        // Weighted draw without replacement; draw n uses the first 52 bits of SHA-256(seed:n) as a fraction
+        const pool = [...candidates];
+        const selected: InspectionCandidate[] = [];
+        for (let draw = 0; draw < requestedCount && pool.length > 0; draw++) {
+            const totalWeight = pool.reduce((sum, candidate) => sum + candidate.weight, 0);
+            let point = parseInt(sha256Hex(`${seedTxId}:${draw}`).slice(0, 13), 16) / 2 ** 52 * totalWeight;
+            let index = pool.findIndex(candidate => (point -= candidate.weight) < 0);
+            if (index === -1) {
+                index = pool.length - 1;
+            }
+            selected.push(pool.splice(index, 1)[0]);
+        }
+
+        const weights: Record<string, number> = {};
+        candidates.forEach(candidate => {
+            weights[candidate.batchId] = candidate.weight;
+        });
+        const selection: InspectionSelection = {
+            docType: 'inspectionSelection',
+            seedTxId,
+            requestedCount,
+            candidateCount: candidates.length,
+            weights,
+            selected,
+            selectedByMspId: ctx.clientIdentity.getMSPID(),
+            selectedByFingerprint: getCallerFingerprint(ctx),
+            selectedAt: getTxTimestamp(ctx),
+            txId: ctx.stub.getTxID()
+        };
+        // The candidates are the batches visible to the caller, so the selection belongs to the caller's tenant
+        inheritTenant(selection, { tenantId: getCallerTenant(ctx) });
+        await writeDocument(ctx, `inspection_${seedTxId}`, selection);
+        emitEvent(ctx, 'InspectionSelected', selection);
+        return selection;
+    }
+
+    /**
+     * Get a selection by the seed it was drawn with
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('InspectionSelection')
+    public async GetInspectionSelection(ctx: Context, seedTxId: string): Promise<InspectionSelection> {
+        const selection = await readDocument<InspectionSelection>(ctx, `inspection_${seedTxId}`);
+        if (!selection) {
+            throw new Error(`No inspection selection was drawn with seed ${seedTxId}`);
+        }
+        return selection;
+    }
+
+    /**
+     * Get all selections, most recent first
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('InspectionSelection[]')
+    public async GetInspectionSelections(ctx: Context): Promise<InspectionSelection[]> {
+        const iterator = await ctx.stub.getStateByRange('inspection_', 'inspection_\uffff');
+        const selections: InspectionSelection[] = [];
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                const selection: InspectionSelection = JSON.parse(result.value.value.toString());
+                if (isVisibleToCaller(ctx, selection)) {
+                    selections.push(selection);
+                }
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+        return selections.sort((a, b) => b.selectedAt.localeCompare(a.selectedAt) || a.seedTxId.localeCompare(b.seedTxId));
+    }
+
+    /**
+     * Weigh every batch that is not disposed by its failed tests and new owners, in batch ID order
+     */
+    private async weighCandidates(ctx: Context): Promise<InspectionCandidate[]> {
+        const tests = await new QualityCertificationContract().GetAllTestResults(ctx);
+        const participants = await getParticipantsByIdAndName(ctx);
+        const newSince = new Date(Date.parse(getTxTimestamp(ctx)) - NEW_PARTICIPANT_DAYS * 24 * 60 * 60 * 1000).toISOString();
+
+        return (await new RiceTracerContract().GetAllRiceBatches(ctx))
+            .filter(batch => batch.currentState !== DISPOSED_STATE)
+            .sort((a, b) => a.batchId.localeCompare(b.batchId))
+            .map(batch => {
+                const failedTests = tests
+                    .filter(test => test.batchId === batch.batchId && !test.revoked && !isPassedTest(test))
+                    .map(test => test.testId);
+                const newParticipants = this.batchOwners(batch)
+                    .filter(owner => this.isNewParticipant(participants.get(owner), newSince));
+                return {
+                    batchId: batch.batchId,
+                    weight: BASE_WEIGHT + FAILED_TEST_WEIGHT * failedTests.length + NEW_PARTICIPANT_WEIGHT * newParticipants.length,
+                    failedTests,
+                    newParticipants
+                };
+            });
+    }
+
+    /**
+     * Everyone who has held a batch, in order of first custody
+     */
+    private batchOwners(batch: RiceBatch): string[] {
+        const owners = [...(batch.history || []).map(event => event.to), batch.currentOwner];
+        return [...new Set(owners.filter(owner => !!owner))];
+    }
+
+    /**
+     * A participant without a track record: not registered, or registered after newSince
+     * Participants seeded without a registration time count as established
+     */
+    private isNewParticipant(participant: Participant | undefined, newSince: string): boolean {
+        return !participant || (!!participant.registeredAt && participant.registeredAt > newSince);
+    }
+}
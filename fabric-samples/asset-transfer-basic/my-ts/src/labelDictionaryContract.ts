@@ -0,0 +1,170 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { LabelDictionary, LabelTranslation } from './types';
+import { readDocument, writeDocument, emitEvent, getTxTimestamp, checkOrgAdmin } from './utils';
+
+/**
+ * Locales labels can be translated to
+ */
+export const LABEL_LOCALES = ['zh', 'en'];
+
+/**
+ * Kinds of translated codes: names of payload fields, and recorded values such as processing steps, statuses and grades
+ */
+export const LABEL_CATEGORIES = ['field', 'value'];
+
+const MAX_CODE_LENGTH = 100;
+const MAX_LABEL_LENGTH = 200;
+
+const TRANSLATION_PREFIX = 'translation_';
+
+@Info({ title: 'LabelDictionaryContract', description: 'Smart contract keeping the Chinese and English labels of fields and recorded values' })
+export class LabelDictionaryContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "LabelDictionaryContract Method Permission Configuration": {
+                "SetTranslation": ["Organization Administrators"],
+                "RemoveTranslation": ["Organization Administrators"],
+                "GetTranslations": ["All Organizations"],
+                "GetLabelDictionary": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Set the labels of a field name or recorded value, replacing its previous labels
+     * category is field or value; code is the field name or the value as recorded, e.g. QualityInspection or Grade 1;
+     * labelsJSON: { zh?, en? } with at least one label
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    @Returns('LabelTranslation')
+    public async SetTranslation(ctx: Context, category: string, code: string, labelsJSON: string): Promise<LabelTranslation> {
+        checkOrgAdmin(ctx);
+        this.validateCode(category, code);
+
+        let input: unknown;
+        try {
+            input = JSON.parse(labelsJSON);
+        } catch (error) {
+            throw new Error(`Labels format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error(`Labels must be an object with a label per locale (${LABEL_LOCALES.join(', ')})`);
+        }
+        const labels: Record<string, string> = {};
+        for (const [locale, label] of Object.entries(input as Record<string, unknown>)) {
+            if (!LABEL_LOCALES.includes(locale)) {
+                throw new Error(`Unsupported locale: ${locale}. Allowed values: ${LABEL_LOCALES.join(', ')}`);
+            }
+            const text = typeof label === 'string' ? label.trim() : '';
+            if (!text || text.length > MAX_LABEL_LENGTH) {
+                throw new Error(`The ${locale} label must be text of 1 to ${MAX_LABEL_LENGTH} characters`);
+            }
+            labels[locale] = text;
+        }
+        if (Object.keys(labels).length === 0) {
+            throw new Error('At least one label is required');
+        }
+
+        const translation: LabelTranslation = {
+            docType: 'labelTranslation',
+            category,
+            code,
+            labels,
+            updatedBy: ctx.clientIdentity.getMSPID(),
+            lastUpdated: getTxTimestamp(ctx)
+        };
+        await writeDocument(ctx, this.getKey(category, code), translation);
+        emitEvent(ctx, 'LabelTranslationChanged', translation);
+        return translation;
+    }
+
+    /**
+     * Remove the labels of a code; apps show the code as recorded, or their built-in label
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async RemoveTranslation(ctx: Context, category: string, code: string): Promise<void> {
+        checkOrgAdmin(ctx);
+        this.validateCode(category, code);
+
+        const key = this.getKey(category, code);
+        if (!(await readDocument<LabelTranslation>(ctx, key))) {
+            throw new Error(`No translation of ${category} ${code} exists`);
+        }
+        await ctx.stub.deleteState(key);
+        emitEvent(ctx, 'LabelTranslationChanged', { category, code, removed: true });
+    }
+
+    /**
+     * Get the translations of a category, or of every category when category is empty, ordered by code
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('LabelTranslation[]')
+    public async GetTranslations(ctx: Context, category: string): Promise<LabelTranslation[]> {
+        if (category && !LABEL_CATEGORIES.includes(category)) {
+            throw new Error(`Invalid label category: ${category}. Allowed values: ${LABEL_CATEGORIES.join(', ')}`);
+        }
+        const prefix = category ? `${TRANSLATION_PREFIX}${category}_` : TRANSLATION_PREFIX;
+        const iterator = await ctx.stub.getStateByRange(prefix, `${prefix}\uffff`);
+        const translations: LabelTranslation[] = [];
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                translations.push(JSON.parse(result.value.value.toString()));
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+        return translations;
+    }
+
+    /**
+     * Get the labels of one locale, by field name and by value; codes without a label in the locale are left out
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('LabelDictionary')
+    public async GetLabelDictionary(ctx: Context, locale: string): Promise<LabelDictionary> {
+        if (!LABEL_LOCALES.includes(locale)) {
+            throw new Error(`Unsupported locale: ${locale}. Allowed values: ${LABEL_LOCALES.join(', ')}`);
+        }
+
+        const dictionary: LabelDictionary = { locale, fields: {}, values: {} };
+        for (const translation of await this.GetTranslations(ctx, '')) {
+            const label = translation.labels[locale];
+            if (label) {
+                const labels = translation.category === 'field' ? dictionary.fields : dictionary.values;
+                labels[translation.code] = label;
+            }
+        }
+        return dictionary;
+    }
+
+    private validateCode(category: string, code: string): void {
+        if (!LABEL_CATEGORIES.includes(category)) {
+            throw new Error(`Invalid label category: ${category}. Allowed values: ${LABEL_CATEGORIES.join(', ')}`);
+        }
+        if (!code || code.trim() !== code || code.length > MAX_CODE_LENGTH) {
+            throw new Error(`Code is required, without surrounding spaces, and can have at most ${MAX_CODE_LENGTH} characters`);
+        }
+    }
+
+    private getKey(category: string, code: string): string {
+        return `${TRANSLATION_PREFIX}${category}_${code}`;
+    }
+}
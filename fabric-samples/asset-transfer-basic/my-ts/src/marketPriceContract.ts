@@ -0,0 +1,191 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { MarketPrice } from './types';
+import {
+    readDocument, writeDocument, normalizeTimestamp, getTxTimestamp, getCallerFingerprint, emitEvent, putIndexEntry, getIndexEntries
+} from './utils';
+
+/**
+ * Environment variable listing the identities allowed to record market prices, as comma-separated
+ * "<MSP ID>:<certificate SHA-256 fingerprint>" entries (colons in the fingerprint are ignored)
+ * Must be set identically on all peers; prices cannot be recorded while it is empty
+ */
+const PRICE_ORACLE_ENV = 'RICETRACE_PRICE_ORACLES';
+
+/**
+ * Composite key index of market prices by variety, region and date
+ */
+export const PRICE_INDEX = 'variety~region~date';
+
+const DATE_PATTERN = /^\d{4}-\d{2}-\d{2}$/;
+
+@Info({ title: 'MarketPriceContract', description: 'Smart contract keeping the on-ledger market price series recorded by the price oracle' })
+export class MarketPriceContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "MarketPriceContract Method Permission Configuration": {
+                "RecordMarketPrice": ["Designated price oracle identities"],
+                "ReadMarketPrice": ["All Organizations"],
+                "GetReferencePrice": ["All Organizations"],
+                "GetMarketPriceSeries": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Record the market price of a variety in a region on a day (YYYY-MM-DD), in CNY per tonne
+     * A recorded price is final, so settlements and agreements referencing it cannot be changed afterwards
+     * Permission: Only the designated price oracle identities can call
+     */
+    @Transaction()
+    public async RecordMarketPrice(
+        ctx: Context,
+        variety: string,
+        region: string,
+        date: string,
+        price: string,
+        source: string
+    ): Promise<void> {
+        this.checkPriceOracle(ctx);
+
+        if (!variety || !region || !source) {
+            throw new Error('Variety, region and source are required');
+        }
+        this.validateDate(date, 'date');
+        const now = getTxTimestamp(ctx);
+        if (date > now.slice(0, 10)) {
+            throw new Error(`Cannot record a market price for a future date (${date})`);
+        }
+        const value = Number(price);
+        if (!/^\d+(\.\d+)?$/.test((price || '').trim()) || !(value > 0)) {
+            throw new Error(`Invalid price ${price}: expected a positive decimal number`);
+        }
+
+        const key = `price_${variety}_${region}_${date}`;
+        if (await readDocument<MarketPrice>(ctx, key)) {
+            throw new Error(`The market price of ${variety} in ${region} on ${date} has already been recorded`);
+        }
+
+        const marketPrice: MarketPrice = {
+            docType: 'marketPrice',
+            variety,
+            region,
+            date,
+            price: value,
+            source,
+            recordedBy: ctx.clientIdentity.getMSPID(),
+            oracleFingerprint: getCallerFingerprint(ctx),
+            recordedAt: now
+        };
+        await writeDocument(ctx, key, marketPrice);
+        await putIndexEntry(ctx, PRICE_INDEX, [variety, region, date]);
+        emitEvent(ctx, 'MarketPriceRecorded', marketPrice);
+    }
+
+    /**
+     * Read the market price recorded for a day
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('MarketPrice')
+    public async ReadMarketPrice(ctx: Context, variety: string, region: string, date: string): Promise<MarketPrice> {
+        const marketPrice = await readDocument<MarketPrice>(ctx, `price_${variety}_${region}_${date}`);
+        if (!marketPrice) {
+            throw new Error(`No market price of ${variety} in ${region} has been recorded for ${date}`);
+        }
+        return marketPrice;
+    }
+
+    /**
+     * Get the price in force on a day: the latest price recorded on or before it, at most maxAgeDays old
+     * (e.g. Friday's close for a Sunday settlement). maxAgeDays defaults to 7
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('MarketPrice')
+    public async GetReferencePrice(ctx: Context, variety: string, region: string, date: string, maxAgeDays: string): Promise<MarketPrice> {
+        this.validateDate(date, 'date');
+        const maxAge = maxAgeDays ? Number(maxAgeDays) : 7;
+        if (!Number.isInteger(maxAge) || maxAge < 0) {
+            throw new Error(`Invalid maxAgeDays ${maxAgeDays}: expected a non-negative integer`);
+        }
+        const earliest = new Date(Date.parse(`${date}T00:00:00Z`) - maxAge * 86400000).toISOString().slice(0, 10);
+
+        const dates = (await getIndexEntries(ctx, PRICE_INDEX, [variety, region]))
+            .map(([, , recordedDate]) => recordedDate)
+            .filter(recordedDate => recordedDate <= date && recordedDate >= earliest);
+        if (dates.length === 0) {
+            throw new Error(`No market price of ${variety} in ${region} has been recorded between ${earliest} and ${date}`);
+        }
+        return this.ReadMarketPrice(ctx, variety, region, dates[dates.length - 1]);
+    }
+
+    /**
+     * Get the recorded prices of a variety in a region by date, optionally limited to a date range (inclusive)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('MarketPrice[]')
+    public async GetMarketPriceSeries(ctx: Context, variety: string, region: string, from: string, to: string): Promise<MarketPrice[]> {
+        if (from) {
+            this.validateDate(from, 'from');
+        }
+        if (to) {
+            this.validateDate(to, 'to');
+        }
+
+        const prices: MarketPrice[] = [];
+        for (const [, , date] of await getIndexEntries(ctx, PRICE_INDEX, [variety, region])) {
+            if ((from && date < from) || (to && date > to)) {
+                continue;
+            }
+            const marketPrice = await readDocument<MarketPrice>(ctx, `price_${variety}_${region}_${date}`);
+            if (marketPrice) {
+                prices.push(marketPrice);
+            }
+        }
+        return prices;
+    }
+
+    /**
+     * Check that the caller is one of the designated price oracle identities
+     */
+    private checkPriceOracle(ctx: Context): void {
+        const oracles = (process.env[PRICE_ORACLE_ENV] || '')
+            .split(',')
+            .map(entry => entry.trim())
+            .filter(Boolean)
+            .map(entry => {
+                const separator = entry.indexOf(':');
+                return { mspId: entry.slice(0, separator), fingerprint: entry.slice(separator + 1).replace(/:/g, '').toLowerCase() };
+            });
+
+        const mspId = ctx.clientIdentity.getMSPID();
+        const fingerprint = getCallerFingerprint(ctx);
+        if (!oracles.some(oracle => oracle.mspId === mspId && oracle.fingerprint === fingerprint)) {
+            throw new Error(`Permission denied: Only the designated price oracle identities (${PRICE_ORACLE_ENV}) can record market prices`);
+        }
+    }
+
+    /**
+     * Validate a YYYY-MM-DD date
+     */
+    private validateDate(date: string, fieldName: string): void {
+        if (!DATE_PATTERN.test(date || '')) {
+            throw new Error(`Invalid ${fieldName} ${date}: expected YYYY-MM-DD`);
+        }
+        normalizeTimestamp(date, fieldName); // Rejects out-of-range dates such as 2024-02-30
+    }
+}
@@ -0,0 +1,193 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { NotificationChannel, NotificationPreference, Participant } from './types';
+import { readDocument, writeDocument, getTxTimestamp, emitEvent } from './utils';
+
+/**
+ * Key prefix of notification preferences, one document per participant
+ */
+export const NOTIFICATION_PREFERENCE_PREFIX = 'notifypref_';
+
+/**
+ * Delivery channels the event bridge can route notifications to, and the form of their targets
+ */
+const CHANNEL_TARGET_PATTERNS: Record<string, RegExp> = {
+    email: /^[^\s@]+@[^\s@]+\.[^\s@]+$/,
+    sms: /^\+[1-9]\d{6,14}$/, // E.164 phone number
+    webhook: /^https?:\/\/\S+$/
+};
+
+/**
+ * Most event types, batches and channels one participant can subscribe
+ */
+const MAX_SUBSCRIPTION_ENTRIES = 50;
+
+@Info({ title: 'NotificationPreferenceContract', description: 'Smart contract registering which events participants want to be notified of, and how' })
+export class NotificationPreferenceContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "NotificationPreferenceContract Method Permission Configuration": {
+                "SetNotificationPreference": ["Organization of the participant"],
+                "RemoveNotificationPreference": ["Organization of the participant"],
+                "GetNotificationPreference": ["All Organizations"],
+                "GetAllNotificationPreferences": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Register or replace the notification preferences of a participant
+     * preferenceJSON: { eventTypes: [...], batchIds: [...], channels: [{ type: email|sms|webhook, target }] }
+     * Empty eventTypes or batchIds subscribe to every event type or batch. Targets are readable by every channel
+     * member, so use role mailboxes and gateway endpoints rather than personal contacts
+     * Permission: The organization of a registered participant; otherwise the organization that first set the preferences
+     */
+    @Transaction()
+    public async SetNotificationPreference(ctx: Context, participantId: string, preferenceJSON: string): Promise<void> {
+        if (!participantId) {
+            throw new Error('Participant ID is required');
+        }
+        const existing = await this.checkPreferenceOwner(ctx, participantId);
+
+        let input: any;
+        try {
+            input = JSON.parse(preferenceJSON);
+        } catch (error) {
+            throw new Error(`Notification preference format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('Notification preference must be an object');
+        }
+
+        const eventTypes = this.parseNameList(input.eventTypes, 'eventTypes');
+        const batchIds = this.parseNameList(input.batchIds, 'batchIds');
+        if (!Array.isArray(input.channels) || input.channels.length === 0) {
+            throw new Error('At least one notification channel is required');
+        }
+        if (input.channels.length > MAX_SUBSCRIPTION_ENTRIES) {
+            throw new Error(`At most ${MAX_SUBSCRIPTION_ENTRIES} channels can be registered`);
+        }
+        const channels: NotificationChannel[] = input.channels.map((channel: any) => {
+            const type = channel && typeof channel.type === 'string' ? channel.type.toLowerCase() : '';
+            const pattern = CHANNEL_TARGET_PATTERNS[type];
+            if (!pattern) {
+                throw new Error(`Unknown notification channel ${channel && channel.type}, expected one of: ${Object.keys(CHANNEL_TARGET_PATTERNS).join(', ')}`);
+            }
+            const target = typeof channel.target === 'string' ? channel.target.trim() : '';
+            if (!pattern.test(target)) {
+                throw new Error(`Invalid ${type} target ${channel.target}`);
+            }
+            return { type, target };
+        });
+
+        const preference: NotificationPreference = {
+            docType: 'notificationPreference',
+            participantId,
+            mspId: ctx.clientIdentity.getMSPID(),
+            eventTypes,
+            batchIds,
+            channels,
+            version: existing ? existing.version + 1 : 1,
+            updatedAt: getTxTimestamp(ctx)
+        };
+
+        await writeDocument(ctx, `${NOTIFICATION_PREFERENCE_PREFIX}${participantId}`, preference);
+        emitEvent(ctx, 'NotificationPreferenceChanged', preference);
+    }
+
+    /**
+     * Remove the notification preferences of a participant, unsubscribing it from every event
+     * Permission: The organization that set the preferences
+     */
+    @Transaction()
+    public async RemoveNotificationPreference(ctx: Context, participantId: string): Promise<void> {
+        const existing = await this.checkPreferenceOwner(ctx, participantId);
+        if (!existing) {
+            throw new Error(`The participant ${participantId} has no notification preferences`);
+        }
+
+        await ctx.stub.deleteState(`${NOTIFICATION_PREFERENCE_PREFIX}${participantId}`);
+        emitEvent(ctx, 'NotificationPreferenceRemoved', { participantId, mspId: existing.mspId });
+    }
+
+    /**
+     * Get the notification preferences of a participant
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('NotificationPreference')
+    public async GetNotificationPreference(ctx: Context, participantId: string): Promise<NotificationPreference> {
+        const preference = await readDocument<NotificationPreference>(ctx, `${NOTIFICATION_PREFERENCE_PREFIX}${participantId}`);
+        if (!preference) {
+            throw new Error(`The participant ${participantId} has no notification preferences`);
+        }
+        return preference;
+    }
+
+    /**
+     * Get the notification preferences of every participant, which the event bridge routes notifications by
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('NotificationPreference[]')
+    public async GetAllNotificationPreferences(ctx: Context): Promise<NotificationPreference[]> {
+        const iterator = await ctx.stub.getStateByRange(NOTIFICATION_PREFERENCE_PREFIX, `${NOTIFICATION_PREFERENCE_PREFIX}\uffff`);
+        const preferences: NotificationPreference[] = [];
+
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                preferences.push(JSON.parse(result.value.value.toString()));
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+        return preferences;
+    }
+
+    /**
+     * Only the participant's organization manages its preferences: the organization of a registered participant,
+     * or the one that set the existing preferences. Returns the existing preferences, if any
+     */
+    private async checkPreferenceOwner(ctx: Context, participantId: string): Promise<NotificationPreference | null> {
+        const mspId = ctx.clientIdentity.getMSPID();
+        const participant = await readDocument<Participant>(ctx, `participant_${participantId}`);
+        if (participant && participant.mspId && participant.mspId !== mspId) {
+            throw new Error(`Permission denied: Participant ${participantId} belongs to ${participant.mspId}`);
+        }
+
+        const existing = await readDocument<NotificationPreference>(ctx, `${NOTIFICATION_PREFERENCE_PREFIX}${participantId}`);
+        if (existing && existing.mspId !== mspId) {
+            throw new Error(`Permission denied: The notification preferences of ${participantId} are managed by ${existing.mspId}`);
+        }
+        return existing;
+    }
+
+    /**
+     * Validate an optional list of event type names or batch IDs (missing = empty = all)
+     */
+    private parseNameList(value: any, field: string): string[] {
+        if (value === undefined || value === null) {
+            return [];
+        }
+        if (!Array.isArray(value) || value.some(entry => typeof entry !== 'string' || !entry.trim())) {
+            throw new Error(`${field} must be an array of non-empty strings`);
+        }
+        if (value.length > MAX_SUBSCRIPTION_ENTRIES) {
+            throw new Error(`At most ${MAX_SUBSCRIPTION_ENTRIES} ${field} can be subscribed`);
+        }
+        return [...new Set(value.map((entry: string) => entry.trim()))];
+    }
+}
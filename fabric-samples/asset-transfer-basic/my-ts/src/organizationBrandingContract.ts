@@ -0,0 +1,138 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { OrganizationBranding } from './types';
+import { readDocument, writeDocument, emitEvent, getTxTimestamp, checkOrgAdmin, getCallerFingerprint } from './utils';
+
+/**
+ * Longest brand name and brand story, in characters
+ */
+const MAX_BRAND_NAME_LENGTH = 100;
+const MAX_STORY_LENGTH = 2000;
+
+/**
+ * SHA-256 digest in lowercase hex
+ */
+const SHA256_HEX_PATTERN = /^[0-9a-f]{64}$/;
+
+/**
+ * Schemes a logo can be fetched from by consumer apps
+ */
+const LOGO_URI_PATTERN = /^(https|ipfs):\/\/\S+$/;
+
+@Info({ title: 'OrganizationBrandingContract', description: 'Smart contract registering the brand each organization presents to consumers' })
+export class OrganizationBrandingContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "OrganizationBrandingContract Method Permission Configuration": {
+                "SetOrganizationBranding": ["Organization Administrators (own organization)"],
+                "GetOrganizationBranding": ["All Organizations"],
+                "GetAllOrganizationBrandings": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Register or replace the brand of the caller's organization, shown to consumers with the products it packaged
+     * brandingJSON: { brandName, logoHash?, logoUri?, story? }; logoHash is the SHA-256 (hex) of the logo file,
+     * e.g. the fileHash of its attachment, and logoUri an https or ipfs address it can be fetched from
+     * Permission: Only organization administrators can call, for their own organization
+     */
+    @Transaction()
+    @Returns('OrganizationBranding')
+    public async SetOrganizationBranding(ctx: Context, brandingJSON: string): Promise<OrganizationBranding> {
+        checkOrgAdmin(ctx);
+
+        let input: { brandName?: unknown; logoHash?: unknown; logoUri?: unknown; story?: unknown };
+        try {
+            input = JSON.parse(brandingJSON);
+        } catch (error) {
+            throw new Error(`Branding format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('Branding must be an object with a brandName');
+        }
+
+        const brandName = typeof input.brandName === 'string' ? input.brandName.trim() : '';
+        if (!brandName || brandName.length > MAX_BRAND_NAME_LENGTH) {
+            throw new Error(`brandName is required and can have at most ${MAX_BRAND_NAME_LENGTH} characters`);
+        }
+        const logoHash = input.logoHash === undefined || input.logoHash === '' ? '' : input.logoHash;
+        if (typeof logoHash !== 'string' || (logoHash && !SHA256_HEX_PATTERN.test(logoHash))) {
+            throw new Error('logoHash must be the SHA-256 of the logo file in lowercase hex');
+        }
+        const logoUri = input.logoUri === undefined || input.logoUri === '' ? undefined : input.logoUri;
+        if (logoUri !== undefined && (typeof logoUri !== 'string' || !LOGO_URI_PATTERN.test(logoUri))) {
+            throw new Error('logoUri must be an https:// or ipfs:// address');
+        }
+        if (logoUri && !logoHash) {
+            throw new Error('A logoUri needs the logoHash of the file, so apps can check what they fetched');
+        }
+        const story = input.story === undefined ? '' : input.story;
+        if (typeof story !== 'string' || story.length > MAX_STORY_LENGTH) {
+            throw new Error(`story must be text of at most ${MAX_STORY_LENGTH} characters`);
+        }
+
+        const mspId = ctx.clientIdentity.getMSPID();
+        const branding: OrganizationBranding = {
+            docType: 'organizationBranding',
+            mspId,
+            brandName,
+            logoHash,
+            story: story.trim(),
+            updatedBy: getCallerFingerprint(ctx),
+            lastUpdated: getTxTimestamp(ctx)
+        };
+        if (logoUri) {
+            branding.logoUri = logoUri;
+        }
+        await writeDocument(ctx, `branding_${mspId}`, branding);
+        emitEvent(ctx, 'OrganizationBrandingChanged', branding);
+        return branding;
+    }
+
+    /**
+     * Get the brand registered by an organization
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('OrganizationBranding')
+    public async GetOrganizationBranding(ctx: Context, mspId: string): Promise<OrganizationBranding> {
+        const branding = await readDocument<OrganizationBranding>(ctx, `branding_${mspId}`);
+        if (!branding) {
+            throw new Error(`No branding is registered for ${mspId}`);
+        }
+        return branding;
+    }
+
+    /**
+     * Get the brands of all organizations
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('OrganizationBranding[]')
+    public async GetAllOrganizationBrandings(ctx: Context): Promise<OrganizationBranding[]> {
+        const iterator = await ctx.stub.getStateByRange('branding_', 'branding_\uffff');
+        const brandings: OrganizationBranding[] = [];
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                brandings.push(JSON.parse(result.value.value.toString()));
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+        return brandings;
+    }
+}
@@ -0,0 +1,124 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { Participant } from './types';
+import { readDocument, writeDocument, getTxTimestamp, emitEvent, isOrgAdmin } from './utils';
+
+/**
+ * Participant roles granted by the certificate authorities at onboarding
+ */
+export const PARTICIPANT_ROLES = ['farmer', 'tester', 'processor'];
+
+/**
+ * Certificate attribute carrying the participant role, set by the CA registrar with ecert=true
+ */
+export const ROLE_ATTRIBUTE = 'ricetrace.role';
+
+/**
+ * Registered participants by ID and by name, the two ways operators and owners are recorded
+ */
+export async function getParticipantsByIdAndName(ctx: Context): Promise<Map<string, Participant>> {
+    const participants = new Map<string, Participant>();
+    const iterator = await ctx.stub.getStateByRange('participant_', 'participant_\uffff');
+    let result = await iterator.next();
+    while (!result.done) {
+        try {
+            const participant: Participant = JSON.parse(result.value.value.toString());
+            if (participant.participantId) {
+                participants.set(participant.participantId, participant);
+                if (participant.name && !participants.has(participant.name)) {
+                    participants.set(participant.name, participant);
+                }
+            }
+        } catch (error) {
+            // Skip invalid data
+            console.warn(`Skipping invalid participant data: ${error}`);
+        }
+        result = await iterator.next();
+    }
+    await iterator.close();
+    return participants;
+}
+
+@Info({ title: 'ParticipantRegistryContract', description: 'Smart contract registering onboarded supply chain participants' })
+export class ParticipantRegistryContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "ParticipantRegistryContract Method Permission Configuration": {
+                "RegisterParticipant": ["Identity enrolled with the participant role", "Organization Administrators"],
+                "GetParticipant": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Register a participant of the caller's organization. An identity enrolled with the ricetrace.role attribute
+     * registers itself and must claim the role in its certificate; its enrollment ID is recorded. Organization
+     * administrators can register participants of any role on their behalf
+     * Permission: Identity carrying the participant role, or organization administrators
+     */
+    @Transaction()
+    @Returns('Participant')
+    public async RegisterParticipant(ctx: Context, participantId: string, name: string, role: string, location: string): Promise<Participant> {
+        if (!participantId || !participantId.trim()) {
+            throw new Error('Participant ID is required');
+        }
+        if (!name || !name.trim()) {
+            throw new Error('Participant name is required');
+        }
+        if (!PARTICIPANT_ROLES.includes(role)) {
+            throw new Error(`Invalid participant role ${role}: expected one of ${PARTICIPANT_ROLES.join(', ')}`);
+        }
+
+        const certifiedRole = ctx.clientIdentity.getAttributeValue(ROLE_ATTRIBUTE);
+        if (certifiedRole ? certifiedRole !== role : !isOrgAdmin(ctx)) {
+            throw new Error(certifiedRole
+                ? `Permission denied: The caller is enrolled as ${certifiedRole}, not ${role}`
+                : `Permission denied: Only an identity enrolled as ${role} or an organization administrator can register the participant`);
+        }
+
+        const key = `participant_${participantId.trim()}`;
+        if (await readDocument<Participant>(ctx, key)) {
+            throw new Error(`The participant ${participantId} already exists`);
+        }
+
+        const participant: Participant = {
+            docType: 'participant',
+            participantId: participantId.trim(),
+            name: name.trim(),
+            role,
+            mspId: ctx.clientIdentity.getMSPID(),
+            location: location || '',
+            enrollmentId: certifiedRole ? ctx.clientIdentity.getAttributeValue('hf.EnrollmentID') || '' : '',
+            registeredAt: getTxTimestamp(ctx)
+        };
+        await writeDocument(ctx, key, participant);
+        emitEvent(ctx, 'ParticipantRegistered', participant);
+        return participant;
+    }
+
+    /**
+     * Get a registered participant
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Participant')
+    public async GetParticipant(ctx: Context, participantId: string): Promise<Participant> {
+        const participant = await readDocument<Participant>(ctx, `participant_${participantId}`);
+        if (!participant) {
+            throw new Error(`The participant ${participantId} does not exist`);
+        }
+        return participant;
+    }
+}
@@ -0,0 +1,215 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { CommercialTermsCommitment, PrivateDataPurge, RetentionPolicy } from './types';
+import { QualityCertificationContract } from './qualityCertificationContract';
+import {
+    readDocument, writeDocument, patchDocument, emitEvent, getTxTimestamp, normalizeEndTimestamp, checkOrgAdmin, getCallerFingerprint,
+    assertPeerOrgMatchesClient, implicitCollectionName, isVisibleToCaller
+} from './utils';
+
+/**
+ * Kinds of private data an organization can set a retention period for; access audit entries are kept forever
+ */
+const RETENTION_DATA_TYPES = ['testReportDetails', 'commercialTerms'];
+
+/**
+ * Longest retention period, about a century
+ */
+const MAX_RETENTION_DAYS = 36500;
+
+/**
+ * Most records purged in one transaction; PurgePrivateData reports how many remain
+ */
+const MAX_PURGES_PER_TRANSACTION = 100;
+
+/**
+ * Private record due for purging and the public record that keeps its hash
+ */
+interface DueRecord {
+    id: string;
+    collection: string;
+    privateKey: string;
+    publicKey: string;
+    marker: Record<string, string>;
+}
+
+@Info({ title: 'PrivateDataRetentionContract', description: 'Smart contract purging private test report details and commercial terms after their retention period' })
+export class PrivateDataRetentionContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "PrivateDataRetentionContract Method Permission Configuration": {
+                "DefineRetentionPolicy": ["Organization Administrators (own organization)"],
+                "GetRetentionPolicies": ["All Organizations"],
+                "PurgePrivateData": ["Organization Administrators (own organization's data)"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Set how long the caller's organization keeps a kind of private data: testReportDetails (reports its labs
+     * shared with SetTestReportDetails) or commercialTerms (terms in its implicit collection)
+     * Permission: Only organization administrators can call, for their own organization
+     */
+    @Transaction()
+    public async DefineRetentionPolicy(ctx: Context, dataType: string, retentionDays: string): Promise<void> {
+        checkOrgAdmin(ctx);
+        this.assertDataType(dataType);
+        const days = Number(retentionDays);
+        if (!Number.isInteger(days) || days < 1 || days > MAX_RETENTION_DAYS) {
+            throw new Error(`Invalid retention period ${retentionDays}: must be a whole number of days from 1 to ${MAX_RETENTION_DAYS}`);
+        }
+
+        const mspId = ctx.clientIdentity.getMSPID();
+        const policy: RetentionPolicy = {
+            docType: 'retentionPolicy',
+            mspId,
+            dataType,
+            retentionDays: days,
+            definedBy: getCallerFingerprint(ctx),
+            lastUpdated: getTxTimestamp(ctx)
+        };
+        await writeDocument(ctx, `retention_${mspId}_${dataType}`, policy);
+        emitEvent(ctx, 'RetentionPolicyDefined', policy);
+    }
+
+    /**
+     * Get the retention policies of all organizations
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('RetentionPolicy[]')
+    public async GetRetentionPolicies(ctx: Context): Promise<RetentionPolicy[]> {
+        const iterator = await ctx.stub.getStateByRange('retention_', 'retention_\uffff');
+        const policies: RetentionPolicy[] = [];
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                policies.push(JSON.parse(result.value.value.toString()));
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+        return policies;
+    }
+
+    /**
+     * Purge the caller's organization's private data of a kind last written at or before a cutoff: olderThan
+     * (a bare date includes the whole day) or, when empty, the start of the retention period of its policy.
+     * The private data is removed from the peers with its history; the hashes on the public ledger remain and
+     * the purge is recorded on the test result or terms commitment. At most 100 records are purged per
+     * transaction; call again while remaining is above zero. Must be endorsed by a peer of the caller's organization
+     * Permission: Only organization administrators can call, for their own organization's data
+     */
+    @Transaction()
+    @Returns('PrivateDataPurge')
+    public async PurgePrivateData(ctx: Context, dataType: string, olderThan: string): Promise<PrivateDataPurge> {
+        checkOrgAdmin(ctx);
+        assertPeerOrgMatchesClient(ctx);
+        this.assertDataType(dataType);
+
+        const mspId = ctx.clientIdentity.getMSPID();
+        const now = getTxTimestamp(ctx);
+        let cutoff: string;
+        if (olderThan) {
+            cutoff = normalizeEndTimestamp(olderThan, 'olderThan');
+        } else {
+            const policy = await readDocument<RetentionPolicy>(ctx, `retention_${mspId}_${dataType}`);
+            if (!policy) {
+                throw new Error(`${mspId} has no retention policy for ${dataType}; define one or pass olderThan`);
+            }
+            cutoff = new Date(Date.parse(now) - policy.retentionDays * 24 * 60 * 60 * 1000).toISOString();
+        }
+        if (cutoff > now) {
+            throw new Error(`olderThan ${olderThan} is in the future`);
+        }
+
+        const due = dataType === 'testReportDetails'
+            ? await this.dueTestReportDetails(ctx, mspId, cutoff)
+            : await this.dueCommercialTerms(ctx, mspId, cutoff);
+        const purged: string[] = [];
+        for (const record of due.slice(0, MAX_PURGES_PER_TRANSACTION)) {
+            await ctx.stub.purgePrivateData(record.collection, record.privateKey);
+            await patchDocument(ctx, record.publicKey, record.marker);
+            purged.push(record.id);
+        }
+
+        const purge: PrivateDataPurge = {
+            mspId,
+            dataType,
+            cutoff,
+            purged,
+            remaining: due.length - purged.length,
+            purgedAt: now
+        };
+        if (purged.length > 0) {
+            emitEvent(ctx, 'PrivateDataPurged', purge);
+        }
+        return purge;
+    }
+
+    /**
+     * Report details shared by the organization's labs, not purged yet, recorded at or before the cutoff
+     */
+    private async dueTestReportDetails(ctx: Context, mspId: string, cutoff: string): Promise<DueRecord[]> {
+        const now = getTxTimestamp(ctx);
+        return (await new QualityCertificationContract().GetAllTestResults(ctx))
+            .filter(test => test.signerMspId === mspId && !!test.reportDetailsCollection && !test.reportDetailsPurgedAt &&
+                (test.reportDetailsAt || '') <= cutoff)
+            .map(test => ({
+                id: test.testId,
+                collection: test.reportDetailsCollection as string,
+                privateKey: `report_${test.testId}`,
+                publicKey: `test_${test.testId}`,
+                marker: { reportDetailsPurgedAt: now }
+            }));
+    }
+
+    /**
+     * Commercial terms of the organization, not purged yet, last updated at or before the cutoff
+     */
+    private async dueCommercialTerms(ctx: Context, mspId: string, cutoff: string): Promise<DueRecord[]> {
+        const now = getTxTimestamp(ctx);
+        const due: DueRecord[] = [];
+        const iterator = await ctx.stub.getStateByRange('terms_', 'terms_\uffff');
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                const commitment: CommercialTermsCommitment = JSON.parse(result.value.value.toString());
+                if (commitment.docType === 'commercialTermsCommitment' && commitment.mspId === mspId && !commitment.purgedAt &&
+                    commitment.updatedAt <= cutoff && isVisibleToCaller(ctx, commitment)) {
+                    due.push({
+                        id: commitment.batchId,
+                        collection: implicitCollectionName(mspId),
+                        privateKey: `terms_${commitment.batchId}`,
+                        publicKey: result.value.key,
+                        marker: { purgedAt: now }
+                    });
+                }
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+        return due;
+    }
+
+    /**
+     * Reject kinds of private data without a retention period
+     */
+    private assertDataType(dataType: string): void {
+        if (!RETENTION_DATA_TYPES.includes(dataType)) {
+            throw new Error(`Invalid data type: ${dataType}. Allowed values: ${RETENTION_DATA_TYPES.join(', ')}`);
+        }
+    }
+}
@@ -0,0 +1,178 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { ProcessingWorkflow, RiceBatch, WorkflowStep } from './types';
+import { readDocument, writeDocument, checkOrgAdmin } from './utils';
+
+/**
+ * Steps of the workflow a batch follows: the definition snapshotted when the batch was created,
+ * or the current definition for batches created before snapshots were taken
+ */
+export async function getBatchWorkflowSteps(ctx: Context, batch: RiceBatch): Promise<WorkflowStep[] | undefined> {
+    if (!batch.workflowId) {
+        return undefined;
+    }
+    if (batch.workflowSteps) {
+        return batch.workflowSteps;
+    }
+    const workflow = await readDocument<ProcessingWorkflow>(ctx, `workflow_${batch.workflowId}`);
+    return workflow ? workflow.steps : undefined;
+}
+
+@Info({ title: 'ProcessingWorkflowContract', description: 'Smart contract for admin-configurable processing workflow definitions' })
+export class ProcessingWorkflowContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "ProcessingWorkflowContract Method Permission Configuration": {
+                "DefineWorkflow": ["Organization Administrators (redefinition: defining organization only)"],
+                "ReadWorkflow": ["All Organizations"],
+                "WorkflowExists": ["All Organizations"],
+                "GetAllWorkflows": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Create or update a named workflow definition
+     * workflowJSON: { name, description, steps: [{ name, optional, requiredTests }] }
+     * Only the defining organization can redefine it; batches already created keep the steps they were created with
+     * Permission: Only organization administrators can call; redefinitions only by the defining organization
+     */
+    @Transaction()
+    public async DefineWorkflow(ctx: Context, workflowId: string, workflowJSON: string): Promise<void> {
+        checkOrgAdmin(ctx);
+
+        if (!workflowId) {
+            throw new Error('Workflow ID is required');
+        }
+
+        let definition: { name?: string; description?: string; steps?: Partial<WorkflowStep>[] };
+        try {
+            definition = JSON.parse(workflowJSON);
+        } catch (error) {
+            throw new Error(`Workflow format error: ${error}`);
+        }
+
+        const steps = this.validateSteps(definition.steps);
+
+        // Get transaction timestamp
+        const txTimestamp = ctx.stub.getTxTimestamp();
+        const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
+
+        const existing = await readDocument<ProcessingWorkflow>(ctx, `workflow_${workflowId}`);
+        const mspId = ctx.clientIdentity.getMSPID();
+        if (existing && existing.definedBy !== mspId) {
+            throw new Error(`Permission denied: Workflow ${workflowId} was defined by ${existing.definedBy}; ${mspId} cannot redefine it`);
+        }
+
+        const workflow: ProcessingWorkflow = {
+            docType: 'processingWorkflow',
+            workflowId,
+            name: definition.name || workflowId,
+            description: definition.description || '',
+            steps,
+            version: existing ? existing.version + 1 : 1,
+            definedBy: mspId,
+            createdTimestamp: existing ? existing.createdTimestamp : now,
+            lastUpdated: now
+        };
+
+        await writeDocument(ctx, `workflow_${workflowId}`, workflow);
+    }
+
+    /**
+     * Read workflow definition
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ProcessingWorkflow')
+    public async ReadWorkflow(ctx: Context, workflowId: string): Promise<ProcessingWorkflow> {
+        const workflow = await readDocument<ProcessingWorkflow>(ctx, `workflow_${workflowId}`);
+        if (!workflow) {
+            throw new Error(`Workflow ${workflowId} does not exist`);
+        }
+        return workflow;
+    }
+
+    /**
+     * Check if workflow exists
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    public async WorkflowExists(ctx: Context, workflowId: string): Promise<boolean> {
+        const workflowJSON = await ctx.stub.getState(`workflow_${workflowId}`);
+        return workflowJSON && workflowJSON.length > 0;
+    }
+
+    /**
+     * Get all workflow definitions
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ProcessingWorkflow[]')
+    public async GetAllWorkflows(ctx: Context): Promise<ProcessingWorkflow[]> {
+        const resultsIterator = await ctx.stub.getStateByRange('workflow_', 'workflow_\uffff');
+        const workflows: ProcessingWorkflow[] = [];
+
+        let result = await resultsIterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                try {
+                    const workflow: ProcessingWorkflow = JSON.parse(result.value.value.toString());
+                    if (workflow.workflowId) {
+                        workflows.push(workflow);
+                    }
+                } catch (error) {
+                    // Skip invalid data
+                    console.warn(`Skipping invalid workflow data: ${error}`);
+                }
+            }
+            result = await resultsIterator.next();
+        }
+
+        await resultsIterator.close();
+        return workflows;
+    }
+
+    /**
+     * Validate and normalize workflow steps
+     */
+    private validateSteps(steps: Partial<WorkflowStep>[] | undefined): WorkflowStep[] {
+        if (!Array.isArray(steps) || steps.length === 0) {
+            throw new Error('Workflow must define at least one step');
+        }
+
+        const seen = new Set<string>();
+        return steps.map((step, index) => {
+            if (!step || typeof step.name !== 'string' || step.name.trim() === '') {
+                throw new Error(`Workflow step ${index} must have a name`);
+            }
+            if (seen.has(step.name)) {
+                throw new Error(`Workflow step ${step.name} is defined more than once`);
+            }
+            seen.add(step.name);
+
+            const requiredTests = step.requiredTests || [];
+            if (!Array.isArray(requiredTests) || requiredTests.some(test => typeof test !== 'string' || test === '')) {
+                throw new Error(`Workflow step ${step.name} has invalid requiredTests`);
+            }
+
+            return {
+                name: step.name,
+                optional: step.optional === true,
+                requiredTests
+            };
+        });
+    }
+}
@@ -5,45 +5,118 @@
 import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
 import stringify from 'json-stringify-deterministic';
 import sortKeysRecursive from 'sort-keys-recursive';
-import { Product, ProductWithBatch, OrganizationType, OrganizationInfo } from './types';
+import {
+    Product, ProductWithBatch, ProductQueryResult, ProductTransfer, ProductReinspection, OrganizationType, OrganizationInfo, NutritionFacts, ProductComposition,
+    ProductOriginComposition, RiceBatch
+} from './types';
+import {
+    normalizeTimestamp, normalizeEndTimestamp, assertNotBefore, readDocument, patchDocument, putIndexEntry, deleteIndexEntry, checkOrgAdmin,
+    createDisposal, DISPOSED_STATE, emitEvent, isProcessedRequest, markRequestProcessed, setKeyEndorsers, parseLabels, updateLabelIndex,
+    getLabeledIds, getTxTimestamp, isVisibleToCaller, checkOrgType, getOrganizationType, getCallerFingerprint
+} from './utils';
+import { withArchivedHistory } from './batchStorageContract';
+import { assertIdConforms } from './identifierPolicyContract';
+import { readInputLimits } from './inputLimitContract';
+import { refreshBatchStatus } from './batchStatusContract';
+
+/**
+ * Composite key index of products by current owner
+ */
+export const OWNER_INDEX = 'owner~productId';
+
+/**
+ * Composite key index of products by best-before date
+ */
+export const BEST_BEFORE_INDEX = 'bestBefore~productId';
+
+/**
+ * Composite key index of products by label key and value
+ */
+export const PRODUCT_LABEL_INDEX = 'productLabel~value~productId';
+
+/**
+ * Composite key indexes of products by status, source batch and package date, used by QueryProducts
+ */
+export const PRODUCT_STATUS_INDEX = 'productStatus~productId';
+export const PRODUCT_BATCH_INDEX = 'productBatch~productId';
+export const PACKAGE_DATE_INDEX = 'packageDate~productId';
+
+/**
+ * Product statuses QueryProducts can select; Expired is derived from the best-before date
+ */
+const PRODUCT_QUERY_STATUSES = ['Active', 'Sold', 'Returned', DISPOSED_STATE, 'Expired'];
+
+/**
+ * Maximum number of index entries read per page of a product query
+ */
+const MAX_PRODUCT_PAGE_SIZE = 1000;
+
+/**
+ * Environment variable that adds the source batch's originating organization to every product's endorsers
+ * Must be set identically on all peers, as it changes the endorsement policy written by transactions
+ */
+const ORIGINATOR_ENDORSEMENT_FLAG = 'RICETRACE_PRODUCT_ORIGINATOR_ENDORSEMENT';
+
+/**
+ * Outcomes of a product re-inspection; only a passed re-inspection allows resale
+ */
+const REINSPECTION_OUTCOMES = ['Passed', 'Failed'];
+
+/**
+ * Energy conversion factors (kJ per gram) used to check declared energy against the macronutrients (GB 28050)
+ */
+const ENERGY_FACTORS_KJ = { protein: 17, carbohydrate: 17, fat: 37, fiber: 8 };
+
+/**
+ * Allowed deviation of declared energy from the energy computed from macronutrients
+ * (relative, with an absolute floor for low-energy products)
+ */
+const ENERGY_TOLERANCE = 0.2;
+const ENERGY_TOLERANCE_MIN_KJ = 40;
+
+/**
+ * Upper bound for sodium per 100 g (pure salt is ~39.3 g sodium per 100 g)
+ */
+const MAX_SODIUM_MG = 40000;
 
 @Info({ title: 'ProductManagementContract', description: 'Smart contract for product management operations' })
 export class ProductManagementContract extends Contract {
 
     /**
-     * Get organization type based on MSP ID
-     * Can be configured based on actual organization structure
+     * Apply the product's key-level endorsement policy: only the owning organization can endorse updates,
+     * together with the source batch's originating organization when RICETRACE_PRODUCT_ORIGINATOR_ENDORSEMENT=true
+     * Products created before owning organizations were recorded keep the chaincode-level policy
      */
-    private getOrganizationType(mspId: string): OrganizationType {
-        // Map MSP ID to organization type
-        // Can be modified based on actual organization structure
-        const mspToOrgType: Record<string, OrganizationType> = {
-            'Org1MSP': OrganizationType.FARM,              // Farm organization
-            'Org2MSP': OrganizationType.MIDDLEMAN_TESTER,  // Middleman/tester organization
-            'Org3MSP': OrganizationType.CONSUMER           // Consumer organization
-        };
+    private async setProductEndorsers(ctx: Context, product: Product): Promise<void> {
+        if (!product.ownerMspId) {
+            return;
+        }
 
-        return mspToOrgType[mspId] || OrganizationType.CONSUMER;
+        const endorsers = [product.ownerMspId];
+        if (process.env[ORIGINATOR_ENDORSEMENT_FLAG] === 'true' && product.originMspId) {
+            endorsers.push(product.originMspId);
+        }
+        await setKeyEndorsers(ctx, `product_${product.productId}`, endorsers);
     }
 
     /**
-     * Check if the caller has permission to perform specific operations
+     * Check that the caller's organization holds the product
      */
-    private checkPermission(ctx: Context, allowedTypes: OrganizationType[]): void {
+    private checkProductOwner(ctx: Context, product: Product, action: string): void {
         const mspId = ctx.clientIdentity.getMSPID();
-        const callerType = this.getOrganizationType(mspId);
-
-        if (!allowedTypes.includes(callerType)) {
-            const allowedNames = allowedTypes.map(type => {
-                switch (type) {
-                    case OrganizationType.FARM: return 'Farm';
-                    case OrganizationType.MIDDLEMAN_TESTER: return 'Middleman/tester';
-                    case OrganizationType.CONSUMER: return 'Consumer';
-                    default: return 'Unknown';
-                }
-            }).join(', ');
-            
-            throw new Error(`Permission denied: Only the following organization types can call: ${allowedNames}`);
+        if (product.ownerMspId !== mspId) {
+            throw new Error(`Permission denied: Product ${product.productId} is held by ${product.ownerMspId || 'an unknown organization'}; ${mspId} cannot ${action}`);
+        }
+    }
+
+    /**
+     * Check that a transfer is not backdated before the previous sale or return of the product
+     */
+    private assertTransferChronology(product: Product, now: string): void {
+        const transfers = product.transfers || [];
+        const previous = transfers[transfers.length - 1];
+        if (previous) {
+            assertNotBefore(now, 'Transfer time', previous.timestamp, `previous ${previous.type} of product ${product.productId}`);
         }
     }
 
@@ -54,7 +127,7 @@ export class ProductManagementContract extends Contract {
     @Returns('OrganizationInfo')
     public async GetCallerInfo(ctx: Context): Promise<OrganizationInfo> {
         const mspId = ctx.clientIdentity.getMSPID();
-        const orgType = this.getOrganizationType(mspId);
+        const orgType = getOrganizationType(mspId);
         
         return {
             orgId: mspId,
@@ -72,8 +145,20 @@ export class ProductManagementContract extends Contract {
         const permissionMatrix = {
             "ProductManagementContract Method Permission Configuration": {
                 "CreateProduct": ["Middleman/Tester"],
+                "TransferProduct": ["Middleman/Tester"],
+                "ReturnProduct": ["Middleman/Tester", "Consumer"],
+                "ClearReinspection": ["Middleman/Tester"],
+                "SetProductNutrition": ["Middleman/Tester"],
+                "SetProductLabels": ["Farm", "Middleman/Tester"],
+                "DisposeProduct": ["Middleman/Tester", "Consumer"],
                 "ReadProduct": ["All Organizations"],
                 "GetAllProducts": ["All Organizations"],
+                "GetProductsByOwner": ["All Organizations"],
+                "GetProductsByLabel": ["All Organizations"],
+                "GetProductComposition": ["All Organizations"],
+                "QueryProducts": ["All Organizations"],
+                "RebuildOwnerIndex": ["Organization Administrators"],
+                "RebuildProductQueryIndexes": ["Organization Administrators"],
                 "ProductExists": ["All Organizations"],
                 "GetCallerInfo": ["All Organizations"],
                 "GetPermissionMatrix": ["All Organizations"]
@@ -90,6 +175,7 @@ export class ProductManagementContract extends Contract {
 
     /**
      * Create product
+     * clientRequestId: idempotency key - a retry with the same ID is not applied twice (empty to disable)
      * Permission: Only middleman/tester can call
      */
     @Transaction()
@@ -98,15 +184,22 @@ export class ProductManagementContract extends Contract {
         productId: string,
         batchId: string,
         packageDate: string,
-        owner: string
+        owner: string,
+        clientRequestId: string
     ): Promise<void> {
         // Check permission: Only middleman can create final product
-        this.checkPermission(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
+
+        // A gateway retry of an already processed request is a no-op
+        if (await isProcessedRequest(ctx, clientRequestId, 'CreateProduct')) {
+            return;
+        }
 
         const existingProduct = await ctx.stub.getState(`product_${productId}`);
         if (existingProduct && existingProduct.length > 0) {
             throw new Error(`Product ${productId} already exists`);
         }
+        await assertIdConforms(ctx, 'product', productId);
 
         // Check if batch exists (this would require cross-contract call in a real scenario)
         // For now, we'll assume the batch exists
@@ -115,18 +208,296 @@ export class ProductManagementContract extends Contract {
             throw new Error(`Batch ${batchId} does not exist`);
         }
 
+        // Rice cannot be packaged before it was harvested
+        const normalizedPackageDate = normalizeTimestamp(packageDate, 'packageDate');
+        const batch = await withArchivedHistory(ctx, await this.GetBatchInfo(ctx, batchId));
+        assertNotBefore(normalizedPackageDate, 'packageDate', batch.harvestDate, `harvestDate of batch ${batchId}`);
+
         const product: Product = {
             docType: 'product',
             productId,
             batchId,
-            packageDate,
-            owner
+            packageDate: normalizedPackageDate,
+            owner,
+            ownerMspId: ctx.clientIdentity.getMSPID(),
+            originMspId: batch.history && batch.history[0] ? batch.history[0].signerMspId || '' : '',
+            packerMspId: ctx.clientIdentity.getMSPID(),
+            status: 'Active',
+            transfers: []
         };
+        // Products belong to the tenant of their source batch
+        if (batch.tenantId) {
+            product.tenantId = batch.tenantId;
+        }
 
         await ctx.stub.putState(
             `product_${productId}`,
             Buffer.from(stringify(sortKeysRecursive(product)))
         );
+        await this.setProductEndorsers(ctx, product);
+        await putIndexEntry(ctx, OWNER_INDEX, [owner, productId]);
+        await this.indexProductForQueries(ctx, product);
+        await refreshBatchStatus(ctx, batchId, { products: [product] });
+        await markRequestProcessed(ctx, clientRequestId, 'CreateProduct');
+        emitEvent(ctx, 'ProductCreated', product);
+    }
+
+    /**
+     * Sell/transfer a product to a new owner
+     * newOwnerMspId: organization of the new owner, which endorses later updates (empty to keep the current organization)
+     * clientRequestId: idempotency key - a retry with the same ID is not applied twice (empty to disable)
+     * Permission: Only middleman/tester can call
+     */
+    @Transaction()
+    public async TransferProduct(
+        ctx: Context,
+        productId: string,
+        newOwner: string,
+        newOwnerMspId: string,
+        clientRequestId: string
+    ): Promise<void> {
+        // Check permission: Only middleman/tester (distributors) can sell products
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
+
+        // A gateway retry of an already processed request is a no-op
+        if (await isProcessedRequest(ctx, clientRequestId, 'TransferProduct')) {
+            return;
+        }
+
+        if (!newOwner) {
+            throw new Error('New owner is required');
+        }
+
+        const product = await this.readProductDocument(ctx, productId);
+        if (product.requiresReinspection) {
+            throw new Error(`Product ${productId} was returned and must be re-inspected before it can be resold`);
+        }
+        if (product.owner === newOwner) {
+            throw new Error(`Product ${productId} is already owned by ${newOwner}`);
+        }
+
+        // Get transaction timestamp
+        const txTimestamp = ctx.stub.getTxTimestamp();
+        const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
+        this.assertTransferChronology(product, now);
+
+        const toMspId = newOwnerMspId || product.ownerMspId;
+        const transfer: ProductTransfer = {
+            timestamp: now,
+            from: product.owner,
+            to: newOwner,
+            type: 'Sale',
+            fromMspId: product.ownerMspId,
+            toMspId,
+            signerFingerprint: getCallerFingerprint(ctx)
+        };
+        const updated = await patchDocument<Product>(ctx, `product_${productId}`, {
+            owner: newOwner,
+            ownerMspId: toMspId,
+            status: 'Sold',
+            transfers: [...(product.transfers || []), transfer]
+        });
+        await this.setProductEndorsers(ctx, updated);
+
+        await deleteIndexEntry(ctx, OWNER_INDEX, [product.owner, productId]);
+        await putIndexEntry(ctx, OWNER_INDEX, [newOwner, productId]);
+        await this.moveStatusIndexEntry(ctx, product, 'Sold');
+        await refreshBatchStatus(ctx, product.batchId, { products: [updated] });
+        await markRequestProcessed(ctx, clientRequestId, 'TransferProduct');
+        emitEvent(ctx, 'ProductTransferred', updated);
+    }
+
+    /**
+     * Return a sold product to the distributor that sold it, with a documented reason
+     * requireReinspection: when true, the product cannot be resold until ClearReinspection is called
+     * clientRequestId: idempotency key - a retry with the same ID is not applied twice (empty to disable)
+     * Permission: Middleman/tester and consumer can call, for products their organization holds
+     */
+    @Transaction()
+    public async ReturnProduct(ctx: Context, productId: string, reason: string, requireReinspection: boolean, clientRequestId: string): Promise<void> {
+        // Check permission: Middleman/tester and consumer (retail) organizations can return products
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER, OrganizationType.CONSUMER]);
+
+        // A gateway retry of an already processed request is a no-op
+        if (await isProcessedRequest(ctx, clientRequestId, 'ReturnProduct')) {
+            return;
+        }
+
+        if (!reason) {
+            throw new Error('Return reason is required');
+        }
+
+        const product = await this.readProductDocument(ctx, productId);
+        this.checkProductOwner(ctx, product, 'return it');
+        const transfers = product.transfers || [];
+        const lastSale = [...transfers].reverse().find(transfer => transfer.type === 'Sale' && transfer.to === product.owner);
+        if (!lastSale) {
+            throw new Error(`Product ${productId} has not been sold to its current owner ${product.owner} and cannot be returned`);
+        }
+
+        // Get transaction timestamp
+        const txTimestamp = ctx.stub.getTxTimestamp();
+        const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
+        this.assertTransferChronology(product, now);
+
+        const toMspId = lastSale.fromMspId || product.ownerMspId;
+        const returnRecord: ProductTransfer = {
+            timestamp: now,
+            from: product.owner,
+            to: lastSale.from,
+            type: 'Return',
+            reason,
+            fromMspId: product.ownerMspId,
+            toMspId,
+            signerFingerprint: getCallerFingerprint(ctx)
+        };
+        const updated = await patchDocument<Product>(ctx, `product_${productId}`, {
+            owner: lastSale.from,
+            ownerMspId: toMspId,
+            status: 'Returned',
+            transfers: [...transfers, returnRecord],
+            requiresReinspection: String(requireReinspection) === 'true'
+        });
+        await this.setProductEndorsers(ctx, updated);
+
+        await deleteIndexEntry(ctx, OWNER_INDEX, [product.owner, productId]);
+        await putIndexEntry(ctx, OWNER_INDEX, [lastSale.from, productId]);
+        await this.moveStatusIndexEntry(ctx, product, 'Returned');
+        await refreshBatchStatus(ctx, product.batchId, { products: [updated] });
+        await markRequestProcessed(ctx, clientRequestId, 'ReturnProduct');
+        emitEvent(ctx, 'ProductReturned', updated);
+    }
+
+    /**
+     * Record the re-inspection of a returned product
+     * outcome: Passed clears the re-inspection flag so the product can be resold; Failed keeps it blocked
+     * notes: optional findings of the inspection
+     * The inspecting organization and identity are recorded with the outcome
+     * Permission: Only middleman/tester can call, for products their organization holds
+     */
+    @Transaction()
+    public async ClearReinspection(ctx: Context, productId: string, outcome: string, notes: string): Promise<void> {
+        // Check permission: Only middleman/tester can re-inspect products
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!REINSPECTION_OUTCOMES.includes(outcome)) {
+            throw new Error(`Invalid re-inspection outcome: ${outcome}, must be one of ${REINSPECTION_OUTCOMES.join(', ')}`);
+        }
+
+        const product = await this.readProductDocument(ctx, productId);
+        this.checkProductOwner(ctx, product, 're-inspect it');
+        if (!product.requiresReinspection) {
+            throw new Error(`Product ${productId} is not awaiting re-inspection`);
+        }
+
+        const reinspection: ProductReinspection = {
+            timestamp: getTxTimestamp(ctx),
+            outcome,
+            inspectedByMspId: ctx.clientIdentity.getMSPID(),
+            // getID() returns "x509::<subject DN>::<issuer DN>"
+            inspectedBySubject: ctx.clientIdentity.getID().split('::')[1] || ''
+        };
+        if (notes) {
+            reinspection.notes = notes;
+        }
+        const updated = await patchDocument<Product>(ctx, `product_${productId}`, {
+            requiresReinspection: outcome !== 'Passed',
+            reinspections: [...(product.reinspections || []), reinspection]
+        });
+        emitEvent(ctx, 'ProductReinspected', updated);
+    }
+
+    /**
+     * Set the label nutrition facts and/or composition of a product
+     * nutritionJSON: NutritionFacts per 100 g ({ energyKj, proteinG, carbohydrateG, fatG?, fiberG?, sodiumMg? })
+     * compositionJSON: ProductComposition ({ ingredients, allergens?, netWeightG?, grade?, bestBefore? })
+     * Either may be empty to leave it unchanged. Values are checked for plausibility: macronutrients cannot
+     * exceed 100 g and the declared energy must match the macronutrients within 20%
+     * Permission: Only middleman/tester can call
+     */
+    @Transaction()
+    public async SetProductNutrition(ctx: Context, productId: string, nutritionJSON: string, compositionJSON: string): Promise<void> {
+        // Check permission: Only middleman/tester packages products and writes their labels
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!nutritionJSON && !compositionJSON) {
+            throw new Error('Nutrition facts or composition is required');
+        }
+        const product = await this.readProductDocument(ctx, productId);
+
+        const patch: Partial<Product> = {};
+        if (nutritionJSON) {
+            patch.nutrition = this.parseNutritionFacts(nutritionJSON);
+        }
+        if (compositionJSON) {
+            patch.composition = this.parseComposition(compositionJSON);
+
+            // Keep the best-before index in step with the label
+            const previousBestBefore = product.composition?.bestBefore;
+            if (previousBestBefore !== patch.composition.bestBefore) {
+                if (previousBestBefore) {
+                    await deleteIndexEntry(ctx, BEST_BEFORE_INDEX, [previousBestBefore, productId]);
+                }
+                if (patch.composition.bestBefore) {
+                    await putIndexEntry(ctx, BEST_BEFORE_INDEX, [patch.composition.bestBefore, productId]);
+                }
+            }
+        }
+
+        const updated = await patchDocument<Product>(ctx, `product_${productId}`, patch);
+        emitEvent(ctx, 'ProductLabelUpdated', updated);
+    }
+
+    /**
+     * Replace the labels of a product, e.g. { "export-market": "JP", "retail-sku": "DHX-5KG" }
+     * labelsJSON is an object of at most 20 string labels; an empty object removes all labels
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async SetProductLabels(ctx: Context, productId: string, labelsJSON: string): Promise<void> {
+        // Check permission: Supply chain organizations attach their own metadata
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        const product = await this.readProductDocument(ctx, productId);
+        const labels = parseLabels(labelsJSON, (await readInputLimits(ctx)).maxLabels);
+
+        const updated = await patchDocument<Product>(ctx, `product_${productId}`, { labels });
+        await updateLabelIndex(ctx, PRODUCT_LABEL_INDEX, productId, product.labels, labels);
+        emitEvent(ctx, 'ProductLabelsChanged', updated);
+    }
+
+    /**
+     * Dispose of a product (spoiled, recalled, ...), moving it to the terminal Disposed state
+     * Disposed products leave the owner's inventory
+     * Permission: Middleman/tester and consumer can call
+     */
+    @Transaction()
+    public async DisposeProduct(
+        ctx: Context,
+        productId: string,
+        reason: string,
+        method: string,
+        quantity: string,
+        handler: string
+    ): Promise<void> {
+        // Check permission: Middleman/tester and consumer (retail) organizations hold products
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER, OrganizationType.CONSUMER]);
+
+        const product = await this.readProductDocument(ctx, productId);
+        const disposal = createDisposal(ctx, reason, method, quantity, handler);
+
+        const updated = await patchDocument<Product>(ctx, `product_${productId}`, {
+            status: DISPOSED_STATE,
+            disposal
+        });
+
+        await deleteIndexEntry(ctx, OWNER_INDEX, [product.owner, productId]);
+        if (product.composition?.bestBefore) {
+            await deleteIndexEntry(ctx, BEST_BEFORE_INDEX, [product.composition.bestBefore, productId]);
+        }
+        await this.moveStatusIndexEntry(ctx, product, DISPOSED_STATE);
+        await refreshBatchStatus(ctx, product.batchId, { products: [updated] });
+        emitEvent(ctx, 'ProductDisposed', updated);
     }
 
     /**
@@ -136,12 +507,10 @@ export class ProductManagementContract extends Contract {
     @Transaction(false)
     @Returns('ProductWithBatch')
     public async ReadProduct(ctx: Context, productId: string): Promise<ProductWithBatch> {
-        const productJSON = await ctx.stub.getState(`product_${productId}`);
-        if (!productJSON || productJSON.length === 0) {
+        const product = await readDocument<Product>(ctx, `product_${productId}`);
+        if (!product) {
             throw new Error(`Product ${productId} does not exist`);
         }
-
-        const product: Product = JSON.parse(productJSON.toString());
         
         // Get batch information (this would require cross-contract call in a real scenario)
         // For now, we'll create a mock batch object
@@ -154,8 +523,8 @@ export class ProductManagementContract extends Contract {
     }
 
     /**
-     * Get all products
-     * Permission: No restriction
+     * Get all products visible to the caller
+     * Permission: No restriction; identities of a tenant only see the products of their tenant and shared products
      */
     @Transaction(false)
     @Returns('Product[]')
@@ -168,7 +537,7 @@ export class ProductManagementContract extends Contract {
             if (result.value && result.value.value.toString()) {
                 try {
                     const product: Product = JSON.parse(result.value.value.toString());
-                    if (product.productId) {
+                    if (product.productId && isVisibleToCaller(ctx, product)) {
                         products.push(product);
                     }
                 } catch (error) {
@@ -183,8 +552,223 @@ export class ProductManagementContract extends Contract {
         return products;
     }
 
+    /**
+     * Get products currently held by an owner, one page at a time
+     * pageSize: maximum number of products per page; bookmark: empty for the first page
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ProductQueryResult')
+    public async GetProductsByOwner(ctx: Context, owner: string, pageSize: number, bookmark: string): Promise<ProductQueryResult> {
+        if (!owner) {
+            throw new Error('Owner is required');
+        }
+        const size = Number(pageSize);
+        if (!Number.isInteger(size) || size <= 0 || size > 1000) {
+            throw new Error(`Invalid page size ${pageSize}: must be an integer between 1 and 1000`);
+        }
+
+        const { iterator, metadata } = await ctx.stub.getStateByPartialCompositeKeyWithPagination(OWNER_INDEX, [owner], size, bookmark || '');
+        const products: Product[] = [];
+
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value) {
+                const [, productId] = ctx.stub.splitCompositeKey(result.value.key).attributes;
+                const product = await readDocument<Product>(ctx, `product_${productId}`);
+                // Guard against stale index entries
+                if (product && product.owner === owner) {
+                    products.push(product);
+                }
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return {
+            products,
+            fetchedRecordsCount: metadata.fetchedRecordsCount,
+            bookmark: metadata.bookmark
+        };
+    }
+
+    /**
+     * Query products by any combination of selectors, one page at a time
+     * selectorJSON: { owner, batchId, status, packageDateFrom, packageDateTo }, all optional. status is Active, Sold,
+     * Returned, Disposed or Expired (not disposed, best-before date passed). owner matches the products an owner
+     * currently holds, so it never matches disposed products. The most selective index is walked (batch, owner, status,
+     * best-before for Expired, else package date) and the other selectors filter the records it points to, so a page
+     * reads at most pageSize index entries and can hold fewer products while more pages follow
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ProductQueryResult')
+    public async QueryProducts(ctx: Context, selectorJSON: string, pageSize: number, bookmark: string): Promise<ProductQueryResult> {
+        let input: any;
+        try {
+            input = JSON.parse(selectorJSON || '{}');
+        } catch (error) {
+            throw new Error(`Product selector format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('Product selector must be an object');
+        }
+        const unexpected = Object.keys(input).filter(key => !['owner', 'batchId', 'status', 'packageDateFrom', 'packageDateTo'].includes(key));
+        if (unexpected.length > 0) {
+            throw new Error(`Unknown product selector(s): ${unexpected.join(', ')}`);
+        }
+
+        const owner = input.owner ? String(input.owner) : '';
+        const batchId = input.batchId ? String(input.batchId) : '';
+        const status = input.status ? PRODUCT_QUERY_STATUSES.find(candidate => candidate.toLowerCase() === String(input.status).toLowerCase()) : '';
+        if (status === undefined) {
+            throw new Error(`Invalid product status ${input.status}, expected one of: ${PRODUCT_QUERY_STATUSES.join(', ')}`);
+        }
+        const from = input.packageDateFrom ? normalizeTimestamp(String(input.packageDateFrom), 'packageDateFrom') : '';
+        const to = input.packageDateTo ? normalizeEndTimestamp(String(input.packageDateTo), 'packageDateTo') : '';
+        if (from && to && from > to) {
+            throw new Error('packageDateFrom must not be after packageDateTo');
+        }
+        const size = Number(pageSize);
+        if (!Number.isInteger(size) || size <= 0 || size > MAX_PRODUCT_PAGE_SIZE) {
+            throw new Error(`Invalid page size ${pageSize}: must be an integer between 1 and ${MAX_PRODUCT_PAGE_SIZE}`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const [indexName, partialKey]: [string, string[]] = batchId ? [PRODUCT_BATCH_INDEX, [batchId]]
+            : owner ? [OWNER_INDEX, [owner]]
+                : status === 'Expired' ? [BEST_BEFORE_INDEX, []]
+                    : status ? [PRODUCT_STATUS_INDEX, [status]]
+                        : [PACKAGE_DATE_INDEX, []];
+
+        const { iterator, metadata } = await ctx.stub.getStateByPartialCompositeKeyWithPagination(indexName, partialKey, size, bookmark || '');
+        const products: Product[] = [];
+
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value) {
+                const { attributes } = ctx.stub.splitCompositeKey(result.value.key);
+                const product = await readDocument<Product>(ctx, `product_${attributes[attributes.length - 1]}`);
+                // Every selector is checked on the stored product, which also guards against stale index entries
+                if (product &&
+                    (!owner || (product.owner === owner && product.status !== DISPOSED_STATE)) &&
+                    (!batchId || product.batchId === batchId) &&
+                    (!status || (status === 'Expired' ? this.isExpired(product, now) : product.status === status)) &&
+                    (!from || product.packageDate >= from) &&
+                    (!to || product.packageDate <= to)) {
+                    products.push(product);
+                }
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return {
+            products,
+            fetchedRecordsCount: metadata.fetchedRecordsCount,
+            bookmark: metadata.bookmark
+        };
+    }
+
+    /**
+     * Get all products carrying a label, using the label index
+     * value is optional; when empty, products with any value of the label are returned
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Product[]')
+    public async GetProductsByLabel(ctx: Context, key: string, value: string): Promise<Product[]> {
+        const products: Product[] = [];
+        for (const productId of await getLabeledIds(ctx, PRODUCT_LABEL_INDEX, key, value)) {
+            const product = await readDocument<Product>(ctx, `product_${productId}`);
+            // Guard against stale index entries
+            if (product && product.labels && product.labels[key] !== undefined && (!value || product.labels[key] === value)) {
+                products.push(product);
+            }
+        }
+        return products;
+    }
+
+    /**
+     * Get the origins of the rice in a product with each origin's share, for origin labeling
+     * A product is packaged from one batch, and batches are not merged on the ledger, so the product's batch
+     * contributes 100%. Source batches linked from other channels are listed separately: the ledger records no
+     * quantity for them, so they carry no share
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ProductOriginComposition')
+    public async GetProductComposition(ctx: Context, productId: string): Promise<ProductOriginComposition> {
+        const product = await readDocument<Product>(ctx, `product_${productId}`);
+        if (!product) {
+            throw new Error(`Product ${productId} does not exist`);
+        }
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${product.batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${product.batchId} of product ${productId} does not exist`);
+        }
+
+        const registration = batch.history.length > 0 ? batch.history[0] : undefined;
+        return {
+            productId,
+            batchId: batch.batchId,
+            contributions: [{
+                channel: ctx.stub.getChannelID(),
+                batchId: batch.batchId,
+                origin: batch.origin,
+                variety: batch.variety,
+                harvestDate: batch.harvestDate,
+                farm: registration ? registration.to : '',
+                farmMspId: registration ? registration.signerMspId : undefined,
+                percentage: 100
+            }],
+            linkedSources: (batch.foreignReferences || []).map(reference => ({
+                channel: reference.channel,
+                batchId: reference.batchId,
+                origin: reference.summary.origin,
+                variety: reference.summary.variety,
+                harvestDate: reference.summary.harvestDate,
+                farm: ''
+            }))
+        };
+    }
+
+    /**
+     * Rebuild the owner index from the stored products
+     * Needed once after upgrading from a version that did not maintain the index
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async RebuildOwnerIndex(ctx: Context): Promise<number> {
+        checkOrgAdmin(ctx);
+
+        // Disposed products have left the owner's inventory
+        const products = (await this.GetAllProducts(ctx)).filter(product => product.status !== DISPOSED_STATE);
+        for (const product of products) {
+            await putIndexEntry(ctx, OWNER_INDEX, [product.owner, product.productId]);
+        }
+        return products.length;
+    }
+
+    /**
+     * Rebuild the status, batch and package date indexes used by QueryProducts from the stored products
+     * Needed once after upgrading from a version that did not maintain the indexes
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async RebuildProductQueryIndexes(ctx: Context): Promise<number> {
+        checkOrgAdmin(ctx);
+
+        const products = await this.GetAllProducts(ctx);
+        for (const product of products) {
+            await this.indexProductForQueries(ctx, product);
+        }
+        return products.length;
+    }
+
     /**
      * Check if product exists
+     * Product IDs share one key space across tenants, so products of other tenants count as existing
      * Permission: No restriction
      */
     @Transaction(false)
@@ -193,14 +777,127 @@ export class ProductManagementContract extends Contract {
         return productJSON && productJSON.length > 0;
     }
 
+    /**
+     * Read the raw stored product document for an update
+     * Disposed products are in a terminal state and cannot be changed any further
+     */
+    private async readProductDocument(ctx: Context, productId: string): Promise<Product> {
+        const product = await readDocument<Product>(ctx, `product_${productId}`);
+        if (!product) {
+            throw new Error(`Product ${productId} does not exist`);
+        }
+        if (product.disposal || product.status === DISPOSED_STATE) {
+            throw new Error(`Product ${productId} has been disposed of and cannot be changed`);
+        }
+        return product;
+    }
+
+    /**
+     * Parse and validate nutrition facts per 100 g
+     */
+    private parseNutritionFacts(nutritionJSON: string): NutritionFacts {
+        let input: Record<string, unknown>;
+        try {
+            input = JSON.parse(nutritionJSON);
+        } catch (error) {
+            throw new Error(`Nutrition facts format error: ${error}`);
+        }
+
+        const amount = (field: string, required: boolean): number | undefined => {
+            const value = input[field];
+            if (value === undefined || value === null) {
+                if (required) {
+                    throw new Error(`Nutrition facts field ${field} is required`);
+                }
+                return undefined;
+            }
+            if (typeof value !== 'number' || !Number.isFinite(value) || value < 0) {
+                throw new Error(`Nutrition facts field ${field} must be a non-negative number`);
+            }
+            return value;
+        };
+
+        const nutrition: NutritionFacts = {
+            energyKj: amount('energyKj', true) as number,
+            proteinG: amount('proteinG', true) as number,
+            carbohydrateG: amount('carbohydrateG', true) as number
+        };
+        for (const field of ['fatG', 'fiberG', 'sodiumMg'] as const) {
+            const value = amount(field, false);
+            if (value !== undefined) {
+                nutrition[field] = value;
+            }
+        }
+
+        const fat = nutrition.fatG || 0;
+        const fiber = nutrition.fiberG || 0;
+        const macronutrients = nutrition.proteinG + nutrition.carbohydrateG + fat + fiber;
+        if (macronutrients > 100) {
+            throw new Error(`Macronutrients add up to ${macronutrients} g, more than the 100 g they are declared for`);
+        }
+        if ((nutrition.sodiumMg || 0) > MAX_SODIUM_MG) {
+            throw new Error(`Sodium of ${nutrition.sodiumMg} mg per 100 g is implausible`);
+        }
+
+        const expectedEnergy = ENERGY_FACTORS_KJ.protein * nutrition.proteinG + ENERGY_FACTORS_KJ.carbohydrate * nutrition.carbohydrateG
+            + ENERGY_FACTORS_KJ.fat * fat + ENERGY_FACTORS_KJ.fiber * fiber;
+        if (Math.abs(nutrition.energyKj - expectedEnergy) > Math.max(expectedEnergy * ENERGY_TOLERANCE, ENERGY_TOLERANCE_MIN_KJ)) {
+            throw new Error(`Declared energy of ${nutrition.energyKj} kJ does not match the ${Math.round(expectedEnergy)} kJ computed from the macronutrients`);
+        }
+        return nutrition;
+    }
+
+    /**
+     * Parse and validate product composition metadata
+     */
+    private parseComposition(compositionJSON: string): ProductComposition {
+        let input: Record<string, unknown>;
+        try {
+            input = JSON.parse(compositionJSON);
+        } catch (error) {
+            throw new Error(`Composition format error: ${error}`);
+        }
+
+        const isStringList = (value: unknown): value is string[] =>
+            Array.isArray(value) && value.every(item => typeof item === 'string' && item.trim() !== '');
+
+        if (!isStringList(input.ingredients) || input.ingredients.length === 0) {
+            throw new Error('Composition must list at least one ingredient');
+        }
+        const composition: ProductComposition = { ingredients: input.ingredients };
+
+        if (input.allergens !== undefined) {
+            if (!isStringList(input.allergens)) {
+                throw new Error('Composition allergens must be a list of names');
+            }
+            composition.allergens = input.allergens;
+        }
+        if (input.netWeightG !== undefined) {
+            if (typeof input.netWeightG !== 'number' || !Number.isFinite(input.netWeightG) || input.netWeightG <= 0) {
+                throw new Error('Composition netWeightG must be a positive number');
+            }
+            composition.netWeightG = input.netWeightG;
+        }
+        if (input.grade !== undefined) {
+            if (typeof input.grade !== 'string') {
+                throw new Error('Composition grade must be a string');
+            }
+            composition.grade = input.grade;
+        }
+        if (input.bestBefore !== undefined) {
+            // A product is good through its best-before day
+            composition.bestBefore = normalizeEndTimestamp(String(input.bestBefore), 'Composition bestBefore');
+        }
+        return composition;
+    }
+
     /**
      * Check if batch exists (helper method for cross-contract validation)
      * Permission: No restriction
      */
     @Transaction(false)
     public async BatchExists(ctx: Context, batchId: string): Promise<boolean> {
-        const batchJSON = await ctx.stub.getState(`batch_${batchId}`);
-        return batchJSON && batchJSON.length > 0;
+        return !!await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
     }
 
     /**
@@ -210,11 +907,35 @@ export class ProductManagementContract extends Contract {
     @Transaction(false)
     @Returns('any')
     public async GetBatchInfo(ctx: Context, batchId: string): Promise<any> {
-        const batchJSON = await ctx.stub.getState(`batch_${batchId}`);
-        if (!batchJSON || batchJSON.length === 0) {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
             throw new Error(`The rice batch ${batchId} does not exist`);
         }
 
-        return JSON.parse(batchJSON.toString());
+        return batch;
+    }
+
+    /**
+     * Add a product to the status, batch and package date indexes
+     */
+    private async indexProductForQueries(ctx: Context, product: Product): Promise<void> {
+        await putIndexEntry(ctx, PRODUCT_STATUS_INDEX, [product.status || 'Active', product.productId]);
+        await putIndexEntry(ctx, PRODUCT_BATCH_INDEX, [product.batchId, product.productId]);
+        await putIndexEntry(ctx, PACKAGE_DATE_INDEX, [product.packageDate, product.productId]);
+    }
+
+    /**
+     * Move a product to its new position in the status index
+     */
+    private async moveStatusIndexEntry(ctx: Context, product: Product, status: string): Promise<void> {
+        await deleteIndexEntry(ctx, PRODUCT_STATUS_INDEX, [product.status || 'Active', product.productId]);
+        await putIndexEntry(ctx, PRODUCT_STATUS_INDEX, [status, product.productId]);
+    }
+
+    /**
+     * Whether a product still in circulation is past its best-before date
+     */
+    private isExpired(product: Product, now: string): boolean {
+        return product.status !== DISPOSED_STATE && !!product.composition?.bestBefore && product.composition.bestBefore < now;
     }
-} 
\ No newline at end of file
+}
@@ -0,0 +1,296 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { createHmac } from 'crypto';
+import { OrganizationType, Product, ProductVerification, ProductVerificationResult, VerificationGuardPolicy } from './types';
+import { readDocument, writeDocument, getTxTimestamp, emitEvent, checkOrgAdmin, checkOrgType } from './utils';
+
+/**
+ * Ledger key of the configured verification guard policy
+ */
+const VERIFICATION_GUARD_KEY = 'limits_verificationGuard';
+
+/**
+ * Key prefix of the verification code and attempt counters of a product
+ */
+export const VERIFICATION_PREFIX = 'verification_';
+
+/**
+ * Key prefix of the outcome of each verification attempt, by transaction ID
+ */
+export const VERIFICATION_RESULT_PREFIX = 'verificationResult_';
+
+/**
+ * Environment variable holding the secret verification codes are hashed with, so the hashes on the ledger
+ * cannot be brute-forced offline. Must be set identically on every endorsing peer: a peer with another secret
+ * computes another hash, its endorsement differs from the others and the attempt fails the endorsement policy
+ */
+const VERIFICATION_SECRET_ENV = 'RICETRACE_VERIFICATION_SECRET';
+
+/**
+ * Shortest accepted verification code
+ */
+const MIN_CODE_LENGTH = 6;
+
+/**
+ * Policy used until an administrator configures another
+ */
+const DEFAULT_VERIFICATION_GUARD: VerificationGuardPolicy = {
+    docType: 'verificationGuardPolicy',
+    maxFailedAttempts: 5,
+    lockoutMinutes: 60,
+    suspiciousSuccessCount: 10,
+    version: 0
+};
+
+@Info({ title: 'ProductVerificationContract', description: 'Smart contract verifying the code printed on a product package, with lockout of brute-force attempts' })
+export class ProductVerificationContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "ProductVerificationContract Method Permission Configuration": {
+                "RegisterVerificationCode": ["Farm", "Middleman/Tester (owning organization only)"],
+                "VerifyProduct": ["All Organizations"],
+                "GetVerificationResult": ["All Organizations"],
+                "GetVerificationStatus": ["All Organizations"],
+                "DefineVerificationGuard": ["Organization Administrators"],
+                "GetVerificationGuard": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Register the verification code printed on a product package. Only a keyed hash of the code is stored.
+     * Registering a new code (e.g. after relabeling) clears the attempt counters and any lockout
+     * Permission: Farm and middleman/tester; the owning organization once the product has one
+     */
+    @Transaction()
+    public async RegisterVerificationCode(ctx: Context, productId: string, code: string): Promise<void> {
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        const product = await readDocument<Product>(ctx, `product_${productId}`);
+        if (!product) {
+            throw new Error(`The product ${productId} does not exist`);
+        }
+        const mspId = ctx.clientIdentity.getMSPID();
+        if (product.ownerMspId && product.ownerMspId !== mspId) {
+            throw new Error(`Permission denied: Product ${productId} is held by ${product.ownerMspId}`);
+        }
+        if (!code || code.trim().length < MIN_CODE_LENGTH) {
+            throw new Error(`Verification code must have at least ${MIN_CODE_LENGTH} characters`);
+        }
+
+        const verification: ProductVerification = {
+            docType: 'productVerification',
+            productId,
+            codeHash: this.hashCode(productId, code.trim()),
+            registeredByMspId: mspId,
+            registeredAt: getTxTimestamp(ctx),
+            failedAttempts: 0,
+            consecutiveFailures: 0,
+            lockouts: 0,
+            lockedUntil: '',
+            attemptsWhileLocked: 0,
+            successfulVerifications: 0
+        };
+        await writeDocument(ctx, `${VERIFICATION_PREFIX}${productId}`, verification);
+    }
+
+    /**
+     * Check the code on a product package. Submit it as a transaction: the attempt is counted, so a wrong code
+     * records verified false instead of failing. After maxFailedAttempts consecutive failures the product is locked
+     * for lockoutMinutes and codes are not checked. Emits SuspiciousVerification on a lockout, on attempts while
+     * locked, and when a code reaches suspiciousSuccessCount successful verifications (a likely cloned code)
+     * Returns only the attempt ID (the transaction ID), never the outcome: a client could evaluate the transaction
+     * without submitting it and guess codes without the attempts being counted. The outcome is stored under the
+     * attempt ID and read with GetVerificationResult once the transaction has been committed
+     * The code hash depends on RICETRACE_VERIFICATION_SECRET, which must be identical on every endorsing peer
+     * Permission: No restriction
+     */
+    @Transaction()
+    @Returns('string')
+    public async VerifyProduct(ctx: Context, productId: string, code: string): Promise<string> {
+        const key = `${VERIFICATION_PREFIX}${productId}`;
+        const verification = await readDocument<ProductVerification>(ctx, key);
+        if (!verification) {
+            throw new Error(`The product ${productId} has no verification code`);
+        }
+        const policy = await this.readPolicy(ctx);
+        const now = getTxTimestamp(ctx);
+        const attemptId = ctx.stub.getTxID();
+
+        if (verification.lockedUntil && now < verification.lockedUntil) {
+            verification.attemptsWhileLocked++;
+            await writeDocument(ctx, key, verification);
+            await this.recordResult(ctx, { productId, verified: false, locked: true, remainingAttempts: 0, lockedUntil: verification.lockedUntil }, now);
+            emitEvent(ctx, 'SuspiciousVerification', this.describe(verification, 'attemptWhileLocked', now));
+            return attemptId;
+        }
+
+        const verified = this.hashCode(productId, (code || '').trim()) === verification.codeHash;
+        if (verified) {
+            verification.consecutiveFailures = 0;
+            verification.successfulVerifications++;
+            verification.lastVerifiedAt = now;
+            if (verification.successfulVerifications === policy.suspiciousSuccessCount) {
+                emitEvent(ctx, 'SuspiciousVerification', this.describe(verification, 'repeatedSuccess', now));
+            }
+        } else {
+            verification.failedAttempts++;
+            verification.consecutiveFailures++;
+            verification.lastFailureAt = now;
+            if (verification.consecutiveFailures >= policy.maxFailedAttempts) {
+                verification.lockedUntil = new Date(Date.parse(now) + policy.lockoutMinutes * 60 * 1000).toISOString();
+                verification.lockouts++;
+                verification.consecutiveFailures = 0;
+                emitEvent(ctx, 'SuspiciousVerification', this.describe(verification, 'lockout', now));
+            }
+        }
+        await writeDocument(ctx, key, verification);
+
+        const locked = !!verification.lockedUntil && now < verification.lockedUntil;
+        await this.recordResult(ctx, {
+            productId,
+            verified,
+            locked,
+            remainingAttempts: locked ? 0 : policy.maxFailedAttempts - verification.consecutiveFailures,
+            lockedUntil: locked ? verification.lockedUntil : ''
+        }, now);
+        return attemptId;
+    }
+
+    /**
+     * Get the outcome of a committed verification attempt
+     * attemptId: the transaction ID VerifyProduct returned
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ProductVerificationResult')
+    public async GetVerificationResult(ctx: Context, productId: string, attemptId: string): Promise<ProductVerificationResult> {
+        const result = await readDocument<ProductVerificationResult>(ctx, `${VERIFICATION_RESULT_PREFIX}${attemptId}`);
+        if (!result || result.productId !== productId) {
+            throw new Error(`The verification attempt ${attemptId} of product ${productId} does not exist or is not committed yet`);
+        }
+        return result;
+    }
+
+    /**
+     * Get the attempt counters and lockout of a product's verification code
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ProductVerification')
+    public async GetVerificationStatus(ctx: Context, productId: string): Promise<ProductVerification> {
+        const verification = await readDocument<ProductVerification>(ctx, `${VERIFICATION_PREFIX}${productId}`);
+        if (!verification) {
+            throw new Error(`The product ${productId} has no verification code`);
+        }
+        return verification;
+    }
+
+    /**
+     * Configure the verification guard
+     * policyJSON: { maxFailedAttempts, lockoutMinutes, suspiciousSuccessCount }, all positive integers
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async DefineVerificationGuard(ctx: Context, policyJSON: string): Promise<void> {
+        checkOrgAdmin(ctx);
+
+        let input: any;
+        try {
+            input = JSON.parse(policyJSON);
+        } catch (error) {
+            throw new Error(`Verification guard format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('Verification guard must be an object');
+        }
+        for (const setting of ['maxFailedAttempts', 'lockoutMinutes', 'suspiciousSuccessCount']) {
+            if (!Number.isInteger(input[setting]) || input[setting] <= 0) {
+                throw new Error(`${setting} must be a positive integer`);
+            }
+        }
+
+        const existing = await readDocument<VerificationGuardPolicy>(ctx, VERIFICATION_GUARD_KEY);
+        const policy: VerificationGuardPolicy = {
+            docType: 'verificationGuardPolicy',
+            maxFailedAttempts: input.maxFailedAttempts,
+            lockoutMinutes: input.lockoutMinutes,
+            suspiciousSuccessCount: input.suspiciousSuccessCount,
+            version: existing ? existing.version + 1 : 1,
+            definedBy: ctx.clientIdentity.getMSPID(),
+            lastUpdated: getTxTimestamp(ctx)
+        };
+
+        await writeDocument(ctx, VERIFICATION_GUARD_KEY, policy);
+    }
+
+    /**
+     * Get the verification guard in force (the built-in defaults, version 0, until configured)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('VerificationGuardPolicy')
+    public async GetVerificationGuard(ctx: Context): Promise<VerificationGuardPolicy> {
+        return this.readPolicy(ctx);
+    }
+
+    /**
+     * Verification guard in force
+     */
+    private async readPolicy(ctx: Context): Promise<VerificationGuardPolicy> {
+        return (await readDocument<VerificationGuardPolicy>(ctx, VERIFICATION_GUARD_KEY)) || DEFAULT_VERIFICATION_GUARD;
+    }
+
+    /**
+     * Store the outcome of this transaction's verification attempt
+     */
+    private async recordResult(ctx: Context, outcome: Omit<ProductVerificationResult, 'docType' | 'attemptId' | 'attemptedAt'>, attemptedAt: string): Promise<void> {
+        const attemptId = ctx.stub.getTxID();
+        const result: ProductVerificationResult = {
+            docType: 'productVerificationResult',
+            attemptId,
+            attemptedAt,
+            ...outcome
+        };
+        await writeDocument(ctx, `${VERIFICATION_RESULT_PREFIX}${attemptId}`, result);
+    }
+
+    /**
+     * Keyed hash of a product's code; without the peer secret, the hashes on the ledger reveal nothing
+     */
+    private hashCode(productId: string, code: string): string {
+        const secret = process.env[VERIFICATION_SECRET_ENV];
+        if (!secret) {
+            throw new Error(`Product verification is not configured: set ${VERIFICATION_SECRET_ENV} on the chaincode`);
+        }
+        return createHmac('sha256', secret).update(`${productId}:${code}`).digest('hex');
+    }
+
+    /**
+     * Payload of a SuspiciousVerification event
+     */
+    private describe(verification: ProductVerification, reason: string, detectedAt: string): object {
+        return {
+            productId: verification.productId,
+            reason,
+            detectedAt,
+            failedAttempts: verification.failedAttempts,
+            lockouts: verification.lockouts,
+            lockedUntil: verification.lockedUntil,
+            attemptsWhileLocked: verification.attemptsWhileLocked,
+            successfulVerifications: verification.successfulVerifications
+        };
+    }
+}
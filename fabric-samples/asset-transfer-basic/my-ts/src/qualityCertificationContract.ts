@@ -5,47 +5,65 @@
 import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
 import stringify from 'json-stringify-deterministic';
 import sortKeysRecursive from 'sort-keys-recursive';
-import { TestResult, OrganizationType, OrganizationInfo, QualityCertificate } from './types';
+import {
+    TestResult, OrganizationType, OrganizationInfo, QualityCertificate, RiceBatch, Sample, CertificationExpiryReport, ExpiringCertification,
+    TestReportDetails
+} from './types';
+import {
+    readDocument, writeDocument, patchDocument, emitEvent, normalizeTimestamp, assertNotBefore, getCallerFingerprint,
+    isProcessedRequest, markRequestProcessed, getCertificateExpiry, getTxTimestamp, isPassingResult, putIndexEntry,
+    deleteIndexEntry, TEST_REPORT_COLLECTION, pairCollectionName, assertPeerOrgMatchesClient, sha256Hex,
+    checkOrgType, getOrganizationType, isVisibleToCaller, inheritTenant
+} from './utils';
+import { BATCH_TEST_INDEX, assertTestResultCapacity, getBatchCreationTime } from './batchStorageContract';
+
+/**
+ * Composite key index of test results by outcome (passed, failed or revoked) and test date
+ */
+export const TEST_OUTCOME_INDEX = 'testOutcome~testDate~testId';
 
-@Info({ title: 'QualityCertificationContract', description: 'Smart contract for quality testing and certification operations' })
-export class QualityCertificationContract extends Contract {
+/**
+ * Outcome of a test result as recorded in the test outcome index
+ */
+export function testOutcome(test: TestResult): string {
+    if (test.revoked) {
+        return 'revoked';
+    }
+    return isPassingResult(test.testResult || test.result) ? 'passed' : 'failed';
+}
 
-    /**
-     * Get organization type based on MSP ID
-     * Can be configured based on actual organization structure
-     */
-    private getOrganizationType(mspId: string): OrganizationType {
-        // Map MSP ID to organization type
-        // Can be modified based on actual organization structure
-        const mspToOrgType: Record<string, OrganizationType> = {
-            'Org1MSP': OrganizationType.FARM,              // Farm organization
-            'Org2MSP': OrganizationType.MIDDLEMAN_TESTER,  // Middleman/tester organization
-            'Org3MSP': OrganizationType.CONSUMER           // Consumer organization
-        };
+/**
+ * Whether a test result stands and passed; revoked results never count as passed
+ */
+export function isPassedTest(test: TestResult): boolean {
+    return !test.revoked && isPassingResult(test.testResult || test.result);
+}
 
-        return mspToOrgType[mspId] || OrganizationType.CONSUMER;
-    }
+/**
+ * SHA-256 digest in lowercase hex
+ */
+const SHA256_HEX_PATTERN = /^[0-9a-f]{64}$/;
 
-    /**
-     * Check if the caller has permission to perform specific operations
-     */
-    private checkPermission(ctx: Context, allowedTypes: OrganizationType[]): void {
-        const mspId = ctx.clientIdentity.getMSPID();
-        const callerType = this.getOrganizationType(mspId);
-
-        if (!allowedTypes.includes(callerType)) {
-            const allowedNames = allowedTypes.map(type => {
-                switch (type) {
-                    case OrganizationType.FARM: return 'Farm';
-                    case OrganizationType.MIDDLEMAN_TESTER: return 'Middleman/tester';
-                    case OrganizationType.CONSUMER: return 'Consumer';
-                    default: return 'Unknown';
-                }
-            }).join(', ');
-            
-            throw new Error(`Permission denied: Only the following organization types can call: ${allowedNames}`);
-        }
-    }
+/**
+ * Transient data key carrying the full report passed to SetTestReportDetails
+ */
+const REPORT_DETAILS_TRANSIENT_KEY = 'reportDetails';
+
+/**
+ * Most residue levels recorded for one test result
+ */
+const MAX_RESIDUE_SUBSTANCES = 50;
+
+/**
+ * Default and longest look-ahead of the certificate expiry check, in days
+ */
+const DEFAULT_EXPIRY_WINDOW_DAYS = 30;
+const MAX_EXPIRY_WINDOW_DAYS = 365;
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+@Info({ title: 'QualityCertificationContract', description: 'Smart contract for quality testing and certification operations' })
+export class QualityCertificationContract extends Contract {
 
     /**
      * Get caller organization information
@@ -54,7 +72,7 @@ export class QualityCertificationContract extends Contract {
     @Returns('OrganizationInfo')
     public async GetCallerInfo(ctx: Context): Promise<OrganizationInfo> {
         const mspId = ctx.clientIdentity.getMSPID();
-        const orgType = this.getOrganizationType(mspId);
+        const orgType = getOrganizationType(mspId);
         
         return {
             orgId: mspId,
@@ -71,13 +89,23 @@ export class QualityCertificationContract extends Contract {
     public async GetPermissionMatrix(ctx: Context): Promise<string> {
         const permissionMatrix = {
             "QualityCertificationContract Method Permission Configuration": {
+                "RecordSample": ["Farm", "Middleman/Tester"],
                 "CreateTestResult": ["Farm", "Middleman/Tester"],
                 "CreateQualityCertificate": ["Middleman/Tester"],
+                "ReadSample": ["All Organizations"],
+                "GetSamplesByBatch": ["All Organizations"],
                 "ReadTestResult": ["All Organizations"],
                 "ReadQualityCertificate": ["All Organizations"],
                 "GetAllTestResults": ["All Organizations"],
                 "GetAllQualityCertificates": ["All Organizations"],
                 "VerifyTestResult": ["Middleman/Tester"],
+                "RevokeTestResult": ["Identity that recorded the test result"],
+                "RecordResidueLevels": ["Identity that recorded the test result"],
+                "RecordMoistureContent": ["Identity that recorded the test result"],
+                "SetTestReportDetails": ["Identity that recorded the test result"],
+                "ReadTestReportDetails": ["Lab organization and the party the test was made for"],
+                "VerifyTestReportHash": ["All Organizations"],
+                "CheckExpiringCertifications": ["All Organizations"],
                 "GetCallerInfo": ["All Organizations"],
                 "GetPermissionMatrix": ["All Organizations"]
             },
@@ -91,8 +119,105 @@ export class QualityCertificationContract extends Contract {
         return JSON.stringify(permissionMatrix, null, 2);
     }
 
+    /**
+     * Register a physical sample drawn from a batch
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async RecordSample(
+        ctx: Context,
+        batchId: string,
+        sampleId: string,
+        quantity: string,
+        sampledBy: string,
+        location: string
+    ): Promise<void> {
+        // Check permission: Farm and middleman/tester can take samples
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!sampleId || !quantity || !sampledBy || !location) {
+            throw new Error('Sample ID, quantity, sampledBy and location are required');
+        }
+
+        const existingSample = await ctx.stub.getState(`sample_${sampleId}`);
+        if (existingSample && existingSample.length > 0) {
+            throw new Error(`Sample ${sampleId} already exists`);
+        }
+
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`Batch ${batchId} does not exist`);
+        }
+
+        // Get transaction timestamp
+        const txTimestamp = ctx.stub.getTxTimestamp();
+        const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
+
+        const sample: Sample = {
+            docType: 'sample',
+            sampleId,
+            batchId,
+            quantity,
+            sampledBy,
+            location,
+            sampledTimestamp: now,
+            recordedBy: ctx.clientIdentity.getMSPID()
+        };
+        inheritTenant(sample, batch);
+
+        await writeDocument(ctx, `sample_${sampleId}`, sample);
+    }
+
+    /**
+     * Read sample
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Sample')
+    public async ReadSample(ctx: Context, sampleId: string): Promise<Sample> {
+        const sample = await readDocument<Sample>(ctx, `sample_${sampleId}`);
+        if (!sample) {
+            throw new Error(`Sample ${sampleId} does not exist`);
+        }
+        return sample;
+    }
+
+    /**
+     * Get samples drawn from a batch
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Sample[]')
+    public async GetSamplesByBatch(ctx: Context, batchId: string): Promise<Sample[]> {
+        const resultsIterator = await ctx.stub.getStateByRange('sample_', 'sample_\uffff');
+        const samples: Sample[] = [];
+
+        let result = await resultsIterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                try {
+                    const sample: Sample = JSON.parse(result.value.value.toString());
+                    if (sample.sampleId && sample.batchId === batchId && isVisibleToCaller(ctx, sample)) {
+                        samples.push(sample);
+                    }
+                } catch (error) {
+                    // Skip invalid data
+                    console.warn(`Skipping invalid sample data: ${error}`);
+                }
+            }
+            result = await resultsIterator.next();
+        }
+
+        await resultsIterator.close();
+        return samples;
+    }
+
     /**
      * Create test result
+     * Every test must reference a registered sample drawn from the same batch
+     * reportHash: SHA-256 (hex) of the lab report file, e.g. from `sha256sum report.pdf`; buyers check the report they
+     * were sent against it with VerifyTestReportHash
+     * clientRequestId: idempotency key - a retry with the same ID is not applied twice (empty to disable)
      * Permission: Farm and middleman/tester can call
      */
     @Transaction()
@@ -100,49 +225,95 @@ export class QualityCertificationContract extends Contract {
         ctx: Context,
         testId: string,
         batchId: string,
+        sampleId: string,
         testType: string,
         testDate: string,
         testResult: string,
         tester: string,
-        notes: string
+        notes: string,
+        reportHash: string,
+        clientRequestId: string
     ): Promise<void> {
         // Check permission: Farm and middleman/tester can create test results
-        this.checkPermission(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
 
-        const existingTest = await ctx.stub.getState(`test_${testId}`);
-        if (existingTest && existingTest.length > 0) {
-            throw new Error(`Test result ${testId} already exists`);
+        // A gateway retry of an already processed request is a no-op
+        if (await isProcessedRequest(ctx, clientRequestId, 'CreateTestResult')) {
+            return;
         }
 
-        // Get transaction timestamp
-        const txTimestamp = ctx.stub.getTxTimestamp();
-        const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
+        const normalizedReportHash = (reportHash || '').trim().toLowerCase();
+        if (!SHA256_HEX_PATTERN.test(normalizedReportHash)) {
+            throw new Error('Report hash must be the SHA-256 digest (64 hex characters) of the report file');
+        }
+
+        // Test IDs are unique across batches and tenants, so a resubmitted result cannot be recorded twice;
+        // the batch of another tenant's result is not disclosed
+        const existingTestJSON = await ctx.stub.getState(`test_${testId}`);
+        if (existingTestJSON && existingTestJSON.length > 0) {
+            const existingTest: TestResult = JSON.parse(existingTestJSON.toString());
+            if (!isVisibleToCaller(ctx, existingTest)) {
+                throw new Error(`Test result ${testId} already exists`);
+            }
+            throw new Error(existingTest.batchId === batchId
+                ? `Test result ${testId} already exists for batch ${batchId}`
+                : `Test result ${testId} already exists (recorded for batch ${existingTest.batchId})`);
+        }
+
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`Batch ${batchId} does not exist`);
+        }
+        await assertTestResultCapacity(ctx, batchId);
+
+        // Link the lab result to the physical sample it was performed on
+        const sample = await readDocument<Sample>(ctx, `sample_${sampleId}`);
+        if (!sample) {
+            throw new Error(`Sample ${sampleId} is not registered; record it with RecordSample first`);
+        }
+        if (sample.batchId !== batchId) {
+            throw new Error(`Sample ${sampleId} was drawn from batch ${sample.batchId}, not ${batchId}`);
+        }
+
+        // A sample cannot be tested before the batch it was drawn from came into existence: its harvest, and its
+        // creation on the ledger, since samples are only drawn from recorded batches
+        const normalizedTestDate = normalizeTimestamp(testDate, 'testDate');
+        assertNotBefore(normalizedTestDate, 'testDate', batch.harvestDate, `harvestDate of batch ${batchId}`);
+        assertNotBefore(normalizedTestDate, 'testDate', await getBatchCreationTime(ctx, batch), `creation of batch ${batchId}`);
 
         const testResultObj: TestResult = {
             docType: 'testResult',
             testId,
             batchId,
             testType,
-            testDate,
+            testDate: normalizedTestDate,
             testResult,
             tester,
             notes,
             isVerified: false,
             verificationSource: '',
             verificationTimestamp: '',
-            reportHash: `hash_${testId}_${now}`,
+            reportHash: normalizedReportHash,
             reportId: testId,
             testerId: '',
             timestamp: '',
             temperature: '',
             report: '',
-            result: ''
+            result: '',
+            signerMspId: ctx.clientIdentity.getMSPID(),
+            signerFingerprint: getCallerFingerprint(ctx),
+            sampleId
         };
+        inheritTenant(testResultObj, batch);
 
         await ctx.stub.putState(
             `test_${testId}`,
             Buffer.from(stringify(sortKeysRecursive(testResultObj)))
         );
+        await putIndexEntry(ctx, TEST_OUTCOME_INDEX, [testOutcome(testResultObj), normalizedTestDate, testId]);
+        await putIndexEntry(ctx, BATCH_TEST_INDEX, [batchId, testId]);
+        await markRequestProcessed(ctx, clientRequestId, 'CreateTestResult');
+        emitEvent(ctx, 'TestResultCreated', testResultObj);
     }
 
     /**
@@ -162,13 +333,18 @@ export class QualityCertificationContract extends Contract {
         standards: string
     ): Promise<void> {
         // Check permission: Only middleman/tester can create quality certificates
-        this.checkPermission(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
 
         const existingCert = await ctx.stub.getState(`cert_${certificateId}`);
         if (existingCert && existingCert.length > 0) {
             throw new Error(`Quality certificate ${certificateId} already exists`);
         }
 
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`Batch ${batchId} does not exist`);
+        }
+
         // Get transaction timestamp
         const txTimestamp = ctx.stub.getTxTimestamp();
         const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
@@ -179,7 +355,7 @@ export class QualityCertificationContract extends Contract {
             batchId,
             testIds: testIds.split(','),
             certificateType,
-            issueDate,
+            issueDate: normalizeTimestamp(issueDate, 'issueDate'),
             issuer,
             validityPeriod,
             standards,
@@ -187,11 +363,13 @@ export class QualityCertificationContract extends Contract {
             createdTimestamp: now,
             lastUpdated: now
         };
+        inheritTenant(certificate, batch);
 
         await ctx.stub.putState(
             `cert_${certificateId}`,
             Buffer.from(stringify(sortKeysRecursive(certificate)))
         );
+        emitEvent(ctx, 'QualityCertificateIssued', certificate);
     }
 
     /**
@@ -201,12 +379,11 @@ export class QualityCertificationContract extends Contract {
     @Transaction(false)
     @Returns('TestResult')
     public async ReadTestResult(ctx: Context, testId: string): Promise<TestResult> {
-        const testJSON = await ctx.stub.getState(`test_${testId}`);
-        if (!testJSON || testJSON.length === 0) {
+        const testResult = await readDocument<TestResult>(ctx, `test_${testId}`);
+        if (!testResult) {
             throw new Error(`Test result ${testId} does not exist`);
         }
-
-        return JSON.parse(testJSON.toString());
+        return testResult;
     }
 
     /**
@@ -216,12 +393,11 @@ export class QualityCertificationContract extends Contract {
     @Transaction(false)
     @Returns('QualityCertificate')
     public async ReadQualityCertificate(ctx: Context, certificateId: string): Promise<QualityCertificate> {
-        const certJSON = await ctx.stub.getState(`cert_${certificateId}`);
-        if (!certJSON || certJSON.length === 0) {
+        const certificate = await readDocument<QualityCertificate>(ctx, `cert_${certificateId}`);
+        if (!certificate) {
             throw new Error(`Quality certificate ${certificateId} does not exist`);
         }
-
-        return JSON.parse(certJSON.toString());
+        return certificate;
     }
 
     /**
@@ -239,7 +415,7 @@ export class QualityCertificationContract extends Contract {
             if (result.value && result.value.value.toString()) {
                 try {
                     const testResult: TestResult = JSON.parse(result.value.value.toString());
-                    if (testResult.testId) {
+                    if (testResult.testId && isVisibleToCaller(ctx, testResult)) {
                         testResults.push(testResult);
                     }
                 } catch (error) {
@@ -269,7 +445,7 @@ export class QualityCertificationContract extends Contract {
             if (result.value && result.value.value.toString()) {
                 try {
                     const certificate: QualityCertificate = JSON.parse(result.value.value.toString());
-                    if (certificate.certificateId) {
+                    if (certificate.certificateId && isVisibleToCaller(ctx, certificate)) {
                         certificates.push(certificate);
                     }
                 } catch (error) {
@@ -284,6 +460,57 @@ export class QualityCertificationContract extends Contract {
         return certificates;
     }
 
+    /**
+     * Find active certificates expiring within the next withinDays days (default 30) and emit one
+     * CertificationExpiring event listing them with the owner of the certified batch, so owners can renew
+     * before export paperwork lapses. Each certificate is reported once: it is marked as notified.
+     * Meant to be invoked daily by the gateway's scheduler
+     * Permission: No restriction (the check is computed from the ledger)
+     */
+    @Transaction()
+    @Returns('CertificationExpiryReport')
+    public async CheckExpiringCertifications(ctx: Context, withinDays: string): Promise<CertificationExpiryReport> {
+        const days = withinDays ? Number(withinDays) : DEFAULT_EXPIRY_WINDOW_DAYS;
+        if (!Number.isInteger(days) || days < 1 || days > MAX_EXPIRY_WINDOW_DAYS) {
+            throw new Error(`Invalid withinDays ${withinDays}: expected a whole number of days from 1 to ${MAX_EXPIRY_WINDOW_DAYS}`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const horizon = new Date(Date.parse(now) + days * DAY_MS).toISOString();
+        const expiring: ExpiringCertification[] = [];
+
+        for (const certificate of await this.GetAllQualityCertificates(ctx)) {
+            if (!certificate.isActive || certificate.expiryNotifiedAt) {
+                continue;
+            }
+            const expiresAt = getCertificateExpiry(certificate.issueDate, certificate.validityPeriod);
+            if (!expiresAt || expiresAt <= now || expiresAt > horizon) {
+                continue;
+            }
+
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${certificate.batchId}`);
+            const lastEvent = batch && batch.history.length > 0 ? batch.history[batch.history.length - 1] : undefined;
+            expiring.push({
+                certificateId: certificate.certificateId,
+                certificateType: certificate.certificateType,
+                batchId: certificate.batchId,
+                issuer: certificate.issuer,
+                expiresAt,
+                daysRemaining: Math.floor((Date.parse(expiresAt) - Date.parse(now)) / DAY_MS),
+                owner: batch ? batch.currentOwner : '',
+                ownerMspId: lastEvent && lastEvent.signerMspId ? lastEvent.signerMspId : ''
+            });
+            await patchDocument<QualityCertificate>(ctx, `cert_${certificate.certificateId}`, { expiryNotifiedAt: now });
+        }
+
+        const report: CertificationExpiryReport = { checkedAt: now, withinDays: days, expiring };
+        if (expiring.length > 0) {
+            // Fabric keeps one event per transaction, so all expiring certificates go into a single event
+            emitEvent(ctx, 'CertificationExpiring', report);
+        }
+        return report;
+    }
+
     /**
      * Verify test result
      * Permission: Only middleman/tester can call
@@ -296,7 +523,7 @@ export class QualityCertificationContract extends Contract {
         verificationNotes: string
     ): Promise<void> {
         // Check permission: Only middleman/tester can verify test results
-        this.checkPermission(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
 
         const testResult = await this.ReadTestResult(ctx, testId);
 
@@ -304,16 +531,248 @@ export class QualityCertificationContract extends Contract {
         const txTimestamp = ctx.stub.getTxTimestamp();
         const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
 
-        // Update verification information
-        testResult.isVerified = true;
-        testResult.verificationSource = verificationSource;
-        testResult.verificationTimestamp = now;
-        testResult.notes = testResult.notes ? `${testResult.notes}; Verification: ${verificationNotes}` : `Verification: ${verificationNotes}`;
+        // Patch only the verification fields, keeping any fields this version does not know about
+        await patchDocument<TestResult>(ctx, `test_${testId}`, {
+            isVerified: true,
+            verificationSource,
+            verificationTimestamp: now,
+            notes: testResult.notes ? `${testResult.notes}; Verification: ${verificationNotes}` : `Verification: ${verificationNotes}`
+        });
+    }
 
-        await ctx.stub.putState(
-            `test_${testId}`,
-            Buffer.from(stringify(sortKeysRecursive(testResult)))
-        );
+    /**
+     * Withdraw a test result, e.g. after discovering an instrument error. The result is flagged as revoked, not
+     * deleted: it stays readable but no longer satisfies quality gates, workflow test requirements or the
+     * traceability score, and leaves the season statistics. Quarantine is placed by testers rather than derived
+     * from results, so it is not lifted; the TestResultRevoked event reports whether the batch is quarantined
+     * Permission: Only the identity (certificate) that recorded the result
+     */
+    @Transaction()
+    public async RevokeTestResult(ctx: Context, batchId: string, testId: string, reason: string): Promise<void> {
+        const testResult = await this.ReadTestResult(ctx, testId);
+        if (testResult.batchId !== batchId) {
+            throw new Error(`Test result ${testId} belongs to batch ${testResult.batchId}, not ${batchId}`);
+        }
+        if (!testResult.signerFingerprint || testResult.signerMspId !== ctx.clientIdentity.getMSPID() ||
+            testResult.signerFingerprint !== getCallerFingerprint(ctx)) {
+            throw new Error(`Permission denied: Only the identity that recorded test result ${testId} can revoke it`);
+        }
+        if (testResult.revoked) {
+            throw new Error(`Test result ${testId} was already revoked at ${testResult.revokedAt}`);
+        }
+        if (!reason || !reason.trim()) {
+            throw new Error('A reason is required to revoke a test result');
+        }
+
+        const updated = await patchDocument<TestResult>(ctx, `test_${testId}`, {
+            revoked: true,
+            revocationReason: reason.trim(),
+            revokedAt: getTxTimestamp(ctx)
+        });
+
+        // Move the result out of the passed/failed outcomes
+        await deleteIndexEntry(ctx, TEST_OUTCOME_INDEX, [testOutcome(testResult), testResult.testDate, testId]);
+        await putIndexEntry(ctx, TEST_OUTCOME_INDEX, [testOutcome(updated), testResult.testDate, testId]);
+
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        emitEvent(ctx, 'TestResultRevoked', { ...updated, batchQuarantined: !!(batch && batch.quarantined) });
+    }
+
+    /**
+     * Record the residue levels a test measured, e.g. for a pesticide residue screen checked against export MRLs
+     * residuesJSON: { "<substance>": <mg/kg>, ... }, 1 to 50 non-negative levels. Levels are recorded once
+     * and cannot be changed; revoke the test result and record a new one to correct them
+     * Permission: Only the identity (certificate) that recorded the result
+     */
+    @Transaction()
+    public async RecordResidueLevels(ctx: Context, batchId: string, testId: string, residuesJSON: string): Promise<void> {
+        const testResult = await this.ReadTestResult(ctx, testId);
+        if (testResult.batchId !== batchId) {
+            throw new Error(`Test result ${testId} belongs to batch ${testResult.batchId}, not ${batchId}`);
+        }
+        if (!testResult.signerFingerprint || testResult.signerMspId !== ctx.clientIdentity.getMSPID() ||
+            testResult.signerFingerprint !== getCallerFingerprint(ctx)) {
+            throw new Error(`Permission denied: Only the identity that recorded test result ${testId} can record its residue levels`);
+        }
+        if (testResult.revoked) {
+            throw new Error(`Test result ${testId} was revoked at ${testResult.revokedAt}`);
+        }
+        if (testResult.residues) {
+            throw new Error(`Residue levels of test result ${testId} are already recorded`);
+        }
+
+        let input: unknown;
+        try {
+            input = JSON.parse(residuesJSON);
+        } catch (error) {
+            throw new Error(`Residue levels format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('Residue levels must be an object of substance names to levels in mg/kg');
+        }
+        const residues: Record<string, number> = {};
+        for (const [substance, level] of Object.entries(input as Record<string, unknown>)) {
+            const name = substance.trim().toLowerCase();
+            if (!name) {
+                throw new Error('Residue substance names cannot be empty');
+            }
+            if (typeof level !== 'number' || !Number.isFinite(level) || level < 0) {
+                throw new Error(`Residue level of ${substance} must be a non-negative number of mg/kg`);
+            }
+            residues[name] = level;
+        }
+        const count = Object.keys(residues).length;
+        if (count === 0 || count > MAX_RESIDUE_SUBSTANCES) {
+            throw new Error(`Between 1 and ${MAX_RESIDUE_SUBSTANCES} residue levels can be recorded per test result, got ${count}`);
+        }
+
+        const updated = await patchDocument<TestResult>(ctx, `test_${testId}`, { residues });
+        emitEvent(ctx, 'ResidueLevelsRecorded', updated);
+    }
+
+    /**
+     * Record the moisture content a moisture test measured, in percent of the sample weight (0-100)
+     * The reading is recorded once; revoke the test result and record a new one to correct it
+     * Permission: Only the identity (certificate) that recorded the result
+     */
+    @Transaction()
+    public async RecordMoistureContent(ctx: Context, batchId: string, testId: string, moisturePercent: string): Promise<void> {
+        const testResult = await this.ReadTestResult(ctx, testId);
+        if (testResult.batchId !== batchId) {
+            throw new Error(`Test result ${testId} belongs to batch ${testResult.batchId}, not ${batchId}`);
+        }
+        if (!testResult.signerFingerprint || testResult.signerMspId !== ctx.clientIdentity.getMSPID() ||
+            testResult.signerFingerprint !== getCallerFingerprint(ctx)) {
+            throw new Error(`Permission denied: Only the identity that recorded test result ${testId} can record its moisture content`);
+        }
+        if (!testResult.testType.toLowerCase().includes('moisture')) {
+            throw new Error(`Test result ${testId} is a ${testResult.testType} test, not a moisture test`);
+        }
+        if (testResult.revoked) {
+            throw new Error(`Test result ${testId} was revoked at ${testResult.revokedAt}`);
+        }
+        if (testResult.moisturePercent !== undefined) {
+            throw new Error(`Moisture content of test result ${testId} is already recorded`);
+        }
+        const value = Number(moisturePercent);
+        if (!moisturePercent || !Number.isFinite(value) || value < 0 || value > 100) {
+            throw new Error(`Moisture content must be a percentage between 0 and 100, got ${moisturePercent}`);
+        }
+
+        const updated = await patchDocument<TestResult>(ctx, `test_${testId}`, { moisturePercent: value });
+        emitEvent(ctx, 'MoistureContentRecorded', updated);
+    }
+
+    /**
+     * Privately share the full report of a test with the party it was made for
+     * The report is passed in the "reportDetails" transient field and stored in the testReports collection of the
+     * lab's and counterparty's organizations; the test result only records its SHA-256 hash. Details are recorded
+     * once; they expire with the collection's blockToLive or are removed earlier by PurgePrivateData, and the hash
+     * remains. Must be endorsed by a peer of the caller's organization
+     * Permission: Only the identity (certificate) that recorded the result
+     */
+    @Transaction()
+    public async SetTestReportDetails(ctx: Context, batchId: string, testId: string, counterpartyMspId: string): Promise<void> {
+        assertPeerOrgMatchesClient(ctx);
+        const testResult = await this.ReadTestResult(ctx, testId);
+        if (testResult.batchId !== batchId) {
+            throw new Error(`Test result ${testId} belongs to batch ${testResult.batchId}, not ${batchId}`);
+        }
+        const mspId = ctx.clientIdentity.getMSPID();
+        if (!testResult.signerFingerprint || testResult.signerMspId !== mspId || testResult.signerFingerprint !== getCallerFingerprint(ctx)) {
+            throw new Error(`Permission denied: Only the identity that recorded test result ${testId} can share its report details`);
+        }
+        if (testResult.reportDetailsHash) {
+            throw new Error(`Report details of test result ${testId} are already recorded`);
+        }
+        if (!counterpartyMspId || counterpartyMspId === mspId) {
+            throw new Error('The report details must be shared with another organization');
+        }
+
+        const detailsBytes = ctx.stub.getTransient().get(REPORT_DETAILS_TRANSIENT_KEY);
+        if (!detailsBytes || detailsBytes.length === 0) {
+            throw new Error(`Report details must be passed in the "${REPORT_DETAILS_TRANSIENT_KEY}" transient field`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const details = Buffer.from(detailsBytes).toString('utf8');
+        const collection = pairCollectionName(TEST_REPORT_COLLECTION, mspId, counterpartyMspId);
+        const record: TestReportDetails = {
+            docType: 'testReportDetails',
+            testId,
+            batchId,
+            mspId,
+            counterpartyMspId,
+            details,
+            recordedAt: now,
+            txId: ctx.stub.getTxID()
+        };
+        await ctx.stub.putPrivateData(collection, `report_${testId}`, Buffer.from(stringify(sortKeysRecursive(record))));
+
+        const updated = await patchDocument<TestResult>(ctx, `test_${testId}`, {
+            reportDetailsHash: sha256Hex(details),
+            reportDetailsCollection: collection,
+            reportDetailsAt: now
+        });
+        emitEvent(ctx, 'TestReportDetailsRecorded', updated);
+    }
+
+    /**
+     * Read the full report of a test shared with SetTestReportDetails
+     * Served from the testReports collection, so it must be evaluated on a peer of the caller's organization
+     * Permission: The lab's organization and the organization the report was shared with
+     */
+    @Transaction(false)
+    @Returns('TestReportDetails')
+    public async ReadTestReportDetails(ctx: Context, batchId: string, testId: string): Promise<TestReportDetails> {
+        assertPeerOrgMatchesClient(ctx);
+        const testResult = await this.ReadTestResult(ctx, testId);
+        if (testResult.batchId !== batchId) {
+            throw new Error(`Test result ${testId} belongs to batch ${testResult.batchId}, not ${batchId}`);
+        }
+        if (!testResult.reportDetailsCollection) {
+            throw new Error(`Test result ${testId} has no report details recorded`);
+        }
+        const mspId = ctx.clientIdentity.getMSPID();
+        if (mspId !== testResult.signerMspId &&
+            testResult.reportDetailsCollection !== pairCollectionName(TEST_REPORT_COLLECTION, testResult.signerMspId || '', mspId)) {
+            throw new Error(`Permission denied: The report details of test result ${testId} were not shared with ${mspId}`);
+        }
+        if (testResult.reportDetailsPurgedAt) {
+            throw new Error(`Report details of test result ${testId} were purged at ${testResult.reportDetailsPurgedAt}; only their hash remains`);
+        }
+
+        const data = await ctx.stub.getPrivateData(testResult.reportDetailsCollection, `report_${testId}`);
+        if (!data || data.length === 0) {
+            throw new Error(`Report details of test result ${testId} are no longer held (expired with the collection's blockToLive); only their hash remains`);
+        }
+        return JSON.parse(Buffer.from(data).toString('utf8'));
+    }
+
+    /**
+     * Check a report file against the hash registered with a test result
+     * Lets a buyer confirm that the report they were sent is the one recorded on the ledger
+     * reportHash is the SHA-256 (hex) of the file, e.g. from `sha256sum report.pdf`
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('boolean')
+    public async VerifyTestReportHash(ctx: Context, batchId: string, testId: string, reportHash: string): Promise<boolean> {
+        const presentedHash = (reportHash || '').trim().toLowerCase();
+        if (!SHA256_HEX_PATTERN.test(presentedHash)) {
+            throw new Error('Report hash must be a SHA-256 digest (64 hex characters)');
+        }
+
+        const testResult = await this.ReadTestResult(ctx, testId);
+        if (testResult.batchId !== batchId) {
+            throw new Error(`Test result ${testId} belongs to batch ${testResult.batchId}, not ${batchId}`);
+        }
+
+        const recordedHash = (testResult.reportHash || '').toLowerCase();
+        if (!SHA256_HEX_PATTERN.test(recordedHash)) {
+            throw new Error(`Test result ${testId} has no report file hash registered`);
+        }
+        return recordedHash === presentedHash;
     }
 
     /**
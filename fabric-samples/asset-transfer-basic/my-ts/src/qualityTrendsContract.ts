@@ -0,0 +1,185 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { QualityTrendBucket, QualityTrends, RiceBatch, TestResult } from './types';
+import { TEST_OUTCOME_INDEX } from './qualityCertificationContract';
+import { ProductManagementContract } from './productManagementContract';
+import { readDocument, getIndexEntries, getTxTimestamp, parseInterval } from './utils';
+
+/**
+ * Most calendar months one trend query can cover
+ */
+const MAX_TREND_MONTHS = 36;
+
+/**
+ * Accumulated readings of a bucket, turned into averages and rates at the end
+ */
+interface TrendTotals {
+    testsRecorded: number;
+    failedTests: number;
+    moistureReadings: number;
+    moistureSum: number;
+    grades: Record<string, number>;
+}
+
+@Info({ title: 'QualityTrendsContract', description: 'Smart contract aggregating test outcomes, moisture and grades over time' })
+export class QualityTrendsContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "QualityTrendsContract Method Permission Configuration": {
+                "GetQualityTrends": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Quality of the rice of a variety from a region over a period, month by month: test failure rate, average
+     * moisture content of moisture tests and the grades declared on packaged products
+     * Tests are found through the test outcome index by test date; revoked results do not count. Products count in
+     * the month they were packaged. variety matches exactly and region is a part of the batch origin, both ignoring
+     * case; either may be empty to match every batch
+     * period: interval of dates "<start>/<end>", at most 36 calendar months
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('QualityTrends')
+    public async GetQualityTrends(ctx: Context, variety: string, region: string, period: string): Promise<QualityTrends> {
+        const { from, to } = parseInterval(period, 'period');
+        const months = this.monthsBetween(from, to);
+        if (months.length > MAX_TREND_MONTHS) {
+            throw new Error(`At most ${MAX_TREND_MONTHS} months of quality trends can be queried at once`);
+        }
+
+        const wantedVariety = (variety || '').trim().toLowerCase();
+        const wantedRegion = (region || '').trim().toLowerCase();
+        const matchingBatches = new Map<string, boolean>();
+        const matches = async (batchId: string): Promise<boolean> => {
+            if (!matchingBatches.has(batchId)) {
+                // readDocument hides batches of other tenants
+                const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+                matchingBatches.set(batchId, !!batch &&
+                    (!wantedVariety || (batch.variety || '').trim().toLowerCase() === wantedVariety) &&
+                    (!wantedRegion || (batch.origin || '').toLowerCase().includes(wantedRegion)));
+            }
+            return matchingBatches.get(batchId) as boolean;
+        };
+
+        const totals = new Map<string, TrendTotals>(months.map(month => [month, this.emptyTotals()]));
+        const counted = new Set<string>();
+        for (const outcome of ['passed', 'failed']) {
+            for (const [, testDate, testId] of await getIndexEntries(ctx, TEST_OUTCOME_INDEX, [outcome])) {
+                if (testDate < from || testDate > to) {
+                    continue;
+                }
+                const bucket = totals.get(testDate.slice(0, 7));
+                const test = await readDocument<TestResult>(ctx, `test_${testId}`);
+                if (!bucket || !test || test.revoked || !(await matches(test.batchId))) {
+                    continue;
+                }
+                counted.add(test.batchId);
+                bucket.testsRecorded++;
+                if (outcome === 'failed') {
+                    bucket.failedTests++;
+                }
+                if (typeof test.moisturePercent === 'number') {
+                    bucket.moistureReadings++;
+                    bucket.moistureSum += test.moisturePercent;
+                }
+            }
+        }
+
+        for (const product of await new ProductManagementContract().GetAllProducts(ctx)) {
+            const grade = product.composition ? product.composition.grade : undefined;
+            const bucket = product.packageDate >= from && product.packageDate <= to ? totals.get(product.packageDate.slice(0, 7)) : undefined;
+            if (!grade || !bucket || !(await matches(product.batchId))) {
+                continue;
+            }
+            counted.add(product.batchId);
+            bucket.grades[grade] = (bucket.grades[grade] || 0) + 1;
+        }
+
+        const overall = this.emptyTotals();
+        const trends: QualityTrends = {
+            variety: variety || '',
+            region: region || '',
+            from,
+            to,
+            batches: counted.size,
+            testsRecorded: 0,
+            failedTests: 0,
+            failureRate: 0,
+            moistureReadings: 0,
+            grades: {},
+            months: [],
+            generatedAt: getTxTimestamp(ctx)
+        };
+        for (const month of months) {
+            const bucket = totals.get(month) as TrendTotals;
+            overall.testsRecorded += bucket.testsRecorded;
+            overall.failedTests += bucket.failedTests;
+            overall.moistureReadings += bucket.moistureReadings;
+            overall.moistureSum += bucket.moistureSum;
+            Object.entries(bucket.grades).forEach(([grade, count]) => {
+                overall.grades[grade] = (overall.grades[grade] || 0) + count;
+            });
+            trends.months.push({ month, ...this.summarize(bucket) });
+        }
+        return { ...trends, ...this.summarize(overall) };
+    }
+
+    private emptyTotals(): TrendTotals {
+        return { testsRecorded: 0, failedTests: 0, moistureReadings: 0, moistureSum: 0, grades: {} };
+    }
+
+    /**
+     * Rates and averages of accumulated readings, rounded to 4 and 2 decimals
+     */
+    private summarize(totals: TrendTotals): Omit<QualityTrendBucket, 'month'> {
+        const summary: Omit<QualityTrendBucket, 'month'> = {
+            testsRecorded: totals.testsRecorded,
+            failedTests: totals.failedTests,
+            failureRate: totals.testsRecorded === 0 ? 0 : Math.round(10000 * totals.failedTests / totals.testsRecorded) / 10000,
+            moistureReadings: totals.moistureReadings,
+            grades: totals.grades
+        };
+        if (totals.moistureReadings > 0) {
+            summary.averageMoisturePercent = Math.round(100 * totals.moistureSum / totals.moistureReadings) / 100;
+        }
+        return summary;
+    }
+
+    /**
+     * Calendar months (YYYY-MM, UTC) from the month of one timestamp to the month of another
+     */
+    private monthsBetween(from: string, to: string): string[] {
+        const months: string[] = [];
+        let year = Number(from.slice(0, 4));
+        let month = Number(from.slice(5, 7));
+        const last = to.slice(0, 7);
+        // Stops one past the limit so oversized periods are rejected without listing every month
+        while (months.length <= MAX_TREND_MONTHS) {
+            const current = `${year}-${String(month).padStart(2, '0')}`;
+            months.push(current);
+            if (current >= last) {
+                break;
+            }
+            month++;
+            if (month > 12) {
+                month = 1;
+                year++;
+            }
+        }
+        return months;
+    }
+}
@@ -0,0 +1,214 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { NamedQueryDefinition, NamedQueryResult, Product, RiceBatch, TestResult } from './types';
+import { BATCH_OWNER_INDEX, RiceTracerContract } from './riceTracerContract';
+import { QualityCertificationContract, TEST_OUTCOME_INDEX, testOutcome } from './qualityCertificationContract';
+import { BEST_BEFORE_INDEX, ProductManagementContract } from './productManagementContract';
+import { readDocument, normalizeTimestamp, putIndexEntry, checkOrgAdmin, DISPOSED_STATE } from './utils';
+
+/**
+ * Default and maximum number of index entries read per page of a named query
+ */
+const DEFAULT_QUERY_PAGE_SIZE = 50;
+const MAX_QUERY_PAGE_SIZE = 200;
+
+/**
+ * Paging options accepted by every named query next to its own parameters
+ */
+const PAGING_PARAMETERS = ['pageSize', 'bookmark'];
+
+type ParameterKind = 'text' | 'timestamp';
+type QueryRecord = RiceBatch | TestResult | Product;
+
+interface NamedQuery {
+    description: string;
+    parameters: Record<string, ParameterKind>;
+    recordType: 'batches' | 'testResults' | 'products';
+    index: string;
+    partialKey: (params: Record<string, string>) => string[];
+    // Read the record an index entry points to, or null when it does not match (or the entry is stale)
+    resolve: (ctx: Context, attributes: string[], params: Record<string, string>) => Promise<QueryRecord | null>;
+}
+
+/**
+ * The curated queries clients can run; each one walks a composite key index, never the whole world state
+ */
+const NAMED_QUERIES: Record<string, NamedQuery> = {
+    batchesByOwner: {
+        description: 'Batches currently held by an owner',
+        parameters: { owner: 'text' },
+        recordType: 'batches',
+        index: BATCH_OWNER_INDEX,
+        partialKey: params => [params.owner],
+        resolve: async (ctx, [owner, batchId]) => {
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+            return batch && batch.currentOwner === owner ? batch : null;
+        }
+    },
+    failedTestsSince: {
+        description: 'Failed test results with a test date at or after since, oldest first',
+        parameters: { since: 'timestamp' },
+        recordType: 'testResults',
+        index: TEST_OUTCOME_INDEX,
+        partialKey: () => ['failed'],
+        resolve: async (ctx, [, testDate, testId], params) =>
+            testDate >= params.since ? readDocument<TestResult>(ctx, `test_${testId}`) : null
+    },
+    productsExpiringBefore: {
+        description: 'Products still in circulation whose best-before date is before the given time, soonest first',
+        parameters: { before: 'timestamp' },
+        recordType: 'products',
+        index: BEST_BEFORE_INDEX,
+        partialKey: () => [],
+        resolve: async (ctx, [bestBefore, productId], params) => {
+            if (bestBefore >= params.before) {
+                return null;
+            }
+            const product = await readDocument<Product>(ctx, `product_${productId}`);
+            return product && product.status !== DISPOSED_STATE && product.composition?.bestBefore === bestBefore ? product : null;
+        }
+    }
+};
+
+@Info({ title: 'QueryCatalogContract', description: 'Smart contract running a catalog of named, index-backed queries' })
+export class QueryCatalogContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "QueryCatalogContract Method Permission Configuration": {
+                "ListNamedQueries": ["All Organizations"],
+                "RunNamedQuery": ["All Organizations"],
+                "RebuildQueryIndexes": ["Organization Administrators"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * List the queries in the catalog and their parameters
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('NamedQueryDefinition[]')
+    public async ListNamedQueries(ctx: Context): Promise<NamedQueryDefinition[]> {
+        return Object.entries(NAMED_QUERIES).map(([name, query]) => ({
+            name,
+            description: query.description,
+            parameters: [...Object.keys(query.parameters), ...PAGING_PARAMETERS]
+        }));
+    }
+
+    /**
+     * Run a query from the catalog, one page at a time
+     * paramsJSON is an object with the query's parameters (timestamps as dates or RFC3339 times), plus the optional
+     * pageSize (1-200, default 50) and bookmark of the previous page. A page reads at most pageSize index entries,
+     * so it can hold fewer records than pageSize while more pages follow
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('NamedQueryResult')
+    public async RunNamedQuery(ctx: Context, name: string, paramsJSON: string): Promise<NamedQueryResult> {
+        const query = NAMED_QUERIES[name];
+        if (!query) {
+            throw new Error(`Unknown query ${name}. Available queries: ${Object.keys(NAMED_QUERIES).join(', ')}`);
+        }
+        const { params, pageSize, bookmark } = this.parseParameters(name, query, paramsJSON);
+
+        const { iterator, metadata } = await ctx.stub.getStateByPartialCompositeKeyWithPagination(
+            query.index, query.partialKey(params), pageSize, bookmark
+        );
+        const records: QueryRecord[] = [];
+
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value) {
+                const { attributes } = ctx.stub.splitCompositeKey(result.value.key);
+                const record = await query.resolve(ctx, attributes, params);
+                if (record) {
+                    records.push(record);
+                }
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        const page: NamedQueryResult = { name, fetchedRecordsCount: metadata.fetchedRecordsCount, bookmark: metadata.bookmark };
+        return Object.assign(page, { [query.recordType]: records });
+    }
+
+    /**
+     * Rebuild the batch owner, test outcome and best-before indexes used by the catalog from the stored documents
+     * Needed once after upgrading from a version that did not maintain the indexes
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async RebuildQueryIndexes(ctx: Context): Promise<number> {
+        checkOrgAdmin(ctx);
+
+        let indexed = 0;
+        for (const batch of await new RiceTracerContract().GetAllRiceBatches(ctx)) {
+            await putIndexEntry(ctx, BATCH_OWNER_INDEX, [batch.currentOwner, batch.batchId]);
+            indexed++;
+        }
+        for (const test of await new QualityCertificationContract().GetAllTestResults(ctx)) {
+            await putIndexEntry(ctx, TEST_OUTCOME_INDEX, [testOutcome(test), test.testDate, test.testId]);
+            indexed++;
+        }
+        for (const product of await new ProductManagementContract().GetAllProducts(ctx)) {
+            if (product.composition?.bestBefore && product.status !== DISPOSED_STATE) {
+                await putIndexEntry(ctx, BEST_BEFORE_INDEX, [product.composition.bestBefore, product.productId]);
+                indexed++;
+            }
+        }
+        return indexed;
+    }
+
+    /**
+     * Check the parameters of a named query: all declared parameters present, no others, timestamps normalized
+     */
+    private parseParameters(
+        name: string,
+        query: NamedQuery,
+        paramsJSON: string
+    ): { params: Record<string, string>; pageSize: number; bookmark: string } {
+        let input: any;
+        try {
+            input = JSON.parse(paramsJSON || '{}');
+        } catch (error) {
+            throw new Error(`Query parameters format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('Query parameters must be an object');
+        }
+
+        const unexpected = Object.keys(input).filter(key => !(key in query.parameters) && !PAGING_PARAMETERS.includes(key));
+        if (unexpected.length > 0) {
+            throw new Error(`Parameters not used by query ${name}: ${unexpected.join(', ')}`);
+        }
+
+        const params: Record<string, string> = {};
+        for (const [parameter, kind] of Object.entries(query.parameters)) {
+            const value = input[parameter] === undefined || input[parameter] === null ? '' : String(input[parameter]);
+            if (!value) {
+                throw new Error(`Query ${name} requires parameter ${parameter}`);
+            }
+            params[parameter] = kind === 'timestamp' ? normalizeTimestamp(value, parameter) : value;
+        }
+
+        const pageSize = input.pageSize === undefined ? DEFAULT_QUERY_PAGE_SIZE : Number(input.pageSize);
+        if (!Number.isInteger(pageSize) || pageSize <= 0 || pageSize > MAX_QUERY_PAGE_SIZE) {
+            throw new Error(`Invalid page size ${input.pageSize}: must be an integer between 1 and ${MAX_QUERY_PAGE_SIZE}`);
+        }
+        return { params, pageSize, bookmark: input.bookmark ? String(input.bookmark) : '' };
+    }
+}
@@ -0,0 +1,358 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { OrganizationType, Product, Recall, RecallAcknowledgment, RecallCompletion, RecallNotice, RecallOwnerStatus, RiceBatch } from './types';
+import { PRODUCT_BATCH_INDEX } from './productManagementContract';
+import { getParticipantsByIdAndName } from './participantRegistryContract';
+import { assertBulkSize } from './inputLimitContract';
+import { refreshBatchStatus } from './batchStatusContract';
+import {
+    readDocument, writeDocument, getTxTimestamp, emitEvent, putIndexEntry, getIndexEntries, getCallerFingerprint, DISPOSED_STATE,
+    checkOrgType, inheritTenant
+} from './utils';
+
+/**
+ * Composite key index of recalls by the batches and products they cover
+ */
+export const RECALL_ITEM_INDEX = 'recallEntity~recallId';
+
+/**
+ * Composite key index of recall acknowledgments by recall
+ */
+export const RECALL_ACKNOWLEDGMENT_INDEX = 'recallId~acknowledgmentId';
+
+/**
+ * What an owner did with recalled stock; NotInStock confirms the owner no longer holds the items
+ */
+export const RECALL_DISPOSITIONS = ['Quarantined', 'Returned', 'Destroyed', 'NotInStock'];
+
+@Info({ title: 'RecallContract', description: 'Smart contract issuing recalls and notifying the current owners of the recalled rice' })
+export class RecallContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "RecallContract Method Permission Configuration": {
+                "IssueRecall": ["Farm", "Middleman/Tester"],
+                "ReadRecall": ["All Organizations"],
+                "GetRecallsByEntity": ["All Organizations"],
+                "AcknowledgeRecall": ["Organization of the notified owner"],
+                "GetRecallAcknowledgments": ["All Organizations"],
+                "GetRecallCompletion": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Issue a recall of batches and products, and work out who has to act on it
+     * itemsJSON: { batchIds: [...], productIds: [...] }, at least one item. A recalled batch brings in every product
+     * packaged from it. The current owners of the recalled items are grouped into one notice each; Fabric keeps one
+     * event per transaction, so the notices travel in a single RecallIssued event and the event bridge delivers a
+     * RecallNotice to each owner
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    @Returns('Recall')
+    public async IssueRecall(ctx: Context, recallId: string, reason: string, itemsJSON: string): Promise<Recall> {
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!recallId) {
+            throw new Error('Recall ID is required');
+        }
+        if (!reason) {
+            throw new Error('Recall reason is required');
+        }
+        // Recall IDs are unique across tenants
+        const existing = await ctx.stub.getState(`recall_${recallId}`);
+        if (existing && existing.length > 0) {
+            throw new Error(`The recall ${recallId} already exists`);
+        }
+
+        let items: { batchIds?: unknown; productIds?: unknown };
+        try {
+            items = JSON.parse(itemsJSON);
+        } catch (error) {
+            throw new Error(`Recall items format error: ${error}`);
+        }
+        const batchIds = this.parseIds(items?.batchIds, 'batchIds');
+        const namedProductIds = this.parseIds(items?.productIds, 'productIds');
+        if (batchIds.length + namedProductIds.length === 0) {
+            throw new Error('A recall must cover at least one batch or product');
+        }
+        await assertBulkSize(ctx, batchIds.length + namedProductIds.length, `Recall ${recallId}`);
+
+        const noticesByOwner = new Map<string, RecallNotice>();
+        const noticeOf = (owner: string, ownerMspId: string): RecallNotice => {
+            const key = `${ownerMspId}/${owner}`;
+            if (!noticesByOwner.has(key)) {
+                noticesByOwner.set(key, { owner, ownerMspId, batchIds: [], productIds: [] });
+            }
+            return noticesByOwner.get(key) as RecallNotice;
+        };
+
+        const sources: Array<RiceBatch | Product> = [];
+        const productIds = new Set<string>();
+        const addProduct = (product: Product) => {
+            // Disposed products are out of the supply chain; nobody has to act on them
+            if (!productIds.has(product.productId) && product.status !== DISPOSED_STATE) {
+                productIds.add(product.productId);
+                noticeOf(product.owner, product.ownerMspId || '').productIds.push(product.productId);
+            }
+        };
+
+        for (const batchId of batchIds) {
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+            if (!batch) {
+                throw new Error(`The rice batch ${batchId} does not exist`);
+            }
+            sources.push(batch);
+            if (!batch.disposal && batch.currentState !== DISPOSED_STATE) {
+                const lastEvent = batch.history.length > 0 ? batch.history[batch.history.length - 1] : undefined;
+                noticeOf(batch.currentOwner, lastEvent && lastEvent.signerMspId ? lastEvent.signerMspId : '').batchIds.push(batchId);
+            }
+            for (const [, productId] of await getIndexEntries(ctx, PRODUCT_BATCH_INDEX, [batchId])) {
+                const product = await readDocument<Product>(ctx, `product_${productId}`);
+                // Guard against stale index entries
+                if (product && product.batchId === batchId) {
+                    addProduct(product);
+                }
+            }
+        }
+        for (const productId of namedProductIds) {
+            const product = await readDocument<Product>(ctx, `product_${productId}`);
+            if (!product) {
+                throw new Error(`The product ${productId} does not exist`);
+            }
+            sources.push(product);
+            addProduct(product);
+        }
+
+        const participants = await getParticipantsByIdAndName(ctx);
+        const notices = [...noticesByOwner.values()]
+            .map(notice => {
+                const participant = participants.get(notice.owner);
+                return participant ? { ...notice, participantId: participant.participantId } : notice;
+            })
+            .sort((a, b) => a.owner.localeCompare(b.owner) || a.ownerMspId.localeCompare(b.ownerMspId));
+
+        const recall: Recall = {
+            docType: 'recall',
+            recallId,
+            reason,
+            batchIds,
+            productIds: [...productIds],
+            notices,
+            issuedAt: getTxTimestamp(ctx),
+            issuedBy: ctx.clientIdentity.getMSPID()
+        };
+        // The items are all visible to the caller, so those of a tenant belong to the caller's tenant
+        sources.forEach(source => inheritTenant(recall, source));
+
+        await writeDocument(ctx, `recall_${recallId}`, recall);
+        for (const entityId of new Set([...batchIds, ...recall.productIds])) {
+            await putIndexEntry(ctx, RECALL_ITEM_INDEX, [entityId, recallId]);
+        }
+        for (const batchId of batchIds) {
+            await refreshBatchStatus(ctx, batchId, { recalled: true });
+        }
+        emitEvent(ctx, 'RecallIssued', recall);
+        return recall;
+    }
+
+    /**
+     * Read a recall
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Recall')
+    public async ReadRecall(ctx: Context, recallId: string): Promise<Recall> {
+        const recall = await readDocument<Recall>(ctx, `recall_${recallId}`);
+        if (!recall) {
+            throw new Error(`The recall ${recallId} does not exist`);
+        }
+        return recall;
+    }
+
+    /**
+     * Get the recalls covering a batch or product
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('Recall[]')
+    public async GetRecallsByEntity(ctx: Context, entityId: string): Promise<Recall[]> {
+        const recalls: Recall[] = [];
+        for (const [, recallId] of await getIndexEntries(ctx, RECALL_ITEM_INDEX, [entityId])) {
+            const recall = await readDocument<Recall>(ctx, `recall_${recallId}`);
+            if (recall) {
+                recalls.push(recall);
+            }
+        }
+        return recalls;
+    }
+
+    /**
+     * Confirm that an owner named in a recall has pulled recalled stock
+     * ownerId is the owner of the notice as recorded, or its participant ID; productIdsJSON lists the products and
+     * batches of the notice the acknowledgment covers, or is empty ([]) for all of them. disposition is one of
+     * Quarantined, Returned, Destroyed or NotInStock. An owner can acknowledge its items in several parts, e.g. with a
+     * different disposition each; an item acknowledged again takes the latest disposition
+     * Permission: The organization of the notified owner; any organization when the owner's organization is unknown
+     */
+    @Transaction()
+    @Returns('RecallAcknowledgment')
+    public async AcknowledgeRecall(ctx: Context, recallId: string, ownerId: string, productIdsJSON: string, disposition: string): Promise<RecallAcknowledgment> {
+        const recall = await this.ReadRecall(ctx, recallId);
+        const mspId = ctx.clientIdentity.getMSPID();
+        const candidates = recall.notices.filter(notice => notice.owner === ownerId || notice.participantId === ownerId);
+        if (candidates.length === 0) {
+            throw new Error(`The recall ${recallId} has no notice for ${ownerId}`);
+        }
+        // The same owner name can be held in several organizations; prefer the caller's
+        const notice = candidates.find(candidate => candidate.ownerMspId === mspId)
+            || candidates.find(candidate => !candidate.ownerMspId);
+        if (!notice) {
+            throw new Error(`Permission denied: Only ${candidates.map(candidate => candidate.ownerMspId).join(', ')} can acknowledge the recall ${recallId} for ${ownerId}`);
+        }
+        if (!RECALL_DISPOSITIONS.includes(disposition)) {
+            throw new Error(`Invalid disposition: ${disposition}. Allowed values: ${RECALL_DISPOSITIONS.join(', ')}`);
+        }
+
+        let productIds: unknown;
+        try {
+            productIds = JSON.parse(productIdsJSON || '[]');
+        } catch (error) {
+            throw new Error(`Product IDs format error: ${error}`);
+        }
+        const noticeItemIds = [...notice.batchIds, ...notice.productIds];
+        let itemIds = this.parseIds(productIds, 'productIds');
+        const foreign = itemIds.filter(itemId => !noticeItemIds.includes(itemId));
+        if (foreign.length > 0) {
+            throw new Error(`${foreign.join(', ')} ${foreign.length === 1 ? 'is' : 'are'} not part of the recall notice to ${notice.owner}`);
+        }
+        if (itemIds.length === 0) {
+            itemIds = noticeItemIds;
+        }
+
+        const acknowledgment: RecallAcknowledgment = {
+            docType: 'recallAcknowledgment',
+            acknowledgmentId: ctx.stub.getTxID(),
+            recallId,
+            owner: notice.owner,
+            ownerMspId: notice.ownerMspId,
+            itemIds,
+            disposition,
+            acknowledgedByMspId: mspId,
+            acknowledgedByFingerprint: getCallerFingerprint(ctx),
+            acknowledgedAt: getTxTimestamp(ctx)
+        };
+        inheritTenant(acknowledgment, recall);
+        // One document per acknowledgment, so owners answering at the same time do not conflict on the recall
+        await writeDocument(ctx, `recallack_${acknowledgment.acknowledgmentId}`, acknowledgment);
+        await putIndexEntry(ctx, RECALL_ACKNOWLEDGMENT_INDEX, [recallId, acknowledgment.acknowledgmentId]);
+        emitEvent(ctx, 'RecallAcknowledged', acknowledgment);
+        return acknowledgment;
+    }
+
+    /**
+     * Get the acknowledgments of a recall, oldest first
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('RecallAcknowledgment[]')
+    public async GetRecallAcknowledgments(ctx: Context, recallId: string): Promise<RecallAcknowledgment[]> {
+        await this.ReadRecall(ctx, recallId);
+        const acknowledgments: RecallAcknowledgment[] = [];
+        for (const [, acknowledgmentId] of await getIndexEntries(ctx, RECALL_ACKNOWLEDGMENT_INDEX, [recallId])) {
+            const acknowledgment = await readDocument<RecallAcknowledgment>(ctx, `recallack_${acknowledgmentId}`);
+            if (acknowledgment) {
+                acknowledgments.push(acknowledgment);
+            }
+        }
+        return acknowledgments.sort((a, b) => a.acknowledgedAt.localeCompare(b.acknowledgedAt));
+    }
+
+    /**
+     * Get the completion of a recall: for each notified owner whether it has responded, Responded when every item of
+     * its notice is acknowledged, Partial when some are, NoResponse otherwise, with the items still pending and the
+     * acknowledged items per disposition. Owners are listed silent ones first, then by name
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('RecallCompletion')
+    public async GetRecallCompletion(ctx: Context, recallId: string): Promise<RecallCompletion> {
+        const recall = await this.ReadRecall(ctx, recallId);
+        const acknowledgments = await this.GetRecallAcknowledgments(ctx, recallId);
+
+        const statusOrder: Record<string, number> = { NoResponse: 0, Partial: 1, Responded: 2 };
+        const ownerStatuses = recall.notices.map(notice => {
+            const itemIds = [...notice.batchIds, ...notice.productIds];
+            // Acknowledgments are sorted oldest first, so the latest disposition of an item wins
+            const dispositionByItem = new Map<string, string>();
+            let lastAcknowledgedAt: string | undefined;
+            for (const acknowledgment of acknowledgments) {
+                if (acknowledgment.owner === notice.owner && acknowledgment.ownerMspId === notice.ownerMspId) {
+                    acknowledgment.itemIds.forEach(itemId => dispositionByItem.set(itemId, acknowledgment.disposition));
+                    lastAcknowledgedAt = acknowledgment.acknowledgedAt;
+                }
+            }
+            const dispositions: Record<string, number> = {};
+            for (const disposition of dispositionByItem.values()) {
+                dispositions[disposition] = (dispositions[disposition] || 0) + 1;
+            }
+            const pendingItemIds = itemIds.filter(itemId => !dispositionByItem.has(itemId));
+            const status: RecallOwnerStatus = {
+                owner: notice.owner,
+                ownerMspId: notice.ownerMspId,
+                status: pendingItemIds.length === 0 ? 'Responded' : dispositionByItem.size > 0 ? 'Partial' : 'NoResponse',
+                items: itemIds.length,
+                acknowledgedItems: itemIds.length - pendingItemIds.length,
+                pendingItemIds,
+                dispositions
+            };
+            if (notice.participantId) {
+                status.participantId = notice.participantId;
+            }
+            if (lastAcknowledgedAt) {
+                status.lastAcknowledgedAt = lastAcknowledgedAt;
+            }
+            return status;
+        }).sort((a, b) => statusOrder[a.status] - statusOrder[b.status] || a.owner.localeCompare(b.owner));
+
+        const items = ownerStatuses.reduce((sum, status) => sum + status.items, 0);
+        const acknowledgedItems = ownerStatuses.reduce((sum, status) => sum + status.acknowledgedItems, 0);
+        return {
+            recallId,
+            issuedAt: recall.issuedAt,
+            owners: ownerStatuses.length,
+            respondedOwners: ownerStatuses.filter(status => status.status === 'Responded').length,
+            partialOwners: ownerStatuses.filter(status => status.status === 'Partial').length,
+            silentOwners: ownerStatuses.filter(status => status.status === 'NoResponse').length,
+            items,
+            acknowledgedItems,
+            completionRate: items === 0 ? 1 : Math.round(acknowledgedItems / items * 10000) / 10000,
+            ownerStatuses
+        };
+    }
+
+    /**
+     * Validate an optional list of entity IDs from the recall items
+     */
+    private parseIds(value: unknown, fieldName: string): string[] {
+        if (value === undefined || value === null) {
+            return [];
+        }
+        if (!Array.isArray(value) || value.some(id => typeof id !== 'string' || !id)) {
+            throw new Error(`${fieldName} must be a list of IDs`);
+        }
+        return [...new Set(value as string[])];
+    }
+}
@@ -0,0 +1,199 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import stringify from 'json-stringify-deterministic';
+import sortKeysRecursive from 'sort-keys-recursive';
+import { OrganizationType, ResourceTotals, ResourceUsage, ResourceUsageSummary, RiceBatch } from './types';
+import { parseInterval, assertPeerOrgMatchesClient, implicitCollectionName, checkOrgType, inheritTenant, isVisibleToCaller } from './utils';
+
+/**
+ * Transient data key carrying the resource usage of the records passed to AddProcessingRecords
+ */
+export const RESOURCES_TRANSIENT_KEY = 'resources';
+
+/**
+ * Private data key prefix of resource usage records
+ */
+const RESOURCE_USAGE_PREFIX = 'resource_';
+
+/**
+ * Measured resources a processing record can carry, all non-negative
+ */
+const RESOURCE_FIELDS: Array<'energyKwh' | 'laborHours' | 'machineHours'> = ['energyKwh', 'laborHours', 'machineHours'];
+
+/**
+ * Key of the resource usage of a history event; zero-padded so a batch's records sort in history order
+ */
+function resourceUsageKey(batchId: string, eventIndex: number): string {
+    return `${RESOURCE_USAGE_PREFIX}${batchId}_${String(eventIndex).padStart(6, '0')}`;
+}
+
+/**
+ * Read the resource usage passed with recordCount processing records in the "resources" transient field: a list
+ * aligned with the records, each entry { energyKwh?, laborHours?, machineHours? } or null for a record without.
+ * The usage goes to the caller's implicit collection, so it must be submitted to a peer of the caller's organization.
+ * Returns one entry per record, or an empty list when the field is absent
+ */
+export function readResourceTransient(ctx: Context, recordCount: number): Array<Partial<ResourceUsage> | null> {
+    const resourcesBytes = ctx.stub.getTransient().get(RESOURCES_TRANSIENT_KEY);
+    if (!resourcesBytes || resourcesBytes.length === 0) {
+        return [];
+    }
+    assertPeerOrgMatchesClient(ctx);
+
+    let input: unknown;
+    try {
+        input = JSON.parse(Buffer.from(resourcesBytes).toString('utf8'));
+    } catch (error) {
+        throw new Error(`Resource usage format error: ${error}`);
+    }
+    if (!Array.isArray(input) || input.length !== recordCount) {
+        throw new Error(`Resource usage must be a list with one entry (or null) per processing record, ${recordCount} in all`);
+    }
+    return input.map((entry, position) => {
+        if (entry === null) {
+            return null;
+        }
+        if (typeof entry !== 'object' || Array.isArray(entry)) {
+            throw new Error(`Resource usage of record ${position} must be an object`);
+        }
+        const usage: Partial<ResourceUsage> = {};
+        for (const field of RESOURCE_FIELDS) {
+            const value = (entry as Record<string, unknown>)[field];
+            if (value === undefined) {
+                continue;
+            }
+            if (typeof value !== 'number' || !Number.isFinite(value) || value < 0) {
+                throw new Error(`${field} of record ${position} must be a non-negative number`);
+            }
+            usage[field] = value;
+        }
+        if (Object.keys(usage).length === 0) {
+            throw new Error(`Resource usage of record ${position} must give ${RESOURCE_FIELDS.join(', ')} or be null`);
+        }
+        return usage;
+    });
+}
+
+/**
+ * Store the resource usage of a processing record of a batch in the caller's implicit collection
+ */
+export async function recordResourceUsage(
+    ctx: Context, batch: RiceBatch, eventIndex: number, step: string, timestamp: string, usage: Partial<ResourceUsage>
+): Promise<void> {
+    const mspId = ctx.clientIdentity.getMSPID();
+    const record: ResourceUsage = {
+        ...usage,
+        docType: 'resourceUsage',
+        batchId: batch.batchId,
+        eventIndex,
+        step,
+        timestamp,
+        mspId,
+        txId: ctx.stub.getTxID()
+    };
+    inheritTenant(record, batch);
+    await ctx.stub.putPrivateData(
+        implicitCollectionName(mspId), resourceUsageKey(batch.batchId, eventIndex), Buffer.from(stringify(sortKeysRecursive(record)))
+    );
+}
+
+@Info({ title: 'ResourceUsageContract', description: 'Smart contract reporting the private energy, labor and machine time of processing steps' })
+export class ResourceUsageContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "ResourceUsageContract Method Permission Configuration": {
+                "GetResourceUsage": ["Farm", "Middleman/Tester (own organization's records only)"],
+                "GetResourceUsageSummary": ["Farm", "Middleman/Tester (own organization's records only)"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Get the resource usage the caller's organization recorded for the processing steps of a batch, in history order
+     * Served from the organization's implicit collection, so it must be evaluated on one of its own peers
+     * Permission: Farm and middleman/tester can call, for their own organization's records only
+     */
+    @Transaction(false)
+    @Returns('ResourceUsage[]')
+    public async GetResourceUsage(ctx: Context, batchId: string): Promise<ResourceUsage[]> {
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+        assertPeerOrgMatchesClient(ctx);
+
+        return this.readUsage(ctx, `${RESOURCE_USAGE_PREFIX}${batchId}_`);
+    }
+
+    /**
+     * Sum the resource usage of the caller's organization over a period ("<start>/<end>", dates or RFC3339 times),
+     * in total and per processing step, e.g. for the energy cost of milling per quarter
+     * Permission: Farm and middleman/tester can call, for their own organization's records only
+     */
+    @Transaction(false)
+    @Returns('ResourceUsageSummary')
+    public async GetResourceUsageSummary(ctx: Context, period: string): Promise<ResourceUsageSummary> {
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+        assertPeerOrgMatchesClient(ctx);
+        const { from, to } = parseInterval(period, 'period');
+
+        const add = (totals: ResourceTotals, usage: ResourceUsage) => {
+            totals.records++;
+            for (const field of RESOURCE_FIELDS) {
+                totals[field] = Math.round((totals[field] + (usage[field] || 0)) * 1000) / 1000;
+            }
+        };
+        const summary: ResourceUsageSummary = {
+            mspId: ctx.clientIdentity.getMSPID(),
+            from,
+            to,
+            batches: 0,
+            totals: { records: 0, energyKwh: 0, laborHours: 0, machineHours: 0 },
+            byStep: {}
+        };
+        const batchIds = new Set<string>();
+        for (const usage of await this.readUsage(ctx, RESOURCE_USAGE_PREFIX)) {
+            if (usage.timestamp < from || usage.timestamp > to) {
+                continue;
+            }
+            batchIds.add(usage.batchId);
+            add(summary.totals, usage);
+            if (!summary.byStep[usage.step]) {
+                summary.byStep[usage.step] = { records: 0, energyKwh: 0, laborHours: 0, machineHours: 0 };
+            }
+            add(summary.byStep[usage.step], usage);
+        }
+        summary.batches = batchIds.size;
+        return summary;
+    }
+
+    /**
+     * Read the caller's resource usage records under a key prefix, leaving out those of other tenants
+     */
+    private async readUsage(ctx: Context, prefix: string): Promise<ResourceUsage[]> {
+        const collection = implicitCollectionName(ctx.clientIdentity.getMSPID());
+        const iterator = await ctx.stub.getPrivateDataByRange(collection, prefix, `${prefix}\uffff`);
+        const records: ResourceUsage[] = [];
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                const record: ResourceUsage = JSON.parse(result.value.value.toString());
+                if (isVisibleToCaller(ctx, record)) {
+                    records.push(record);
+                }
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+        return records;
+    }
+}
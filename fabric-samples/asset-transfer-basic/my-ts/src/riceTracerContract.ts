@@ -5,45 +5,478 @@
 import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
 import stringify from 'json-stringify-deterministic';
 import sortKeysRecursive from 'sort-keys-recursive';
-import { RiceBatch, OrganizationType, OrganizationInfo, HistoryEvent, ReportDetail } from './types';
+import {
+    RiceBatch, OrganizationType, OrganizationInfo, ContractVersion, HistoryEvent, ReportDetail, ProcessingWorkflow, TestResult, Product, Participant,
+    CommercialTerms, CommercialTermsCommitment, ValueCommitment, DailyStats, Disposal, ForeignBatchReference, ForeignProvenanceSummary,
+    ForeignReferenceVerification, BatchStateHash, BatchGenealogy, BatchHistoryDiff, GenealogyNode, GenealogyEdge, InsurancePolicy, InsuranceClaim, InsuranceEvidence, WeatherObservation,
+    Delegation, TransferCheck, TransferBlocker, ProcessingRecordCorrection, OwnerInventory, InventoryTotals, BatchQueryResult,
+    BatchComparison, ComparedBatch, QualityCertificate, IntegrityIssue, ReferentialIntegrityReport, NetworkKpis, Settlement
+} from './types';
+import { QualityCertificationContract, TEST_OUTCOME_INDEX, isPassedTest } from './qualityCertificationContract';
+import {
+    OWNER_INDEX, BEST_BEFORE_INDEX, PRODUCT_LABEL_INDEX, PRODUCT_STATUS_INDEX, PRODUCT_BATCH_INDEX, PACKAGE_DATE_INDEX, ProductManagementContract
+} from './productManagementContract';
+import { PLOT_WEATHER_INDEX } from './weatherDataContract';
+import { PRICE_INDEX } from './marketPriceContract';
+import { ENTITY_ATTACHMENT_INDEX } from './attachmentContract';
+import { EQUIPMENT_USAGE_INDEX, assertEquipmentUsable, recordEquipmentUsage } from './equipmentContract';
+import { AGRO_INPUT_INDEX, validateAgroInputs, recordAgroInputs } from './agroInputContract';
+import { FACILITY_ACTIVITY_INDEX, assertFacilityContext, recordFacilityActivity } from './facilityContract';
+import { DOCUMENT_ACKNOWLEDGMENT_INDEX, DOCUMENT_HASH_INDEX, ENTITY_DOCUMENT_INDEX } from './documentAnchorContract';
+import { CONSIGNMENT_ITEM_INDEX } from './consignmentContract';
+import { getBatchWorkflowSteps } from './processingWorkflowContract';
+import { SCHEDULED_TRANSFER_INDEX } from './scheduledTransferContract';
+import { RECALL_ACKNOWLEDGMENT_INDEX, RECALL_ITEM_INDEX } from './recallContract';
+import { BATCH_STATUS_INDEX, deriveBatchStatus, withBatchStatus } from './batchStatusContract';
+import { BATCH_TEST_INDEX, appendHistoryEvent, assertHistoryEventFits, updateHistoryEvent, withArchivedHistory } from './batchStorageContract';
+import { CROP_SEASON_INDEX, resolveCropSeason } from './cropSeasonContract';
+import { consumeReservations, reservedQuantity } from './batchReservationContract';
+import { ID_SEQUENCE_PREFIX, assertIdConforms } from './identifierPolicyContract';
+import { COMPLIANCE_PROFILE_PREFIX, readDomesticCountry } from './complianceProfileContract';
+import { assertReportSize, readInputLimits } from './inputLimitContract';
+import { readResourceTransient, recordResourceUsage } from './resourceUsageContract';
+import { getParticipantsByIdAndName } from './participantRegistryContract';
+import {
+    readDocument, writeDocument, patchDocument, normalizeTimestamp, normalizeEndTimestamp, assertNotBefore, isPassingResult, getCertificateExpiry,
+    certificateFingerprint, getCallerFingerprint, putIndexEntry, deleteIndexEntry, getIndexEntries, checkOrgAdmin,
+    createDisposal, DISPOSED_STATE, emitEvent, isProcessedRequest, markRequestProcessed, implicitCollectionName,
+    assertPeerOrgMatchesClient, sha256Hex, getTxTimestamp, setKeyEndorsers, documentHash, parseInterval, diffDocuments,
+    parseLabels, updateLabelIndex, getLabeledIds, getCallerTenant, isVisibleToCaller, inheritTenant, checkOrgType, getOrganizationType
+} from './utils';
+
+/**
+ * Semantic version of the chaincode; keep in sync with package.json
+ */
+export const CONTRACT_VERSION = '1.0.0';
+
+/**
+ * Composite key index of batches by their current processing step
+ */
+const STEP_INDEX = 'step~batchId';
+
+/**
+ * Composite key index of batches by their current owner
+ */
+export const BATCH_OWNER_INDEX = 'batchOwner~batchId';
+
+/**
+ * Composite key index of batches by label key and value
+ */
+const BATCH_LABEL_INDEX = 'batchLabel~value~batchId';
+
+/**
+ * Default and maximum number of hops followed by GetBatchGenealogy
+ */
+const DEFAULT_GENEALOGY_DEPTH = 3;
+const MAX_GENEALOGY_DEPTH = 10;
+
+/**
+ * Most processing records AddProcessingRecords accepts in one transaction
+ */
+const MAX_PROCESSING_RECORDS = 100;
+
+/**
+ * Fewest and most batches CompareBatches puts side by side
+ */
+const MIN_COMPARED_BATCHES = 2;
+const MAX_COMPARED_BATCHES = 10;
+
+/**
+ * Environment variable that enables ResetLedgerState; set only on development/test peers
+ */
+const LEDGER_RESET_FLAG = 'RICETRACE_ALLOW_LEDGER_RESET';
+
+/**
+ * Traceability data removed by ResetLedgerState (workflow definitions are configuration and are kept)
+ */
+const RESET_KEY_PREFIXES = ['batch_', 'product_', 'test_', 'sample_', 'cert_', 'request_', 'participant_', 'terms_', 'stats_', 'unit_', 'shipment_', 'weather_', 'price_', 'attachment_', 'equipment_', 'gi_', 'consignment_', 'batchhistory_', 'notifypref_', 'verification_', 'verificationResult_', 'recall_', 'recallack_', 'facility_', 'document_', 'docack_', 'inspection_', 'settlement_', ID_SEQUENCE_PREFIX, COMPLIANCE_PROFILE_PREFIX];
+
+/**
+ * Transient data key carrying the InitLedger fixture set
+ */
+const FIXTURES_TRANSIENT_KEY = 'fixtures';
+
+/**
+ * Transient data key carrying the commercial terms written by SetCommercialTerms
+ */
+const TERMS_TRANSIENT_KEY = 'terms';
+
+/**
+ * Documents a value commitment can be attached to
+ */
+const COMMITTABLE_ENTITY_PREFIXES = ['batch_', 'product_', 'test_'];
+
+/**
+ * Composite key object type of value commitments (entityId, field, committing MSP ID)
+ * Each organization commits in its own slot, so committing first does not lock another organization out of a field
+ */
+const VALUE_COMMITMENT_KEY = 'commit';
+
+/**
+ * Shortest accepted commitment salt; a short salt lets a small value space be brute-forced from the hash
+ */
+const MIN_SALT_LENGTH = 16;
+
+/**
+ * Risks an insurance policy attached to a batch can cover
+ */
+const INSURANCE_COVERAGES = ['crop', 'storage', 'transport'];
+
+/**
+ * Ledger documents of the batch an insurance claim can cite as evidence, by evidence type
+ * historyEvent evidence is an index into the batch history; weather evidence is an anchored weather observation
+ * covering the incident; document evidence is the SHA-256 of an off-chain record such as a sensor logger export
+ */
+const INSURANCE_EVIDENCE_PREFIXES: Record<string, string> = {
+    testResult: 'test_',
+    certificate: 'cert_',
+    sample: 'sample_',
+    shipment: 'shipment_'
+};
+
+/**
+ * Disposal reasons counted as recalls in the daily statistics
+ */
+const RECALL_REASON_PATTERN = /recall/i;
+
+/**
+ * Window of the network KPIs in which a party that handed over or received rice counts as active
+ */
+const DEFAULT_ACTIVE_DAYS = 90;
+
+/**
+ * Actions a delegate can perform on a batch: transfer hands it to another owner, process records a step
+ * without handover
+ */
+const DELEGATION_PERMISSIONS = ['transfer', 'process'];
+
+/**
+ * Fixture set accepted by InitLedger
+ */
+interface LedgerFixtures {
+    batches?: RiceBatch[];
+    products?: Product[];
+    participants?: Participant[];
+}
 
 @Info({ title: 'RiceTracerContract', description: 'Smart contract for rice batch tracing and transfer operations' })
 export class RiceTracerContract extends Contract {
 
     /**
-     * Get organization type based on MSP ID
-     * Can be configured based on actual organization structure
+     * Delete every key in a range
+     */
+    private async deleteRange(ctx: Context, startKey: string, endKey: string): Promise<number> {
+        const keys: string[] = [];
+        const resultsIterator = await ctx.stub.getStateByRange(startKey, endKey);
+        let result = await resultsIterator.next();
+        while (!result.done) {
+            if (result.value) {
+                keys.push(result.value.key);
+            }
+            result = await resultsIterator.next();
+        }
+        await resultsIterator.close();
+
+        for (const key of keys) {
+            await ctx.stub.deleteState(key);
+        }
+        return keys.length;
+    }
+
+    /**
+     * Write a fixture set to the ledger
+     * Fixtures are validated like regular input: dates are normalized, products must reference a known batch,
+     * and existing or duplicate keys are rejected. Indexes are maintained for the seeded documents.
      */
-    private getOrganizationType(mspId: string): OrganizationType {
-        // Map MSP ID to organization type
-        // Can be modified based on actual organization structure
-        const mspToOrgType: Record<string, OrganizationType> = {
-            'Org1MSP': OrganizationType.FARM,              // Farm organization
-            'Org2MSP': OrganizationType.MIDDLEMAN_TESTER,  // Middleman/tester organization
-            'Org3MSP': OrganizationType.CONSUMER           // Consumer organization
+    private async seedFixtures(ctx: Context, fixturesJSON: string): Promise<void> {
+        let fixtures: LedgerFixtures;
+        try {
+            fixtures = JSON.parse(fixturesJSON);
+        } catch (error) {
+            throw new Error(`Fixture format error: ${error}`);
+        }
+
+        // Writes are not visible to reads in the same transaction, so duplicates within the fixtures are tracked here
+        const seededKeys = new Set<string>();
+        const seededBatches: RiceBatch[] = [];
+        const seededProducts: Product[] = [];
+        const claimKey = async (key: string): Promise<void> => {
+            if (seededKeys.has(key)) {
+                throw new Error(`Fixture ${key} is defined more than once`);
+            }
+            // Keys are shared across tenants, so documents of other tenants are checked as well
+            const existing = await ctx.stub.getState(key);
+            if (existing && existing.length > 0) {
+                throw new Error(`Fixture ${key} already exists on the ledger`);
+            }
+            seededKeys.add(key);
         };
 
-        return mspToOrgType[mspId] || OrganizationType.CONSUMER;
+        for (const batch of fixtures.batches || []) {
+            if (!batch.batchId || !batch.currentOwner || !batch.currentState) {
+                throw new Error('Fixture batches require batchId, currentOwner and currentState');
+            }
+            await claimKey(`batch_${batch.batchId}`);
+
+            const harvestDate = normalizeTimestamp(batch.harvestDate, `harvestDate of batch ${batch.batchId}`);
+            const seeded: RiceBatch = {
+                ...batch,
+                docType: 'riceBatch',
+                harvestDate,
+                ...resolveCropSeason(harvestDate, batch.cropYear ? String(batch.cropYear) : '', batch.season || ''),
+                history: batch.history || []
+            };
+            seededBatches.push(seeded);
+            await putIndexEntry(ctx, STEP_INDEX, [seeded.currentState, seeded.batchId]);
+            await putIndexEntry(ctx, BATCH_OWNER_INDEX, [seeded.currentOwner, seeded.batchId]);
+            await putIndexEntry(ctx, CROP_SEASON_INDEX, [String(seeded.cropYear), seeded.season as string, seeded.batchId]);
+        }
+
+        for (const product of fixtures.products || []) {
+            if (!product.productId || !product.batchId || !product.owner) {
+                throw new Error('Fixture products require productId, batchId and owner');
+            }
+            if (!seededKeys.has(`batch_${product.batchId}`) && !(await this.RiceBatchExists(ctx, product.batchId))) {
+                throw new Error(`Fixture product ${product.productId} references unknown batch ${product.batchId}`);
+            }
+            await claimKey(`product_${product.productId}`);
+
+            const seeded: Product = {
+                status: 'Active',
+                transfers: [],
+                ...product,
+                docType: 'product',
+                packageDate: normalizeTimestamp(product.packageDate, `packageDate of product ${product.productId}`)
+            };
+            await writeDocument(ctx, `product_${product.productId}`, seeded);
+            if (seeded.status !== DISPOSED_STATE) {
+                await putIndexEntry(ctx, OWNER_INDEX, [seeded.owner, seeded.productId]);
+            }
+            await putIndexEntry(ctx, PRODUCT_STATUS_INDEX, [seeded.status || 'Active', seeded.productId]);
+            await putIndexEntry(ctx, PRODUCT_BATCH_INDEX, [seeded.batchId, seeded.productId]);
+            await putIndexEntry(ctx, PACKAGE_DATE_INDEX, [seeded.packageDate, seeded.productId]);
+            seededProducts.push(seeded);
+        }
+
+        // Batches are written once their products are known, which their status depends on
+        for (const batch of seededBatches) {
+            const products = seededProducts.filter(product => product.batchId === batch.batchId);
+            const seeded = { ...batch, status: await deriveBatchStatus(ctx, batch, { products }) };
+            await writeDocument(ctx, `batch_${batch.batchId}`, seeded);
+            await putIndexEntry(ctx, BATCH_STATUS_INDEX, [seeded.status, seeded.batchId]);
+        }
+
+        for (const participant of fixtures.participants || []) {
+            if (!participant.participantId || !participant.name) {
+                throw new Error('Fixture participants require participantId and name');
+            }
+            await claimKey(`participant_${participant.participantId}`);
+            await writeDocument(ctx, `participant_${participant.participantId}`, { ...participant, docType: 'participant' });
+        }
+    }
+
+    /**
+     * Disposed batches are in a terminal state and cannot be changed any further
+     */
+    private assertNotDisposed(batch: RiceBatch): void {
+        if (batch.disposal || batch.currentState === DISPOSED_STATE) {
+            throw new Error(`The rice batch ${batch.batchId} has been disposed of and cannot be changed`);
+        }
+    }
+
+    /**
+     * Only the organization that registered a batch (signer of its first history event) manages its delegations
+     */
+    private checkBatchRegistrant(ctx: Context, batch: RiceBatch, action: string): void {
+        const registrant = batch.history.length > 0 ? batch.history[0].signerMspId : undefined;
+        if (!registrant || registrant !== ctx.clientIdentity.getMSPID()) {
+            throw new Error(`Permission denied: Only the organization that registered batch ${batch.batchId} (${registrant || 'unknown'}) can ${action}`);
+        }
     }
 
     /**
-     * Check if the caller has permission to perform specific operations
+     * The caller's unrevoked, unexpired delegation on a batch allowing the action, if any
      */
-    private checkPermission(ctx: Context, allowedTypes: OrganizationType[]): void {
+    private findActiveDelegation(ctx: Context, batch: RiceBatch, action: string): Delegation | undefined {
+        const delegations = batch.delegations || [];
+        if (delegations.length === 0) {
+            return undefined;
+        }
         const mspId = ctx.clientIdentity.getMSPID();
-        const callerType = this.getOrganizationType(mspId);
-
-        if (!allowedTypes.includes(callerType)) {
-            const allowedNames = allowedTypes.map(type => {
-                switch (type) {
-                    case OrganizationType.FARM: return 'Farm';
-                    case OrganizationType.MIDDLEMAN_TESTER: return 'Middleman/tester';
-                    case OrganizationType.CONSUMER: return 'Consumer';
-                    default: return 'Unknown';
+        const fingerprint = getCallerFingerprint(ctx);
+        const now = getTxTimestamp(ctx);
+        return delegations.find(delegation =>
+            delegation.delegateMspId === mspId &&
+            delegation.delegateFingerprint === fingerprint &&
+            !delegation.revokedAt &&
+            delegation.expiresAt > now &&
+            delegation.permissions.includes(action)
+        );
+    }
+
+    /**
+     * The batch, product or test result with the ID, if it exists and is visible to the caller
+     */
+    private async readCommittableEntity(ctx: Context, entityId: string): Promise<{ tenantId?: string } | null> {
+        for (const prefix of COMMITTABLE_ENTITY_PREFIXES) {
+            const entity = await readDocument<{ tenantId?: string }>(ctx, `${prefix}${entityId}`);
+            if (entity) {
+                return entity;
+            }
+        }
+        return null;
+    }
+
+    /**
+     * Organization currently owning a batch: the signer of its latest history event
+     */
+    private getBatchOwnerMspId(batch: RiceBatch): string {
+        const lastEvent = batch.history[batch.history.length - 1];
+        return lastEvent && lastEvent.signerMspId ? lastEvent.signerMspId : '';
+    }
+
+    /**
+     * Enforce the processing workflow referenced by the batch, if any, before a step is recorded
+     * The step must belong to the workflow, move the batch forward, skip only optional steps,
+     * and have all of its required tests passed. A batch follows the workflow as it was defined when the batch
+     * was created; batches created before definitions were snapshotted follow the current definition
+     */
+    private async enforceWorkflow(ctx: Context, batch: RiceBatch, step: string): Promise<void> {
+        if (!batch.workflowId) {
+            return;
+        }
+
+        const steps = await getBatchWorkflowSteps(ctx, batch);
+        if (!steps) {
+            throw new Error(`Workflow ${batch.workflowId} referenced by batch ${batch.batchId} does not exist`);
+        }
+        const workflowId = batch.workflowId;
+
+        const stepNames = steps.map(workflowStep => workflowStep.name);
+        const targetIndex = stepNames.indexOf(step);
+        if (targetIndex === -1) {
+            throw new Error(`Step ${step} is not part of workflow ${workflowId}, expected one of: ${stepNames.join(', ')}`);
+        }
+
+        // Furthest workflow step the batch has already reached
+        const currentIndex = batch.history.reduce((furthest, event) => Math.max(furthest, stepNames.indexOf(event.step)), -1);
+        if (targetIndex <= currentIndex) {
+            throw new Error(`Step ${step} cannot be recorded: batch ${batch.batchId} has already reached ${stepNames[currentIndex]} in workflow ${workflowId}`);
+        }
+
+        const skipped = steps.slice(currentIndex + 1, targetIndex).filter(workflowStep => !workflowStep.optional);
+        if (skipped.length > 0) {
+            throw new Error(`Step ${step} cannot be recorded: required step(s) ${skipped.map(workflowStep => workflowStep.name).join(', ')} of workflow ${workflowId} have not been completed`);
+        }
+
+        const requiredTests = steps[targetIndex].requiredTests || [];
+        if (requiredTests.length > 0) {
+            const tests = await new QualityCertificationContract().GetTestResultsByBatch(ctx, batch.batchId);
+            const missing = requiredTests.filter(testType =>
+                !tests.some(test => test.testType === testType && isPassedTest(test))
+            );
+            if (missing.length > 0) {
+                throw new Error(`Step ${step} requires passed test(s) before it can be recorded: ${missing.join(', ')}`);
+            }
+        }
+    }
+
+    /**
+     * Whether a step repeats a history event: same step and handover, backed by the same report
+     */
+    private isSameStep(event: HistoryEvent, fromOperator: string, toOperator: string, step: string, report: ReportDetail): boolean {
+        return event.step === step &&
+            event.from === fromOperator &&
+            event.to === toOperator &&
+            !!event.report &&
+            event.report.reportId === report.reportId &&
+            event.report.reportHash === report.reportHash;
+    }
+
+    /**
+     * Check that a step and the verification date its report claims do not precede the previous record of the batch
+     * Agro-input application dates are not checked: field applications legitimately predate the step recording them
+     */
+    private assertStepChronology(time: string, timeName: string, report: ReportDetail, previous: HistoryEvent | undefined): void {
+        if (!previous) {
+            return;
+        }
+        const previousName = `previous ${previous.step} event`;
+        assertNotBefore(time, timeName, previous.timestamp, previousName);
+        if (report.verificationTimestamp) {
+            assertNotBefore(report.verificationTimestamp, 'Report verificationTimestamp', previous.timestamp, previousName);
+        }
+    }
+
+    /**
+     * Check the optional verification date, geolocation and cold-chain evidence of a step report
+     */
+    private validateReportEvidence(report: ReportDetail): void {
+        if (report.verificationTimestamp) {
+            report.verificationTimestamp = normalizeTimestamp(report.verificationTimestamp, 'Report verificationTimestamp');
+        }
+        if (report.geolocation) {
+            const { latitude, longitude } = report.geolocation;
+            if (typeof latitude !== 'number' || typeof longitude !== 'number' ||
+                Math.abs(latitude) > 90 || Math.abs(longitude) > 180) {
+                throw new Error('Report geolocation must have a numeric latitude (-90 to 90) and longitude (-180 to 180)');
+            }
+        }
+        if (report.temperatureLogHash !== undefined) {
+            report.temperatureLogHash = String(report.temperatureLogHash).toLowerCase();
+            if (!/^[0-9a-f]{64}$/.test(report.temperatureLogHash)) {
+                throw new Error('Temperature log hash must be a SHA-256 digest (64 hex characters)');
+            }
+        }
+        if (report.inputs !== undefined) {
+            report.inputs = validateAgroInputs(report.inputs);
+        }
+    }
+
+    /**
+     * Enforce quality gates that apply regardless of workflow:
+     * - Packaged requires a passed moisture test dated after the batch was Dried
+     * - Shipped is blocked while quarantined, and exports require a valid phytosanitary certificate
+     */
+    private async enforceQualityGates(ctx: Context, batch: RiceBatch, step: string, report: ReportDetail, now: string): Promise<void> {
+        const qualityContract = new QualityCertificationContract();
+
+        if (step === 'Packaged') {
+            const dried = [...batch.history].reverse().find(event => event.step === 'Dried');
+            if (!dried) {
+                throw new Error(`Batch ${batch.batchId} cannot be Packaged before it has been Dried`);
+            }
+
+            const tests = await qualityContract.GetTestResultsByBatch(ctx, batch.batchId);
+            const passedMoistureTest = tests.some(test =>
+                test.testType.toLowerCase().includes('moisture') &&
+                isPassedTest(test) &&
+                Date.parse(test.testDate) >= Date.parse(dried.timestamp)
+            );
+            if (!passedMoistureTest) {
+                throw new Error(`Batch ${batch.batchId} cannot be Packaged without a passed moisture test after it was Dried (${dried.timestamp})`);
+            }
+        }
+
+        if (step === 'Shipped') {
+            if (batch.quarantined) {
+                throw new Error(`Batch ${batch.batchId} cannot be Shipped while quarantined: ${batch.quarantineReason || 'no reason recorded'}`);
+            }
+
+            // Exports need a phytosanitary certificate, so the destination must be stated for every shipment
+            const destination = (report.destinationCountry || '').trim().toUpperCase();
+            if (!/^[A-Z]{2}$/.test(destination)) {
+                throw new Error(`Batch ${batch.batchId} cannot be Shipped without the destination country (ISO 3166-1 alpha-2 code) in its report`);
+            }
+            if (destination !== await readDomesticCountry(ctx)) {
+                const certificates = await qualityContract.GetCertificatesByBatch(ctx, batch.batchId);
+                const validCertificate = certificates.some(cert =>
+                    cert.certificateType.toLowerCase().includes('phytosanitary') && this.isCertificateInForce(cert, now)
+                );
+                if (!validCertificate) {
+                    throw new Error(`Batch ${batch.batchId} cannot be Shipped to ${destination} without a valid phytosanitary certificate`);
                 }
-            }).join(', ');
-            
-            throw new Error(`Permission denied: Only the following organization types can call: ${allowedNames}`);
+            }
         }
     }
 
@@ -54,7 +487,7 @@ export class RiceTracerContract extends Contract {
     @Returns('OrganizationInfo')
     public async GetCallerInfo(ctx: Context): Promise<OrganizationInfo> {
         const mspId = ctx.clientIdentity.getMSPID();
-        const orgType = this.getOrganizationType(mspId);
+        const orgType = getOrganizationType(mspId);
         
         return {
             orgId: mspId,
@@ -63,6 +496,32 @@ export class RiceTracerContract extends Contract {
         };
     }
 
+    /**
+     * Health check: always returns true, without reading or writing state
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('boolean')
+    public async Ping(ctx: Context): Promise<boolean> {
+        return true;
+    }
+
+    /**
+     * Get the deployed contract version and build information
+     * Build information comes from RICETRACE_BUILD_COMMIT / RICETRACE_BUILD_TIME, set when the image is built
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ContractVersion')
+    public async GetContractVersion(ctx: Context): Promise<ContractVersion> {
+        return {
+            contractVersion: CONTRACT_VERSION,
+            buildCommit: process.env.RICETRACE_BUILD_COMMIT || '',
+            buildTime: process.env.RICETRACE_BUILD_TIME || '',
+            nodeVersion: process.version
+        };
+    }
+
     /**
      * Get permission configuration for all methods
      */
@@ -73,14 +532,49 @@ export class RiceTracerContract extends Contract {
             "RiceTracerContract Method Permission Configuration": {
                 "InitLedger": ["Farm"],
                 "CreateRiceBatch": ["Farm"], 
-                "CompleteStepAndTransfer": ["Farm", "Middleman/Tester"],
+                "CompleteStepAndTransfer": ["Farm", "Middleman/Tester", "Delegates of the batch"],
+                "AddProcessingRecords": ["Farm", "Middleman/Tester", "Delegates of the batch"],
+                "CanTransferRiceBatch": ["All Organizations"],
+                "CorrectProcessingRecord": ["Organization that signed the record"],
+                "DisposeBatch": ["Farm", "Middleman/Tester"],
+                "QuarantineBatch": ["Middleman/Tester"],
+                "ReleaseQuarantine": ["Middleman/Tester"],
+                "SetBatchLabels": ["Farm", "Middleman/Tester"],
+                "GrantDelegate": ["Organization that registered the batch"],
+                "RevokeDelegate": ["Organization that registered the batch"],
+                "LinkForeignBatch": ["Farm", "Middleman/Tester"],
+                "VerifyForeignBatchReference": ["All Organizations"],
+                "GetBatchStateHash": ["All Organizations"],
+                "AttachInsurancePolicy": ["Farm", "Middleman/Tester"],
+                "ClaimInsurance": ["Farm", "Middleman/Tester (policyholder organization only)"],
+                "SetCommercialTerms": ["Farm", "Middleman/Tester (owning organization only)"],
+                "ReadCommercialTerms": ["Farm", "Middleman/Tester (own organization's terms only)"],
+                "VerifyCommercialTerms": ["All Organizations"],
+                "SnapshotDailyStats": ["All Organizations"],
+                "GetDailyStats": ["All Organizations"],
+                "GetNetworkKpis": ["All Organizations"],
+                "CommitValue": ["Farm", "Middleman/Tester"],
+                "RevealValue": ["Farm", "Middleman/Tester (own commitments only)"],
+                "GetValueCommitment": ["All Organizations"],
                 "ReadRiceBatch": ["All Organizations"],
                 "RiceBatchExists": ["All Organizations"],
                 "GetAllRiceBatches": ["All Organizations"],
+                "GetRiceBatchesByProcessingStep": ["All Organizations"],
+                "GetRiceBatchesByLabel": ["All Organizations"],
+                "GetOwnerInventory": ["All Organizations"],
+                "CompareBatches": ["All Organizations"],
+                "RebuildStepIndex": ["Organization Administrators"],
+                "ValidateReferentialIntegrity": ["Organization Administrators"],
+                "ResetLedgerState": ["Organization Administrators (development networks only)"],
                 "GetBatchHistory": ["All Organizations"],
+                "GetBatchHistoryDiff": ["All Organizations"],
+                "GetBatchGenealogy": ["All Organizations"],
                 "GetBatchCurrentStatus": ["All Organizations"],
+                "VerifyRecordSigner": ["All Organizations"],
                 "GetCallerInfo": ["All Organizations"],
-                "GetPermissionMatrix": ["All Organizations"]
+                "GetPermissionMatrix": ["All Organizations"],
+                "Ping": ["All Organizations"],
+                "GetContractVersion": ["All Organizations"]
             },
             "Organization Type Description": {
                 "1": "Farm (FARM) - Responsible for creating batches and initial processing",
@@ -94,24 +588,38 @@ export class RiceTracerContract extends Contract {
 
     /**
      * Initialize ledger data
+     * Seeds the fixture set passed in the "fixtures" transient field ({ batches, products, participants }),
+     * or the built-in demo batches when no fixtures are given
      * Permission: Only farm can call
      */
     @Transaction()
     public async InitLedger(ctx: Context): Promise<void> {
         // Check permission: Only farm can initialize ledger
-        this.checkPermission(ctx, [OrganizationType.FARM]);
+        checkOrgType(ctx, [OrganizationType.FARM]);
+
+        const fixtures = ctx.stub.getTransient().get(FIXTURES_TRANSIENT_KEY);
+        if (fixtures && fixtures.length > 0) {
+            await this.seedFixtures(ctx, Buffer.from(fixtures).toString());
+            return;
+        }
 
         // Get transaction timestamp, ensure determinism
         const txTimestamp = ctx.stub.getTxTimestamp();
         const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
 
+        // The seeding farm registers the demo batches, so it can manage their delegations
+        const signerMspId = ctx.clientIdentity.getMSPID();
+        const signerFingerprint = getCallerFingerprint(ctx);
+
         const batches: RiceBatch[] = [
             {
                 docType: 'riceBatch',
                 batchId: 'batch1',
                 origin: 'Heilongjiang',
                 variety: 'Japonica',
-                harvestDate: '2024-09-15',
+                harvestDate: '2024-09-15T00:00:00.000Z',
+                cropYear: 2024,
+                season: 'Middle',
                 currentOwner: 'Farmer Zhang',
                 currentState: 'Harvested',
                 history: [
@@ -128,7 +636,9 @@ export class RiceTracerContract extends Contract {
                             isVerified: true,
                             verificationSource: 'RiceTrace-Oracle',
                             verificationTimestamp: now
-                        }
+                        },
+                        signerMspId,
+                        signerFingerprint
                     }
                 ]
             },
@@ -137,7 +647,9 @@ export class RiceTracerContract extends Contract {
                 batchId: 'batch2',
                 origin: 'Sichuan',
                 variety: 'Indica',
-                harvestDate: '2024-09-20',
+                harvestDate: '2024-09-20T00:00:00.000Z',
+                cropYear: 2024,
+                season: 'Middle',
                 currentOwner: 'Farmer Li',
                 currentState: 'Stored',
                 history: [
@@ -154,22 +666,31 @@ export class RiceTracerContract extends Contract {
                             isVerified: true,
                             verificationSource: 'RiceTrace-Oracle',
                             verificationTimestamp: now
-                        }
+                        },
+                        signerMspId,
+                        signerFingerprint
                     }
                 ]
             }
         ];
 
         for (const batch of batches) {
+            batch.status = await deriveBatchStatus(ctx, batch);
             await ctx.stub.putState(
                 `batch_${batch.batchId}`,
                 Buffer.from(stringify(sortKeysRecursive(batch)))
             );
+            await putIndexEntry(ctx, STEP_INDEX, [batch.currentState, batch.batchId]);
+            await putIndexEntry(ctx, BATCH_STATUS_INDEX, [batch.status, batch.batchId]);
+            await putIndexEntry(ctx, BATCH_OWNER_INDEX, [batch.currentOwner, batch.batchId]);
+            await putIndexEntry(ctx, CROP_SEASON_INDEX, [String(batch.cropYear), batch.season as string, batch.batchId]);
         }
     }
 
     /**
      * Create new rice batch
+     * cropYear and season (Early, Middle or Late) are checked against harvestDate; either may be empty to derive it
+     * clientRequestId: idempotency key - a retry with the same ID is not applied twice (empty to disable)
      * Permission: Only farm can call
      */
     @Transaction()
@@ -182,23 +703,41 @@ export class RiceTracerContract extends Contract {
         initialTestResultJSON: string,
         owner: string,
         initialStep: string,
-        operator: string
+        operator: string,
+        workflowId: string,
+        cropYear: string,
+        season: string,
+        clientRequestId: string
     ): Promise<void> {
         // Check permission: Only farm can create batch
-        this.checkPermission(ctx, [OrganizationType.FARM]);
+        checkOrgType(ctx, [OrganizationType.FARM]);
+
+        // A gateway retry of an already processed request is a no-op
+        if (await isProcessedRequest(ctx, clientRequestId, 'CreateRiceBatch')) {
+            return;
+        }
 
         const exists = await this.RiceBatchExists(ctx, batchId);
         if (exists) {
             throw new Error(`The rice batch ${batchId} already exists`);
         }
+        await assertIdConforms(ctx, 'batch', batchId);
+
+        // Store harvest date in normalized UTC RFC3339 form so ordering and range queries work
+        const normalizedHarvestDate = normalizeTimestamp(harvestDate, 'harvestDate');
+        const cropSeason = resolveCropSeason(normalizedHarvestDate, cropYear, season);
 
         // Parse initial test result
+        await assertReportSize(ctx, initialTestResultJSON, 'The initial test result');
         const initialTestResult = JSON.parse(initialTestResultJSON);
 
         // Get transaction timestamp
         const txTimestamp = ctx.stub.getTxTimestamp();
         const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
 
+        // A batch cannot be registered before it was harvested
+        assertNotBefore(now, 'Batch creation time', normalizedHarvestDate, 'harvestDate');
+
         // Create initial report detail based on test result
         const initialReport: ReportDetail = {
             reportId: initialTestResult.testId || initialTestResult.reportId || '',
@@ -210,6 +749,9 @@ export class RiceTracerContract extends Contract {
             verificationTimestamp: now,
             notes: initialTestResult.notes
         };
+        if (initialTestResult.inputs !== undefined) {
+            initialReport.inputs = validateAgroInputs(initialTestResult.inputs);
+        }
 
         // Create initial history event
         const initialHistoryEvent: HistoryEvent = {
@@ -217,7 +759,9 @@ export class RiceTracerContract extends Contract {
             from: '',
             to: owner,
             step: initialStep,
-            report: initialReport
+            report: initialReport,
+            signerMspId: ctx.clientIdentity.getMSPID(),
+            signerFingerprint: getCallerFingerprint(ctx)
         };
 
         const batch: RiceBatch = {
@@ -225,22 +769,55 @@ export class RiceTracerContract extends Contract {
             batchId,
             origin,
             variety,
-            harvestDate,
+            harvestDate: normalizedHarvestDate,
+            cropYear: cropSeason.cropYear,
+            season: cropSeason.season,
             currentOwner: owner,
             currentState: initialStep,
             history: [initialHistoryEvent]
         };
 
+        // Batches of a tenant (e.g. a cooperative) are only visible to identities of that tenant
+        const tenantId = getCallerTenant(ctx);
+        if (tenantId) {
+            batch.tenantId = tenantId;
+        }
+
+        // The batch keeps the workflow as defined now, so redefining it does not change the rules for batches in progress.
+        // The initial step must be a valid starting point of the workflow
+        if (workflowId) {
+            const workflow = await readDocument<ProcessingWorkflow>(ctx, `workflow_${workflowId}`);
+            if (!workflow) {
+                throw new Error(`Workflow ${workflowId} referenced by batch ${batchId} does not exist`);
+            }
+            batch.workflowId = workflowId;
+            batch.workflowVersion = workflow.version;
+            batch.workflowSteps = workflow.steps;
+            await this.enforceWorkflow(ctx, { ...batch, history: [] }, initialStep);
+        }
+        batch.status = await deriveBatchStatus(ctx, batch);
+
         await ctx.stub.putState(
             `batch_${batchId}`,
             Buffer.from(stringify(sortKeysRecursive(batch)))
         );
+        await putIndexEntry(ctx, STEP_INDEX, [initialStep, batchId]);
+        await putIndexEntry(ctx, BATCH_STATUS_INDEX, [batch.status, batchId]);
+        await putIndexEntry(ctx, BATCH_OWNER_INDEX, [owner, batchId]);
+        await putIndexEntry(ctx, CROP_SEASON_INDEX, [String(cropSeason.cropYear), cropSeason.season, batchId]);
+        if (initialReport.inputs) {
+            await recordAgroInputs(ctx, initialReport.inputs, batchId, initialStep, now);
+        }
+        await markRequestProcessed(ctx, clientRequestId, 'CreateRiceBatch');
+        emitEvent(ctx, 'BatchCreated', batch);
     }
 
     /**
      * Complete step and transfer - new unified transaction method
      * Merge processing record and ownership transfer into a single atomic operation
-     * Permission: Farm and middleman/tester can call
+     * clientRequestId: idempotency key - a retry with the same ID is not applied twice (empty to disable)
+     * Permission: Farm and middleman/tester can call, as can identities holding an active delegation on the batch
+     * for the action (transfer to another owner, or process without handover); delegated steps record both parties
      */
     @Transaction()
     public async CompleteStepAndTransfer(
@@ -249,24 +826,76 @@ export class RiceTracerContract extends Contract {
         fromOperator: string,
         toOperator: string,
         step: string,
-        reportStr: string // JSON字符串格式的ReportDetail
+        reportStr: string, // JSON字符串格式的ReportDetail
+        clientRequestId: string
     ): Promise<void> {
-        // Check permission: Farm and middleman/tester can call
-        this.checkPermission(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+        // Read the raw stored document so fields written by newer chaincode versions survive the update
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
 
-        const batch = await this.ReadRiceBatch(ctx, batchId);
+        // Check permission: Farm and middleman/tester can call; other identities only under a delegation
+        const delegation = batch ? this.findActiveDelegation(ctx, batch, toOperator === batch.currentOwner ? 'process' : 'transfer') : undefined;
+        if (!delegation) {
+            checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+        }
 
-        // Get transaction timestamp
-        const txTimestamp = ctx.stub.getTxTimestamp();
-        const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
+        // A gateway retry of an already processed request is a no-op
+        if (await isProcessedRequest(ctx, clientRequestId, 'CompleteStepAndTransfer')) {
+            return;
+        }
+
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        this.assertNotDisposed(batch);
 
         // Parse report detail
+        await assertReportSize(ctx, reportStr, `The report of step ${step}`);
         let report: ReportDetail;
         try {
             report = JSON.parse(reportStr);
         } catch (error) {
             throw new Error(`Report format error: ${error}`);
         }
+        this.validateReportEvidence(report);
+
+        // A resubmission without an idempotency key must not record the same step twice
+        const lastEvent = batch.history[batch.history.length - 1];
+        if (lastEvent && this.isSameStep(lastEvent, fromOperator, toOperator, step, report)) {
+            throw new Error(`Step ${step} of batch ${batchId} already exists: it is identical to the last recorded step (report ${report.reportId || 'without ID'})`);
+        }
+
+        // Workflow and quality gates look at the whole history, including events moved to continuation keys
+        const fullBatch = await withArchivedHistory(ctx, batch);
+
+        // Enforce the batch's processing workflow, if it follows one
+        await this.enforceWorkflow(ctx, fullBatch, step);
+
+        // Get transaction timestamp
+        const txTimestamp = ctx.stub.getTxTimestamp();
+        const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
+
+        // Neither the transfer nor the dates its report claims can be backdated before the previous event in the batch history
+        this.assertStepChronology(now, 'Transfer time', report, lastEvent);
+
+        // Enforce quality gates for packaging and shipping
+        await this.enforceQualityGates(ctx, fullBatch, step, report, now);
+
+        // Quantity reserved for a pending sale only goes to its buyer; handing the batch over consumes the reservations
+        const reservations = consumeReservations(batch, toOperator, now);
+
+        // Link the step to the equipment it ran on, so recalls can be scoped to a faulty machine
+        if (report.equipmentId) {
+            await recordEquipmentUsage(ctx, report.equipmentId, batchId, step, now);
+        }
+
+        // Link the step to the site, line and shift it took place at, so investigations can narrow a problem to a shift
+        report = await assertFacilityContext(ctx, report, now);
+        await recordFacilityActivity(ctx, report, batchId, step, now);
+
+        // Index the agro-chemicals the step applied, so a contaminated lot can be traced to the batch
+        if (report.inputs) {
+            await recordAgroInputs(ctx, report.inputs, batchId, step, now);
+        }
 
         // Create new history event
         const historyEvent: HistoryEvent = {
@@ -274,74 +903,1852 @@ export class RiceTracerContract extends Contract {
             from: fromOperator,
             to: toOperator,
             step: step,
-            report: report
+            report: report,
+            signerMspId: ctx.clientIdentity.getMSPID(),
+            signerFingerprint: getCallerFingerprint(ctx)
         };
+        if (delegation) {
+            historyEvent.delegationId = delegation.delegationId;
+            historyEvent.onBehalfOfMspId = delegation.grantedByMspId;
+            historyEvent.onBehalfOfFingerprint = delegation.grantedByFingerprint;
+        }
 
-        // Add event to history
-        batch.history.push(historyEvent);
-
-        // Update batch status
-        batch.currentOwner = toOperator;
-        batch.currentState = step;
+        // Patch only the fields this transaction owns: append the event and update the batch status
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, await withBatchStatus(ctx, batch, {
+            ...await appendHistoryEvent(ctx, batch, historyEvent),
+            ...(reservations ? { reservations } : {}),
+            currentOwner: toOperator,
+            currentState: step
+        }));
 
-        await ctx.stub.putState(
-            `batch_${batchId}`,
-            Buffer.from(stringify(sortKeysRecursive(batch)))
-        );
+        // Move the batch to its new position in the processing step and owner indexes
+        await deleteIndexEntry(ctx, STEP_INDEX, [batch.currentState, batchId]);
+        await putIndexEntry(ctx, STEP_INDEX, [step, batchId]);
+        await deleteIndexEntry(ctx, BATCH_OWNER_INDEX, [batch.currentOwner, batchId]);
+        await putIndexEntry(ctx, BATCH_OWNER_INDEX, [toOperator, batchId]);
+        await markRequestProcessed(ctx, clientRequestId, 'CompleteStepAndTransfer');
+        emitEvent(ctx, 'BatchStepCompleted', updated);
     }
 
     /**
-     * Get complete history event record of the batch
-     * Permission: All organizations can query
+     * Record a run of processing steps of a batch without handover in one transaction, e.g. the records a
+     * packaging line produces every few seconds
+     * recordsJSON: [{ step, report, timestamp? }, ...], 1 to 100 records in the order they happened. timestamp is
+     * when the line recorded the step (RFC3339, default the transaction time); it cannot be in the future or before
+     * the previous record. Each record is checked like a CompleteStepAndTransfer processing step (report evidence,
+     * duplicate step, workflow, quality gates, equipment, storage limits) against the batch as the records before it
+     * leave it. The records are applied together or not at all: if any is invalid, the transaction fails listing
+     * every invalid record by its position. Returns the number of records added
+     * The energy, labor and machine time a step used can be passed in the "resources" transient field, a list aligned
+     * with the records of { energyKwh?, laborHours?, machineHours? } or null. It is kept in the caller's implicit
+     * collection, private to the processing organization (see ResourceUsageContract), and never in the public history
+     * clientRequestId: idempotency key - a retry with the same ID is not applied twice (empty to disable)
+     * Permission: Farm and middleman/tester can call, as can identities holding an active process delegation
      */
-    @Transaction(false)
-    @Returns('HistoryEvent[]')
-    public async GetBatchHistory(ctx: Context, batchId: string): Promise<HistoryEvent[]> {
-        const batch = await this.ReadRiceBatch(ctx, batchId);
-        return batch.history;
-    }
+    @Transaction()
+    @Returns('number')
+    public async AddProcessingRecords(ctx: Context, batchId: string, recordsJSON: string, clientRequestId: string): Promise<number> {
+        let batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
 
-    /**
-     * Get current status summary of the batch
-     * Permission: All organizations can query
-     */
-    @Transaction(false)
-    @Returns('string')
-    public async GetBatchCurrentStatus(ctx: Context, batchId: string): Promise<string> {
-        const batch = await this.ReadRiceBatch(ctx, batchId);
-        
-        const statusInfo = {
-            batchId: batch.batchId,
-            currentOwner: batch.currentOwner,
-            currentState: batch.currentState,
-            variety: batch.variety,
-            origin: batch.origin,
-            harvestDate: batch.harvestDate,
-            totalEvents: batch.history.length,
-            lastUpdate: batch.history.length > 0 ? batch.history[batch.history.length - 1].timestamp : ''
-        };
-        
-        return JSON.stringify(statusInfo, null, 2);
-    }
+        const delegation = batch ? this.findActiveDelegation(ctx, batch, 'process') : undefined;
+        if (!delegation) {
+            checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+        }
 
-    /**
-     * Read rice batch information
-     * Permission: No restriction
-     */
-    @Transaction(false)
-    @Returns('RiceBatch')
-    public async ReadRiceBatch(ctx: Context, batchId: string): Promise<RiceBatch> {
-        const batchJSON = await ctx.stub.getState(`batch_${batchId}`);
-        if (!batchJSON || batchJSON.length === 0) {
+        // A gateway retry of an already processed request is a no-op
+        if (await isProcessedRequest(ctx, clientRequestId, 'AddProcessingRecords')) {
+            return 0;
+        }
+
+        if (!batch) {
             throw new Error(`The rice batch ${batchId} does not exist`);
         }
+        this.assertNotDisposed(batch);
 
-        return JSON.parse(batchJSON.toString());
-    }
+        let records: any[];
+        try {
+            records = JSON.parse(recordsJSON);
+        } catch (error) {
+            throw new Error(`Processing records format error: ${error}`);
+        }
+        if (!Array.isArray(records) || records.length === 0 || records.length > MAX_PROCESSING_RECORDS) {
+            throw new Error(`Processing records must be a list of 1 to ${MAX_PROCESSING_RECORDS} records`);
+        }
+        const resources = readResourceTransient(ctx, records.length);
 
-    /**
-     * Check if rice batch exists
-     * Permission: No restriction
+        const now = getTxTimestamp(ctx);
+        const originalState = batch.currentState;
+        let fullBatch = await withArchivedHistory(ctx, batch);
+        let patch: Partial<RiceBatch> = {};
+        const errors: string[] = [];
+        for (const [position, record] of records.entries()) {
+            try {
+                if (!record || typeof record !== 'object' || typeof record.step !== 'string' || !record.step.trim()) {
+                    throw new Error('step is required');
+                }
+                if (!record.report || typeof record.report !== 'object' || Array.isArray(record.report)) {
+                    throw new Error('report must be an object');
+                }
+                const step = record.step.trim();
+                let report: ReportDetail = record.report;
+                this.validateReportEvidence(report);
+
+                const timestamp = record.timestamp ? normalizeTimestamp(record.timestamp, 'timestamp') : now;
+                if (timestamp > now) {
+                    throw new Error(`timestamp ${timestamp} is after the transaction time ${now}`);
+                }
+                const lastEvent = fullBatch.history[fullBatch.history.length - 1];
+                if (lastEvent) {
+                    this.assertStepChronology(timestamp, 'Record time', report, lastEvent);
+                    if (this.isSameStep(lastEvent, batch.currentOwner, batch.currentOwner, step, report)) {
+                        throw new Error(`it is identical to the previous ${step} record (report ${report.reportId || 'without ID'})`);
+                    }
+                }
+                await this.enforceWorkflow(ctx, fullBatch, step);
+                await this.enforceQualityGates(ctx, fullBatch, step, report, timestamp);
+                if (report.equipmentId) {
+                    await recordEquipmentUsage(ctx, report.equipmentId, batchId, step, timestamp);
+                }
+                report = await assertFacilityContext(ctx, report, timestamp);
+                await recordFacilityActivity(ctx, report, batchId, step, timestamp);
+                if (report.inputs) {
+                    await recordAgroInputs(ctx, report.inputs, batchId, step, timestamp);
+                }
+
+                const historyEvent: HistoryEvent = {
+                    timestamp,
+                    from: batch.currentOwner,
+                    to: batch.currentOwner,
+                    step,
+                    report,
+                    signerMspId: ctx.clientIdentity.getMSPID(),
+                    signerFingerprint: getCallerFingerprint(ctx)
+                };
+                if (delegation) {
+                    historyEvent.delegationId = delegation.delegationId;
+                    historyEvent.onBehalfOfMspId = delegation.grantedByMspId;
+                    historyEvent.onBehalfOfFingerprint = delegation.grantedByFingerprint;
+                }
+
+                const resourceUsage = resources[position];
+                if (resourceUsage) {
+                    await recordResourceUsage(ctx, fullBatch, fullBatch.history.length, step, timestamp, resourceUsage);
+                }
+
+                // Append to the stored document as the earlier records left it, moving full history to segments
+                const appended = await appendHistoryEvent(ctx, batch, historyEvent);
+                batch = { ...batch, ...appended, currentState: step };
+                patch = { ...patch, ...appended, currentState: step };
+                fullBatch = { ...fullBatch, history: [...fullBatch.history, historyEvent], currentState: step };
+            } catch (error) {
+                errors.push(`record ${position}: ${(error as Error).message}`);
+            }
+        }
+        if (errors.length > 0) {
+            throw new Error(`${errors.length} of ${records.length} processing records of batch ${batchId} are invalid, none were added: ${errors.join('; ')}`);
+        }
+
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, await withBatchStatus(ctx, batch, patch));
+        if (updated.currentState !== originalState) {
+            await deleteIndexEntry(ctx, STEP_INDEX, [originalState, batchId]);
+            await putIndexEntry(ctx, STEP_INDEX, [updated.currentState, batchId]);
+        }
+        await markRequestProcessed(ctx, clientRequestId, 'AddProcessingRecords');
+        emitEvent(ctx, 'BatchStepCompleted', updated);
+        return records.length;
+    }
+
+    /**
+     * Check whether the caller could hand a batch over to newOwner, recording step, without submitting anything
+     * Runs the rules CompleteStepAndTransfer enforces and lists every one that would reject the transfer, so a
+     * client can show why before submitting. The handover is checked as coming from the current owner.
+     * step and reportStr are optional: without a step only the rules that do not depend on it are checked
+     * Permission: No restriction (the permission rule is evaluated for the caller)
+     */
+    @Transaction(false)
+    @Returns('TransferCheck')
+    public async CanTransferRiceBatch(ctx: Context, batchId: string, newOwner: string, step: string, reportStr: string): Promise<TransferCheck> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+
+        const blockers: TransferBlocker[] = [];
+        const check = async (rule: string, enforce: () => void | Promise<void>): Promise<void> => {
+            try {
+                await enforce();
+            } catch (error) {
+                blockers.push({ rule, message: (error as Error).message });
+            }
+        };
+
+        await check('permission', () => {
+            if (!this.findActiveDelegation(ctx, batch, newOwner === batch.currentOwner ? 'process' : 'transfer')) {
+                checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+            }
+        });
+        await check('disposed', () => this.assertNotDisposed(batch));
+        await check('reservations', () => {
+            consumeReservations(batch, newOwner, getTxTimestamp(ctx));
+        });
+
+        let report: ReportDetail = { reportId: '', reportType: '', reportHash: '', summary: '', isVerified: false };
+        await check('report', async () => {
+            if (reportStr) {
+                await assertReportSize(ctx, reportStr, `The report of step ${step}`);
+                try {
+                    report = JSON.parse(reportStr);
+                } catch (error) {
+                    throw new Error(`Report format error: ${error}`);
+                }
+                this.validateReportEvidence(report);
+            }
+        });
+
+        const now = getTxTimestamp(ctx);
+        const lastEvent = batch.history[batch.history.length - 1];
+        await check('chronology', () => this.assertStepChronology(now, 'Transfer time', report, lastEvent));
+
+        if (step) {
+            if (lastEvent) {
+                await check('duplicateStep', () => {
+                    if (this.isSameStep(lastEvent, batch.currentOwner, newOwner, step, report)) {
+                        throw new Error(`Step ${step} of batch ${batchId} already exists: it is identical to the last recorded step (report ${report.reportId || 'without ID'})`);
+                    }
+                });
+            }
+            const fullBatch = await withArchivedHistory(ctx, batch);
+            await check('workflow', () => this.enforceWorkflow(ctx, fullBatch, step));
+            await check('qualityGates', () => this.enforceQualityGates(ctx, fullBatch, step, report, now));
+            if (report.equipmentId) {
+                await check('equipment', () => assertEquipmentUsable(ctx, report.equipmentId as string, now));
+            }
+            await check('facility', () => assertFacilityContext(ctx, report, now));
+            await check('storageLimits', () => assertHistoryEventFits(ctx, batch, {
+                timestamp: now,
+                from: batch.currentOwner,
+                to: newOwner,
+                step,
+                report,
+                signerMspId: ctx.clientIdentity.getMSPID(),
+                signerFingerprint: getCallerFingerprint(ctx)
+            }));
+        }
+
+        return { batchId, newOwner, step: step || '', allowed: blockers.length === 0, blockers };
+    }
+
+    /**
+     * Correct the step and/or report of a mistyped processing record without rewriting history
+     * index: position of the record in the full batch history (0 = registration)
+     * correctedRecordJSON: { step?, report? }; the time, parties and signer of a record cannot be corrected
+     * The original record is kept and marked supersededBy the correction, which is appended to the batch's
+     * corrections. Correcting a record again supersedes the previous correction. A correction of the latest
+     * record's step also moves the batch to the corrected step
+     * Permission: The organization that signed the record
+     */
+    @Transaction()
+    @Returns('ProcessingRecordCorrection')
+    public async CorrectProcessingRecord(
+        ctx: Context,
+        batchId: string,
+        index: string,
+        correctedRecordJSON: string,
+        reason: string
+    ): Promise<ProcessingRecordCorrection> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        this.assertNotDisposed(batch);
+
+        const fullBatch = await withArchivedHistory(ctx, batch);
+        const position = Number(index);
+        if (!/^\d+$/.test(index) || position >= fullBatch.history.length) {
+            throw new Error(`Invalid record index ${index}: batch ${batchId} has ${fullBatch.history.length} history records`);
+        }
+        const original = fullBatch.history[position];
+        if (!original.signerMspId || original.signerMspId !== ctx.clientIdentity.getMSPID()) {
+            throw new Error(`Permission denied: Only the organization that signed record ${position} of batch ${batchId} (${original.signerMspId || 'unknown'}) can correct it`);
+        }
+        if (!reason || !reason.trim()) {
+            throw new Error('A reason is required to correct a processing record');
+        }
+
+        let input: any;
+        try {
+            input = JSON.parse(correctedRecordJSON);
+        } catch (error) {
+            throw new Error(`Corrected record format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('Corrected record must be an object');
+        }
+        const uncorrectable = Object.keys(input).filter(field => field !== 'step' && field !== 'report');
+        if (uncorrectable.length > 0) {
+            throw new Error(`Only the step and report of a processing record can be corrected, not: ${uncorrectable.join(', ')}`);
+        }
+
+        // A record corrected before is corrected again from its latest correction
+        const corrections = batch.corrections || [];
+        const previous = corrections.find(correction => correction.correctionId === original.supersededBy);
+        const current = previous || original;
+        const step = input.step !== undefined ? String(input.step).trim() : current.step;
+        let report: ReportDetail = input.report !== undefined ? input.report : current.report;
+        if (!step) {
+            throw new Error('Corrected step cannot be empty');
+        }
+        if (!report || typeof report !== 'object') {
+            throw new Error('Corrected report must be an object');
+        }
+        this.validateReportEvidence(report);
+        if (step === current.step && JSON.stringify(report) === JSON.stringify(current.report)) {
+            throw new Error(`The correction does not change record ${position} of batch ${batchId}`);
+        }
+        // A corrected site, line or shift must exist at the facility; records without facility context are left as they were
+        if (input.report !== undefined) {
+            report = await assertFacilityContext(ctx, report, original.timestamp);
+        }
+
+        const correction: ProcessingRecordCorrection = {
+            correctionId: ctx.stub.getTxID(),
+            index: position,
+            step,
+            report,
+            reason: reason.trim(),
+            correctedByMspId: ctx.clientIdentity.getMSPID(),
+            correctedByFingerprint: getCallerFingerprint(ctx),
+            correctedAt: getTxTimestamp(ctx)
+        };
+
+        const isLatest = position === fullBatch.history.length - 1;
+        const patch: Partial<RiceBatch> = {
+            ...await updateHistoryEvent(ctx, batch, position, { supersededBy: correction.correctionId }),
+            corrections: [
+                ...corrections.map(entry => entry === previous ? { ...entry, supersededBy: correction.correctionId } : entry),
+                correction
+            ]
+        };
+        if (isLatest && step !== batch.currentState) {
+            patch.currentState = step;
+            await deleteIndexEntry(ctx, STEP_INDEX, [batch.currentState, batchId]);
+            await putIndexEntry(ctx, STEP_INDEX, [step, batchId]);
+        }
+
+        // Applications added by the correction are traced too; those it removed stay indexed, erring on the side of a recall
+        if (report.inputs) {
+            await recordAgroInputs(ctx, report.inputs, batchId, step, original.timestamp);
+        }
+        if (input.report !== undefined) {
+            await recordFacilityActivity(ctx, report, batchId, step, original.timestamp);
+        }
+
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, await withBatchStatus(ctx, batch, patch));
+        emitEvent(ctx, 'ProcessingRecordCorrected', updated);
+        return correction;
+    }
+
+    /**
+     * Dispose of a batch (spoiled, recalled, ...), moving it to the terminal Disposed state
+     * The disposition is recorded on the batch and as a final history event
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async DisposeBatch(
+        ctx: Context,
+        batchId: string,
+        reason: string,
+        method: string,
+        quantity: string,
+        handler: string
+    ): Promise<void> {
+        // Check permission: Farm and middleman/tester hold batches and can dispose of them
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        this.assertNotDisposed(batch);
+
+        const disposal = createDisposal(ctx, reason, method, quantity, handler);
+
+        const historyEvent: HistoryEvent = {
+            timestamp: disposal.timestamp,
+            from: batch.currentOwner,
+            to: handler,
+            step: DISPOSED_STATE,
+            report: {
+                reportId: '',
+                reportType: 'Disposal',
+                reportHash: '',
+                summary: `${reason}; method: ${method}; quantity: ${quantity}`,
+                isVerified: false
+            },
+            signerMspId: ctx.clientIdentity.getMSPID(),
+            signerFingerprint: getCallerFingerprint(ctx)
+        };
+
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, await withBatchStatus(ctx, batch, {
+            ...await appendHistoryEvent(ctx, batch, historyEvent),
+            currentOwner: handler,
+            currentState: DISPOSED_STATE,
+            disposal
+        }));
+
+        await deleteIndexEntry(ctx, STEP_INDEX, [batch.currentState, batchId]);
+        await putIndexEntry(ctx, STEP_INDEX, [DISPOSED_STATE, batchId]);
+        await deleteIndexEntry(ctx, BATCH_OWNER_INDEX, [batch.currentOwner, batchId]);
+        await putIndexEntry(ctx, BATCH_OWNER_INDEX, [handler, batchId]);
+        emitEvent(ctx, 'BatchDisposed', updated);
+    }
+
+    /**
+     * Place a batch under quarantine, blocking shipment until released
+     * Permission: Only middleman/tester can call
+     */
+    @Transaction()
+    public async QuarantineBatch(ctx: Context, batchId: string, reason: string): Promise<void> {
+        // Check permission: Only middleman/tester can quarantine batches
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!reason) {
+            throw new Error('Quarantine reason is required');
+        }
+
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        this.assertNotDisposed(batch);
+        if (batch.quarantined) {
+            throw new Error(`The rice batch ${batchId} is already quarantined`);
+        }
+
+        // Get transaction timestamp
+        const txTimestamp = ctx.stub.getTxTimestamp();
+        const now = new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
+
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, await withBatchStatus(ctx, batch, {
+            quarantined: true,
+            quarantineReason: reason,
+            quarantinedAt: now,
+            quarantinedBy: ctx.clientIdentity.getMSPID()
+        }));
+        emitEvent(ctx, 'BatchQuarantined', updated);
+    }
+
+    /**
+     * Release a batch from quarantine
+     * Permission: Only middleman/tester can call
+     */
+    @Transaction()
+    public async ReleaseQuarantine(ctx: Context, batchId: string): Promise<void> {
+        // Check permission: Only middleman/tester can release quarantined batches
+        checkOrgType(ctx, [OrganizationType.MIDDLEMAN_TESTER]);
+
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        if (!batch.quarantined) {
+            throw new Error(`The rice batch ${batchId} is not quarantined`);
+        }
+
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, await withBatchStatus(ctx, batch, {
+            quarantined: false,
+            quarantineReason: '',
+            quarantinedAt: '',
+            quarantinedBy: ''
+        }));
+        emitEvent(ctx, 'BatchQuarantineReleased', updated);
+    }
+
+    /**
+     * Replace the labels of a batch, e.g. { "export-market": "JP", "coop-id": "HLJ-017" }
+     * labelsJSON is an object of at most 20 string labels; an empty object removes all labels
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async SetBatchLabels(ctx: Context, batchId: string, labelsJSON: string): Promise<void> {
+        // Check permission: Supply chain organizations attach their own metadata
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        const labels = parseLabels(labelsJSON, (await readInputLimits(ctx)).maxLabels);
+
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, { labels });
+        await updateLabelIndex(ctx, BATCH_LABEL_INDEX, batchId, batch.labels, labels);
+        emitEvent(ctx, 'BatchLabelsChanged', updated);
+    }
+
+    /**
+     * Grant a power of attorney over a batch, e.g. to a cooperative or broker selling on a farmer's behalf
+     * delegateIdentity is "<MSP ID>:<certificate SHA-256 fingerprint>" (colons in the fingerprint are ignored),
+     * permissions a comma-separated subset of transfer and process, expiry a date or RFC3339 time in the future
+     * Returns the delegation; its ID (the granting transaction ID) is recorded on every delegated history event
+     * Permission: Only the organization that registered the batch can call
+     */
+    @Transaction()
+    @Returns('Delegation')
+    public async GrantDelegate(
+        ctx: Context,
+        batchId: string,
+        delegateIdentity: string,
+        permissions: string,
+        expiry: string
+    ): Promise<Delegation> {
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        this.checkBatchRegistrant(ctx, batch, 'grant delegations');
+        this.assertNotDisposed(batch);
+
+        const separator = (delegateIdentity || '').indexOf(':');
+        const delegateMspId = separator > 0 ? delegateIdentity.slice(0, separator).trim() : '';
+        const delegateFingerprint = separator > 0 ? delegateIdentity.slice(separator + 1).replace(/:/g, '').trim().toLowerCase() : '';
+        if (!delegateMspId || !/^[0-9a-f]{64}$/.test(delegateFingerprint)) {
+            throw new Error('Delegate identity must be "<MSP ID>:<certificate SHA-256 fingerprint>"');
+        }
+        const grantedByFingerprint = getCallerFingerprint(ctx);
+        if (delegateMspId === ctx.clientIdentity.getMSPID() && delegateFingerprint === grantedByFingerprint) {
+            throw new Error('Cannot delegate to yourself');
+        }
+
+        const granted = [...new Set((permissions || '').split(',').map(permission => permission.trim()).filter(Boolean))];
+        const unknown = granted.filter(permission => !DELEGATION_PERMISSIONS.includes(permission));
+        if (granted.length === 0 || unknown.length > 0) {
+            throw new Error(`Invalid delegation permissions ${permissions}: use a comma-separated subset of ${DELEGATION_PERMISSIONS.join(', ')}`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const expiresAt = normalizeEndTimestamp(expiry, 'expiry');
+        if (expiresAt <= now) {
+            throw new Error(`Delegation expiry ${expiresAt} must be in the future`);
+        }
+
+        const delegation: Delegation = {
+            delegationId: ctx.stub.getTxID(),
+            delegateMspId,
+            delegateFingerprint,
+            permissions: granted,
+            grantedByMspId: ctx.clientIdentity.getMSPID(),
+            grantedByFingerprint,
+            grantedAt: now,
+            expiresAt
+        };
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            delegations: [...(batch.delegations || []), delegation]
+        });
+        emitEvent(ctx, 'DelegateGranted', updated);
+        return delegation;
+    }
+
+    /**
+     * Revoke a delegation before it expires; steps already recorded under it stay attributed to it
+     * Permission: Only the organization that registered the batch can call
+     */
+    @Transaction()
+    public async RevokeDelegate(ctx: Context, batchId: string, delegationId: string): Promise<void> {
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        this.checkBatchRegistrant(ctx, batch, 'revoke delegations');
+
+        const delegations = batch.delegations || [];
+        const delegation = delegations.find(candidate => candidate.delegationId === delegationId);
+        if (!delegation) {
+            throw new Error(`The delegation ${delegationId} does not exist on batch ${batchId}`);
+        }
+        if (delegation.revokedAt) {
+            throw new Error(`The delegation ${delegationId} was already revoked at ${delegation.revokedAt}`);
+        }
+
+        const revokedAt = getTxTimestamp(ctx);
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            delegations: delegations.map(candidate => candidate === delegation ? { ...candidate, revokedAt } : candidate)
+        });
+        emitEvent(ctx, 'DelegateRevoked', updated);
+    }
+
+    /**
+     * Reference a batch committed on another channel as a source of a batch, e.g. when rice moves between
+     * regional networks. The foreign batch is read from its channel through the chaincode on the endorsing peer,
+     * which must have joined that channel, and must still have the state hash given (GetBatchStateHash on the
+     * foreign channel), so the reference pins the exact state the rice was received in
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async LinkForeignBatch(
+        ctx: Context,
+        batchId: string,
+        channel: string,
+        foreignBatchId: string,
+        stateHash: string,
+        chaincodeName: string
+    ): Promise<void> {
+        // Check permission: Only organizations that receive rice link its source
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!channel || !foreignBatchId || !chaincodeName) {
+            throw new Error('Foreign channel, batch ID and chaincode name are required');
+        }
+        if (channel === ctx.stub.getChannelID()) {
+            throw new Error(`Batch ${foreignBatchId} is on this channel; foreign references must point to another channel`);
+        }
+        const hash = (stateHash || '').toLowerCase();
+        if (!/^[0-9a-f]{64}$/.test(hash)) {
+            throw new Error('State hash must be a SHA-256 digest (64 hex characters)');
+        }
+
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        this.assertNotDisposed(batch);
+        const references = batch.foreignReferences || [];
+        if (references.some(reference => reference.channel === channel && reference.batchId === foreignBatchId)) {
+            throw new Error(`The rice batch ${batchId} already references batch ${foreignBatchId} on channel ${channel}`);
+        }
+
+        const foreignBatch = await this.readForeignBatch(ctx, chaincodeName, channel, foreignBatchId);
+        if (documentHash(foreignBatch) !== hash) {
+            throw new Error(`State hash does not match batch ${foreignBatchId} on channel ${channel}; it has changed since the hash was taken`);
+        }
+
+        const reference: ForeignBatchReference = {
+            channel,
+            chaincodeName,
+            batchId: foreignBatchId,
+            stateHash: hash,
+            summary: this.foreignProvenanceSummary(foreignBatch),
+            linkedAt: getTxTimestamp(ctx),
+            linkedBy: ctx.clientIdentity.getMSPID()
+        };
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            foreignReferences: [...references, reference]
+        });
+        emitEvent(ctx, 'ForeignBatchLinked', updated);
+    }
+
+    /**
+     * Re-read a referenced foreign batch from its channel and compare it with the state recorded when linked
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ForeignReferenceVerification')
+    public async VerifyForeignBatchReference(
+        ctx: Context,
+        batchId: string,
+        channel: string,
+        foreignBatchId: string
+    ): Promise<ForeignReferenceVerification> {
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        const reference = (batch.foreignReferences || [])
+            .find(candidate => candidate.channel === channel && candidate.batchId === foreignBatchId);
+        if (!reference) {
+            throw new Error(`The rice batch ${batchId} has no reference to batch ${foreignBatchId} on channel ${channel}`);
+        }
+
+        const foreignBatch = await this.readForeignBatch(ctx, reference.chaincodeName, channel, foreignBatchId);
+        const currentStateHash = documentHash(foreignBatch);
+        return {
+            channel,
+            batchId: foreignBatchId,
+            recordedStateHash: reference.stateHash,
+            currentStateHash,
+            matches: currentStateHash === reference.stateHash,
+            summary: this.foreignProvenanceSummary(foreignBatch)
+        };
+    }
+
+    /**
+     * Canonical SHA-256 of a batch document, cited by references from other channels
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('BatchStateHash')
+    public async GetBatchStateHash(ctx: Context, batchId: string): Promise<BatchStateHash> {
+        return {
+            channel: ctx.stub.getChannelID(),
+            batchId,
+            stateHash: documentHash(await this.ReadRiceBatch(ctx, batchId))
+        };
+    }
+
+    /**
+     * Read a batch from another channel (a query only: cross-channel reads are not validated at commit)
+     */
+    private async readForeignBatch(ctx: Context, chaincodeName: string, channel: string, batchId: string): Promise<RiceBatch> {
+        const response = await ctx.stub.invokeChaincode(chaincodeName, ['ReadRiceBatch', batchId], channel)
+            .catch((error: Error) => {
+                throw new Error(`Cannot read batch ${batchId} from channel ${channel}: ${error.message}`);
+            });
+        if (response.status >= 400) {
+            throw new Error(`Cannot read batch ${batchId} from channel ${channel}: ${response.message}`);
+        }
+        return JSON.parse(Buffer.from(response.payload).toString());
+    }
+
+    private foreignProvenanceSummary(batch: RiceBatch): ForeignProvenanceSummary {
+        return {
+            origin: batch.origin,
+            variety: batch.variety,
+            harvestDate: batch.harvestDate,
+            currentOwner: batch.currentOwner,
+            currentState: batch.currentState,
+            historyLength: (batch.history || []).length
+        };
+    }
+
+    /**
+     * Attach an insurance policy (crop, storage or transport cover) to a batch, so its insurance status travels
+     * with the batch. validity is an ISO 8601 interval "<start>/<end>" of dates or times; documentHash is the
+     * SHA-256 (hex) of the policy document, which stays off-chain. The caller's organization is the policyholder
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async AttachInsurancePolicy(
+        ctx: Context,
+        batchId: string,
+        insurer: string,
+        policyNumber: string,
+        coverage: string,
+        validity: string,
+        documentHash: string
+    ): Promise<void> {
+        // Check permission: Only organizations that hold or carry batches insure them
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!insurer || !policyNumber) {
+            throw new Error('Insurer and policy number are required');
+        }
+        if (!INSURANCE_COVERAGES.includes(coverage)) {
+            throw new Error(`Unknown insurance coverage ${coverage}, expected one of ${INSURANCE_COVERAGES.join(', ')}`);
+        }
+        const { from: validFrom, to: validTo } = parseInterval(validity, 'Policy validity');
+        const hash = (documentHash || '').toLowerCase();
+        if (!/^[0-9a-f]{64}$/.test(hash)) {
+            throw new Error('Policy document hash must be a SHA-256 digest (64 hex characters)');
+        }
+
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        this.assertNotDisposed(batch);
+        const policies = batch.insurancePolicies || [];
+        if (policies.some(policy => policy.insurer === insurer && policy.policyNumber === policyNumber)) {
+            throw new Error(`Policy ${policyNumber} of ${insurer} is already attached to the rice batch ${batchId}`);
+        }
+
+        const policy: InsurancePolicy = {
+            insurer,
+            policyNumber,
+            coverage,
+            validFrom,
+            validTo,
+            documentHash: hash,
+            attachedAt: getTxTimestamp(ctx),
+            attachedBy: ctx.clientIdentity.getMSPID()
+        };
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            insurancePolicies: [...policies, policy]
+        });
+        emitEvent(ctx, 'InsurancePolicyAttached', updated);
+    }
+
+    /**
+     * File an insurance claim against a policy attached to a batch
+     * evidenceJSON is a list of { type, reference, description? } citing records of the batch on the ledger:
+     * a testResult, certificate, sample or shipment ID, a historyEvent index, the data hash of a weather observation
+     * covering the incident date, or the SHA-256 of an off-chain document (e.g. a sensor logger export). Claims can be filed for disposed batches, since the loss often
+     * led to the disposal. Only the policyholder organization can claim
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async ClaimInsurance(
+        ctx: Context,
+        batchId: string,
+        insurer: string,
+        policyNumber: string,
+        claimId: string,
+        incidentDate: string,
+        description: string,
+        evidenceJSON: string
+    ): Promise<void> {
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!claimId || !description) {
+            throw new Error('Claim ID and description are required');
+        }
+        const incidentTimestamp = normalizeTimestamp(incidentDate, 'incidentDate');
+
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        const policy = (batch.insurancePolicies || [])
+            .find(candidate => candidate.insurer === insurer && candidate.policyNumber === policyNumber);
+        if (!policy) {
+            throw new Error(`Policy ${policyNumber} of ${insurer} is not attached to the rice batch ${batchId}`);
+        }
+        const mspId = ctx.clientIdentity.getMSPID();
+        if (policy.attachedBy !== mspId) {
+            throw new Error(`Permission denied: Policy ${policyNumber} is held by ${policy.attachedBy}`);
+        }
+        if (incidentTimestamp < policy.validFrom || incidentTimestamp > policy.validTo) {
+            throw new Error(`Incident date ${incidentTimestamp} is outside the policy validity ${policy.validFrom}/${policy.validTo}`);
+        }
+        const claims = batch.insuranceClaims || [];
+        if (claims.some(claim => claim.claimId === claimId)) {
+            throw new Error(`Claim ${claimId} has already been filed for the rice batch ${batchId}`);
+        }
+
+        let evidence: InsuranceEvidence[];
+        try {
+            evidence = JSON.parse(evidenceJSON);
+        } catch (error) {
+            throw new Error(`Evidence format error: ${error}`);
+        }
+        if (!Array.isArray(evidence) || evidence.length === 0) {
+            throw new Error('A claim must cite at least one piece of evidence');
+        }
+        for (const item of evidence) {
+            await this.assertInsuranceEvidence(ctx, batch, item, incidentTimestamp);
+        }
+
+        const claim: InsuranceClaim = {
+            claimId,
+            insurer,
+            policyNumber,
+            incidentDate: incidentTimestamp,
+            description,
+            evidence: evidence.map(item => ({
+                type: item.type,
+                reference: ['document', 'weather'].includes(item.type) ? String(item.reference).toLowerCase() : String(item.reference),
+                ...(item.description ? { description: item.description } : {})
+            })),
+            filedAt: getTxTimestamp(ctx),
+            filedBy: mspId
+        };
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            insuranceClaims: [...claims, claim]
+        });
+        emitEvent(ctx, 'InsuranceClaimFiled', updated);
+    }
+
+    /**
+     * Ensure a piece of claim evidence exists on the ledger and belongs to the claimed batch
+     */
+    private async assertInsuranceEvidence(ctx: Context, batch: RiceBatch, item: InsuranceEvidence, incidentDate: string): Promise<void> {
+        const reference = item && item.reference !== undefined ? String(item.reference) : '';
+        if (!reference) {
+            throw new Error('Every piece of evidence needs a type and a reference');
+        }
+
+        if (item.type === 'document') {
+            if (!/^[0-9a-f]{64}$/.test(reference.toLowerCase())) {
+                throw new Error('Document evidence must be referenced by its SHA-256 digest (64 hex characters)');
+            }
+            return;
+        }
+        if (item.type === 'weather') {
+            const observation = await readDocument<WeatherObservation>(ctx, `weather_${reference.toLowerCase()}`);
+            if (!observation) {
+                throw new Error(`Weather observation ${reference} does not exist`);
+            }
+            if (incidentDate < observation.periodStart || incidentDate > observation.periodEnd) {
+                throw new Error(`Weather observation ${reference} does not cover the incident date ${incidentDate}`);
+            }
+            return;
+        }
+        if (item.type === 'historyEvent') {
+            if (!/^\d+$/.test(reference) || Number(reference) >= batch.history.length) {
+                throw new Error(`The rice batch ${batch.batchId} has no history event ${reference}`);
+            }
+            return;
+        }
+
+        const prefix = INSURANCE_EVIDENCE_PREFIXES[item.type];
+        if (!prefix) {
+            throw new Error(`Unknown evidence type ${item.type}, expected one of ${[...Object.keys(INSURANCE_EVIDENCE_PREFIXES), 'historyEvent', 'weather', 'document'].join(', ')}`);
+        }
+        const record = await readDocument<{ batchId?: string; batchIds?: string[] }>(ctx, `${prefix}${reference}`);
+        if (!record) {
+            throw new Error(`Evidence ${item.type} ${reference} does not exist`);
+        }
+        const batchIds = record.batchIds || [record.batchId];
+        if (!batchIds.includes(batch.batchId)) {
+            throw new Error(`Evidence ${item.type} ${reference} does not concern the rice batch ${batch.batchId}`);
+        }
+    }
+
+    /**
+     * Privately attach commercial notes/terms to a batch owned by the caller's organization
+     * The terms are passed in the "terms" transient field and stored in the organization's implicit
+     * collection; the public ledger only records their SHA-256 hash (terms_<batchId>_<mspId>).
+     * Must be endorsed by a peer of the caller's organization
+     * Permission: Farm and middleman/tester can call, for batches their organization owns
+     */
+    @Transaction()
+    public async SetCommercialTerms(ctx: Context, batchId: string): Promise<void> {
+        // Check permission: Only organizations that hold batches keep commercial terms for them
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+        assertPeerOrgMatchesClient(ctx);
+
+        const termsBytes = ctx.stub.getTransient().get(TERMS_TRANSIENT_KEY);
+        if (!termsBytes || termsBytes.length === 0) {
+            throw new Error(`Commercial terms must be passed in the "${TERMS_TRANSIENT_KEY}" transient field`);
+        }
+
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        const mspId = ctx.clientIdentity.getMSPID();
+        const ownerMspId = this.getBatchOwnerMspId(batch);
+        if (ownerMspId !== mspId) {
+            throw new Error(`Batch ${batchId} is owned by ${ownerMspId || 'an unknown organization'}; ${mspId} cannot attach commercial terms to it`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const terms = Buffer.from(termsBytes).toString('utf8');
+        const record: CommercialTerms = {
+            docType: 'commercialTerms',
+            batchId,
+            mspId,
+            terms,
+            updatedAt: now,
+            txId: ctx.stub.getTxID()
+        };
+        await ctx.stub.putPrivateData(implicitCollectionName(mspId), `terms_${batchId}`, Buffer.from(stringify(sortKeysRecursive(record))));
+
+        // Later updates of the commitment only need the holding organization's endorsement
+        const commitmentKey = `terms_${batchId}_${mspId}`;
+        const commitment: CommercialTermsCommitment = {
+            docType: 'commercialTermsCommitment',
+            batchId,
+            mspId,
+            termsHash: sha256Hex(terms),
+            updatedAt: now
+        };
+        inheritTenant(commitment, batch);
+        await writeDocument(ctx, commitmentKey, commitment);
+        await setKeyEndorsers(ctx, commitmentKey, [mspId]);
+        emitEvent(ctx, 'CommercialTermsUpdated', commitment);
+    }
+
+    /**
+     * Read the commercial terms the caller's organization attached to a batch
+     * Served from the organization's implicit collection, so it must be evaluated on one of its own peers
+     * Permission: Farm and middleman/tester can call, for their own organization's terms only
+     */
+    @Transaction(false)
+    @Returns('CommercialTerms')
+    public async ReadCommercialTerms(ctx: Context, batchId: string): Promise<CommercialTerms> {
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+        assertPeerOrgMatchesClient(ctx);
+
+        const mspId = ctx.clientIdentity.getMSPID();
+        const data = await ctx.stub.getPrivateData(implicitCollectionName(mspId), `terms_${batchId}`);
+        if (!data || data.length === 0) {
+            throw new Error(`${mspId} has no commercial terms for batch ${batchId}`);
+        }
+        return JSON.parse(Buffer.from(data).toString('utf8'));
+    }
+
+    /**
+     * Check presented commercial terms against an organization's public hash commitment for a batch
+     * Lets a counterparty that received the terms off-chain prove they are the ones recorded
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('boolean')
+    public async VerifyCommercialTerms(ctx: Context, batchId: string, mspId: string, terms: string): Promise<boolean> {
+        const commitment = await readDocument<CommercialTermsCommitment>(ctx, `terms_${batchId}_${mspId}`);
+        if (!commitment) {
+            throw new Error(`${mspId} has not recorded commercial terms for batch ${batchId}`);
+        }
+        return commitment.termsHash === sha256Hex(terms);
+    }
+
+    /**
+     * Commit to a sensitive value of a batch, product or test result without disclosing it
+     * saltedHash is the SHA-256 (hex) of salt + value, computed off-chain; a commitment cannot be replaced
+     * Commitments are kept per organization: each organization can commit one value per field of an entity
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async CommitValue(ctx: Context, entityId: string, field: string, saltedHash: string): Promise<void> {
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!field) {
+            throw new Error('Committed field name is required');
+        }
+        const hash = (saltedHash || '').toLowerCase();
+        if (!/^[0-9a-f]{64}$/.test(hash)) {
+            throw new Error('Salted hash must be a SHA-256 digest (64 hex characters)');
+        }
+        const entity = await this.readCommittableEntity(ctx, entityId);
+        if (!entity) {
+            throw new Error(`No batch, product or test result ${entityId} exists`);
+        }
+
+        const mspId = ctx.clientIdentity.getMSPID();
+        const key = ctx.stub.createCompositeKey(VALUE_COMMITMENT_KEY, [entityId, field, mspId]);
+        if (await readDocument<ValueCommitment>(ctx, key)) {
+            throw new Error(`${mspId} has already committed a value for ${field} of ${entityId}`);
+        }
+
+        const commitment: ValueCommitment = {
+            docType: 'valueCommitment',
+            entityId,
+            field,
+            saltedHash: hash,
+            committedBy: mspId,
+            committedAt: getTxTimestamp(ctx),
+            revealed: false
+        };
+        inheritTenant(commitment, entity);
+        await writeDocument(ctx, key, commitment);
+        emitEvent(ctx, 'ValueCommitted', commitment);
+    }
+
+    /**
+     * Reveal a committed value, proving it is the one committed earlier
+     * The transaction fails unless SHA-256(salt + value) matches the commitment; the value and salt are then
+     * stored so anyone can recheck it. An organization reveals its own commitment
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async RevealValue(ctx: Context, entityId: string, field: string, value: string, salt: string): Promise<void> {
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        const mspId = ctx.clientIdentity.getMSPID();
+        const commitment = await this.GetValueCommitment(ctx, entityId, field, mspId);
+        if (commitment.revealed) {
+            throw new Error(`The value for ${field} of ${entityId} has already been revealed`);
+        }
+        if (!salt || salt.length < MIN_SALT_LENGTH) {
+            throw new Error(`Salt must be at least ${MIN_SALT_LENGTH} characters`);
+        }
+        if (sha256Hex(`${salt}${value}`) !== commitment.saltedHash) {
+            throw new Error(`Value and salt do not match the commitment for ${field} of ${entityId}`);
+        }
+
+        const updated = await patchDocument<ValueCommitment>(ctx, ctx.stub.createCompositeKey(VALUE_COMMITMENT_KEY, [entityId, field, mspId]), {
+            revealed: true,
+            value,
+            salt,
+            revealedAt: getTxTimestamp(ctx),
+            revealedBy: mspId
+        });
+        emitEvent(ctx, 'ValueRevealed', updated);
+    }
+
+    /**
+     * Get an organization's commitment for a field of a batch, product or test result
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ValueCommitment')
+    public async GetValueCommitment(ctx: Context, entityId: string, field: string, mspId: string): Promise<ValueCommitment> {
+        const commitment = await readDocument<ValueCommitment>(ctx, ctx.stub.createCompositeKey(VALUE_COMMITMENT_KEY, [entityId, field, mspId]));
+        if (!commitment) {
+            throw new Error(`${mspId} has not committed a value for ${field} of ${entityId}`);
+        }
+        return commitment;
+    }
+
+    /**
+     * Verify that a presented certificate is the one that signed a stored record
+     * entityId is a batch ID (recordIndex selects the history event), a product ID (recordIndex selects the sale or return)
+     * or a test ID (recordIndex is ignored)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('boolean')
+    public async VerifyRecordSigner(ctx: Context, entityId: string, recordIndex: number, certPEM: string): Promise<boolean> {
+        const presentedFingerprint = certificateFingerprint(certPEM);
+
+        const stored = await readDocument<RiceBatch>(ctx, `batch_${entityId}`);
+        if (stored) {
+            const batch = await withArchivedHistory(ctx, stored);
+            const index = Number(recordIndex);
+            if (!Number.isInteger(index) || index < 0 || index >= batch.history.length) {
+                throw new Error(`Record index ${recordIndex} is out of range for batch ${entityId} (${batch.history.length} records)`);
+            }
+            const event = batch.history[index];
+            if (!event.signerFingerprint) {
+                throw new Error(`Record ${index} of batch ${entityId} has no signer fingerprint`);
+            }
+            return event.signerFingerprint === presentedFingerprint;
+        }
+
+        const product = await readDocument<Product>(ctx, `product_${entityId}`);
+        if (product) {
+            const transfers = product.transfers || [];
+            const index = Number(recordIndex);
+            if (!Number.isInteger(index) || index < 0 || index >= transfers.length) {
+                throw new Error(`Record index ${recordIndex} is out of range for product ${entityId} (${transfers.length} transfers)`);
+            }
+            if (!transfers[index].signerFingerprint) {
+                throw new Error(`Transfer ${index} of product ${entityId} has no signer fingerprint`);
+            }
+            return transfers[index].signerFingerprint === presentedFingerprint;
+        }
+
+        const testResult = await readDocument<TestResult>(ctx, `test_${entityId}`);
+        if (testResult) {
+            if (!testResult.signerFingerprint) {
+                throw new Error(`Test result ${entityId} has no signer fingerprint`);
+            }
+            return testResult.signerFingerprint === presentedFingerprint;
+        }
+
+        throw new Error(`No batch, product or test result found with ID ${entityId}`);
+    }
+
+    /**
+     * Get complete history event record of the batch
+     * Permission: All organizations can query
+     */
+    @Transaction(false)
+    @Returns('HistoryEvent[]')
+    public async GetBatchHistory(ctx: Context, batchId: string): Promise<HistoryEvent[]> {
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        return batch.history;
+    }
+
+    /**
+     * Compare two committed versions of a batch and list the changed fields
+     * Each version is identified by the transaction that wrote it. txA is optional: when empty, txB is compared with
+     * the version before it, showing exactly what txB modified (everything is added for the creating transaction)
+     * Permission: All organizations can query
+     */
+    @Transaction(false)
+    @Returns('BatchHistoryDiff')
+    public async GetBatchHistoryDiff(ctx: Context, batchId: string, txA: string, txB: string): Promise<BatchHistoryDiff> {
+        if (!txB) {
+            throw new Error('The transaction ID of the later version is required');
+        }
+
+        const versions: { txId: string; timestamp: string; doc: object }[] = [];
+        const iterator = await ctx.stub.getHistoryForKey(`batch_${batchId}`);
+        let result = await iterator.next();
+        while (!result.done) {
+            const modification = result.value;
+            const doc = modification.isDelete ? {} : JSON.parse(Buffer.from(modification.value).toString());
+            // History is read from raw keys, so versions of another tenant's batch are left out like its current document
+            if (isVisibleToCaller(ctx, doc)) {
+                versions.push({
+                    txId: modification.txId,
+                    timestamp: new Date(modification.timestamp.seconds.toNumber() * 1000 + Math.floor(modification.timestamp.nanos / 1e6)).toISOString(),
+                    doc
+                });
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+        if (versions.length === 0) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        // Peers return the newest version first; keep writes of one block in order when sorting by time
+        versions.reverse().sort((a, b) => a.timestamp.localeCompare(b.timestamp));
+
+        const findVersion = (txId: string) => {
+            const index = versions.findIndex(version => version.txId === txId);
+            if (index < 0) {
+                throw new Error(`Transaction ${txId} did not write the rice batch ${batchId}`);
+            }
+            return index;
+        };
+        const toIndex = findVersion(txB);
+        const fromIndex = txA ? findVersion(txA) : toIndex - 1;
+        const from = fromIndex >= 0 ? versions[fromIndex] : { txId: '', timestamp: '', doc: {} };
+        const to = versions[toIndex];
+
+        return {
+            batchId,
+            fromTxId: from.txId,
+            fromTimestamp: from.timestamp,
+            toTxId: to.txId,
+            toTimestamp: to.timestamp,
+            changes: diffDocuments(from.doc, to.doc)
+        };
+    }
+
+    /**
+     * Get the ancestor/descendant graph of a batch up to depth hops (default 3), with every edge annotated by the
+     * operation that derived it, e.g. to find every product a recall of the batch reaches
+     * The graph follows the derivations the chaincode records: products packaged from a batch, and batches
+     * continued from a batch on another channel (foreign batches are not followed further)
+     * Permission: All organizations can query
+     */
+    @Transaction(false)
+    @Returns('BatchGenealogy')
+    public async GetBatchGenealogy(ctx: Context, batchId: string, depth: string): Promise<BatchGenealogy> {
+        const maxDepth = depth ? Number(depth) : DEFAULT_GENEALOGY_DEPTH;
+        if (!Number.isInteger(maxDepth) || maxDepth < 1 || maxDepth > MAX_GENEALOGY_DEPTH) {
+            throw new Error(`Invalid depth ${depth}: expected a whole number of hops from 1 to ${MAX_GENEALOGY_DEPTH}`);
+        }
+        const root = await this.ReadRiceBatch(ctx, batchId);
+
+        // One scan of the products serves every batch in the graph
+        const productsByBatch = new Map<string, Product[]>();
+        const products = new Map<string, Product>();
+        for (const product of await new ProductManagementContract().GetAllProducts(ctx)) {
+            products.set(product.productId, product);
+            productsByBatch.set(product.batchId, [...(productsByBatch.get(product.batchId) || []), product]);
+        }
+        const batches = new Map<string, RiceBatch>([[root.batchId, root]]);
+        const loadBatch = async (id: string): Promise<RiceBatch | null> => {
+            if (!batches.has(id)) {
+                const batch = await readDocument<RiceBatch>(ctx, `batch_${id}`);
+                if (!batch) {
+                    return null;
+                }
+                batches.set(id, batch);
+            }
+            return batches.get(id) as RiceBatch;
+        };
+
+        // Neighbours of a node with the edge connecting them; direction is +1 for descendants, -1 for ancestors
+        const neighbours = async (node: GenealogyNode): Promise<{ node: GenealogyNode; edge: GenealogyEdge; direction: number }[]> => {
+            if (node.type === 'batch') {
+                const batch = await loadBatch(node.id) as RiceBatch;
+                return [
+                    ...(productsByBatch.get(node.id) || []).map(product => ({
+                        node: { id: product.productId, type: 'product', generation: 0, owner: product.owner, state: product.status },
+                        edge: { from: node.id, to: product.productId, operation: 'packaging', at: product.packageDate },
+                        direction: 1
+                    })),
+                    ...(batch.foreignReferences || []).map(reference => ({
+                        node: { id: `${reference.channel}/${reference.batchId}`, type: 'foreignBatch', generation: 0 },
+                        edge: { from: `${reference.channel}/${reference.batchId}`, to: node.id, operation: 'link', at: reference.linkedAt },
+                        direction: -1
+                    }))
+                ];
+            }
+            if (node.type === 'product') {
+                const product = products.get(node.id) as Product;
+                const batch = await loadBatch(product.batchId);
+                return batch ? [{
+                    node: { id: batch.batchId, type: 'batch', generation: 0, owner: batch.currentOwner, state: batch.currentState },
+                    edge: { from: batch.batchId, to: node.id, operation: 'packaging', at: product.packageDate },
+                    direction: -1
+                }] : [];
+            }
+            return [];
+        };
+
+        const genealogy: BatchGenealogy = {
+            batchId: root.batchId,
+            depth: maxDepth,
+            nodes: [{ id: root.batchId, type: 'batch', generation: 0, owner: root.currentOwner, state: root.currentState }],
+            edges: [],
+            truncated: false
+        };
+        const visited = new Set([`batch:${root.batchId}`]);
+        const edgeKeys = new Set<string>();
+        let frontier = [...genealogy.nodes];
+        for (let hop = 1; frontier.length > 0; hop++) {
+            const next: GenealogyNode[] = [];
+            for (const node of frontier) {
+                for (const neighbour of await neighbours(node)) {
+                    const nodeKey = `${neighbour.node.type}:${neighbour.node.id}`;
+                    if (!visited.has(nodeKey) && hop > maxDepth) {
+                        genealogy.truncated = true;
+                        continue;
+                    }
+                    const edgeKey = `${neighbour.edge.from}>${neighbour.edge.to}:${neighbour.edge.operation}`;
+                    if (!edgeKeys.has(edgeKey)) {
+                        edgeKeys.add(edgeKey);
+                        genealogy.edges.push(neighbour.edge);
+                    }
+                    if (!visited.has(nodeKey)) {
+                        visited.add(nodeKey);
+                        neighbour.node.generation = node.generation + neighbour.direction;
+                        genealogy.nodes.push(neighbour.node);
+                        next.push(neighbour.node);
+                    }
+                }
+            }
+            frontier = hop > maxDepth ? [] : next;
+        }
+        return genealogy;
+    }
+
+    /**
+     * Get current status summary of the batch
+     * Permission: All organizations can query
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetBatchCurrentStatus(ctx: Context, batchId: string): Promise<string> {
+        const batch = await this.ReadRiceBatch(ctx, batchId);
+        
+        const statusInfo = {
+            batchId: batch.batchId,
+            currentOwner: batch.currentOwner,
+            currentState: batch.currentState,
+            status: batch.status || await deriveBatchStatus(ctx, batch),
+            variety: batch.variety,
+            origin: batch.origin,
+            harvestDate: batch.harvestDate,
+            totalEvents: batch.history.length,
+            lastUpdate: batch.history.length > 0 ? batch.history[batch.history.length - 1].timestamp : ''
+        };
+        
+        return JSON.stringify(statusInfo, null, 2);
+    }
+
+    /**
+     * Get all rice batches currently at a processing step, using the step index
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('RiceBatch[]')
+    public async GetRiceBatchesByProcessingStep(ctx: Context, step: string): Promise<RiceBatch[]> {
+        if (!step) {
+            throw new Error('Processing step is required');
+        }
+
+        const entries = await getIndexEntries(ctx, STEP_INDEX, [step]);
+        const batches: RiceBatch[] = [];
+        for (const [, batchId] of entries) {
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+            // Guard against stale index entries
+            if (batch && batch.currentState === step) {
+                batches.push(batch);
+            }
+        }
+        return batches;
+    }
+
+    /**
+     * Get all rice batches carrying a label, using the label index
+     * value is optional; when empty, batches with any value of the label are returned
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('RiceBatch[]')
+    public async GetRiceBatchesByLabel(ctx: Context, key: string, value: string): Promise<RiceBatch[]> {
+        const batches: RiceBatch[] = [];
+        for (const batchId of await getLabeledIds(ctx, BATCH_LABEL_INDEX, key, value)) {
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+            // Guard against stale index entries
+            if (batch && batch.labels && batch.labels[key] !== undefined && (!value || batch.labels[key] === value)) {
+                batches.push(batch);
+            }
+        }
+        return batches;
+    }
+
+    /**
+     * Get what an owner currently holds in one call: its batches with their remaining (unreserved) quantity, its
+     * products, and the batch quantities totalled overall, by variety and by processing step
+     * Disposed batches and sold or disposed products are not inventory
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('OwnerInventory')
+    public async GetOwnerInventory(ctx: Context, owner: string): Promise<OwnerInventory> {
+        if (!owner) {
+            throw new Error('Owner is required');
+        }
+
+        const now = getTxTimestamp(ctx);
+        const newTotals = (): InventoryTotals => ({ batches: 0, batchesWithoutQuantity: 0, quantityKg: 0, reservedKg: 0, availableKg: 0 });
+        const inventory: OwnerInventory = { owner, batches: [], products: [], totals: newTotals(), byVariety: {}, byStep: {} };
+
+        for (const [, batchId] of await getIndexEntries(ctx, BATCH_OWNER_INDEX, [owner])) {
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+            // Guard against stale index entries
+            if (!batch || batch.currentOwner !== owner || batch.disposal || batch.currentState === DISPOSED_STATE) {
+                continue;
+            }
+
+            const quantityKg = batch.quantityKg || 0;
+            const reservedKg = reservedQuantity(batch, now);
+            const availableKg = Math.max(quantityKg - reservedKg, 0);
+            inventory.batches.push({ batchId, variety: batch.variety, currentState: batch.currentState, quantityKg, reservedKg, availableKg });
+
+            inventory.byVariety[batch.variety] = inventory.byVariety[batch.variety] || newTotals();
+            inventory.byStep[batch.currentState] = inventory.byStep[batch.currentState] || newTotals();
+            for (const totals of [inventory.totals, inventory.byVariety[batch.variety], inventory.byStep[batch.currentState]]) {
+                totals.batches++;
+                if (!batch.quantityKg) {
+                    totals.batchesWithoutQuantity++;
+                }
+                totals.quantityKg += quantityKg;
+                totals.reservedKg += reservedKg;
+                totals.availableKg += availableKg;
+            }
+        }
+
+        for (const [, productId] of await getIndexEntries(ctx, OWNER_INDEX, [owner])) {
+            const product = await readDocument<Product>(ctx, `product_${productId}`);
+            if (product && product.owner === owner && product.status !== 'Sold' && product.status !== DISPOSED_STATE) {
+                inventory.products.push(product);
+            }
+        }
+        return inventory;
+    }
+
+    /**
+     * Put offered lots side by side for a buyer: origin, variety, harvest, available quantity, the latest standing
+     * result of each test type, the grades declared on their products and their active certificates
+     * batchIds: JSON array of 2 to 10 batch IDs
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('BatchComparison')
+    public async CompareBatches(ctx: Context, batchIds: string): Promise<BatchComparison> {
+        let ids: unknown;
+        try {
+            ids = JSON.parse(batchIds);
+        } catch (error) {
+            throw new Error(`Batch IDs format error: ${error}`);
+        }
+        if (!Array.isArray(ids) || ids.some(id => typeof id !== 'string' || !id)) {
+            throw new Error('Batch IDs must be a list of batch IDs');
+        }
+        const uniqueIds = [...new Set(ids as string[])];
+        if (uniqueIds.length < MIN_COMPARED_BATCHES || uniqueIds.length > MAX_COMPARED_BATCHES) {
+            throw new Error(`Between ${MIN_COMPARED_BATCHES} and ${MAX_COMPARED_BATCHES} different batches can be compared, got ${uniqueIds.length}`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const qualityContract = new QualityCertificationContract();
+        const tests = await qualityContract.GetAllTestResults(ctx);
+        const certificates = await qualityContract.GetAllQualityCertificates(ctx);
+        const comparison: BatchComparison = { comparedAt: now, testTypes: [], certificateTypes: [], batches: [] };
+
+        for (const batchId of uniqueIds) {
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+            if (!batch) {
+                throw new Error(`The rice batch ${batchId} does not exist`);
+            }
+
+            const compared: ComparedBatch = {
+                batchId,
+                origin: batch.origin,
+                variety: batch.variety,
+                harvestDate: batch.harvestDate,
+                cropYear: batch.cropYear,
+                season: batch.season,
+                currentState: batch.currentState,
+                currentOwner: batch.currentOwner,
+                availableKg: batch.quantityKg === undefined ? undefined : Math.max(batch.quantityKg - reservedQuantity(batch, now), 0),
+                geographicIndication: batch.giCompliance && batch.giCompliance.passed ? batch.giCompliance.giName : undefined,
+                quarantined: !!batch.quarantined,
+                qualityMetrics: {},
+                grades: [],
+                certifications: []
+            };
+
+            // Latest result per test type; a later test supersedes an earlier one of the same type
+            const batchTests = tests
+                .filter(test => test.batchId === batchId && !test.revoked)
+                .sort((a, b) => a.testDate.localeCompare(b.testDate));
+            for (const test of batchTests) {
+                compared.qualityMetrics[test.testType] = {
+                    testId: test.testId,
+                    result: test.testResult || test.result,
+                    passed: isPassedTest(test),
+                    testDate: test.testDate,
+                    laboratory: test.laboratory,
+                    isVerified: test.isVerified
+                };
+            }
+
+            for (const [, productId] of await getIndexEntries(ctx, PRODUCT_BATCH_INDEX, [batchId])) {
+                const product = await readDocument<Product>(ctx, `product_${productId}`);
+                const grade = product && product.batchId === batchId && product.composition ? product.composition.grade : undefined;
+                if (grade && !compared.grades.includes(grade)) {
+                    compared.grades.push(grade);
+                }
+            }
+            compared.grades.sort();
+
+            compared.certifications = certificates
+                .filter(cert => cert.batchId === batchId && this.isCertificateInForce(cert, now))
+                .map(cert => ({
+                    certificateId: cert.certificateId,
+                    certificateType: cert.certificateType,
+                    issuer: cert.issuer,
+                    standards: cert.standards,
+                    expiresAt: getCertificateExpiry(cert.issueDate, cert.validityPeriod) || undefined
+                }));
+
+            comparison.batches.push(compared);
+        }
+
+        comparison.testTypes = [...new Set(comparison.batches.flatMap(batch => Object.keys(batch.qualityMetrics)))].sort();
+        comparison.certificateTypes = [...new Set(comparison.batches.flatMap(batch => batch.certifications.map(cert => cert.certificateType)))].sort();
+        return comparison;
+    }
+
+    /**
+     * Whether a certificate is active and not past its validity period
+     */
+    private isCertificateInForce(cert: QualityCertificate, now: string): boolean {
+        if (!cert.isActive) {
+            return false;
+        }
+        const expiry = getCertificateExpiry(cert.issueDate, cert.validityPeriod);
+        return expiry === null || expiry >= now;
+    }
+
+    /**
+     * Rebuild the processing step index from the stored batches
+     * Needed once after upgrading from a version that did not maintain the index
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async RebuildStepIndex(ctx: Context): Promise<number> {
+        checkOrgAdmin(ctx);
+
+        const batches = await this.GetAllRiceBatches(ctx);
+        for (const batch of batches) {
+            await putIndexEntry(ctx, STEP_INDEX, [batch.currentState, batch.batchId]);
+        }
+        return batches.length;
+    }
+
+    /**
+     * Scan the ledger for references to entities that do not exist and suggest how to repair each, e.g. after a
+     * bulk import. Nothing is changed. Rules:
+     * - missingBatch: a product packaged from a batch that does not exist
+     * - unregisteredParticipant: a party of a batch history event or product transfer, or a product owner, that is
+     *   not a registered participant (by ID or name); reported once per party, at the first reference found
+     * - danglingGenealogyEdge: a packaging edge of the product-by-batch index whose batch or product no longer
+     *   exists, or whose product was packaged from another batch
+     * References to batches on other channels are not checked
+     * Permission: Only organization administrators can call
+     */
+    @Transaction(false)
+    @Returns('ReferentialIntegrityReport')
+    public async ValidateReferentialIntegrity(ctx: Context): Promise<ReferentialIntegrityReport> {
+        checkOrgAdmin(ctx);
+
+        // Batches come with their archived history
+        const batches = new Map<string, RiceBatch>();
+        for (const batch of await this.GetAllRiceBatches(ctx)) {
+            batches.set(batch.batchId, batch);
+        }
+        const products = new Map<string, Product>();
+        for (const product of await new ProductManagementContract().GetAllProducts(ctx)) {
+            products.set(product.productId, product);
+        }
+        const participants = await getParticipantsByIdAndName(ctx);
+        const issues: IntegrityIssue[] = [];
+
+        for (const product of products.values()) {
+            if (!batches.has(product.batchId)) {
+                issues.push({
+                    rule: 'missingBatch', entityId: product.productId, reference: product.batchId,
+                    message: `Product ${product.productId} was packaged from batch ${product.batchId}, which does not exist`,
+                    repair: `Import batch ${product.batchId} again, or dispose of product ${product.productId} if it was imported in error`
+                });
+            }
+        }
+
+        const parties = new Map<string, { issue: IntegrityIssue; references: number }>();
+        const addParty = (party: string, entityId: string, index?: number) => {
+            if (!party || participants.has(party)) {
+                return;
+            }
+            const seen = parties.get(party);
+            if (seen) {
+                seen.references++;
+                return;
+            }
+            const issue: IntegrityIssue = { rule: 'unregisteredParticipant', entityId, reference: party, message: '', repair: '' };
+            if (index !== undefined) {
+                issue.index = index;
+            }
+            parties.set(party, { issue, references: 1 });
+        };
+        for (const batch of batches.values()) {
+            batch.history.forEach((event, index) => {
+                addParty(event.from, batch.batchId, index);
+                addParty(event.to, batch.batchId, index);
+            });
+        }
+        for (const product of products.values()) {
+            (product.transfers || []).forEach((transfer, index) => {
+                addParty(transfer.from, product.productId, index);
+                addParty(transfer.to, product.productId, index);
+            });
+            addParty(product.owner, product.productId);
+        }
+        for (const [party, { issue, references }] of parties) {
+            issue.message = `${party} is named in ${references} record(s), first on ${issue.entityId}, but is not a registered participant`;
+            issue.repair = `Register ${party} with RegisterParticipant, using the name as recorded, or correct the records naming it`;
+            issues.push(issue);
+        }
+
+        for (const [batchId, productId] of await getIndexEntries(ctx, PRODUCT_BATCH_INDEX, [])) {
+            const product = products.get(productId);
+            // A product whose batch is missing is already reported as missingBatch
+            if (product && product.batchId === batchId) {
+                continue;
+            }
+            const reason = !batches.has(batchId) ? `batch ${batchId} does not exist`
+                : !product ? `product ${productId} does not exist` : `product ${productId} was packaged from batch ${product.batchId}`;
+            // Queries skip stale entries, so only the missing side needs repairing
+            const repair = product
+                ? `Run RebuildProductQueryIndexes so product ${productId} is indexed under batch ${product.batchId}`
+                : `Import product ${productId} again if it should exist; otherwise no repair is needed`;
+            issues.push({
+                rule: 'danglingGenealogyEdge', entityId: productId, reference: batchId,
+                message: `The packaging edge from batch ${batchId} to product ${productId} is stale: ${reason}`,
+                repair
+            });
+        }
+
+        const participantIds = new Set([...participants.values()].map(participant => participant.participantId));
+        return {
+            checkedAt: getTxTimestamp(ctx),
+            batches: batches.size,
+            products: products.size,
+            participants: participantIds.size,
+            consistent: issues.length === 0,
+            issues
+        };
+    }
+
+    /**
+     * Delete all batches, products, test results, samples, certificates, participants, terms and value commitments, daily statistics,
+     * EPCIS logistics units and shipments, weather observations, market prices and indexes
+     * Development only: refused unless the chaincode runs with RICETRACE_ALLOW_LEDGER_RESET=true,
+     * so test networks can be reset without redeploying the chaincode
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async ResetLedgerState(ctx: Context): Promise<number> {
+        checkOrgAdmin(ctx);
+        if (process.env[LEDGER_RESET_FLAG] !== 'true') {
+            throw new Error(`Ledger reset is disabled; set ${LEDGER_RESET_FLAG}=true on the chaincode of a development network to enable it`);
+        }
+
+        let deleted = 0;
+        for (const prefix of RESET_KEY_PREFIXES) {
+            deleted += await this.deleteRange(ctx, prefix, `${prefix}\uffff`);
+        }
+        const indexes = [
+            STEP_INDEX, BATCH_OWNER_INDEX, BATCH_LABEL_INDEX, OWNER_INDEX, PRODUCT_LABEL_INDEX, TEST_OUTCOME_INDEX, BEST_BEFORE_INDEX,
+            PLOT_WEATHER_INDEX, PRICE_INDEX, ENTITY_ATTACHMENT_INDEX, EQUIPMENT_USAGE_INDEX, CONSIGNMENT_ITEM_INDEX,
+            BATCH_TEST_INDEX, PRODUCT_STATUS_INDEX, PRODUCT_BATCH_INDEX, PACKAGE_DATE_INDEX,
+            CROP_SEASON_INDEX, AGRO_INPUT_INDEX, FACILITY_ACTIVITY_INDEX, ENTITY_DOCUMENT_INDEX, DOCUMENT_HASH_INDEX, DOCUMENT_ACKNOWLEDGMENT_INDEX,
+            SCHEDULED_TRANSFER_INDEX, RECALL_ITEM_INDEX, RECALL_ACKNOWLEDGMENT_INDEX, BATCH_STATUS_INDEX, VALUE_COMMITMENT_KEY
+        ];
+        for (const indexName of indexes) {
+            const entries = await getIndexEntries(ctx, indexName, []);
+            for (const attributes of entries) {
+                await deleteIndexEntry(ctx, indexName, attributes);
+            }
+            deleted += entries.length;
+        }
+        return deleted;
+    }
+
+    /**
+     * Write the immutable activity summary of a completed UTC day (YYYY-MM-DD)
+     * Counts batches created, batch and product transfers, tests recorded and failed, and recalls on that day,
+     * so trend reports can read one document per day instead of replaying history. Meant to be invoked daily
+     * by a scheduler; a day can only be snapshotted once it is over, and only once
+     * Permission: No restriction (the summary is computed from the ledger)
+     */
+    @Transaction()
+    public async SnapshotDailyStats(ctx: Context, date: string): Promise<DailyStats> {
+        if (!/^\d{4}-\d{2}-\d{2}$/.test(date || '')) {
+            throw new Error(`Invalid date ${date}: expected YYYY-MM-DD`);
+        }
+        normalizeTimestamp(date, 'date'); // Rejects out-of-range dates such as 2024-02-30
+        const now = getTxTimestamp(ctx);
+        if (date >= now.slice(0, 10)) {
+            throw new Error(`Statistics for ${date} can only be taken once the day is over`);
+        }
+        if (await readDocument<DailyStats>(ctx, `stats_${date}`)) {
+            throw new Error(`Statistics for ${date} have already been recorded`);
+        }
+
+        const onDate = (timestamp?: string) => !!timestamp && timestamp.slice(0, 10) === date;
+        const isRecall = (disposal?: Disposal) => !!disposal && onDate(disposal.timestamp) && RECALL_REASON_PATTERN.test(disposal.reason);
+
+        const stats: DailyStats = {
+            docType: 'dailyStats',
+            date,
+            batchesCreated: 0,
+            batchTransfers: 0,
+            productTransfers: 0,
+            testsRecorded: 0,
+            failedTests: 0,
+            recalls: 0,
+            generatedAt: now,
+            generatedBy: ctx.clientIdentity.getMSPID()
+        };
+
+        for (const batch of await this.GetAllRiceBatches(ctx)) {
+            batch.history.forEach((event, index) => {
+                if (!onDate(event.timestamp)) {
+                    return;
+                }
+                if (index === 0) {
+                    stats.batchesCreated++;
+                } else if (event.step !== DISPOSED_STATE) {
+                    stats.batchTransfers++;
+                }
+            });
+            if (isRecall(batch.disposal)) {
+                stats.recalls++;
+            }
+        }
+
+        for (const product of await new ProductManagementContract().GetAllProducts(ctx)) {
+            stats.productTransfers += (product.transfers || []).filter(transfer => onDate(transfer.timestamp)).length;
+            if (isRecall(product.disposal)) {
+                stats.recalls++;
+            }
+        }
+
+        for (const test of await new QualityCertificationContract().GetAllTestResults(ctx)) {
+            if (onDate(test.testDate)) {
+                stats.testsRecorded++;
+                if (!isPassingResult(test.testResult)) {
+                    stats.failedTests++;
+                }
+            }
+        }
+
+        await writeDocument(ctx, `stats_${date}`, stats);
+        emitEvent(ctx, 'DailyStatsRecorded', stats);
+        return stats;
+    }
+
+    /**
+     * Get the recorded daily statistics between two dates (YYYY-MM-DD, inclusive)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('DailyStats[]')
+    public async GetDailyStats(ctx: Context, startDate: string, endDate: string): Promise<DailyStats[]> {
+        const resultsIterator = await ctx.stub.getStateByRange(`stats_${startDate}`, `stats_${endDate}\uffff`);
+        const stats: DailyStats[] = [];
+
+        let result = await resultsIterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                stats.push(JSON.parse(result.value.value.toString()));
+            }
+            result = await resultsIterator.next();
+        }
+
+        await resultsIterator.close();
+        return stats;
+    }
+
+    /**
+     * Get network-level KPIs for the operators' steering committee: registered participants and those active in the
+     * last activeDays days (90 when empty), batches per current processing step, the average days from harvest to
+     * packaging, and the quarantines and settlement disputes still open. Computed from the current state, unlike the
+     * daily statistics
+     * Permission: No restriction; identities of a tenant only count the batches of their tenant and shared batches
+     */
+    @Transaction(false)
+    @Returns('NetworkKpis')
+    public async GetNetworkKpis(ctx: Context, activeDays: string): Promise<NetworkKpis> {
+        const days = activeDays ? Number(activeDays) : DEFAULT_ACTIVE_DAYS;
+        if (!Number.isInteger(days) || days < 1) {
+            throw new Error(`activeDays must be a positive whole number of days, got ${activeDays}`);
+        }
+        const now = getTxTimestamp(ctx);
+        const activeSince = new Date(Date.parse(now) - days * 24 * 60 * 60 * 1000).toISOString();
+        const isRecent = (timestamp?: string) => !!timestamp && timestamp >= activeSince && timestamp <= now;
+
+        const kpis: NetworkKpis = {
+            generatedAt: now,
+            activeSince,
+            registeredParticipants: 0,
+            activeParticipants: 0,
+            activeParties: 0,
+            batches: 0,
+            batchesByStage: {},
+            packagedBatches: 0,
+            openQuarantines: 0,
+            openDisputes: 0
+        };
+
+        const activeParties = new Set<string>();
+        const addParty = (party?: string) => {
+            if (party) {
+                activeParties.add(party);
+            }
+        };
+        let harvestToPackagingDays = 0;
+        for (const batch of await this.GetAllRiceBatches(ctx)) {
+            kpis.batches++;
+            kpis.batchesByStage[batch.currentState] = (kpis.batchesByStage[batch.currentState] || 0) + 1;
+            if (batch.quarantined && batch.currentState !== DISPOSED_STATE) {
+                kpis.openQuarantines++;
+            }
+            for (const event of batch.history) {
+                if (isRecent(event.timestamp)) {
+                    addParty(event.from);
+                    addParty(event.to);
+                }
+            }
+            const packaged = batch.history.find(event => event.step === 'Packaged');
+            const elapsed = packaged ? Date.parse(packaged.timestamp) - Date.parse(batch.harvestDate) : NaN;
+            // Legacy free-form dates cannot be measured
+            if (!isNaN(elapsed)) {
+                harvestToPackagingDays += elapsed / (24 * 60 * 60 * 1000);
+                kpis.packagedBatches++;
+            }
+        }
+        if (kpis.packagedBatches > 0) {
+            kpis.averageHarvestToPackagingDays = Math.round(harvestToPackagingDays / kpis.packagedBatches * 10) / 10;
+        }
+
+        for (const product of await new ProductManagementContract().GetAllProducts(ctx)) {
+            for (const transfer of product.transfers || []) {
+                if (isRecent(transfer.timestamp)) {
+                    addParty(transfer.from);
+                    addParty(transfer.to);
+                }
+            }
+        }
+        kpis.activeParties = activeParties.size;
+
+        const participants = await getParticipantsByIdAndName(ctx);
+        const registered = new Set([...participants.values()].map(participant => participant.participantId));
+        kpis.registeredParticipants = registered.size;
+        const active = new Set<string>();
+        for (const party of activeParties) {
+            const participant = participants.get(party);
+            if (participant) {
+                active.add(participant.participantId);
+            }
+        }
+        kpis.activeParticipants = active.size;
+
+        const iterator = await ctx.stub.getStateByRange('settlement_', 'settlement_\uffff');
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                const settlement: Settlement = JSON.parse(result.value.value.toString());
+                if (settlement.status === 'disputed' && isVisibleToCaller(ctx, settlement)) {
+                    kpis.openDisputes++;
+                }
+            }
+            result = await iterator.next();
+        }
+        await iterator.close();
+
+        return kpis;
+    }
+
+    /**
+     * Read rice batch information, with its full history (including events moved to continuation keys)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('RiceBatch')
+    public async ReadRiceBatch(ctx: Context, batchId: string): Promise<RiceBatch> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+
+        return withArchivedHistory(ctx, batch);
+    }
+
+    /**
+     * Check if rice batch exists
+     * Batch IDs share one key space across tenants, so batches of other tenants count as existing
+     * Permission: No restriction
      */
     @Transaction(false)
     public async RiceBatchExists(ctx: Context, batchId: string): Promise<boolean> {
@@ -350,8 +2757,8 @@ export class RiceTracerContract extends Contract {
     }
 
     /**
-     * Get all rice batches
-     * Permission: No restriction
+     * Get all rice batches visible to the caller
+     * Permission: No restriction; identities of a tenant only see the batches of their tenant and shared batches
      */
     @Transaction(false)
     @Returns('RiceBatch[]')
@@ -364,8 +2771,8 @@ export class RiceTracerContract extends Contract {
             if (result.value && result.value.value.toString()) {
                 try {
                     const batch: RiceBatch = JSON.parse(result.value.value.toString());
-                    if (batch.batchId) {
-                        batches.push(batch);
+                    if (batch.batchId && isVisibleToCaller(ctx, batch)) {
+                        batches.push(await withArchivedHistory(ctx, batch));
                     }
                 } catch (error) {
                     // Skip invalid data
@@ -378,4 +2785,44 @@ export class RiceTracerContract extends Contract {
         await resultsIterator.close();
         return batches;
     }
-} 
\ No newline at end of file
+
+    /**
+     * Get all rice batches one page at a time, in batch ID order, e.g. to compare the ledger with an off-chain copy
+     * pageSize: maximum number of batches per page; bookmark: empty for the first page
+     * Permission: No restriction; batches of other tenants are left out, so a page may hold fewer than pageSize batches
+     */
+    @Transaction(false)
+    @Returns('BatchQueryResult')
+    public async GetRiceBatchesWithPagination(ctx: Context, pageSize: number, bookmark: string): Promise<BatchQueryResult> {
+        const size = Number(pageSize);
+        if (!Number.isInteger(size) || size <= 0 || size > 1000) {
+            throw new Error(`Invalid page size ${pageSize}: must be an integer between 1 and 1000`);
+        }
+
+        const { iterator, metadata } = await ctx.stub.getStateByRangeWithPagination('batch_', 'batch_\uffff', size, bookmark || '');
+        const batches: RiceBatch[] = [];
+
+        let result = await iterator.next();
+        while (!result.done) {
+            if (result.value && result.value.value.toString()) {
+                try {
+                    const batch: RiceBatch = JSON.parse(result.value.value.toString());
+                    if (batch.batchId && isVisibleToCaller(ctx, batch)) {
+                        batches.push(await withArchivedHistory(ctx, batch));
+                    }
+                } catch (error) {
+                    // Skip invalid data
+                    console.warn(`Skipping invalid batch data: ${error}`);
+                }
+            }
+            result = await iterator.next();
+        }
+
+        await iterator.close();
+        return {
+            batches,
+            fetchedRecordsCount: metadata.fetchedRecordsCount,
+            bookmark: metadata.bookmark
+        };
+    }
+}
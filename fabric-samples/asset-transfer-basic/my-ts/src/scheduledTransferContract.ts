@@ -0,0 +1,231 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { HistoryEvent, RiceBatch, ScheduledTransfer } from './types';
+import { BATCH_OWNER_INDEX } from './riceTracerContract';
+import { appendHistoryEvent } from './batchStorageContract';
+import { consumeReservations } from './batchReservationContract';
+import {
+    readDocument, patchDocument, normalizeTimestamp, getTxTimestamp, emitEvent, putIndexEntry, getIndexEntries, deleteIndexEntry,
+    assertNotBefore, getCallerFingerprint, DISPOSED_STATE
+} from './utils';
+
+/**
+ * Composite key index of pending scheduled transfers by the time they become effective
+ */
+export const SCHEDULED_TRANSFER_INDEX = 'effectiveTime~batchId~scheduleId';
+
+@Info({ title: 'ScheduledTransferContract', description: 'Smart contract scheduling batch handovers for a contractual date' })
+export class ScheduledTransferContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "ScheduledTransferContract Method Permission Configuration": {
+                "ScheduleTransfer": ["Organization owning the batch"],
+                "ActivateScheduledTransfer": ["All Organizations (once effective)"],
+                "CancelScheduledTransfer": ["Organization that scheduled the transfer"],
+                "GetScheduledTransfers": ["All Organizations"],
+                "GetDueScheduledTransfers": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Schedule the handover of a batch to newOwner at effectiveTime (RFC3339, in the future), e.g. the handover date
+     * of a sales contract. Once the transaction time reaches effectiveTime any party can activate the transfer,
+     * so nobody has to submit it at midnight. A batch has at most one pending scheduled transfer. Returns it
+     * Permission: The organization owning the batch (signer of its latest history event)
+     */
+    @Transaction()
+    @Returns('ScheduledTransfer')
+    public async ScheduleTransfer(ctx: Context, batchId: string, newOwner: string, effectiveTime: string): Promise<ScheduledTransfer> {
+        const batch = await this.readBatch(ctx, batchId);
+        const lastEvent = batch.history[batch.history.length - 1];
+        const ownerMspId = lastEvent && lastEvent.signerMspId ? lastEvent.signerMspId : '';
+        if (ownerMspId !== ctx.clientIdentity.getMSPID()) {
+            throw new Error(`Permission denied: Only the organization owning batch ${batchId} (${ownerMspId || 'unknown'}) can schedule its transfer`);
+        }
+        if (!newOwner || !newOwner.trim()) {
+            throw new Error('New owner is required');
+        }
+        if (newOwner.trim() === batch.currentOwner) {
+            throw new Error(`Batch ${batchId} is already held by ${batch.currentOwner}`);
+        }
+        const pending = (batch.scheduledTransfers || []).find(schedule => schedule.status === 'Pending');
+        if (pending) {
+            throw new Error(`Batch ${batchId} already has a transfer to ${pending.newOwner} scheduled for ${pending.effectiveTime}; cancel it first`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const effective = normalizeTimestamp(effectiveTime, 'effectiveTime');
+        if (effective <= now) {
+            throw new Error(`effectiveTime ${effective} must be in the future; transfer the batch directly instead`);
+        }
+
+        const schedule: ScheduledTransfer = {
+            scheduleId: ctx.stub.getTxID(),
+            batchId,
+            fromOwner: batch.currentOwner,
+            newOwner: newOwner.trim(),
+            effectiveTime: effective,
+            scheduledByMspId: ctx.clientIdentity.getMSPID(),
+            scheduledAt: now,
+            status: 'Pending'
+        };
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            scheduledTransfers: [...(batch.scheduledTransfers || []), schedule]
+        });
+        await putIndexEntry(ctx, SCHEDULED_TRANSFER_INDEX, [effective, batchId, schedule.scheduleId]);
+        emitEvent(ctx, 'TransferScheduled', updated);
+        return schedule;
+    }
+
+    /**
+     * Activate the pending scheduled transfer of a batch: hand the batch over to the new owner, keeping its
+     * processing step. The transaction time must have reached the effective time, and the batch must still be held
+     * by the owner that scheduled the transfer. The history event records the caller as signer and the schedule as
+     * its report
+     * Permission: No restriction
+     */
+    @Transaction()
+    @Returns('ScheduledTransfer')
+    public async ActivateScheduledTransfer(ctx: Context, batchId: string): Promise<ScheduledTransfer> {
+        const batch = await this.readBatch(ctx, batchId);
+        const schedules = batch.scheduledTransfers || [];
+        const schedule = schedules.find(candidate => candidate.status === 'Pending');
+        if (!schedule) {
+            throw new Error(`Batch ${batchId} has no pending scheduled transfer`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        if (now < schedule.effectiveTime) {
+            throw new Error(`The transfer of batch ${batchId} to ${schedule.newOwner} cannot be activated before ${schedule.effectiveTime}`);
+        }
+        if (batch.currentOwner !== schedule.fromOwner) {
+            throw new Error(`Batch ${batchId} was handed over to ${batch.currentOwner} after the transfer was scheduled; the scheduled transfer can only be cancelled`);
+        }
+        const lastEvent = batch.history[batch.history.length - 1];
+        if (lastEvent) {
+            assertNotBefore(now, 'Transfer time', lastEvent.timestamp, `previous ${lastEvent.step} event`);
+        }
+
+        // Quantity reserved for a pending sale only goes to its buyer
+        const reservations = consumeReservations(batch, schedule.newOwner, now);
+        const historyEvent: HistoryEvent = {
+            timestamp: now,
+            from: batch.currentOwner,
+            to: schedule.newOwner,
+            step: batch.currentState,
+            report: {
+                reportId: schedule.scheduleId,
+                reportType: 'ScheduledTransfer',
+                reportHash: '',
+                summary: `Handed over to ${schedule.newOwner} as scheduled by ${schedule.scheduledByMspId} for ${schedule.effectiveTime}`,
+                isVerified: false
+            },
+            signerMspId: ctx.clientIdentity.getMSPID(),
+            signerFingerprint: getCallerFingerprint(ctx)
+        };
+        const activated: ScheduledTransfer = { ...schedule, status: 'Activated', closedAt: now, activatedByMspId: ctx.clientIdentity.getMSPID() };
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            ...await appendHistoryEvent(ctx, batch, historyEvent),
+            ...(reservations ? { reservations } : {}),
+            scheduledTransfers: schedules.map(candidate => candidate === schedule ? activated : candidate),
+            currentOwner: schedule.newOwner
+        });
+        await deleteIndexEntry(ctx, BATCH_OWNER_INDEX, [batch.currentOwner, batchId]);
+        await putIndexEntry(ctx, BATCH_OWNER_INDEX, [schedule.newOwner, batchId]);
+        await deleteIndexEntry(ctx, SCHEDULED_TRANSFER_INDEX, [schedule.effectiveTime, batchId, schedule.scheduleId]);
+        emitEvent(ctx, 'ScheduledTransferActivated', updated);
+        return activated;
+    }
+
+    /**
+     * Cancel the pending scheduled transfer of a batch, e.g. when the sale falls through
+     * Permission: The organization that scheduled the transfer
+     */
+    @Transaction()
+    public async CancelScheduledTransfer(ctx: Context, batchId: string): Promise<void> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        const schedules = batch.scheduledTransfers || [];
+        const schedule = schedules.find(candidate => candidate.status === 'Pending');
+        if (!schedule) {
+            throw new Error(`Batch ${batchId} has no pending scheduled transfer`);
+        }
+        if (schedule.scheduledByMspId !== ctx.clientIdentity.getMSPID()) {
+            throw new Error(`Permission denied: The transfer of batch ${batchId} was scheduled by ${schedule.scheduledByMspId}`);
+        }
+
+        const closedAt = getTxTimestamp(ctx);
+        const updated = await patchDocument<RiceBatch>(ctx, `batch_${batchId}`, {
+            scheduledTransfers: schedules.map(candidate => candidate === schedule ? { ...candidate, status: 'Cancelled', closedAt } : candidate)
+        });
+        await deleteIndexEntry(ctx, SCHEDULED_TRANSFER_INDEX, [schedule.effectiveTime, batchId, schedule.scheduleId]);
+        emitEvent(ctx, 'ScheduledTransferCancelled', updated);
+    }
+
+    /**
+     * Get the scheduled transfers of a batch, pending and closed, in the order they were scheduled
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ScheduledTransfer[]')
+    public async GetScheduledTransfers(ctx: Context, batchId: string): Promise<ScheduledTransfer[]> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        return batch.scheduledTransfers || [];
+    }
+
+    /**
+     * Get the pending scheduled transfers whose effective time has been reached, oldest first, e.g. for a job
+     * activating them
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('ScheduledTransfer[]')
+    public async GetDueScheduledTransfers(ctx: Context): Promise<ScheduledTransfer[]> {
+        const now = getTxTimestamp(ctx);
+        const due: ScheduledTransfer[] = [];
+        for (const [effectiveTime, batchId, scheduleId] of await getIndexEntries(ctx, SCHEDULED_TRANSFER_INDEX, [])) {
+            if (effectiveTime > now) {
+                break;
+            }
+            // readDocument hides batches of other tenants
+            const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+            const schedule = batch && (batch.scheduledTransfers || []).find(candidate => candidate.scheduleId === scheduleId);
+            if (schedule && schedule.status === 'Pending') {
+                due.push(schedule);
+            }
+        }
+        return due;
+    }
+
+    /**
+     * Read a batch that can still change hands
+     */
+    private async readBatch(ctx: Context, batchId: string): Promise<RiceBatch> {
+        const batch = await readDocument<RiceBatch>(ctx, `batch_${batchId}`);
+        if (!batch) {
+            throw new Error(`The rice batch ${batchId} does not exist`);
+        }
+        if (batch.disposal || batch.currentState === DISPOSED_STATE) {
+            throw new Error(`The rice batch ${batchId} has been disposed of and cannot be transferred`);
+        }
+        return batch;
+    }
+}
@@ -0,0 +1,221 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import {
+    CommercialTermsCommitment, FarmerSettlementReport, OrganizationType, RiceBatch, Settlement, SettlementLine
+} from './types';
+import { RiceTracerContract } from './riceTracerContract';
+import { getParticipantsByIdAndName } from './participantRegistryContract';
+import { resolveCropSeason } from './cropSeasonContract';
+import { readDocument, writeDocument, emitEvent, getTxTimestamp, parseInterval, implicitCollectionName, checkOrgType, inheritTenant } from './utils';
+
+/**
+ * Settlement statuses a party can record; a transfer without a record is unsettled
+ */
+const SETTLEMENT_STATUSES = ['invoiced', 'paid', 'disputed'];
+
+/**
+ * Seasons a period can name, as recorded on batches
+ */
+const SEASONS = ['Early', 'Middle', 'Late'];
+
+@Info({ title: 'SettlementContract', description: 'Smart contract tracking the settlement of batch transfers and reporting farmer payouts' })
+export class SettlementContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "SettlementContract Method Permission Configuration": {
+                "RecordSettlement": ["Farm", "Middleman/Tester (organizations that signed an event of the batch)"],
+                "GetSettlement": ["Farm", "Middleman/Tester"],
+                "GetFarmerSettlementReport": ["Farm", "Middleman/Tester"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Record the settlement status of the handover recorded by a history event of a batch: invoiced, paid or
+     * disputed, with an optional invoice or payment reference. Amounts are not recorded; they stay in the
+     * parties' private commercial terms. Every change is kept
+     * Permission: Farm and middleman/tester organizations that signed an event of the batch
+     */
+    @Transaction()
+    @Returns('Settlement')
+    public async RecordSettlement(ctx: Context, batchId: string, eventIndex: string, status: string, reference: string): Promise<Settlement> {
+        // Check permission: Only trading parties settle transfers
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!SETTLEMENT_STATUSES.includes(status)) {
+            throw new Error(`Invalid settlement status: ${status}. Allowed values: ${SETTLEMENT_STATUSES.join(', ')}`);
+        }
+        const batch = await new RiceTracerContract().ReadRiceBatch(ctx, batchId);
+        const index = Number(eventIndex);
+        const event = Number.isInteger(index) ? batch.history[index] : undefined;
+        if (!event) {
+            throw new Error(`Batch ${batchId} has no history event ${eventIndex}`);
+        }
+        if (!this.isHandover(event.from, event.to)) {
+            throw new Error(`History event ${eventIndex} of batch ${batchId} is not a handover between two parties`);
+        }
+        const mspId = ctx.clientIdentity.getMSPID();
+        if (!batch.history.some(candidate => candidate.signerMspId === mspId)) {
+            throw new Error(`Permission denied: ${mspId} is not a party to batch ${batchId}`);
+        }
+
+        const now = getTxTimestamp(ctx);
+        const key = `settlement_${batchId}_${index}`;
+        const existing = await readDocument<Settlement>(ctx, key);
+        const settlement: Settlement = {
+            docType: 'settlement',
+            batchId,
+            eventIndex: index,
+            from: event.from,
+            to: event.to,
+            status,
+            reference: reference || '',
+            lastUpdated: now,
+            changes: [...(existing ? existing.changes : []), { status, reference: reference || '', recordedByMspId: mspId, recordedAt: now }]
+        };
+        inheritTenant(settlement, batch);
+        await writeDocument(ctx, key, settlement);
+        emitEvent(ctx, 'SettlementStatusChanged', settlement);
+        return settlement;
+    }
+
+    /**
+     * Get the settlement of a transfer
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction(false)
+    @Returns('Settlement')
+    public async GetSettlement(ctx: Context, batchId: string, eventIndex: string): Promise<Settlement> {
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        const settlement = await readDocument<Settlement>(ctx, `settlement_${batchId}_${eventIndex}`);
+        if (!settlement) {
+            throw new Error(`No settlement is recorded for history event ${eventIndex} of batch ${batchId}`);
+        }
+        return settlement;
+    }
+
+    /**
+     * Report every transfer out of a farmer's batches with its settlement status, so a cooperative can reconcile
+     * payouts. farmerId is a participant ID or name; transfers recorded under either count. period is empty
+     * (all), a crop year (2024), a crop season (2024-Late) or an interval of transfer dates (2024-10-01/2024-12-31).
+     * When evaluated on a peer of the caller's organization, each line carries the commercial terms (prices) the
+     * organization holds for the batch
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction(false)
+    @Returns('FarmerSettlementReport')
+    public async GetFarmerSettlementReport(ctx: Context, farmerId: string, period: string): Promise<FarmerSettlementReport> {
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!farmerId) {
+            throw new Error('Farmer ID is required');
+        }
+        const matchesPeriod = this.parsePeriod(period || '');
+        const participant = (await getParticipantsByIdAndName(ctx)).get(farmerId);
+        const farmerNames = participant ? [...new Set([participant.participantId, participant.name].filter(name => !!name))] : [farmerId];
+
+        // Private terms are only readable on the caller's own peers
+        const mspId = ctx.clientIdentity.getMSPID();
+        const pricesIncluded = ctx.stub.getMspID() === mspId;
+
+        const lines: SettlementLine[] = [];
+        for (const batch of await new RiceTracerContract().GetAllRiceBatches(ctx)) {
+            const season = this.cropSeason(batch);
+            for (const [index, event] of batch.history.entries()) {
+                if (!farmerNames.includes(event.from) || farmerNames.includes(event.to) || !this.isHandover(event.from, event.to) ||
+                    !matchesPeriod(event.timestamp, season)) {
+                    continue;
+                }
+                const settlement = await readDocument<Settlement>(ctx, `settlement_${batch.batchId}_${index}`);
+                const paid = settlement ? settlement.changes.filter(change => change.status === 'paid').pop() : undefined;
+                const commitment = await readDocument<CommercialTermsCommitment>(ctx, `terms_${batch.batchId}_${mspId}`);
+                const line: SettlementLine = {
+                    batchId: batch.batchId,
+                    eventIndex: index,
+                    transferredAt: event.timestamp,
+                    step: event.step,
+                    to: event.to,
+                    cropYear: season ? season.cropYear : undefined,
+                    season: season ? season.season : undefined,
+                    quantityKg: batch.quantityKg,
+                    status: settlement ? settlement.status : 'unsettled',
+                    reference: settlement ? settlement.reference : '',
+                    settledAt: settlement && settlement.status === 'paid' && paid ? paid.recordedAt : undefined,
+                    termsHash: commitment && !commitment.purgedAt ? commitment.termsHash : undefined
+                };
+                if (pricesIncluded && commitment && !commitment.purgedAt) {
+                    const terms = await ctx.stub.getPrivateData(implicitCollectionName(mspId), `terms_${batch.batchId}`);
+                    if (terms && terms.length > 0) {
+                        line.commercialTerms = JSON.parse(Buffer.from(terms).toString('utf8')).terms;
+                    }
+                }
+                lines.push(line);
+            }
+        }
+        lines.sort((a, b) => a.transferredAt.localeCompare(b.transferredAt) || a.batchId.localeCompare(b.batchId));
+
+        const statusCounts: Record<string, number> = {};
+        lines.forEach(line => {
+            statusCounts[line.status] = (statusCounts[line.status] || 0) + 1;
+        });
+        return {
+            farmerId,
+            farmerNames,
+            period: period || '',
+            pricesIncluded,
+            lines,
+            statusCounts,
+            generatedAt: getTxTimestamp(ctx)
+        };
+    }
+
+    /**
+     * A history event handing a batch from one party to another
+     */
+    private isHandover(from: string, to: string): boolean {
+        return !!from && !!to && from !== to;
+    }
+
+    /**
+     * Crop year and season of a batch, derived from its harvest date when not recorded
+     */
+    private cropSeason(batch: RiceBatch): { cropYear: number; season: string } | undefined {
+        if (batch.cropYear && batch.season) {
+            return { cropYear: batch.cropYear, season: batch.season };
+        }
+        return /^\d{4}-\d{2}/.test(batch.harvestDate || '') ? resolveCropSeason(batch.harvestDate, '', '') : undefined;
+    }
+
+    /**
+     * Turn a report period into a filter on the transfer time and the batch's crop season
+     */
+    private parsePeriod(period: string): (transferredAt: string, season?: { cropYear: number; season: string }) => boolean {
+        if (!period) {
+            return () => true;
+        }
+        if (period.includes('/')) {
+            const { from, to } = parseInterval(period, 'period');
+            return transferredAt => transferredAt >= from && transferredAt <= to;
+        }
+        const match = /^(\d{4})(?:-([A-Za-z]+))?$/.exec(period.trim());
+        const seasonName = match && match[2] ? SEASONS.find(name => name.toLowerCase() === match[2].toLowerCase()) : undefined;
+        if (!match || (match[2] && !seasonName)) {
+            throw new Error(`Invalid period ${period}: expected a crop year (2024), a crop season (2024-Late) or an interval (2024-10-01/2024-12-31)`);
+        }
+        const cropYear = Number(match[1]);
+        return (_transferredAt, season) => !!season && season.cropYear === cropYear && (!seasonName || season.season === seasonName);
+    }
+}
@@ -0,0 +1,421 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import {
+    Product, RiceBatch, TraceabilityCriterionScore, TraceabilityScore, TraceabilityScoringCriteria, TraceChainDeficiency,
+    TraceChainValidation
+} from './types';
+import { RiceTracerContract } from './riceTracerContract';
+import { QualityCertificationContract, isPassedTest } from './qualityCertificationContract';
+import { getParticipantsByIdAndName } from './participantRegistryContract';
+import { getBatchWorkflowSteps } from './processingWorkflowContract';
+import { readDocument, writeDocument, getTxTimestamp, checkOrgAdmin } from './utils';
+
+/**
+ * Ledger key of the configured scoring criteria
+ */
+const SCORING_CRITERIA_KEY = 'scoring_traceability';
+
+/**
+ * Criteria used until an administrator configures others: equal weights, a passed moisture test before
+ * packaging, and a temperature log for shipping
+ */
+const DEFAULT_SCORING_CRITERIA: TraceabilityScoringCriteria = {
+    docType: 'traceabilityScoring',
+    plotGeolocationWeight: 25,
+    requiredTestsWeight: 25,
+    requiredTests: { Packaged: ['Moisture'] },
+    custodyChainWeight: 25,
+    coldChainWeight: 25,
+    coldChainSteps: ['Shipped'],
+    version: 0
+};
+
+@Info({ title: 'TraceabilityScoreContract', description: 'Smart contract scoring how completely a batch is documented' })
+export class TraceabilityScoreContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "TraceabilityScoreContract Method Permission Configuration": {
+                "DefineScoringCriteria": ["Organization Administrators"],
+                "GetScoringCriteria": ["All Organizations"],
+                "GetTraceabilityScore": ["All Organizations"],
+                "GetWellDocumentedBatches": ["All Organizations"],
+                "ValidateTraceChain": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Configure the scoring criteria
+     * criteriaJSON: { plotGeolocationWeight, requiredTestsWeight, custodyChainWeight, coldChainWeight,
+     * requiredTests: { <step>: [<test type>, ...] }, coldChainSteps: [<step>, ...] }. Weights are non-negative
+     * numbers, at least one of them positive; a weight of 0 leaves the criterion out of the score
+     * Permission: Only organization administrators can call
+     */
+    @Transaction()
+    public async DefineScoringCriteria(ctx: Context, criteriaJSON: string): Promise<void> {
+        checkOrgAdmin(ctx);
+
+        let input: any;
+        try {
+            input = JSON.parse(criteriaJSON);
+        } catch (error) {
+            throw new Error(`Scoring criteria format error: ${error}`);
+        }
+        if (!input || typeof input !== 'object' || Array.isArray(input)) {
+            throw new Error('Scoring criteria must be an object');
+        }
+
+        const weights = ['plotGeolocationWeight', 'requiredTestsWeight', 'custodyChainWeight', 'coldChainWeight'];
+        for (const weight of weights) {
+            if (typeof input[weight] !== 'number' || !Number.isFinite(input[weight]) || input[weight] < 0) {
+                throw new Error(`${weight} must be a non-negative number`);
+            }
+        }
+        if (weights.every(weight => input[weight] === 0)) {
+            throw new Error('At least one scoring weight must be positive');
+        }
+
+        const requiredTests = input.requiredTests === undefined ? {} : input.requiredTests;
+        if (!requiredTests || typeof requiredTests !== 'object' || Array.isArray(requiredTests) ||
+            Object.values(requiredTests).some(tests => !this.isStringList(tests))) {
+            throw new Error('requiredTests must map step names to lists of test types');
+        }
+        const coldChainSteps = input.coldChainSteps === undefined ? [] : input.coldChainSteps;
+        if (!this.isStringList(coldChainSteps)) {
+            throw new Error('coldChainSteps must be a list of step names');
+        }
+
+        const existing = await readDocument<TraceabilityScoringCriteria>(ctx, SCORING_CRITERIA_KEY);
+        const criteria: TraceabilityScoringCriteria = {
+            docType: 'traceabilityScoring',
+            plotGeolocationWeight: input.plotGeolocationWeight,
+            requiredTestsWeight: input.requiredTestsWeight,
+            requiredTests,
+            custodyChainWeight: input.custodyChainWeight,
+            coldChainWeight: input.coldChainWeight,
+            coldChainSteps,
+            version: existing ? existing.version + 1 : 1,
+            definedBy: ctx.clientIdentity.getMSPID(),
+            lastUpdated: getTxTimestamp(ctx)
+        };
+
+        await writeDocument(ctx, SCORING_CRITERIA_KEY, criteria);
+    }
+
+    /**
+     * Get the scoring criteria in force (the built-in defaults, version 0, until configured)
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('TraceabilityScoringCriteria')
+    public async GetScoringCriteria(ctx: Context): Promise<TraceabilityScoringCriteria> {
+        return (await readDocument<TraceabilityScoringCriteria>(ctx, SCORING_CRITERIA_KEY)) || DEFAULT_SCORING_CRITERIA;
+    }
+
+    /**
+     * Score how completely a batch is documented, 0-100, with the contribution of each criterion
+     * Criteria that do not apply yet (no tests required for the steps reached, no cold-chain step reached)
+     * count as met
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('TraceabilityScore')
+    public async GetTraceabilityScore(ctx: Context, batchId: string): Promise<TraceabilityScore> {
+        const batch = await new RiceTracerContract().ReadRiceBatch(ctx, batchId);
+        return this.scoreBatch(ctx, batch, await this.GetScoringCriteria(ctx));
+    }
+
+    /**
+     * Get the scores of all batches scoring at least minScore, best documented first
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('TraceabilityScore[]')
+    public async GetWellDocumentedBatches(ctx: Context, minScore: string): Promise<TraceabilityScore[]> {
+        const threshold = minScore === '' ? 0 : Number(minScore);
+        if (!Number.isFinite(threshold) || threshold < 0 || threshold > 100) {
+            throw new Error(`Invalid minimum score ${minScore}: must be a number between 0 and 100`);
+        }
+
+        const criteria = await this.GetScoringCriteria(ctx);
+        const scores: TraceabilityScore[] = [];
+        for (const batch of await new RiceTracerContract().GetAllRiceBatches(ctx)) {
+            const score = await this.scoreBatch(ctx, batch, criteria);
+            if (score.score >= threshold) {
+                scores.push(score);
+            }
+        }
+        return scores.sort((a, b) => b.score - a.score || a.batchId.localeCompare(b.batchId));
+    }
+
+    /**
+     * Check the trace chain of a product, from the registration of its batch to its current owner, for gaps QA
+     * teams have to close, and list every deficiency found:
+     * - custodyLink: an unsigned batch event, a handover that does not continue from the previous owner, a product
+     *   packaged by someone who never held the batch, or a product transfer that does not continue the chain
+     * - stepOrder: an event dated before the previous one, a step out of its workflow's order or a required
+     *   workflow step skipped, or a product packaged before its batch was registered
+     * - missingTest: a step reached without a passed test its scoring criteria or workflow require, dated no later
+     *   than the step
+     * - unregisteredOperator: an operator or owner that is not a registered participant (by ID or name)
+     * Corrected history events are checked as corrected
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('TraceChainValidation')
+    public async ValidateTraceChain(ctx: Context, productId: string): Promise<TraceChainValidation> {
+        const product = await readDocument<Product>(ctx, `product_${productId}`);
+        if (!product) {
+            throw new Error(`Product ${productId} does not exist`);
+        }
+        const batch = await new RiceTracerContract().ReadRiceBatch(ctx, product.batchId);
+        const criteria = await this.GetScoringCriteria(ctx);
+        const workflowSteps = await getBatchWorkflowSteps(ctx, batch);
+        const deficiencies: TraceChainDeficiency[] = [];
+
+        const corrections = batch.corrections || [];
+        const history = batch.history.map(event => {
+            const correction = event.supersededBy ? corrections.find(candidate => candidate.correctionId === event.supersededBy) : undefined;
+            return correction ? { ...event, step: correction.step, report: correction.report } : event;
+        });
+        if (history.length === 0) {
+            deficiencies.push({ rule: 'custodyLink', entityId: batch.batchId, message: `Batch ${batch.batchId} has no recorded history` });
+        }
+
+        // Batch custody and chronology
+        history.forEach((event, index) => {
+            if (!event.signerMspId) {
+                deficiencies.push({ rule: 'custodyLink', entityId: batch.batchId, index, step: event.step, message: `Event ${index} (${event.step}) is not signed by an organization` });
+            }
+            const previous = history[index - 1];
+            if (previous && event.from !== previous.to) {
+                deficiencies.push({
+                    rule: 'custodyLink', entityId: batch.batchId, index, step: event.step,
+                    message: `Event ${index} (${event.step}) hands the batch over from ${event.from || 'nobody'}, but event ${index - 1} left it with ${previous.to}`
+                });
+            }
+            if (previous && event.timestamp < previous.timestamp) {
+                deficiencies.push({
+                    rule: 'stepOrder', entityId: batch.batchId, index, step: event.step,
+                    message: `Event ${index} (${event.step}) at ${event.timestamp} is dated before event ${index - 1} (${previous.step}) at ${previous.timestamp}`
+                });
+            }
+        });
+
+        // Workflow order: steps outside the workflow (e.g. Disposed) are not ordered
+        if (workflowSteps) {
+            const stepNames = workflowSteps.map(workflowStep => workflowStep.name);
+            let furthest = -1;
+            history.forEach((event, index) => {
+                const position = stepNames.indexOf(event.step);
+                if (position === -1) {
+                    return;
+                }
+                if (position <= furthest) {
+                    deficiencies.push({
+                        rule: 'stepOrder', entityId: batch.batchId, index, step: event.step,
+                        message: `Event ${index} (${event.step}) comes after ${stepNames[furthest]} in workflow ${batch.workflowId}`
+                    });
+                    return;
+                }
+                const skipped = workflowSteps.slice(furthest + 1, position).filter(workflowStep => !workflowStep.optional);
+                if (skipped.length > 0) {
+                    deficiencies.push({
+                        rule: 'stepOrder', entityId: batch.batchId, index, step: event.step,
+                        message: `Event ${index} (${event.step}) skips required step(s) ${skipped.map(workflowStep => workflowStep.name).join(', ')} of workflow ${batch.workflowId}`
+                    });
+                }
+                furthest = position;
+            });
+        }
+
+        // Tests required at the checkpoints reached
+        const tests = await new QualityCertificationContract().GetTestResultsByBatch(ctx, batch.batchId);
+        const checked = new Set<string>();
+        history.forEach((event, index) => {
+            if (checked.has(event.step)) {
+                return;
+            }
+            checked.add(event.step);
+            const workflowStep = workflowSteps ? workflowSteps.find(candidate => candidate.name === event.step) : undefined;
+            const required = new Set([...(criteria.requiredTests[event.step] || []), ...(workflowStep ? workflowStep.requiredTests || [] : [])]);
+            for (const testType of required) {
+                const passed = tests.some(test => test.testType === testType && isPassedTest(test) && Date.parse(test.testDate) <= Date.parse(event.timestamp));
+                if (!passed) {
+                    deficiencies.push({
+                        rule: 'missingTest', entityId: batch.batchId, index, step: event.step,
+                        message: `No passed ${testType} test dated before ${event.step} (${event.timestamp})`
+                    });
+                }
+            }
+        });
+
+        // Product custody: packaged by a holder of the batch, then handed on without gaps
+        const transfers = product.transfers || [];
+        const packager = transfers.length > 0 ? transfers[0].from : product.owner;
+        if (!history.some(event => event.to === packager)) {
+            deficiencies.push({
+                rule: 'custodyLink', entityId: productId,
+                message: `Product ${productId} was packaged by ${packager}, who never held batch ${batch.batchId}`
+            });
+        }
+        if (history.length > 0 && product.packageDate && Date.parse(product.packageDate) < Date.parse(history[0].timestamp)) {
+            deficiencies.push({
+                rule: 'stepOrder', entityId: productId,
+                message: `Product ${productId} was packaged on ${product.packageDate}, before batch ${batch.batchId} was registered (${history[0].timestamp})`
+            });
+        }
+        transfers.forEach((transfer, index) => {
+            const previous = transfers[index - 1];
+            if (previous && transfer.from !== previous.to) {
+                deficiencies.push({
+                    rule: 'custodyLink', entityId: productId, index,
+                    message: `Transfer ${index} (${transfer.type}) starts from ${transfer.from}, but transfer ${index - 1} went to ${previous.to}`
+                });
+            }
+        });
+        const lastHolder = transfers.length > 0 ? transfers[transfers.length - 1].to : product.owner;
+        if (lastHolder !== product.owner) {
+            deficiencies.push({
+                rule: 'custodyLink', entityId: productId,
+                message: `Product ${productId} is owned by ${product.owner}, but its last transfer went to ${lastHolder}`
+            });
+        }
+
+        // Operators and owners must be registered participants
+        const participants = await getParticipantsByIdAndName(ctx);
+        const operators = new Map<string, TraceChainDeficiency>();
+        const addOperator = (operator: string, location: Omit<TraceChainDeficiency, 'rule' | 'message'>) => {
+            if (operator && !participants.has(operator) && !operators.has(operator)) {
+                operators.set(operator, { rule: 'unregisteredOperator', ...location, message: `${operator} is not a registered participant` });
+            }
+        };
+        history.forEach((event, index) => {
+            addOperator(event.from, { entityId: batch.batchId, index, step: event.step });
+            addOperator(event.to, { entityId: batch.batchId, index, step: event.step });
+        });
+        transfers.forEach((transfer, index) => {
+            addOperator(transfer.from, { entityId: productId, index });
+            addOperator(transfer.to, { entityId: productId, index });
+        });
+        addOperator(product.owner, { entityId: productId });
+        deficiencies.push(...operators.values());
+
+        return {
+            productId,
+            batchId: batch.batchId,
+            valid: deficiencies.length === 0,
+            deficiencies,
+            criteriaVersion: criteria.version
+        };
+    }
+
+    /**
+     * Evaluate every weighted criterion for a batch and combine them into a 0-100 score
+     */
+    private async scoreBatch(ctx: Context, batch: RiceBatch, criteria: TraceabilityScoringCriteria): Promise<TraceabilityScore> {
+        const history = batch.history || [];
+        const results: TraceabilityCriterionScore[] = [];
+
+        if (criteria.plotGeolocationWeight > 0) {
+            const located = history.find(event => event.report && event.report.geolocation);
+            results.push({
+                criterion: 'plotGeolocation',
+                weight: criteria.plotGeolocationWeight,
+                fulfilment: located ? 1 : 0,
+                detail: located ? `Geolocation recorded at ${located.step}` : 'No step report carries a geolocation'
+            });
+        }
+
+        if (criteria.requiredTestsWeight > 0) {
+            results.push({ criterion: 'requiredTests', weight: criteria.requiredTestsWeight, ...await this.scoreRequiredTests(ctx, batch, criteria) });
+        }
+
+        if (criteria.custodyChainWeight > 0) {
+            const broken = history
+                .map((event, index) => ({ event, index }))
+                .filter(({ event, index }) => !event.signerMspId || (index > 0 && event.from !== history[index - 1].to));
+            results.push({
+                criterion: 'custodyChain',
+                weight: criteria.custodyChainWeight,
+                fulfilment: history.length === 0 ? 0 : (history.length - broken.length) / history.length,
+                detail: history.length === 0 ? 'No history recorded'
+                    : broken.length === 0 ? `All ${history.length} events are signed and continue from the previous owner`
+                        : `Unsigned or discontinuous events: ${broken.map(({ event }) => event.step).join(', ')}`
+            });
+        }
+
+        if (criteria.coldChainWeight > 0) {
+            const steps = history.filter(event => criteria.coldChainSteps.includes(event.step));
+            const missing = steps.filter(event => !event.report || !event.report.temperatureLogHash);
+            results.push({
+                criterion: 'coldChain',
+                weight: criteria.coldChainWeight,
+                fulfilment: steps.length === 0 ? 1 : (steps.length - missing.length) / steps.length,
+                detail: steps.length === 0 ? 'No cold-chain step reached yet'
+                    : missing.length === 0 ? `Temperature logs recorded for ${steps.length} step(s)`
+                        : `No temperature log for: ${missing.map(event => event.step).join(', ')}`
+            });
+        }
+
+        const totalWeight = results.reduce((sum, result) => sum + result.weight, 0);
+        const earned = results.reduce((sum, result) => sum + result.weight * result.fulfilment, 0);
+        return {
+            batchId: batch.batchId,
+            score: Math.round(100 * earned / totalWeight),
+            criteria: results,
+            criteriaVersion: criteria.version
+        };
+    }
+
+    /**
+     * Share of the tests required for the steps the batch reached (configured, plus its workflow's) that passed
+     */
+    private async scoreRequiredTests(
+        ctx: Context,
+        batch: RiceBatch,
+        criteria: TraceabilityScoringCriteria
+    ): Promise<{ fulfilment: number; detail: string }> {
+        const workflowSteps = await getBatchWorkflowSteps(ctx, batch);
+        const required: string[] = [];
+        for (const step of new Set((batch.history || []).map(event => event.step))) {
+            const workflowStep = workflowSteps ? workflowSteps.find(candidate => candidate.name === step) : undefined;
+            for (const testType of [...(criteria.requiredTests[step] || []), ...(workflowStep ? workflowStep.requiredTests || [] : [])]) {
+                if (!required.includes(testType)) {
+                    required.push(testType);
+                }
+            }
+        }
+        if (required.length === 0) {
+            return { fulfilment: 1, detail: 'No tests required for the steps reached' };
+        }
+
+        const tests = await new QualityCertificationContract().GetTestResultsByBatch(ctx, batch.batchId);
+        const missing = required.filter(testType =>
+            !tests.some(test => test.testType === testType && isPassedTest(test))
+        );
+        return {
+            fulfilment: (required.length - missing.length) / required.length,
+            detail: missing.length === 0 ? `Passed: ${required.join(', ')}` : `Missing passed test(s): ${missing.join(', ')}`
+        };
+    }
+
+    /**
+     * Whether a value is a list of non-empty strings
+     */
+    private isStringList(value: unknown): boolean {
+        return Array.isArray(value) && value.every(item => typeof item === 'string' && item !== '');
+    }
+}
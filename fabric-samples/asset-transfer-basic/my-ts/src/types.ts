@@ -28,6 +28,24 @@ export class OrganizationInfo {
     public orgName: string = '';
 }
 
+/**
+ * Deployed contract version and build information
+ */
+@Object()
+export class ContractVersion {
+    @Property()
+    public contractVersion: string = ''; // Semantic version of the chaincode package
+
+    @Property()
+    public buildCommit: string = ''; // Source revision the chaincode was built from, if known
+
+    @Property()
+    public buildTime: string = ''; // Build time (ISO 8601), if known
+
+    @Property()
+    public nodeVersion: string = ''; // Node.js runtime of the chaincode process
+}
+
 
 
 /**
@@ -58,6 +76,72 @@ export class ReportDetail {
 
     @Property()
     public notes?: string;
+
+    @Property()
+    public destinationCountry?: string; // ISO country code of the shipment destination, for Shipped steps
+
+    @Property()
+    public equipmentId?: string; // Registered equipment the step was processed on (dryer, mill, ...)
+
+    @Property('geolocation', 'GeoLocation')
+    public geolocation?: GeoLocation; // Where the step took place, e.g. the plot of a harvest record
+
+    @Property()
+    public temperatureLogHash?: string; // SHA-256 of the cold-chain temperature logger data covering the step
+
+    @Property('inputs', 'AgroInputApplication[]')
+    public inputs?: AgroInputApplication[]; // Agro-chemicals applied to the rice, e.g. pesticides listed in a harvest log
+
+    @Property()
+    public facilityId?: string; // Registered facility (site) the step took place at
+
+    @Property()
+    public line?: string; // Processing line of the facility, e.g. "Line 2"
+
+    @Property()
+    public shift?: string; // Operator shift of the facility, e.g. "Night"
+
+    @Property('ambient', 'AmbientConditions')
+    public ambient?: AmbientConditions; // Conditions at the facility while the step ran
+}
+
+/**
+ * Ambient conditions measured at a facility during a processing step
+ */
+@Object()
+export class AmbientConditions {
+    @Property()
+    public temperatureC?: number;
+
+    @Property()
+    public humidityPercent?: number; // Relative humidity
+}
+
+/**
+ * Application of an agro-chemical lot (pesticide, fungicide, fertilizer, ...) to the rice of a batch
+ */
+@Object()
+export class AgroInputApplication {
+    @Property()
+    public chemicalName: string = ''; // Product or active ingredient name, e.g. Chlorpyrifos
+
+    @Property()
+    public inputLotId?: string; // Manufacturer lot of the applied product
+
+    @Property()
+    public appliedAt?: string; // Date of the field application; defaults to the time of the step
+}
+
+/**
+ * WGS 84 coordinates in decimal degrees
+ */
+@Object()
+export class GeoLocation {
+    @Property()
+    public latitude: number = 0;
+
+    @Property()
+    public longitude: number = 0;
 }
 
 /**
@@ -79,6 +163,24 @@ export class HistoryEvent {
 
     @Property('report', 'ReportDetail')
     public report: ReportDetail = new ReportDetail();
+
+    @Property()
+    public signerMspId?: string; // MSP ID of the invoking identity
+
+    @Property()
+    public signerFingerprint?: string; // SHA-256 fingerprint of the invoker's X.509 certificate
+
+    @Property()
+    public delegationId?: string; // Set when the invoker acted as a delegate under this delegation
+
+    @Property()
+    public onBehalfOfMspId?: string; // Organization of the identity that granted the delegation
+
+    @Property()
+    public onBehalfOfFingerprint?: string; // Certificate fingerprint of the identity that granted the delegation
+
+    @Property()
+    public supersededBy?: string; // ID of the correction replacing the step and report of this record
 }
 
 /**
@@ -149,6 +251,108 @@ export class TestResult {
 
     @Property()
     public certificationNumber?: string;
+
+    @Property()
+    public signerMspId?: string; // MSP ID of the invoking identity
+
+    @Property()
+    public signerFingerprint?: string; // SHA-256 fingerprint of the invoker's X.509 certificate
+
+    @Property()
+    public sampleId?: string; // Registered physical sample the test was performed on
+
+    @Property()
+    public revoked?: boolean; // Withdrawn by the tester; a revoked result no longer counts for any rule or statistic
+
+    @Property()
+    public revocationReason?: string;
+
+    @Property()
+    public revokedAt?: string;
+
+    @Property()
+    public residues?: Record<string, number>; // Measured residue levels in mg/kg by substance, e.g. { "chlorpyrifos": 0.005 }
+
+    @Property()
+    public moisturePercent?: number; // Measured moisture content of a moisture test, in percent of the sample weight
+
+    @Property()
+    public reportDetailsHash?: string; // SHA-256 (hex) of the full report held in a testReports collection
+
+    @Property()
+    public reportDetailsCollection?: string; // testReports collection of the lab and the party the test was made for
+
+    @Property()
+    public reportDetailsAt?: string;
+
+    @Property()
+    public reportDetailsPurgedAt?: string; // The details were purged; the hash remains
+
+    @Property()
+    public tenantId?: string; // Tenant of the source batch; hidden from identities of other tenants
+}
+
+/**
+ * Full test report shared privately by the lab and the party the test was made for
+ */
+@Object()
+export class TestReportDetails {
+    @Property()
+    public docType: string = 'testReportDetails';
+
+    @Property()
+    public testId: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public mspId: string = ''; // Organization of the lab
+
+    @Property()
+    public counterpartyMspId: string = '';
+
+    @Property()
+    public details: string = ''; // Free text or JSON, as submitted
+
+    @Property()
+    public recordedAt: string = '';
+
+    @Property()
+    public txId: string = '';
+}
+
+/**
+ * Physical sample drawn from a batch for laboratory testing
+ */
+@Object()
+export class Sample {
+    @Property()
+    public docType: string = 'sample';
+
+    @Property()
+    public sampleId: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public quantity: string = ''; // Amount drawn, e.g. "500g"
+
+    @Property()
+    public sampledBy: string = '';
+
+    @Property()
+    public location: string = ''; // Where the sample was taken, e.g. warehouse or silo
+
+    @Property()
+    public sampledTimestamp: string = '';
+
+    @Property()
+    public recordedBy: string = ''; // MSP ID of the recording organization
+
+    @Property()
+    public tenantId?: string; // Tenant of the source batch; hidden from identities of other tenants
 }
 
 /**
@@ -171,6 +375,12 @@ export class RiceBatch {
     @Property()
     public harvestDate: string = '';
 
+    @Property()
+    public cropYear?: number; // Crop year the rice was planted in; late-season harvests may fall in the next calendar year
+
+    @Property()
+    public season?: string; // Early, Middle or Late, consistent with harvestDate
+
     @Property()
     public currentOwner: string = '';
 
@@ -179,79 +389,3426 @@ export class RiceBatch {
 
     @Property('history', 'HistoryEvent[]')
     public history: HistoryEvent[] = [];
+
+    @Property()
+    public workflowId?: string; // Processing workflow the batch must follow, if any
+
+    @Property()
+    public workflowVersion?: number; // Version of the workflow when the batch was created
+
+    @Property('workflowSteps', 'WorkflowStep[]')
+    public workflowSteps?: WorkflowStep[]; // Steps of the workflow when the batch was created; later redefinitions do not apply
+
+    @Property()
+    public quarantined?: boolean; // Quarantined batches cannot be shipped
+
+    @Property()
+    public quarantineReason?: string;
+
+    @Property()
+    public quarantinedAt?: string;
+
+    @Property()
+    public quarantinedBy?: string; // MSP ID of the organization that placed the quarantine
+
+    @Property('disposal', 'Disposal')
+    public disposal?: Disposal; // Set when the batch has been disposed of (terminal state)
+
+    @Property()
+    public status?: string; // Derived by the contract: Active, Quarantined, Recalled, Finalized, Archived or Disposed
+
+    @Property('foreignReferences', 'ForeignBatchReference[]')
+    public foreignReferences?: ForeignBatchReference[]; // Source batches committed on other channels (regional networks)
+
+    @Property('insurancePolicies', 'InsurancePolicy[]')
+    public insurancePolicies?: InsurancePolicy[]; // Crop, storage or transport insurance covering the batch
+
+    @Property('insuranceClaims', 'InsuranceClaim[]')
+    public insuranceClaims?: InsuranceClaim[];
+
+    @Property()
+    public labels?: Record<string, string>; // Deployment-specific metadata, e.g. { "export-market": "JP" }
+
+    @Property()
+    public tenantId?: string; // Tenant (e.g. cooperative ID) of the registering farm; hidden from identities of other tenants
+
+    @Property('delegations', 'Delegation[]')
+    public delegations?: Delegation[]; // Identities allowed to act on the batch on behalf of the registering farmer
+
+    @Property('giCompliance', 'GIComplianceCheck')
+    public giCompliance?: GIComplianceCheck; // Latest geographic indication check; the GI claim is shown only when it passed
+
+    @Property()
+    public archivedHistorySegments?: number; // Continuation keys holding the oldest history events once the stored history reached its cap
+
+    @Property()
+    public archivedHistoryEvents?: number; // Events held in continuation keys; history indexes include them
+
+    @Property()
+    public quantityKg?: number; // Quantity of rice in the batch, declared by the owning organization
+
+    @Property('reservations', 'BatchReservation[]')
+    public reservations?: BatchReservation[]; // Quantity committed to pending sales
+
+    @Property('scheduledTransfers', 'ScheduledTransfer[]')
+    public scheduledTransfers?: ScheduledTransfer[]; // Handovers agreed for a future date
+
+    @Property('corrections', 'ProcessingRecordCorrection[]')
+    public corrections?: ProcessingRecordCorrection[]; // Corrections of mistyped history records, oldest first
+
+    @Property('weightAdjustments', 'WeightAdjustment[]')
+    public weightAdjustments?: WeightAdjustment[]; // Explained weight losses of processing steps, oldest first
 }
 
 /**
- * Product structure
+ * Power of attorney over a batch: a delegate identity (e.g. a cooperative or broker) may record steps on behalf
+ * of the identity that granted it, until it expires or is revoked
  */
 @Object()
-export class Product {
+export class Delegation {
     @Property()
-    public docType: string = 'product'; // Fixed value "product"
+    public delegationId: string = ''; // ID of the granting transaction
 
     @Property()
-    public productId: string = '';
+    public delegateMspId: string = '';
 
     @Property()
-    public batchId: string = '';
+    public delegateFingerprint: string = ''; // SHA-256 fingerprint of the delegate's X.509 certificate
+
+    @Property('permissions', 'string[]')
+    public permissions: string[] = []; // transfer (hand the batch to another owner) and/or process (steps without handover)
 
     @Property()
-    public packageDate: string = '';
+    public grantedByMspId: string = '';
 
     @Property()
-    public owner: string = '';
+    public grantedByFingerprint: string = '';
+
+    @Property()
+    public grantedAt: string = '';
+
+    @Property()
+    public expiresAt: string = '';
+
+    @Property()
+    public revokedAt?: string;
 }
 
 /**
- * Quality certificate structure
+ * Insurance policy attached to a batch; the policy document itself stays off-chain
  */
 @Object()
-export class QualityCertificate {
+export class InsurancePolicy {
     @Property()
-    public docType: string = 'qualityCertificate';
+    public insurer: string = '';
 
     @Property()
-    public certificateId: string = '';
+    public policyNumber: string = '';
 
     @Property()
-    public batchId: string = '';
+    public coverage: string = ''; // crop, storage or transport
 
-    @Property('testIds', 'string[]')
-    public testIds: string[] = [];
+    @Property()
+    public validFrom: string = '';
 
     @Property()
-    public certificateType: string = '';
+    public validTo: string = '';
 
     @Property()
-    public issueDate: string = '';
+    public documentHash: string = ''; // SHA-256 (hex) of the policy document
 
     @Property()
-    public issuer: string = '';
+    public attachedAt: string = '';
 
     @Property()
-    public validityPeriod: string = '';
+    public attachedBy: string = ''; // MSP ID of the policyholder organization
+}
 
+/**
+ * On-chain record cited as evidence for an insurance claim
+ */
+@Object()
+export class InsuranceEvidence {
     @Property()
-    public standards: string = '';
+    public type: string = ''; // testResult, certificate, sample, shipment, historyEvent, weather or document
 
     @Property()
-    public isActive: boolean = true;
+    public reference: string = ''; // Test, certificate, sample or shipment ID, history index, weather data hash or document SHA-256
 
     @Property()
-    public createdTimestamp: string = '';
+    public description?: string; // e.g. "Cold-chain logger export, truck 12"
+}
+
+/**
+ * Insurance claim filed against a policy attached to a batch
+ */
+@Object()
+export class InsuranceClaim {
+    @Property()
+    public claimId: string = '';
 
     @Property()
-    public lastUpdated: string = '';
+    public insurer: string = '';
+
+    @Property()
+    public policyNumber: string = '';
+
+    @Property()
+    public incidentDate: string = '';
+
+    @Property()
+    public description: string = '';
+
+    @Property('evidence', 'InsuranceEvidence[]')
+    public evidence: InsuranceEvidence[] = [];
+
+    @Property()
+    public filedAt: string = '';
+
+    @Property()
+    public filedBy: string = ''; // MSP ID of the claiming organization
 }
 
 /**
- * Combined query result of product and batch
+ * Provenance of a batch on another channel, copied when the reference is linked
  */
 @Object()
-export class ProductWithBatch {
-    @Property('product', 'Product')
-    public product: Product = new Product();
+export class ForeignProvenanceSummary {
+    @Property()
+    public origin: string = '';
 
-    @Property('batch', 'RiceBatch')
-    public batch: RiceBatch = new RiceBatch();
-} 
\ No newline at end of file
+    @Property()
+    public variety: string = '';
+
+    @Property()
+    public harvestDate: string = '';
+
+    @Property()
+    public currentOwner: string = '';
+
+    @Property()
+    public currentState: string = '';
+
+    @Property()
+    public historyLength: number = 0;
+}
+
+/**
+ * Reference to a batch committed on another channel, e.g. when rice moves between regional networks
+ */
+@Object()
+export class ForeignBatchReference {
+    @Property()
+    public channel: string = '';
+
+    @Property()
+    public chaincodeName: string = '';
+
+    @Property()
+    public batchId: string = ''; // Batch ID on the foreign channel
+
+    @Property()
+    public stateHash: string = ''; // SHA-256 (hex) of the foreign batch document when linked
+
+    @Property('summary', 'ForeignProvenanceSummary')
+    public summary: ForeignProvenanceSummary = new ForeignProvenanceSummary();
+
+    @Property()
+    public linkedAt: string = '';
+
+    @Property()
+    public linkedBy: string = ''; // MSP ID of the linking organization
+}
+
+/**
+ * State hash of a batch, as cited by a foreign batch reference on another channel
+ */
+@Object()
+export class BatchStateHash {
+    @Property()
+    public channel: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public stateHash: string = ''; // SHA-256 (hex) of the canonical batch document
+}
+
+/**
+ * Batch, product or foreign batch in a genealogy graph
+ */
+@Object()
+export class GenealogyNode {
+    @Property()
+    public id: string = ''; // Batch or product ID; "<channel>/<batchId>" for a batch on another channel
+
+    @Property()
+    public type: string = ''; // batch, product or foreignBatch
+
+    @Property()
+    public generation: number = 0; // Hops from the queried batch: negative for ancestors, positive for descendants
+
+    @Property()
+    public owner?: string;
+
+    @Property()
+    public state?: string; // Current state of a batch or status of a product
+}
+
+/**
+ * Derivation of one genealogy node from another
+ */
+@Object()
+export class GenealogyEdge {
+    @Property()
+    public from: string = ''; // Node ID of the source
+
+    @Property()
+    public to: string = ''; // Node ID of the derived node
+
+    @Property()
+    public operation: string = ''; // packaging (batch into product) or link (batch continued from another channel)
+
+    @Property()
+    public at?: string;
+}
+
+/**
+ * Ancestor/descendant graph of a batch, e.g. to find every product a recall reaches
+ */
+@Object()
+export class BatchGenealogy {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public depth: number = 0;
+
+    @Property('nodes', 'GenealogyNode[]')
+    public nodes: GenealogyNode[] = [];
+
+    @Property('edges', 'GenealogyEdge[]')
+    public edges: GenealogyEdge[] = [];
+
+    @Property()
+    public truncated: boolean = false; // Whether the graph continues beyond depth
+}
+
+/**
+ * Field that differs between two versions of a document
+ */
+@Object()
+export class FieldChange {
+    @Property()
+    public path: string = ''; // e.g. currentOwner, history[3], report.summary
+
+    @Property()
+    public change: string = ''; // added, removed or modified
+
+    @Property()
+    public before?: string; // JSON of the old value; absent when added
+
+    @Property()
+    public after?: string; // JSON of the new value; absent when removed
+}
+
+/**
+ * Differences between two committed versions of a batch
+ */
+@Object()
+export class BatchHistoryDiff {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public fromTxId: string = '';
+
+    @Property()
+    public fromTimestamp: string = '';
+
+    @Property()
+    public toTxId: string = '';
+
+    @Property()
+    public toTimestamp: string = '';
+
+    @Property('changes', 'FieldChange[]')
+    public changes: FieldChange[] = [];
+}
+
+/**
+ * Result of re-reading a referenced batch from its channel
+ */
+@Object()
+export class ForeignReferenceVerification {
+    @Property()
+    public channel: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public recordedStateHash: string = '';
+
+    @Property()
+    public currentStateHash: string = '';
+
+    @Property()
+    public matches: boolean = false; // False when the foreign batch changed since it was linked
+
+    @Property('summary', 'ForeignProvenanceSummary')
+    public summary: ForeignProvenanceSummary = new ForeignProvenanceSummary(); // Current provenance on the foreign channel
+}
+
+/**
+ * End-of-life disposition of a batch or product
+ */
+@Object()
+export class Disposal {
+    @Property()
+    public reason: string = ''; // e.g. Spoiled, Recalled, Pest damage
+
+    @Property()
+    public method: string = ''; // e.g. Incineration, Animal feed, Composting
+
+    @Property()
+    public quantity: string = ''; // Amount disposed, e.g. "1200kg"
+
+    @Property()
+    public handler: string = ''; // Party that carried out the disposal
+
+    @Property()
+    public timestamp: string = '';
+
+    @Property()
+    public recordedBy: string = ''; // MSP ID of the recording organization
+}
+
+/**
+ * Product ownership movement - sale to the next owner or return to the previous one
+ */
+@Object()
+export class ProductTransfer {
+    @Property()
+    public timestamp: string = '';
+
+    @Property()
+    public from: string = '';
+
+    @Property()
+    public to: string = '';
+
+    @Property()
+    public type: string = ''; // Sale or Return
+
+    @Property()
+    public reason?: string; // Documented reason for returns
+
+    @Property()
+    public fromMspId?: string; // Owning organization before the transfer
+
+    @Property()
+    public toMspId?: string; // Owning organization after the transfer
+
+    @Property()
+    public signerFingerprint?: string; // SHA-256 fingerprint of the invoker's X.509 certificate
+}
+
+/**
+ * Re-inspection of a returned product by its owning organization
+ */
+@Object()
+export class ProductReinspection {
+    @Property()
+    public timestamp: string = '';
+
+    @Property()
+    public outcome: string = ''; // Passed (the product can be resold) or Failed
+
+    @Property()
+    public notes?: string;
+
+    @Property()
+    public inspectedByMspId: string = '';
+
+    @Property()
+    public inspectedBySubject: string = ''; // Subject DN of the inspecting identity's certificate
+}
+
+/**
+ * Product structure
+ */
+@Object()
+export class Product {
+    @Property()
+    public docType: string = 'product'; // Fixed value "product"
+
+    @Property()
+    public productId: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public packageDate: string = '';
+
+    @Property()
+    public owner: string = '';
+
+    @Property()
+    public ownerMspId?: string; // Organization holding the product; must endorse updates to it
+
+    @Property()
+    public originMspId?: string; // Organization that registered the source batch
+
+    @Property()
+    public packerMspId?: string; // Organization that packaged the product; its branding is shown to consumers
+
+    @Property()
+    public status?: string; // Active, Sold, Returned or Disposed
+
+    @Property('transfers', 'ProductTransfer[]')
+    public transfers?: ProductTransfer[];
+
+    @Property()
+    public requiresReinspection?: boolean; // Returned products flagged for re-inspection cannot be resold until cleared
+
+    @Property('reinspections', 'ProductReinspection[]')
+    public reinspections?: ProductReinspection[];
+
+    @Property('disposal', 'Disposal')
+    public disposal?: Disposal; // Set when the product has been disposed of (terminal state)
+
+    @Property('nutrition', 'NutritionFacts')
+    public nutrition?: NutritionFacts; // Label nutrition facts, per 100 g
+
+    @Property('composition', 'ProductComposition')
+    public composition?: ProductComposition;
+
+    @Property()
+    public labels?: Record<string, string>; // Deployment-specific metadata, e.g. { "coop-id": "HLJ-017" }
+
+    @Property()
+    public tenantId?: string; // Tenant of the source batch; hidden from identities of other tenants
+}
+
+/**
+ * Nutrition facts of a packaged product, per 100 g (GB 28050 style label)
+ */
+@Object()
+export class NutritionFacts {
+    @Property()
+    public energyKj: number = 0;
+
+    @Property()
+    public proteinG: number = 0;
+
+    @Property()
+    public carbohydrateG: number = 0;
+
+    @Property()
+    public fatG?: number;
+
+    @Property()
+    public fiberG?: number;
+
+    @Property()
+    public sodiumMg?: number;
+}
+
+/**
+ * Composition metadata of a packaged product
+ */
+@Object()
+export class ProductComposition {
+    @Property('ingredients', 'string[]')
+    public ingredients: string[] = []; // e.g. ["Japonica rice (100%)"]
+
+    @Property('allergens', 'string[]')
+    public allergens?: string[];
+
+    @Property()
+    public netWeightG?: number;
+
+    @Property()
+    public grade?: string; // e.g. "Grade 1 (GB/T 1354)"
+
+    @Property()
+    public bestBefore?: string; // ISO 8601 end of the best-before day
+}
+
+/**
+ * One page of batches returned by a paginated query
+ */
+@Object()
+export class BatchQueryResult {
+    @Property('batches', 'RiceBatch[]')
+    public batches: RiceBatch[] = [];
+
+    @Property()
+    public fetchedRecordsCount: number = 0;
+
+    @Property()
+    public bookmark: string = ''; // Pass back to fetch the next page; empty when there are no more results
+}
+
+/**
+ * One page of products returned by a paginated query
+ */
+@Object()
+export class ProductQueryResult {
+    @Property('products', 'Product[]')
+    public products: Product[] = [];
+
+    @Property()
+    public fetchedRecordsCount: number = 0;
+
+    @Property()
+    public bookmark: string = ''; // Pass back to fetch the next page; empty when there are no more results
+}
+
+/**
+ * One page of results of a named query; only the list matching the query's record type is set
+ */
+@Object()
+export class NamedQueryResult {
+    @Property()
+    public name: string = '';
+
+    @Property('batches', 'RiceBatch[]')
+    public batches?: RiceBatch[];
+
+    @Property('testResults', 'TestResult[]')
+    public testResults?: TestResult[];
+
+    @Property('products', 'Product[]')
+    public products?: Product[];
+
+    @Property()
+    public fetchedRecordsCount: number = 0;
+
+    @Property()
+    public bookmark: string = ''; // Pass back to fetch the next page; empty when there are no more results
+}
+
+/**
+ * A query in the named query catalog and the parameters it takes
+ */
+@Object()
+export class NamedQueryDefinition {
+    @Property()
+    public name: string = '';
+
+    @Property()
+    public description: string = '';
+
+    @Property('parameters', 'string[]')
+    public parameters: string[] = [];
+}
+
+/**
+ * Quality certificate structure
+ */
+@Object()
+export class QualityCertificate {
+    @Property()
+    public docType: string = 'qualityCertificate';
+
+    @Property()
+    public certificateId: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property('testIds', 'string[]')
+    public testIds: string[] = [];
+
+    @Property()
+    public certificateType: string = '';
+
+    @Property()
+    public issueDate: string = '';
+
+    @Property()
+    public issuer: string = '';
+
+    @Property()
+    public validityPeriod: string = '';
+
+    @Property()
+    public standards: string = '';
+
+    @Property()
+    public isActive: boolean = true;
+
+    @Property()
+    public createdTimestamp: string = '';
+
+    @Property()
+    public lastUpdated: string = '';
+
+    @Property()
+    public expiryNotifiedAt?: string; // When a CertificationExpiring event was emitted for the certificate
+
+    @Property()
+    public tenantId?: string; // Tenant of the source batch; hidden from identities of other tenants
+}
+
+/**
+ * Certificate nearing expiry, as reported by CheckExpiringCertifications
+ */
+@Object()
+export class ExpiringCertification {
+    @Property()
+    public certificateId: string = '';
+
+    @Property()
+    public certificateType: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public issuer: string = '';
+
+    @Property()
+    public expiresAt: string = '';
+
+    @Property()
+    public daysRemaining: number = 0; // Whole days left, rounded down
+
+    @Property()
+    public owner: string = ''; // Current owner of the certified batch
+
+    @Property()
+    public ownerMspId: string = ''; // Organization that signed the batch's latest history event
+}
+
+/**
+ * Result of a scheduled certificate expiry check
+ */
+@Object()
+export class CertificationExpiryReport {
+    @Property()
+    public checkedAt: string = '';
+
+    @Property()
+    public withinDays: number = 0;
+
+    @Property('expiring', 'ExpiringCertification[]')
+    public expiring: ExpiringCertification[] = []; // Certificates newly found to expire within the window
+}
+
+/**
+ * Combined query result of product and batch
+ */
+@Object()
+export class ProductWithBatch {
+    @Property('product', 'Product')
+    public product: Product = new Product();
+
+    @Property('batch', 'RiceBatch')
+    public batch: RiceBatch = new RiceBatch();
+}
+
+/**
+ * Single step of a processing workflow
+ */
+@Object()
+export class WorkflowStep {
+    @Property()
+    public name: string = ''; // Step name as recorded in HistoryEvent.step
+
+    @Property()
+    public optional: boolean = false; // Optional steps may be skipped
+
+    @Property('requiredTests', 'string[]')
+    public requiredTests: string[] = []; // Test types that must have passed before entering this step
+}
+
+/**
+ * Named processing workflow definition - ordered step sequence used by a mill
+ */
+@Object()
+export class ProcessingWorkflow {
+    @Property()
+    public docType: string = 'processingWorkflow';
+
+    @Property()
+    public workflowId: string = '';
+
+    @Property()
+    public name: string = '';
+
+    @Property()
+    public description: string = '';
+
+    @Property('steps', 'WorkflowStep[]')
+    public steps: WorkflowStep[] = [];
+
+    @Property()
+    public version: number = 1;
+
+    @Property()
+    public definedBy: string = ''; // MSP ID of the defining organization
+
+    @Property()
+    public createdTimestamp: string = '';
+
+    @Property()
+    public lastUpdated: string = '';
+}
+
+/**
+ * Record of a client request already processed by a write transaction (idempotency key)
+ */
+@Object()
+export class ProcessedRequest {
+    @Property()
+    public docType: string = 'processedRequest';
+
+    @Property()
+    public clientRequestId: string = '';
+
+    @Property()
+    public operation: string = ''; // Transaction that processed the request
+
+    @Property()
+    public mspId: string = ''; // Request IDs are scoped to the submitting organization
+
+    @Property()
+    public txId: string = ''; // Transaction that first processed the request
+
+    @Property()
+    public timestamp: string = '';
+}
+
+/**
+ * Commercial notes/terms an organization attaches to a batch it owns
+ * Stored in the organization's implicit private data collection; only a hash is public
+ */
+@Object()
+export class CommercialTerms {
+    @Property()
+    public docType: string = 'commercialTerms';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public mspId: string = ''; // Organization that holds the terms
+
+    @Property()
+    public terms: string = ''; // Free text or JSON, as submitted
+
+    @Property()
+    public updatedAt: string = '';
+
+    @Property()
+    public txId: string = '';
+}
+
+/**
+ * Public commitment to an organization's private commercial terms for a batch
+ */
+@Object()
+export class CommercialTermsCommitment {
+    @Property()
+    public docType: string = 'commercialTermsCommitment';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public mspId: string = '';
+
+    @Property()
+    public termsHash: string = ''; // SHA-256 (hex) of the terms
+
+    @Property()
+    public updatedAt: string = '';
+
+    @Property()
+    public purgedAt?: string; // The terms were purged from the implicit collection; the hash remains
+
+    @Property()
+    public tenantId?: string; // Tenant of the batch; hidden from identities of other tenants
+}
+
+/**
+ * Salted-hash commitment to a sensitive value (price, lab value, ...) of a batch, product or test result
+ * The plaintext is only stored once the committer reveals it
+ */
+@Object()
+export class ValueCommitment {
+    @Property()
+    public docType: string = 'valueCommitment';
+
+    @Property()
+    public entityId: string = '';
+
+    @Property()
+    public field: string = ''; // Name of the committed value, e.g. pricePerTonne
+
+    @Property()
+    public saltedHash: string = ''; // SHA-256 (hex) of salt + value
+
+    @Property()
+    public committedBy: string = ''; // MSP ID of the committing organization
+
+    @Property()
+    public committedAt: string = '';
+
+    @Property()
+    public revealed: boolean = false;
+
+    @Property()
+    public value?: string; // Set on reveal
+
+    @Property()
+    public salt?: string; // Set on reveal, so anyone can recheck the hash
+
+    @Property()
+    public revealedAt?: string;
+
+    @Property()
+    public revealedBy?: string;
+
+    @Property()
+    public tenantId?: string; // Tenant of the committed entity; hidden from identities of other tenants
+}
+
+/**
+ * Immutable summary of one UTC day of supply chain activity, for long-term trend reporting
+ */
+@Object()
+export class DailyStats {
+    @Property()
+    public docType: string = 'dailyStats';
+
+    @Property()
+    public date: string = ''; // YYYY-MM-DD (UTC)
+
+    @Property()
+    public batchesCreated: number = 0;
+
+    @Property()
+    public batchTransfers: number = 0; // Processing steps / handovers recorded on batches
+
+    @Property()
+    public productTransfers: number = 0; // Product sales and returns
+
+    @Property()
+    public testsRecorded: number = 0;
+
+    @Property()
+    public failedTests: number = 0;
+
+    @Property()
+    public recalls: number = 0; // Batches and products disposed of because of a recall
+
+    @Property()
+    public generatedAt: string = '';
+
+    @Property()
+    public generatedBy: string = ''; // MSP ID of the organization that took the snapshot
+}
+
+/**
+ * Network-level indicators for the operators' steering committee, computed from the current ledger state
+ */
+@Object()
+export class NetworkKpis {
+    @Property()
+    public generatedAt: string = '';
+
+    @Property()
+    public activeSince: string = ''; // Start of the window in which parties count as active
+
+    @Property()
+    public registeredParticipants: number = 0;
+
+    @Property()
+    public activeParticipants: number = 0; // Registered participants that handed over, received or sold rice in the window
+
+    @Property()
+    public activeParties: number = 0; // Distinct owners active in the window, registered or not
+
+    @Property()
+    public batches: number = 0;
+
+    @Property()
+    public batchesByStage: Record<string, number> = {}; // Batches per current processing step
+
+    @Property()
+    public averageHarvestToPackagingDays?: number; // Absent while no batch has been packaged
+
+    @Property()
+    public packagedBatches: number = 0; // Batches the average is taken over
+
+    @Property()
+    public openQuarantines: number = 0;
+
+    @Property()
+    public openDisputes: number = 0; // Transfers whose latest settlement status is disputed
+}
+
+/**
+ * Supply chain participant (farm, mill, distributor, retailer, ...) registered by fixture seeding or onboarding
+ */
+@Object()
+export class Participant {
+    @Property()
+    public docType: string = 'participant';
+
+    @Property()
+    public participantId: string = '';
+
+    @Property()
+    public name: string = '';
+
+    @Property()
+    public role: string = ''; // e.g. Farmer, Processor, Distributor, Retailer
+
+    @Property()
+    public mspId: string = ''; // Organization the participant belongs to
+
+    @Property()
+    public location: string = '';
+
+    @Property()
+    public enrollmentId?: string; // Fabric CA enrollment ID of the participant's identity, when it registered itself
+
+    @Property()
+    public registeredAt?: string; // Transaction timestamp of the registration; absent for seeded participants
+}
+
+/**
+ * Logistics unit (pallet, container, ...) identified by an SSCC, built from EPCIS AggregationEvents
+ */
+@Object()
+export class LogisticsUnit {
+    @Property()
+    public docType: string = 'logisticsUnit';
+
+    @Property()
+    public unitId: string = ''; // Parent EPC, e.g. urn:epc:id:sscc:0614141.1234567890
+
+    @Property('childEpcs', 'string[]')
+    public childEpcs: string[] = []; // EPCs currently aggregated into the unit
+
+    @Property('batchIds', 'string[]')
+    public batchIds: string[] = []; // Batches the child EPCs resolve to
+
+    @Property('productIds', 'string[]')
+    public productIds: string[] = []; // Products the child EPCs resolve to
+
+    @Property()
+    public packed: boolean = false; // False once all children have been removed
+
+    @Property()
+    public bizLocation: string = ''; // Last reported business location
+
+    @Property()
+    public lastEventId: string = '';
+
+    @Property()
+    public createdAt: string = '';
+
+    @Property()
+    public updatedAt: string = '';
+
+    @Property()
+    public tenantId?: string; // Tenant of the importing identity; hidden from identities of other tenants
+}
+
+/**
+ * Shipment recorded from an EPCIS ObjectEvent with the shipping (or departing) business step
+ */
+@Object()
+export class Shipment {
+    @Property()
+    public docType: string = 'shipment';
+
+    @Property()
+    public shipmentId: string = ''; // EPCIS event ID
+
+    @Property()
+    public eventTime: string = '';
+
+    @Property('batchIds', 'string[]')
+    public batchIds: string[] = [];
+
+    @Property('productIds', 'string[]')
+    public productIds: string[] = [];
+
+    @Property('unitIds', 'string[]')
+    public unitIds: string[] = []; // Logistics units shipped; their contents are included in batchIds / productIds
+
+    @Property()
+    public source: string = ''; // Owning party or location from the EPCIS sourceList
+
+    @Property()
+    public destination: string = ''; // Owning party or location from the EPCIS destinationList
+
+    @Property()
+    public bizLocation: string = '';
+
+    @Property()
+    public importedBy: string = ''; // MSP ID of the importing organization
+
+    @Property()
+    public tenantId?: string; // Tenant of the importing identity; hidden from identities of other tenants
+}
+
+/**
+ * EPCIS event that was not imported, with the reason
+ */
+@Object()
+export class SkippedEpcisEvent {
+    @Property()
+    public eventId: string = '';
+
+    @Property()
+    public reason: string = '';
+}
+
+/**
+ * Outcome of importing an EPCIS capture document
+ */
+@Object()
+export class EpcisImportResult {
+    @Property()
+    public documentHash: string = ''; // SHA-256 of the canonical capture document
+
+    @Property()
+    public eventCount: number = 0;
+
+    @Property()
+    public processingRecords: number = 0;
+
+    @Property()
+    public shipments: number = 0;
+
+    @Property()
+    public logisticsUnits: number = 0; // Logistics units created or updated
+
+    @Property('batchIds', 'string[]')
+    public batchIds: string[] = []; // Batches whose history was extended
+
+    @Property('skipped', 'SkippedEpcisEvent[]')
+    public skipped: SkippedEpcisEvent[] = [];
+}
+
+/**
+ * Read access to a sensitive view (private test report, commercial terms), kept in a private data collection
+ * shared by the accessing organization and the regulator
+ */
+@Object()
+export class AccessLogEntry {
+    @Property()
+    public docType: string = 'accessLogEntry';
+
+    @Property()
+    public resourceType: string = ''; // testReport | commercialTerms
+
+    @Property()
+    public resourceId: string = ''; // Report ID or batch ID
+
+    @Property()
+    public purpose: string = ''; // Reason given by the reader, if any
+
+    @Property()
+    public accessedBy: string = ''; // Reader as identified by the gateway (role or user)
+
+    @Property()
+    public accessorMspId: string = '';
+
+    @Property()
+    public accessorFingerprint: string = ''; // SHA-256 fingerprint of the submitting X.509 certificate
+
+    @Property()
+    public accessedAt: string = '';
+
+    @Property()
+    public txId: string = '';
+}
+
+/**
+ * Weather observation for a plot over a period, anchored by the hash of the raw feed (kept off-chain)
+ */
+@Object()
+export class WeatherObservation {
+    @Property()
+    public docType: string = 'weatherObservation';
+
+    @Property()
+    public dataHash: string = ''; // SHA-256 (hex) of the raw feed data; identifies the observation
+
+    @Property()
+    public plotId: string = ''; // Field or plot the observation applies to
+
+    @Property()
+    public periodStart: string = '';
+
+    @Property()
+    public periodEnd: string = '';
+
+    @Property()
+    public source: string = ''; // Weather station or feed provider, e.g. "CMA station 50953"
+
+    @Property()
+    public summary: string = ''; // Human-readable claim, e.g. "Dry window: 0 mm rain, max RH 58%"
+
+    @Property()
+    public anchoredBy: string = ''; // MSP ID of the anchoring organization
+
+    @Property()
+    public anchoredAt: string = '';
+}
+
+/**
+ * Market price of a rice variety in a region on a day, recorded by the designated price oracle
+ */
+@Object()
+export class MarketPrice {
+    @Property()
+    public docType: string = 'marketPrice';
+
+    @Property()
+    public variety: string = '';
+
+    @Property()
+    public region: string = '';
+
+    @Property()
+    public date: string = ''; // YYYY-MM-DD
+
+    @Property()
+    public price: number = 0; // CNY per tonne
+
+    @Property()
+    public source: string = ''; // Publisher of the price, e.g. "Heilongjiang Grain Exchange daily close"
+
+    @Property()
+    public recordedBy: string = ''; // MSP ID of the oracle identity
+
+    @Property()
+    public oracleFingerprint: string = ''; // Certificate fingerprint of the oracle identity
+
+    @Property()
+    public recordedAt: string = '';
+}
+
+/**
+ * Category-specific metadata of an attachment; which fields apply depends on the category
+ */
+@Object()
+export class AttachmentMetadata {
+    @Property()
+    public takenAt?: string; // photo: when the photo was taken
+
+    @Property()
+    public stage?: string; // photo: supply chain stage the photo shows, e.g. Field or Milling (see PHOTO_STAGES)
+
+    @Property()
+    public caption?: string; // photo: description shown with the photo
+
+    @Property()
+    public laboratory?: string; // labReport
+
+    @Property()
+    public reportDate?: string; // labReport
+
+    @Property()
+    public testId?: string; // labReport: on-chain test result the report backs
+
+    @Property()
+    public certificateNumber?: string; // certificate
+
+    @Property()
+    public issuer?: string; // certificate
+
+    @Property()
+    public validUntil?: string; // certificate
+
+    @Property()
+    public counterparty?: string; // contract
+
+    @Property()
+    public signedDate?: string; // contract
+
+    @Property()
+    public declarationNumber?: string; // customsDoc
+
+    @Property()
+    public country?: string; // customsDoc: ISO 3166-1 alpha-2 code of the declaring customs authority
+}
+
+/**
+ * Document attached to a batch or product; the file itself stays off-chain and is identified by its hash
+ */
+@Object()
+export class Attachment {
+    @Property()
+    public docType: string = 'attachment';
+
+    @Property()
+    public attachmentId: string = '';
+
+    @Property()
+    public entityId: string = ''; // Batch or product ID
+
+    @Property()
+    public entityType: string = ''; // batch or product
+
+    @Property()
+    public category: string = ''; // photo, labReport, certificate, contract or customsDoc
+
+    @Property()
+    public title: string = '';
+
+    @Property()
+    public fileHash: string = ''; // SHA-256 (hex) of the file
+
+    @Property()
+    public mimeType: string = '';
+
+    @Property()
+    public uri?: string; // Where the file can be fetched, e.g. an object store URL
+
+    @Property()
+    public cid?: string; // IPFS content identifier when the file is stored on IPFS
+
+    @Property('metadata', 'AttachmentMetadata')
+    public metadata: AttachmentMetadata = {};
+
+    @Property()
+    public addedBy: string = ''; // MSP ID of the attaching organization
+
+    @Property()
+    public addedAt: string = '';
+
+    @Property()
+    public tenantId?: string; // Tenant of the batch or product; hidden from identities of other tenants
+}
+
+/**
+ * Document issued about a batch or product (e.g. a PDF traceability certificate) whose hash is anchored on-chain,
+ * so anyone holding the file can check it was issued unaltered
+ */
+@Object()
+export class AnchoredDocument {
+    @Property()
+    public docType: string = 'anchoredDocument';
+
+    @Property()
+    public documentId: string = ''; // Printed on the document, e.g. a certificate number
+
+    @Property()
+    public entityId: string = ''; // Batch or product ID
+
+    @Property()
+    public entityType: string = ''; // batch or product
+
+    @Property()
+    public documentType: string = ''; // e.g. traceabilityCertificate
+
+    @Property()
+    public documentHash: string = ''; // SHA-256 (hex) of the file
+
+    @Property()
+    public issuedBy: string = ''; // MSP ID of the issuing organization
+
+    @Property()
+    public issuerFingerprint: string = ''; // SHA-256 fingerprint of the issuing identity's X.509 certificate
+
+    @Property()
+    public issuedAt: string = '';
+
+    @Property()
+    public tenantId?: string; // Tenant of the batch or product; hidden from identities of other tenants
+}
+
+/**
+ * Acknowledgment by a buyer of having received and reviewed a quality document of a batch or product
+ */
+@Object()
+export class DocumentAcknowledgment {
+    @Property()
+    public docType: string = 'documentAcknowledgment';
+
+    @Property()
+    public entityId: string = ''; // Batch or product ID
+
+    @Property()
+    public documentHash: string = ''; // Hash of the acknowledged file, lowercase
+
+    @Property()
+    public documentSource: string = ''; // testReport, attachment or anchoredDocument
+
+    @Property()
+    public documentRef: string = ''; // Test, attachment or document ID
+
+    @Property()
+    public acknowledgedByMspId: string = '';
+
+    @Property()
+    public acknowledgedByFingerprint: string = ''; // SHA-256 fingerprint of the acknowledging identity's X.509 certificate
+
+    @Property()
+    public acknowledgedBySubject: string = ''; // Subject DN of the acknowledging identity's certificate
+
+    @Property()
+    public note?: string;
+
+    @Property()
+    public acknowledgedAt: string = '';
+
+    @Property()
+    public tenantId?: string; // Tenant of the batch or product; hidden from identities of other tenants
+}
+
+/**
+ * Calibration or maintenance performed on a piece of processing equipment
+ */
+@Object()
+export class EquipmentServiceRecord {
+    @Property()
+    public type: string = ''; // calibration or maintenance
+
+    @Property()
+    public performedAt: string = '';
+
+    @Property()
+    public description: string = '';
+
+    @Property()
+    public documentHash?: string; // SHA-256 of the calibration certificate or service report, if any
+
+    @Property()
+    public recordedAt: string = '';
+}
+
+/**
+ * Processing equipment (dryer, mill, color sorter, packaging line) that processing steps reference
+ */
+@Object()
+export class Equipment {
+    @Property()
+    public docType: string = 'equipment';
+
+    @Property()
+    public equipmentId: string = '';
+
+    @Property()
+    public equipmentType: string = ''; // dryer, mill, colorSorter or packagingLine
+
+    @Property()
+    public name: string = ''; // e.g. "Satake NPX mill, line 2"
+
+    @Property()
+    public location: string = '';
+
+    @Property()
+    public ownerMspId: string = ''; // Organization operating the equipment; the only one that can use or service it
+
+    @Property()
+    public lastCalibrationDate: string = '';
+
+    @Property()
+    public nextCalibrationDue: string = ''; // Steps cannot be processed on the equipment after this time
+
+    @Property()
+    public lastMaintenanceDate?: string;
+
+    @Property('serviceLog', 'EquipmentServiceRecord[]')
+    public serviceLog: EquipmentServiceRecord[] = [];
+
+    @Property()
+    public registeredAt: string = '';
+}
+
+/**
+ * Processing step performed on a piece of equipment
+ */
+@Object()
+export class EquipmentUsage {
+    @Property()
+    public equipmentId: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public step: string = '';
+
+    @Property()
+    public processedAt: string = '';
+}
+
+/**
+ * Operator shift of a facility; times are HH:mm in UTC, and a shift ending before it starts runs past midnight
+ */
+@Object()
+export class FacilityShift {
+    @Property()
+    public name: string = ''; // e.g. "Day" or "Night"
+
+    @Property()
+    public start: string = '';
+
+    @Property()
+    public end: string = '';
+}
+
+/**
+ * Site where processing steps take place (drying yard, mill, warehouse, packing plant)
+ */
+@Object()
+export class Facility {
+    @Property()
+    public docType: string = 'facility';
+
+    @Property()
+    public facilityId: string = '';
+
+    @Property()
+    public name: string = '';
+
+    @Property()
+    public facilityType: string = ''; // dryingYard, mill, warehouse or packingPlant
+
+    @Property()
+    public location: string = ''; // Address or region of the site
+
+    @Property()
+    public ownerMspId: string = ''; // Organization operating the facility; only it can record steps there
+
+    @Property('lines', 'string[]')
+    public lines: string[] = []; // Processing lines; steps may name any line when empty
+
+    @Property('shifts', 'FacilityShift[]')
+    public shifts: FacilityShift[] = []; // Operator shifts; steps may name any shift when empty
+
+    @Property()
+    public registeredAt: string = '';
+
+    @Property()
+    public lastUpdated: string = '';
+}
+
+/**
+ * Processing step recorded at a facility
+ */
+@Object()
+export class FacilityActivity {
+    @Property()
+    public facilityId: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public step: string = '';
+
+    @Property()
+    public processedAt: string = '';
+
+    @Property()
+    public line: string = '';
+
+    @Property()
+    public shift: string = '';
+}
+
+/**
+ * Geographic indication rule for a protected origin, e.g. Wuchang rice
+ */
+@Object()
+export class GIRule {
+    @Property()
+    public docType: string = 'giRule';
+
+    @Property()
+    public giId: string = '';
+
+    @Property()
+    public name: string = ''; // Protected name carried in the trace output, e.g. "Wuchang Rice"
+
+    @Property('allowedRegions', 'string[]')
+    public allowedRegions: string[] = []; // Batch origins inside the protected area
+
+    @Property('allowedPlots', 'string[]')
+    public allowedPlots: string[] = []; // Registered plots; any plot in the regions when empty
+
+    @Property('allowedVarieties', 'string[]')
+    public allowedVarieties: string[] = []; // Permitted varieties; any variety when empty
+
+    @Property()
+    public version: number = 1;
+
+    @Property()
+    public definedBy: string = ''; // MSP ID of the defining organization
+
+    @Property()
+    public createdTimestamp: string = '';
+
+    @Property()
+    public lastUpdated: string = '';
+}
+
+/**
+ * Result of checking a batch against a geographic indication rule
+ */
+@Object()
+export class GIComplianceCheck {
+    @Property()
+    public giId: string = '';
+
+    @Property()
+    public giName: string = '';
+
+    @Property()
+    public ruleVersion: number = 1;
+
+    @Property()
+    public plotId: string = '';
+
+    @Property()
+    public passed: boolean = false;
+
+    @Property('violations', 'string[]')
+    public violations: string[] = [];
+
+    @Property()
+    public checkedAt: string = '';
+
+    @Property()
+    public checkedBy: string = ''; // MSP ID of the organization that ran the check
+}
+
+/**
+ * Status change of an export consignment
+ */
+@Object()
+export class ConsignmentStatusChange {
+    @Property()
+    public status: string = '';
+
+    @Property()
+    public timestamp: string = '';
+
+    @Property()
+    public mspId: string = ''; // Organization that recorded the change
+
+    @Property()
+    public note?: string;
+}
+
+/**
+ * Export consignment grouping batches and products shipped abroad together, with its customs documentation
+ */
+@Object()
+export class Consignment {
+    @Property()
+    public docType: string = 'consignment';
+
+    @Property()
+    public consignmentId: string = '';
+
+    @Property()
+    public exporterMspId: string = ''; // Organization that prepared the consignment; the only one that can advance it
+
+    @Property()
+    public destinationCountry: string = ''; // ISO 3166-1 alpha-2 code
+
+    @Property('batchIds', 'string[]')
+    public batchIds: string[] = [];
+
+    @Property('productIds', 'string[]')
+    public productIds: string[] = [];
+
+    @Property()
+    public phytosanitaryCertificateHash?: string; // SHA-256 of the phytosanitary certificate, set on inspection
+
+    @Property()
+    public customsDeclarationRef?: string; // Export customs declaration number, set on clearance
+
+    @Property()
+    public status: string = ''; // Prepared, Inspected, Cleared or Shipped
+
+    @Property('statusHistory', 'ConsignmentStatusChange[]')
+    public statusHistory: ConsignmentStatusChange[] = [];
+
+    @Property()
+    public transfer?: ConsignmentTransfer; // Handover of the items to the importer, once started
+
+    @Property()
+    public createdAt: string = '';
+
+    @Property()
+    public tenantId?: string; // Tenant of the consigned batches and products; hidden from identities of other tenants
+}
+
+/**
+ * Handover of every batch and product of a consignment to the importer, possibly over several transactions
+ */
+@Object()
+export class ConsignmentTransfer {
+    @Property()
+    public newOwner: string = '';
+
+    @Property()
+    public newOwnerMspId: string = ''; // Organization of the new owner, which endorses later product updates
+
+    @Property('transferredBatchIds', 'string[]')
+    public transferredBatchIds: string[] = [];
+
+    @Property('transferredProductIds', 'string[]')
+    public transferredProductIds: string[] = [];
+
+    @Property()
+    public startedAt: string = '';
+
+    @Property()
+    public completedAt?: string; // Set once every item has been handed over
+}
+
+/**
+ * Result of one TransferConsignment transaction
+ */
+@Object()
+export class ConsignmentTransferProgress {
+    @Property()
+    public consignmentId: string = '';
+
+    @Property()
+    public newOwner: string = '';
+
+    @Property()
+    public transferred: number = 0; // Items handed over by this transaction
+
+    @Property()
+    public remaining: number = 0; // Items still to hand over in further transactions
+
+    @Property()
+    public complete: boolean = false;
+}
+
+/**
+ * Maximum residue level (MRL) of a substance in an export market
+ */
+@Object()
+export class ResidueLimit {
+    @Property()
+    public substance: string = ''; // Pesticide or contaminant, e.g. "chlorpyrifos" or "cadmium"
+
+    @Property()
+    public maxMgPerKg: number = 0;
+}
+
+/**
+ * Import requirements of an export market, e.g. EU MRLs or Japanese import rules
+ */
+@Object()
+export class ComplianceProfile {
+    @Property()
+    public docType: string = 'complianceProfile';
+
+    @Property()
+    public market: string = ''; // Market ID, e.g. "EU" or "JP"
+
+    @Property()
+    public name: string = '';
+
+    @Property('countries', 'string[]')
+    public countries: string[] = []; // ISO 3166-1 alpha-2 destination countries the profile applies to
+
+    @Property('requiredTests', 'string[]')
+    public requiredTests: string[] = []; // Test types a batch must have passed
+
+    @Property('residueLimits', 'ResidueLimit[]')
+    public residueLimits: ResidueLimit[] = [];
+
+    @Property('requiredDocuments', 'string[]')
+    public requiredDocuments: string[] = []; // Attachment categories a batch must carry, e.g. "labReport"
+
+    @Property()
+    public version: number = 1;
+
+    @Property()
+    public definedBy: string = ''; // MSP ID of the defining organization
+
+    @Property()
+    public createdTimestamp: string = '';
+
+    @Property()
+    public lastUpdated: string = '';
+}
+
+/**
+ * Country the supply chain operates in; shipments to other countries are exports
+ */
+@Object()
+export class DomesticMarket {
+    @Property()
+    public docType: string = 'domesticMarket';
+
+    @Property()
+    public country: string = ''; // ISO 3166-1 alpha-2 code
+
+    @Property()
+    public version: number = 0; // 0 for the built-in default
+
+    @Property()
+    public definedBy?: string;
+
+    @Property()
+    public lastUpdated?: string;
+}
+
+/**
+ * Requirement of a compliance profile a batch does not meet
+ */
+@Object()
+export class ComplianceViolation {
+    @Property()
+    public rule: string = ''; // requiredTest, residueLimit or requiredDocument
+
+    @Property()
+    public requirement: string = ''; // Test type, substance or attachment category
+
+    @Property()
+    public message: string = '';
+}
+
+/**
+ * Result of checking a batch against the compliance profile of an export market
+ */
+@Object()
+export class ExportComplianceCheck {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public market: string = '';
+
+    @Property()
+    public profileVersion: number = 1;
+
+    @Property()
+    public compliant: boolean = false;
+
+    @Property('violations', 'ComplianceViolation[]')
+    public violations: ComplianceViolation[] = [];
+
+    @Property()
+    public checkedAt: string = '';
+}
+
+/**
+ * Weighted criteria of the traceability completeness score
+ */
+@Object()
+export class TraceabilityScoringCriteria {
+    @Property()
+    public docType: string = 'traceabilityScoring';
+
+    @Property()
+    public plotGeolocationWeight: number = 0; // Some step report carries the geolocation of the plot
+
+    @Property()
+    public requiredTestsWeight: number = 0; // Passed tests for the steps the batch reached
+
+    @Property()
+    public requiredTests: Record<string, string[]> = {}; // Step name -> test types that must have passed, on top of the batch's workflow
+
+    @Property()
+    public custodyChainWeight: number = 0; // Every handover continues from the previous owner and is signed
+
+    @Property()
+    public coldChainWeight: number = 0; // Cold-chain temperature logs on the steps that need them
+
+    @Property('coldChainSteps', 'string[]')
+    public coldChainSteps: string[] = [];
+
+    @Property()
+    public version: number = 0; // 0 for the built-in defaults
+
+    @Property()
+    public definedBy?: string;
+
+    @Property()
+    public lastUpdated?: string;
+}
+
+/**
+ * Contribution of one criterion to a traceability score
+ */
+@Object()
+export class TraceabilityCriterionScore {
+    @Property()
+    public criterion: string = '';
+
+    @Property()
+    public weight: number = 0;
+
+    @Property()
+    public fulfilment: number = 0; // Share of the criterion met, 0-1
+
+    @Property()
+    public detail: string = '';
+}
+
+/**
+ * Traceability completeness score of a batch, 0-100
+ */
+@Object()
+export class TraceabilityScore {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public score: number = 0;
+
+    @Property('criteria', 'TraceabilityCriterionScore[]')
+    public criteria: TraceabilityCriterionScore[] = [];
+
+    @Property()
+    public criteriaVersion: number = 0;
+}
+
+/**
+ * Gap in the trace chain of a product
+ */
+@Object()
+export class TraceChainDeficiency {
+    @Property()
+    public rule: string = ''; // custodyLink, stepOrder, missingTest or unregisteredOperator
+
+    @Property()
+    public entityId: string = ''; // Batch or product the gap was found on
+
+    @Property()
+    public index?: number; // Position of the history event or product transfer concerned
+
+    @Property()
+    public step?: string;
+
+    @Property()
+    public message: string = '';
+}
+
+/**
+ * Result of checking the trace chain of a product for gaps
+ */
+@Object()
+export class TraceChainValidation {
+    @Property()
+    public productId: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public valid: boolean = false; // No deficiency found
+
+    @Property('deficiencies', 'TraceChainDeficiency[]')
+    public deficiencies: TraceChainDeficiency[] = [];
+
+    @Property()
+    public criteriaVersion: number = 0; // Version of the scoring criteria whose required tests were checked
+}
+
+/**
+ * Caps on how much a single batch can accumulate, so its document stays within practical state size
+ */
+@Object()
+export class BatchStorageLimits {
+    @Property()
+    public docType: string = 'batchStorageLimits';
+
+    @Property()
+    public maxHistoryEvents: number = 0; // History events kept on the batch document; older ones move to continuation keys
+
+    @Property()
+    public maxTestResultsPerBatch: number = 0;
+
+    @Property()
+    public maxDocumentBytes: number = 0; // Serialized batch documents larger than this are rejected
+
+    @Property()
+    public version: number = 0; // 0 for the built-in defaults
+
+    @Property()
+    public definedBy?: string;
+
+    @Property()
+    public lastUpdated?: string;
+}
+
+/**
+ * Caps on the size of transaction inputs, so oversized requests are rejected before they reach world state and blocks
+ */
+@Object()
+export class InputLimits {
+    @Property()
+    public docType: string = 'inputLimits';
+
+    @Property()
+    public maxReportBytes: number = 0; // Report JSON of a recorded step
+
+    @Property()
+    public maxLabels: number = 0; // Labels of a batch or product
+
+    @Property()
+    public maxBulkItems: number = 0; // Events of an EPCIS import, items of a recall or consignment
+
+    @Property()
+    public version: number = 0; // 0 for the built-in defaults
+
+    @Property()
+    public definedBy?: string;
+
+    @Property()
+    public lastUpdated?: string;
+}
+
+/**
+ * Continuation key holding a run of the oldest history events of a batch
+ */
+@Object()
+export class BatchHistorySegment {
+    @Property()
+    public docType: string = 'batchHistorySegment';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public segment: number = 0; // 1 for the oldest events
+
+    @Property()
+    public firstIndex: number = 0; // Index of the first event of the segment in the full history
+
+    @Property('events', 'HistoryEvent[]')
+    public events: HistoryEvent[] = [];
+
+    @Property()
+    public tenantId?: string; // Tenant of the batch; hidden from identities of other tenants
+}
+
+/**
+ * Storage used by a batch against the configured limits
+ */
+@Object()
+export class BatchStorageUsage {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public documentBytes: number = 0;
+
+    @Property()
+    public maxDocumentBytes: number = 0;
+
+    @Property()
+    public storedHistoryEvents: number = 0; // Events on the batch document itself
+
+    @Property()
+    public maxHistoryEvents: number = 0;
+
+    @Property()
+    public archivedHistorySegments: number = 0;
+
+    @Property()
+    public totalHistoryEvents: number = 0;
+
+    @Property()
+    public testResults: number = 0;
+
+    @Property()
+    public maxTestResultsPerBatch: number = 0;
+}
+
+/**
+ * Aggregates of the batches of a crop year, or of one season of it, for year-over-year comparisons
+ */
+@Object()
+export class SeasonStats {
+    @Property()
+    public cropYear: number = 0;
+
+    @Property()
+    public season: string = ''; // Empty for the whole crop year
+
+    @Property()
+    public batches: number = 0;
+
+    @Property()
+    public disposedBatches: number = 0;
+
+    @Property()
+    public products: number = 0; // Products packaged from the batches
+
+    @Property()
+    public testsRecorded: number = 0;
+
+    @Property()
+    public failedTests: number = 0;
+
+    @Property()
+    public failureRate: number = 0; // failedTests / testsRecorded, 0-1
+
+    @Property()
+    public varieties: Record<string, number> = {}; // Batches per variety
+}
+
+/**
+ * A transfer rule that would reject a step and transfer
+ */
+@Object()
+export class TransferBlocker {
+    @Property()
+    public rule: string = ''; // permission, disposed, reservations, report, duplicateStep, chronology, workflow, qualityGates, equipment, storageLimits
+
+    @Property()
+    public message: string = '';
+}
+
+/**
+ * Outcome of checking a step and transfer against every transfer rule without recording it
+ */
+@Object()
+export class TransferCheck {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public newOwner: string = '';
+
+    @Property()
+    public step: string = ''; // Empty when only the step-independent rules were checked
+
+    @Property()
+    public allowed: boolean = false;
+
+    @Property('blockers', 'TransferBlocker[]')
+    public blockers: TransferBlocker[] = [];
+}
+
+/**
+ * Where a participant's notifications are delivered
+ */
+@Object()
+export class NotificationChannel {
+    @Property()
+    public type: string = ''; // email, sms or webhook
+
+    @Property()
+    public target: string = ''; // Email address, E.164 phone number or webhook URL
+}
+
+/**
+ * Events a participant wants to be notified of, and the channels to notify it on
+ */
+@Object()
+export class NotificationPreference {
+    @Property()
+    public docType: string = 'notificationPreference';
+
+    @Property()
+    public participantId: string = '';
+
+    @Property()
+    public mspId: string = ''; // Organization managing the preferences
+
+    @Property('eventTypes', 'string[]')
+    public eventTypes: string[] = []; // Chaincode event names; empty for every event
+
+    @Property('batchIds', 'string[]')
+    public batchIds: string[] = []; // Batches of interest; empty for every batch
+
+    @Property('channels', 'NotificationChannel[]')
+    public channels: NotificationChannel[] = [];
+
+    @Property()
+    public version: number = 0;
+
+    @Property()
+    public updatedAt: string = '';
+}
+
+/**
+ * Limits protecting product verification codes against brute force
+ */
+@Object()
+export class VerificationGuardPolicy {
+    @Property()
+    public docType: string = 'verificationGuardPolicy';
+
+    @Property()
+    public maxFailedAttempts: number = 0; // Consecutive failures that lock the product
+
+    @Property()
+    public lockoutMinutes: number = 0;
+
+    @Property()
+    public suspiciousSuccessCount: number = 0; // Successful verifications of one code reported as a likely clone
+
+    @Property()
+    public version: number = 0;
+
+    @Property()
+    public definedBy?: string;
+
+    @Property()
+    public lastUpdated?: string;
+}
+
+/**
+ * Verification code of a product package and its attempt counters
+ */
+@Object()
+export class ProductVerification {
+    @Property()
+    public docType: string = 'productVerification';
+
+    @Property()
+    public productId: string = '';
+
+    @Property()
+    public codeHash: string = ''; // HMAC-SHA256 of the code, keyed by the peers' verification secret
+
+    @Property()
+    public registeredByMspId: string = '';
+
+    @Property()
+    public registeredAt: string = '';
+
+    @Property()
+    public failedAttempts: number = 0;
+
+    @Property()
+    public consecutiveFailures: number = 0;
+
+    @Property()
+    public lockouts: number = 0;
+
+    @Property()
+    public lockedUntil: string = ''; // Empty when the product has never been locked
+
+    @Property()
+    public attemptsWhileLocked: number = 0;
+
+    @Property()
+    public successfulVerifications: number = 0;
+
+    @Property()
+    public lastFailureAt?: string;
+
+    @Property()
+    public lastVerifiedAt?: string;
+}
+
+/**
+ * Outcome of a product verification attempt, readable once the attempt's transaction has been committed
+ */
+@Object()
+export class ProductVerificationResult {
+    @Property()
+    public docType: string = 'productVerificationResult';
+
+    @Property()
+    public attemptId: string = ''; // Transaction ID of the VerifyProduct call
+
+    @Property()
+    public productId: string = '';
+
+    @Property()
+    public attemptedAt: string = '';
+
+    @Property()
+    public verified: boolean = false;
+
+    @Property()
+    public locked: boolean = false; // The code was not checked, or this attempt triggered a lockout
+
+    @Property()
+    public remainingAttempts: number = 0; // Failures left before a lockout
+
+    @Property()
+    public lockedUntil: string = '';
+}
+
+/**
+ * Quantity of a batch committed to a pending sale, so it cannot be sold twice
+ */
+@Object()
+export class BatchReservation {
+    @Property()
+    public reservationId: string = ''; // ID of the reserving transaction
+
+    @Property()
+    public buyer: string = '';
+
+    @Property()
+    public quantityKg: number = 0;
+
+    @Property()
+    public reservedByMspId: string = '';
+
+    @Property()
+    public reservedAt: string = '';
+
+    @Property()
+    public expiresAt: string = '';
+
+    @Property()
+    public status: string = ''; // Active, Consumed or Released; an Active reservation past expiresAt is Expired
+
+    @Property()
+    public closedAt?: string; // When the reservation was consumed by the transfer to the buyer, or released
+}
+
+/**
+ * Handover of a batch agreed for a contractual date, activated by any party once the date is reached
+ */
+@Object()
+export class ScheduledTransfer {
+    @Property()
+    public scheduleId: string = ''; // ID of the scheduling transaction
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public fromOwner: string = ''; // Owner of the batch when the transfer was scheduled
+
+    @Property()
+    public newOwner: string = '';
+
+    @Property()
+    public effectiveTime: string = ''; // Earliest time the transfer can be activated
+
+    @Property()
+    public scheduledByMspId: string = '';
+
+    @Property()
+    public scheduledAt: string = '';
+
+    @Property()
+    public status: string = ''; // Pending, Activated or Cancelled
+
+    @Property()
+    public closedAt?: string; // When the transfer was activated or cancelled
+
+    @Property()
+    public activatedByMspId?: string;
+}
+
+/**
+ * Quantity of a batch and how much of it is reserved
+ */
+@Object()
+export class BatchReservationSummary {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public quantityKg: number = 0;
+
+    @Property()
+    public reservedKg: number = 0; // Held by active, unexpired reservations
+
+    @Property()
+    public availableKg: number = 0;
+
+    @Property('reservations', 'BatchReservation[]')
+    public reservations: BatchReservation[] = [];
+}
+
+/**
+ * Weight lost by a batch in a processing step, e.g. moisture removed by drying, which lowers its declared quantity
+ */
+@Object()
+export class WeightAdjustment {
+    @Property()
+    public adjustmentId: string = ''; // ID of the recording transaction
+
+    @Property()
+    public step: string = ''; // Processing step that lost the weight
+
+    @Property()
+    public beforeKg: number = 0;
+
+    @Property()
+    public afterKg: number = 0;
+
+    @Property()
+    public lossPercent: number = 0; // Rounded to 0.01
+
+    @Property()
+    public reason: string = '';
+
+    @Property()
+    public recordedByMspId: string = '';
+
+    @Property()
+    public recordedAt: string = '';
+}
+
+/**
+ * Resources a processing step consumed, kept in the recording organization's implicit collection for cost analytics
+ */
+@Object()
+export class ResourceUsage {
+    @Property()
+    public docType: string = 'resourceUsage';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public eventIndex: number = 0; // Index of the processing record in the full batch history
+
+    @Property()
+    public step: string = '';
+
+    @Property()
+    public timestamp: string = ''; // Time of the processing record
+
+    @Property()
+    public mspId: string = ''; // Organization that recorded the step and holds the record
+
+    @Property()
+    public energyKwh?: number;
+
+    @Property()
+    public laborHours?: number;
+
+    @Property()
+    public machineHours?: number;
+
+    @Property()
+    public txId: string = '';
+
+    @Property()
+    public tenantId?: string; // Tenant of the batch; hidden from identities of other tenants
+}
+
+/**
+ * Summed resource usage of a group of processing records
+ */
+@Object()
+export class ResourceTotals {
+    @Property()
+    public records: number = 0;
+
+    @Property()
+    public energyKwh: number = 0;
+
+    @Property()
+    public laborHours: number = 0;
+
+    @Property()
+    public machineHours: number = 0;
+}
+
+/**
+ * Resource usage of an organization's processing records over a period, in total and per step
+ */
+@Object()
+export class ResourceUsageSummary {
+    @Property()
+    public mspId: string = '';
+
+    @Property()
+    public from: string = '';
+
+    @Property()
+    public to: string = '';
+
+    @Property()
+    public batches: number = 0;
+
+    @Property('totals', 'ResourceTotals')
+    public totals: ResourceTotals = new ResourceTotals();
+
+    @Property()
+    public byStep: Record<string, ResourceTotals> = {};
+}
+
+/**
+ * Correction of a mistyped processing record. The original history event is kept and points to it
+ */
+@Object()
+export class ProcessingRecordCorrection {
+    @Property()
+    public correctionId: string = ''; // ID of the correcting transaction
+
+    @Property()
+    public index: number = 0; // Index of the corrected event in the full history
+
+    @Property()
+    public step: string = ''; // Corrected step
+
+    @Property('report', 'ReportDetail')
+    public report: ReportDetail = new ReportDetail(); // Corrected report
+
+    @Property()
+    public reason: string = '';
+
+    @Property()
+    public correctedByMspId: string = '';
+
+    @Property()
+    public correctedByFingerprint: string = '';
+
+    @Property()
+    public correctedAt: string = '';
+
+    @Property()
+    public supersededBy?: string; // ID of a later correction of the same event
+}
+
+/**
+ * Quantities of a group of an owner's batches
+ */
+@Object()
+export class InventoryTotals {
+    @Property()
+    public batches: number = 0;
+
+    @Property()
+    public batchesWithoutQuantity: number = 0; // Batches whose quantity has not been declared; not in the kg totals
+
+    @Property()
+    public quantityKg: number = 0;
+
+    @Property()
+    public reservedKg: number = 0;
+
+    @Property()
+    public availableKg: number = 0;
+}
+
+/**
+ * A batch in an owner's inventory with its remaining quantity
+ */
+@Object()
+export class InventoryBatch {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public variety: string = '';
+
+    @Property()
+    public currentState: string = '';
+
+    @Property()
+    public quantityKg: number = 0; // 0 when not declared
+
+    @Property()
+    public reservedKg: number = 0; // Held by active reservations
+
+    @Property()
+    public availableKg: number = 0;
+}
+
+/**
+ * Batches and products an owner currently holds, with totals by variety and processing step
+ */
+@Object()
+export class OwnerInventory {
+    @Property()
+    public owner: string = '';
+
+    @Property('batches', 'InventoryBatch[]')
+    public batches: InventoryBatch[] = []; // Disposed batches are not inventory
+
+    @Property('products', 'Product[]')
+    public products: Product[] = []; // Sold and disposed products are not inventory
+
+    @Property('totals', 'InventoryTotals')
+    public totals: InventoryTotals = new InventoryTotals();
+
+    @Property()
+    public byVariety: Record<string, InventoryTotals> = {};
+
+    @Property()
+    public byStep: Record<string, InventoryTotals> = {};
+}
+
+/**
+ * Latest standing result of one test type for a compared batch
+ */
+@Object()
+export class ComparedQualityMetric {
+    @Property()
+    public testId: string = '';
+
+    @Property()
+    public result: string = '';
+
+    @Property()
+    public passed: boolean = false;
+
+    @Property()
+    public testDate: string = '';
+
+    @Property()
+    public laboratory?: string;
+
+    @Property()
+    public isVerified: boolean = false; // Confirmed against the laboratory's report
+}
+
+/**
+ * Active certificate of a compared batch
+ */
+@Object()
+export class ComparedCertification {
+    @Property()
+    public certificateId: string = '';
+
+    @Property()
+    public certificateType: string = '';
+
+    @Property()
+    public issuer: string = '';
+
+    @Property()
+    public standards: string = '';
+
+    @Property()
+    public expiresAt?: string; // Not set for certificates without a validity period
+}
+
+/**
+ * One lot in a batch comparison
+ */
+@Object()
+export class ComparedBatch {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public origin: string = '';
+
+    @Property()
+    public variety: string = '';
+
+    @Property()
+    public harvestDate: string = '';
+
+    @Property()
+    public cropYear?: number;
+
+    @Property()
+    public season?: string;
+
+    @Property()
+    public currentState: string = '';
+
+    @Property()
+    public currentOwner: string = '';
+
+    @Property()
+    public availableKg?: number; // Declared quantity less active reservations; not set when no quantity was declared
+
+    @Property()
+    public geographicIndication?: string; // Name of the protected origin, only when the latest GI check passed
+
+    @Property()
+    public quarantined: boolean = false;
+
+    @Property()
+    public qualityMetrics: Record<string, ComparedQualityMetric> = {}; // By test type; revoked results left out
+
+    @Property('grades', 'string[]')
+    public grades: string[] = []; // Grades declared on the products packaged from the batch
+
+    @Property('certifications', 'ComparedCertification[]')
+    public certifications: ComparedCertification[] = []; // Active and unexpired
+}
+
+/**
+ * Side-by-side view of lots offered to a buyer
+ */
+@Object()
+export class BatchComparison {
+    @Property()
+    public comparedAt: string = '';
+
+    @Property('testTypes', 'string[]')
+    public testTypes: string[] = []; // Every test type found on any compared batch, i.e. the rows of the quality table
+
+    @Property('certificateTypes', 'string[]')
+    public certificateTypes: string[] = [];
+
+    @Property('batches', 'ComparedBatch[]')
+    public batches: ComparedBatch[] = []; // In the order requested
+}
+
+/**
+ * Notice of a recall to one current owner of recalled batches or products
+ */
+@Object()
+export class RecallNotice {
+    @Property()
+    public owner: string = '';
+
+    @Property()
+    public ownerMspId: string = ''; // Organization holding the items; empty when no signer is recorded
+
+    @Property()
+    public participantId?: string; // Registered participant the owner name or ID matches, if any
+
+    @Property('batchIds', 'string[]')
+    public batchIds: string[] = []; // Recalled batches the owner holds
+
+    @Property('productIds', 'string[]')
+    public productIds: string[] = []; // Recalled products the owner holds
+}
+
+/**
+ * Recall of batches and the products packaged from them, with a notice per current owner
+ */
+@Object()
+export class Recall {
+    @Property()
+    public docType: string = 'recall';
+
+    @Property()
+    public recallId: string = '';
+
+    @Property()
+    public reason: string = '';
+
+    @Property('batchIds', 'string[]')
+    public batchIds: string[] = []; // Batches recalled
+
+    @Property('productIds', 'string[]')
+    public productIds: string[] = []; // Products recalled, named or packaged from a recalled batch; disposed ones left out
+
+    @Property('notices', 'RecallNotice[]')
+    public notices: RecallNotice[] = [];
+
+    @Property()
+    public issuedAt: string = '';
+
+    @Property()
+    public issuedBy: string = ''; // MSP ID of the issuing organization
+
+    @Property()
+    public tenantId?: string; // Tenant of the recalled batches and products; hidden from identities of other tenants
+}
+
+/**
+ * Confirmation by an owner named in a recall notice of what it did with recalled stock
+ */
+@Object()
+export class RecallAcknowledgment {
+    @Property()
+    public docType: string = 'recallAcknowledgment';
+
+    @Property()
+    public acknowledgmentId: string = ''; // ID of the acknowledging transaction
+
+    @Property()
+    public recallId: string = '';
+
+    @Property()
+    public owner: string = ''; // Owner of the notice acknowledged
+
+    @Property()
+    public ownerMspId: string = '';
+
+    @Property('itemIds', 'string[]')
+    public itemIds: string[] = []; // Products and batches of the notice the acknowledgment covers
+
+    @Property()
+    public disposition: string = ''; // Quarantined, Returned, Destroyed or NotInStock
+
+    @Property()
+    public acknowledgedByMspId: string = '';
+
+    @Property()
+    public acknowledgedByFingerprint: string = '';
+
+    @Property()
+    public acknowledgedAt: string = '';
+
+    @Property()
+    public tenantId?: string; // Tenant of the recall; hidden from identities of other tenants
+}
+
+/**
+ * Response of one owner named in a recall
+ */
+@Object()
+export class RecallOwnerStatus {
+    @Property()
+    public owner: string = '';
+
+    @Property()
+    public ownerMspId: string = '';
+
+    @Property()
+    public participantId?: string;
+
+    @Property()
+    public status: string = ''; // Responded (every item acknowledged), Partial or NoResponse
+
+    @Property()
+    public items: number = 0;
+
+    @Property()
+    public acknowledgedItems: number = 0;
+
+    @Property('pendingItemIds', 'string[]')
+    public pendingItemIds: string[] = [];
+
+    @Property()
+    public dispositions: Record<string, number> = {}; // Acknowledged items per disposition
+
+    @Property()
+    public lastAcknowledgedAt?: string;
+}
+
+/**
+ * Progress of a recall: which owners have pulled the recalled stock and which have not responded
+ */
+@Object()
+export class RecallCompletion {
+    @Property()
+    public recallId: string = '';
+
+    @Property()
+    public issuedAt: string = '';
+
+    @Property()
+    public owners: number = 0;
+
+    @Property()
+    public respondedOwners: number = 0; // Owners that acknowledged every item of their notice
+
+    @Property()
+    public partialOwners: number = 0;
+
+    @Property()
+    public silentOwners: number = 0; // Owners without any acknowledgment
+
+    @Property()
+    public items: number = 0;
+
+    @Property()
+    public acknowledgedItems: number = 0;
+
+    @Property()
+    public completionRate: number = 0; // Acknowledged share of the items, 0 to 1
+
+    @Property('ownerStatuses', 'RecallOwnerStatus[]')
+    public ownerStatuses: RecallOwnerStatus[] = [];
+}
+
+/**
+ * A source batch of a product and its share of the product
+ */
+@Object()
+export class OriginContribution {
+    @Property()
+    public channel: string = ''; // Channel the source batch is committed on
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public origin: string = '';
+
+    @Property()
+    public variety: string = '';
+
+    @Property()
+    public harvestDate: string = '';
+
+    @Property()
+    public farm: string = ''; // Primary producer: the owner the batch was registered to; empty for foreign batches
+
+    @Property()
+    public farmMspId?: string; // Organization that registered the batch
+
+    @Property()
+    public percentage?: number; // Share of the product, rounded to 0.01; not set when no quantity is recorded
+}
+
+/**
+ * Origins of the rice in a product, for origin labeling
+ */
+@Object()
+export class ProductOriginComposition {
+    @Property()
+    public productId: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property('contributions', 'OriginContribution[]')
+    public contributions: OriginContribution[] = []; // Shares add up to 100
+
+    @Property('linkedSources', 'OriginContribution[]')
+    public linkedSources: OriginContribution[] = []; // Source batches on other channels linked to the batch, without shares
+}
+
+/**
+ * Application of an agro-chemical to a batch, as recorded by one of its steps
+ */
+@Object()
+export class AgroInputUse {
+    @Property()
+    public step: string = '';
+
+    @Property()
+    public chemicalName: string = '';
+
+    @Property()
+    public inputLotId: string = ''; // Empty when the lot was not recorded
+
+    @Property()
+    public appliedAt: string = '';
+}
+
+/**
+ * Batch that received an agro-chemical, with the matching applications
+ */
+@Object()
+export class ExposedBatch {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public owner: string = '';
+
+    @Property()
+    public state: string = '';
+
+    @Property('applications', 'AgroInputUse[]')
+    public applications: AgroInputUse[] = [];
+}
+
+/**
+ * Product packaged from an exposed batch
+ */
+@Object()
+export class ExposedProduct {
+    @Property()
+    public productId: string = '';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public owner: string = '';
+
+    @Property()
+    public status: string = '';
+}
+
+/**
+ * Batches and downstream products potentially affected by a contaminated agro-chemical
+ */
+@Object()
+export class AgroInputExposure {
+    @Property()
+    public query: string = ''; // Chemical name or input lot ID searched for
+
+    @Property('batches', 'ExposedBatch[]')
+    public batches: ExposedBatch[] = [];
+
+    @Property('products', 'ExposedProduct[]')
+    public products: ExposedProduct[] = [];
+}
+
+/**
+ * How the IDs of one kind of entity are formed: <org prefix><zero-padded sequence>[check digit]
+ */
+@Object()
+export class IdScheme {
+    @Property()
+    public prefixes: Record<string, string> = {}; // MSP ID -> prefix of the IDs the organization creates
+
+    @Property()
+    public sequenceDigits: number = 0;
+
+    @Property()
+    public checkDigit: string = 'none'; // none or gs1 (GS1 mod-10 over the whole ID, prefixes must be digits)
+
+    @Property()
+    public gs1Compatible: boolean = false; // IDs fit a GS1 lot or serial number (AI 10/21): at most 20 characters of GS1 set 82
+}
+
+/**
+ * ID policy for batches and products created on the ledger
+ */
+@Object()
+export class IdPolicy {
+    @Property()
+    public docType: string = 'idPolicy';
+
+    @Property()
+    public batch?: IdScheme;
+
+    @Property()
+    public product?: IdScheme;
+
+    @Property()
+    public version: number = 0;
+
+    @Property()
+    public definedBy?: string;
+
+    @Property()
+    public lastUpdated?: string;
+}
+
+/**
+ * Next sequence number an organization draws IDs of one kind of entity from
+ */
+@Object()
+export class IdSequence {
+    @Property()
+    public docType: string = 'idSequence';
+
+    @Property()
+    public entity: string = ''; // batch or product
+
+    @Property()
+    public mspId: string = '';
+
+    @Property()
+    public next: number = 1;
+}
+
+/**
+ * Broken reference between ledger entities, with the suggested repair
+ */
+@Object()
+export class IntegrityIssue {
+    @Property()
+    public rule: string = ''; // missingBatch, unregisteredParticipant or danglingGenealogyEdge
+
+    @Property()
+    public entityId: string = ''; // Batch or product holding the reference (for participants, the first one found)
+
+    @Property()
+    public reference: string = ''; // Batch ID or participant the reference points at
+
+    @Property()
+    public index?: number; // Position of the history event or product transfer concerned
+
+    @Property()
+    public message: string = '';
+
+    @Property()
+    public repair: string = '';
+}
+
+/**
+ * Result of scanning the ledger for references to missing entities
+ */
+@Object()
+export class ReferentialIntegrityReport {
+    @Property()
+    public checkedAt: string = '';
+
+    @Property()
+    public batches: number = 0;
+
+    @Property()
+    public products: number = 0;
+
+    @Property()
+    public participants: number = 0;
+
+    @Property()
+    public consistent: boolean = false; // No issue found
+
+    @Property('issues', 'IntegrityIssue[]')
+    public issues: IntegrityIssue[] = [];
+}
+
+/**
+ * Batch in the pool of a regulator spot-check draw, with the risk factors behind its weight
+ */
+@Object()
+export class InspectionCandidate {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public weight: number = 0; // Relative chance of being drawn
+
+    @Property('failedTests', 'string[]')
+    public failedTests: string[] = []; // IDs of failed, unrevoked test results of the batch
+
+    @Property('newParticipants', 'string[]')
+    public newParticipants: string[] = []; // Owners of the batch registered recently or not registered at all
+}
+
+/**
+ * Batches drawn for regulator spot checks, recorded so the draw can be recomputed by anyone
+ */
+@Object()
+export class InspectionSelection {
+    @Property()
+    public docType: string = 'inspectionSelection';
+
+    @Property()
+    public seedTxId: string = ''; // Committed transaction whose ID seeds the draw; each seed draws once
+
+    @Property()
+    public requestedCount: number = 0;
+
+    @Property()
+    public candidateCount: number = 0;
+
+    @Property()
+    public weights: Record<string, number> = {}; // Weight of every candidate batch at the time of the draw
+
+    @Property('selected', 'InspectionCandidate[]')
+    public selected: InspectionCandidate[] = []; // In draw order
+
+    @Property()
+    public selectedByMspId: string = '';
+
+    @Property()
+    public selectedByFingerprint: string = '';
+
+    @Property()
+    public selectedAt: string = '';
+
+    @Property()
+    public txId: string = '';
+
+    @Property()
+    public tenantId?: string; // Tenant of the selecting identity, whose batches were the candidates; hidden from other tenants
+}
+
+/**
+ * How long an organization keeps a kind of private data before PurgePrivateData removes it
+ */
+@Object()
+export class RetentionPolicy {
+    @Property()
+    public docType: string = 'retentionPolicy';
+
+    @Property()
+    public mspId: string = '';
+
+    @Property()
+    public dataType: string = ''; // testReportDetails or commercialTerms
+
+    @Property()
+    public retentionDays: number = 0;
+
+    @Property()
+    public definedBy: string = ''; // Certificate fingerprint of the administrator
+
+    @Property()
+    public lastUpdated: string = '';
+}
+
+/**
+ * Outcome of one PurgePrivateData transaction
+ */
+@Object()
+export class PrivateDataPurge {
+    @Property()
+    public mspId: string = '';
+
+    @Property()
+    public dataType: string = '';
+
+    @Property()
+    public cutoff: string = ''; // Records last written at or before this time were purged
+
+    @Property('purged', 'string[]')
+    public purged: string[] = []; // Test IDs or batch IDs whose private data was purged
+
+    @Property()
+    public remaining: number = 0; // Records due for purging left for later transactions
+
+    @Property()
+    public purgedAt: string = '';
+}
+
+/**
+ * Change of the settlement status of a transfer
+ */
+@Object()
+export class SettlementStatusChange {
+    @Property()
+    public status: string = '';
+
+    @Property()
+    public reference: string = ''; // Invoice or payment reference
+
+    @Property()
+    public recordedByMspId: string = '';
+
+    @Property()
+    public recordedAt: string = '';
+}
+
+/**
+ * Settlement of the handover recorded by one history event of a batch; the amount stays in private terms
+ */
+@Object()
+export class Settlement {
+    @Property()
+    public docType: string = 'settlement';
+
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public eventIndex: number = 0;
+
+    @Property()
+    public from: string = '';
+
+    @Property()
+    public to: string = '';
+
+    @Property()
+    public status: string = ''; // invoiced, paid or disputed
+
+    @Property()
+    public reference: string = '';
+
+    @Property()
+    public lastUpdated: string = '';
+
+    @Property('changes', 'SettlementStatusChange[]')
+    public changes: SettlementStatusChange[] = []; // Oldest first
+
+    @Property()
+    public tenantId?: string; // Tenant of the batch; hidden from identities of other tenants
+}
+
+/**
+ * One transfer out of a farmer's batch in a settlement report
+ */
+@Object()
+export class SettlementLine {
+    @Property()
+    public batchId: string = '';
+
+    @Property()
+    public eventIndex: number = 0;
+
+    @Property()
+    public transferredAt: string = '';
+
+    @Property()
+    public step: string = '';
+
+    @Property()
+    public to: string = '';
+
+    @Property()
+    public cropYear?: number;
+
+    @Property()
+    public season?: string;
+
+    @Property()
+    public quantityKg?: number; // Declared quantity of the batch
+
+    @Property()
+    public status: string = ''; // unsettled until a party records a settlement
+
+    @Property()
+    public reference: string = '';
+
+    @Property()
+    public settledAt?: string; // When the transfer was marked paid
+
+    @Property()
+    public termsHash?: string; // Public commitment to the caller's organization's commercial terms for the batch
+
+    @Property()
+    public commercialTerms?: string; // The caller's organization's private terms (prices), when it holds them
+}
+
+/**
+ * Transfers out of a farmer's batches in a period with their settlement statuses, to reconcile payouts
+ */
+@Object()
+export class FarmerSettlementReport {
+    @Property()
+    public farmerId: string = '';
+
+    @Property('farmerNames', 'string[]')
+    public farmerNames: string[] = []; // Participant ID and name the farmer is recorded under
+
+    @Property()
+    public period: string = '';
+
+    @Property()
+    public pricesIncluded: boolean = false; // Served by a peer of the caller's organization, so its private terms are included
+
+    @Property('lines', 'SettlementLine[]')
+    public lines: SettlementLine[] = []; // In transfer order
+
+    @Property()
+    public statusCounts: Record<string, number> = {};
+
+    @Property()
+    public generatedAt: string = '';
+}
+
+/**
+ * Quality of tested rice in one calendar month (UTC)
+ */
+@Object()
+export class QualityTrendBucket {
+    @Property()
+    public month: string = ''; // YYYY-MM
+
+    @Property()
+    public testsRecorded: number = 0;
+
+    @Property()
+    public failedTests: number = 0;
+
+    @Property()
+    public failureRate: number = 0; // failedTests / testsRecorded, 0-1
+
+    @Property()
+    public moistureReadings: number = 0; // Tests with a recorded moisture content
+
+    @Property()
+    public averageMoisturePercent?: number; // Unset without moisture readings
+
+    @Property()
+    public grades: Record<string, number> = {}; // Products packaged in the month per declared grade
+}
+
+/**
+ * Quality trends of a variety and region over a period, month by month
+ */
+@Object()
+export class QualityTrends {
+    @Property()
+    public variety: string = ''; // Empty for every variety
+
+    @Property()
+    public region: string = ''; // Empty for every origin
+
+    @Property()
+    public from: string = '';
+
+    @Property()
+    public to: string = '';
+
+    @Property()
+    public batches: number = 0; // Matching batches with a test or product in the period
+
+    @Property()
+    public testsRecorded: number = 0;
+
+    @Property()
+    public failedTests: number = 0;
+
+    @Property()
+    public failureRate: number = 0;
+
+    @Property()
+    public moistureReadings: number = 0;
+
+    @Property()
+    public averageMoisturePercent?: number;
+
+    @Property()
+    public grades: Record<string, number> = {};
+
+    @Property('months', 'QualityTrendBucket[]')
+    public months: QualityTrendBucket[] = [];
+
+    @Property()
+    public generatedAt: string = '';
+}
+
+/**
+ * Display metadata of an organization's rice brand, shown with the products it packaged
+ */
+@Object()
+export class OrganizationBranding {
+    @Property()
+    public docType: string = 'organizationBranding';
+
+    @Property()
+    public mspId: string = '';
+
+    @Property()
+    public brandName: string = '';
+
+    @Property()
+    public logoHash: string = ''; // SHA-256 (hex) of the logo file; empty without a logo
+
+    @Property()
+    public logoUri?: string; // Where the logo can be fetched (https or ipfs); apps check it against logoHash
+
+    @Property()
+    public story: string = ''; // Brand story shown to consumers
+
+    @Property()
+    public updatedBy: string = ''; // Certificate fingerprint of the administrator
+
+    @Property()
+    public lastUpdated: string = '';
+}
+
+/**
+ * Translations of a field name or recorded value (processing step, status, grade...) shown to consumers
+ */
+@Object()
+export class LabelTranslation {
+    @Property()
+    public docType: string = 'labelTranslation';
+
+    @Property()
+    public category: string = ''; // field (names of payload fields) or value (recorded values)
+
+    @Property()
+    public code: string = ''; // Field name or value as recorded, e.g. QualityInspection or Grade 1
+
+    @Property()
+    public labels: Record<string, string> = {}; // Label per locale (zh, en)
+
+    @Property()
+    public updatedBy: string = ''; // MSP ID of the administrator's organization
+
+    @Property()
+    public lastUpdated: string = '';
+}
+
+/**
+ * Labels of one locale, by code
+ */
+@Object()
+export class LabelDictionary {
+    @Property()
+    public locale: string = '';
+
+    @Property()
+    public fields: Record<string, string> = {};
+
+    @Property()
+    public values: Record<string, string> = {};
+}
@@ -0,0 +1,590 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { createHash } from 'crypto';
+import { Context } from 'fabric-contract-api';
+import { KeyEndorsementPolicy } from 'fabric-shim';
+import stringify from 'json-stringify-deterministic';
+import sortKeysRecursive from 'sort-keys-recursive';
+import { Disposal, FieldChange, OrganizationType, ProcessedRequest } from './types';
+
+/**
+ * Terminal state of disposed batches and products
+ */
+export const DISPOSED_STATE = 'Disposed';
+
+/**
+ * Stored document as it exists on the ledger.
+ * May carry fields written by a newer chaincode version that this version does not model.
+ */
+export type StoredDocument<T> = T & Record<string, unknown>;
+
+/**
+ * Certificate attribute naming the tenant (e.g. cooperative ID) an identity acts for
+ * The tenant is recorded in batch and product documents (tenantId) rather than in their keys, so IDs stay unique
+ * across tenants and existing keys and indexes keep working. Documents derived from a batch or product (samples,
+ * test results, certificates and other per-batch records) carry the tenant of their source (inheritTenant).
+ * Isolation therefore depends on every read of these documents going through readDocument or isVisibleToCaller,
+ * including range scans and key history; raw reads are only used to check that an ID is free
+ */
+export const TENANT_ATTRIBUTE = 'ricetrace.tenant';
+
+/**
+ * Tenant of the invoking identity, or an empty string for channel-wide identities without the attribute
+ */
+export function getCallerTenant(ctx: Context): string {
+    return ctx.clientIdentity.getAttributeValue(TENANT_ATTRIBUTE) || '';
+}
+
+/**
+ * Whether the caller may see a document: documents without a tenant are shared, and a tenant's documents are
+ * only visible to identities of that tenant (identities without a tenant do not see them)
+ */
+export function isVisibleToCaller(ctx: Context, doc: { tenantId?: unknown }): boolean {
+    return !doc.tenantId || doc.tenantId === getCallerTenant(ctx);
+}
+
+/**
+ * Stamp a document derived from a batch or product with the tenant of its source
+ */
+export function inheritTenant<T extends { tenantId?: string }>(doc: T, source: { tenantId?: string } | null | undefined): T {
+    if (source && source.tenantId) {
+        doc.tenantId = source.tenantId;
+    }
+    return doc;
+}
+
+/**
+ * Read a JSON document from world state, keeping every stored field
+ * Returns null if the key does not exist, or if the document belongs to another tenant than the caller
+ */
+export async function readDocument<T>(ctx: Context, key: string): Promise<StoredDocument<T> | null> {
+    const data = await ctx.stub.getState(key);
+    if (!data || data.length === 0) {
+        return null;
+    }
+    const doc = JSON.parse(data.toString()) as StoredDocument<T>;
+    return isVisibleToCaller(ctx, doc) ? doc : null;
+}
+
+/**
+ * Write a JSON document to world state using deterministic serialization
+ */
+export async function writeDocument(ctx: Context, key: string, doc: object): Promise<void> {
+    await ctx.stub.putState(key, Buffer.from(stringify(sortKeysRecursive(doc))));
+}
+
+/**
+ * Apply a patch to a stored document.
+ * Only the fields in the patch are replaced; unknown fields on the stored document are carried over,
+ * so a mixed-version network does not lose data written by newer chaincode.
+ */
+export function applyPatch<T extends object>(stored: StoredDocument<T>, patch: Partial<T>): StoredDocument<T> {
+    return { ...stored, ...patch };
+}
+
+/**
+ * Read a document, apply a patch and write it back in one step
+ */
+export async function patchDocument<T extends object>(ctx: Context, key: string, patch: Partial<T>): Promise<StoredDocument<T>> {
+    const stored = await readDocument<T>(ctx, key);
+    if (!stored) {
+        throw new Error(`Document ${key} does not exist`);
+    }
+    const updated = applyPatch(stored, patch);
+    await writeDocument(ctx, key, updated);
+    return updated;
+}
+
+/**
+ * RFC3339 date-time, or a plain calendar date (treated as midnight UTC)
+ */
+const RFC3339_PATTERN = /^(\d{4})-(\d{2})-(\d{2})(?:[Tt ](\d{2}):(\d{2}):(\d{2})(\.\d+)?([Zz]|[+-]\d{2}:\d{2}))?$/;
+
+/**
+ * Parse a timestamp and normalize it to UTC RFC3339 (YYYY-MM-DDTHH:mm:ss.sssZ)
+ * The normalized form sorts lexicographically in chronological order, so it can be used in range queries
+ */
+export function normalizeTimestamp(value: string, fieldName: string): string {
+    if (typeof value !== 'string' || value.trim() === '') {
+        throw new Error(`${fieldName} is required`);
+    }
+
+    const trimmed = value.trim();
+    const match = RFC3339_PATTERN.exec(trimmed);
+    if (!match) {
+        throw new Error(`Invalid ${fieldName} "${value}": expected RFC3339 format, e.g. 2024-09-15T08:00:00Z or 2024-09-15`);
+    }
+
+    // Reject out-of-range components that Date would otherwise silently roll over (e.g. 2024-02-30)
+    const [, year, month, day, hour, minute, second] = match;
+    const daysInMonth = new Date(Date.UTC(Number(year), Number(month), 0)).getUTCDate();
+    if (Number(month) < 1 || Number(month) > 12 || Number(day) < 1 || Number(day) > daysInMonth) {
+        throw new Error(`Invalid ${fieldName} "${value}": date is out of range`);
+    }
+    if (hour !== undefined && (Number(hour) > 23 || Number(minute) > 59 || Number(second) > 59)) {
+        throw new Error(`Invalid ${fieldName} "${value}": time is out of range`);
+    }
+
+    const isoInput = hour === undefined
+        ? `${year}-${month}-${day}T00:00:00Z`
+        : trimmed.replace(/[t ]/, 'T').replace(/z$/, 'Z');
+    const parsed = new Date(isoInput);
+    if (isNaN(parsed.getTime())) {
+        throw new Error(`Invalid ${fieldName} "${value}"`);
+    }
+
+    return parsed.toISOString();
+}
+
+/**
+ * Get the transaction timestamp as a normalized UTC RFC3339 string
+ * Using the proposal timestamp keeps the value identical on every endorsing peer
+ */
+export function getTxTimestamp(ctx: Context): string {
+    const txTimestamp = ctx.stub.getTxTimestamp();
+    return new Date(txTimestamp.seconds.toNumber() * 1000).toISOString();
+}
+
+/**
+ * Normalize the end of a time range; a bare date includes the whole day
+ */
+export function normalizeEndTimestamp(value: string, fieldName: string): string {
+    const timestamp = normalizeTimestamp(value, fieldName);
+    return /^\d{4}-\d{2}-\d{2}$/.test(value.trim()) ? timestamp.replace('T00:00:00.000Z', 'T23:59:59.999Z') : timestamp;
+}
+
+/**
+ * Parse an ISO 8601 interval "<start>/<end>" of dates or RFC3339 times into normalized timestamps
+ * A bare end date includes the whole day
+ */
+export function parseInterval(value: string, fieldName: string): { from: string; to: string } {
+    const [start, end, ...rest] = (value || '').split('/');
+    if (!start || !end || rest.length > 0) {
+        throw new Error(`${fieldName} must be an interval "<start>/<end>", e.g. 2024-04-01/2024-10-31`);
+    }
+    const from = normalizeTimestamp(start, `${fieldName} start`);
+    const to = normalizeEndTimestamp(end, `${fieldName} end`);
+    assertNotBefore(to, `${fieldName} end`, from, 'its start');
+    return { from, to };
+}
+
+/**
+ * Ensure one point in time does not precede another
+ * Values that cannot be parsed (legacy free-form data) are not compared
+ */
+export function assertNotBefore(later: string, laterName: string, earlier: string, earlierName: string): void {
+    const laterTime = Date.parse(later);
+    const earlierTime = Date.parse(earlier);
+    if (isNaN(laterTime) || isNaN(earlierTime)) {
+        return;
+    }
+    if (laterTime < earlierTime) {
+        throw new Error(`${laterName} (${later}) cannot be earlier than ${earlierName} (${earlier})`);
+    }
+}
+
+/**
+ * Check whether the caller is an organization administrator
+ * Admin identities carry OU=admin in their certificate subject (NodeOUs), or the ricetrace.admin=true attribute
+ */
+export function isOrgAdmin(ctx: Context): boolean {
+    if (ctx.clientIdentity.getAttributeValue('ricetrace.admin') === 'true') {
+        return true;
+    }
+    // getID() returns "x509::<subject DN>::<issuer DN>"
+    const subject = ctx.clientIdentity.getID().split('::')[1] || '';
+    return /(^|[/,])\s*OU=admin\s*([/,]|$)/i.test(subject);
+}
+
+/**
+ * Check that the caller is an organization administrator
+ */
+export function checkOrgAdmin(ctx: Context): void {
+    if (!isOrgAdmin(ctx)) {
+        throw new Error('Permission denied: Only organization administrators can call this operation');
+    }
+}
+
+/**
+ * Organization type of each MSP; callers of other MSPs are treated as consumers
+ * Can be modified based on actual organization structure
+ */
+const MSP_ORGANIZATION_TYPES: Record<string, OrganizationType> = {
+    'Org1MSP': OrganizationType.FARM,              // Farm organization
+    'Org2MSP': OrganizationType.MIDDLEMAN_TESTER,  // Middleman/tester organization
+    'Org3MSP': OrganizationType.CONSUMER           // Consumer organization
+};
+
+/**
+ * Get organization type based on MSP ID
+ */
+export function getOrganizationType(mspId: string): OrganizationType {
+    return MSP_ORGANIZATION_TYPES[mspId] || OrganizationType.CONSUMER;
+}
+
+/**
+ * Check that the caller's organization is of one of the allowed types
+ */
+export function checkOrgType(ctx: Context, allowedTypes: OrganizationType[]): void {
+    const callerType = getOrganizationType(ctx.clientIdentity.getMSPID());
+
+    if (!allowedTypes.includes(callerType)) {
+        const allowedNames = allowedTypes.map(type => {
+            switch (type) {
+                case OrganizationType.FARM: return 'Farm';
+                case OrganizationType.MIDDLEMAN_TESTER: return 'Middleman/tester';
+                case OrganizationType.CONSUMER: return 'Consumer';
+                default: return 'Unknown';
+            }
+        }).join(', ');
+
+        throw new Error(`Permission denied: Only the following organization types can call: ${allowedNames}`);
+    }
+}
+
+/**
+ * Check whether a recorded test outcome counts as passed
+ */
+export function isPassingResult(result: string): boolean {
+    return ['pass', 'passed', 'qualified', 'ok'].includes((result || '').trim().toLowerCase());
+}
+
+/**
+ * Work out when a certificate expires
+ * validityPeriod is either an explicit expiry date or a duration such as "12 months", "365 days" or "2 years"
+ * Returns null when the certificate has no determinable expiry
+ */
+export function getCertificateExpiry(issueDate: string, validityPeriod: string): string | null {
+    const period = (validityPeriod || '').trim();
+    if (period === '') {
+        return null;
+    }
+
+    if (/^\d{4}-\d{2}-\d{2}/.test(period)) {
+        try {
+            return normalizeTimestamp(period, 'validityPeriod');
+        } catch {
+            return null;
+        }
+    }
+
+    const match = /^(\d+)\s*(day|month|year)s?$/i.exec(period);
+    const issued = new Date(issueDate);
+    if (!match || isNaN(issued.getTime())) {
+        return null;
+    }
+
+    const amount = Number(match[1]);
+    switch (match[2].toLowerCase()) {
+        case 'day': issued.setUTCDate(issued.getUTCDate() + amount); break;
+        case 'month': issued.setUTCMonth(issued.getUTCMonth() + amount); break;
+        default: issued.setUTCFullYear(issued.getUTCFullYear() + amount); break;
+    }
+    return issued.toISOString();
+}
+
+/**
+ * Emit a chaincode event carrying the affected document
+ * Fabric keeps only the last event set in a transaction, so each transaction emits exactly one
+ */
+export function emitEvent(ctx: Context, eventName: string, payload: object): void {
+    ctx.stub.setEvent(eventName, Buffer.from(stringify(sortKeysRecursive(payload))));
+}
+
+/**
+ * Build the disposition record of a disposed batch or product
+ */
+export function createDisposal(ctx: Context, reason: string, method: string, quantity: string, handler: string): Disposal {
+    if (!reason || !method || !quantity || !handler) {
+        throw new Error('Disposal reason, method, quantity and handler are required');
+    }
+    return {
+        reason,
+        method,
+        quantity,
+        handler,
+        timestamp: getTxTimestamp(ctx),
+        recordedBy: ctx.clientIdentity.getMSPID()
+    };
+}
+
+/**
+ * Compute the SHA-256 fingerprint (lowercase hex) of a PEM encoded X.509 certificate
+ * The hash is taken over the DER bytes, matching `openssl x509 -fingerprint -sha256`
+ */
+export function certificateFingerprint(certPEM: string): string {
+    const base64 = (certPEM || '')
+        .replace(/-----BEGIN CERTIFICATE-----|-----END CERTIFICATE-----/g, '')
+        .replace(/\s+/g, '');
+    if (base64 === '') {
+        throw new Error('Invalid certificate: PEM content is empty');
+    }
+    return createHash('sha256').update(Buffer.from(base64, 'base64')).digest('hex');
+}
+
+/**
+ * Get the certificate fingerprint of the invoking identity
+ */
+export function getCallerFingerprint(ctx: Context): string {
+    return certificateFingerprint(Buffer.from(ctx.clientIdentity.getIDBytes()).toString('utf8'));
+}
+
+/**
+ * Add an entry to a composite key index
+ * Index entries carry no data; the indexed document is read by its primary key
+ */
+export async function putIndexEntry(ctx: Context, indexName: string, attributes: string[]): Promise<void> {
+    if (attributes.some(attribute => !attribute)) {
+        return;
+    }
+    await ctx.stub.putState(ctx.stub.createCompositeKey(indexName, attributes), Buffer.from([0x00]));
+}
+
+/**
+ * Remove an entry from a composite key index
+ */
+export async function deleteIndexEntry(ctx: Context, indexName: string, attributes: string[]): Promise<void> {
+    if (attributes.some(attribute => !attribute)) {
+        return;
+    }
+    await ctx.stub.deleteState(ctx.stub.createCompositeKey(indexName, attributes));
+}
+
+/**
+ * Get the attributes of every index entry matching a partial key
+ */
+export async function getIndexEntries(ctx: Context, indexName: string, partialAttributes: string[]): Promise<string[][]> {
+    const resultsIterator = await ctx.stub.getStateByPartialCompositeKey(indexName, partialAttributes);
+    const entries: string[][] = [];
+
+    let result = await resultsIterator.next();
+    while (!result.done) {
+        if (result.value) {
+            entries.push(ctx.stub.splitCompositeKey(result.value.key).attributes);
+        }
+        result = await resultsIterator.next();
+    }
+
+    await resultsIterator.close();
+    return entries;
+}
+
+/**
+ * Limits on the labels of a batch or product; the number of labels is an input limit, 20 until configured
+ */
+export const DEFAULT_MAX_LABELS = 20;
+const MAX_LABEL_VALUE_LENGTH = 256;
+const LABEL_KEY_PATTERN = /^[a-z0-9]([a-z0-9._/-]{0,61}[a-z0-9])?$/;
+const RESERVED_LABEL_PREFIXES = ['ricetrace.', 'fabric.'];
+
+/**
+ * Parse and validate a labels map { key: value } of at most maxLabels labels
+ * Keys are lowercase letters, digits and . _ - / (at most 63 characters, not under a reserved prefix);
+ * values are non-empty strings of at most 256 printable characters
+ */
+export function parseLabels(labelsJSON: string, maxLabels = DEFAULT_MAX_LABELS): Record<string, string> {
+    let input: unknown;
+    try {
+        input = JSON.parse(labelsJSON);
+    } catch (error) {
+        throw new Error(`Labels format error: ${error}`);
+    }
+    if (!input || typeof input !== 'object' || Array.isArray(input)) {
+        throw new Error('Labels must be an object of key-value pairs');
+    }
+
+    const entries = Object.entries(input as Record<string, unknown>);
+    if (entries.length > maxLabels) {
+        throw new Error(`At most ${maxLabels} labels can be set, got ${entries.length}`);
+    }
+    const labels: Record<string, string> = {};
+    for (const [key, value] of entries) {
+        if (!LABEL_KEY_PATTERN.test(key)) {
+            throw new Error(`Invalid label key ${key}: use up to 63 lowercase letters, digits, '.', '_', '-' or '/'`);
+        }
+        const reserved = RESERVED_LABEL_PREFIXES.find(prefix => key.startsWith(prefix));
+        if (reserved) {
+            throw new Error(`Label key ${key} uses the reserved prefix ${reserved}`);
+        }
+        if (typeof value !== 'string' || value === '' || value.length > MAX_LABEL_VALUE_LENGTH) {
+            throw new Error(`Label ${key} must be a non-empty string of at most ${MAX_LABEL_VALUE_LENGTH} characters`);
+        }
+        if ([...value].some(char => char < ' ' || char === '\u007f')) {
+            throw new Error(`Label ${key} contains control characters`);
+        }
+        labels[key] = value;
+    }
+    return labels;
+}
+
+/**
+ * Move an entity's entries in a label index ('<key>~<value>~<id>') from its previous labels to its new ones
+ */
+export async function updateLabelIndex(
+    ctx: Context,
+    indexName: string,
+    entityId: string,
+    previous: Record<string, string> | undefined,
+    labels: Record<string, string>
+): Promise<void> {
+    for (const [key, value] of Object.entries(previous || {})) {
+        if (labels[key] !== value) {
+            await deleteIndexEntry(ctx, indexName, [key, value, entityId]);
+        }
+    }
+    for (const [key, value] of Object.entries(labels)) {
+        await putIndexEntry(ctx, indexName, [key, value, entityId]);
+    }
+}
+
+/**
+ * Get the IDs of the entities carrying a label, optionally with a specific value
+ */
+export async function getLabeledIds(ctx: Context, indexName: string, key: string, value: string): Promise<string[]> {
+    if (!key) {
+        throw new Error('Label key is required');
+    }
+    const entries = await getIndexEntries(ctx, indexName, value ? [key, value] : [key]);
+    return entries.map(([, , entityId]) => entityId);
+}
+
+/**
+ * Get the ledger key of a processed client request
+ * Request IDs are scoped to the submitting organization, so organizations cannot collide
+ */
+function processedRequestKey(ctx: Context, clientRequestId: string): string {
+    return `request_${ctx.clientIdentity.getMSPID()}_${clientRequestId}`;
+}
+
+/**
+ * Check whether a client request ID was already processed (idempotent retry)
+ * Gateways retrying after a timeout resubmit with the same ID; the replay must not apply the operation again.
+ * An empty ID disables the check.
+ */
+export async function isProcessedRequest(ctx: Context, clientRequestId: string, operation: string): Promise<boolean> {
+    if (!clientRequestId) {
+        return false;
+    }
+
+    const processed = await readDocument<ProcessedRequest>(ctx, processedRequestKey(ctx, clientRequestId));
+    if (!processed) {
+        return false;
+    }
+    if (processed.operation !== operation) {
+        throw new Error(`Client request ID ${clientRequestId} was already used for ${processed.operation}`);
+    }
+    return true;
+}
+
+/**
+ * Record a client request ID as processed by the current transaction
+ */
+export async function markRequestProcessed(ctx: Context, clientRequestId: string, operation: string): Promise<void> {
+    if (!clientRequestId) {
+        return;
+    }
+
+    const record: ProcessedRequest = {
+        docType: 'processedRequest',
+        clientRequestId,
+        operation,
+        mspId: ctx.clientIdentity.getMSPID(),
+        txId: ctx.stub.getTxID(),
+        timestamp: getTxTimestamp(ctx)
+    };
+    await writeDocument(ctx, processedRequestKey(ctx, clientRequestId), record);
+}
+
+/**
+ * Restrict endorsement of later updates to a key to the given organizations (state-based endorsement)
+ * A peer of every listed organization must endorse; this overrides the chaincode-level policy for the key
+ */
+export async function setKeyEndorsers(ctx: Context, key: string, mspIds: string[]): Promise<void> {
+    const orgs = [...new Set(mspIds.filter(mspId => !!mspId))].sort();
+    if (orgs.length === 0) {
+        throw new Error(`Key ${key} requires at least one endorsing organization`);
+    }
+
+    const policy = new KeyEndorsementPolicy();
+    policy.addOrgs('MEMBER', ...orgs);
+    await ctx.stub.setStateValidationParameter(key, policy.getPolicy());
+}
+
+// Private data collections, defined per organization pair in collections.yaml
+export const TEST_REPORT_COLLECTION = 'testReports';
+export const PRICING_COLLECTION = 'pricing';
+export const ACCESS_AUDIT_COLLECTION = 'accessAudit';
+
+/**
+ * Name of the private data collection shared by two organizations: <collection>_<MspA>_<MspB>, MSP IDs sorted
+ * Must match the names produced by my-js/tools/collections-gen.js
+ */
+export function pairCollectionName(collection: string, mspA: string, mspB: string): string {
+    return [collection, ...[mspA, mspB].sort()].join('_');
+}
+
+/**
+ * Name of an organization's implicit private data collection (exists on every channel, no configuration)
+ */
+export function implicitCollectionName(mspId: string): string {
+    return `_implicit_org_${mspId}`;
+}
+
+/**
+ * Require the endorsing peer to belong to the caller's organization
+ * Private data in an implicit collection is only stored on that organization's peers
+ */
+export function assertPeerOrgMatchesClient(ctx: Context): void {
+    const clientMspId = ctx.clientIdentity.getMSPID();
+    const peerMspId = ctx.stub.getMspID();
+    if (clientMspId !== peerMspId) {
+        throw new Error(`Client from ${clientMspId} cannot use private data on a peer of ${peerMspId}; submit to a ${clientMspId} peer`);
+    }
+}
+
+/**
+ * SHA-256 (lowercase hex) of a UTF-8 string
+ */
+export function sha256Hex(value: string): string {
+    return createHash('sha256').update(value, 'utf8').digest('hex');
+}
+
+/**
+ * SHA-256 (lowercase hex) of a document in its canonical (sorted-key) JSON form, as written by writeDocument
+ */
+export function documentHash(doc: object): string {
+    return sha256Hex(stringify(sortKeysRecursive(doc)));
+}
+
+/**
+ * List the fields that differ between two versions of a document, depth first in key order
+ * Objects are compared field by field and arrays element by element, so an appended history event
+ * shows as one added element rather than a modified array
+ */
+export function diffDocuments(before: unknown, after: unknown, path = ''): FieldChange[] {
+    const isObject = (value: unknown) => typeof value === 'object' && value !== null;
+    if (isObject(before) && isObject(after) && Array.isArray(before) === Array.isArray(after)) {
+        const changes: FieldChange[] = [];
+        const oldValue = before as Record<string, unknown>;
+        const newValue = after as Record<string, unknown>;
+        const keys = Array.isArray(before)
+            ? [...Array(Math.max(before.length, (after as unknown[]).length)).keys()].map(String)
+            : [...new Set([...Object.keys(oldValue), ...Object.keys(newValue)])].sort();
+        for (const key of keys) {
+            const childPath = Array.isArray(before) ? `${path}[${key}]` : path ? `${path}.${key}` : key;
+            if (!(key in oldValue)) {
+                changes.push({ path: childPath, change: 'added', after: JSON.stringify(newValue[key]) });
+            } else if (!(key in newValue)) {
+                changes.push({ path: childPath, change: 'removed', before: JSON.stringify(oldValue[key]) });
+            } else {
+                changes.push(...diffDocuments(oldValue[key], newValue[key], childPath));
+            }
+        }
+        return changes;
+    }
+    const oldJSON = JSON.stringify(before);
+    const newJSON = JSON.stringify(after);
+    return oldJSON === newJSON ? [] : [{ path, change: 'modified', before: oldJSON, after: newJSON }];
+}
@@ -0,0 +1,142 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context, Contract, Info, Returns, Transaction } from 'fabric-contract-api';
+import { OrganizationType, WeatherObservation } from './types';
+import {
+    readDocument, writeDocument, normalizeTimestamp, normalizeEndTimestamp, parseInterval, getTxTimestamp, emitEvent, putIndexEntry,
+    getIndexEntries, sha256Hex, checkOrgType
+} from './utils';
+
+/**
+ * Composite key index of weather observations by plot and period start
+ */
+export const PLOT_WEATHER_INDEX = 'plot~periodStart~dataHash';
+
+@Info({ title: 'WeatherDataContract', description: 'Smart contract anchoring weather observations of plots with verifiable hashes' })
+export class WeatherDataContract extends Contract {
+
+    /**
+     * Get permission configuration for all methods
+     */
+    @Transaction(false)
+    @Returns('string')
+    public async GetPermissionMatrix(ctx: Context): Promise<string> {
+        const permissionMatrix = {
+            "WeatherDataContract Method Permission Configuration": {
+                "AnchorWeatherData": ["Farm", "Middleman/Tester"],
+                "ReadWeatherObservation": ["All Organizations"],
+                "GetWeatherObservations": ["All Organizations"],
+                "VerifyWeatherData": ["All Organizations"],
+                "GetPermissionMatrix": ["All Organizations"]
+            }
+        };
+
+        return JSON.stringify(permissionMatrix, null, 2);
+    }
+
+    /**
+     * Anchor a weather observation of a plot, e.g. the station feed covering a harvest window
+     * period is an ISO 8601 interval "<start>/<end>"; dataHash is the SHA-256 (hex) of the raw feed data, which
+     * stays off-chain and identifies the observation. Quality claims ("harvested during a dry window") and
+     * insurance claims cite the observation by its hash
+     * Permission: Farm and middleman/tester can call
+     */
+    @Transaction()
+    public async AnchorWeatherData(
+        ctx: Context,
+        plotId: string,
+        period: string,
+        source: string,
+        dataHash: string,
+        summary: string
+    ): Promise<void> {
+        // Check permission: Only farm and middleman/tester organizations make quality claims that rely on weather
+        checkOrgType(ctx, [OrganizationType.FARM, OrganizationType.MIDDLEMAN_TESTER]);
+
+        if (!plotId || !source || !summary) {
+            throw new Error('Plot ID, source and summary are required');
+        }
+        const { from, to } = parseInterval(period, 'Observation period');
+        const now = getTxTimestamp(ctx);
+        if (from > now) {
+            throw new Error(`Observation period cannot start in the future (${from})`);
+        }
+        const hash = (dataHash || '').toLowerCase();
+        if (!/^[0-9a-f]{64}$/.test(hash)) {
+            throw new Error('Weather data hash must be a SHA-256 digest (64 hex characters)');
+        }
+        const existing = await readDocument<WeatherObservation>(ctx, `weather_${hash}`);
+        if (existing) {
+            throw new Error(`Weather data ${hash} has already been anchored for plot ${existing.plotId}`);
+        }
+
+        const observation: WeatherObservation = {
+            docType: 'weatherObservation',
+            dataHash: hash,
+            plotId,
+            periodStart: from,
+            periodEnd: to,
+            source,
+            summary,
+            anchoredBy: ctx.clientIdentity.getMSPID(),
+            anchoredAt: now
+        };
+        await writeDocument(ctx, `weather_${hash}`, observation);
+        await putIndexEntry(ctx, PLOT_WEATHER_INDEX, [plotId, from, hash]);
+        emitEvent(ctx, 'WeatherDataAnchored', observation);
+    }
+
+    /**
+     * Read a weather observation by the hash of its data
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('WeatherObservation')
+    public async ReadWeatherObservation(ctx: Context, dataHash: string): Promise<WeatherObservation> {
+        const observation = await readDocument<WeatherObservation>(ctx, `weather_${(dataHash || '').toLowerCase()}`);
+        if (!observation) {
+            throw new Error(`Weather observation ${dataHash} does not exist`);
+        }
+        return observation;
+    }
+
+    /**
+     * Get the weather observations of a plot whose period overlaps a time range, by period start
+     * from and to are optional dates or RFC3339 times; a bare end date includes the whole day
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('WeatherObservation[]')
+    public async GetWeatherObservations(ctx: Context, plotId: string, from: string, to: string): Promise<WeatherObservation[]> {
+        if (!plotId) {
+            throw new Error('Plot ID is required');
+        }
+        const rangeStart = from ? normalizeTimestamp(from, 'from') : '';
+        const rangeEnd = to ? normalizeEndTimestamp(to, 'to') : '';
+
+        const observations: WeatherObservation[] = [];
+        for (const [, periodStart, hash] of await getIndexEntries(ctx, PLOT_WEATHER_INDEX, [plotId])) {
+            if (rangeEnd && periodStart > rangeEnd) {
+                continue;
+            }
+            const observation = await readDocument<WeatherObservation>(ctx, `weather_${hash}`);
+            if (observation && (!rangeStart || observation.periodEnd >= rangeStart)) {
+                observations.push(observation);
+            }
+        }
+        return observations;
+    }
+
+    /**
+     * Check raw weather feed data against an anchored observation
+     * Permission: No restriction
+     */
+    @Transaction(false)
+    @Returns('boolean')
+    public async VerifyWeatherData(ctx: Context, dataHash: string, data: string): Promise<boolean> {
+        const observation = await this.ReadWeatherObservation(ctx, dataHash);
+        return sha256Hex(data) === observation.dataHash;
+    }
+}
@@ -0,0 +1,268 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+import { Context } from 'fabric-contract-api';
+import { ChaincodeStub, ClientIdentity } from 'fabric-shim';
+
+/**
+ * Fake transaction context for contract unit tests
+ * Backs the chaincode stub with an in-memory state map and implements the stub and client identity
+ * methods the contracts use. Every method is a jest mock, so tests can assert on calls or override
+ * a single call with mockResolvedValueOnce / mockReturnValueOnce.
+ */
+
+export const TEST_CERT_PEM = '-----BEGIN CERTIFICATE-----\nAAEC\n-----END CERTIFICATE-----\n';
+export const TEST_TIMESTAMP_SECONDS = 1727000000; // 2024-09-22T10:13:20Z
+
+const COMPOSITE_KEY_NAMESPACE = '\x00';
+const MAX_UNICODE_RUNE = '\u{10FFFF}';
+
+export interface MockIdentity {
+    mspId: string;
+    id?: string;
+    certPEM?: string;
+    attributes?: Record<string, string>;
+}
+
+export interface MockContextOptions extends Partial<MockIdentity> {
+    peerMspId?: string; // Organization of the endorsing peer; defaults to the caller's organization
+    channelId?: string; // Channel of the transaction; defaults to channel1
+    txId?: string;
+    timestampSeconds?: number;
+    transient?: Record<string, string>;
+}
+
+export interface MockKeyModification {
+    txId: string;
+    timestampSeconds: number;
+    isDelete: boolean;
+    value: Buffer;
+}
+
+export interface MockEvent {
+    name: string;
+    payload: any;
+}
+
+/**
+ * State and helpers added to the stub for test setup and assertions
+ */
+export interface MockStubState {
+    state: Map<string, Buffer>;
+    validationParameters: Map<string, Buffer>; // key-level endorsement policies
+    privateData: Map<string, Map<string, Buffer>>; // collection -> key -> value
+    keyHistory: Map<string, MockKeyModification[]>; // key -> writes and deletes, oldest first
+    events: MockEvent[];
+    /** Store a JSON document directly (test setup) */
+    putJSON(key: string, value: object): void;
+    /** Read a JSON document directly, undefined when absent */
+    getJSON<T = any>(key: string): T | undefined;
+    /** Whether a composite (index) key exists */
+    hasCompositeKey(objectType: string, attributes: string[]): boolean;
+    /** Set the transient data passed with later calls */
+    setTransient(transient: Record<string, string>): void;
+    /** Change the transaction timestamp used by later calls */
+    setTxTimestamp(seconds: number): void;
+    /** Start a new transaction: new txID, cleared events */
+    nextTransaction(txId?: string): void;
+    /** Change the organization of the endorsing peer ('' follows the caller's organization) */
+    setPeerMspId(mspId: string): void;
+}
+
+export interface MockIdentityControl {
+    /** Switch the calling identity (e.g. to test another organization's permissions) */
+    setIdentity(identity: MockIdentity): void;
+}
+
+export type MockContext = Context & {
+    stub: ChaincodeStub & MockStubState;
+    clientIdentity: ClientIdentity & MockIdentityControl;
+};
+
+function defaultId(mspId: string): string {
+    return `x509::/OU=client/CN=User1@${mspId}::/CN=ca.${mspId}`;
+}
+
+/**
+ * Create a fake transaction context
+ * Defaults to a client of Org1MSP at TEST_TIMESTAMP_SECONDS with an empty ledger
+ */
+export function createMockContext(options: MockContextOptions = {}): MockContext {
+    const state = new Map<string, Buffer>();
+    const validationParameters = new Map<string, Buffer>();
+    const privateData = new Map<string, Map<string, Buffer>>();
+    const keyHistory = new Map<string, MockKeyModification[]>();
+    const events: MockEvent[] = [];
+    let txCounter = 1;
+    let txId = options.txId || `tx${txCounter}`;
+    let timestampSeconds = options.timestampSeconds ?? TEST_TIMESTAMP_SECONDS;
+    let transient = new Map<string, Buffer>();
+    let peerMspId = options.peerMspId || '';
+    let identity: MockIdentity = {
+        mspId: options.mspId || 'Org1MSP',
+        id: options.id,
+        certPEM: options.certPEM,
+        attributes: options.attributes
+    };
+
+    const createCompositeKey = (objectType: string, attributes: string[]): string =>
+        `${COMPOSITE_KEY_NAMESPACE}${objectType}${COMPOSITE_KEY_NAMESPACE}${attributes.map(attribute => `${attribute}${COMPOSITE_KEY_NAMESPACE}`).join('')}`;
+
+    const recordModification = (key: string, isDelete: boolean, value: Buffer) => {
+        keyHistory.set(key, [...(keyHistory.get(key) || []), { txId, timestampSeconds, isDelete, value }]);
+    };
+
+    const keysInRange = (startKey: string, endKey: string): string[] =>
+        [...state.keys()].filter(key => key >= startKey && (endKey === '' || key < endKey)).sort();
+
+    const iterator = (keys: string[]) => {
+        let index = 0;
+        return {
+            next: async () => index < keys.length
+                ? { value: { key: keys[index], value: state.get(keys[index++]) as Buffer }, done: false }
+                : { value: undefined, done: true },
+            close: async () => undefined
+        };
+    };
+
+    const stub = {
+        state,
+        validationParameters,
+        privateData,
+        keyHistory,
+        events,
+
+        getTxID: jest.fn(() => txId),
+        getTxTimestamp: jest.fn(() => ({
+            seconds: { low: timestampSeconds, high: 0, toNumber: () => timestampSeconds },
+            nanos: 0
+        })),
+        getTransient: jest.fn(() => transient),
+        getMspID: jest.fn(() => peerMspId || identity.mspId),
+        getChannelID: jest.fn(() => options.channelId || 'channel1'),
+        // No other chaincode or channel is reachable unless a test provides a response
+        invokeChaincode: jest.fn(async (chaincodeName: string, args: string[], channel: string) => ({
+            status: 500,
+            message: `chaincode ${chaincodeName} is not available on channel ${channel}`,
+            payload: Buffer.from('')
+        })),
+        getState: jest.fn(async (key: string) => state.get(key) || Buffer.from('')),
+        putState: jest.fn(async (key: string, value: Uint8Array) => {
+            state.set(key, Buffer.from(value));
+            recordModification(key, false, Buffer.from(value));
+        }),
+        deleteState: jest.fn(async (key: string) => {
+            state.delete(key);
+            recordModification(key, true, Buffer.from(''));
+        }),
+        // Newest first, as returned by a peer
+        getHistoryForKey: jest.fn(async (key: string) => {
+            const modifications = [...(keyHistory.get(key) || [])].reverse();
+            let index = 0;
+            return {
+                next: async () => {
+                    if (index >= modifications.length) {
+                        return { value: undefined, done: true };
+                    }
+                    const modification = modifications[index++];
+                    return {
+                        value: {
+                            txId: modification.txId,
+                            timestamp: { seconds: { low: modification.timestampSeconds, high: 0, toNumber: () => modification.timestampSeconds }, nanos: 0 },
+                            isDelete: modification.isDelete,
+                            value: modification.value
+                        },
+                        done: false
+                    };
+                },
+                close: async () => undefined
+            };
+        }),
+        setStateValidationParameter: jest.fn(async (key: string, ep: Uint8Array) => { validationParameters.set(key, Buffer.from(ep)); }),
+        getStateValidationParameter: jest.fn(async (key: string) => validationParameters.get(key) || Buffer.from('')),
+        getPrivateData: jest.fn(async (collection: string, key: string) => privateData.get(collection)?.get(key) || Buffer.from('')),
+        putPrivateData: jest.fn(async (collection: string, key: string, value: Uint8Array) => {
+            if (!privateData.has(collection)) {
+                privateData.set(collection, new Map());
+            }
+            (privateData.get(collection) as Map<string, Buffer>).set(key, Buffer.from(value));
+        }),
+        purgePrivateData: jest.fn(async (collection: string, key: string) => {
+            privateData.get(collection)?.delete(key);
+        }),
+        getPrivateDataByRange: jest.fn(async (collection: string, startKey: string, endKey: string) => {
+            const entries = privateData.get(collection) || new Map<string, Buffer>();
+            const keys = [...entries.keys()].filter(key => key >= startKey && (endKey === '' || key < endKey)).sort();
+            let index = 0;
+            return {
+                next: async () => index < keys.length
+                    ? { value: { key: keys[index], value: entries.get(keys[index++]) as Buffer }, done: false }
+                    : { value: undefined, done: true },
+                close: async () => undefined
+            };
+        }),
+        // Simple-key range scans never return composite keys, as on a peer
+        getStateByRange: jest.fn(async (startKey: string, endKey: string) =>
+            iterator(keysInRange(startKey, endKey).filter(key => !key.startsWith(COMPOSITE_KEY_NAMESPACE)))
+        ),
+        getStateByRangeWithPagination: jest.fn(async (startKey: string, endKey: string, pageSize: number, bookmark: string) => {
+            const keys = keysInRange(bookmark || startKey, endKey).filter(key => !key.startsWith(COMPOSITE_KEY_NAMESPACE));
+            const page = keys.slice(0, pageSize);
+            return {
+                iterator: iterator(page),
+                metadata: { fetchedRecordsCount: page.length, bookmark: keys[pageSize] || '' }
+            };
+        }),
+        createCompositeKey: jest.fn(createCompositeKey),
+        splitCompositeKey: jest.fn((compositeKey: string) => {
+            const parts = compositeKey.split(COMPOSITE_KEY_NAMESPACE).slice(1, -1);
+            return { objectType: parts[0], attributes: parts.slice(1) };
+        }),
+        getStateByPartialCompositeKey: jest.fn(async (objectType: string, attributes: string[]) => {
+            const prefix = createCompositeKey(objectType, attributes);
+            return iterator(keysInRange(prefix, `${prefix}${MAX_UNICODE_RUNE}`));
+        }),
+        getStateByPartialCompositeKeyWithPagination: jest.fn(async (objectType: string, attributes: string[], pageSize: number, bookmark: string) => {
+            const prefix = createCompositeKey(objectType, attributes);
+            const keys = keysInRange(bookmark || prefix, `${prefix}${MAX_UNICODE_RUNE}`);
+            const page = keys.slice(0, pageSize);
+            return {
+                iterator: iterator(page),
+                metadata: { fetchedRecordsCount: page.length, bookmark: keys[pageSize] || '' }
+            };
+        }),
+        setEvent: jest.fn((name: string, payload: Uint8Array) => {
+            // Fabric keeps only the last event set by a transaction
+            events.splice(0, events.length, { name, payload: JSON.parse(Buffer.from(payload).toString()) });
+        }),
+
+        putJSON: (key: string, value: object) => { state.set(key, Buffer.from(JSON.stringify(value))); },
+        getJSON: (key: string) => {
+            const value = state.get(key);
+            return value && value.length > 0 ? JSON.parse(value.toString()) : undefined;
+        },
+        hasCompositeKey: (objectType: string, attributes: string[]) => state.has(createCompositeKey(objectType, attributes)),
+        setTransient: (values: Record<string, string>) => {
+            transient = new Map(Object.entries(values).map(([key, value]) => [key, Buffer.from(value)]));
+        },
+        setTxTimestamp: (seconds: number) => { timestampSeconds = seconds; },
+        nextTransaction: (nextTxId?: string) => {
+            txId = nextTxId || `tx${++txCounter}`;
+            events.length = 0;
+        },
+        setPeerMspId: (mspId: string) => { peerMspId = mspId; }
+    };
+
+    const clientIdentity = {
+        getMSPID: jest.fn(() => identity.mspId),
+        getID: jest.fn(() => identity.id || defaultId(identity.mspId)),
+        getIDBytes: jest.fn(() => Buffer.from(identity.certPEM || TEST_CERT_PEM)),
+        getAttributeValue: jest.fn((name: string) => (identity.attributes || {})[name] ?? null),
+        assertAttributeValue: jest.fn((name: string, value: string) => (identity.attributes || {})[name] === value),
+        setIdentity: (next: MockIdentity) => { identity = next; }
+    };
+
+    stub.setTransient(options.transient || {});
+    return { stub, clientIdentity } as unknown as MockContext;
+}
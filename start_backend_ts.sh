@@ -30,7 +30,7 @@ cd ../../test-network
 
 # deploy TypeScript chaincode
 echo "📦 Deploying TypeScript chaincode..."
-./network.sh deployCC -ccn basic -ccp ../asset-transfer-basic/my-ts/ -ccl typescript -c channel1
+./network.sh deployCC -ccn basic -ccp ../asset-transfer-basic/my-ts/ -ccl typescript -c channel1 -cccg ../asset-transfer-basic/my-ts/collections_config.json
 
 # start Redis container
 echo "🔴 Starting Redis container..."